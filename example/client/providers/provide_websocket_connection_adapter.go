@@ -7,6 +7,7 @@ import (
 )
 
 func ProviderWebsocketConnectionAdapter(tracerProvider trace.TracerProvider) wsadapters.WebsocketConnectionAdapterInterface {
-	// Return a websocket connection adapter which uuses nhooyr websocket library under the hood
-	return gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil)
+	// Return a websocket connection adapter which uuses nhooyr websocket library under the hood.
+	// Ping/pong cycles are traced with the provided tracer provider.
+	return gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil, gorilla.WithTracerProvider(tracerProvider))
 }
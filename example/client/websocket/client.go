@@ -64,6 +64,7 @@ func (client *ExampleClientImpl) OnOpen(
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
 	exit context.CancelFunc,
+	sessionId string,
 	restarting bool) error {
 	// Start a new span
 	_, span := client.tracer.Start(ctx, "wscengine.example.client.on_open", trace.WithSpanKind(trace.SpanKindClient))
@@ -137,6 +138,7 @@ func (client *ExampleClientImpl) OnReadError(
 	readMutex *sync.Mutex,
 	restart context.CancelFunc,
 	exit context.CancelFunc,
+	sessionId string,
 	err error) {
 	// Start a new span
 	_, span := client.tracer.Start(ctx, "wscengine.example.client.on_read_error", trace.WithSpanKind(trace.SpanKindClient))
@@ -152,6 +154,7 @@ func (client *ExampleClientImpl) OnClose(
 	ctx context.Context,
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
+	sessionId string,
 	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
 	// Start a new span
 	_, span := client.tracer.Start(ctx, "wscengine.example.client.on_close", trace.WithSpanKind(trace.SpanKindClient),
@@ -165,6 +168,7 @@ func (client *ExampleClientImpl) OnClose(
 
 func (client *ExampleClientImpl) OnCloseError(
 	ctx context.Context,
+	sessionId string,
 	err error) {
 	// Start a new span
 	_, span := client.tracer.Start(ctx, "wscengine.example.client.on_close_error", trace.WithSpanKind(trace.SpanKindClient))
@@ -179,6 +183,7 @@ func (client *ExampleClientImpl) OnCloseError(
 func (client *ExampleClientImpl) OnRestartError(
 	ctx context.Context,
 	exit context.CancelFunc,
+	sessionId string,
 	err error,
 	retryCount int) {
 	// Start a new span
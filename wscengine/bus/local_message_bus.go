@@ -0,0 +1,125 @@
+// The package contains an in-process message bus which can be used to dispatch received messages
+// to local goroutines by topic, without requiring an external messaging middleware.
+package bus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Identifier of a subscription returned by Subscribe.
+type SubscriptionID uint64
+
+// Function which extracts the topic of a message. An empty topic means the message must not be
+// published.
+type TopicExtractor func(msg []byte) string
+
+// In-process publish/subscribe message bus.
+//
+// Publish never blocks: a message is delivered to a subscriber only if its channel is ready to
+// receive it (= has free capacity or a goroutine is waiting on it). Use buffered channels to
+// absorb bursts. The same message slice is delivered to all subscribers: subscribers must not
+// modify it.
+//
+// The bus is safe for concurrent use.
+type LocalMessageBus struct {
+	// Mutex used to protect subscriptions
+	mu sync.RWMutex
+	// Last subscription ID which has been issued
+	lastId SubscriptionID
+	// Subscriber channels by topic and subscription ID
+	topics map[string]map[SubscriptionID]chan<- []byte
+	// Topic by subscription ID
+	subscriptions map[SubscriptionID]string
+}
+
+// # Description
+//
+// Factory which creates a new LocalMessageBus without subscriptions.
+func NewLocalMessageBus() *LocalMessageBus {
+	return &LocalMessageBus{
+		topics:        map[string]map[SubscriptionID]chan<- []byte{},
+		subscriptions: map[SubscriptionID]string{},
+	}
+}
+
+// # Description
+//
+// Subscribe the provided channel to a topic. The bus never closes the channel: the channel can be
+// closed by its owner once Unsubscribe has returned.
+//
+// # Returns
+//
+// The subscription ID to use to unsubscribe.
+func (bus *LocalMessageBus) Subscribe(topic string, ch chan<- []byte) SubscriptionID {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.lastId++
+	id := bus.lastId
+	subscribers, found := bus.topics[topic]
+	if !found {
+		subscribers = map[SubscriptionID]chan<- []byte{}
+		bus.topics[topic] = subscribers
+	}
+	subscribers[id] = ch
+	bus.subscriptions[id] = topic
+	return id
+}
+
+// # Description
+//
+// Remove a subscription. Once the method has returned, no message will be delivered to the
+// subscription channel anymore. Unknown subscription IDs are ignored.
+func (bus *LocalMessageBus) Unsubscribe(id SubscriptionID) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	topic, found := bus.subscriptions[id]
+	if !found {
+		return
+	}
+	delete(bus.subscriptions, id)
+	delete(bus.topics[topic], id)
+	if len(bus.topics[topic]) == 0 {
+		delete(bus.topics, topic)
+	}
+}
+
+// # Description
+//
+// Deliver the message to all subscribers of the topic which are ready to receive it.
+//
+// # Returns
+//
+// The number of subscribers the message has been delivered to.
+func (bus *LocalMessageBus) Publish(topic string, msg []byte) int {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	delivered := 0
+	for _, ch := range bus.topics[topic] {
+		select {
+		case ch <- msg:
+			delivered++
+		default:
+			// Subscriber is not ready - Skip
+		}
+	}
+	return delivered
+}
+
+// # Description
+//
+// Create a message handler which publishes received messages to the topic returned by the
+// provided extractor. The handler can be used as the innermost handler of a middleware chain or
+// be called from the user provided OnMessage callback.
+//
+// Messages for which the extractor returns an empty topic are discarded.
+func (bus *LocalMessageBus) PublishHandler(extractor TopicExtractor) middleware.MessageHandler {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		if topic := extractor(msg); topic != "" {
+			bus.Publish(topic, msg)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package bus
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for LocalMessageBus unit tests
+type LocalMessageBusUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run LocalMessageBusUnitTestSuite test suite
+func TestLocalMessageBusUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LocalMessageBusUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test messages are delivered to the subscribers of their topic only.
+func (suite *LocalMessageBusUnitTestSuite) TestPublishSubscribe() {
+	bus := NewLocalMessageBus()
+	first, second, other := make(chan []byte, 1), make(chan []byte, 1), make(chan []byte, 1)
+	firstId := bus.Subscribe("trades", first)
+	secondId := bus.Subscribe("trades", second)
+	bus.Subscribe("book", other)
+	require.NotEqual(suite.T(), firstId, secondId)
+	require.Equal(suite.T(), 2, bus.Publish("trades", []byte("hello")))
+	require.Equal(suite.T(), []byte("hello"), <-first)
+	require.Equal(suite.T(), []byte("hello"), <-second)
+	require.Empty(suite.T(), other)
+	// No subscribers
+	require.Equal(suite.T(), 0, bus.Publish("unknown", []byte("hello")))
+	// Unsubscribed channels do not receive messages anymore
+	bus.Unsubscribe(firstId)
+	bus.Unsubscribe(firstId)
+	require.Equal(suite.T(), 1, bus.Publish("trades", []byte("again")))
+	require.Empty(suite.T(), first)
+	require.Equal(suite.T(), []byte("again"), <-second)
+	bus.Unsubscribe(secondId)
+	require.NotContains(suite.T(), bus.topics, "trades")
+}
+
+// Test Publish does not block on subscribers which are not ready.
+func (suite *LocalMessageBusUnitTestSuite) TestPublishSkipsFullSubscribers() {
+	bus := NewLocalMessageBus()
+	full, ready := make(chan []byte), make(chan []byte, 2)
+	bus.Subscribe("trades", full)
+	bus.Subscribe("trades", ready)
+	require.Equal(suite.T(), 1, bus.Publish("trades", []byte("a")))
+	require.Equal(suite.T(), 1, bus.Publish("trades", []byte("b")))
+	require.Equal(suite.T(), 0, bus.Publish("trades", []byte("c")))
+	require.Equal(suite.T(), []byte("a"), <-ready)
+	require.Equal(suite.T(), []byte("b"), <-ready)
+}
+
+// Test the publish handler dispatches messages by topic.
+func (suite *LocalMessageBusUnitTestSuite) TestPublishHandler() {
+	bus := NewLocalMessageBus()
+	ch := make(chan []byte, 2)
+	bus.Subscribe("a", ch)
+	handler := bus.PublishHandler(func(msg []byte) string {
+		if len(msg) == 0 {
+			return ""
+		}
+		return string(msg[:1])
+	})
+	handler(context.Background(), wsadapters.Text, []byte("a1"))
+	handler(context.Background(), wsadapters.Text, []byte("b1"))
+	handler(context.Background(), wsadapters.Text, []byte{})
+	require.Len(suite.T(), ch, 1)
+	require.Equal(suite.T(), []byte("a1"), <-ch)
+}
+
+// Test the bus is safe for concurrent use.
+func (suite *LocalMessageBusUnitTestSuite) TestConcurrentUse() {
+	bus := NewLocalMessageBus()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ch := make(chan []byte, 100)
+			id := bus.Subscribe("topic", ch)
+			bus.Unsubscribe(id)
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				bus.Publish("topic", []byte("hello"))
+			}
+		}()
+	}
+	wg.Wait()
+	require.Empty(suite.T(), bus.topics)
+	require.Empty(suite.T(), bus.subscriptions)
+}
@@ -0,0 +1,228 @@
+package wscengine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for the close message returned by OnClose
+type CloseMessageIntegrationTestSuite struct {
+	suite.Suite
+}
+
+// Run CloseMessageIntegrationTestSuite test suite
+func TestCloseMessageIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(CloseMessageIntegrationTestSuite))
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the server receives the close message returned by OnClose when the engine stops.
+func (suite *CloseMessageIntegrationTestSuite) TestCloseMessageOnStop() {
+	srv, closes := newCloseRecordingServer("")
+	defer srv.Close()
+	client := newCloseMessageClient(&wsclient.CloseMessageDetails{CloseReason: 4001, CloseMessage: "custom stop"})
+	engine := suite.startEngine(srv, client, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Stop(ctx))
+	requireCloseReceived(suite.T(), closes, 4001, "custom stop")
+}
+
+// Test the server receives the close message returned by OnClose when exit is called from
+// OnMessage.
+func (suite *CloseMessageIntegrationTestSuite) TestCloseMessageOnExit() {
+	srv, closes := newCloseRecordingServer("exit")
+	defer srv.Close()
+	client := newCloseMessageClient(&wsclient.CloseMessageDetails{CloseReason: 4002, CloseMessage: "custom exit"})
+	engine := suite.startEngine(srv, client, false)
+	requireCloseReceived(suite.T(), closes, 4002, "custom exit")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Stop(ctx))
+}
+
+// Test the server receives the close message returned by OnClose when restart is called from
+// OnMessage and the engine reconnects.
+func (suite *CloseMessageIntegrationTestSuite) TestCloseMessageOnRestart() {
+	srv, closes := newCloseRecordingServer("restart")
+	defer srv.Close()
+	client := newCloseMessageClient(&wsclient.CloseMessageDetails{CloseReason: 4003, CloseMessage: "custom restart"})
+	engine := suite.startEngine(srv, client, true)
+	requireCloseReceived(suite.T(), closes, 4003, "custom restart")
+	// Wait for the engine to reconnect before it is stopped
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2 && engine.State() == EngineStateConnected
+	}, 5*time.Second, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Stop(ctx))
+}
+
+// Test the server receives 1001 Going Away when OnClose returns nil.
+func (suite *CloseMessageIntegrationTestSuite) TestDefaultCloseMessage() {
+	srv, closes := newCloseRecordingServer("")
+	defer srv.Close()
+	client := newCloseMessageClient(nil)
+	engine := suite.startEngine(srv, client, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Stop(ctx))
+	requireCloseReceived(suite.T(), closes, int(wsadapters.GoingAway), "Going away")
+}
+
+// Test the server sees an abnormal closure (1006) when OnClose returns nil and the engine is
+// configured to skip the close message.
+func (suite *CloseMessageIntegrationTestSuite) TestSkipCloseFrameOnNilOnClose() {
+	srv, closes := newCloseRecordingServer("")
+	defer srv.Close()
+	client := newCloseMessageClient(nil)
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithSkipCloseFrameOnNilOnClose(true)
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.NoError(suite.T(), engine.Stop(ctx))
+	select {
+	case closeErr := <-closes:
+		require.Equal(suite.T(), websocket.CloseAbnormalClosure, closeErr.Code)
+	case <-ctx.Done():
+		suite.FailNow("server should have detected the dropped connection")
+	}
+	require.Empty(suite.T(), client.RecordedOnCloseErrors())
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Start an engine connected to the provided server.
+func (suite *CloseMessageIntegrationTestSuite) startEngine(
+	srv *httptest.Server,
+	client wsclient.WebsocketClientInterface,
+	autoReconnect bool) *WebsocketEngine {
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(autoReconnect).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	return engine
+}
+
+// Start a server which sends greeting to each new connection (if not empty) and sends the close
+// errors it receives on the returned channel. Close errors are dropped when the channel is full.
+func newCloseRecordingServer(greeting string) (*httptest.Server, chan *websocket.CloseError) {
+	closes := make(chan *websocket.CloseError, 10)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if greeting != "" {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(greeting)); err != nil {
+				return
+			}
+		}
+		for {
+			_, _, err := conn.ReadMessage()
+			closeErr := new(websocket.CloseError)
+			if errors.As(err, &closeErr) {
+				select {
+				case closes <- closeErr:
+				default:
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}))
+	return srv, closes
+}
+
+// Wait for the server to receive a close message and check its code and reason.
+func requireCloseReceived(t *testing.T, closes chan *websocket.CloseError, code int, reason string) {
+	select {
+	case closeErr := <-closes:
+		require.Equal(t, code, closeErr.Code)
+		require.Equal(t, reason, closeErr.Text)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "server did not receive a close message")
+	}
+}
+
+// Recording client which returns closeMessage from OnClose and calls exit when it receives "exit"
+// messages. Restart is called only for the first "restart" message.
+type closeMessageClient struct {
+	*wstest.RecordingClient
+	closeMessage *wsclient.CloseMessageDetails
+	// Set once restart has been called
+	restarted atomic.Bool
+}
+
+func newCloseMessageClient(closeMessage *wsclient.CloseMessageDetails) *closeMessageClient {
+	return &closeMessageClient{
+		RecordingClient: wstest.NewRecordingClient(),
+		closeMessage:    closeMessage,
+	}
+}
+
+// Record the call and call exit or restart
+func (client *closeMessageClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.RecordingClient.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	switch string(msg) {
+	case "exit":
+		exit()
+	case "restart":
+		if client.restarted.CompareAndSwap(false, true) {
+			restart()
+		}
+	}
+}
+
+// Record the call and return closeMessage
+func (client *closeMessageClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.RecordingClient.OnClose(ctx, conn, readMutex, sessionId, closeMessage)
+	return client.closeMessage
+}
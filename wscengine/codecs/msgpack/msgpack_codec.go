@@ -0,0 +1,197 @@
+// The package contains a MessagePack codec which can be used to encode and decode messages
+// exchanged as Binary messages over a websocket connection.
+//
+// Besides plain encoding and decoding, the codec can embed a type code in messages so they can be
+// decoded into the right Go type without knowing it in advance: typed messages are encoded as a
+// two items MessagePack array which contains the type code (uint8) and the value.
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Error returned when a typed message contains a type code which has not been registered.
+var ErrUnknownTypeCode = errors.New("unknown type code")
+
+// Error returned when a value whose type has not been registered is encoded as a typed message.
+var ErrUnregisteredType = errors.New("unregistered type")
+
+// Error returned when a message is not a valid typed message.
+var ErrInvalidTypedMessage = errors.New("invalid typed message")
+
+/*************************************************************************************************/
+/* TYPE REGISTRY                                                                                 */
+/*************************************************************************************************/
+
+// Registry which maps type codes embedded in typed messages to Go types. The registry is safe for
+// concurrent use.
+type TypeRegistry struct {
+	// Mutex used to protect registered types
+	mu sync.RWMutex
+	// Go type by type code
+	types map[uint8]reflect.Type
+	// Type code by Go type
+	codes map[reflect.Type]uint8
+}
+
+// # Description
+//
+// Factory which creates a new and empty TypeRegistry.
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		types: map[uint8]reflect.Type{},
+		codes: map[reflect.Type]uint8{},
+	}
+}
+
+// # Description
+//
+// Register the type of the provided zero value with the provided type code. Typed messages with
+// this code are decoded into a value of the same type: register a pointer (like &Trade{}) to get
+// pointers back.
+//
+// # Returns
+//
+// An error if zero is nil or if the code or the type are already registered.
+func (registry *TypeRegistry) RegisterType(code uint8, zero any) error {
+	if zero == nil {
+		return fmt.Errorf("cannot register a nil value")
+	}
+	t := reflect.TypeOf(zero)
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registered, found := registry.types[code]; found {
+		return fmt.Errorf("type code %d is already registered for %s", code, registered)
+	}
+	if registered, found := registry.codes[t]; found {
+		return fmt.Errorf("type %s is already registered with type code %d", t, registered)
+	}
+	registry.types[code] = t
+	registry.codes[t] = code
+	return nil
+}
+
+// Return the type registered with the code.
+func (registry *TypeRegistry) typeOf(code uint8) (reflect.Type, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	t, found := registry.types[code]
+	return t, found
+}
+
+// Return the code the type is registered with.
+func (registry *TypeRegistry) codeOf(t reflect.Type) (uint8, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	code, found := registry.codes[t]
+	return code, found
+}
+
+/*************************************************************************************************/
+/* CODEC                                                                                         */
+/*************************************************************************************************/
+
+// Codec which encodes and decodes values with MessagePack. The codec is safe for concurrent use.
+type MsgpackCodec struct {
+	// Registry used to encode and decode typed messages
+	registry *TypeRegistry
+}
+
+// # Description
+//
+// Factory which creates a new MsgpackCodec which uses the provided registry for typed messages. A
+// new and empty registry is used if registry is nil.
+func NewMsgpackCodec(registry *TypeRegistry) *MsgpackCodec {
+	if registry == nil {
+		registry = NewTypeRegistry()
+	}
+	return &MsgpackCodec{registry: registry}
+}
+
+// # Description
+//
+// Register a type in the codec registry. See TypeRegistry.RegisterType.
+func (codec *MsgpackCodec) RegisterType(code uint8, zero any) error {
+	return codec.registry.RegisterType(code, zero)
+}
+
+// # Description
+//
+// Encode the value with MessagePack.
+func (codec *MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// # Description
+//
+// Decode the MessagePack encoded data into v, which must be a pointer.
+func (codec *MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// # Description
+//
+// Encode the value as a typed message which embeds the type code the value type is registered with.
+//
+// # Returns
+//
+// The typed message or an error. ErrUnregisteredType is returned if the value type has not been
+// registered.
+func (codec *MsgpackCodec) EncodeWithType(v any) ([]byte, error) {
+	code, found := codec.registry.codeOf(reflect.TypeOf(v))
+	if !found {
+		return nil, fmt.Errorf("%w: %T", ErrUnregisteredType, v)
+	}
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+	err := enc.EncodeArrayLen(2)
+	if err == nil {
+		err = enc.EncodeUint8(code)
+	}
+	if err == nil {
+		err = enc.Encode(v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode typed message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// # Description
+//
+// Decode a typed message into a new value of the type registered with the embedded type code.
+//
+// # Returns
+//
+// The decoded value or an error. ErrUnknownTypeCode is returned if the type code has not been
+// registered and ErrInvalidTypedMessage is returned if data is not a typed message.
+func (codec *MsgpackCodec) DecodeWithType(data []byte) (typedValue any, err error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTypedMessage, err)
+	}
+	if length != 2 {
+		return nil, fmt.Errorf("%w: expected an array of 2 items, got %d", ErrInvalidTypedMessage, length)
+	}
+	code, err := dec.DecodeUint8()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTypedMessage, err)
+	}
+	t, found := codec.registry.typeOf(code)
+	if !found {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTypeCode, code)
+	}
+	value := reflect.New(t)
+	err = dec.Decode(value.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode typed message with type code %d: %w", code, err)
+	}
+	return value.Elem().Interface(), nil
+}
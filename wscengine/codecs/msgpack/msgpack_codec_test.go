@@ -0,0 +1,178 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for MsgpackCodec unit tests
+type MsgpackCodecUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run MsgpackCodecUnitTestSuite test suite
+func TestMsgpackCodecUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MsgpackCodecUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Small message used in tests and benchmarks
+type trade struct {
+	Symbol string  `json:"symbol" msgpack:"symbol"`
+	Price  float64 `json:"price" msgpack:"price"`
+	Volume float64 `json:"volume" msgpack:"volume"`
+	Side   string  `json:"side" msgpack:"side"`
+	Time   int64   `json:"time" msgpack:"time"`
+}
+
+// Other message used in tests
+type heartbeat struct {
+	Seq uint64 `msgpack:"seq"`
+}
+
+// Sample small message
+var sampleTrade = trade{Symbol: "XBT/USD", Price: 37120.5, Volume: 0.0125, Side: "b", Time: 1700000000123}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test typed messages are decoded into the registered types.
+func (suite *MsgpackCodecUnitTestSuite) TestEncodeDecodeWithType() {
+	codec := NewMsgpackCodec(nil)
+	require.NoError(suite.T(), codec.RegisterType(1, trade{}))
+	require.NoError(suite.T(), codec.RegisterType(2, &heartbeat{}))
+	data, err := codec.EncodeWithType(sampleTrade)
+	require.NoError(suite.T(), err)
+	decoded, err := codec.DecodeWithType(data)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), sampleTrade, decoded)
+	data, err = codec.EncodeWithType(&heartbeat{Seq: 42})
+	require.NoError(suite.T(), err)
+	decoded, err = codec.DecodeWithType(data)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), &heartbeat{Seq: 42}, decoded)
+	// Plain encoding
+	data, err = codec.Marshal(sampleTrade)
+	require.NoError(suite.T(), err)
+	plain := trade{}
+	require.NoError(suite.T(), codec.Unmarshal(data, &plain))
+	require.Equal(suite.T(), sampleTrade, plain)
+}
+
+// Test registration conflicts are rejected.
+func (suite *MsgpackCodecUnitTestSuite) TestRegisterType() {
+	registry := NewTypeRegistry()
+	require.NoError(suite.T(), registry.RegisterType(1, trade{}))
+	require.Error(suite.T(), registry.RegisterType(1, heartbeat{}))
+	require.Error(suite.T(), registry.RegisterType(2, trade{}))
+	require.Error(suite.T(), registry.RegisterType(3, nil))
+	// Pointer and value types are distinct
+	require.NoError(suite.T(), registry.RegisterType(2, &trade{}))
+}
+
+// Test errors returned for unregistered types and invalid typed messages.
+func (suite *MsgpackCodecUnitTestSuite) TestTypedMessageErrors() {
+	registry := NewTypeRegistry()
+	require.NoError(suite.T(), registry.RegisterType(1, trade{}))
+	codec := NewMsgpackCodec(registry)
+	_, err := codec.EncodeWithType(heartbeat{})
+	require.ErrorIs(suite.T(), err, ErrUnregisteredType)
+	// Unknown type code
+	data, err := msgpack.Marshal([]any{uint8(9), heartbeat{}})
+	require.NoError(suite.T(), err)
+	_, err = codec.DecodeWithType(data)
+	require.ErrorIs(suite.T(), err, ErrUnknownTypeCode)
+	// Not an array, wrong length and invalid type code
+	for _, v := range []any{"trade", []any{uint8(1)}, []any{"1", sampleTrade}} {
+		data, err = msgpack.Marshal(v)
+		require.NoError(suite.T(), err)
+		_, err = codec.DecodeWithType(data)
+		require.ErrorIs(suite.T(), err, ErrInvalidTypedMessage)
+	}
+	// Value does not match the registered type
+	data, err = msgpack.Marshal([]any{uint8(1), "not a trade"})
+	require.NoError(suite.T(), err)
+	_, err = codec.DecodeWithType(data)
+	require.Error(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* BENCHMARKS                                                                                    */
+/*************************************************************************************************/
+
+// Number of messages processed per benchmark iteration: one iteration is one second of traffic
+// at 50k small messages/s.
+const messagesPerSecond = 50000
+
+// Run fn messagesPerSecond times per iteration and report the achievable message rate.
+func runMessages(b *testing.B, fn func() error) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < messagesPerSecond; j++ {
+			if err := fn(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*messagesPerSecond)/b.Elapsed().Seconds(), "msgs/s")
+}
+
+// Benchmark encoding small messages with MessagePack.
+func BenchmarkMsgpackEncode(b *testing.B) {
+	codec := NewMsgpackCodec(nil)
+	runMessages(b, func() error {
+		_, err := codec.Marshal(sampleTrade)
+		return err
+	})
+}
+
+// Benchmark encoding small messages with JSON.
+func BenchmarkJSONEncode(b *testing.B) {
+	runMessages(b, func() error {
+		_, err := json.Marshal(sampleTrade)
+		return err
+	})
+}
+
+// Benchmark decoding small messages with MessagePack.
+func BenchmarkMsgpackDecode(b *testing.B) {
+	codec := NewMsgpackCodec(nil)
+	data, _ := codec.Marshal(sampleTrade)
+	runMessages(b, func() error {
+		v := trade{}
+		return codec.Unmarshal(data, &v)
+	})
+}
+
+// Benchmark decoding small typed messages with MessagePack.
+func BenchmarkMsgpackDecodeWithType(b *testing.B) {
+	codec := NewMsgpackCodec(nil)
+	codec.RegisterType(1, trade{})
+	data, _ := codec.EncodeWithType(sampleTrade)
+	runMessages(b, func() error {
+		_, err := codec.DecodeWithType(data)
+		return err
+	})
+}
+
+// Benchmark decoding small messages with JSON.
+func BenchmarkJSONDecode(b *testing.B) {
+	data, _ := json.Marshal(sampleTrade)
+	runMessages(b, func() error {
+		v := trade{}
+		return json.Unmarshal(data, &v)
+	})
+}
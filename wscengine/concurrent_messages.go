@@ -0,0 +1,56 @@
+package wscengine
+
+import "context"
+
+// # Description
+//
+// Call the provided function which processes a message. If concurrent messages are not limited,
+// the function is called in the current goroutine. Otherwise, the method waits for a free slot
+// and calls the function in a dedicated goroutine which releases the slot when it returns. If the
+// session ends while the method waits for a free slot, the function is called in the current
+// goroutine so the message is not lost.
+//
+// # Inputs
+//
+//   - sessionCtx: Context bound to the websocket connection lifetime.
+//   - onMessage: Function which processes the message.
+func (wsengine *WebsocketEngine) dispatchMessage(sessionCtx context.Context, onMessage func()) {
+	if wsengine.messageSlots == nil {
+		onMessage()
+		return
+	}
+	select {
+	case wsengine.messageSlots <- struct{}{}:
+		go func() {
+			defer func() { <-wsengine.messageSlots }()
+			onMessage()
+		}()
+	case <-sessionCtx.Done():
+		onMessage()
+	}
+}
+
+// # Description
+//
+// Take a message slot for a callback which runs in the current goroutine (see OnMessageStream).
+// If concurrent messages are not limited, no slot is taken. If the session ends while the method
+// waits for a free slot, no slot is taken so the message is not lost.
+//
+// # Inputs
+//
+//   - sessionCtx: Context bound to the websocket connection lifetime.
+//
+// # Returns
+//
+// A function which releases the slot. The function must be called once the callback returns.
+func (wsengine *WebsocketEngine) acquireMessageSlot(sessionCtx context.Context) func() {
+	if wsengine.messageSlots == nil {
+		return func() {}
+	}
+	select {
+	case wsengine.messageSlots <- struct{}{}:
+		return func() { <-wsengine.messageSlots }
+	case <-sessionCtx.Done():
+		return func() {}
+	}
+}
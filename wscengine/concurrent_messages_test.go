@@ -0,0 +1,111 @@
+package wscengine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for concurrent messages limit unit tests
+type ConcurrentMessagesUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ConcurrentMessagesUnitTestSuite test suite
+func TestConcurrentMessagesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConcurrentMessagesUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test a single engine goroutine runs several OnMessage callbacks at once, up to the limit, and
+// stops dispatching messages while all slots are taken.
+func (suite *ConcurrentMessagesUnitTestSuite) TestMaxConcurrentMessages() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newSlowClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithMaxConcurrentMessages(2)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	for i := 0; i < 4; i++ {
+		adapter.EnqueueMessage(wsadapters.Text, []byte(fmt.Sprint(i)))
+	}
+	// Two callbacks run at once - the other messages wait for a free slot
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 2, 5*time.Second))
+	time.Sleep(20 * time.Millisecond)
+	require.Len(suite.T(), client.RecordedOnMessages(), 2)
+	require.Equal(suite.T(), int32(2), client.running.Load())
+	// Release the callbacks - remaining messages are processed
+	close(client.release)
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 4, 5*time.Second))
+	require.Eventually(suite.T(), func() bool {
+		return client.running.Load() == 0
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(suite.T(), int32(2), client.maxRunning.Load())
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Websocket client which blocks in OnMessage until released and records the number of callbacks
+// which run at once
+type slowClient struct {
+	*wstest.RecordingClient
+	// Closed to release the blocked callbacks
+	release chan struct{}
+	// Number of callbacks which are running
+	running atomic.Int32
+	// Maximum number of callbacks which have run at once
+	maxRunning atomic.Int32
+}
+
+func newSlowClient() *slowClient {
+	return &slowClient{
+		RecordingClient: wstest.NewRecordingClient(),
+		release:         make(chan struct{}),
+	}
+}
+
+// Record the call and block until released
+func (client *slowClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	running := client.running.Add(1)
+	defer client.running.Add(-1)
+	for {
+		current := client.maxRunning.Load()
+		if running <= current || client.maxRunning.CompareAndSwap(current, running) {
+			break
+		}
+	}
+	client.RecordingClient.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	<-client.release
+}
@@ -0,0 +1,139 @@
+// The package contains a context wrapper which defers and memoizes context value lookups so hot
+// callbacks only pay for the values they actually use.
+package context
+
+import (
+	gocontext "context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Alias type used as key in context
+type contextKey string
+
+const (
+	// Context key used to store a tracer
+	tracerKey contextKey = "tracer"
+	// Context key used to store a logger
+	loggerKey contextKey = "logger"
+	// Context key used to store tags
+	tagsKey contextKey = "tags"
+)
+
+// Tracer returned by LazyContext when the context does not carry any tracer
+var noopTracer trace.Tracer = noop.NewTracerProvider().Tracer("")
+
+// # Description
+//
+// Return a copy of the provided context which holds the provided tracer.
+func WithTracer(ctx gocontext.Context, tracer trace.Tracer) gocontext.Context {
+	return gocontext.WithValue(ctx, tracerKey, tracer)
+}
+
+// # Description
+//
+// Return a copy of the provided context which holds the provided logger.
+func WithLogger(ctx gocontext.Context, logger *slog.Logger) gocontext.Context {
+	return gocontext.WithValue(ctx, loggerKey, logger)
+}
+
+// # Description
+//
+// Return a copy of the provided context which holds the provided tags.
+func WithTags(ctx gocontext.Context, tags map[string]string) gocontext.Context {
+	return gocontext.WithValue(ctx, tagsKey, tags)
+}
+
+// Context wrapper which memoizes value lookups.
+//
+// Looking up a value in a context walks the whole chain of parent contexts. LazyContext defers
+// lookups until a value is requested and caches the result so later lookups of the same key are
+// served without walking the chain again. Values stored in a context are immutable, so caching is
+// always safe.
+//
+// The engine stores its tracer and its logger in the context provided to callbacks: callbacks can
+// wrap their context in a LazyContext to get them.
+//
+// A LazyContext is safe for concurrent use.
+type LazyContext struct {
+	// Parent context - provides Deadline, Done and Err
+	gocontext.Context
+	// Memoized Tracer lookup
+	tracerOnce sync.Once
+	tracer     trace.Tracer
+	// Memoized Logger lookup
+	loggerOnce sync.Once
+	logger     *slog.Logger
+	// Memoized Tags lookup
+	tagsOnce sync.Once
+	tags     map[string]string
+	// Mutex which protects values
+	mu sync.Mutex
+	// Memoized Value lookups - allocated on first lookup
+	values map[any]any
+}
+
+// # Description
+//
+// Wrap the provided context in a LazyContext. No value is looked up until requested.
+func NewLazyContext(parent gocontext.Context) *LazyContext {
+	return &LazyContext{Context: parent}
+}
+
+// # Description
+//
+// Return the value associated with key in the parent context. The first lookup of a key walks
+// the parent context chain, next lookups are served from cache.
+func (ctx *LazyContext) Value(key any) any {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if value, ok := ctx.values[key]; ok {
+		return value
+	}
+	value := ctx.Context.Value(key)
+	if ctx.values == nil {
+		ctx.values = make(map[any]any, 1)
+	}
+	ctx.values[key] = value
+	return value
+}
+
+// # Description
+//
+// Return the tracer stored in the context with WithTracer or a no-op tracer if there is none.
+func (ctx *LazyContext) Tracer() trace.Tracer {
+	ctx.tracerOnce.Do(func() {
+		ctx.tracer, _ = ctx.Context.Value(tracerKey).(trace.Tracer)
+		if ctx.tracer == nil {
+			ctx.tracer = noopTracer
+		}
+	})
+	return ctx.tracer
+}
+
+// # Description
+//
+// Return the logger stored in the context with WithLogger or slog.Default() if there is none.
+func (ctx *LazyContext) Logger() *slog.Logger {
+	ctx.loggerOnce.Do(func() {
+		ctx.logger, _ = ctx.Context.Value(loggerKey).(*slog.Logger)
+		if ctx.logger == nil {
+			ctx.logger = slog.Default()
+		}
+	})
+	return ctx.logger
+}
+
+// # Description
+//
+// Return the tags stored in the context with WithTags or nil if there is none. The returned map
+// must not be modified.
+func (ctx *LazyContext) Tags() map[string]string {
+	ctx.tagsOnce.Do(func() {
+		ctx.tags, _ = ctx.Context.Value(tagsKey).(map[string]string)
+	})
+	return ctx.tags
+}
@@ -0,0 +1,146 @@
+package context
+
+import (
+	gocontext "context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for LazyContext unit tests
+type LazyContextUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run LazyContextUnitTestSuite test suite
+func TestLazyContextUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LazyContextUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test accessors return values stored in the parent context.
+func (suite *LazyContextUnitTestSuite) TestAccessors() {
+	tracer := noop.NewTracerProvider().Tracer("test")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tags := map[string]string{"env": "test"}
+	parent := WithTags(WithLogger(WithTracer(gocontext.Background(), tracer), logger), tags)
+	ctx := NewLazyContext(parent)
+	require.Equal(suite.T(), tracer, ctx.Tracer())
+	require.Equal(suite.T(), logger, ctx.Logger())
+	require.Equal(suite.T(), tags, ctx.Tags())
+}
+
+// Test accessors defaults when the parent context does not hold any value.
+func (suite *LazyContextUnitTestSuite) TestAccessorsDefaults() {
+	ctx := NewLazyContext(gocontext.Background())
+	require.NotNil(suite.T(), ctx.Tracer())
+	require.Equal(suite.T(), slog.Default(), ctx.Logger())
+	require.Nil(suite.T(), ctx.Tags())
+}
+
+// Test Value is memoized and the LazyContext behaves as its parent.
+func (suite *LazyContextUnitTestSuite) TestValue() {
+	type key string
+	parent, cancel := gocontext.WithCancel(gocontext.WithValue(gocontext.Background(), key("k"), "v"))
+	ctx := NewLazyContext(parent)
+	require.Equal(suite.T(), "v", ctx.Value(key("k")))
+	require.Equal(suite.T(), "v", ctx.Value(key("k")))
+	require.Nil(suite.T(), ctx.Value(key("missing")))
+	require.Len(suite.T(), ctx.values, 2)
+	// Cancel parent
+	cancel()
+	<-ctx.Done()
+	require.ErrorIs(suite.T(), ctx.Err(), gocontext.Canceled)
+}
+
+// Test a LazyContext can be shared by concurrent goroutines. Run with -race to detect data races.
+func (suite *LazyContextUnitTestSuite) TestConcurrentUse() {
+	type key string
+	tracer := noop.NewTracerProvider().Tracer("test")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parent := WithLogger(WithTracer(gocontext.WithValue(gocontext.Background(), key("k"), "v"), tracer), logger)
+	ctx := NewLazyContext(parent)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(suite.T(), tracer, ctx.Tracer())
+			require.Equal(suite.T(), logger, ctx.Logger())
+			require.Nil(suite.T(), ctx.Tags())
+			require.Equal(suite.T(), "v", ctx.Value(key("k")))
+		}()
+	}
+	wg.Wait()
+}
+
+/*************************************************************************************************/
+/* BENCHMARKS                                                                                    */
+/*************************************************************************************************/
+
+// Count of concurrent callbacks per benchmark iteration
+const concurrentCallbacks = 10000
+
+// Build a context similar to the one provided to callbacks: a few values under a chain of
+// unrelated values.
+func benchmarkContext() gocontext.Context {
+	type key int
+	ctx := WithTags(WithLogger(WithTracer(gocontext.Background(), noop.NewTracerProvider().Tracer("bench")), slog.Default()), map[string]string{})
+	for i := 0; i < 10; i++ {
+		ctx = gocontext.WithValue(ctx, key(i), i)
+	}
+	return ctx
+}
+
+// Run callback concurrently concurrentCallbacks times per iteration.
+func runConcurrentCallbacks(b *testing.B, ctx gocontext.Context, callback func(ctx gocontext.Context)) {
+	b.ReportAllocs()
+	wg := sync.WaitGroup{}
+	for i := 0; i < b.N; i++ {
+		wg.Add(concurrentCallbacks)
+		for j := 0; j < concurrentCallbacks; j++ {
+			go func() {
+				defer wg.Done()
+				callback(ctx)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// Benchmark callbacks which extract all values at start but only use the logger.
+func BenchmarkEagerContextExtraction(b *testing.B) {
+	runConcurrentCallbacks(b, benchmarkContext(), func(ctx gocontext.Context) {
+		_ = ctx.Value(tracerKey)
+		_ = ctx.Value(tagsKey)
+		logger := ctx.Value(loggerKey).(*slog.Logger)
+		for k := 0; k < 3; k++ {
+			logger = ctx.Value(loggerKey).(*slog.Logger)
+		}
+		_ = logger
+	})
+}
+
+// Benchmark callbacks which lazily use the logger through a LazyContext.
+func BenchmarkLazyContextExtraction(b *testing.B) {
+	runConcurrentCallbacks(b, benchmarkContext(), func(ctx gocontext.Context) {
+		lazy := NewLazyContext(ctx)
+		logger := lazy.Logger()
+		for k := 0; k < 3; k++ {
+			logger = lazy.Logger()
+		}
+		_ = logger
+	})
+}
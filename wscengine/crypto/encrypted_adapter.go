@@ -0,0 +1,253 @@
+// The package contains a connection adapter decorator which encrypts the messages written on a
+// websocket connection and decrypts the messages read from it with an AEAD cipher, for APIs which
+// exchange encrypted payloads on top of the websocket protocol.
+//
+// Each message is encrypted with a random nonce which is prepended to the ciphertext: the payload
+// of each frame is nonce || ciphertext || authentication tag.
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Size in bytes of the keys used by all cipher suites.
+const KeySize = 32
+
+// Error wrapped in the error returned by Read and ReadStream when a message cannot be decrypted:
+// the message is too short, has been tampered with or has been encrypted with another key.
+var ErrDecryptionFailed = errors.New("failed to decrypt message")
+
+// AEAD cipher used to encrypt and decrypt messages.
+type CipherSuite int
+
+const (
+	// AES-256 in Galois/Counter mode with a 12 bytes nonce
+	AES256GCM CipherSuite = iota
+	// ChaCha20-Poly1305 (RFC 8439) with a 12 bytes nonce
+	ChaCha20Poly1305
+)
+
+// Return the name of the cipher suite.
+func (suite CipherSuite) String() string {
+	switch suite {
+	case AES256GCM:
+		return "AES-256-GCM"
+	case ChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	default:
+		return fmt.Sprintf("CipherSuite(%d)", int(suite))
+	}
+}
+
+// A decorator which transparently encrypts the messages written with the decorated adapter and
+// decrypts the messages it reads. Both ends of the connection must share the same key and cipher
+// suite.
+//
+// The message type is preserved: as ciphertexts are not valid UTF-8, peers should exchange
+// encrypted messages as Binary messages. Streamed messages are buffered: WriteStream encrypts and
+// writes the message when the writer is closed and ReadStream reads and decrypts the whole
+// message before it returns.
+type EncryptedAdapter struct {
+	// Decorated WebsocketConnectionAdapterInterface implementation
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// AEAD cipher used to encrypt and decrypt messages
+	aead cipher.AEAD
+	// Mode used by WritePropagated to embed the trace context in messages before they are
+	// encrypted
+	propagationMode wsadapters.PropagationMode
+}
+
+// # Description
+//
+// Create a new decorator which encrypts and decrypts the messages exchanged with the provided
+// implementation of WebsocketConnectionAdapterInterface.
+//
+// # Inputs
+//
+//   - inner: Decorated connection adapter.
+//   - key: Secret key shared with the server. It must be KeySize bytes long. The key is copied.
+//   - cipherSuite: AEAD cipher used to encrypt and decrypt messages.
+//
+// # Returns
+//
+// The decorator or an error if inner is nil, if the key does not have the right size or if the
+// cipher suite is unknown.
+func NewEncryptedAdapter(inner wsadapters.WebsocketConnectionAdapterInterface, key []byte, cipherSuite CipherSuite) (*EncryptedAdapter, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("provided inner adapter is nil")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: %d bytes instead of %d", len(key), KeySize)
+	}
+	key = bytes.Clone(key)
+	var aead cipher.AEAD
+	var err error
+	switch cipherSuite {
+	case AES256GCM:
+		var block cipher.Block
+		block, err = aes.NewCipher(key)
+		if err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case ChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unknown cipher suite: %s", cipherSuite)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s cipher: %w", cipherSuite, err)
+	}
+	return &EncryptedAdapter{
+		decorated: inner,
+		aead:      aead,
+	}, nil
+}
+
+// Simple proxy for Dial method.
+func (adapter *EncryptedAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method. Close reason is not encrypted.
+func (adapter *EncryptedAdapter) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *EncryptedAdapter) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Simple proxy for Ping method.
+func (adapter *EncryptedAdapter) Ping(ctx context.Context) error {
+	return adapter.decorated.Ping(ctx)
+}
+
+// Decorate the Read method to decrypt the message.
+func (adapter *EncryptedAdapter) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	msgType, msg, err := adapter.decorated.Read(ctx)
+	if err != nil {
+		return msgType, msg, err
+	}
+	plaintext, err := adapter.decrypt(msg)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, plaintext, nil
+}
+
+// Decorate the ReadStream method to read and decrypt the whole message.
+func (adapter *EncryptedAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, reader, err := adapter.decorated.ReadStream(ctx)
+	if err != nil {
+		return msgType, reader, err
+	}
+	msg, err := io.ReadAll(reader)
+	if err != nil {
+		return msgType, nil, err
+	}
+	plaintext, err := adapter.decrypt(msg)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(plaintext), nil
+}
+
+// Decorate the Write method to encrypt the message.
+func (adapter *EncryptedAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	ciphertext, err := adapter.encrypt(msg)
+	if err != nil {
+		return err
+	}
+	return adapter.decorated.Write(ctx, msgType, ciphertext)
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode set with
+// WithPropagationMode, then encrypt and write the message like Write. The trace context is
+// encrypted with the message: the propagation mode of the decorated adapter is not used.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *EncryptedAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	msgType, msg, err := wsadapters.InjectTraceContext(ctx, adapter.propagationMode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in messages before
+// they are encrypted. Defaults to wsadapters.None: messages are written as is. The method must be
+// called before the adapter is used.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *EncryptedAdapter) WithPropagationMode(mode wsadapters.PropagationMode) *EncryptedAdapter {
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// Decorate the WriteStream method to buffer the message and encrypt it when the writer is closed.
+func (adapter *EncryptedAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return wsadapters.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *EncryptedAdapter) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *EncryptedAdapter) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method. Statistics are computed on encrypted messages.
+func (adapter *EncryptedAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Encrypt the message with a random nonce and return nonce || ciphertext.
+func (adapter *EncryptedAdapter) encrypt(msg []byte) ([]byte, error) {
+	nonce := make([]byte, adapter.aead.NonceSize(), adapter.aead.NonceSize()+len(msg)+adapter.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return adapter.aead.Seal(nonce, nonce, msg, nil), nil
+}
+
+// Decrypt a message encrypted by encrypt.
+func (adapter *EncryptedAdapter) decrypt(msg []byte) ([]byte, error) {
+	nonceSize := adapter.aead.NonceSize()
+	if len(msg) < nonceSize+adapter.aead.Overhead() {
+		return nil, fmt.Errorf("%w: message is too short (%d bytes)", ErrDecryptionFailed, len(msg))
+	}
+	plaintext, err := adapter.aead.Open(nil, msg[:nonceSize], msg[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return plaintext, nil
+}
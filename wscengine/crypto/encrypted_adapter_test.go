@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for EncryptedAdapter unit tests
+type EncryptedAdapterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run EncryptedAdapterUnitTestSuite test suite
+func TestEncryptedAdapterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EncryptedAdapterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory validates its inputs.
+func (suite *EncryptedAdapterUnitTestSuite) TestNewEncryptedAdapter() {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	_, err := NewEncryptedAdapter(nil, key, AES256GCM)
+	require.Error(suite.T(), err)
+	_, err = NewEncryptedAdapter(mock.NewMockWebsocketConnectionAdapter(), key[:16], AES256GCM)
+	require.Error(suite.T(), err)
+	_, err = NewEncryptedAdapter(mock.NewMockWebsocketConnectionAdapter(), key, CipherSuite(42))
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), "CipherSuite(42)", CipherSuite(42).String())
+}
+
+// Test messages written by an adapter are encrypted with a per-message nonce and decrypted by an
+// adapter which uses the same key and cipher suite.
+func (suite *EncryptedAdapterUnitTestSuite) TestEncryptDecrypt() {
+	for _, cipherSuite := range []CipherSuite{AES256GCM, ChaCha20Poly1305} {
+		key := bytes.Repeat([]byte{byte(cipherSuite)}, KeySize)
+		writer, writerMock := suite.newAdapter(key, cipherSuite)
+		reader, readerMock := suite.newAdapter(key, cipherSuite)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Same message is encrypted with different nonces
+		require.NoError(suite.T(), writer.Write(ctx, wsadapters.Binary, []byte("secret")))
+		require.NoError(suite.T(), writer.Write(ctx, wsadapters.Binary, []byte("secret")))
+		stream, err := writer.WriteStream(ctx, wsadapters.Text)
+		require.NoError(suite.T(), err)
+		_, err = stream.Write([]byte("streamed"))
+		require.NoError(suite.T(), err)
+		require.NoError(suite.T(), stream.Close())
+		written := writerMock.WrittenMessages()
+		require.Len(suite.T(), written, 3)
+		require.NotContains(suite.T(), string(written[0].Msg), "secret")
+		require.Len(suite.T(), written[0].Msg, 12+len("secret")+16, cipherSuite.String())
+		require.NotEqual(suite.T(), written[0].Msg[:12], written[1].Msg[:12])
+		require.Equal(suite.T(), wsadapters.Text, written[2].MsgType)
+		// Encrypted messages are decrypted by Read and ReadStream
+		for _, msg := range written {
+			readerMock.EnqueueMessage(msg.MsgType, msg.Msg)
+		}
+		for i := 0; i < 2; i++ {
+			msgType, msg, err := reader.Read(ctx)
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), wsadapters.Binary, msgType)
+			require.Equal(suite.T(), []byte("secret"), msg)
+		}
+		msgType, msgReader, err := reader.ReadStream(ctx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), wsadapters.Text, msgType)
+		msg, err := io.ReadAll(msgReader)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), []byte("streamed"), msg)
+	}
+}
+
+// Test messages which have been tampered with, encrypted with another key or which are too short
+// cannot be decrypted.
+func (suite *EncryptedAdapterUnitTestSuite) TestDecryptionFailures() {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	writer, writerMock := suite.newAdapter(key, ChaCha20Poly1305)
+	reader, readerMock := suite.newAdapter(key, ChaCha20Poly1305)
+	other, otherMock := suite.newAdapter(bytes.Repeat([]byte{2}, KeySize), ChaCha20Poly1305)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), writer.Write(ctx, wsadapters.Binary, []byte("secret")))
+	ciphertext := writerMock.WrittenMessages()[0].Msg
+	// Wrong key
+	otherMock.EnqueueMessage(wsadapters.Binary, ciphertext)
+	_, _, err := other.Read(ctx)
+	require.ErrorIs(suite.T(), err, ErrDecryptionFailed)
+	// Tampered message
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 0xFF
+	readerMock.EnqueueMessage(wsadapters.Binary, tampered)
+	_, _, err = reader.Read(ctx)
+	require.ErrorIs(suite.T(), err, ErrDecryptionFailed)
+	// Message too short
+	readerMock.EnqueueMessage(wsadapters.Binary, ciphertext[:10])
+	_, _, err = reader.ReadStream(ctx)
+	require.ErrorIs(suite.T(), err, ErrDecryptionFailed)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Create a connected EncryptedAdapter which decorates a mock adapter
+func (suite *EncryptedAdapterUnitTestSuite) newAdapter(key []byte, cipherSuite CipherSuite) (*EncryptedAdapter, *mock.MockWebsocketConnectionAdapter) {
+	inner := mock.NewMockWebsocketConnectionAdapter()
+	adapter, err := NewEncryptedAdapter(inner, key, cipherSuite)
+	require.NoError(suite.T(), err)
+	_, err = adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	return adapter, inner
+}
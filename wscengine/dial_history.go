@@ -0,0 +1,101 @@
+package wscengine
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Number of dial attempts kept in the dial history
+const dialHistorySize = 100
+
+// Dial attempt recorded in the engine dial history.
+type DialAttempt struct {
+	// Time when the dial attempt started
+	AttemptedAt time.Time
+	// URL dialed by the engine (resolved target or previous server if enabled)
+	TargetURL url.URL
+	// Indicates whether the websocket connection has been opened
+	Success bool
+	// Error returned by the dial attempt - empty on success
+	Error string
+	// Duration of the dial attempt
+	Duration time.Duration
+}
+
+// Encode the dial attempt with the URL as a string and the duration in milliseconds.
+func (attempt DialAttempt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		AttemptedAt time.Time `json:"attemptedAt"`
+		TargetURL   string    `json:"targetUrl"`
+		Success     bool      `json:"success"`
+		Error       string    `json:"error,omitempty"`
+		DurationMs  float64   `json:"durationMs"`
+	}{
+		AttemptedAt: attempt.AttemptedAt,
+		TargetURL:   attempt.TargetURL.String(),
+		Success:     attempt.Success,
+		Error:       attempt.Error,
+		DurationMs:  float64(attempt.Duration) / float64(time.Millisecond),
+	})
+}
+
+// Ring buffer which keeps the last dial attempts.
+type dialHistory struct {
+	// Mutex used to protect the ring buffer
+	mu sync.Mutex
+	// Recorded attempts
+	entries [dialHistorySize]DialAttempt
+	// Index where the next attempt is recorded
+	next int
+	// Number of recorded attempts - at most dialHistorySize
+	count int
+}
+
+// Record a dial attempt, overwriting the oldest one if the buffer is full.
+func (history *dialHistory) add(attempt DialAttempt) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.entries[history.next] = attempt
+	history.next = (history.next + 1) % dialHistorySize
+	if history.count < dialHistorySize {
+		history.count++
+	}
+}
+
+// Return a copy of the recorded attempts, oldest first.
+func (history *dialHistory) list() []DialAttempt {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	attempts := make([]DialAttempt, 0, history.count)
+	start := (history.next - history.count + dialHistorySize) % dialHistorySize
+	for i := 0; i < history.count; i++ {
+		attempts = append(attempts, history.entries[(start+i)%dialHistorySize])
+	}
+	return attempts
+}
+
+// Forget all recorded attempts.
+func (history *dialHistory) clear() {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.entries = [dialHistorySize]DialAttempt{}
+	history.next = 0
+	history.count = 0
+}
+
+// # Description
+//
+// Return the last 100 dial attempts made by the engine when it starts or reconnects, oldest first.
+// Use it to debug intermittent reconnects.
+func (wsengine *WebsocketEngine) DialHistory() []DialAttempt {
+	return wsengine.dialHistory.list()
+}
+
+// # Description
+//
+// Forget all recorded dial attempts - useful for test isolation.
+func (wsengine *WebsocketEngine) ClearDialHistory() {
+	wsengine.dialHistory.clear()
+}
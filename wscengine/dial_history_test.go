@@ -0,0 +1,115 @@
+package wscengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for dial history tests
+type DialHistoryTestSuite struct {
+	suite.Suite
+}
+
+// Run DialHistoryTestSuite test suite
+func TestDialHistoryTestSuite(t *testing.T) {
+	suite.Run(t, new(DialHistoryTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the ring buffer keeps the last attempts, oldest first.
+func (suite *DialHistoryTestSuite) TestRingBuffer() {
+	history := &dialHistory{}
+	require.Empty(suite.T(), history.list())
+	for i := 0; i < dialHistorySize+20; i++ {
+		history.add(DialAttempt{TargetURL: url.URL{Scheme: "ws", Host: fmt.Sprintf("host-%d", i)}})
+	}
+	attempts := history.list()
+	require.Len(suite.T(), attempts, dialHistorySize)
+	require.Equal(suite.T(), "host-20", attempts[0].TargetURL.Host)
+	require.Equal(suite.T(), fmt.Sprintf("host-%d", dialHistorySize+19), attempts[dialHistorySize-1].TargetURL.Host)
+	history.clear()
+	require.Empty(suite.T(), history.list())
+	history.add(DialAttempt{TargetURL: url.URL{Scheme: "ws", Host: "new"}})
+	require.Equal(suite.T(), "new", history.list()[0].TargetURL.Host)
+}
+
+// Test JSON encoding of dial attempts.
+func (suite *DialHistoryTestSuite) TestMarshalJSON() {
+	attemptedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := json.Marshal(DialAttempt{
+		AttemptedAt: attemptedAt,
+		TargetURL:   url.URL{Scheme: "ws", Host: "localhost:8080", Path: "/ws"},
+		Error:       "connection refused",
+		Duration:    1500 * time.Microsecond,
+	})
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{
+		"attemptedAt": "2024-01-02T03:04:05Z",
+		"targetUrl": "ws://localhost:8080/ws",
+		"success": false,
+		"error": "connection refused",
+		"durationMs": 1.5
+	}`, string(data))
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test failed and successful dial attempts are recorded and exposed by the debug handler.
+func (suite *DialHistoryTestSuite) TestEngineDialHistory() {
+	srv, _ := newCountingEchoServer()
+	defer srv.Close()
+	target := toWebsocketURL(suite.T(), srv.URL)
+	// Dial a closed server first
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	opts := NewWebsocketEngineConfigurationOptions().WithAutoReconnect(false)
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), closed.URL), gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.Error(suite.T(), engine.Start(timeoutCtx))
+	engine.target = target
+	require.NoError(suite.T(), engine.Start(timeoutCtx))
+	defer engine.Stop(timeoutCtx)
+	attempts := engine.DialHistory()
+	require.Len(suite.T(), attempts, 2)
+	require.False(suite.T(), attempts[0].Success)
+	require.NotEmpty(suite.T(), attempts[0].Error)
+	require.True(suite.T(), attempts[1].Success)
+	require.Empty(suite.T(), attempts[1].Error)
+	require.Equal(suite.T(), *target, attempts[1].TargetURL)
+	require.Positive(suite.T(), attempts[1].Duration)
+	require.False(suite.T(), attempts[1].AttemptedAt.Before(attempts[0].AttemptedAt))
+	// Debug handler output
+	rec := httptest.NewRecorder()
+	NewEngineDebugHandler(engine).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/engine", nil))
+	state := struct {
+		DialHistory []map[string]any `json:"dialHistory"`
+	}{}
+	require.NoError(suite.T(), json.NewDecoder(rec.Body).Decode(&state))
+	require.Len(suite.T(), state.DialHistory, 2)
+	require.Equal(suite.T(), target.String(), state.DialHistory[1]["targetUrl"])
+	// Clear history
+	engine.ClearDialHistory()
+	require.Empty(suite.T(), engine.DialHistory())
+}
@@ -0,0 +1,44 @@
+package wscengine
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// # Description
+//
+// Wait for the OnMessage callbacks of the session which ends to return, for at most DrainTimeout.
+// The method returns immediately if graceful drain is disabled.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing and logging purpose.
+//   - span: Shutdown span in which an event is recorded if the drain timeout elapses.
+//   - sessionId: Id of the session which ends.
+func (wsengine *WebsocketEngine) drainInFlightMessages(ctx context.Context, span trace.Span, sessionId string) {
+	timeout := wsengine.engineCfgOpts.DrainTimeout
+	if timeout <= 0 {
+		return
+	}
+	// Wait for the callbacks in a separate goroutine so the wait can time out
+	drained := make(chan struct{})
+	inFlightMessages := wsengine.inFlightMessages
+	go func() {
+		inFlightMessages.Wait()
+		close(drained)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		// All callbacks have returned
+	case <-timer.C:
+		// Proceed with the shutdown - remaining callbacks keep running
+		span.AddEvent(eventDrainTimeout)
+		wsengine.logger.WarnContext(ctx, "drain timeout elapsed before in-flight messages were processed",
+			logKeySessionId, sessionId,
+			logKeyDuration, timeout)
+	}
+}
@@ -0,0 +1,156 @@
+package wscengine
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine graceful drain unit tests
+type DrainUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run DrainUnitTestSuite test suite
+func TestDrainUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DrainUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the engine waits for in-flight OnMessage callbacks before calling OnClose when exit is
+// called by another callback.
+func (suite *DrainUnitTestSuite) TestDrainWaitsForInFlightMessages() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newDrainClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(2).
+		WithDrainTimeout(5 * time.Second)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	// First message blocks its goroutine, second message stops the engine
+	adapter.EnqueueMessage(wsadapters.Text, []byte("block"))
+	<-client.started
+	adapter.EnqueueMessage(wsadapters.Text, []byte("exit"))
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnMessages()) == 2
+	}, 5*time.Second, time.Millisecond)
+	// OnClose is not called while a callback is running
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(suite.T(), client.RecordedOnCloses())
+	close(client.release)
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnCloses()) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(suite.T(), []string{"processed", "closed"}, client.events())
+	require.NoError(suite.T(), engine.Stop(ctx))
+}
+
+// Test the engine proceeds with the shutdown once the drain timeout has elapsed.
+func (suite *DrainUnitTestSuite) TestDrainTimeout() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newDrainClient()
+	defer close(client.release)
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(2).
+		WithDrainTimeout(20 * time.Millisecond)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	adapter.EnqueueMessage(wsadapters.Text, []byte("block"))
+	<-client.started
+	// Stop returns even though the callback is still running
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Len(suite.T(), client.RecordedOnCloses(), 1)
+	require.Equal(suite.T(), []string{"closed"}, client.events())
+	require.Len(suite.T(), adapter.CloseMessages(), 1)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Recording client which blocks on "block" messages until release is closed, calls exit on "exit"
+// messages and records the order in which messages are processed and OnClose is called.
+type drainClient struct {
+	*wstest.RecordingClient
+	// Receives a value when a "block" message starts being processed
+	started chan struct{}
+	// Closed to release the blocked callbacks
+	release chan struct{}
+	// Recorded events
+	mu  sync.Mutex
+	evt []string
+}
+
+func newDrainClient() *drainClient {
+	return &drainClient{
+		RecordingClient: wstest.NewRecordingClient(),
+		started:         make(chan struct{}, 1),
+		release:         make(chan struct{}),
+	}
+}
+
+// Record the call and process the message
+func (client *drainClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.RecordingClient.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	switch string(msg) {
+	case "block":
+		client.started <- struct{}{}
+		<-client.release
+		client.record("processed")
+	case "exit":
+		exit()
+	}
+}
+
+// Record the call and the event
+func (client *drainClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.record("closed")
+	return client.RecordingClient.OnClose(ctx, conn, readMutex, sessionId, closeMessage)
+}
+
+func (client *drainClient) record(event string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.evt = append(client.evt, event)
+}
+
+func (client *drainClient) events() []string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]string(nil), client.evt...)
+}
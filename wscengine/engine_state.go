@@ -0,0 +1,170 @@
+package wscengine
+
+import "sync"
+
+// Connection state of the engine.
+type EngineState int
+
+const (
+	// Engine is starting and opens its first connection.
+	EngineStateConnecting EngineState = iota
+	// Engine has opened a connection and processes messages.
+	EngineStateConnected
+	// Connection has been interrupted and the engine tries to open a new one.
+	EngineStateReconnecting
+	// Engine is not started or has stopped. This is the state of a new engine.
+	EngineStateStopped
+)
+
+// Return the state name.
+func (state EngineState) String() string {
+	switch state {
+	case EngineStateConnecting:
+		return "connecting"
+	case EngineStateConnected:
+		return "connected"
+	case EngineStateReconnecting:
+		return "reconnecting"
+	case EngineStateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// # Description
+//
+// Return the current connection state of the engine.
+func (wsengine *WebsocketEngine) State() EngineState {
+	return wsengine.stateNotifier.current()
+}
+
+// # Description
+//
+// Return the ID of the current session or, if the engine is not connected, the ID of the last
+// session. An empty string is returned if the engine has never opened a connection.
+func (wsengine *WebsocketEngine) SessionID() string {
+	return wsengine.lastSessionID()
+}
+
+// # Description
+//
+// Return the number of times the engine has reconnected to the server. The count includes the
+// reconnects recorded in the state restored by the configured state persister, if any.
+func (wsengine *WebsocketEngine) ReconnectCount() int {
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	return wsengine.state.RestartCount
+}
+
+// # Description
+//
+// Return a channel which is closed when the engine definitely stops: Stop has been called, exit
+// has been called by a callback or the engine has stopped reconnecting (see
+// WithMaxReconnectAttempts).
+//
+// The channel is bound to the engine context created by Start: call Done after Start. A new
+// channel is used when the engine starts again after it has stopped. Nil is returned if the
+// engine has never been started.
+func (wsengine *WebsocketEngine) Done() <-chan struct{} {
+	if wsengine.engineCtx == nil {
+		return nil
+	}
+	return wsengine.engineCtx.Done()
+}
+
+// # Description
+//
+// Return a channel which receives the state changes of the engine.
+//
+// The channel has a capacity of 1 and only keeps the latest state: the engine never blocks on it
+// and intermediate states are dropped if they are not read in time. The channel is closed when the
+// engine stops, after EngineStateStopped has been published. A new channel is used when the engine
+// starts again after it has stopped: call StateChanges again after Start.
+func (wsengine *WebsocketEngine) StateChanges() <-chan EngineState {
+	return wsengine.stateNotifier.channel()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Publisher of the engine state changes.
+type engineStateNotifier struct {
+	// Mutex used to protect the fields below
+	mu sync.Mutex
+	// Current state
+	state EngineState
+	// Channel which holds the latest published state
+	changes chan EngineState
+	// Indicates whether changes has been closed
+	closed bool
+}
+
+// Create a new notifier in the stopped state. The channel is empty until the engine starts.
+func newEngineStateNotifier() *engineStateNotifier {
+	return &engineStateNotifier{
+		mu:      sync.Mutex{},
+		state:   EngineStateStopped,
+		changes: make(chan EngineState, 1),
+		closed:  false,
+	}
+}
+
+// Return the current state.
+func (notifier *engineStateNotifier) current() EngineState {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	return notifier.state
+}
+
+// Return the current channel.
+func (notifier *engineStateNotifier) channel() <-chan EngineState {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	return notifier.changes
+}
+
+// Open a new channel if the current one has been closed and publish the state.
+func (notifier *engineStateNotifier) reset(state EngineState) {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.closed {
+		notifier.changes = make(chan EngineState, 1)
+		notifier.closed = false
+	}
+	notifier.publish(state)
+}
+
+// Publish the state.
+func (notifier *engineStateNotifier) set(state EngineState) {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	notifier.publish(state)
+}
+
+// Publish EngineStateStopped and close the channel.
+func (notifier *engineStateNotifier) stop() {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	notifier.publish(EngineStateStopped)
+	if !notifier.closed {
+		close(notifier.changes)
+		notifier.closed = true
+	}
+}
+
+// Update the state and replace the state held by the channel, if any. Mutex must be held.
+func (notifier *engineStateNotifier) publish(state EngineState) {
+	notifier.state = state
+	if notifier.closed {
+		return
+	}
+	// Drop the state which has not been read - publishers are serialized by the mutex so there
+	// is always room for the new state once the channel has been drained.
+	select {
+	case <-notifier.changes:
+	default:
+	}
+	notifier.changes <- state
+}
@@ -0,0 +1,129 @@
+package wscengine
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine state unit tests
+type EngineStateUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run EngineStateUnitTestSuite test suite
+func TestEngineStateUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EngineStateUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the notifier keeps only the latest state, closes the channel when stopped and opens a new
+// channel when reset.
+func (suite *EngineStateUnitTestSuite) TestEngineStateNotifier() {
+	notifier := newEngineStateNotifier()
+	require.Equal(suite.T(), EngineStateStopped, notifier.current())
+	// Only the latest state is kept
+	notifier.reset(EngineStateConnecting)
+	notifier.set(EngineStateConnected)
+	notifier.set(EngineStateReconnecting)
+	require.Equal(suite.T(), EngineStateReconnecting, notifier.current())
+	changes := notifier.channel()
+	require.Equal(suite.T(), EngineStateReconnecting, <-changes)
+	// Stopped state is published and channel is closed
+	notifier.stop()
+	require.Equal(suite.T(), EngineStateStopped, notifier.current())
+	state, ok := <-changes
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), EngineStateStopped, state)
+	_, ok = <-changes
+	require.False(suite.T(), ok)
+	// Publishing after stop does not panic
+	notifier.set(EngineStateConnected)
+	notifier.stop()
+	// Reset opens a new channel
+	notifier.reset(EngineStateConnecting)
+	require.NotEqual(suite.T(), changes, notifier.channel())
+	require.Equal(suite.T(), EngineStateConnecting, <-notifier.channel())
+}
+
+// Test state names.
+func (suite *EngineStateUnitTestSuite) TestEngineStateString() {
+	require.Equal(suite.T(), "connecting", EngineStateConnecting.String())
+	require.Equal(suite.T(), "connected", EngineStateConnected.String())
+	require.Equal(suite.T(), "reconnecting", EngineStateReconnecting.String())
+	require.Equal(suite.T(), "stopped", EngineStateStopped.String())
+	require.Equal(suite.T(), "unknown", EngineState(42).String())
+}
+
+// Test the engine publishes its state when it starts, reconnects and stops.
+func (suite *EngineStateUnitTestSuite) TestEngineStateChanges() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithAutoReconnect(true).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), EngineStateStopped, engine.State())
+	require.Empty(suite.T(), engine.SessionID())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := engine.StateChanges()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.Equal(suite.T(), EngineStateConnected, engine.State())
+	require.Equal(suite.T(), EngineStateConnected, <-changes)
+	firstSessionId := engine.SessionID()
+	require.NotEmpty(suite.T(), firstSessionId)
+	require.Zero(suite.T(), engine.ReconnectCount())
+	// Server closes the connection: engine reconnects
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return engine.State() == EngineStateConnected && len(changes) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(suite.T(), EngineStateConnected, <-changes)
+	require.NotEqual(suite.T(), firstSessionId, engine.SessionID())
+	require.Equal(suite.T(), 1, engine.ReconnectCount())
+	// Engine stops: stopped state is published and channel is closed
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Equal(suite.T(), EngineStateStopped, engine.State())
+	require.Equal(suite.T(), EngineStateStopped, <-changes)
+	_, ok := <-changes
+	require.False(suite.T(), ok)
+}
+
+// Test the Done channel is closed when the engine stops.
+func (suite *EngineStateUnitTestSuite) TestEngineDone() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), nil, nil)
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), engine.Done())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	done := engine.Done()
+	select {
+	case <-done:
+		suite.FailNow("done channel should not be closed while the engine runs")
+	default:
+	}
+	require.NoError(suite.T(), engine.Stop(ctx))
+	select {
+	case <-done:
+	default:
+		suite.FailNow("done channel should be closed once the engine has stopped")
+	}
+}
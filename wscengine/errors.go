@@ -1,6 +1,9 @@
 package wscengine
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 /*************************************************************************************************/
 /* ENGINE START ERROR                                                                            */
@@ -19,3 +22,97 @@ func (err EngineStartError) Error() string {
 func (err EngineStartError) Unwrap() error {
 	return err.Err
 }
+
+/*************************************************************************************************/
+/* LIFECYCLE ERRORS                                                                              */
+/*************************************************************************************************/
+
+// Error which occurs when the engine fails to open a connection to the server. The error is
+// embedded in the EngineStartError returned by Start or provided to OnRestartError.
+type DialError struct {
+	// Error returned by the connection adapter
+	Cause error
+	// Number of the connection attempt - 1 when the engine starts, retry count + 1 when the
+	// engine restarts
+	Attempt int
+}
+
+func (err DialError) Error() string {
+	return fmt.Sprintf("websocket engine failed to dial the server (attempt %d): %v", err.Attempt, err.Cause)
+}
+
+func (err DialError) Unwrap() error {
+	return err.Cause
+}
+
+// Error provided to OnRestartError when the engine fails to restart.
+type RestartError struct {
+	// Error which has caused the restart to fail - usually an EngineStartError
+	Cause error
+	// Number of consecutive failed restarts before this one
+	RetryCount int
+}
+
+func (err RestartError) Error() string {
+	return fmt.Sprintf("websocket engine failed to restart (retry %d): %v", err.RetryCount, err.Cause)
+}
+
+func (err RestartError) Unwrap() error {
+	return err.Cause
+}
+
+// Error provided to OnCloseError when the engine fails to close the connection.
+type CloseError struct {
+	// Error returned by the connection adapter
+	Cause error
+}
+
+func (err CloseError) Error() string {
+	return fmt.Sprintf("websocket engine failed to close the connection: %v", err.Cause)
+}
+
+func (err CloseError) Unwrap() error {
+	return err.Cause
+}
+
+/*************************************************************************************************/
+/* RECONNECT ERRORS                                                                              */
+/*************************************************************************************************/
+
+// Error provided to OnCloseError when the engine stops reconnecting because the reconnect backoff
+// function has returned StopReconnecting.
+var ErrReconnectAborted = errors.New("reconnect aborted by the reconnect backoff function")
+
+// Error provided to OnCloseError when the engine stops reconnecting because the maximum number of
+// consecutive reconnect attempts has been reached.
+var ErrMaxReconnectAttemptsExceeded = errors.New("maximum number of reconnect attempts exceeded")
+
+/*************************************************************************************************/
+/* READ ERRORS                                                                                   */
+/*************************************************************************************************/
+
+// Error provided to OnReadError when no message has been received during the read idle timeout
+// (see WithSilentDeadlineDetection): the connection is considered dead and the engine restarts.
+var ErrReadIdleTimeout = errors.New("no message received during the read idle timeout")
+
+/*************************************************************************************************/
+/* PAUSE ERRORS                                                                                  */
+/*************************************************************************************************/
+
+// Error returned by Pause when the engine is already paused.
+var ErrEngineAlreadyPaused = errors.New("websocket engine is already paused")
+
+// Error returned by Resume when the engine is not paused.
+var ErrEngineNotPaused = errors.New("websocket engine is not paused")
+
+/*************************************************************************************************/
+/* WRITE QUEUE ERRORS                                                                            */
+/*************************************************************************************************/
+
+// Error returned by Write when the write queue is enabled (see WithWriteQueue) and the message
+// cannot be queued before the provided context is done.
+var ErrWriteQueueFull = errors.New("write queue is full")
+
+// Error returned by Write when the write rate is limited (see WithWriteRateLimit) and the limit
+// does not allow to write the message before the provided context is done.
+var ErrWriteRateLimited = errors.New("write rate limit exceeded")
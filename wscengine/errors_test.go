@@ -1,9 +1,16 @@
 package wscengine
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -42,3 +49,60 @@ func (suite *WebsocketEngineOptionsUnitTestSuite) TestUnwrap() {
 		Err: fmt.Errorf(expected),
 	}.Unwrap().Error())
 }
+
+// Test the lifecycle errors messages and their unwrapping.
+func (suite *EngineStartErrorUnitTestSuite) TestLifecycleErrors() {
+	cause := fmt.Errorf("connection refused")
+	err := error(RestartError{Cause: EngineStartError{Err: DialError{Cause: cause, Attempt: 3}}, RetryCount: 2})
+	require.Equal(suite.T(), "websocket engine failed to restart (retry 2): websocket engine failed to start: websocket engine failed to dial the server (attempt 3): connection refused", err.Error())
+	require.ErrorIs(suite.T(), err, cause)
+	dialErr := new(DialError)
+	require.ErrorAs(suite.T(), err, dialErr)
+	require.Equal(suite.T(), 3, dialErr.Attempt)
+	err = CloseError{Cause: cause}
+	require.Equal(suite.T(), "websocket engine failed to close the connection: connection refused", err.Error())
+	require.ErrorIs(suite.T(), err, cause)
+}
+
+// Test Start returns a DialError and OnRestartError receives RestartError which carry the retry
+// count and the dial attempt.
+func (suite *EngineStartErrorUnitTestSuite) TestEngineProvidesLifecycleErrors() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialErr := fmt.Errorf("connection refused")
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	adapter.SetDialResponse(nil, dialErr)
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	// Start fails
+	err = engine.Start(ctx)
+	target := new(DialError)
+	require.ErrorAs(suite.T(), err, target)
+	require.Equal(suite.T(), 1, target.Attempt)
+	require.ErrorIs(suite.T(), err, dialErr)
+	// Engine fails to reconnect once the connection is closed by the server
+	adapter.SetDialResponse(nil, nil)
+	require.NoError(suite.T(), engine.Start(ctx))
+	adapter.SetDialResponse(nil, dialErr)
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnRestartErrors()) >= 2
+	}, 5*time.Second, time.Millisecond)
+	// Let the engine reconnect and stop it
+	adapter.SetDialResponse(nil, nil)
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), engine.Stop(ctx))
+	for retryCount, call := range client.RecordedOnRestartErrors()[:2] {
+		restartErr := new(RestartError)
+		require.True(suite.T(), errors.As(call.Err, restartErr))
+		require.Equal(suite.T(), retryCount, restartErr.RetryCount)
+		require.ErrorAs(suite.T(), call.Err, target)
+		require.Equal(suite.T(), retryCount+1, target.Attempt)
+	}
+}
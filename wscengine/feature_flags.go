@@ -0,0 +1,153 @@
+package wscengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+/*************************************************************************************************/
+/* FEATURE FLAGS                                                                                 */
+/*************************************************************************************************/
+
+// Key of a feature flag used to toggle an experimental engine feature.
+type FeatureFlagKey string
+
+// Error returned by SetFeatureFlag when the flag is unknown.
+var ErrUnknownFeatureFlag = errors.New("unknown feature flag")
+
+// Error returned by SetFeatureFlag when the flag cannot be toggled while the engine runs. The new
+// value is recorded and takes effect the next time the engine starts or restarts.
+var ErrFlagRequiresRestart = errors.New("feature flag requires an engine restart to take effect")
+
+// Specification of a feature flag.
+type featureFlagSpec struct {
+	// Indicates whether a change takes effect only when the engine starts or restarts.
+	requiresRestart bool
+}
+
+// Feature flags known by the engine. Experimental features add their flag here: flags are all
+// disabled when the engine is created.
+var featureFlagSpecs = map[FeatureFlagKey]featureFlagSpec{}
+
+// # Description
+//
+// Enable or disable an experimental engine feature at runtime. The method can be called from
+// inside callbacks.
+//
+// # Returns
+//
+//   - nil if the flag has been toggled.
+//   - ErrUnknownFeatureFlag if the flag is unknown: nothing is changed.
+//   - ErrFlagRequiresRestart if the flag can only change when the engine starts: the new value is
+//     recorded and will be applied the next time the engine starts or restarts.
+func (wsengine *WebsocketEngine) SetFeatureFlag(flag FeatureFlagKey, enabled bool) error {
+	spec, found := featureFlagSpecs[flag]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownFeatureFlag, flag)
+	}
+	wsengine.featureFlagsMutex.Lock()
+	defer wsengine.featureFlagsMutex.Unlock()
+	if spec.requiresRestart {
+		wsengine.pendingFeatureFlags[flag] = enabled
+		return fmt.Errorf("%w: %s", ErrFlagRequiresRestart, flag)
+	}
+	wsengine.featureFlags[flag] = enabled
+	return nil
+}
+
+// # Description
+//
+// Return a copy of the current state of all feature flags. Pending changes of flags which require
+// a restart are not included until they are applied.
+func (wsengine *WebsocketEngine) FeatureFlags() map[FeatureFlagKey]bool {
+	wsengine.featureFlagsMutex.Lock()
+	defer wsengine.featureFlagsMutex.Unlock()
+	flags := make(map[FeatureFlagKey]bool, len(wsengine.featureFlags))
+	for flag, enabled := range wsengine.featureFlags {
+		flags[flag] = enabled
+	}
+	return flags
+}
+
+// Apply recorded changes of flags which require a restart. Called when the engine starts or
+// restarts.
+func (wsengine *WebsocketEngine) applyPendingFeatureFlags() {
+	wsengine.featureFlagsMutex.Lock()
+	defer wsengine.featureFlagsMutex.Unlock()
+	for flag, enabled := range wsengine.pendingFeatureFlags {
+		wsengine.featureFlags[flag] = enabled
+		delete(wsengine.pendingFeatureFlags, flag)
+	}
+}
+
+// Build the initial feature flags state: all known flags are disabled.
+func newFeatureFlags() map[FeatureFlagKey]bool {
+	flags := make(map[FeatureFlagKey]bool, len(featureFlagSpecs))
+	for flag := range featureFlagSpecs {
+		flags[flag] = false
+	}
+	return flags
+}
+
+/*************************************************************************************************/
+/* DEBUG HANDLER                                                                                 */
+/*************************************************************************************************/
+
+// Engine state returned by the debug handler.
+type EngineDebugState struct {
+	// Indicates whether the engine is started
+	Started bool `json:"started"`
+	// Current state of the feature flags
+	FeatureFlags map[FeatureFlagKey]bool `json:"featureFlags"`
+	// Last dial attempts, oldest first
+	DialHistory []DialAttempt `json:"dialHistory"`
+}
+
+// # Description
+//
+// Create a http.Handler which exposes the engine state, usually mounted on /debug/engine.
+//
+//   - GET returns the engine state as a JSON encoded EngineDebugState.
+//   - POST toggles a feature flag with the 'flag' and 'enabled' query parameters (for example
+//     /debug/engine?flag=my_flag&enabled=true) and returns the engine state. The handler responds
+//     with 400 if enabled is invalid, 404 if the flag is unknown and 202 if the flag requires a
+//     restart to take effect.
+//
+// The handler does not perform any authentication: mount it on an internal listener only.
+func NewEngineDebugHandler(wsengine *WebsocketEngine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		switch r.Method {
+		case http.MethodGet:
+			// Nothing to do
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "invalid enabled parameter", http.StatusBadRequest)
+				return
+			}
+			err = wsengine.SetFeatureFlag(FeatureFlagKey(r.URL.Query().Get("flag")), enabled)
+			if errors.Is(err, ErrUnknownFeatureFlag) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, ErrFlagRequiresRestart) {
+				status = http.StatusAccepted
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(EngineDebugState{
+			Started:      wsengine.IsStarted(),
+			FeatureFlags: wsengine.FeatureFlags(),
+			DialHistory:  wsengine.DialHistory(),
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package wscengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test flags registered for the duration of the tests
+const (
+	testRuntimeFlag FeatureFlagKey = "test_runtime"
+	testRestartFlag FeatureFlagKey = "test_restart"
+)
+
+// Test suite used for feature flags unit tests
+type FeatureFlagsUnitTestSuite struct {
+	suite.Suite
+	// Engine used by tests
+	engine *WebsocketEngine
+}
+
+// Run FeatureFlagsUnitTestSuite test suite
+func TestFeatureFlagsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FeatureFlagsUnitTestSuite))
+}
+
+// Register test flags and create a new engine before each test.
+func (suite *FeatureFlagsUnitTestSuite) SetupTest() {
+	featureFlagSpecs[testRuntimeFlag] = featureFlagSpec{requiresRestart: false}
+	featureFlagSpecs[testRestartFlag] = featureFlagSpec{requiresRestart: true}
+	engine, err := NewWebsocketEngine(
+		&url.URL{Scheme: "ws", Host: "localhost"},
+		wsadapters.NewWebsocketConnectionAdapterInterfaceMock(),
+		wsclient.NewWebsocketClientMock(),
+		nil,
+		nil)
+	require.NoError(suite.T(), err)
+	suite.engine = engine
+}
+
+// Unregister test flags after each test.
+func (suite *FeatureFlagsUnitTestSuite) TearDownTest() {
+	delete(featureFlagSpecs, testRuntimeFlag)
+	delete(featureFlagSpecs, testRestartFlag)
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test feature flags are toggled at runtime or on restart.
+func (suite *FeatureFlagsUnitTestSuite) TestSetFeatureFlag() {
+	// All flags are disabled by default
+	require.Equal(suite.T(), map[FeatureFlagKey]bool{testRuntimeFlag: false, testRestartFlag: false}, suite.engine.FeatureFlags())
+	// Runtime flag is toggled immediately
+	require.NoError(suite.T(), suite.engine.SetFeatureFlag(testRuntimeFlag, true))
+	require.True(suite.T(), suite.engine.FeatureFlags()[testRuntimeFlag])
+	// Restart flag is toggled when the engine (re)starts
+	require.ErrorIs(suite.T(), suite.engine.SetFeatureFlag(testRestartFlag, true), ErrFlagRequiresRestart)
+	require.False(suite.T(), suite.engine.FeatureFlags()[testRestartFlag])
+	suite.engine.applyPendingFeatureFlags()
+	require.True(suite.T(), suite.engine.FeatureFlags()[testRestartFlag])
+	// Unknown flags are rejected
+	require.ErrorIs(suite.T(), suite.engine.SetFeatureFlag("unknown", true), ErrUnknownFeatureFlag)
+	require.NotContains(suite.T(), suite.engine.FeatureFlags(), FeatureFlagKey("unknown"))
+	// Returned map is a copy
+	suite.engine.FeatureFlags()[testRuntimeFlag] = false
+	require.True(suite.T(), suite.engine.FeatureFlags()[testRuntimeFlag])
+}
+
+// Test the debug handler exposes and toggles feature flags.
+func (suite *FeatureFlagsUnitTestSuite) TestEngineDebugHandler() {
+	srv := httptest.NewServer(NewEngineDebugHandler(suite.engine))
+	defer srv.Close()
+	// Toggle flags
+	for query, expected := range map[string]int{
+		"?flag=test_runtime&enabled=true": http.StatusOK,
+		"?flag=test_restart&enabled=true": http.StatusAccepted,
+		"?flag=unknown&enabled=true":      http.StatusNotFound,
+		"?flag=test_runtime&enabled=nope": http.StatusBadRequest,
+	} {
+		resp, err := http.Post(srv.URL+"/debug/engine"+query, "", nil)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		require.Equal(suite.T(), expected, resp.StatusCode, query)
+	}
+	// Get state
+	resp, err := http.Get(srv.URL + "/debug/engine")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	state := EngineDebugState{}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&state))
+	require.False(suite.T(), state.Started)
+	require.Equal(suite.T(), map[FeatureFlagKey]bool{testRuntimeFlag: true, testRestartFlag: false}, state.FeatureFlags)
+	// Other methods are not allowed
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+"/debug/engine", nil)
+	require.NoError(suite.T(), err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	require.Equal(suite.T(), http.StatusMethodNotAllowed, resp.StatusCode)
+}
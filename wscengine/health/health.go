@@ -0,0 +1,59 @@
+// The package contains a HTTP handler which reports whether a websocket engine is connected, for
+// use as a readiness probe (Kubernetes, load balancers, sidecars, ...).
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gbdevw/gowse/wscengine"
+)
+
+// Engine status returned by the health handler.
+type Status struct {
+	// Connection state of the engine (connecting, connected, reconnecting or stopped)
+	State string `json:"state"`
+	// ID of the current session or of the last session if the engine is not connected
+	SessionID string `json:"sessionId"`
+	// Number of times the engine has reconnected to the server
+	ReconnectCount int `json:"reconnectCount"`
+}
+
+// # Description
+//
+// Create a http.Handler which reports whether the engine is connected, usually mounted on
+// /healthz or /readyz.
+//
+// The handler responds with 200 when the engine state is EngineStateConnected and with 503
+// Service Unavailable otherwise. In both cases, the body is the JSON encoded Status of the engine.
+// Only GET and HEAD requests are accepted.
+//
+// # Inputs
+//
+//   - engine: Engine to probe.
+//
+// # Returns
+//
+// The health handler.
+func NewHTTPHandler(engine *wscengine.WebsocketEngine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := engine.State()
+		status := http.StatusOK
+		if state != wscengine.EngineStateConnected {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Status{
+			State:          state.String(),
+			SessionID:      engine.SessionID(),
+			ReconnectCount: engine.ReconnectCount(),
+		})
+	})
+}
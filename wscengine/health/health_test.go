@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+// Test suite used for the health handler
+type HealthHandlerTestSuite struct {
+	suite.Suite
+}
+
+// Run HealthHandlerTestSuite test suite
+func TestHealthHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthHandlerTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the handler reports the engine state, session ID and reconnect count.
+func (suite *HealthHandlerTestSuite) TestHealthHandler() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := wscengine.NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	srv := httptest.NewServer(NewHTTPHandler(engine))
+	defer srv.Close()
+	// Engine is not started
+	status, health := suite.get(srv.URL)
+	require.Equal(suite.T(), http.StatusServiceUnavailable, status)
+	require.Equal(suite.T(), Status{State: "stopped"}, health)
+	// Engine is connected
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	status, health = suite.get(srv.URL)
+	require.Equal(suite.T(), http.StatusOK, status)
+	require.Equal(suite.T(), Status{State: "connected", SessionID: engine.SessionID(), ReconnectCount: 0}, health)
+	// Engine has reconnected
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return engine.ReconnectCount() == 1 && engine.State() == wscengine.EngineStateConnected
+	}, 5*time.Second, time.Millisecond)
+	status, health = suite.get(srv.URL)
+	require.Equal(suite.T(), http.StatusOK, status)
+	require.Equal(suite.T(), 1, health.ReconnectCount)
+	// Engine is stopped
+	require.NoError(suite.T(), engine.Stop(ctx))
+	status, health = suite.get(srv.URL)
+	require.Equal(suite.T(), http.StatusServiceUnavailable, status)
+	require.Equal(suite.T(), Status{State: "stopped", SessionID: engine.SessionID(), ReconnectCount: 1}, health)
+	// Other methods are not allowed
+	resp, err := http.Post(srv.URL, "", nil)
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	require.Equal(suite.T(), http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Get the health status and decode the response body.
+func (suite *HealthHandlerTestSuite) get(url string) (int, Status) {
+	resp, err := http.Get(url)
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), "application/json", resp.Header.Get("Content-Type"))
+	health := Status{}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&health))
+	return resp.StatusCode, health
+}
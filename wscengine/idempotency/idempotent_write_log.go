@@ -0,0 +1,216 @@
+// The package provides idempotent writes: outbound messages are wrapped in an envelope which
+// carries an idempotency key so they can be safely re-sent after a reconnection. The server is
+// expected to deduplicate messages by key.
+package idempotency
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Status of a message tracked by IdempotentWriteLog.
+type Status int
+
+const (
+	// The message has been written (or the write has failed) and has not been acknowledged yet.
+	// Pending messages are re-sent by IdempotentWriter.Replay.
+	StatusPending Status = iota
+	// The server has acknowledged the message.
+	StatusAcknowledged
+	// The message has not been acknowledged before the log TTL elapsed. Expired messages are not
+	// re-sent anymore.
+	StatusExpired
+)
+
+// Return the status name.
+func (status Status) String() string {
+	switch status {
+	case StatusPending:
+		return "pending"
+	case StatusAcknowledged:
+		return "acknowledged"
+	case StatusExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(status))
+	}
+}
+
+// A message tracked by IdempotentWriteLog.
+type Entry struct {
+	// Idempotency key
+	Key string
+	// Message type
+	MsgType wsadapters.MessageType
+	// Message content, without envelope
+	Msg []byte
+	// Time of the first write
+	SentAt time.Time
+	// Number of write attempts
+	Attempts int
+	// Message status
+	Status Status
+}
+
+// Log which tracks the idempotency keys of sent messages and their status.
+//
+// Pending messages expire when they are not acknowledged before the log TTL elapses. Messages
+// are kept in the log during the TTL so a key cannot be reused while the server may still
+// deduplicate it. Cleanup, which is run on each new record, then removes them.
+type IdempotentWriteLog struct {
+	// Time after which pending messages expire
+	ttl time.Duration
+	// Mutex used to protect entries and order
+	mu sync.Mutex
+	// Entries by key
+	entries map[string]*Entry
+	// Keys in record order
+	order []string
+	// Function used to get current time - can be replaced in tests
+	now func() time.Time
+}
+
+// # Description
+//
+// Factory which creates a new, empty IdempotentWriteLog.
+//
+// # Inputs
+//
+//   - ttl: Time after which pending messages expire. Must be positive.
+//
+// # Returns
+//
+// A new IdempotentWriteLog or an error if ttl is not positive.
+func NewIdempotentWriteLog(ttl time.Duration) (*IdempotentWriteLog, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be positive: %s", ttl)
+	}
+	return &IdempotentWriteLog{
+		ttl:     ttl,
+		entries: map[string]*Entry{},
+		order:   []string{},
+		now:     time.Now,
+	}, nil
+}
+
+// # Description
+//
+// Record a write attempt for the provided key. The first attempt creates a pending entry, next
+// attempts increment the entry attempt counter.
+//
+// # Returns
+//
+// An error if the key is empty or if the key is already acknowledged or expired.
+func (log *IdempotentWriteLog) Record(key string, msgType wsadapters.MessageType, msg []byte) error {
+	if key == "" {
+		return fmt.Errorf("idempotency key must not be empty")
+	}
+	log.Cleanup()
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	entry, ok := log.entries[key]
+	if !ok {
+		log.entries[key] = &Entry{
+			Key:      key,
+			MsgType:  msgType,
+			Msg:      msg,
+			SentAt:   log.now(),
+			Attempts: 1,
+			Status:   StatusPending,
+		}
+		log.order = append(log.order, key)
+		return nil
+	}
+	log.refresh(entry)
+	if entry.Status != StatusPending {
+		return fmt.Errorf("message with idempotency key %s is %s", key, entry.Status)
+	}
+	entry.Attempts = entry.Attempts + 1
+	return nil
+}
+
+// # Description
+//
+// Mark the message with the provided key as acknowledged.
+//
+// # Returns
+//
+// An error if the key is unknown or if the message has expired.
+func (log *IdempotentWriteLog) Acknowledge(key string) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	entry, ok := log.entries[key]
+	if !ok {
+		return fmt.Errorf("unknown idempotency key: %s", key)
+	}
+	log.refresh(entry)
+	if entry.Status == StatusExpired {
+		return fmt.Errorf("message with idempotency key %s is expired", key)
+	}
+	entry.Status = StatusAcknowledged
+	return nil
+}
+
+// # Description
+//
+// Return the status of the message with the provided key.
+//
+// # Returns
+//
+// The message status and true if the key is tracked, false otherwise.
+func (log *IdempotentWriteLog) Status(key string) (Status, bool) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	entry, ok := log.entries[key]
+	if !ok {
+		return StatusPending, false
+	}
+	log.refresh(entry)
+	return entry.Status, true
+}
+
+// # Description
+//
+// Return a copy of the pending entries in record order.
+func (log *IdempotentWriteLog) Pending() []Entry {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	pending := []Entry{}
+	for _, key := range log.order {
+		entry := log.entries[key]
+		log.refresh(entry)
+		if entry.Status == StatusPending {
+			pending = append(pending, *entry)
+		}
+	}
+	return pending
+}
+
+// # Description
+//
+// Remove acknowledged and expired entries which have been first written more than TTL ago.
+func (log *IdempotentWriteLog) Cleanup() {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	order := log.order[:0]
+	for _, key := range log.order {
+		entry := log.entries[key]
+		log.refresh(entry)
+		if entry.Status == StatusPending || log.now().Sub(entry.SentAt) <= log.ttl {
+			order = append(order, key)
+		} else {
+			delete(log.entries, key)
+		}
+	}
+	log.order = order
+}
+
+// Mark the entry as expired if it is pending and its TTL has elapsed. Mutex must be locked.
+func (log *IdempotentWriteLog) refresh(entry *Entry) {
+	if entry.Status == StatusPending && log.now().Sub(entry.SentAt) > log.ttl {
+		entry.Status = StatusExpired
+	}
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for IdempotentWriteLog unit tests
+type IdempotentWriteLogUnitTestSuite struct {
+	suite.Suite
+	// Tested log
+	log *IdempotentWriteLog
+	// Current time used by the log
+	now time.Time
+}
+
+// Run IdempotentWriteLogUnitTestSuite test suite
+func TestIdempotentWriteLogUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotentWriteLogUnitTestSuite))
+}
+
+// Create a log with a controllable clock
+func (suite *IdempotentWriteLogUnitTestSuite) SetupTest() {
+	log, err := NewIdempotentWriteLog(time.Minute)
+	require.NoError(suite.T(), err)
+	suite.now = time.Now()
+	log.now = func() time.Time { return suite.now }
+	suite.log = log
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails with invalid ttl.
+func (suite *IdempotentWriteLogUnitTestSuite) TestFactoryWithInvalidTTL() {
+	_, err := NewIdempotentWriteLog(0)
+	require.Error(suite.T(), err)
+}
+
+// Test status transitions: pending, acknowledged and expired.
+func (suite *IdempotentWriteLogUnitTestSuite) TestStatus() {
+	require.Error(suite.T(), suite.log.Record("", wsadapters.Text, nil))
+	require.NoError(suite.T(), suite.log.Record("a", wsadapters.Text, []byte("a")))
+	require.NoError(suite.T(), suite.log.Record("b", wsadapters.Text, []byte("b")))
+	// Second attempt for a pending message
+	require.NoError(suite.T(), suite.log.Record("a", wsadapters.Text, []byte("a")))
+	pending := suite.log.Pending()
+	require.Len(suite.T(), pending, 2)
+	require.Equal(suite.T(), "a", pending[0].Key)
+	require.Equal(suite.T(), 2, pending[0].Attempts)
+	// Acknowledge
+	require.NoError(suite.T(), suite.log.Acknowledge("a"))
+	require.Error(suite.T(), suite.log.Acknowledge("unknown"))
+	status, ok := suite.log.Status("a")
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), StatusAcknowledged, status)
+	require.Error(suite.T(), suite.log.Record("a", wsadapters.Text, []byte("a")))
+	// Expire
+	suite.now = suite.now.Add(2 * time.Minute)
+	status, ok = suite.log.Status("b")
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), StatusExpired, status)
+	require.Error(suite.T(), suite.log.Acknowledge("b"))
+	require.Empty(suite.T(), suite.log.Pending())
+	// Cleanup removes acknowledged and expired messages
+	suite.log.Cleanup()
+	_, ok = suite.log.Status("a")
+	require.False(suite.T(), ok)
+	_, ok = suite.log.Status("b")
+	require.False(suite.T(), ok)
+}
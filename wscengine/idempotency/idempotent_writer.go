@@ -0,0 +1,182 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Function which wraps a message in an envelope which carries the idempotency key.
+type EnvelopeEncoder func(msgType wsadapters.MessageType, msg []byte, idempotencyKey string) ([]byte, error)
+
+// Default envelope: a JSON object with the idempotency key and the message.
+type Envelope struct {
+	// Idempotency key used by the server to deduplicate messages
+	IdempotencyKey string `json:"idempotency_key"`
+	// Message content
+	Payload json.RawMessage `json:"payload"`
+}
+
+// # Description
+//
+// Default EnvelopeEncoder which encodes an Envelope as JSON. Text messages which are valid JSON
+// are embedded as is in the payload field. Other text messages are embedded as JSON strings and
+// binary messages as base64 encoded JSON strings.
+func JSONEnvelopeEncoder(msgType wsadapters.MessageType, msg []byte, idempotencyKey string) ([]byte, error) {
+	payload := json.RawMessage(msg)
+	if msgType != wsadapters.Text || !json.Valid(msg) {
+		var value any = msg
+		if msgType == wsadapters.Text {
+			value = string(msg)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		payload = encoded
+	}
+	return json.Marshal(Envelope{IdempotencyKey: idempotencyKey, Payload: payload})
+}
+
+// Writer which wraps messages in an envelope carrying an idempotency key and tracks them in an
+// IdempotentWriteLog so they can be re-sent after a reconnection.
+//
+// Call SetConnection from the OnOpen callback of the websocket client with the new connection,
+// then Replay to re-send the messages which have not been acknowledged.
+type IdempotentWriter struct {
+	// Log used to track sent messages
+	log *IdempotentWriteLog
+	// Function used to wrap messages
+	encoder EnvelopeEncoder
+	// Mutex used to protect conn
+	mu sync.Mutex
+	// Current connection
+	conn wsadapters.WebsocketConnectionAdapterInterface
+}
+
+// # Description
+//
+// Factory which creates a new IdempotentWriter.
+//
+// # Inputs
+//
+//   - log: Log used to track sent messages. Required.
+//   - encoder: Optional function used to wrap messages. If nil, JSONEnvelopeEncoder is used.
+//
+// # Returns
+//
+// A new IdempotentWriter or an error if log is nil.
+func NewIdempotentWriter(log *IdempotentWriteLog, encoder EnvelopeEncoder) (*IdempotentWriter, error) {
+	if log == nil {
+		return nil, fmt.Errorf("provided log is nil")
+	}
+	if encoder == nil {
+		encoder = JSONEnvelopeEncoder
+	}
+	return &IdempotentWriter{log: log, encoder: encoder}, nil
+}
+
+// # Description
+//
+// Set the connection used to write messages. Must be called from OnOpen each time the engine
+// (re)opens the connection.
+func (writer *IdempotentWriter) SetConnection(conn wsadapters.WebsocketConnectionAdapterInterface) {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	writer.conn = conn
+}
+
+// # Description
+//
+// Write the message wrapped in an envelope which carries the idempotency key. The message is
+// recorded as pending in the log before it is written: if the write fails, it is unclear whether
+// the server has received the message and the message is re-sent by Replay.
+//
+// Writing again a pending message with the same key is allowed: the server deduplicates it.
+//
+// # Returns
+//
+// An error if the key is empty, already acknowledged or expired, if there is no connection or
+// if the write has failed.
+func (writer *IdempotentWriter) IdempotentWrite(ctx context.Context, msgType wsadapters.MessageType, msg []byte, idempotencyKey string) error {
+	err := writer.log.Record(idempotencyKey, msgType, msg)
+	if err != nil {
+		return err
+	}
+	return writer.write(ctx, msgType, msg, idempotencyKey)
+}
+
+// # Description
+//
+// Re-send all pending messages in the order they were first written. Replay stops at the first
+// write failure.
+//
+// # Returns
+//
+// The number of re-sent messages and an error if a write has failed.
+func (writer *IdempotentWriter) Replay(ctx context.Context) (int, error) {
+	replayed := 0
+	for _, entry := range writer.log.Pending() {
+		err := writer.log.Record(entry.Key, entry.MsgType, entry.Msg)
+		if err != nil {
+			// Message has been acknowledged or has expired in the meantime - skip
+			continue
+		}
+		err = writer.write(ctx, entry.MsgType, entry.Msg, entry.Key)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay message with idempotency key %s: %w", entry.Key, err)
+		}
+		replayed = replayed + 1
+	}
+	return replayed, nil
+}
+
+// Wrap and write a message on the current connection.
+func (writer *IdempotentWriter) write(ctx context.Context, msgType wsadapters.MessageType, msg []byte, idempotencyKey string) error {
+	envelope, err := writer.encoder(msgType, msg, idempotencyKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap message with idempotency key %s: %w", idempotencyKey, err)
+	}
+	writer.mu.Lock()
+	conn := writer.conn
+	writer.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("idempotent write failed because there is no connection")
+	}
+	return conn.Write(ctx, msgType, envelope)
+}
+
+// # Description
+//
+// Build a middleware which acknowledges messages in the log when the server acknowledges them.
+// Acknowledged messages are then removed from the log by its automatic cleanup.
+//
+// # Inputs
+//
+//   - log: Log in which messages are acknowledged.
+//   - extractor: Function which returns the idempotency key acknowledged by a received message
+//     and true, or false if the message is not an acknowledgement.
+//   - forward: If true, acknowledgements are handed over to the next handler. Otherwise they are
+//     dropped by the middleware.
+//
+// # Returns
+//
+// The middleware.
+func AcknowledgementMiddleware(log *IdempotentWriteLog, extractor func(msg []byte) (string, bool), forward bool) middleware.MessageMiddleware {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		key, ok := extractor(msg)
+		if !ok {
+			next(ctx, msgType, msg)
+			return
+		}
+		// Errors are ignored: acknowledgements for unknown or expired keys are not actionable
+		log.Acknowledge(key)
+		if forward {
+			next(ctx, msgType, msg)
+		}
+	}
+}
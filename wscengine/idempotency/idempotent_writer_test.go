@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for IdempotentWriter unit tests
+type IdempotentWriterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run IdempotentWriterUnitTestSuite test suite
+func TestIdempotentWriterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotentWriterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test JSONEnvelopeEncoder.
+func (suite *IdempotentWriterUnitTestSuite) TestJSONEnvelopeEncoder() {
+	encoded, err := JSONEnvelopeEncoder(wsadapters.Text, []byte(`{"a":1}`), "k1")
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"idempotency_key":"k1","payload":{"a":1}}`, string(encoded))
+	encoded, err = JSONEnvelopeEncoder(wsadapters.Text, []byte(`hello`), "k2")
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"idempotency_key":"k2","payload":"hello"}`, string(encoded))
+	encoded, err = JSONEnvelopeEncoder(wsadapters.Binary, []byte{0x01, 0x02}, "k3")
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"idempotency_key":"k3","payload":"AQI="}`, string(encoded))
+}
+
+// Test failed writes are replayed with the same key and acknowledged messages are not.
+func (suite *IdempotentWriterUnitTestSuite) TestIdempotentWriteAndReplay() {
+	log, err := NewIdempotentWriteLog(time.Minute)
+	require.NoError(suite.T(), err)
+	_, err = NewIdempotentWriter(nil, nil)
+	require.Error(suite.T(), err)
+	writer, err := NewIdempotentWriter(log, nil)
+	require.NoError(suite.T(), err)
+	// No connection
+	require.Error(suite.T(), writer.IdempotentWrite(context.Background(), wsadapters.Text, []byte(`1`), "k0"))
+	// First connection: write k1 succeeds, write k2 fails
+	conn := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	conn.On("Write", mock.Anything, wsadapters.Text, []byte(`{"idempotency_key":"k1","payload":1}`)).Return(nil)
+	conn.On("Write", mock.Anything, wsadapters.Text, []byte(`{"idempotency_key":"k2","payload":2}`)).Return(fmt.Errorf("connection lost"))
+	writer.SetConnection(conn)
+	require.NoError(suite.T(), writer.IdempotentWrite(context.Background(), wsadapters.Text, []byte(`1`), "k1"))
+	require.Error(suite.T(), writer.IdempotentWrite(context.Background(), wsadapters.Text, []byte(`2`), "k2"))
+	// Server acknowledges k1
+	ack := middleware.Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		suite.FailNow("acknowledgement should not be forwarded")
+	}, AcknowledgementMiddleware(log, func(msg []byte) (string, bool) {
+		return strings.CutPrefix(string(msg), "ack:")
+	}, false))
+	ack(context.Background(), wsadapters.Text, []byte("ack:k1"))
+	status, _ := log.Status("k1")
+	require.Equal(suite.T(), StatusAcknowledged, status)
+	// Reconnect: only k0 and k2 are replayed
+	reconnected := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	reconnected.On("Write", mock.Anything, wsadapters.Text, mock.Anything).Return(nil)
+	writer.SetConnection(reconnected)
+	replayed, err := writer.Replay(context.Background())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), 2, replayed)
+	reconnected.AssertCalled(suite.T(), "Write", mock.Anything, wsadapters.Text, []byte(`{"idempotency_key":"k0","payload":1}`))
+	reconnected.AssertCalled(suite.T(), "Write", mock.Anything, wsadapters.Text, []byte(`{"idempotency_key":"k2","payload":2}`))
+	require.Equal(suite.T(), 2, log.Pending()[1].Attempts)
+}
@@ -0,0 +1,119 @@
+package wscengine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Keys of the attributes added to engine logs
+const (
+	logKeySessionId    = "session_id"
+	logKeyTarget       = "target"
+	logKeyRestart      = "restart"
+	logKeyRetryCount   = "retry_count"
+	logKeyCloseCode    = "close_code"
+	logKeyReason       = "reason"
+	logKeyDuration     = "duration"
+	logKeyError        = "error"
+	logKeyHeldMessages = "held_messages"
+	logKeyAffinityAddr = "affinity_addr"
+)
+
+// slog handler which discards all records. Used when no logger is configured.
+type discardLogHandler struct{}
+
+func (handler discardLogHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (handler discardLogHandler) Handle(context.Context, slog.Record) error { return nil }
+func (handler discardLogHandler) WithAttrs([]slog.Attr) slog.Handler        { return handler }
+func (handler discardLogHandler) WithGroup(string) slog.Handler             { return handler }
+
+// Return the provided logger or a logger which discards all records if nil.
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(discardLogHandler{})
+	}
+	return logger
+}
+
+// Decorator used by the engine to log ping/pong cycles at DEBUG level.
+type loggingConnectionDecorator struct {
+	// Decorated connection adapter
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// Logger to log to
+	logger *slog.Logger
+}
+
+// Simple proxy for Dial method.
+func (adapter *loggingConnectionDecorator) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method.
+func (adapter *loggingConnectionDecorator) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *loggingConnectionDecorator) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Proxy for Ping method which logs the ping and the pong or the error.
+func (adapter *loggingConnectionDecorator) Ping(ctx context.Context) error {
+	adapter.logger.DebugContext(ctx, "ping sent")
+	start := time.Now()
+	err := adapter.decorated.Ping(ctx)
+	if err != nil {
+		adapter.logger.DebugContext(ctx, "ping failed", logKeyError, err)
+		return err
+	}
+	adapter.logger.DebugContext(ctx, "pong received", logKeyDuration, time.Since(start))
+	return nil
+}
+
+// Simple proxy for Read method.
+func (adapter *loggingConnectionDecorator) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	return adapter.decorated.Read(ctx)
+}
+
+// Simple proxy for ReadStream method.
+func (adapter *loggingConnectionDecorator) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	return adapter.decorated.ReadStream(ctx)
+}
+
+// Simple proxy for Write method.
+func (adapter *loggingConnectionDecorator) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	return adapter.decorated.Write(ctx, msgType, msg)
+}
+
+// Simple proxy for WritePropagated method.
+func (adapter *loggingConnectionDecorator) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	return adapter.decorated.WritePropagated(ctx, msgType, msg, propagator)
+}
+
+// Simple proxy for WriteStream method.
+func (adapter *loggingConnectionDecorator) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return adapter.decorated.WriteStream(ctx, msgType)
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *loggingConnectionDecorator) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *loggingConnectionDecorator) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *loggingConnectionDecorator) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
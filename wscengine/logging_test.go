@@ -0,0 +1,123 @@
+package wscengine
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	wscontext "github.com/gbdevw/gowse/wscengine/context"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine logging unit tests
+type LoggingUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run LoggingUnitTestSuite test suite
+func TestLoggingUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the discard logger is used when no logger is configured.
+func (suite *LoggingUnitTestSuite) TestLoggerOrDiscard() {
+	logger := slog.Default()
+	require.Same(suite.T(), logger, loggerOrDiscard(logger))
+	discard := loggerOrDiscard(nil)
+	require.NotNil(suite.T(), discard)
+	require.False(suite.T(), discard.Enabled(context.Background(), slog.LevelError))
+	discard.Error("dropped", "key", "value")
+}
+
+// Test the engine logs its activity with the configured logger.
+func (suite *LoggingUnitTestSuite) TestEngineLogs() {
+	logs := &lockedLogBuffer{}
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond }).
+		WithLogger(logger)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	// Ping with the connection provided to callbacks
+	require.NoError(suite.T(), client.RecordedOnOpens()[0].Conn.Ping(ctx))
+	// Server closes the connection: engine reconnects
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(suite.T(), engine.Stop(ctx))
+	output := logs.String()
+	require.Contains(suite.T(), output, "level=INFO msg=\"websocket connection opened\"")
+	require.Contains(suite.T(), output, "level=DEBUG msg=\"ping sent\"")
+	require.Contains(suite.T(), output, "level=DEBUG msg=\"pong received\"")
+	require.Contains(suite.T(), output, "level=INFO msg=\"websocket connection closed by server\"")
+	require.Contains(suite.T(), output, "close_code=1001 reason=bye")
+	require.Contains(suite.T(), output, "level=WARN msg=\"reconnecting to websocket server\"")
+	require.Contains(suite.T(), output, "level=INFO msg=\"closing websocket connection\"")
+	require.Contains(suite.T(), output, "level=INFO msg=\"engine stopped\"")
+}
+
+// Test callbacks can get the engine logger and tracer through a LazyContext.
+func (suite *LoggingUnitTestSuite) TestCallbackContext() {
+	logger := slog.New(slog.NewTextHandler(&lockedLogBuffer{}, nil))
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().WithLogger(logger)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	for _, callbackCtx := range []context.Context{client.RecordedOnOpens()[0].Ctx, client.RecordedOnMessages()[0].Ctx} {
+		lazy := wscontext.NewLazyContext(callbackCtx)
+		require.Same(suite.T(), logger, lazy.Logger())
+		require.Equal(suite.T(), engine.tracer, lazy.Tracer())
+	}
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Buffer safe for concurrent use
+type lockedLogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (buffer *lockedLogBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.Write(p)
+}
+
+func (buffer *lockedLogBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.String()
+}
@@ -0,0 +1,132 @@
+// The package provides the Metrics interface the websocket engine reports its connection metrics
+// to, a Prometheus implementation of it, and a recorder users can use to record domain specific
+// metrics (order book depth, bid-ask spread, ...) alongside the connection metrics.
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Constants used for instrumentation purpose
+const (
+	// Instrumentation library package name
+	pkgName = "gowsclient.metrics"
+	// Instrumentation library package version
+	pkgVersion = "0.0.0"
+)
+
+// Alias type used as key in context
+type contextKey string
+
+const (
+	// Context key used to store the business metrics recorder
+	recorderKey contextKey = "businessMetrics"
+)
+
+// Recorder for business metrics backed by OpenTelemetry histograms. Instruments are created
+// lazily, the first time a metric name is recorded, and reused afterwards.
+//
+// The recorder is safe for concurrent use. Methods called on a nil recorder do nothing so
+// callbacks do not have to check whether a recorder is available in their context.
+type BusinessMetricsRecorder struct {
+	// Meter used to create instruments
+	meter metric.Meter
+	// Float64 histograms by metric name (metric.Float64Histogram)
+	float64Instruments sync.Map
+	// Int64 histograms by metric name (metric.Int64Histogram)
+	int64Instruments sync.Map
+}
+
+// # Description
+//
+// Factory which creates a new BusinessMetricsRecorder.
+//
+// # Inputs
+//
+//   - provider: Meter provider used to create instruments. If nil, the global meter provider is
+//     used.
+func NewBusinessMetricsRecorder(provider metric.MeterProvider) *BusinessMetricsRecorder {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	return &BusinessMetricsRecorder{
+		meter: provider.Meter(pkgName, metric.WithInstrumentationVersion(pkgVersion)),
+	}
+}
+
+// # Description
+//
+// Record a float64 value for the metric with the provided name. Instrument creation failures are
+// reported to the global OpenTelemetry error handler and the value is dropped.
+func (recorder *BusinessMetricsRecorder) RecordFloat64(ctx context.Context, name string, value float64, attrs ...attribute.KeyValue) {
+	if recorder == nil {
+		return
+	}
+	instrument, ok := recorder.float64Instruments.Load(name)
+	if !ok {
+		created, err := recorder.meter.Float64Histogram(name)
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		instrument, _ = recorder.float64Instruments.LoadOrStore(name, created)
+	}
+	instrument.(metric.Float64Histogram).Record(ctx, value, metric.WithAttributes(attrs...))
+}
+
+// # Description
+//
+// Record an int64 value for the metric with the provided name. Instrument creation failures are
+// reported to the global OpenTelemetry error handler and the value is dropped.
+func (recorder *BusinessMetricsRecorder) RecordInt64(ctx context.Context, name string, value int64, attrs ...attribute.KeyValue) {
+	if recorder == nil {
+		return
+	}
+	instrument, ok := recorder.int64Instruments.Load(name)
+	if !ok {
+		created, err := recorder.meter.Int64Histogram(name)
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		instrument, _ = recorder.int64Instruments.LoadOrStore(name, created)
+	}
+	instrument.(metric.Int64Histogram).Record(ctx, value, metric.WithAttributes(attrs...))
+}
+
+// # Description
+//
+// Return a copy of the provided context which holds the provided recorder.
+func WithBusinessMetrics(ctx context.Context, recorder *BusinessMetricsRecorder) context.Context {
+	return context.WithValue(ctx, recorderKey, recorder)
+}
+
+// # Description
+//
+// Extract the business metrics recorder from the provided context.
+//
+// # Returns
+//
+// The recorder stored in the context or nil if there is none. Methods can safely be called on the
+// returned recorder even if it is nil.
+func BusinessMetricsFromContext(ctx context.Context) *BusinessMetricsRecorder {
+	recorder, _ := ctx.Value(recorderKey).(*BusinessMetricsRecorder)
+	return recorder
+}
+
+// # Description
+//
+// Create a middleware which injects the provided recorder in the context handed over to the next
+// handler so it can be retrieved with BusinessMetricsFromContext in OnMessage.
+func BusinessMetricsMiddleware(recorder *BusinessMetricsRecorder) middleware.MessageMiddleware {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		next(WithBusinessMetrics(ctx, recorder), msgType, msg)
+	}
+}
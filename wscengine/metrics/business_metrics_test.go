@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for BusinessMetricsRecorder unit tests
+type BusinessMetricsRecorderUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run BusinessMetricsRecorderUnitTestSuite test suite
+func TestBusinessMetricsRecorderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(BusinessMetricsRecorderUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test instruments are created once per metric name and values are recorded.
+func (suite *BusinessMetricsRecorderUnitTestSuite) TestRecord() {
+	meter := newMeterRecorder()
+	recorder := NewBusinessMetricsRecorder(&meterProviderStub{meter: meter})
+	ctx := context.Background()
+	recorder.RecordFloat64(ctx, "spread", 0.5, attribute.String("pair", "BTC/USD"))
+	recorder.RecordFloat64(ctx, "spread", 0.25)
+	recorder.RecordInt64(ctx, "depth", 10)
+	recorder.RecordInt64(ctx, "depth", 20)
+	require.Equal(suite.T(), map[string]int{"float64:spread": 1, "int64:depth": 1}, meter.created)
+	require.Equal(suite.T(), []float64{0.5, 0.25}, meter.float64Values["spread"])
+	require.Equal(suite.T(), []int64{10, 20}, meter.int64Values["depth"])
+}
+
+// Test recorder is injected in context by the middleware and nil recorders can be used.
+func (suite *BusinessMetricsRecorderUnitTestSuite) TestContextInjection() {
+	// No recorder in context - methods are no-op
+	missing := BusinessMetricsFromContext(context.Background())
+	require.Nil(suite.T(), missing)
+	missing.RecordFloat64(context.Background(), "spread", 1)
+	missing.RecordInt64(context.Background(), "depth", 1)
+	// Recorder is injected by the middleware
+	meter := newMeterRecorder()
+	recorder := NewBusinessMetricsRecorder(&meterProviderStub{meter: meter})
+	handler := middleware.Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		BusinessMetricsFromContext(ctx).RecordInt64(ctx, "messages", 1)
+	}, BusinessMetricsMiddleware(recorder))
+	handler(context.Background(), wsadapters.Text, []byte("msg"))
+	require.Equal(suite.T(), []int64{1}, meter.int64Values["messages"])
+	require.Same(suite.T(), recorder, BusinessMetricsFromContext(WithBusinessMetrics(context.Background(), recorder)))
+}
+
+// Test the global meter provider is used by default.
+func (suite *BusinessMetricsRecorderUnitTestSuite) TestDefaultMeterProvider() {
+	recorder := NewBusinessMetricsRecorder(nil)
+	require.NotNil(suite.T(), recorder.meter)
+	recorder.RecordFloat64(context.Background(), "spread", 1)
+}
+
+/*************************************************************************************************/
+/* METER STUB                                                                                    */
+/*************************************************************************************************/
+
+// Meter provider which returns the meter recorder
+type meterProviderStub struct {
+	noop.MeterProvider
+	meter *meterRecorder
+}
+
+func (provider *meterProviderStub) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return provider.meter
+}
+
+// Meter which records created instruments and recorded values
+type meterRecorder struct {
+	noop.Meter
+	mu            sync.Mutex
+	created       map[string]int
+	float64Values map[string][]float64
+	int64Values   map[string][]int64
+}
+
+func newMeterRecorder() *meterRecorder {
+	return &meterRecorder{
+		created:       map[string]int{},
+		float64Values: map[string][]float64{},
+		int64Values:   map[string][]int64{},
+	}
+}
+
+func (meter *meterRecorder) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.created["float64:"+name]++
+	return &float64HistogramStub{meter: meter, name: name}, nil
+}
+
+func (meter *meterRecorder) Int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.created["int64:"+name]++
+	return &int64HistogramStub{meter: meter, name: name}, nil
+}
+
+type float64HistogramStub struct {
+	noop.Float64Histogram
+	meter *meterRecorder
+	name  string
+}
+
+func (stub *float64HistogramStub) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	stub.meter.mu.Lock()
+	defer stub.meter.mu.Unlock()
+	stub.meter.float64Values[stub.name] = append(stub.meter.float64Values[stub.name], value)
+}
+
+type int64HistogramStub struct {
+	noop.Int64Histogram
+	meter *meterRecorder
+	name  string
+}
+
+func (stub *int64HistogramStub) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	stub.meter.mu.Lock()
+	defer stub.meter.mu.Unlock()
+	stub.meter.int64Values[stub.name] = append(stub.meter.int64Values[stub.name], value)
+}
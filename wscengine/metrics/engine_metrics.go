@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Connection metrics emitted by the websocket engine.
+//
+// Implementations must be safe for concurrent use: methods are called by the engine goroutines.
+type Metrics interface {
+	// Called each time a message is read from the server.
+	MessageReceived()
+	// Called each time a message is successfully written to the server.
+	MessageSent()
+	// Called each time the engine has successfully reconnected to the server.
+	Reconnected()
+	// Called each time a ping completes successfully with the round trip duration.
+	ObservePing(duration time.Duration)
+	// Called with true when the engine opens a connection and with false when the connection is
+	// closed.
+	SetConnectionUp(up bool)
+}
+
+// Metrics implementation which exposes the engine metrics to Prometheus:
+//   - wscengine_messages_received_total (counter)
+//   - wscengine_messages_sent_total (counter)
+//   - wscengine_reconnects_total (counter)
+//   - wscengine_ping_duration_seconds (histogram)
+//   - wscengine_connection_up (gauge, 1 when a connection is up, 0 otherwise)
+type PrometheusMetrics struct {
+	// Number of received messages
+	messagesReceived prometheus.Counter
+	// Number of sent messages
+	messagesSent prometheus.Counter
+	// Number of successful reconnects
+	reconnects prometheus.Counter
+	// Ping round trip durations
+	pingDuration prometheus.Histogram
+	// Connection state
+	connectionUp prometheus.Gauge
+}
+
+// # Description
+//
+// Factory which creates a new PrometheusMetrics and registers its collectors.
+//
+// # Inputs
+//
+//   - registerer: Registerer used to register the collectors. If nil,
+//     prometheus.DefaultRegisterer is used.
+//   - constLabels: Optional labels added to all metrics. Use them to distinguish engines when
+//     several engines register their metrics with the same registerer.
+//
+// # Returns
+//
+// The new PrometheusMetrics or an error if a collector could not be registered.
+func NewPrometheusMetrics(registerer prometheus.Registerer, constLabels prometheus.Labels) (*PrometheusMetrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics := &PrometheusMetrics{
+		messagesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "wscengine_messages_received_total",
+			Help:        "Number of messages received from the websocket server.",
+			ConstLabels: constLabels,
+		}),
+		messagesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "wscengine_messages_sent_total",
+			Help:        "Number of messages sent to the websocket server.",
+			ConstLabels: constLabels,
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "wscengine_reconnects_total",
+			Help:        "Number of times the engine has reconnected to the websocket server.",
+			ConstLabels: constLabels,
+		}),
+		pingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "wscengine_ping_duration_seconds",
+			Help:        "Round trip duration of successful pings.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+		connectionUp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "wscengine_connection_up",
+			Help:        "1 when a connection to the websocket server is up, 0 otherwise.",
+			ConstLabels: constLabels,
+		}),
+	}
+	for _, collector := range []prometheus.Collector{
+		metrics.messagesReceived,
+		metrics.messagesSent,
+		metrics.reconnects,
+		metrics.pingDuration,
+		metrics.connectionUp,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register engine metrics: %w", err)
+		}
+	}
+	return metrics, nil
+}
+
+// Increment wscengine_messages_received_total.
+func (metrics *PrometheusMetrics) MessageReceived() {
+	metrics.messagesReceived.Inc()
+}
+
+// Increment wscengine_messages_sent_total.
+func (metrics *PrometheusMetrics) MessageSent() {
+	metrics.messagesSent.Inc()
+}
+
+// Increment wscengine_reconnects_total.
+func (metrics *PrometheusMetrics) Reconnected() {
+	metrics.reconnects.Inc()
+}
+
+// Observe the ping duration in wscengine_ping_duration_seconds.
+func (metrics *PrometheusMetrics) ObservePing(duration time.Duration) {
+	metrics.pingDuration.Observe(duration.Seconds())
+}
+
+// Set wscengine_connection_up to 1 if up is true, 0 otherwise.
+func (metrics *PrometheusMetrics) SetConnectionUp(up bool) {
+	if up {
+		metrics.connectionUp.Set(1)
+	} else {
+		metrics.connectionUp.Set(0)
+	}
+}
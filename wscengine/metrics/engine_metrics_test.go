@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for PrometheusMetrics unit tests
+type PrometheusMetricsUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run PrometheusMetricsUnitTestSuite test suite
+func TestPrometheusMetricsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PrometheusMetricsUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test PrometheusMetrics complies with Metrics
+func (suite *PrometheusMetricsUnitTestSuite) TestInterfaceCompliance() {
+	var metrics interface{} = &PrometheusMetrics{}
+	_, ok := metrics.(Metrics)
+	require.True(suite.T(), ok)
+}
+
+// Test collectors are registered and updated.
+func (suite *PrometheusMetricsUnitTestSuite) TestMetrics() {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewPrometheusMetrics(registry, prometheus.Labels{"engine": "test"})
+	require.NoError(suite.T(), err)
+	metrics.MessageReceived()
+	metrics.MessageReceived()
+	metrics.MessageSent()
+	metrics.Reconnected()
+	metrics.ObservePing(20 * time.Millisecond)
+	metrics.SetConnectionUp(true)
+	require.Equal(suite.T(), 2.0, testutil.ToFloat64(metrics.messagesReceived))
+	require.Equal(suite.T(), 1.0, testutil.ToFloat64(metrics.messagesSent))
+	require.Equal(suite.T(), 1.0, testutil.ToFloat64(metrics.reconnects))
+	require.Equal(suite.T(), 1.0, testutil.ToFloat64(metrics.connectionUp))
+	metrics.SetConnectionUp(false)
+	require.Equal(suite.T(), 0.0, testutil.ToFloat64(metrics.connectionUp))
+	// All metrics are exposed by the registry
+	count, err := testutil.GatherAndCount(registry,
+		"wscengine_messages_received_total",
+		"wscengine_messages_sent_total",
+		"wscengine_reconnects_total",
+		"wscengine_ping_duration_seconds",
+		"wscengine_connection_up")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), 5, count)
+}
+
+// Test registering metrics twice with the same registerer fails.
+func (suite *PrometheusMetricsUnitTestSuite) TestRegisterTwice() {
+	registry := prometheus.NewRegistry()
+	_, err := NewPrometheusMetrics(registry, nil)
+	require.NoError(suite.T(), err)
+	_, err = NewPrometheusMetrics(registry, nil)
+	require.Error(suite.T(), err)
+	// Distinct const label values can be used to register several engines
+	registry = prometheus.NewRegistry()
+	_, err = NewPrometheusMetrics(registry, prometheus.Labels{"engine": "first"})
+	require.NoError(suite.T(), err)
+	_, err = NewPrometheusMetrics(registry, prometheus.Labels{"engine": "second"})
+	require.NoError(suite.T(), err)
+}
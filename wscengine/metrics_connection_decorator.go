@@ -0,0 +1,124 @@
+package wscengine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/metrics"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Decorator used by the engine to report the messages read and written and the ping durations to
+// the configured metrics.
+type metricsConnectionDecorator struct {
+	// Decorated connection adapter
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// Metrics to report to
+	metrics metrics.Metrics
+}
+
+// Simple proxy for Dial method.
+func (adapter *metricsConnectionDecorator) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method.
+func (adapter *metricsConnectionDecorator) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *metricsConnectionDecorator) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Proxy for Ping method which observes the duration of successful pings.
+func (adapter *metricsConnectionDecorator) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := adapter.decorated.Ping(ctx)
+	if err == nil {
+		adapter.metrics.ObservePing(time.Since(start))
+	}
+	return err
+}
+
+// Proxy for Read method which counts received messages.
+func (adapter *metricsConnectionDecorator) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	msgType, msg, err := adapter.decorated.Read(ctx)
+	if err == nil {
+		adapter.metrics.MessageReceived()
+	}
+	return msgType, msg, err
+}
+
+// Proxy for ReadStream method which counts received messages.
+func (adapter *metricsConnectionDecorator) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, reader, err := adapter.decorated.ReadStream(ctx)
+	if err == nil {
+		adapter.metrics.MessageReceived()
+	}
+	return msgType, reader, err
+}
+
+// Proxy for Write method which counts sent messages.
+func (adapter *metricsConnectionDecorator) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	err := adapter.decorated.Write(ctx, msgType, msg)
+	if err == nil {
+		adapter.metrics.MessageSent()
+	}
+	return err
+}
+
+// Proxy for WritePropagated method which counts the messages sent.
+func (adapter *metricsConnectionDecorator) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	err := adapter.decorated.WritePropagated(ctx, msgType, msg, propagator)
+	if err == nil {
+		adapter.metrics.MessageSent()
+	}
+	return err
+}
+
+// Proxy for WriteStream method which counts messages sent once the returned writer has been
+// successfully closed.
+func (adapter *metricsConnectionDecorator) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	writer, err := adapter.decorated.WriteStream(ctx, msgType)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsMessageWriter{WriteCloser: writer, metrics: adapter.metrics}, nil
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *metricsConnectionDecorator) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *metricsConnectionDecorator) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *metricsConnectionDecorator) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+// Message writer which counts the message sent once it has been successfully closed.
+type metricsMessageWriter struct {
+	io.WriteCloser
+	// Metrics to report to
+	metrics metrics.Metrics
+}
+
+// Close the decorated writer and count the message sent.
+func (writer *metricsMessageWriter) Close() error {
+	err := writer.WriteCloser.Close()
+	if err == nil {
+		writer.metrics.MessageSent()
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+// The package contains middlewares and a connection decorator which transparently encrypt and
+// decrypt messages with age (https://age-encryption.org).
+//
+// Encrypted messages use the age binary format and are sent/handed over as Binary messages.
+// Messages can be encrypted to several recipients (X25519 keys, SSH keys, ...) or with a
+// passphrase (age.NewScryptRecipient and age.NewScryptIdentity). A passphrase recipient must be
+// the only recipient of a message.
+package age
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"filippo.io/age"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Error returned when none of the provided identities can decrypt a message.
+var ErrDecryptionIdentityNotFound = errors.New("no identity can decrypt the message")
+
+// # Description
+//
+// Encrypt the message to all the provided recipients using the age binary format.
+//
+// # Returns
+//
+// The encrypted message or an error if no recipient is provided or if encryption failed (for
+// example when a passphrase recipient is mixed with other recipients).
+func Encrypt(msg []byte, recipients ...age.Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipient provided")
+	}
+	encrypted := &bytes.Buffer{}
+	w, err := age.Encrypt(encrypted, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	_, err = w.Write(msg)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	return encrypted.Bytes(), nil
+}
+
+// # Description
+//
+// Decrypt a message encrypted with the age binary format using the first provided identity which
+// matches one of the message recipients.
+//
+// # Returns
+//
+// The decrypted message or an error. ErrDecryptionIdentityNotFound is returned when none of the
+// provided identities can decrypt the message.
+func Decrypt(msg []byte, identities ...age.Identity) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, ErrDecryptionIdentityNotFound
+	}
+	r, err := age.Decrypt(bytes.NewReader(msg), identities...)
+	if err != nil {
+		noMatch := new(age.NoIdentityMatchError)
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("%w: %w", ErrDecryptionIdentityNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to decrypt message: %w", err)
+	}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message: %w", err)
+	}
+	return decrypted, nil
+}
+
+// # Description
+//
+// Create a middleware which encrypts received messages to the provided recipients (for example,
+// before they are archived by the next middlewares) and hands them over as Binary messages.
+//
+// Messages which cannot be encrypted are dropped and the failure is logged with the default
+// logger.
+func AgeEncryptionMiddleware(recipients []age.Recipient) middleware.MessageMiddleware {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		encrypted, err := Encrypt(msg, recipients...)
+		if err != nil {
+			log.Default().Printf("dropped message: %s", err)
+			return
+		}
+		next(ctx, wsadapters.Binary, encrypted)
+	}
+}
+
+// # Description
+//
+// Create a middleware which decrypts received messages with the provided identities and hands
+// them over as Binary messages as the original message type is not part of the encrypted message.
+//
+// Messages which cannot be decrypted (ErrDecryptionIdentityNotFound, corrupted message, ...) are
+// dropped and the failure is logged with the default logger.
+func AgeDecryptionMiddleware(identities []age.Identity) middleware.MessageMiddleware {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		decrypted, err := Decrypt(msg, identities...)
+		if err != nil {
+			log.Default().Printf("dropped message: %s", err)
+			return
+		}
+		next(ctx, wsadapters.Binary, decrypted)
+	}
+}
+
+// # Description
+//
+// Decorate the provided connection adapter so messages sent with Write are encrypted to the
+// provided recipients and sent as Binary messages.
+func WrapConnection(conn wsadapters.WebsocketConnectionAdapterInterface, recipients []age.Recipient) wsadapters.WebsocketConnectionAdapterInterface {
+	return &encryptingConnectionAdapter{
+		WebsocketConnectionAdapterInterface: conn,
+		recipients:                          recipients,
+	}
+}
+
+// Connection adapter decorator which encrypts sent messages.
+type encryptingConnectionAdapter struct {
+	wsadapters.WebsocketConnectionAdapterInterface
+	// Recipients messages are encrypted to
+	recipients []age.Recipient
+}
+
+// Encrypt the message and forward it to the decorated connection adapter as a Binary message.
+func (adapter *encryptingConnectionAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	encrypted, err := Encrypt(msg, adapter.recipients...)
+	if err != nil {
+		return err
+	}
+	return adapter.WebsocketConnectionAdapterInterface.Write(ctx, wsadapters.Binary, encrypted)
+}
@@ -0,0 +1,128 @@
+package age
+
+import (
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for age middlewares unit tests
+type AgeMiddlewareUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run AgeMiddlewareUnitTestSuite test suite
+func TestAgeMiddlewareUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AgeMiddlewareUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test a message encrypted to several recipients can be decrypted by each of them only.
+func (suite *AgeMiddlewareUnitTestSuite) TestMultiRecipientEncryption() {
+	alice, bob, eve := newIdentity(suite.T()), newIdentity(suite.T()), newIdentity(suite.T())
+	encrypted, err := Encrypt([]byte("hello"), alice.Recipient(), bob.Recipient())
+	require.NoError(suite.T(), err)
+	for _, identity := range []age.Identity{alice, bob} {
+		decrypted, err := Decrypt(encrypted, eve, identity)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), []byte("hello"), decrypted)
+	}
+	_, err = Decrypt(encrypted, eve)
+	require.ErrorIs(suite.T(), err, ErrDecryptionIdentityNotFound)
+	_, err = Decrypt(encrypted)
+	require.ErrorIs(suite.T(), err, ErrDecryptionIdentityNotFound)
+	// Corrupted messages and missing recipients
+	_, err = Decrypt([]byte("not an age message"), alice)
+	require.Error(suite.T(), err)
+	require.NotErrorIs(suite.T(), err, ErrDecryptionIdentityNotFound)
+	_, err = Encrypt([]byte("hello"))
+	require.Error(suite.T(), err)
+}
+
+// Test passphrase based encryption.
+func (suite *AgeMiddlewareUnitTestSuite) TestPassphraseEncryption() {
+	recipient, err := age.NewScryptRecipient("secret")
+	require.NoError(suite.T(), err)
+	// Use a low work factor to keep tests fast
+	recipient.SetWorkFactor(10)
+	encrypted, err := Encrypt([]byte("hello"), recipient)
+	require.NoError(suite.T(), err)
+	identity, err := age.NewScryptIdentity("secret")
+	require.NoError(suite.T(), err)
+	decrypted, err := Decrypt(encrypted, identity)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("hello"), decrypted)
+	wrong, err := age.NewScryptIdentity("wrong")
+	require.NoError(suite.T(), err)
+	_, err = Decrypt(encrypted, wrong)
+	require.ErrorIs(suite.T(), err, ErrDecryptionIdentityNotFound)
+	// Passphrase recipient must be the only recipient
+	_, err = Encrypt([]byte("hello"), recipient, newIdentity(suite.T()).Recipient())
+	require.Error(suite.T(), err)
+}
+
+// Test messages are encrypted and decrypted by the middlewares and dropped on failure.
+func (suite *AgeMiddlewareUnitTestSuite) TestMiddlewares() {
+	alice, eve := newIdentity(suite.T()), newIdentity(suite.T())
+	received := [][]byte{}
+	handler := func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		require.Equal(suite.T(), wsadapters.Binary, msgType)
+		received = append(received, msg)
+	}
+	// Encrypt then decrypt
+	chain := middleware.Chain(handler,
+		AgeEncryptionMiddleware([]age.Recipient{alice.Recipient()}),
+		AgeDecryptionMiddleware([]age.Identity{alice}))
+	chain(context.Background(), wsadapters.Text, []byte("hello"))
+	require.Equal(suite.T(), [][]byte{[]byte("hello")}, received)
+	// Messages which cannot be decrypted are dropped
+	chain = middleware.Chain(handler,
+		AgeEncryptionMiddleware([]age.Recipient{alice.Recipient()}),
+		AgeDecryptionMiddleware([]age.Identity{eve}))
+	chain(context.Background(), wsadapters.Text, []byte("hello"))
+	require.Len(suite.T(), received, 1)
+	// Messages which cannot be encrypted are dropped
+	chain = middleware.Chain(handler, AgeEncryptionMiddleware(nil))
+	chain(context.Background(), wsadapters.Text, []byte("hello"))
+	require.Len(suite.T(), received, 1)
+}
+
+// Test sent messages are encrypted by the connection decorator.
+func (suite *AgeMiddlewareUnitTestSuite) TestWrapConnection() {
+	alice := newIdentity(suite.T())
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Binary, mock.Anything).Return(nil)
+	conn := WrapConnection(connMock, []age.Recipient{alice.Recipient()})
+	require.NoError(suite.T(), conn.Write(context.Background(), wsadapters.Text, []byte("hello")))
+	encrypted := connMock.Calls[0].Arguments.Get(2).([]byte)
+	decrypted, err := Decrypt(encrypted, alice)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("hello"), decrypted)
+	// Encryption failure is returned
+	require.Error(suite.T(), WrapConnection(connMock, nil).Write(context.Background(), wsadapters.Text, []byte("hello")))
+	connMock.AssertNumberOfCalls(suite.T(), "Write", 1)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Generate a new X25519 identity
+func newIdentity(t *testing.T) *age.X25519Identity {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	return identity
+}
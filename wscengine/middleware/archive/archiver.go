@@ -0,0 +1,304 @@
+// The package contains a middleware which archives received messages in an object store such as
+// a S3-compatible object store or the local filesystem.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+const (
+	// Content type of archive objects
+	contentType = "application/x-ndjson"
+	// Default buffer size which triggers a flush
+	defaultFlushSize = 1024 * 1024
+	// Default interval between two flushes
+	defaultFlushInterval = time.Minute
+	// Default max buffer size expressed as a multiple of FlushSize
+	defaultMaxBufferFactor = 4
+)
+
+// Counter of all messages dropped by archivers, either because their buffer was full or because
+// a flush failed.
+var DroppedByArchiver atomic.Uint64
+
+// Archiver options.
+type ArchiveOptions struct {
+	// Buffered size in bytes which triggers a flush. Defaults to 1 MiB.
+	FlushSize int
+	// Max. duration between two flushes. Defaults to 1 minute.
+	FlushInterval time.Duration
+	// Max. size in bytes of buffered messages waiting for a flush. Messages which would make the
+	// buffer exceed this size are dropped. Defaults to 4 times FlushSize.
+	MaxBufferSize int
+	// Logger used to report failed flushes. If nil, default logger will be used.
+	Logger *log.Logger
+}
+
+// A record written in archive objects, one per line.
+type archiveRecord struct {
+	// Time when the message has been received
+	Timestamp time.Time `json:"timestamp"`
+	// Engine session ID
+	SessionId string `json:"sessionId"`
+	// Message type: text or binary
+	Type string `json:"type"`
+	// Text message
+	Text string `json:"text,omitempty"`
+	// Binary message - base64 encoded
+	Binary []byte `json:"binary,omitempty"`
+}
+
+// Archiver which buffers received messages in memory and flushes them as newline delimited JSON
+// objects to an ObjectWriter.
+type Archiver struct {
+	// Store objects are written to
+	writer ObjectWriter
+	// Prefix prepended to each object key
+	keyPrefix string
+	// Options with defaults applied
+	opts ArchiveOptions
+	// Mutex which protects buffers and pending
+	mu sync.Mutex
+	// Buffered records by session ID
+	buffers map[string][]byte
+	// Total size of buffers and of the flush in progress if any
+	pending int
+	// Count of messages dropped by this archiver
+	dropped atomic.Uint64
+	// Channel used to request an early flush
+	flushSignal chan struct{}
+	// Channel closed to stop the flush goroutine
+	stop chan struct{}
+	// Channel closed when the flush goroutine has exited
+	done chan struct{}
+	// Used to ensure Close is performed once
+	closeOnce sync.Once
+}
+
+// # Description
+//
+// Factory which creates a new Archiver and starts its flush goroutine.
+//
+// # Inputs
+//
+//   - writer: Store objects are written to. Must not be nil.
+//   - keyPrefix: Prefix prepended to each object key.
+//   - opts: Archiver options. Zero values are replaced by defaults.
+//
+// # Returns
+//
+// A new Archiver or an error if writer is nil.
+func NewArchiver(writer ObjectWriter, keyPrefix string, opts ArchiveOptions) (*Archiver, error) {
+	if writer == nil {
+		return nil, fmt.Errorf("provided writer is nil")
+	}
+	// Apply defaults
+	if opts.FlushSize <= 0 {
+		opts.FlushSize = defaultFlushSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.MaxBufferSize <= 0 {
+		opts.MaxBufferSize = defaultMaxBufferFactor * opts.FlushSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	archiver := &Archiver{
+		writer:      writer,
+		keyPrefix:   keyPrefix,
+		opts:        opts,
+		buffers:     map[string][]byte{},
+		flushSignal: make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go archiver.run()
+	return archiver, nil
+}
+
+// # Description
+//
+// Create a middleware which archives received messages in the provided S3 bucket. Object keys are
+// built as follow: <keyPrefix><UTC timestamp>-<session ID>.ndjson
+//
+// The underlying archiver lives as long as the process. Use NewArchiver and NewS3ObjectWriter to
+// be able to stop it and flush remaining messages.
+//
+// # Returns
+//
+// The middleware or an error if the archiver could not be created.
+func S3ArchivingMiddleware(client s3iface.S3API, bucket, keyPrefix string, opts ArchiveOptions) (middleware.MessageMiddleware, error) {
+	writer, err := NewS3ObjectWriter(client, bucket)
+	if err != nil {
+		return nil, err
+	}
+	archiver, err := NewArchiver(writer, keyPrefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	return archiver.Middleware, nil
+}
+
+// # Description
+//
+// Create a middleware which archives received messages as files in the provided directory. Use it
+// to test archiving without a S3-compatible object store.
+//
+// # Returns
+//
+// The middleware or an error if the archiver could not be created.
+func FileSystemArchivingMiddleware(dir, keyPrefix string, opts ArchiveOptions) (middleware.MessageMiddleware, error) {
+	writer, err := NewFileSystemObjectWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+	archiver, err := NewArchiver(writer, keyPrefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	return archiver.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which archives the received message and hands it over to the next handler. Archiving
+// never blocks the message: it is dropped from the archive if the buffer is full.
+func (archiver *Archiver) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	archiver.Archive(middleware.SessionIdFromContext(ctx), msgType, msg)
+	next(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Buffer a message for archiving.
+//
+// # Returns
+//
+// True if the message has been buffered, false if it has been dropped.
+func (archiver *Archiver) Archive(sessionId string, msgType wsadapters.MessageType, msg []byte) bool {
+	// Build record
+	record := archiveRecord{
+		Timestamp: time.Now().UTC(),
+		SessionId: sessionId,
+	}
+	if msgType == wsadapters.Binary {
+		record.Type = "binary"
+		record.Binary = msg
+	} else {
+		record.Type = "text"
+		record.Text = string(msg)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		archiver.drop(1)
+		return false
+	}
+	line = append(line, '\n')
+	// Buffer record
+	archiver.mu.Lock()
+	if archiver.pending+len(line) > archiver.opts.MaxBufferSize {
+		archiver.mu.Unlock()
+		archiver.drop(1)
+		return false
+	}
+	archiver.buffers[sessionId] = append(archiver.buffers[sessionId], line...)
+	archiver.pending += len(line)
+	full := archiver.pending >= archiver.opts.FlushSize
+	archiver.mu.Unlock()
+	if full {
+		// Request an early flush - a flush is already requested if channel is full
+		select {
+		case archiver.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// # Description
+//
+// Return the count of messages dropped by this archiver.
+func (archiver *Archiver) Dropped() uint64 {
+	return archiver.dropped.Load()
+}
+
+// # Description
+//
+// Stop the flush goroutine and flush remaining messages.
+func (archiver *Archiver) Close() error {
+	archiver.closeOnce.Do(func() {
+		close(archiver.stop)
+		<-archiver.done
+	})
+	return nil
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Flush buffers periodically and on demand until the archiver is closed.
+func (archiver *Archiver) run() {
+	defer close(archiver.done)
+	ticker := time.NewTicker(archiver.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-archiver.stop:
+			archiver.flush()
+			return
+		case <-ticker.C:
+			archiver.flush()
+		case <-archiver.flushSignal:
+			archiver.flush()
+		}
+	}
+}
+
+// Write one object per session with buffered records.
+func (archiver *Archiver) flush() {
+	// Swap buffers - pending is only decreased once the flush is over so the buffer limit takes
+	// the flush in progress into account.
+	archiver.mu.Lock()
+	buffers := archiver.buffers
+	archiver.buffers = map[string][]byte{}
+	archiver.mu.Unlock()
+	flushed := 0
+	now := time.Now().UTC()
+	for sessionId, content := range buffers {
+		key := fmt.Sprintf("%s%s-%s.ndjson", archiver.keyPrefix, now.Format("20060102T150405.000000000Z"), sessionId)
+		err := archiver.writer.PutObject(context.Background(), key, content)
+		if err != nil {
+			// Records are lost: count them as dropped
+			archiver.drop(bytes.Count(content, []byte{'\n'}))
+			archiver.opts.Logger.Println("failed to flush archived messages:", err)
+		}
+		flushed += len(content)
+	}
+	archiver.mu.Lock()
+	archiver.pending -= flushed
+	archiver.mu.Unlock()
+}
+
+// Increment drop counters.
+func (archiver *Archiver) drop(count int) {
+	archiver.dropped.Add(uint64(count))
+	DroppedByArchiver.Add(uint64(count))
+}
@@ -0,0 +1,168 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for Archiver unit tests
+type ArchiverUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ArchiverUnitTestSuite test suite
+func TestArchiverUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ArchiverUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test messages are archived in local files when FlushSize is reached.
+func (suite *ArchiverUnitTestSuite) TestFileSystemArchiving() {
+	dir := suite.T().TempDir()
+	mw, err := FileSystemArchivingMiddleware(dir, "archive/", ArchiveOptions{FlushSize: 1, MaxBufferSize: 1024, FlushInterval: time.Hour})
+	require.NoError(suite.T(), err)
+	// Run a message through the middleware
+	passed := false
+	ctx := middleware.ContextWithSessionId(context.Background(), "session")
+	mw(ctx, wsadapters.Text, []byte(`{"hello":"world"}`), func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		passed = true
+	})
+	require.True(suite.T(), passed)
+	// Wait for the archive file
+	var files []string
+	require.Eventually(suite.T(), func() bool {
+		files, _ = filepath.Glob(filepath.Join(dir, "archive", "*-session.ndjson"))
+		return len(files) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	content, err := os.ReadFile(files[0])
+	require.NoError(suite.T(), err)
+	record := archiveRecord{}
+	require.NoError(suite.T(), json.Unmarshal(bytes.TrimSpace(content), &record))
+	require.Equal(suite.T(), "session", record.SessionId)
+	require.Equal(suite.T(), "text", record.Type)
+	require.Equal(suite.T(), `{"hello":"world"}`, record.Text)
+}
+
+// Test messages are flushed to S3 when the archiver is closed.
+func (suite *ArchiverUnitTestSuite) TestS3ArchivingOnClose() {
+	client := &s3ClientStub{}
+	writer, err := NewS3ObjectWriter(client, "bucket")
+	require.NoError(suite.T(), err)
+	archiver, err := NewArchiver(writer, "prefix/", ArchiveOptions{FlushInterval: time.Hour})
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), archiver.Archive("a", wsadapters.Text, []byte("1")))
+	require.True(suite.T(), archiver.Archive("a", wsadapters.Binary, []byte{0x01}))
+	require.True(suite.T(), archiver.Archive("b", wsadapters.Text, []byte("2")))
+	require.NoError(suite.T(), archiver.Close())
+	// One object per session
+	require.Len(suite.T(), client.objects, 2)
+	for key, content := range client.objects {
+		require.Equal(suite.T(), "bucket", key.bucket)
+		require.Regexp(suite.T(), `^prefix/\d{8}T\d{6}\.\d{9}Z-(a|b)\.ndjson$`, key.key)
+		lines := 0
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			lines++
+		}
+		if key.key[len(key.key)-8:] == "a.ndjson" {
+			require.Equal(suite.T(), 2, lines)
+		} else {
+			require.Equal(suite.T(), 1, lines)
+		}
+	}
+}
+
+// Test messages are dropped and counted when the buffer is full or when a flush fails.
+func (suite *ArchiverUnitTestSuite) TestDropped() {
+	client := &s3ClientStub{err: fmt.Errorf("unavailable")}
+	writer, err := NewS3ObjectWriter(client, "bucket")
+	require.NoError(suite.T(), err)
+	archiver, err := NewArchiver(writer, "", ArchiveOptions{
+		FlushSize:     1024,
+		MaxBufferSize: 128,
+		FlushInterval: time.Hour,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	require.NoError(suite.T(), err)
+	before := DroppedByArchiver.Load()
+	require.True(suite.T(), archiver.Archive("s", wsadapters.Text, []byte("small")))
+	require.False(suite.T(), archiver.Archive("s", wsadapters.Text, bytes.Repeat([]byte("x"), 256)))
+	require.Equal(suite.T(), uint64(1), archiver.Dropped())
+	// Failed flush on close drops buffered message
+	require.NoError(suite.T(), archiver.Close())
+	require.Equal(suite.T(), uint64(2), archiver.Dropped())
+	require.Equal(suite.T(), before+2, DroppedByArchiver.Load())
+}
+
+// Test factories with invalid inputs.
+func (suite *ArchiverUnitTestSuite) TestFactoriesWithInvalidInputs() {
+	_, err := NewArchiver(nil, "", ArchiveOptions{})
+	require.Error(suite.T(), err)
+	_, err = NewS3ObjectWriter(nil, "bucket")
+	require.Error(suite.T(), err)
+	_, err = S3ArchivingMiddleware(&s3ClientStub{}, "", "", ArchiveOptions{})
+	require.Error(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Key of an object stored by s3ClientStub
+type s3ObjectKey struct {
+	bucket string
+	key    string
+}
+
+// S3 client stub which stores objects in memory. Calling other methods than PutObjectWithContext
+// panics.
+type s3ClientStub struct {
+	s3iface.S3API
+	mu      sync.Mutex
+	objects map[s3ObjectKey][]byte
+	err     error
+}
+
+// Store object in memory or return configured error.
+func (stub *s3ClientStub) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	content, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.objects == nil {
+		stub.objects = map[s3ObjectKey][]byte{}
+	}
+	stub.objects[s3ObjectKey{bucket: aws.StringValue(input.Bucket), key: aws.StringValue(input.Key)}] = content
+	return &s3.PutObjectOutput{}, nil
+}
@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Interface for stores archived messages are written to.
+type ObjectWriter interface {
+	// # Description
+	//
+	// Write an object which contains archived messages.
+	//
+	// # Inputs
+	//
+	//   - ctx: Context used for the write.
+	//   - key: Key of the object to write.
+	//   - content: Object content: newline delimited JSON archive records.
+	//
+	// # Returns
+	//
+	// Nil in case of success or an error if the object could not be written.
+	PutObject(ctx context.Context, key string, content []byte) error
+}
+
+/*************************************************************************************************/
+/* S3                                                                                            */
+/*************************************************************************************************/
+
+// ObjectWriter implementation which writes objects to a S3-compatible object store.
+type S3ObjectWriter struct {
+	// S3 client
+	client s3iface.S3API
+	// Bucket objects are written to
+	bucket string
+}
+
+// # Description
+//
+// Factory which creates a new ObjectWriter that writes objects to the provided bucket.
+//
+// # Inputs
+//
+//   - client: S3 client to use. Must not be nil.
+//   - bucket: Bucket objects are written to. Must not be empty.
+//
+// # Returns
+//
+// A new S3ObjectWriter or an error if client is nil or bucket is empty.
+func NewS3ObjectWriter(client s3iface.S3API, bucket string) (*S3ObjectWriter, error) {
+	if client == nil {
+		return nil, fmt.Errorf("provided s3 client is nil")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("provided bucket is empty")
+	}
+	return &S3ObjectWriter{
+		client: client,
+		bucket: bucket,
+	}, nil
+}
+
+// Write the object to the bucket.
+func (writer *S3ObjectWriter) PutObject(ctx context.Context, key string, content []byte) error {
+	_, err := writer.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(writer.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put archive object %s in bucket %s: %w", key, writer.bucket, err)
+	}
+	return nil
+}
+
+/*************************************************************************************************/
+/* LOCAL FILESYSTEM                                                                              */
+/*************************************************************************************************/
+
+// ObjectWriter implementation which writes objects as files in a local directory. Object keys are
+// used as paths relative to the directory. Mainly intended for tests and local development.
+type FileSystemObjectWriter struct {
+	// Root directory
+	dir string
+}
+
+// # Description
+//
+// Factory which creates a new ObjectWriter that writes objects as files in the provided directory.
+// The directory is created if it does not exist.
+//
+// # Returns
+//
+// A new FileSystemObjectWriter or an error if the directory could not be created.
+func NewFileSystemObjectWriter(dir string) (*FileSystemObjectWriter, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &FileSystemObjectWriter{dir: dir}, nil
+}
+
+// Write the object as a file in the directory.
+func (writer *FileSystemObjectWriter) PutObject(ctx context.Context, key string, content []byte) error {
+	path := filepath.Join(writer.dir, filepath.FromSlash(key))
+	err := os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	err = os.WriteFile(path, content, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write archive file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+// The package contains a middleware which records an audit trail of all messages in a SQL
+// database.
+//
+// Records are inserted in the provided table which must have the following columns:
+//
+//	session_id   VARCHAR   -- Engine session ID
+//	timestamp    TIMESTAMP -- Time when the message has been received or sent (UTC)
+//	direction    VARCHAR   -- inbound or outbound
+//	message_type VARCHAR   -- text or binary
+//	payload_hash VARCHAR   -- Hex encoded SHA-256 hash of the message
+//	payload      BLOB      -- Full message if FullPayload is enabled, NULL otherwise
+//	tags         VARCHAR   -- JSON object which contains the metadata tags
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+const (
+	// Default number of records inserted with a single statement
+	defaultBatchSize = 100
+	// Default max. duration between two flushes
+	defaultFlushInterval = time.Second
+	// Direction of messages received from the server
+	DirectionInbound = "inbound"
+	// Direction of messages sent to the server
+	DirectionOutbound = "outbound"
+)
+
+// Columns of the audit table, in insertion order
+var columns = []string{"session_id", "timestamp", "direction", "message_type", "payload_hash", "payload", "tags"}
+
+// Regex used to validate table names (optionally schema qualified) as they are used in statements
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Audit options.
+type AuditOptions struct {
+	// If true, the full message is stored in the payload column. Defaults to false: only the
+	// message hash is stored.
+	FullPayload bool
+	// Number of records which triggers a flush. Records are inserted with a single statement per
+	// batch. Defaults to 100.
+	BatchSize int
+	// Max. duration between two flushes. Defaults to 1 second.
+	FlushInterval time.Duration
+	// Metadata tags stored with each record (like the application name or the environment).
+	Tags map[string]string
+	// Function which returns the placeholder of the statement parameter at the provided index
+	// (starting at 1). Defaults to QuestionPlaceholder (MySQL, SQLite). Use DollarPlaceholder for
+	// PostgreSQL.
+	Placeholder func(index int) string
+	// Logger used to report failed flushes. If nil, default logger will be used.
+	Logger *log.Logger
+}
+
+// # Description
+//
+// Set opts.FullPayload and return the modified object.
+//
+// # FullPayload
+//
+// This option defines whether the full message is stored in the audit trail in addition to its
+// hash.
+//
+// Defaults to false (= only the message hash is stored).
+//
+// # Return
+//
+// The modified options.
+func (opts *AuditOptions) WithFullPayload(value bool) *AuditOptions {
+	// Set and return
+	opts.FullPayload = value
+	return opts
+}
+
+// # Description
+//
+// Placeholder used by MySQL and SQLite drivers: ?
+func QuestionPlaceholder(index int) string {
+	return "?"
+}
+
+// # Description
+//
+// Placeholder used by PostgreSQL drivers: $1, $2, ...
+func DollarPlaceholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+// A record of the audit trail.
+type auditRecord struct {
+	// Engine session ID
+	sessionId string
+	// Time when the message has been received or sent
+	timestamp time.Time
+	// Message direction: inbound or outbound
+	direction string
+	// Message type: text or binary
+	messageType string
+	// Hex encoded SHA-256 hash of the message
+	payloadHash string
+	// Full message - nil if FullPayload is disabled
+	payload []byte
+}
+
+// Audit logger which buffers message records in memory and inserts them in batches in a SQL
+// database.
+type AuditLogger struct {
+	// Database records are inserted in
+	db *sql.DB
+	// Name of the audit table
+	tableName string
+	// Options with defaults applied
+	opts AuditOptions
+	// JSON encoded metadata tags
+	tags string
+	// Mutex which protects buffer
+	mu sync.Mutex
+	// Buffered records
+	buffer []auditRecord
+	// Prepared statements by number of inserted records - only used by the flush goroutine
+	stmts map[int]*sql.Stmt
+	// Channel used to request an early flush
+	flushSignal chan struct{}
+	// Channel closed to stop the flush goroutine
+	stop chan struct{}
+	// Channel closed when the flush goroutine has exited
+	done chan struct{}
+	// Used to ensure Close is performed once
+	closeOnce sync.Once
+}
+
+// # Description
+//
+// Factory which creates a new AuditLogger and starts its flush goroutine.
+//
+// # Inputs
+//
+//   - db: Database records are inserted in. Must not be nil.
+//   - tableName: Name of the audit table, optionally schema qualified (schema.table).
+//   - opts: Audit options. Zero values are replaced by defaults.
+//
+// # Returns
+//
+// A new AuditLogger or an error if db is nil, if the table name is invalid or if the tags cannot
+// be encoded.
+func NewAuditLogger(db *sql.DB, tableName string, opts AuditOptions) (*AuditLogger, error) {
+	if db == nil {
+		return nil, fmt.Errorf("provided db is nil")
+	}
+	if !tableNameRegex.MatchString(tableName) {
+		return nil, fmt.Errorf("invalid table name: %q", tableName)
+	}
+	// Apply defaults
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.Placeholder == nil {
+		opts.Placeholder = QuestionPlaceholder
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	tags := "{}"
+	if len(opts.Tags) > 0 {
+		encoded, err := json.Marshal(opts.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode tags: %w", err)
+		}
+		tags = string(encoded)
+	}
+	logger := &AuditLogger{
+		db:          db,
+		tableName:   tableName,
+		opts:        opts,
+		tags:        tags,
+		buffer:      make([]auditRecord, 0, opts.BatchSize),
+		stmts:       map[int]*sql.Stmt{},
+		flushSignal: make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go logger.run()
+	return logger, nil
+}
+
+// # Description
+//
+// Create a middleware which records received messages in the provided database table.
+//
+// The underlying audit logger lives as long as the process: records buffered when the process
+// exits are lost. Callers who need durability must use NewAuditLogger and call
+// AuditLogger.Close before exit so remaining records are flushed. NewAuditLogger is also
+// required to record sent messages (see AuditLogger.WrapConnection).
+//
+// # Returns
+//
+// The middleware or an error if db is nil, if the table name is invalid or if the tags cannot be
+// encoded.
+func DatabaseAuditMiddleware(db *sql.DB, tableName string, opts AuditOptions) (middleware.MessageMiddleware, error) {
+	logger, err := NewAuditLogger(db, tableName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return logger.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which records the received message and hands it over to the next handler.
+func (logger *AuditLogger) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	logger.Record(middleware.SessionIdFromContext(ctx), DirectionInbound, msgType, msg)
+	next(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Buffer a message record. A flush is requested once BatchSize records are buffered.
+//
+// # Inputs
+//
+//   - sessionId: Engine session ID.
+//   - direction: DirectionInbound or DirectionOutbound.
+//   - msgType: Message type.
+//   - msg: Message.
+func (logger *AuditLogger) Record(sessionId string, direction string, msgType wsadapters.MessageType, msg []byte) {
+	// Build record
+	hash := sha256.Sum256(msg)
+	record := auditRecord{
+		sessionId:   sessionId,
+		timestamp:   time.Now().UTC(),
+		direction:   direction,
+		messageType: "text",
+		payloadHash: hex.EncodeToString(hash[:]),
+	}
+	if msgType == wsadapters.Binary {
+		record.messageType = "binary"
+	}
+	if logger.opts.FullPayload {
+		record.payload = append([]byte(nil), msg...)
+	}
+	// Buffer record
+	logger.mu.Lock()
+	logger.buffer = append(logger.buffer, record)
+	full := len(logger.buffer) >= logger.opts.BatchSize
+	logger.mu.Unlock()
+	if full {
+		// Request an early flush - a flush is already requested if channel is full
+		select {
+		case logger.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// # Description
+//
+// Decorate the provided connection adapter so messages sent with Write are recorded as outbound
+// messages. The session ID is extracted from the context provided to Write.
+func (logger *AuditLogger) WrapConnection(conn wsadapters.WebsocketConnectionAdapterInterface) wsadapters.WebsocketConnectionAdapterInterface {
+	return &auditConnectionAdapter{
+		WebsocketConnectionAdapterInterface: conn,
+		logger:                              logger,
+	}
+}
+
+// # Description
+//
+// Stop the flush goroutine, flush remaining records and release prepared statements.
+func (logger *AuditLogger) Close() error {
+	logger.closeOnce.Do(func() {
+		close(logger.stop)
+		<-logger.done
+	})
+	return nil
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Connection adapter decorator which records sent messages.
+type auditConnectionAdapter struct {
+	wsadapters.WebsocketConnectionAdapterInterface
+	// Audit logger used to record sent messages
+	logger *AuditLogger
+}
+
+// Record the message and forward it to the decorated connection adapter.
+func (adapter *auditConnectionAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	err := adapter.WebsocketConnectionAdapterInterface.Write(ctx, msgType, msg)
+	if err == nil {
+		adapter.logger.Record(middleware.SessionIdFromContext(ctx), DirectionOutbound, msgType, msg)
+	}
+	return err
+}
+
+// Flush buffered records periodically and on demand until the audit logger is closed.
+func (logger *AuditLogger) run() {
+	defer close(logger.done)
+	ticker := time.NewTicker(logger.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-logger.stop:
+			logger.flush()
+			for _, stmt := range logger.stmts {
+				stmt.Close()
+			}
+			return
+		case <-ticker.C:
+			logger.flush()
+		case <-logger.flushSignal:
+			logger.flush()
+		}
+	}
+}
+
+// Insert buffered records in batches of at most BatchSize records.
+func (logger *AuditLogger) flush() {
+	logger.mu.Lock()
+	records := logger.buffer
+	logger.buffer = make([]auditRecord, 0, logger.opts.BatchSize)
+	logger.mu.Unlock()
+	for len(records) > 0 {
+		count := min(len(records), logger.opts.BatchSize)
+		err := logger.insert(records[:count])
+		if err != nil {
+			logger.opts.Logger.Printf("failed to insert %d audit records: %s", count, err)
+		}
+		records = records[count:]
+	}
+}
+
+// Insert the records with a single prepared statement.
+func (logger *AuditLogger) insert(records []auditRecord) error {
+	stmt, err := logger.prepare(len(records))
+	if err != nil {
+		return err
+	}
+	args := make([]any, 0, len(records)*len(columns))
+	for _, record := range records {
+		var payload any
+		if record.payload != nil {
+			payload = record.payload
+		}
+		args = append(args,
+			record.sessionId,
+			record.timestamp,
+			record.direction,
+			record.messageType,
+			record.payloadHash,
+			payload,
+			logger.tags)
+	}
+	_, err = stmt.Exec(args...)
+	return err
+}
+
+// Return the prepared statement which inserts the provided number of records. Statements are
+// prepared once and reused.
+func (logger *AuditLogger) prepare(count int) (*sql.Stmt, error) {
+	if stmt, ok := logger.stmts[count]; ok {
+		return stmt, nil
+	}
+	rows := make([]string, 0, count)
+	index := 1
+	for i := 0; i < count; i++ {
+		placeholders := make([]string, 0, len(columns))
+		for range columns {
+			placeholders = append(placeholders, logger.opts.Placeholder(index))
+			index++
+		}
+		rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		logger.tableName, strings.Join(columns, ", "), strings.Join(rows, ", "))
+	stmt, err := logger.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare audit insert statement: %w", err)
+	}
+	logger.stmts[count] = stmt
+	return stmt, nil
+}
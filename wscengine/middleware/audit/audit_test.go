@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for AuditLogger unit tests
+type AuditLoggerUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run AuditLoggerUnitTestSuite test suite
+func TestAuditLoggerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditLoggerUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test inbound and outbound messages are inserted in batches with prepared statements.
+func (suite *AuditLoggerUnitTestSuite) TestInboundAndOutboundBatches() {
+	recorder := &dbRecorder{}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+	logger, err := NewAuditLogger(db, "audit", AuditOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Tags:          map[string]string{"env": "test"},
+		Placeholder:   DollarPlaceholder,
+	})
+	require.NoError(suite.T(), err)
+	// Run a received message through the middleware
+	passed := false
+	ctx := middleware.ContextWithSessionId(context.Background(), "session")
+	logger.Middleware(ctx, wsadapters.Text, []byte("in"), func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		passed = true
+	})
+	require.True(suite.T(), passed)
+	// Send a message with a wrapped connection - batch is full and flushed
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Binary, []byte("out")).Return(nil)
+	require.NoError(suite.T(), logger.WrapConnection(connMock).Write(ctx, wsadapters.Binary, []byte("out")))
+	require.Eventually(suite.T(), func() bool {
+		return len(recorder.execs()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// Record a third message which is flushed on close
+	logger.Record("other", DirectionInbound, wsadapters.Text, []byte("last"))
+	require.NoError(suite.T(), logger.Close())
+	execs := recorder.execs()
+	require.Len(suite.T(), execs, 2)
+	// Check batch insert
+	require.Equal(suite.T(), "INSERT INTO audit (session_id, timestamp, direction, message_type, payload_hash, payload, tags) VALUES ($1, $2, $3, $4, $5, $6, $7), ($8, $9, $10, $11, $12, $13, $14)", execs[0].query)
+	require.Len(suite.T(), execs[0].args, 14)
+	require.Equal(suite.T(), "session", execs[0].args[0])
+	require.Equal(suite.T(), DirectionInbound, execs[0].args[2])
+	require.Equal(suite.T(), "text", execs[0].args[3])
+	require.Equal(suite.T(), hash("in"), execs[0].args[4])
+	require.Nil(suite.T(), execs[0].args[5])
+	require.Equal(suite.T(), `{"env":"test"}`, execs[0].args[6])
+	require.Equal(suite.T(), DirectionOutbound, execs[0].args[9])
+	require.Equal(suite.T(), "binary", execs[0].args[10])
+	require.Equal(suite.T(), hash("out"), execs[0].args[11])
+	// Check remaining record is inserted with a single row statement
+	require.Len(suite.T(), execs[1].args, 7)
+	require.Equal(suite.T(), "other", execs[1].args[0])
+	// Check statements are prepared once and closed
+	require.Equal(suite.T(), 2, recorder.prepared())
+	require.Equal(suite.T(), 2, recorder.closed())
+}
+
+// Test full payload is stored when enabled.
+func (suite *AuditLoggerUnitTestSuite) TestFullPayload() {
+	recorder := &dbRecorder{}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+	opts := AuditOptions{FlushInterval: time.Hour}
+	logger, err := NewAuditLogger(db, "schema.audit", *opts.WithFullPayload(true))
+	require.NoError(suite.T(), err)
+	logger.Record("session", DirectionInbound, wsadapters.Text, []byte("payload"))
+	require.NoError(suite.T(), logger.Close())
+	execs := recorder.execs()
+	require.Len(suite.T(), execs, 1)
+	require.True(suite.T(), strings.HasPrefix(execs[0].query, "INSERT INTO schema.audit "))
+	require.Equal(suite.T(), []byte("payload"), execs[0].args[5])
+	require.Equal(suite.T(), "{}", execs[0].args[6])
+}
+
+// Test factories reject invalid parameters.
+func (suite *AuditLoggerUnitTestSuite) TestInvalidParameters() {
+	db := sql.OpenDB(&dbRecorder{})
+	defer db.Close()
+	_, err := NewAuditLogger(nil, "audit", AuditOptions{})
+	require.Error(suite.T(), err)
+	_, err = NewAuditLogger(db, "audit; DROP TABLE audit", AuditOptions{})
+	require.Error(suite.T(), err)
+	_, err = DatabaseAuditMiddleware(db, "", AuditOptions{})
+	require.Error(suite.T(), err)
+	mw, err := DatabaseAuditMiddleware(db, "audit", AuditOptions{})
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), mw)
+}
+
+/*************************************************************************************************/
+/* DATABASE STUB                                                                                 */
+/*************************************************************************************************/
+
+// Compute the expected payload hash
+func hash(msg string) string {
+	sum := sha256.Sum256([]byte(msg))
+	return hex.EncodeToString(sum[:])
+}
+
+// An executed statement
+type execRecord struct {
+	query string
+	args  []driver.Value
+}
+
+// driver.Connector which records prepared and executed statements
+type dbRecorder struct {
+	mu            sync.Mutex
+	executed      []execRecord
+	preparedCount int
+	closedCount   int
+}
+
+func (recorder *dbRecorder) Connect(ctx context.Context) (driver.Conn, error) {
+	return &connStub{recorder: recorder}, nil
+}
+
+func (recorder *dbRecorder) Driver() driver.Driver {
+	return nil
+}
+
+func (recorder *dbRecorder) execs() []execRecord {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]execRecord(nil), recorder.executed...)
+}
+
+func (recorder *dbRecorder) prepared() int {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.preparedCount
+}
+
+func (recorder *dbRecorder) closed() int {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.closedCount
+}
+
+// driver.Conn stub
+type connStub struct {
+	recorder *dbRecorder
+}
+
+func (conn *connStub) Prepare(query string) (driver.Stmt, error) {
+	conn.recorder.mu.Lock()
+	defer conn.recorder.mu.Unlock()
+	conn.recorder.preparedCount++
+	return &stmtStub{recorder: conn.recorder, query: query}, nil
+}
+
+func (conn *connStub) Close() error {
+	return nil
+}
+
+func (conn *connStub) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+// driver.Stmt stub
+type stmtStub struct {
+	recorder *dbRecorder
+	query    string
+}
+
+func (stmt *stmtStub) Close() error {
+	stmt.recorder.mu.Lock()
+	defer stmt.recorder.mu.Unlock()
+	stmt.recorder.closedCount++
+	return nil
+}
+
+func (stmt *stmtStub) NumInput() int {
+	return -1
+}
+
+func (stmt *stmtStub) Exec(args []driver.Value) (driver.Result, error) {
+	stmt.recorder.mu.Lock()
+	defer stmt.recorder.mu.Unlock()
+	stmt.recorder.executed = append(stmt.recorder.executed, execRecord{query: stmt.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (stmt *stmtStub) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("queries are not supported")
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import "context"
+
+// Alias type used as key in context
+type contextKey string
+
+const (
+	// Context key used to store the engine session ID
+	sessionIdKey contextKey = "sessionId"
+)
+
+// # Description
+//
+// Return a copy of the provided context which holds the provided engine session ID.
+func ContextWithSessionId(ctx context.Context, sessionId string) context.Context {
+	return context.WithValue(ctx, sessionIdKey, sessionId)
+}
+
+// # Description
+//
+// Extract the engine session ID from the provided context.
+//
+// # Returns
+//
+// The session ID stored in the context or an empty string if there is none.
+func SessionIdFromContext(ctx context.Context) string {
+	sessionId, _ := ctx.Value(sessionIdKey).(string)
+	return sessionId
+}
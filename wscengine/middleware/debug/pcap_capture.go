@@ -0,0 +1,231 @@
+//go:build debug
+
+// The package contains middlewares which help debugging applications built on the websocket
+// engine. They are meant for development only: the package is only built with the debug build tag
+// (go build -tags debug) so it cannot be included in production builds by accident.
+package debug
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Synthetic endpoints used in captured packets: messages are captured as if they were sent by the
+// server to the client over a plain TCP connection.
+var (
+	clientMAC  = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	serverMAC  = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	clientIP   = net.IPv4(10, 0, 0, 1)
+	serverIP   = net.IPv4(10, 0, 0, 2)
+	clientPort = layers.TCPPort(49152)
+	serverPort = layers.TCPPort(80)
+)
+
+const (
+	// Maximum TCP payload size of a captured packet: frames are split in several segments
+	maxSegmentSize = 1460
+	// Maximum captured packet size
+	snapshotLength = 65536
+)
+
+// Synthetic opening handshake written at the beginning of the capture so Wireshark dissects the
+// TCP stream as a websocket connection.
+const (
+	handshakeRequest = "GET / HTTP/1.1\r\n" +
+		"Host: 10.0.0.2\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	handshakeResponse = "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n\r\n"
+)
+
+// Writer which captures received messages as synthetic websocket frames in a pcap file which can
+// be opened with Wireshark.
+type PCAPCapture struct {
+	// Mutex used to serialize writes
+	mu sync.Mutex
+	// Capture file
+	file *os.File
+	// pcap writer
+	writer *pcapgo.Writer
+	// Next TCP sequence numbers of the client and of the server
+	clientSeq uint32
+	serverSeq uint32
+	// Codec used to encode websocket frames
+	codec wsadapters.FrameCodec
+	// Flag set once the capture is closed
+	closed bool
+}
+
+// # Description
+//
+// Factory which creates the capture file (truncated if it already exists) and writes the pcap
+// header and a synthetic websocket opening handshake.
+//
+// # Inputs
+//
+//   - outputPath: Path of the pcap file.
+//
+// # Returns
+//
+// A new PCAPCapture or an error if the capture file could not be created.
+func NewPCAPCapture(outputPath string) (*PCAPCapture, error) {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+	capture := &PCAPCapture{
+		file:      file,
+		writer:    pcapgo.NewWriter(file),
+		clientSeq: 1,
+		serverSeq: 1,
+		codec:     wsadapters.DefaultFrameCodec{},
+	}
+	err = capture.writer.WriteFileHeader(snapshotLength, layers.LinkTypeEthernet)
+	if err == nil {
+		err = capture.writeSegments(true, []byte(handshakeRequest))
+	}
+	if err == nil {
+		err = capture.writeSegments(false, []byte(handshakeResponse))
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write capture file: %w", err)
+	}
+	return capture, nil
+}
+
+// # Description
+//
+// Create a middleware which captures received messages in a pcap file. See NewPCAPCapture.
+//
+// The capture file is never closed by the middleware: written packets are not buffered so the file
+// can be opened at any time. Use NewPCAPCapture to be able to close it.
+//
+// # Returns
+//
+// The middleware or an error if the capture file could not be created.
+func PCAPCaptureMiddleware(outputPath string) (middleware.MessageMiddleware, error) {
+	capture, err := NewPCAPCapture(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return capture.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which captures the received message as a server to client websocket frame and hands
+// it over to the next handler. Capture failures never block the message.
+func (capture *PCAPCapture) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	capture.Capture(msgType, msg)
+	next(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Write the message as a server to client websocket frame in the capture file.
+//
+// # Returns
+//
+// An error if the frame could not be written or if the capture is closed.
+func (capture *PCAPCapture) Capture(msgType wsadapters.MessageType, msg []byte) error {
+	frame, err := capture.codec.Encode(int(msgType), msg, false)
+	if err != nil {
+		return err
+	}
+	return capture.writeSegments(false, frame)
+}
+
+// # Description
+//
+// Close the capture file. Next messages are not captured anymore.
+func (capture *PCAPCapture) Close() error {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	if capture.closed {
+		return nil
+	}
+	capture.closed = true
+	return capture.file.Close()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Write data as one or several TCP segments sent by the client or by the server.
+func (capture *PCAPCapture) writeSegments(fromClient bool, data []byte) error {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	if capture.closed {
+		return fmt.Errorf("capture is closed")
+	}
+	for offset := 0; offset < len(data); offset += maxSegmentSize {
+		end := offset + maxSegmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		err := capture.writeSegment(fromClient, data[offset:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write a single TCP segment - mutex must be held.
+func (capture *PCAPCapture) writeSegment(fromClient bool, payload []byte) error {
+	eth := &layers.Ethernet{SrcMAC: serverMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: serverIP, DstIP: clientIP}
+	tcp := &layers.TCP{SrcPort: serverPort, DstPort: clientPort, Seq: capture.serverSeq, Ack: capture.clientSeq, ACK: true, PSH: true, Window: 65535}
+	if fromClient {
+		eth.SrcMAC, eth.DstMAC = clientMAC, serverMAC
+		ip.SrcIP, ip.DstIP = clientIP, serverIP
+		tcp.SrcPort, tcp.DstPort = clientPort, serverPort
+		tcp.Seq, tcp.Ack = capture.clientSeq, capture.serverSeq
+	}
+	err := tcp.SetNetworkLayerForChecksum(ip)
+	if err != nil {
+		return err
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	err = gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload))
+	if err != nil {
+		return err
+	}
+	packet := buf.Bytes()
+	err = capture.writer.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     time.Now(),
+		CaptureLength: len(packet),
+		Length:        len(packet),
+	}, packet)
+	if err != nil {
+		return err
+	}
+	if fromClient {
+		capture.clientSeq += uint32(len(payload))
+	} else {
+		capture.serverSeq += uint32(len(payload))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+//go:build debug
+
+package debug
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for PCAPCapture unit tests
+type PCAPCaptureUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run PCAPCaptureUnitTestSuite test suite
+func TestPCAPCaptureUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PCAPCaptureUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test received messages are captured as websocket frames which can be read back from the file.
+func (suite *PCAPCaptureUnitTestSuite) TestCapture() {
+	path := filepath.Join(suite.T().TempDir(), "capture.pcap")
+	capture, err := NewPCAPCapture(path)
+	require.NoError(suite.T(), err)
+	large := bytes.Repeat([]byte("x"), 70000)
+	delivered := 0
+	next := func(context.Context, wsadapters.MessageType, []byte) { delivered++ }
+	capture.Middleware(context.Background(), wsadapters.Text, []byte("hello"), next)
+	capture.Middleware(context.Background(), wsadapters.Binary, large, next)
+	require.Equal(suite.T(), 2, delivered)
+	require.NoError(suite.T(), capture.Close())
+	require.NoError(suite.T(), capture.Close())
+	// Messages are still delivered once the capture is closed
+	capture.Middleware(context.Background(), wsadapters.Text, []byte("late"), next)
+	require.Equal(suite.T(), 3, delivered)
+	// Read back the capture and rebuild both TCP streams
+	file, err := os.Open(path)
+	require.NoError(suite.T(), err)
+	defer file.Close()
+	reader, err := pcapgo.NewReader(file)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), layers.LinkTypeEthernet, reader.LinkType())
+	clientStream, serverStream := []byte{}, []byte{}
+	source := gopacket.NewPacketSource(reader, layers.LayerTypeEthernet)
+	for packet := range source.Packets() {
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		require.True(suite.T(), ok)
+		if tcp.SrcPort == clientPort {
+			clientStream = append(clientStream, tcp.Payload...)
+		} else {
+			serverStream = append(serverStream, tcp.Payload...)
+		}
+	}
+	require.Equal(suite.T(), handshakeRequest, string(clientStream))
+	codec := wsadapters.DefaultFrameCodec{}
+	first, _ := codec.Encode(int(wsadapters.Text), []byte("hello"), false)
+	second, _ := codec.Encode(int(wsadapters.Binary), large, false)
+	expected := append([]byte(handshakeResponse), first...)
+	expected = append(expected, second...)
+	require.Equal(suite.T(), expected, serverStream)
+}
+
+// Test the factory fails when the capture file cannot be created.
+func (suite *PCAPCaptureUnitTestSuite) TestCaptureFileError() {
+	_, err := PCAPCaptureMiddleware(filepath.Join(suite.T().TempDir(), "missing", "capture.pcap"))
+	require.Error(suite.T(), err)
+}
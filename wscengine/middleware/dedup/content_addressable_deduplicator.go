@@ -0,0 +1,225 @@
+// The package contains a middleware which drops duplicated messages, like the messages received
+// twice during the overlap window when subscriptions are renewed after a reconnection.
+//
+// Messages are identified by the SHA-256 hash of their content. Hashes are stored in memory or in
+// Redis to deduplicate messages across several engine instances.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefix of the Redis keys used to store message hashes
+const redisKeyPrefix = "gowse:dedup:"
+
+// Functional option used to customize a ContentAddressableDeduplicator when it is created.
+type DeduplicatorOption func(dedup *ContentAddressableDeduplicator)
+
+// # Description
+//
+// Option which sets the interval between two removals of expired hashes from the in-memory store.
+// Defaults to the TTL. The option has no effect when a Redis backend is used as Redis expires
+// hashes itself.
+func WithCleanupInterval(interval time.Duration) DeduplicatorOption {
+	return func(dedup *ContentAddressableDeduplicator) {
+		if interval > 0 {
+			dedup.cleanupInterval = interval
+		}
+	}
+}
+
+// # Description
+//
+// Option which stores message hashes in Redis instead of memory so messages are deduplicated
+// across all engine instances which use the same Redis server. Hashes are stored with the
+// "gowse:dedup:" key prefix and expire after the TTL.
+//
+// If Redis cannot be reached, messages are handed over to the next handler (fail open) and the
+// failure is logged with the default logger.
+func WithRedisBackend(client *redis.Client) DeduplicatorOption {
+	return func(dedup *ContentAddressableDeduplicator) {
+		dedup.redis = client
+	}
+}
+
+// Option which replaces the function used to get current time - used in tests.
+func withClock(now func() time.Time) DeduplicatorOption {
+	return func(dedup *ContentAddressableDeduplicator) {
+		dedup.now = now
+	}
+}
+
+// Middleware which drops messages whose content has already been seen within a TTL.
+type ContentAddressableDeduplicator struct {
+	// Time during which a seen message is considered as a duplicate
+	ttl time.Duration
+	// Interval between two cleanups of the in-memory store
+	cleanupInterval time.Duration
+	// Redis client used to store hashes - nil if hashes are stored in memory
+	redis *redis.Client
+	// Expiration time (time.Time) by message hash ([sha256.Size]byte) - in-memory store
+	seen sync.Map
+	// Number of dropped messages
+	dropped atomic.Int64
+	// Channel closed to stop the cleanup goroutine
+	stop chan struct{}
+	// Channel closed once the cleanup goroutine has exited
+	done chan struct{}
+	// Used to close the deduplicator once
+	closeOnce sync.Once
+	// Function used to get current time
+	now func() time.Time
+}
+
+// # Description
+//
+// Factory which creates a new ContentAddressableDeduplicator. When hashes are stored in memory, a
+// goroutine removes expired hashes until Close is called.
+//
+// # Inputs
+//
+//   - ttl: Time during which a seen message is considered as a duplicate. Must be positive.
+//   - opts: Options used to customize the deduplicator.
+//
+// # Returns
+//
+// A new ContentAddressableDeduplicator or an error if ttl is not positive.
+func NewContentAddressableDeduplicator(ttl time.Duration, opts ...DeduplicatorOption) (*ContentAddressableDeduplicator, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be positive")
+	}
+	dedup := &ContentAddressableDeduplicator{
+		ttl:             ttl,
+		cleanupInterval: ttl,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(dedup)
+	}
+	if dedup.redis == nil {
+		go dedup.run()
+	} else {
+		close(dedup.done)
+	}
+	return dedup, nil
+}
+
+// # Description
+//
+// Create a middleware which drops messages whose content has already been seen within the TTL.
+// See NewContentAddressableDeduplicator.
+//
+// The underlying deduplicator lives as long as the process. Use NewContentAddressableDeduplicator
+// to be able to stop it.
+func ContentAddressableDeduplicatorMiddleware(ttl time.Duration, opts ...DeduplicatorOption) (middleware.MessageMiddleware, error) {
+	dedup, err := NewContentAddressableDeduplicator(ttl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return dedup.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which hands over the message to the next handler unless a message with the same
+// content has been seen within the TTL.
+func (dedup *ContentAddressableDeduplicator) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	if dedup.isDuplicate(ctx, sha256.Sum256(msg)) {
+		dedup.dropped.Add(1)
+		return
+	}
+	next(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Return the number of messages which have been dropped as duplicates.
+func (dedup *ContentAddressableDeduplicator) Dropped() int64 {
+	return dedup.dropped.Load()
+}
+
+// # Description
+//
+// Stop the cleanup goroutine. The Redis client is not closed.
+func (dedup *ContentAddressableDeduplicator) Close() error {
+	dedup.closeOnce.Do(func() {
+		close(dedup.stop)
+		<-dedup.done
+	})
+	return nil
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Record the hash and return true if it has already been seen within the TTL.
+func (dedup *ContentAddressableDeduplicator) isDuplicate(ctx context.Context, hash [sha256.Size]byte) bool {
+	if dedup.redis != nil {
+		// SET NX only succeeds for the first message within the TTL
+		first, err := dedup.redis.SetNX(ctx, redisKeyPrefix+hex.EncodeToString(hash[:]), 1, dedup.ttl).Result()
+		if err != nil {
+			log.Default().Printf("failed to check message hash in redis: %s", err)
+			return false
+		}
+		return !first
+	}
+	now := dedup.now()
+	expiration := now.Add(dedup.ttl)
+	for {
+		previous, loaded := dedup.seen.LoadOrStore(hash, expiration)
+		if !loaded {
+			return false
+		}
+		if now.Before(previous.(time.Time)) {
+			return true
+		}
+		// Hash has expired - record it again unless another goroutine did it meanwhile
+		if dedup.seen.CompareAndSwap(hash, previous, expiration) {
+			return false
+		}
+	}
+}
+
+// Remove expired hashes periodically until the deduplicator is closed.
+func (dedup *ContentAddressableDeduplicator) run() {
+	defer close(dedup.done)
+	ticker := time.NewTicker(dedup.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-dedup.stop:
+			return
+		case <-ticker.C:
+			dedup.cleanup()
+		}
+	}
+}
+
+// Remove expired hashes from the in-memory store.
+func (dedup *ContentAddressableDeduplicator) cleanup() {
+	now := dedup.now()
+	dedup.seen.Range(func(key, value any) bool {
+		if !now.Before(value.(time.Time)) {
+			dedup.seen.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
@@ -0,0 +1,119 @@
+package dedup
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for ContentAddressableDeduplicator unit tests
+type ContentAddressableDeduplicatorUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ContentAddressableDeduplicatorUnitTestSuite test suite
+func TestContentAddressableDeduplicatorUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ContentAddressableDeduplicatorUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory rejects non positive TTL.
+func (suite *ContentAddressableDeduplicatorUnitTestSuite) TestNewContentAddressableDeduplicator() {
+	_, err := NewContentAddressableDeduplicator(0)
+	require.Error(suite.T(), err)
+	_, err = ContentAddressableDeduplicatorMiddleware(-time.Second)
+	require.Error(suite.T(), err)
+}
+
+// Test duplicated messages are dropped until the TTL expires and expired hashes are cleaned up.
+func (suite *ContentAddressableDeduplicatorUnitTestSuite) TestInMemoryStore() {
+	now := time.Now()
+	clock := &atomic.Int64{}
+	clock.Store(now.UnixNano())
+	dedup, err := NewContentAddressableDeduplicator(
+		time.Minute,
+		WithCleanupInterval(10*time.Millisecond),
+		withClock(func() time.Time { return time.Unix(0, clock.Load()) }))
+	require.NoError(suite.T(), err)
+	defer dedup.Close()
+	received := []string{}
+	handler := middleware.Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		received = append(received, string(msg))
+	}, dedup.Middleware)
+	handler(context.Background(), wsadapters.Text, []byte("a"))
+	handler(context.Background(), wsadapters.Text, []byte("b"))
+	handler(context.Background(), wsadapters.Text, []byte("a"))
+	require.Equal(suite.T(), []string{"a", "b"}, received)
+	require.Equal(suite.T(), int64(1), dedup.Dropped())
+	// Move time after TTL: hashes are removed by the cleanup goroutine and messages are accepted again
+	clock.Store(now.Add(2 * time.Minute).UnixNano())
+	require.Eventually(suite.T(), func() bool {
+		count := 0
+		dedup.seen.Range(func(key, value any) bool {
+			count++
+			return true
+		})
+		return count == 0
+	}, time.Second, 10*time.Millisecond)
+	handler(context.Background(), wsadapters.Text, []byte("a"))
+	require.Equal(suite.T(), []string{"a", "b", "a"}, received)
+	// Close is idempotent
+	require.NoError(suite.T(), dedup.Close())
+	require.NoError(suite.T(), dedup.Close())
+}
+
+// Test an expired hash which has not been cleaned up yet does not cause a message to be dropped.
+func (suite *ContentAddressableDeduplicatorUnitTestSuite) TestExpiredHashBeforeCleanup() {
+	dedup, err := NewContentAddressableDeduplicator(20*time.Millisecond, WithCleanupInterval(time.Hour))
+	require.NoError(suite.T(), err)
+	defer dedup.Close()
+	count := 0
+	next := func(context.Context, wsadapters.MessageType, []byte) { count++ }
+	dedup.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	time.Sleep(30 * time.Millisecond)
+	dedup.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	dedup.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	require.Equal(suite.T(), 2, count)
+}
+
+// Test messages are deduplicated across deduplicators which share a Redis backend.
+func (suite *ContentAddressableDeduplicatorUnitTestSuite) TestRedisBackend() {
+	srv := miniredis.RunT(suite.T())
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer client.Close()
+	first, err := NewContentAddressableDeduplicator(time.Minute, WithRedisBackend(client))
+	require.NoError(suite.T(), err)
+	defer first.Close()
+	second, err := ContentAddressableDeduplicatorMiddleware(time.Minute, WithRedisBackend(client))
+	require.NoError(suite.T(), err)
+	count := 0
+	next := func(context.Context, wsadapters.MessageType, []byte) { count++ }
+	first.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	second(context.Background(), wsadapters.Text, []byte("a"), next)
+	require.Equal(suite.T(), 1, count)
+	require.Len(suite.T(), srv.Keys(), 1)
+	require.Equal(suite.T(), time.Minute, srv.TTL(srv.Keys()[0]))
+	// Hash expires in Redis
+	srv.FastForward(2 * time.Minute)
+	first.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	require.Equal(suite.T(), 2, count)
+	// Messages are delivered when Redis is unreachable
+	srv.Close()
+	first.Middleware(context.Background(), wsadapters.Text, []byte("a"), next)
+	require.Equal(suite.T(), 3, count)
+}
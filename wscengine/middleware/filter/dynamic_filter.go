@@ -0,0 +1,206 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Filter which drops received messages matching rules loaded from a configuration file. Rules are
+// reloaded periodically and each time the configuration file changes.
+type DynamicFilter struct {
+	// Path to the rules configuration file
+	configPath string
+	// Current rules - replaced atomically on reload
+	rules atomic.Pointer[Rules]
+	// Watcher used to reload rules when the file changes
+	watcher *fsnotify.Watcher
+	// Logger used to report failed reloads
+	logger *log.Logger
+	// Channel closed to stop the reload goroutine
+	stop chan struct{}
+	// Channel closed when the reload goroutine has exited
+	done chan struct{}
+	// Used to ensure Close is performed once
+	closeOnce sync.Once
+}
+
+// # Description
+//
+// Factory which creates a new DynamicFilter. Rules are loaded from the configuration file before
+// the factory returns. A goroutine is then started to reload the rules every reloadInterval and
+// each time the configuration file changes. Failed reloads keep the previous rules.
+//
+// # Inputs
+//
+//   - configPath: Path to the YAML or JSON rules configuration file.
+//   - reloadInterval: Interval between two periodic reloads. A value of 0 or less disables
+//     periodic reloads: rules are then only reloaded on file change.
+//   - logger: Logger used to report failed reloads. If nil, default logger will be used.
+//
+// # Returns
+//
+// A new DynamicFilter or an error if rules could not be loaded or the file could not be watched.
+func NewDynamicFilter(configPath string, reloadInterval time.Duration, logger *log.Logger) (*DynamicFilter, error) {
+	if logger == nil {
+		// Use default logger
+		logger = log.Default()
+	}
+	filter := &DynamicFilter{
+		configPath: configPath,
+		logger:     logger,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	// Initial load
+	err := filter.Reload()
+	if err != nil {
+		return nil, err
+	}
+	// Watch directory as editors often replace the file instead of writing it
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filter rules watcher: %w", err)
+	}
+	err = watcher.Add(filepath.Dir(configPath))
+	if err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch filter rules file: %w", err)
+	}
+	filter.watcher = watcher
+	// Start reload goroutine
+	go filter.run(reloadInterval)
+	return filter, nil
+}
+
+// # Description
+//
+// Create a middleware which drops received messages matching rules loaded from the provided
+// configuration file. See NewDynamicFilter.
+//
+// The underlying filter lives as long as the process. Use NewDynamicFilter to be able to stop it.
+func DynamicFilterMiddleware(configPath string, reloadInterval time.Duration) (middleware.MessageMiddleware, error) {
+	filter, err := NewDynamicFilter(configPath, reloadInterval, nil)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which drops messages matching the current rules and hands over other messages to
+// the next handler.
+func (filter *DynamicFilter) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	if filter.rules.Load().Match(msg) {
+		// Drop message
+		return
+	}
+	next(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Reload rules from the configuration file. Current rules are kept in case of error.
+//
+// An empty file (or a file which only contains whitespaces) is rejected: it is usually a file
+// which is being rewritten in place and has just been truncated. Use an explicit empty rule list
+// (rules: []) to disable filtering.
+//
+// # Returns
+//
+// Nil in case of success or an error if the file could not be read, is empty or could not be
+// parsed.
+func (filter *DynamicFilter) Reload() error {
+	content, err := os.ReadFile(filter.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read filter rules: %w", err)
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return fmt.Errorf("filter rules file is empty")
+	}
+	rules, err := ParseRules(content)
+	if err != nil {
+		return err
+	}
+	filter.rules.Store(rules)
+	return nil
+}
+
+// # Description
+//
+// Return the rules currently used by the filter.
+func (filter *DynamicFilter) Rules() *Rules {
+	return filter.rules.Load()
+}
+
+// # Description
+//
+// Stop reloading rules. The filter keeps filtering messages with the last loaded rules.
+func (filter *DynamicFilter) Close() error {
+	var err error
+	filter.closeOnce.Do(func() {
+		close(filter.stop)
+		err = filter.watcher.Close()
+		<-filter.done
+	})
+	return err
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Reload rules periodically and on file change until the filter is closed.
+func (filter *DynamicFilter) run(reloadInterval time.Duration) {
+	defer close(filter.done)
+	// Use a nil channel when periodic reload is disabled so it never fires
+	var tick <-chan time.Time
+	if reloadInterval > 0 {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	target := filepath.Clean(filter.configPath)
+	for {
+		select {
+		case <-filter.stop:
+			return
+		case <-tick:
+			filter.reloadAndLog()
+		case event, ok := <-filter.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == target && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				filter.reloadAndLog()
+			}
+		case err, ok := <-filter.watcher.Errors:
+			if !ok {
+				return
+			}
+			filter.logger.Println("filter rules watcher error:", err)
+		}
+	}
+}
+
+// Reload rules and log the error if any.
+func (filter *DynamicFilter) reloadAndLog() {
+	if err := filter.Reload(); err != nil {
+		filter.logger.Println("failed to reload filter rules, previous rules are kept:", err)
+	}
+}
@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for DynamicFilter unit tests
+type DynamicFilterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run DynamicFilterUnitTestSuite test suite
+func TestDynamicFilterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DynamicFilterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails when the configuration file is missing or invalid.
+func (suite *DynamicFilterUnitTestSuite) TestFactoryWithBadConfig() {
+	dir := suite.T().TempDir()
+	_, err := NewDynamicFilter(filepath.Join(dir, "missing.yaml"), 0, nil)
+	require.Error(suite.T(), err)
+	path := filepath.Join(dir, "rules.yaml")
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules:\n  - field: type\n"), 0o600))
+	_, err = NewDynamicFilter(path, 0, nil)
+	require.Error(suite.T(), err)
+}
+
+// Test the middleware drops matching messages and rules are reloaded when the file changes.
+func (suite *DynamicFilterUnitTestSuite) TestMiddlewareAndReload() {
+	path := filepath.Join(suite.T().TempDir(), "rules.yaml")
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules:\n  - field: $.type\n    value: heartbeat\n"), 0o600))
+	filter, err := NewDynamicFilter(path, 0, log.New(io.Discard, "", 0))
+	require.NoError(suite.T(), err)
+	defer filter.Close()
+	// Count messages which pass through the filter
+	passed := 0
+	next := func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) { passed++ }
+	filter.Middleware(context.Background(), wsadapters.Text, []byte(`{"type":"heartbeat"}`), next)
+	filter.Middleware(context.Background(), wsadapters.Text, []byte(`{"type":"ticker"}`), next)
+	require.Equal(suite.T(), 1, passed)
+	// Update rules in place and wait for the reload - The watcher may see the truncated file
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules:\n  - field: $.type\n    value: ticker\n"), 0o600))
+	require.Eventually(suite.T(), func() bool {
+		return filter.Rules().Match([]byte(`{"type":"ticker"}`))
+	}, 5*time.Second, 10*time.Millisecond)
+	// Invalid rules must not replace current rules
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules: ["), 0o600))
+	require.Error(suite.T(), filter.Reload())
+	require.True(suite.T(), filter.Rules().Match([]byte(`{"type":"ticker"}`)))
+	// Truncated files must not replace current rules
+	require.NoError(suite.T(), os.WriteFile(path, []byte(" \n"), 0o600))
+	require.Error(suite.T(), filter.Reload())
+	require.True(suite.T(), filter.Rules().Match([]byte(`{"type":"ticker"}`)))
+}
+
+// Test periodic reload.
+func (suite *DynamicFilterUnitTestSuite) TestPeriodicReload() {
+	path := filepath.Join(suite.T().TempDir(), "rules.yaml")
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules: []\n"), 0o600))
+	filter, err := NewDynamicFilter(path, 10*time.Millisecond, log.New(io.Discard, "", 0))
+	require.NoError(suite.T(), err)
+	// Remove watcher from the picture so only periodic reload can pick up changes
+	require.NoError(suite.T(), filter.watcher.Remove(filepath.Dir(path)))
+	require.NoError(suite.T(), os.WriteFile(path, []byte("rules:\n  - field: $.id\n    operator: exists\n"), 0o600))
+	require.Eventually(suite.T(), func() bool {
+		return filter.Rules().Match([]byte(`{"id":1}`))
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(suite.T(), filter.Close())
+	require.NoError(suite.T(), filter.Close())
+}
@@ -0,0 +1,139 @@
+// The package contains a middleware which drops received messages based on rules loaded from a
+// configuration file which is reloaded at runtime.
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operators which can be used in a filter rule.
+type Operator string
+
+const (
+	// Field value must be equal to the rule value
+	OperatorEqual Operator = "eq"
+	// Field value must not be equal to the rule value
+	OperatorNotEqual Operator = "ne"
+	// Field must exist in the message
+	OperatorExists Operator = "exists"
+)
+
+// A rule which describes messages to drop.
+type Rule struct {
+	// Path to the field to evaluate, using a dot notation which starts with '$' as root (for
+	// example: $.type or $.data.channel).
+	Field string `json:"field" yaml:"field"`
+	// Operator used to evaluate the field. Defaults to eq.
+	Operator Operator `json:"operator" yaml:"operator"`
+	// Value the field value is compared to. Not used with exists operator.
+	Value any `json:"value" yaml:"value"`
+}
+
+// Content of a filter rules configuration file.
+//
+// Example (YAML):
+//
+//	rules:
+//	  - field: $.type
+//	    operator: eq
+//	    value: heartbeat
+//
+// A message is dropped if it matches at least one rule.
+type Rules struct {
+	// Rules used to decide which messages are dropped.
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// # Description
+//
+// Parse filter rules from the provided YAML or JSON content and validate them.
+//
+// # Returns
+//
+// The parsed rules or an error if the content is not valid.
+func ParseRules(content []byte) (*Rules, error) {
+	// JSON being a subset of YAML, YAML parser is used for both formats
+	rules := new(Rules)
+	err := yaml.Unmarshal(content, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter rules: %w", err)
+	}
+	// Validate rules
+	for i, rule := range rules.Rules {
+		if rule.Field != "$" && !strings.HasPrefix(rule.Field, "$.") {
+			return nil, fmt.Errorf("invalid filter rule #%d: field must start with '$.': %s", i, rule.Field)
+		}
+		switch rule.Operator {
+		case "":
+			rules.Rules[i].Operator = OperatorEqual
+		case OperatorEqual, OperatorNotEqual, OperatorExists:
+		default:
+			return nil, fmt.Errorf("invalid filter rule #%d: unknown operator: %s", i, rule.Operator)
+		}
+	}
+	return rules, nil
+}
+
+// # Description
+//
+// Check whether the provided message matches at least one rule and must be dropped. Messages which
+// are not JSON objects never match.
+func (rules *Rules) Match(msg []byte) bool {
+	if rules == nil || len(rules.Rules) == 0 {
+		return false
+	}
+	// Decode message
+	var doc any
+	if err := json.Unmarshal(msg, &doc); err != nil {
+		return false
+	}
+	// Evaluate rules
+	for _, rule := range rules.Rules {
+		if rule.match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate the rule against a decoded JSON document.
+func (rule Rule) match(doc any) bool {
+	value, found := lookup(doc, rule.Field)
+	switch rule.Operator {
+	case OperatorExists:
+		return found
+	case OperatorNotEqual:
+		return found && !equal(value, rule.Value)
+	default:
+		return found && equal(value, rule.Value)
+	}
+}
+
+// Lookup a field in a decoded JSON document using a $.a.b path.
+func lookup(doc any, path string) (any, bool) {
+	current := doc
+	for _, name := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".") {
+		if name == "" {
+			continue
+		}
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Compare a decoded JSON value with a rule value using their string representation so numbers
+// and booleans from YAML and JSON compare equal.
+func equal(value any, expected any) bool {
+	return fmt.Sprint(value) == fmt.Sprint(expected)
+}
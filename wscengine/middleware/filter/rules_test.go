@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for Rules unit tests
+type RulesUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run RulesUnitTestSuite test suite
+func TestRulesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RulesUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test ParseRules with YAML and JSON content.
+func (suite *RulesUnitTestSuite) TestParseRules() {
+	rules, err := ParseRules([]byte("rules:\n  - field: $.type\n    value: heartbeat\n"))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), rules.Rules, 1)
+	require.Equal(suite.T(), OperatorEqual, rules.Rules[0].Operator)
+	rules, err = ParseRules([]byte(`{"rules":[{"field":"$.data.id","operator":"exists"}]}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), OperatorExists, rules.Rules[0].Operator)
+}
+
+// Test ParseRules rejects invalid rules.
+func (suite *RulesUnitTestSuite) TestParseRulesInvalid() {
+	_, err := ParseRules([]byte("rules:\n  - field: type\n"))
+	require.Error(suite.T(), err)
+	_, err = ParseRules([]byte("rules:\n  - field: $.type\n    operator: gt\n"))
+	require.Error(suite.T(), err)
+	_, err = ParseRules([]byte("rules: ["))
+	require.Error(suite.T(), err)
+}
+
+// Test Match with all operators.
+func (suite *RulesUnitTestSuite) TestMatch() {
+	rules := &Rules{Rules: []Rule{
+		{Field: "$.type", Operator: OperatorEqual, Value: "heartbeat"},
+		{Field: "$.data.level", Operator: OperatorNotEqual, Value: 1},
+		{Field: "$.debug", Operator: OperatorExists},
+	}}
+	require.True(suite.T(), rules.Match([]byte(`{"type":"heartbeat"}`)))
+	require.True(suite.T(), rules.Match([]byte(`{"type":"data","data":{"level":2}}`)))
+	require.False(suite.T(), rules.Match([]byte(`{"type":"data","data":{"level":1}}`)))
+	require.True(suite.T(), rules.Match([]byte(`{"debug":null}`)))
+	require.False(suite.T(), rules.Match([]byte(`not json`)))
+	require.False(suite.T(), (*Rules)(nil).Match([]byte(`{"type":"heartbeat"}`)))
+}
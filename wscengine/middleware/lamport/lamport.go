@@ -0,0 +1,194 @@
+// The package contains a middleware and a connection decorator which maintain a Lamport logical
+// clock (https://en.wikipedia.org/wiki/Lamport_timestamp) from timestamps carried by JSON messages.
+//
+// The clock is not reset when the engine restarts so timestamps can be used to reason about the
+// happens-before relation of messages across reconnects and sessions.
+package lamport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Alias type used as key in context
+type contextKey string
+
+const (
+	// Context key used to store the Lamport timestamp of a received message
+	lamportClockKey contextKey = "lamportClock"
+)
+
+// # Description
+//
+// Extract the Lamport timestamp attached to the context by a LamportClock middleware.
+//
+// # Returns
+//
+// The local clock value after the message has been received and true, or 0 and false if the
+// context holds no Lamport timestamp.
+func TimestampFromContext(ctx context.Context) (uint64, bool) {
+	ts, ok := ctx.Value(lamportClockKey).(uint64)
+	return ts, ok
+}
+
+// Lamport logical clock updated by received messages and used to timestamp sent messages.
+//
+// The clock is safe for concurrent use.
+type LamportClock struct {
+	// Name of the JSON field which contains the Lamport timestamp
+	fieldName string
+	// Mutex used to protect the clock value
+	mu sync.Mutex
+	// Local clock value
+	value uint64
+}
+
+// # Description
+//
+// Factory which creates a new LamportClock which starts at 0.
+//
+// # Inputs
+//
+//   - clockFieldName: Name of the top level JSON field which contains the Lamport timestamp in
+//     received and sent messages.
+func NewLamportClock(clockFieldName string) *LamportClock {
+	return &LamportClock{fieldName: clockFieldName}
+}
+
+// # Description
+//
+// Create a middleware which updates a Lamport clock with the timestamps of received messages and
+// attaches the updated clock value to the context provided to the next handler.
+//
+// The underlying clock lives as long as the process. Use NewLamportClock to be able to timestamp
+// sent messages (see LamportClock.WrapConnection).
+func LamportClockMiddleware(clockFieldName string) middleware.MessageMiddleware {
+	return NewLamportClock(clockFieldName).Middleware
+}
+
+// # Description
+//
+// Return the current value of the local clock.
+func (clock *LamportClock) Now() uint64 {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.value
+}
+
+// # Description
+//
+// Increment the local clock for a local event (like a sent message) and return its new value.
+func (clock *LamportClock) Tick() uint64 {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.value++
+	return clock.value
+}
+
+// # Description
+//
+// Update the local clock with a received timestamp: the local clock is set to the max. of the
+// local clock and the received timestamp, plus one.
+//
+// # Returns
+//
+// The updated local clock value.
+func (clock *LamportClock) Observe(ts uint64) uint64 {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	if ts > clock.value {
+		clock.value = ts
+	}
+	clock.value++
+	return clock.value
+}
+
+// # Description
+//
+// Middleware which updates the clock with the timestamp contained in the received message and
+// hands the message over to the next handler with a context which holds the updated clock value
+// (see TimestampFromContext).
+//
+// Messages which are not JSON objects or which do not contain a valid timestamp are handed over
+// too: they are treated as local events and only increment the clock.
+func (clock *LamportClock) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	var ts uint64
+	if received, ok := clock.extract(msg); ok {
+		ts = clock.Observe(received)
+	} else {
+		ts = clock.Tick()
+	}
+	next(context.WithValue(ctx, lamportClockKey, ts), msgType, msg)
+}
+
+// # Description
+//
+// Decorate the provided connection adapter so messages can be timestamped with the clock before
+// they are sent (see ClockedConnectionAdapter.WriteWithClock).
+func (clock *LamportClock) WrapConnection(conn wsadapters.WebsocketConnectionAdapterInterface) *ClockedConnectionAdapter {
+	return &ClockedConnectionAdapter{
+		WebsocketConnectionAdapterInterface: conn,
+		clock:                               clock,
+	}
+}
+
+// Connection adapter decorator which can timestamp sent messages with a Lamport clock. Other
+// methods, Write included, are forwarded as is to the decorated connection adapter.
+type ClockedConnectionAdapter struct {
+	wsadapters.WebsocketConnectionAdapterInterface
+	// Clock used to timestamp sent messages
+	clock *LamportClock
+}
+
+// # Description
+//
+// Increment the clock, inject its value in the clock field of the message and send it with the
+// decorated connection adapter. Top level fields of the message are re-encoded in alphabetical
+// order.
+//
+// # Returns
+//
+// An error if the message is not a JSON object or if Write failed.
+func (adapter *ClockedConnectionAdapter) WriteWithClock(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return fmt.Errorf("failed to inject lamport timestamp: message is not a JSON object: %w", err)
+	}
+	fields[adapter.clock.fieldName] = json.RawMessage(fmt.Sprint(adapter.clock.Tick()))
+	timestamped, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to inject lamport timestamp: %w", err)
+	}
+	return adapter.WebsocketConnectionAdapterInterface.Write(ctx, msgType, timestamped)
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Extract the timestamp from the clock field of the message. False is returned if the message is
+// not a JSON object or if the field is missing or is not an unsigned integer.
+func (clock *LamportClock) extract(msg []byte) (uint64, bool) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return 0, false
+	}
+	raw, found := fields[clock.fieldName]
+	if !found {
+		return 0, false
+	}
+	var ts uint64
+	if err := json.Unmarshal(raw, &ts); err != nil {
+		return 0, false
+	}
+	return ts, true
+}
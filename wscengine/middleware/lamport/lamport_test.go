@@ -0,0 +1,99 @@
+package lamport
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for Lamport clock unit tests
+type LamportClockUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run LamportClockUnitTestSuite test suite
+func TestLamportClockUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LamportClockUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the clock is updated by received messages and attached to the context.
+func (suite *LamportClockUnitTestSuite) TestMiddleware() {
+	clock := NewLamportClock("ts")
+	received := []uint64{}
+	handler := middleware.Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		ts, ok := TimestampFromContext(ctx)
+		require.True(suite.T(), ok)
+		received = append(received, ts)
+	}, clock.Middleware)
+	// Received timestamp ahead of the local clock
+	handler(context.Background(), wsadapters.Text, []byte(`{"ts":10,"data":"a"}`))
+	// Received timestamp behind the local clock
+	handler(context.Background(), wsadapters.Text, []byte(`{"ts":3}`))
+	// Missing, invalid timestamps and non JSON messages are local events
+	handler(context.Background(), wsadapters.Text, []byte(`{"data":"b"}`))
+	handler(context.Background(), wsadapters.Text, []byte(`{"ts":"invalid"}`))
+	handler(context.Background(), wsadapters.Binary, []byte("not json"))
+	require.Equal(suite.T(), []uint64{11, 12, 13, 14, 15}, received)
+	require.Equal(suite.T(), uint64(15), clock.Now())
+	// No timestamp in a plain context
+	_, ok := TimestampFromContext(context.Background())
+	require.False(suite.T(), ok)
+	// Convenience factory
+	var ts uint64
+	LamportClockMiddleware("ts")(context.Background(), wsadapters.Text, []byte(`{"ts":41}`), func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		ts, _ = TimestampFromContext(ctx)
+	})
+	require.Equal(suite.T(), uint64(42), ts)
+}
+
+// Test sent messages are timestamped with the local clock.
+func (suite *LamportClockUnitTestSuite) TestWriteWithClock() {
+	clock := NewLamportClock("ts")
+	clock.Observe(5)
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Text, mock.Anything).Return(nil)
+	conn := clock.WrapConnection(connMock)
+	require.NoError(suite.T(), conn.WriteWithClock(context.Background(), wsadapters.Text, []byte(`{"data":"a","ts":1}`)))
+	require.JSONEq(suite.T(), `{"data":"a","ts":7}`, string(connMock.Calls[0].Arguments.Get(2).([]byte)))
+	require.Equal(suite.T(), uint64(7), clock.Now())
+	// Write is forwarded as is
+	require.NoError(suite.T(), conn.Write(context.Background(), wsadapters.Text, []byte(`{"data":"b"}`)))
+	require.Equal(suite.T(), []byte(`{"data":"b"}`), connMock.Calls[1].Arguments.Get(2))
+	// Messages which are not JSON objects are rejected
+	require.Error(suite.T(), conn.WriteWithClock(context.Background(), wsadapters.Text, []byte(`[1,2]`)))
+	connMock.AssertNumberOfCalls(suite.T(), "Write", 2)
+	require.Equal(suite.T(), uint64(7), clock.Now())
+}
+
+// Test the clock is safe for concurrent use.
+func (suite *LamportClockUnitTestSuite) TestConcurrentUse() {
+	clock := NewLamportClock("ts")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			clock.Tick()
+		}()
+		go func() {
+			defer wg.Done()
+			clock.Middleware(context.Background(), wsadapters.Text, []byte(`{"ts":0}`), func(context.Context, wsadapters.MessageType, []byte) {})
+		}()
+	}
+	wg.Wait()
+	require.Equal(suite.T(), uint64(100), clock.Now())
+}
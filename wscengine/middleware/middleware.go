@@ -0,0 +1,48 @@
+// The package defines middlewares which can be used to process received messages before they are
+// handed over to the user provided OnMessage callback.
+package middleware
+
+import (
+	"context"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Function which handles a received message.
+type MessageHandler func(ctx context.Context, msgType wsadapters.MessageType, msg []byte)
+
+// Middleware which processes a received message and decides whether and how the message is handed
+// over to the next handler in the chain.
+//
+// A middleware can inspect or transform the message, enrich the context or drop the message by not
+// calling next. A middleware must call next at most once.
+type MessageMiddleware func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next MessageHandler)
+
+// # Description
+//
+// Compose the provided middlewares and handler into a single handler. Middlewares are called in
+// the order they are provided: the first middleware is the outermost one and the last middleware
+// calls the provided handler when it calls next.
+//
+// # Inputs
+//
+//   - handler: Innermost handler which is called by the last middleware.
+//   - middlewares: Middlewares to compose. Nil middlewares are skipped.
+//
+// # Returns
+//
+// A handler which runs the whole middleware chain.
+func Chain(handler MessageHandler, middlewares ...MessageMiddleware) MessageHandler {
+	// Wrap handler starting from the innermost middleware
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		mw := middlewares[i]
+		if mw == nil {
+			continue
+		}
+		next := handler
+		handler = func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+			mw(ctx, msgType, msg, next)
+		}
+	}
+	return handler
+}
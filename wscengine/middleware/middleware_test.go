@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for middleware chain unit tests
+type MiddlewareUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run MiddlewareUnitTestSuite test suite
+func TestMiddlewareUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Chain calls middlewares in order and ends with the handler.
+func (suite *MiddlewareUnitTestSuite) TestChainOrder() {
+	calls := []string{}
+	record := func(name string) MessageMiddleware {
+		return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next MessageHandler) {
+			calls = append(calls, name)
+			next(ctx, msgType, append(msg, []byte(name)...))
+		}
+	}
+	var received []byte
+	handler := Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		calls = append(calls, "handler")
+		received = msg
+	}, record("a"), nil, record("b"))
+	handler(context.Background(), wsadapters.Text, []byte("msg-"))
+	require.Equal(suite.T(), []string{"a", "b", "handler"}, calls)
+	require.Equal(suite.T(), "msg-ab", string(received))
+}
+
+// Test a middleware can drop a message by not calling next.
+func (suite *MiddlewareUnitTestSuite) TestChainDrop() {
+	called := false
+	handler := Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		called = true
+	}, func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next MessageHandler) {})
+	handler(context.Background(), wsadapters.Text, []byte("msg"))
+	require.False(suite.T(), called)
+}
+
+// Test session ID context helpers.
+func (suite *MiddlewareUnitTestSuite) TestSessionIdContext() {
+	require.Empty(suite.T(), SessionIdFromContext(context.Background()))
+	ctx := ContextWithSessionId(context.Background(), "session")
+	require.Equal(suite.T(), "session", SessionIdFromContext(ctx))
+}
@@ -0,0 +1,255 @@
+// The package contains a middleware which throttles received messages per topic/channel so topics
+// with a high message rate do not starve the others.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"golang.org/x/time/rate"
+)
+
+// Default number of messages buffered per topic when OnLimitBuffer is used
+const defaultBufferSize = 100
+
+// Function which extracts the topic/channel of a message. An empty string can be returned when
+// the topic cannot be extracted: the message is then handed over without throttling.
+type TopicExtractor func(msg []byte) string
+
+// Strategy used when a message exceeds the limit of its topic.
+type LimitStrategy int
+
+const (
+	// Messages exceeding the limit of their topic are dropped.
+	OnLimitDrop LimitStrategy = iota
+	// Messages exceeding the limit of their topic are buffered and handed over to the next handler
+	// once the limit allows it. Messages are dropped when the topic buffer is full.
+	OnLimitBuffer
+)
+
+// Options of the PerTopicRateLimiter.
+type PerTopicRateLimiterOptions struct {
+	// Messages per second allowed for topics which have no limit set with SetTopicLimit. Defaults
+	// to 0 (= no limit).
+	DefaultRPS float64
+	// Burst allowed for topics which have no limit set with SetTopicLimit. Defaults to 1.
+	DefaultBurst int
+	// Strategy used when a message exceeds the limit of its topic. Defaults to OnLimitDrop.
+	Strategy LimitStrategy
+	// Max. number of messages buffered per topic when Strategy is OnLimitBuffer. Defaults to 100.
+	BufferSize int
+}
+
+// Middleware which applies a rate limit per topic. Limiters are created lazily the first time a
+// topic is seen and can be adjusted at any time with SetTopicLimit.
+type PerTopicRateLimiter struct {
+	// Function used to extract message topics
+	extractor TopicExtractor
+	// Options with defaults applied
+	opts PerTopicRateLimiterOptions
+	// Limiters by topic (*topicLimiter)
+	limiters sync.Map
+	// Number of dropped messages
+	dropped atomic.Uint64
+	// Context canceled to stop the buffer goroutines
+	stopCtx context.Context
+	// Function used to cancel stopCtx
+	stop context.CancelFunc
+	// Used to wait for buffer goroutines to exit
+	wg sync.WaitGroup
+}
+
+// Limiter and buffer of a single topic.
+type topicLimiter struct {
+	// Rate limiter of the topic
+	limiter *rate.Limiter
+	// Buffered messages - nil when Strategy is OnLimitDrop
+	queue chan bufferedMessage
+	// Number of buffered messages which have not been handed over yet
+	pending atomic.Int64
+}
+
+// A message waiting in a topic buffer.
+type bufferedMessage struct {
+	ctx     context.Context
+	msgType wsadapters.MessageType
+	msg     []byte
+	next    middleware.MessageHandler
+}
+
+// # Description
+//
+// Factory which creates a new PerTopicRateLimiter.
+//
+// # Inputs
+//
+//   - extractor: Function used to extract the topic of messages. Required.
+//   - opts: Options. Zero values are replaced by defaults.
+//
+// # Returns
+//
+// A new PerTopicRateLimiter or an error if extractor is nil.
+func NewPerTopicRateLimiter(extractor TopicExtractor, opts PerTopicRateLimiterOptions) (*PerTopicRateLimiter, error) {
+	if extractor == nil {
+		return nil, fmt.Errorf("provided topic extractor is nil")
+	}
+	// Apply defaults
+	if opts.DefaultBurst <= 0 {
+		opts.DefaultBurst = 1
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &PerTopicRateLimiter{
+		extractor: extractor,
+		opts:      opts,
+		stopCtx:   stopCtx,
+		stop:      stop,
+	}, nil
+}
+
+// # Description
+//
+// Create a middleware which throttles received messages per topic. See NewPerTopicRateLimiter.
+//
+// The underlying rate limiter lives as long as the process. Use NewPerTopicRateLimiter to be able
+// to adjust topic limits and to stop it.
+func PerTopicRateLimiterMiddleware(extractor TopicExtractor, opts PerTopicRateLimiterOptions) (middleware.MessageMiddleware, error) {
+	limiter, err := NewPerTopicRateLimiter(extractor, opts)
+	if err != nil {
+		return nil, err
+	}
+	return limiter.Middleware, nil
+}
+
+// # Description
+//
+// Middleware which hands over the message to the next handler if the limit of its topic allows
+// it. Otherwise, the message is dropped or buffered depending on the configured strategy.
+//
+// Buffered messages are handed over to the next handler by a goroutine dedicated to the topic,
+// with a context which is not canceled when the provided context is. Order of messages is
+// preserved within a topic.
+func (limiter *PerTopicRateLimiter) Middleware(
+	ctx context.Context,
+	msgType wsadapters.MessageType,
+	msg []byte,
+	next middleware.MessageHandler) {
+	topic := limiter.extractor(msg)
+	if topic == "" {
+		next(ctx, msgType, msg)
+		return
+	}
+	tl := limiter.getOrCreate(topic)
+	if tl.queue == nil {
+		if tl.limiter.Allow() {
+			next(ctx, msgType, msg)
+		} else {
+			limiter.dropped.Add(1)
+		}
+		return
+	}
+	// Bypass the buffer only if it is empty so order is preserved
+	if tl.pending.Load() == 0 && tl.limiter.Allow() {
+		next(ctx, msgType, msg)
+		return
+	}
+	tl.pending.Add(1)
+	select {
+	case tl.queue <- bufferedMessage{ctx: context.WithoutCancel(ctx), msgType: msgType, msg: msg, next: next}:
+	default:
+		// Buffer is full
+		tl.pending.Add(-1)
+		limiter.dropped.Add(1)
+	}
+}
+
+// # Description
+//
+// Set the limit of the provided topic. The limit applies immediately, including to messages
+// already buffered for the topic.
+//
+// # Inputs
+//
+//   - topic: Topic to set the limit of.
+//   - rps: Messages per second allowed for the topic. A value of 0 or less disables the limit.
+//   - burst: Max. number of messages allowed at once.
+func (limiter *PerTopicRateLimiter) SetTopicLimit(topic string, rps float64, burst int) {
+	tl := limiter.getOrCreate(topic)
+	tl.limiter.SetBurst(burst)
+	tl.limiter.SetLimit(toLimit(rps))
+}
+
+// # Description
+//
+// Return the number of messages dropped because their topic limit was exceeded or their topic
+// buffer was full.
+func (limiter *PerTopicRateLimiter) Dropped() uint64 {
+	return limiter.dropped.Load()
+}
+
+// # Description
+//
+// Stop the buffer goroutines. Messages which are still buffered are dropped.
+func (limiter *PerTopicRateLimiter) Close() error {
+	limiter.stop()
+	limiter.wg.Wait()
+	return nil
+}
+
+// Return the limiter of the provided topic. The limiter is created with the default limit and its
+// buffer goroutine started if it does not exist.
+func (limiter *PerTopicRateLimiter) getOrCreate(topic string) *topicLimiter {
+	if tl, ok := limiter.limiters.Load(topic); ok {
+		return tl.(*topicLimiter)
+	}
+	tl := &topicLimiter{
+		limiter: rate.NewLimiter(toLimit(limiter.opts.DefaultRPS), limiter.opts.DefaultBurst),
+	}
+	if limiter.opts.Strategy == OnLimitBuffer {
+		tl.queue = make(chan bufferedMessage, limiter.opts.BufferSize)
+	}
+	actual, loaded := limiter.limiters.LoadOrStore(topic, tl)
+	if !loaded && tl.queue != nil {
+		limiter.wg.Add(1)
+		go limiter.drain(tl)
+	}
+	return actual.(*topicLimiter)
+}
+
+// Hand buffered messages over to the next handler once the topic limit allows it.
+func (limiter *PerTopicRateLimiter) drain(tl *topicLimiter) {
+	defer limiter.wg.Done()
+	for {
+		select {
+		case <-limiter.stopCtx.Done():
+			return
+		case buffered := <-tl.queue:
+			if err := tl.limiter.Wait(limiter.stopCtx); err != nil {
+				if limiter.stopCtx.Err() != nil {
+					// Limiter has been stopped
+					return
+				}
+				// Topic limit does not allow any message (burst is 0)
+				tl.pending.Add(-1)
+				limiter.dropped.Add(1)
+				continue
+			}
+			buffered.next(buffered.ctx, buffered.msgType, buffered.msg)
+			tl.pending.Add(-1)
+		}
+	}
+}
+
+// Convert a number of messages per second to a rate limit. Values of 0 or less disable the limit.
+func toLimit(rps float64) rate.Limit {
+	if rps <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(rps)
+}
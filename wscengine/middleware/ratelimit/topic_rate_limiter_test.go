@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for PerTopicRateLimiter unit tests
+type PerTopicRateLimiterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run PerTopicRateLimiterUnitTestSuite test suite
+func TestPerTopicRateLimiterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PerTopicRateLimiterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test messages exceeding a topic limit are dropped without affecting other topics.
+func (suite *PerTopicRateLimiterUnitTestSuite) TestDropStrategy() {
+	limiter, err := NewPerTopicRateLimiter(prefixExtractor, PerTopicRateLimiterOptions{})
+	require.NoError(suite.T(), err)
+	defer limiter.Close()
+	limiter.SetTopicLimit("fast", 0.001, 2)
+	recorder := &handlerRecorder{}
+	for i := 0; i < 5; i++ {
+		limiter.Middleware(context.Background(), wsadapters.Text, []byte("fast:msg"), recorder.handle)
+		limiter.Middleware(context.Background(), wsadapters.Text, []byte("slow:msg"), recorder.handle)
+		limiter.Middleware(context.Background(), wsadapters.Text, []byte("no topic"), recorder.handle)
+	}
+	// Only the burst is allowed for the limited topic - other topics have no limit by default
+	require.Equal(suite.T(), 2, recorder.count("fast:msg"))
+	require.Equal(suite.T(), 5, recorder.count("slow:msg"))
+	require.Equal(suite.T(), 5, recorder.count("no topic"))
+	require.Equal(suite.T(), uint64(3), limiter.Dropped())
+	// Removing the limit lets messages through
+	limiter.SetTopicLimit("fast", 0, 1)
+	limiter.Middleware(context.Background(), wsadapters.Text, []byte("fast:msg"), recorder.handle)
+	require.Equal(suite.T(), 3, recorder.count("fast:msg"))
+}
+
+// Test messages exceeding a topic limit are buffered and handed over in order.
+func (suite *PerTopicRateLimiterUnitTestSuite) TestBufferStrategy() {
+	limiter, err := NewPerTopicRateLimiter(prefixExtractor, PerTopicRateLimiterOptions{
+		DefaultRPS:   100,
+		DefaultBurst: 1,
+		Strategy:     OnLimitBuffer,
+		BufferSize:   5,
+	})
+	require.NoError(suite.T(), err)
+	defer limiter.Close()
+	recorder := &handlerRecorder{}
+	// Context is canceled after messages are buffered - buffered messages are still handed over
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 8; i++ {
+		limiter.Middleware(ctx, wsadapters.Text, []byte("topic:"+string(rune('a'+i))), recorder.handle)
+	}
+	cancel()
+	// First message is allowed, others are buffered until the buffer is full. Every message is
+	// either handed over in order or dropped.
+	expected := []string{"topic:a", "topic:b", "topic:c", "topic:d", "topic:e", "topic:f", "topic:g", "topic:h"}
+	require.Eventually(suite.T(), func() bool {
+		return uint64(len(recorder.messages()))+limiter.Dropped() == 8
+	}, 5*time.Second, 10*time.Millisecond)
+	delivered := recorder.messages()
+	require.GreaterOrEqual(suite.T(), len(delivered), 6)
+	require.Equal(suite.T(), expected[:len(delivered)], delivered)
+}
+
+// Test buffered messages are dropped when the limiter is closed.
+func (suite *PerTopicRateLimiterUnitTestSuite) TestCloseWithBufferedMessages() {
+	limiter, err := NewPerTopicRateLimiter(prefixExtractor, PerTopicRateLimiterOptions{Strategy: OnLimitBuffer})
+	require.NoError(suite.T(), err)
+	limiter.SetTopicLimit("topic", 0.001, 1)
+	recorder := &handlerRecorder{}
+	limiter.Middleware(context.Background(), wsadapters.Text, []byte("topic:a"), recorder.handle)
+	limiter.Middleware(context.Background(), wsadapters.Text, []byte("topic:b"), recorder.handle)
+	require.NoError(suite.T(), limiter.Close())
+	require.Equal(suite.T(), []string{"topic:a"}, recorder.messages())
+}
+
+// Test factories reject a nil extractor.
+func (suite *PerTopicRateLimiterUnitTestSuite) TestInvalidParameters() {
+	_, err := NewPerTopicRateLimiter(nil, PerTopicRateLimiterOptions{})
+	require.Error(suite.T(), err)
+	_, err = PerTopicRateLimiterMiddleware(nil, PerTopicRateLimiterOptions{})
+	require.Error(suite.T(), err)
+	mw, err := PerTopicRateLimiterMiddleware(prefixExtractor, PerTopicRateLimiterOptions{})
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), mw)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Topic extractor which returns the message part before the first ':'
+func prefixExtractor(msg []byte) string {
+	topic, _, found := strings.Cut(string(msg), ":")
+	if !found {
+		return ""
+	}
+	return topic
+}
+
+// Handler which records received messages
+type handlerRecorder struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (recorder *handlerRecorder) handle(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.received = append(recorder.received, string(msg))
+}
+
+func (recorder *handlerRecorder) messages() []string {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]string(nil), recorder.received...)
+}
+
+func (recorder *handlerRecorder) count(msg string) int {
+	count := 0
+	for _, received := range recorder.messages() {
+		if received == msg {
+			count++
+		}
+	}
+	return count
+}
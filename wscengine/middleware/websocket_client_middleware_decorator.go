@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// A decorator which runs received messages through a middleware chain before the decorated
+// OnMessage callback is called. Other callbacks are forwarded as is to the decorated client.
+type WebsocketClientMiddlewareDecorator struct {
+	// Decorated WebsocketClientInterface implementation
+	decorated wsclient.WebsocketClientInterface
+	// Middlewares applied to received messages
+	middlewares []MessageMiddleware
+}
+
+// # Description
+//
+// Build and return a new decorator which runs received messages through the provided middlewares
+// before calling the decorated OnMessage callback.
+//
+// # Inputs
+//
+//   - decorated: The WebsocketClientInterface implementation to decorate. Must not be nil.
+//   - middlewares: Middlewares to apply, in order, to each received message.
+//
+// # Returns
+//
+// A new decorator or an error if decorated is nil.
+func NewWebsocketClientMiddlewareDecorator(
+	decorated wsclient.WebsocketClientInterface,
+	middlewares ...MessageMiddleware,
+) (*WebsocketClientMiddlewareDecorator, error) {
+	if decorated == nil {
+		// Return an error if decorated is nil
+		return nil, fmt.Errorf("provided decorated is nil")
+	}
+	// Build and return decorator
+	return &WebsocketClientMiddlewareDecorator{
+		decorated:   decorated,
+		middlewares: middlewares,
+	}, nil
+}
+
+// Forward OnOpen call to decorated
+func (decorator *WebsocketClientMiddlewareDecorator) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	return decorator.decorated.OnOpen(ctx, resp, conn, readMutex, exit, sessionId, restarting)
+}
+
+// Run the received message through the middleware chain and call decorated OnMessage at the end
+// of the chain. The session ID is made available to middlewares through the context.
+func (decorator *WebsocketClientMiddlewareDecorator) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	// Build chain which ends with the decorated OnMessage
+	handler := Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		decorator.decorated.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	}, decorator.middlewares...)
+	// Run chain
+	handler(ContextWithSessionId(ctx, sessionId), msgType, msg)
+}
+
+// Forward OnReadError call to decorated
+func (decorator *WebsocketClientMiddlewareDecorator) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+	decorator.decorated.OnReadError(ctx, conn, readMutex, restart, exit, sessionId, err)
+}
+
+// Forward OnClose call to decorated
+func (decorator *WebsocketClientMiddlewareDecorator) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	return decorator.decorated.OnClose(ctx, conn, readMutex, sessionId, closeMessage)
+}
+
+// Forward OnCloseError call to decorated
+func (decorator *WebsocketClientMiddlewareDecorator) OnCloseError(
+	ctx context.Context,
+	sessionId string,
+	err error) {
+	decorator.decorated.OnCloseError(ctx, sessionId, err)
+}
+
+// Forward OnRestartError call to decorated
+func (decorator *WebsocketClientMiddlewareDecorator) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+	decorator.decorated.OnRestartError(ctx, exit, sessionId, err, retryCount)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for WebsocketClientMiddlewareDecorator unit tests
+type WebsocketClientMiddlewareDecoratorUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run WebsocketClientMiddlewareDecoratorUnitTestSuite test suite
+func TestWebsocketClientMiddlewareDecoratorUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(WebsocketClientMiddlewareDecoratorUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails when decorated is nil.
+func (suite *WebsocketClientMiddlewareDecoratorUnitTestSuite) TestFactoryWithNilDecorated() {
+	decorator, err := NewWebsocketClientMiddlewareDecorator(nil)
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), decorator)
+}
+
+// Test OnMessage runs the middleware chain before calling decorated OnMessage.
+func (suite *WebsocketClientMiddlewareDecoratorUnitTestSuite) TestOnMessage() {
+	// Create mock and decorator with a middleware which transforms the message
+	clientMock := wsclient.NewWebsocketClientMock()
+	clientMock.On("OnMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		"session", wsadapters.Text, []byte("transformed")).Return()
+	var sessionId string
+	decorator, err := NewWebsocketClientMiddlewareDecorator(clientMock,
+		func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next MessageHandler) {
+			sessionId = SessionIdFromContext(ctx)
+			next(ctx, msgType, []byte("transformed"))
+		})
+	require.NoError(suite.T(), err)
+	// Call OnMessage
+	decorator.OnMessage(context.Background(), nil, &sync.Mutex{}, func() {}, func() {}, "session", wsadapters.Text, []byte("msg"))
+	clientMock.AssertNumberOfCalls(suite.T(), "OnMessage", 1)
+	require.Equal(suite.T(), "session", sessionId)
+}
+
+// Test OnMessage does not call decorated OnMessage when a middleware drops the message.
+func (suite *WebsocketClientMiddlewareDecoratorUnitTestSuite) TestOnMessageDropped() {
+	clientMock := wsclient.NewWebsocketClientMock()
+	decorator, err := NewWebsocketClientMiddlewareDecorator(clientMock,
+		func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next MessageHandler) {})
+	require.NoError(suite.T(), err)
+	decorator.OnMessage(context.Background(), nil, &sync.Mutex{}, func() {}, func() {}, "session", wsadapters.Text, []byte("msg"))
+	clientMock.AssertNotCalled(suite.T(), "OnMessage")
+}
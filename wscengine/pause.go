@@ -0,0 +1,233 @@
+package wscengine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Message received while the engine is paused and held until the engine resumes.
+type heldMessage struct {
+	// Context of the goroutine which has read the message
+	ctx context.Context
+	// Context bound to the lifetime of the session the message has been received on
+	sessionCtx context.Context
+	// Function which cancels the session the message has been received on
+	cancelSession context.CancelFunc
+	// Wait group which tracks the OnMessage callbacks of the session the message has been
+	// received on
+	inFlightMessages *sync.WaitGroup
+	// ID of the session the message has been received on
+	sessionId string
+	// Message type
+	msgType wsadapters.MessageType
+	// Message content
+	msg []byte
+}
+
+// Gate which holds the messages received while the engine is paused.
+type pauseGate struct {
+	// Mutex used to protect all other fields
+	mu sync.Mutex
+	// Flag set between Pause and Resume
+	paused bool
+	// Flag set while the held messages are delivered after Resume
+	draining bool
+	// Incremented by each Resume - Used to stop the delivery started by a previous Resume
+	generation uint64
+	// Messages held in reception order
+	held []heldMessage
+	// Channel closed and replaced each time the gate changes - Wakes up the goroutines which wait
+	// for room in the buffer
+	changed chan struct{}
+}
+
+// Create a new, open pauseGate.
+func newPauseGate() *pauseGate {
+	return &pauseGate{
+		held:    []heldMessage{},
+		changed: make(chan struct{}),
+	}
+}
+
+// Wake up the goroutines which wait for the gate to change. Must be called with mu locked.
+func (gate *pauseGate) notify() {
+	close(gate.changed)
+	gate.changed = make(chan struct{})
+}
+
+// # Description
+//
+// Pause the processing of messages. While the engine is paused, the engine goes on reading the
+// connection so control frames are processed and the connection is kept alive, but OnMessage is
+// not called: received messages are held in reception order until Resume is called. Messages
+// larger than the stream threshold are read in memory and held like other messages: they are
+// handed over to OnMessage when the engine resumes.
+//
+// When the number of held messages reaches the limit set with WithPauseBuffer, the engine stops
+// reading until Resume is called, which in turn applies backpressure on the server. Held messages
+// are kept when the engine restarts: they are handed over with the ID of the session they have
+// been received on.
+//
+// The engine can be paused before it starts and the method can be called from inside callbacks.
+//
+// # Returns
+//
+// ErrEngineAlreadyPaused if the engine is already paused or the context error if the provided
+// context is done.
+func (wsengine *WebsocketEngine) Pause(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	gate := wsengine.pauseGate
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	if gate.paused {
+		return ErrEngineAlreadyPaused
+	}
+	// Pausing while held messages are delivered stops their delivery: remaining ones are kept
+	gate.paused = true
+	gate.draining = false
+	gate.notify()
+	wsengine.logger.InfoContext(ctx, "websocket engine paused")
+	return nil
+}
+
+// # Description
+//
+// Resume the processing of messages paused with Pause. The held messages are handed over to
+// OnMessage in reception order by a background goroutine, before the messages received after the
+// engine has resumed. The method does not wait for the held messages to be processed.
+//
+// # Returns
+//
+// ErrEngineNotPaused if the engine is not paused or the context error if the provided context is
+// done.
+func (wsengine *WebsocketEngine) Resume(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	gate := wsengine.pauseGate
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	if !gate.paused {
+		return ErrEngineNotPaused
+	}
+	gate.paused = false
+	gate.draining = true
+	gate.generation++
+	gate.notify()
+	wsengine.logger.InfoContext(ctx, "websocket engine resumed", logKeyHeldMessages, len(gate.held))
+	go wsengine.deliverHeldMessages(gate.generation)
+	return nil
+}
+
+// # Description
+//
+// Return true if the engine is paused.
+func (wsengine *WebsocketEngine) IsPaused() bool {
+	wsengine.pauseGate.mu.Lock()
+	defer wsengine.pauseGate.mu.Unlock()
+	return wsengine.pauseGate.paused
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Return true if received messages must be held because the engine is paused or held messages
+// are being delivered.
+func (wsengine *WebsocketEngine) isHoldingMessages() bool {
+	wsengine.pauseGate.mu.Lock()
+	defer wsengine.pauseGate.mu.Unlock()
+	return wsengine.pauseGate.paused || wsengine.pauseGate.draining
+}
+
+// # Description
+//
+// Hold the provided message if the engine is paused or if held messages are being delivered.
+// When the buffer is full, wait until there is room in the buffer, until the gate opens or until
+// the session ends. A message received on a session which ends while the goroutine waits is held
+// anyway so it is not lost.
+//
+// # Returns
+//
+// True if the message has been held, false if the message must be handed over to OnMessage.
+func (wsengine *WebsocketEngine) holdMessage(sessionCtx context.Context, msg heldMessage) bool {
+	gate := wsengine.pauseGate
+	for {
+		gate.mu.Lock()
+		if !gate.paused && !gate.draining {
+			gate.mu.Unlock()
+			return false
+		}
+		if len(gate.held) < wsengine.engineCfgOpts.PauseBufferSize {
+			gate.held = append(gate.held, msg)
+			gate.mu.Unlock()
+			return true
+		}
+		// Buffer is full - wait for the gate to change
+		changed := gate.changed
+		gate.mu.Unlock()
+		select {
+		case <-changed:
+		case <-sessionCtx.Done():
+			gate.mu.Lock()
+			defer gate.mu.Unlock()
+			if !gate.paused && !gate.draining {
+				return false
+			}
+			gate.held = append(gate.held, msg)
+			return true
+		}
+	}
+}
+
+// # Description
+//
+// Hand over the held messages to OnMessage in reception order until there are no more held
+// messages or until the engine is paused again. Messages are dispatched like the messages read by
+// the engine goroutines: they take a message slot if concurrent messages are limited and they are
+// tracked so the graceful drain waits for them.
+//
+// Held messages keep the session they have been received on. A message held across a restart is
+// handed over with the ID, the context and the restart function of the ended session: the restart
+// function has no effect and, as the session has ended, the message is processed immediately by
+// the delivery goroutine without taking a slot and it is not tracked by the drain.
+//
+// # Inputs
+//
+//   - generation: Generation of the Resume call which has started the delivery.
+func (wsengine *WebsocketEngine) deliverHeldMessages(generation uint64) {
+	gate := wsengine.pauseGate
+	for {
+		gate.mu.Lock()
+		if !gate.draining || gate.generation != generation {
+			// Engine has been paused again
+			gate.mu.Unlock()
+			return
+		}
+		if len(gate.held) == 0 {
+			gate.draining = false
+			gate.notify()
+			gate.mu.Unlock()
+			return
+		}
+		msg := gate.held[0]
+		gate.held = gate.held[1:]
+		gate.notify()
+		gate.mu.Unlock()
+		// Track the message if its session is still running so shutdown waits for its processing
+		tracked := msg.sessionCtx.Err() == nil
+		if tracked {
+			msg.inFlightMessages.Add(1)
+		}
+		wsengine.dispatchMessage(msg.sessionCtx, func() {
+			wsengine.wsclient.OnMessage(msg.ctx, wsengine.conn, wsengine.readMutex, msg.cancelSession, wsengine.engineStopFunc, msg.sessionId, msg.msgType, msg.msg)
+			if tracked {
+				msg.inFlightMessages.Done()
+			}
+		})
+	}
+}
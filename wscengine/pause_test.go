@@ -0,0 +1,183 @@
+package wscengine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine pause unit tests
+type PauseUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run PauseUnitTestSuite test suite
+func TestPauseUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PauseUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Pause and Resume return an error when the engine is already paused or not paused.
+func (suite *PauseUnitTestSuite) TestPauseResumeErrors() {
+	engine, _, _ := suite.newEngine(NewWebsocketEngineConfigurationOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	require.ErrorIs(suite.T(), engine.Resume(ctx), ErrEngineNotPaused)
+	require.NoError(suite.T(), engine.Pause(ctx))
+	require.True(suite.T(), engine.IsPaused())
+	require.ErrorIs(suite.T(), engine.Pause(ctx), ErrEngineAlreadyPaused)
+	require.NoError(suite.T(), engine.Resume(ctx))
+	require.False(suite.T(), engine.IsPaused())
+	cancel()
+	require.ErrorIs(suite.T(), engine.Pause(ctx), context.Canceled)
+	require.ErrorIs(suite.T(), engine.Resume(ctx), context.Canceled)
+}
+
+// Test messages received while the engine is paused are held and handed over to OnMessage in
+// reception order when the engine resumes, before the messages received afterwards.
+func (suite *PauseUnitTestSuite) TestMessagesHeldWhilePaused() {
+	engine, adapter, client := suite.newEngine(NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	require.NoError(suite.T(), engine.Pause(ctx))
+	for _, msg := range []string{"1", "2", "3"} {
+		adapter.EnqueueMessage(wsadapters.Text, []byte(msg))
+	}
+	// Messages are read but not handed over to OnMessage
+	require.Eventually(suite.T(), func() bool {
+		return suite.heldCount(engine) == 3
+	}, 5*time.Second, time.Millisecond)
+	require.Empty(suite.T(), client.RecordedOnMessages())
+	require.NoError(suite.T(), engine.Resume(ctx))
+	adapter.EnqueueMessage(wsadapters.Text, []byte("4"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 4, 5*time.Second))
+	for index, call := range client.RecordedOnMessages() {
+		require.Equal(suite.T(), []byte{byte('1' + index)}, call.Msg)
+	}
+	require.Zero(suite.T(), suite.heldCount(engine))
+}
+
+// Test the engine stops reading when the pause buffer is full and reads the remaining messages
+// once it resumes.
+func (suite *PauseUnitTestSuite) TestPauseBufferBackpressure() {
+	engine, adapter, client := suite.newEngine(NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithPauseBuffer(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Pause(ctx))
+	// Engine can be paused before it starts
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	for _, msg := range []string{"1", "2", "3"} {
+		adapter.EnqueueMessage(wsadapters.Text, []byte(msg))
+	}
+	// First message is held, the goroutine waits with the second one and the third is not read
+	require.Eventually(suite.T(), func() bool {
+		return suite.heldCount(engine) == 1
+	}, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(suite.T(), 1, suite.heldCount(engine))
+	require.Empty(suite.T(), client.RecordedOnMessages())
+	require.NoError(suite.T(), engine.Resume(ctx))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 3, 5*time.Second))
+	for index, call := range client.RecordedOnMessages() {
+		require.Equal(suite.T(), []byte{byte('1' + index)}, call.Msg)
+	}
+}
+
+// Test held messages take a message slot when concurrent messages are limited.
+func (suite *PauseUnitTestSuite) TestHeldMessagesTakeSlots() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newSlowClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithMaxConcurrentMessages(2)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	require.NoError(suite.T(), engine.Pause(ctx))
+	for i := 0; i < 4; i++ {
+		adapter.EnqueueMessage(wsadapters.Text, []byte(fmt.Sprint(i)))
+	}
+	require.Eventually(suite.T(), func() bool {
+		return suite.heldCount(engine) == 4
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), engine.Resume(ctx))
+	// Two held messages are processed at once - the other ones wait for a free slot
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 2, 5*time.Second))
+	time.Sleep(20 * time.Millisecond)
+	require.Len(suite.T(), client.RecordedOnMessages(), 2)
+	close(client.release)
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 4, 5*time.Second))
+	require.Equal(suite.T(), int32(2), client.maxRunning.Load())
+}
+
+// Test the graceful drain waits for the held messages which are being processed.
+func (suite *PauseUnitTestSuite) TestDrainWaitsForHeldMessages() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newDrainClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithDrainTimeout(5 * time.Second)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.NoError(suite.T(), engine.Pause(ctx))
+	adapter.EnqueueMessage(wsadapters.Text, []byte("block"))
+	require.Eventually(suite.T(), func() bool {
+		return suite.heldCount(engine) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), engine.Resume(ctx))
+	<-client.started
+	// OnClose is not called while the held message is processed
+	stopped := make(chan error, 1)
+	go func() { stopped <- engine.Stop(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(suite.T(), client.RecordedOnCloses())
+	close(client.release)
+	require.NoError(suite.T(), <-stopped)
+	require.Equal(suite.T(), []string{"processed", "closed"}, client.events())
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Create an engine which uses a mock adapter and a recording client
+func (suite *PauseUnitTestSuite) newEngine(opts *WebsocketEngineConfigurationOptions) (*WebsocketEngine, *mock.MockWebsocketConnectionAdapter, *wstest.RecordingClient) {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine, adapter, client
+}
+
+// Return the number of messages held by the engine
+func (suite *PauseUnitTestSuite) heldCount(engine *WebsocketEngine) int {
+	engine.pauseGate.mu.Lock()
+	defer engine.pauseGate.mu.Unlock()
+	return len(engine.pauseGate.held)
+}
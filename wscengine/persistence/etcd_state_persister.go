@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Default timeout for etcd operations
+const defaultEtcdTimeout = 5 * time.Second
+
+// StatePersister implementation which persists the engine state as a JSON value in etcd.
+type EtcdStatePersister struct {
+	// etcd KV API - *clientv3.Client implements it
+	kv clientv3.KV
+	// Key used to store the state
+	key string
+	// Timeout applied to each etcd operation
+	timeout time.Duration
+}
+
+// # Description
+//
+// Factory which creates a new EtcdStatePersister which persists the engine state under the
+// provided key.
+//
+// # Inputs
+//
+//   - client: etcd client (or any implementation of the KV API) to use. Must not be nil.
+//   - key: Key used to store the state. Must not be empty.
+//
+// # Returns
+//
+// A new EtcdStatePersister or an error if inputs are invalid.
+func NewEtcdStatePersister(client clientv3.KV, key string) (*EtcdStatePersister, error) {
+	if client == nil {
+		return nil, fmt.Errorf("provided etcd client is nil")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("provided key is empty")
+	}
+	return &EtcdStatePersister{
+		kv:      client,
+		key:     key,
+		timeout: defaultEtcdTimeout,
+	}, nil
+}
+
+// Persist the state under the persister key.
+func (persister *EtcdStatePersister) Save(state EngineState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode engine state: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), persister.timeout)
+	defer cancel()
+	_, err = persister.kv.Put(ctx, persister.key, string(content))
+	if err != nil {
+		return fmt.Errorf("failed to save engine state in etcd: %w", err)
+	}
+	return nil
+}
+
+// Load the state stored under the persister key. ErrNoState is returned if the key does not exist.
+func (persister *EtcdStatePersister) Load() (EngineState, error) {
+	state := EngineState{}
+	ctx, cancel := context.WithTimeout(context.Background(), persister.timeout)
+	defer cancel()
+	resp, err := persister.kv.Get(ctx, persister.key)
+	if err != nil {
+		return state, fmt.Errorf("failed to load engine state from etcd: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return state, ErrNoState
+	}
+	err = json.Unmarshal(resp.Kvs[0].Value, &state)
+	if err != nil {
+		return EngineState{}, fmt.Errorf("failed to decode engine state: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,93 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for EtcdStatePersister unit tests
+type EtcdStatePersisterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run EtcdStatePersisterUnitTestSuite test suite
+func TestEtcdStatePersisterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EtcdStatePersisterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Save and then Load.
+func (suite *EtcdStatePersisterUnitTestSuite) TestSaveAndLoad() {
+	kv := &etcdKVStub{values: map[string]string{}}
+	persister, err := NewEtcdStatePersister(kv, "/engine/state")
+	require.NoError(suite.T(), err)
+	_, err = persister.Load()
+	require.ErrorIs(suite.T(), err, ErrNoState)
+	expected := EngineState{TargetURL: "ws://localhost", LastSessionID: "session", RestartCount: 1}
+	require.NoError(suite.T(), persister.Save(expected))
+	state, err := persister.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), expected, state)
+}
+
+// Test etcd errors are returned.
+func (suite *EtcdStatePersisterUnitTestSuite) TestErrors() {
+	kv := &etcdKVStub{err: fmt.Errorf("unavailable")}
+	persister, err := NewEtcdStatePersister(kv, "/engine/state")
+	require.NoError(suite.T(), err)
+	require.Error(suite.T(), persister.Save(EngineState{}))
+	_, err = persister.Load()
+	require.Error(suite.T(), err)
+	require.NotErrorIs(suite.T(), err, ErrNoState)
+}
+
+// Test factory with invalid inputs.
+func (suite *EtcdStatePersisterUnitTestSuite) TestFactoryWithInvalidInputs() {
+	_, err := NewEtcdStatePersister(nil, "key")
+	require.Error(suite.T(), err)
+	_, err = NewEtcdStatePersister(&etcdKVStub{}, "")
+	require.Error(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// etcd KV stub which stores values in memory. Calling other methods than Put and Get panics.
+type etcdKVStub struct {
+	clientv3.KV
+	values map[string]string
+	err    error
+}
+
+func (stub *etcdKVStub) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	stub.values[key] = val
+	return &clientv3.PutResponse{}, nil
+}
+
+func (stub *etcdKVStub) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	resp := &clientv3.GetResponse{}
+	if val, ok := stub.values[key]; ok {
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte(key), Value: []byte(val)}}
+	}
+	return resp, nil
+}
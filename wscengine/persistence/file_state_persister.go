@@ -0,0 +1,78 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StatePersister implementation which persists the engine state as a JSON file.
+//
+// State is written to a temporary file which then replaces the state file so a crash while saving
+// never leaves a partially written state file.
+type FileStatePersister struct {
+	// Path to the state file
+	path string
+}
+
+// # Description
+//
+// Factory which creates a new FileStatePersister which persists the engine state in the provided
+// file. The file and its directory are created on first Save.
+func NewFileStatePersister(path string) *FileStatePersister {
+	return &FileStatePersister{path: path}
+}
+
+// Persist the state in the state file.
+func (persister *FileStatePersister) Save(state EngineState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode engine state: %w", err)
+	}
+	dir := filepath.Dir(persister.path)
+	err = os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create engine state directory: %w", err)
+	}
+	// Write a temporary file in the same directory so rename is atomic
+	tmp, err := os.CreateTemp(dir, filepath.Base(persister.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary engine state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temporary engine state file: %w", err)
+	}
+	err = os.Rename(tmp.Name(), persister.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace engine state file: %w", err)
+	}
+	return nil
+}
+
+// Load the state from the state file. ErrNoState is returned if the file does not exist.
+func (persister *FileStatePersister) Load() (EngineState, error) {
+	state := EngineState{}
+	content, err := os.ReadFile(persister.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, ErrNoState
+		}
+		return state, fmt.Errorf("failed to read engine state file: %w", err)
+	}
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return EngineState{}, fmt.Errorf("failed to decode engine state: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,61 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for FileStatePersister unit tests
+type FileStatePersisterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run FileStatePersisterUnitTestSuite test suite
+func TestFileStatePersisterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FileStatePersisterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Save and then Load.
+func (suite *FileStatePersisterUnitTestSuite) TestSaveAndLoad() {
+	persister := NewFileStatePersister(filepath.Join(suite.T().TempDir(), "nested", "state.json"))
+	// Load before any save
+	_, err := persister.Load()
+	require.ErrorIs(suite.T(), err, ErrNoState)
+	// Save twice and load
+	require.NoError(suite.T(), persister.Save(EngineState{TargetURL: "ws://first"}))
+	expected := EngineState{
+		TargetURL:     "ws://localhost",
+		LastSessionID: "session",
+		RestartCount:  2,
+		Subscriptions: []byte(`["ticker"]`),
+	}
+	require.NoError(suite.T(), persister.Save(expected))
+	state, err := persister.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), expected, state)
+	// No temporary file must be left
+	entries, err := os.ReadDir(filepath.Dir(persister.path))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), entries, 1)
+}
+
+// Test Load with a corrupted file.
+func (suite *FileStatePersisterUnitTestSuite) TestLoadCorruptedState() {
+	path := filepath.Join(suite.T().TempDir(), "state.json")
+	require.NoError(suite.T(), os.WriteFile(path, []byte("{"), 0o600))
+	_, err := NewFileStatePersister(path).Load()
+	require.Error(suite.T(), err)
+	require.NotErrorIs(suite.T(), err, ErrNoState)
+}
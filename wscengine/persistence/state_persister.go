@@ -0,0 +1,41 @@
+// The package contains the interface and implementations used by the websocket engine to persist
+// its state so it can be restored after a crash.
+package persistence
+
+import "errors"
+
+// Error returned by StatePersister.Load when there is no persisted state.
+var ErrNoState = errors.New("no persisted engine state")
+
+// State of the websocket engine which is persisted so it can be restored after a crash.
+type EngineState struct {
+	// Target websocket server URL.
+	TargetURL string `json:"targetUrl"`
+	// ID of the last session started by the engine.
+	LastSessionID string `json:"lastSessionId"`
+	// Number of times the engine has restarted.
+	RestartCount int `json:"restartCount"`
+	// Opaque blob provided by the user (for example by a subscription manager) which describes the
+	// active subscriptions.
+	Subscriptions []byte `json:"subscriptions,omitempty"`
+}
+
+// Interface for persisters which save and load the websocket engine state.
+type StatePersister interface {
+	// # Description
+	//
+	// Persist the provided engine state. The persisted state replaces the previous one.
+	//
+	// # Returns
+	//
+	// Nil in case of success or an error if the state could not be persisted.
+	Save(state EngineState) error
+	// # Description
+	//
+	// Load the last persisted engine state.
+	//
+	// # Returns
+	//
+	// The last persisted state or an error. ErrNoState is returned if no state has been persisted.
+	Load() (EngineState, error)
+}
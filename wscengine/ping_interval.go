@@ -0,0 +1,113 @@
+package wscengine
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// # Description
+//
+// Engine internal goroutine task which pings the server every PingInterval while the session is
+// up. The ping is skipped if a message has been read during the last interval.
+//
+// If a ping fails or times out, the connection is considered lost: the goroutine cancels the
+// session, shuts down the engine (OnClose callback + close connection + restart if applicable)
+// and exits. The underlying connection is then forcefully closed, if possible, so the engine
+// goroutines blocked on the lost connection are released.
+//
+// # Inputs
+//
+//   - sessionCtx: Context produced from engine context and bound to websocket connection lifetime.
+//   - cancelSession: Function to call to cancel session context and stop all other goroutines.
+//   - shutdownSync: Object used to ensure engine shutdown is performed exactly once.
+//   - sessionId: Id bound to the connection lifecycle. Used to correlate traces.
+func (wsengine *WebsocketEngine) runPingLoop(
+	sessionCtx context.Context,
+	cancelSession context.CancelFunc,
+	shutdownSync *sync.Once,
+	sessionId string) {
+	interval := wsengine.engineCfgOpts.PingInterval
+	timeout := wsengine.engineCfgOpts.PingTimeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sessionCtx.Done():
+			// Session has ended - exit
+			return
+		case <-ticker.C:
+			// Skip the ping if a message has been read during the last interval
+			if time.Since(time.Unix(0, wsengine.lastMessageAt.Load())) < interval {
+				continue
+			}
+			if !wsengine.ping(sessionCtx, cancelSession, shutdownSync, sessionId, timeout) {
+				return
+			}
+		}
+	}
+}
+
+// # Description
+//
+// Ping the server and handle a failed ping as a connection loss.
+//
+// # Returns
+//
+// True if the ping has succeeded, false if the session has ended.
+func (wsengine *WebsocketEngine) ping(
+	sessionCtx context.Context,
+	cancelSession context.CancelFunc,
+	shutdownSync *sync.Once,
+	sessionId string,
+	timeout time.Duration) bool {
+	// Start span with fresh context which carries the session ID
+	ctx, span := wsengine.tracer.Start(middleware.ContextWithSessionId(context.Background(), sessionId), spanEngineBackgroundPing,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(attrSessionId, sessionId),
+		))
+	defer span.End()
+	pingCtx, cancelPing := context.WithTimeout(sessionCtx, timeout)
+	err := wsengine.conn.Ping(pingCtx)
+	cancelPing()
+	if err == nil {
+		// Record the pong so the read idle watchdog knows the connection is alive
+		wsengine.lastPongAt.Store(time.Now().UnixNano())
+		span.SetStatus(codes.Ok, codes.Ok.String())
+		return true
+	}
+	// Ignore the error if the session has ended during the ping
+	select {
+	case <-sessionCtx.Done():
+		span.SetStatus(codes.Ok, codes.Ok.String())
+		return false
+	default:
+	}
+	// Connection is lost - shutdown the engine
+	span.RecordError(err)
+	span.AddEvent(eventPingFailed)
+	span.SetStatus(codes.Error, codes.Error.String())
+	wsengine.logger.WarnContext(ctx, "ping failed, connection is considered lost",
+		logKeySessionId, sessionId,
+		logKeyError, err)
+	// Keep a reference to the underlying connection: the adapter drops it when it is closed
+	underlying := wsengine.conn.GetUnderlyingWebsocketConnection()
+	cancelSession()
+	shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, false) })
+	// The peer may not answer the close message: close the underlying connection so pending
+	// reads on the lost connection return
+	if closer, ok := underlying.(io.Closer); ok {
+		closer.Close()
+	}
+	return false
+}
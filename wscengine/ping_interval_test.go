@@ -0,0 +1,117 @@
+package wscengine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine ping interval unit tests
+type PingIntervalUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run PingIntervalUnitTestSuite test suite
+func TestPingIntervalUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PingIntervalUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the engine pings the server periodically when no message is received.
+func (suite *PingIntervalUnitTestSuite) TestPingWhenIdle() {
+	adapter := &pingCountingAdapter{MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter()}
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithPingInterval(10*time.Millisecond, time.Second)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.Eventually(suite.T(), func() bool {
+		return adapter.pings.Load() >= 3
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), engine.Stop(ctx))
+}
+
+// Test the ping is skipped when messages are received during the interval.
+func (suite *PingIntervalUnitTestSuite) TestPingSkippedWhenMessagesAreReceived() {
+	adapter := &pingCountingAdapter{MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter()}
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithPingInterval(100*time.Millisecond, time.Second)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Keep the connection busy for several intervals
+	for i := 0; i < 50; i++ {
+		adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Zero(suite.T(), adapter.pings.Load())
+	// Engine pings the server once the connection is idle
+	require.Eventually(suite.T(), func() bool {
+		return adapter.pings.Load() > 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+// Test a failed ping is handled as a connection loss: the connection is closed and the engine
+// reconnects.
+func (suite *PingIntervalUnitTestSuite) TestPingFailureRestartsEngine() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	adapter.SetPingError(fmt.Errorf("%w: no pong", wsadapters.ErrPingFailed))
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(2).
+		WithPingInterval(10*time.Millisecond, 0).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) >= 2
+	}, 5*time.Second, time.Millisecond)
+	// Connection has been closed with the default close message and the engine has reconnected
+	adapter.SetPingError(nil)
+	require.True(suite.T(), client.RecordedOnOpens()[1].Restarting)
+	require.NotEmpty(suite.T(), client.RecordedOnCloses())
+	require.Nil(suite.T(), client.RecordedOnCloses()[0].CloseMessage)
+	require.Equal(suite.T(), wsadapters.GoingAway, adapter.CloseMessages()[0].Code)
+	require.NoError(suite.T(), engine.Stop(ctx))
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Mock adapter which counts Ping calls
+type pingCountingAdapter struct {
+	*mock.MockWebsocketConnectionAdapter
+	pings atomic.Int64
+}
+
+func (adapter *pingCountingAdapter) Ping(ctx context.Context) error {
+	adapter.pings.Add(1)
+	return adapter.MockWebsocketConnectionAdapter.Ping(ctx)
+}
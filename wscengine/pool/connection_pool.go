@@ -0,0 +1,384 @@
+// The package contains a pool of websocket engines connected to several identical websocket
+// endpoints (regional feeds, replicas, ...). The pool distributes writes among the engines and
+// merges the messages they receive on a single channel.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Default capacity of the channel which receives the merged messages.
+const DefaultBufferSize = 256
+
+// Error returned by Write when no engine of the pool is connected.
+var ErrNoConnection = errors.New("no connection of the pool is up")
+
+// Error returned by Start when the pool has been stopped: a pool cannot be restarted.
+var ErrPoolStopped = errors.New("connection pool has been stopped")
+
+// Strategy used to distribute writes among the engines of the pool.
+type WriteStrategy int
+
+const (
+	// Each message is written on a single connection: connections are used in turn. Connections
+	// which are down are skipped.
+	RoundRobin WriteStrategy = iota
+	// Each message is written on all connections which are up.
+	Broadcast
+)
+
+// Message received by an engine of the pool.
+type PooledMessage struct {
+	// ID of the engine which has received the message - index of its endpoint in the pool
+	EngineID int
+	// Message type
+	MsgType wsadapters.MessageType
+	// Raw payload
+	Payload []byte
+}
+
+// Endpoint an engine of the pool connects to.
+type PoolEndpoint struct {
+	// Target websocket server URL. Required.
+	Target *url.URL
+	// Connection adapter used by the engine. Required. Each endpoint must use its own adapter.
+	Adapter wsadapters.WebsocketConnectionAdapterInterface
+}
+
+// Options used to create a ConnectionPool.
+type ConnectionPoolOptions struct {
+	// Strategy used to distribute writes among the engines. Defaults to RoundRobin.
+	WriteStrategy WriteStrategy
+	// Capacity of the channel which receives the merged messages. If 0, DefaultBufferSize is
+	// used.
+	BufferSize int
+	// Configuration options shared by all engines. If nil, default engine options are used.
+	EngineOptions *wscengine.WebsocketEngineConfigurationOptions
+	// OpenTelemetry tracer provider used by all engines. If nil, global TracerProvider is used.
+	TracerProvider trace.TracerProvider
+}
+
+// Pool of websocket engines connected to identical endpoints.
+//
+// Messages received by all engines are merged on the channel returned by Messages: the engines
+// stop reading when the channel is full, so the channel must be consumed. Writes are distributed
+// among the engines which are connected according to the configured WriteStrategy.
+//
+// A pool can be started and stopped once. The pool is safe for concurrent use.
+type ConnectionPool struct {
+	// Pool members indexed by engine ID
+	members []*poolMember
+	// Strategy used to distribute writes
+	strategy WriteStrategy
+	// Index of the next member used by round-robin writes
+	next atomic.Uint64
+	// Channel which receives the merged messages
+	messages chan PooledMessage
+	// Channel closed when the pool stops - Releases OnMessage calls which wait on messages
+	done chan struct{}
+	// Mutex used to protect stopped and the registration of pending deliveries
+	mu sync.Mutex
+	// Flag set once the pool has been stopped
+	stopped bool
+	// Deliveries in progress - messages is closed once they are over
+	deliveries sync.WaitGroup
+}
+
+// # Description
+//
+// Factory which creates a new, non-started ConnectionPool with one engine per endpoint.
+//
+// # Inputs
+//
+//   - endpoints: Endpoints the engines connect to. At least one endpoint is required. The index of
+//     an endpoint is the ID of its engine.
+//   - opts: Pool options.
+//
+// # Returns
+//
+// A new ConnectionPool or an error if an endpoint or the options are invalid.
+func NewConnectionPool(endpoints []PoolEndpoint, opts ConnectionPoolOptions) (*ConnectionPool, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one endpoint is required")
+	}
+	if opts.WriteStrategy != RoundRobin && opts.WriteStrategy != Broadcast {
+		return nil, fmt.Errorf("unknown write strategy: %d", opts.WriteStrategy)
+	}
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must be greater or equal to 0")
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	pool := &ConnectionPool{
+		members:  make([]*poolMember, 0, len(endpoints)),
+		strategy: opts.WriteStrategy,
+		messages: make(chan PooledMessage, opts.BufferSize),
+		done:     make(chan struct{}),
+	}
+	for id, endpoint := range endpoints {
+		member := &poolMember{id: id, pool: pool}
+		engine, err := wscengine.NewWebsocketEngine(endpoint.Target, endpoint.Adapter, member, opts.EngineOptions, opts.TracerProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create engine %d: %w", id, err)
+		}
+		member.engine = engine
+		pool.members = append(pool.members, member)
+	}
+	return pool, nil
+}
+
+// # Description
+//
+// Start all engines of the pool. If an engine fails to start, the engines which have already
+// started are stopped.
+//
+// # Returns
+//
+// An error if an engine has failed to start or if the pool has been stopped.
+func (pool *ConnectionPool) Start(ctx context.Context) error {
+	pool.mu.Lock()
+	stopped := pool.stopped
+	pool.mu.Unlock()
+	if stopped {
+		return ErrPoolStopped
+	}
+	for index, member := range pool.members {
+		err := member.engine.Start(ctx)
+		if err != nil {
+			for _, started := range pool.members[:index] {
+				started.engine.Stop(ctx)
+			}
+			return fmt.Errorf("failed to start engine %d: %w", member.id, err)
+		}
+	}
+	return nil
+}
+
+// # Description
+//
+// Stop all engines of the pool and close the channel returned by Messages once all received
+// messages have been delivered. Messages received while the pool stops are dropped. Calling Stop
+// more than once has no effect.
+//
+// # Returns
+//
+// The errors returned by the engines which have failed to stop, if any.
+func (pool *ConnectionPool) Stop(ctx context.Context) error {
+	pool.mu.Lock()
+	if pool.stopped {
+		pool.mu.Unlock()
+		return nil
+	}
+	pool.stopped = true
+	pool.mu.Unlock()
+	// Release pending deliveries so engines are not blocked by a full channel
+	close(pool.done)
+	errs := []error{}
+	for _, member := range pool.members {
+		if member.engine.IsStarted() {
+			if err := member.engine.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop engine %d: %w", member.id, err))
+			}
+		}
+	}
+	pool.deliveries.Wait()
+	close(pool.messages)
+	return errors.Join(errs...)
+}
+
+// # Description
+//
+// Return the channel which receives the messages of all engines. The channel is closed when the
+// pool is stopped.
+func (pool *ConnectionPool) Messages() <-chan PooledMessage {
+	return pool.messages
+}
+
+// # Description
+//
+// Write a message according to the write strategy of the pool: on the next connection which is
+// up (RoundRobin) or on all connections which are up (Broadcast).
+//
+// # Returns
+//
+// ErrNoConnection if no connection is up. With RoundRobin, the error of the write. With
+// Broadcast, the joined errors of the writes which have failed.
+func (pool *ConnectionPool) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	if pool.strategy == Broadcast {
+		written := false
+		errs := []error{}
+		for _, member := range pool.members {
+			conn := member.connection()
+			if conn == nil {
+				continue
+			}
+			written = true
+			if err := conn.Write(ctx, msgType, msg); err != nil {
+				errs = append(errs, fmt.Errorf("failed to write on engine %d: %w", member.id, err))
+			}
+		}
+		if !written {
+			return ErrNoConnection
+		}
+		return errors.Join(errs...)
+	}
+	// Round-robin: use the next connection which is up
+	count := uint64(len(pool.members))
+	start := pool.next.Add(1) - 1
+	for offset := uint64(0); offset < count; offset++ {
+		member := pool.members[(start+offset)%count]
+		if conn := member.connection(); conn != nil {
+			if err := conn.Write(ctx, msgType, msg); err != nil {
+				return fmt.Errorf("failed to write on engine %d: %w", member.id, err)
+			}
+			return nil
+		}
+	}
+	return ErrNoConnection
+}
+
+// # Description
+//
+// Return the engine which has the provided ID or nil if the ID is unknown.
+func (pool *ConnectionPool) Engine(id int) *wscengine.WebsocketEngine {
+	if id < 0 || id >= len(pool.members) {
+		return nil
+	}
+	return pool.members[id].engine
+}
+
+// # Description
+//
+// Return the number of engines of the pool.
+func (pool *ConnectionPool) Size() int {
+	return len(pool.members)
+}
+
+// # Description
+//
+// Return the number of engines which are connected.
+func (pool *ConnectionPool) ConnectedCount() int {
+	connected := 0
+	for _, member := range pool.members {
+		if member.connection() != nil {
+			connected++
+		}
+	}
+	return connected
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Deliver a message received by an engine on the merged channel. Wait until the channel can
+// receive the message or until the pool stops.
+func (pool *ConnectionPool) deliver(msg PooledMessage) {
+	pool.mu.Lock()
+	if pool.stopped {
+		pool.mu.Unlock()
+		return
+	}
+	pool.deliveries.Add(1)
+	pool.mu.Unlock()
+	defer pool.deliveries.Done()
+	select {
+	case pool.messages <- msg:
+	case <-pool.done:
+	}
+}
+
+// Member of the pool - websocket client of one engine.
+type poolMember struct {
+	// Engine ID
+	id int
+	// Pool the member belongs to
+	pool *ConnectionPool
+	// Engine of the member
+	engine *wscengine.WebsocketEngine
+	// Mutex used to protect conn
+	mu sync.Mutex
+	// Connection provided to OnOpen - nil while the connection is down
+	conn wsadapters.WebsocketConnectionAdapterInterface
+}
+
+// Return the connection of the member or nil if the connection is down.
+func (member *poolMember) connection() wsadapters.WebsocketConnectionAdapterInterface {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+	return member.conn
+}
+
+// Set the connection of the member.
+func (member *poolMember) setConnection(conn wsadapters.WebsocketConnectionAdapterInterface) {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+	member.conn = conn
+}
+
+// Record the connection so it can be used by Write.
+func (member *poolMember) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	member.setConnection(conn)
+	return nil
+}
+
+// Deliver the message on the merged channel.
+func (member *poolMember) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	member.pool.deliver(PooledMessage{EngineID: member.id, MsgType: msgType, Payload: msg})
+}
+
+// Do nothing - the engine restarts if the connection is closed.
+func (member *poolMember) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Forget the connection so it is not used by Write anymore.
+func (member *poolMember) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	member.setConnection(nil)
+	return nil
+}
+
+// Do nothing.
+func (member *poolMember) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine keeps trying to reconnect.
+func (member *poolMember) OnRestartError(ctx context.Context, exit context.CancelFunc, sessionId string, err error, retryCount int) {
+}
@@ -0,0 +1,190 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for ConnectionPool unit tests
+type ConnectionPoolUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ConnectionPoolUnitTestSuite test suite
+func TestConnectionPoolUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConnectionPoolUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory validates its inputs.
+func (suite *ConnectionPoolUnitTestSuite) TestNewConnectionPool() {
+	_, err := NewConnectionPool(nil, ConnectionPoolOptions{})
+	require.Error(suite.T(), err)
+	endpoints, _ := newEndpoints(1)
+	_, err = NewConnectionPool(endpoints, ConnectionPoolOptions{WriteStrategy: WriteStrategy(42)})
+	require.Error(suite.T(), err)
+	_, err = NewConnectionPool(endpoints, ConnectionPoolOptions{BufferSize: -1})
+	require.Error(suite.T(), err)
+	_, err = NewConnectionPool([]PoolEndpoint{{Target: endpoints[0].Target}}, ConnectionPoolOptions{})
+	require.Error(suite.T(), err)
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), 1, pool.Size())
+	require.Equal(suite.T(), DefaultBufferSize, cap(pool.messages))
+	require.NotNil(suite.T(), pool.Engine(0))
+	require.Nil(suite.T(), pool.Engine(1))
+	require.Nil(suite.T(), pool.Engine(-1))
+}
+
+// Test the messages received by all engines are merged with the ID of their engine and the
+// channel is closed when the pool stops.
+func (suite *ConnectionPoolUnitTestSuite) TestMessagesAreMerged() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	endpoints, adapters := newEndpoints(2)
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{EngineOptions: newEngineOptions()})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), pool.Start(ctx))
+	require.Equal(suite.T(), 2, pool.ConnectedCount())
+	adapters[0].EnqueueMessage(wsadapters.Text, []byte("eu"))
+	adapters[1].EnqueueMessage(wsadapters.Binary, []byte("us"))
+	received := map[int]PooledMessage{}
+	for len(received) < 2 {
+		select {
+		case msg := <-pool.Messages():
+			received[msg.EngineID] = msg
+		case <-ctx.Done():
+			suite.FailNow("messages should have been received")
+		}
+	}
+	require.Equal(suite.T(), PooledMessage{EngineID: 0, MsgType: wsadapters.Text, Payload: []byte("eu")}, received[0])
+	require.Equal(suite.T(), PooledMessage{EngineID: 1, MsgType: wsadapters.Binary, Payload: []byte("us")}, received[1])
+	// Stop closes the channel - pool cannot be restarted
+	require.NoError(suite.T(), pool.Stop(ctx))
+	require.NoError(suite.T(), pool.Stop(ctx))
+	_, open := <-pool.Messages()
+	require.False(suite.T(), open)
+	require.Zero(suite.T(), pool.ConnectedCount())
+	require.ErrorIs(suite.T(), pool.Start(ctx), ErrPoolStopped)
+}
+
+// Test round-robin writes use the connections in turn and skip the connections which are down.
+func (suite *ConnectionPoolUnitTestSuite) TestRoundRobinWrite() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	endpoints, adapters := newEndpoints(3)
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{WriteStrategy: RoundRobin, EngineOptions: newEngineOptions()})
+	require.NoError(suite.T(), err)
+	require.ErrorIs(suite.T(), pool.Write(ctx, wsadapters.Text, []byte("too early")), ErrNoConnection)
+	require.NoError(suite.T(), pool.Start(ctx))
+	defer pool.Stop(ctx)
+	for i := 0; i < 6; i++ {
+		require.NoError(suite.T(), pool.Write(ctx, wsadapters.Text, []byte(fmt.Sprint(i))))
+	}
+	for _, adapter := range adapters {
+		require.Len(suite.T(), adapter.WrittenMessages(), 2)
+	}
+	// Stop an engine - its connection is skipped
+	require.NoError(suite.T(), pool.Engine(1).Stop(ctx))
+	require.Equal(suite.T(), 2, pool.ConnectedCount())
+	for i := 0; i < 4; i++ {
+		require.NoError(suite.T(), pool.Write(ctx, wsadapters.Text, []byte(fmt.Sprint(i))))
+	}
+	require.Len(suite.T(), adapters[1].WrittenMessages(), 2)
+	require.Equal(suite.T(), 8, len(adapters[0].WrittenMessages())+len(adapters[2].WrittenMessages()))
+}
+
+// Test broadcast writes use all connections which are up.
+func (suite *ConnectionPoolUnitTestSuite) TestBroadcastWrite() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	endpoints, adapters := newEndpoints(3)
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{WriteStrategy: Broadcast, EngineOptions: newEngineOptions()})
+	require.NoError(suite.T(), err)
+	require.ErrorIs(suite.T(), pool.Write(ctx, wsadapters.Text, []byte("too early")), ErrNoConnection)
+	require.NoError(suite.T(), pool.Start(ctx))
+	defer pool.Stop(ctx)
+	require.NoError(suite.T(), pool.Write(ctx, wsadapters.Text, []byte("hello")))
+	for _, adapter := range adapters {
+		require.Len(suite.T(), adapter.WrittenMessages(), 1)
+		require.Equal(suite.T(), []byte("hello"), adapter.WrittenMessages()[0].Msg)
+	}
+}
+
+// Test Start stops the engines which have started when an engine fails to start.
+func (suite *ConnectionPoolUnitTestSuite) TestStartFailure() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	endpoints, adapters := newEndpoints(2)
+	adapters[1].SetDialResponse(nil, fmt.Errorf("dial failed"))
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{EngineOptions: newEngineOptions()})
+	require.NoError(suite.T(), err)
+	require.Error(suite.T(), pool.Start(ctx))
+	require.False(suite.T(), pool.Engine(0).IsStarted())
+	require.False(suite.T(), pool.Engine(1).IsStarted())
+}
+
+// Test Stop does not block when the merged channel is full.
+func (suite *ConnectionPoolUnitTestSuite) TestStopWhenChannelIsFull() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	endpoints, adapters := newEndpoints(1)
+	pool, err := NewConnectionPool(endpoints, ConnectionPoolOptions{BufferSize: 1, EngineOptions: newEngineOptions()})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), pool.Start(ctx))
+	for i := 0; i < 3; i++ {
+		adapters[0].EnqueueMessage(wsadapters.Text, []byte(fmt.Sprint(i)))
+	}
+	require.Eventually(suite.T(), func() bool {
+		return len(pool.Messages()) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), pool.Stop(ctx))
+	// Buffered message is still delivered before the channel is closed
+	msg, open := <-pool.Messages()
+	require.True(suite.T(), open)
+	require.Equal(suite.T(), []byte("0"), msg.Payload)
+	_, open = <-pool.Messages()
+	require.False(suite.T(), open)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Create endpoints which use mock adapters
+func newEndpoints(count int) ([]PoolEndpoint, []*mock.MockWebsocketConnectionAdapter) {
+	endpoints := []PoolEndpoint{}
+	adapters := []*mock.MockWebsocketConnectionAdapter{}
+	for i := 0; i < count; i++ {
+		adapter := mock.NewMockWebsocketConnectionAdapter()
+		adapters = append(adapters, adapter)
+		endpoints = append(endpoints, PoolEndpoint{
+			Target:  &url.URL{Scheme: "ws", Host: fmt.Sprintf("region-%d", i)},
+			Adapter: adapter,
+		})
+	}
+	return endpoints, adapters
+}
+
+// Create engine options which do not reconnect
+func newEngineOptions() *wscengine.WebsocketEngineConfigurationOptions {
+	return wscengine.NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithAutoReconnect(false)
+}
@@ -0,0 +1,406 @@
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/router"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// Default capacity of the channels returned by Subscribe.
+const DefaultBufferSize = 16
+
+// GraphQLWSClient options.
+type GraphQLWSClientOptions struct {
+	// Optional payload sent with the connection_init message (ex: authentication token).
+	ConnectionInitPayload map[string]any
+	// Capacity of the channels returned by Subscribe. DefaultBufferSize is used if 0.
+	BufferSize int
+}
+
+// Operation (subscription, query or mutation) managed by the client
+type operation struct {
+	// Payload of the subscribe message - sent again when subscriptions are renewed
+	payload subscribePayload
+	// Whether the operation is renewed when the engine reconnects (subscriptions only)
+	renew bool
+	// Messages routed to the operation - closed once the operation is finished
+	messages <-chan []byte
+	// Function which stops routing messages to the operation
+	cancel router.CancelFunc
+	// Channel which delivers the operation results to the user
+	responses chan GraphQLResponse
+	// Error which ended the operation if any - set before messages is closed
+	err error
+}
+
+// A WebsocketClientInterface implementation which speaks the graphql-transport-ws protocol over
+// the websocket connection managed by the websocket engine.
+//
+// The client sends connection_init each time the engine (re)opens the connection and waits for
+// the connection_ack message from OnOpen. Operation results (next, error and complete messages)
+// are dispatched to operations by a SubscriptionRouter using the operation ID as topic. Active
+// subscriptions are automatically renewed when the engine reconnects while pending queries and
+// mutations fail when the connection is closed.
+//
+// Subscribe and Mutation must not be called from inside OnOpen or while the engine read mutex is
+// locked as results are read by engine goroutines.
+type GraphQLWSClient struct {
+	// Client options
+	opts GraphQLWSClientOptions
+	// Router which dispatches operation results by operation ID
+	router *router.SubscriptionRouter
+	// Mutex used to protect client state
+	mu sync.Mutex
+	// Current connection - set during OnOpen
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Indicates whether the server has acknowledged the connection
+	acknowledged bool
+	// Last operation ID - operation IDs are sequential in the client scope
+	operationId uint64
+	// Active operations by operation ID
+	operations map[string]*operation
+}
+
+// # Description
+//
+// Factory which creates a new GraphQLWSClient. Provide the client to the websocket engine factory
+// so the engine calls its callbacks.
+//
+// # Returns
+//
+// A new GraphQLWSClient or an error if the buffer size is negative.
+func NewGraphQLWSClient(opts GraphQLWSClientOptions) (*GraphQLWSClient, error) {
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must be at least 0: %d", opts.BufferSize)
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	rt, err := router.NewSubscriptionRouter(extractOperationId, nil, opts.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphQLWSClient{
+		opts:       opts,
+		router:     rt,
+		operations: map[string]*operation{},
+	}, nil
+}
+
+// # Description
+//
+// Start a subscription and return the channel which receives its results.
+//
+// The channel is closed when the server completes the subscription, when the server reports an
+// error (the errors are delivered as a last result) or when ctx is done. In the latter case, the
+// client notifies the server the subscription is complete. The subscription is renewed when the
+// engine reconnects: cancel ctx to release it once the engine has been stopped.
+//
+// Results are dropped if the channel consumer does not keep up with the server.
+//
+// # Inputs
+//
+//   - ctx: Context bound to the subscription lifetime.
+//   - query: GraphQL subscription document.
+//   - variables: Optional variables of the operation.
+//
+// # Returns
+//
+// The channel which receives the subscription results or an error if the client is not
+// connected or if the subscribe message could not be sent.
+func (client *GraphQLWSClient) Subscribe(ctx context.Context, query string, variables map[string]any) (<-chan GraphQLResponse, error) {
+	op, err := client.start(ctx, subscribePayload{Query: query, Variables: variables}, true)
+	if err != nil {
+		return nil, err
+	}
+	return op.responses, nil
+}
+
+// # Description
+//
+// Execute a mutation (or a query) and wait for its result.
+//
+// # Inputs
+//
+//   - ctx: Context bound to the operation lifetime.
+//   - query: GraphQL mutation or query document.
+//   - variables: Optional variables of the operation.
+//
+// # Returns
+//
+// The operation result or an error if the client is not connected, if the server reported an
+// error message (GraphQLErrors), if the connection has been closed or if ctx is done before the
+// server completes the operation. Errors carried by an error message are also set in the
+// returned result. Execution errors carried by the result itself (ex: partial results) do not
+// cause an error to be returned.
+func (client *GraphQLWSClient) Mutation(ctx context.Context, query string, variables map[string]any) (GraphQLResponse, error) {
+	op, err := client.start(ctx, subscribePayload{Query: query, Variables: variables}, false)
+	if err != nil {
+		return GraphQLResponse{}, err
+	}
+	// Keep the first result and wait for the operation to complete
+	var result *GraphQLResponse
+	for resp := range op.responses {
+		if result == nil {
+			first := resp
+			result = &first
+		}
+	}
+	if op.err != nil {
+		if result == nil {
+			return GraphQLResponse{}, op.err
+		}
+		return *result, op.err
+	}
+	if result == nil {
+		return GraphQLResponse{}, fmt.Errorf("graphql operation completed without result")
+	}
+	return *result, nil
+}
+
+/*************************************************************************************************/
+/* WEBSOCKET CLIENT CALLBACKS                                                                    */
+/*************************************************************************************************/
+
+// Initialize the connection and renew active subscriptions.
+func (client *GraphQLWSClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn = conn
+	client.acknowledged = false
+	// Send connection_init
+	var payload any
+	if client.opts.ConnectionInitPayload != nil {
+		payload = client.opts.ConnectionInitPayload
+	}
+	err := client.write(ctx, "", messageConnectionInit, payload)
+	if err != nil {
+		return fmt.Errorf("failed to send connection_init message: %w", err)
+	}
+	// Engine does not read messages until OnOpen completes: wait for connection_ack
+	for acknowledged := false; !acknowledged; {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to receive connection_ack message: %w", err)
+		}
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return fmt.Errorf("failed to receive connection_ack message: %w", err)
+		}
+		switch msg.Type {
+		case messageConnectionAck:
+			acknowledged = true
+		case messagePing:
+			err = client.write(ctx, "", messagePong, nil)
+			if err != nil {
+				return fmt.Errorf("failed to send pong message: %w", err)
+			}
+		}
+	}
+	// Renew subscriptions
+	for id, op := range client.operations {
+		err = client.write(ctx, id, messageSubscribe, op.payload)
+		if err != nil {
+			return fmt.Errorf("failed to renew subscription %s: %w", id, err)
+		}
+	}
+	client.acknowledged = true
+	return nil
+}
+
+// Reply to pings and dispatch operation results.
+func (client *GraphQLWSClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	decoded, err := decodeMessage(msg)
+	if err != nil {
+		// Discard invalid messages
+		return
+	}
+	switch decoded.Type {
+	case messagePing:
+		client.mu.Lock()
+		client.write(ctx, "", messagePong, nil)
+		client.mu.Unlock()
+	case messageNext, messageError, messageComplete:
+		client.router.Route(ctx, msgType, msg)
+	}
+}
+
+// Do nothing - read errors are handled by the engine.
+func (client *GraphQLWSClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Fail pending queries and mutations. Subscriptions are kept to be renewed.
+func (client *GraphQLWSClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.acknowledged = false
+	for id, op := range client.operations {
+		if !op.renew {
+			delete(client.operations, id)
+			op.err = fmt.Errorf("graphql-transport-ws connection closed before operation %s completed", id)
+			op.cancel()
+		}
+	}
+	return &wsclient.CloseMessageDetails{
+		CloseReason:  wsadapters.NormalClosure,
+		CloseMessage: "graphql-transport-ws client disconnected",
+	}
+}
+
+// Do nothing.
+func (client *GraphQLWSClient) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine retries to connect.
+func (client *GraphQLWSClient) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Register a new operation, send its subscribe message and start forwarding its results.
+func (client *GraphQLWSClient) start(ctx context.Context, payload subscribePayload, renew bool) (*operation, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.acknowledged {
+		return nil, fmt.Errorf("graphql-transport-ws client is not connected")
+	}
+	client.operationId = client.operationId + 1
+	id := strconv.FormatUint(client.operationId, 10)
+	// Route results before the operation is sent so none is missed
+	messages, cancel := client.router.Subscribe(id)
+	op := &operation{
+		payload:   payload,
+		renew:     renew,
+		messages:  messages,
+		cancel:    cancel,
+		responses: make(chan GraphQLResponse, client.opts.BufferSize),
+	}
+	client.operations[id] = op
+	err := client.write(ctx, id, messageSubscribe, payload)
+	if err != nil {
+		delete(client.operations, id)
+		cancel()
+		return nil, fmt.Errorf("failed to send subscribe message: %w", err)
+	}
+	go client.forward(ctx, id, op)
+	return op, nil
+}
+
+// Decode the messages routed to the operation and deliver its results until the operation is
+// finished or ctx is done.
+func (client *GraphQLWSClient) forward(ctx context.Context, id string, op *operation) {
+	defer close(op.responses)
+	for {
+		select {
+		case <-ctx.Done():
+			client.finish(id, ctx.Err(), true)
+			return
+		case data, ok := <-op.messages:
+			if !ok {
+				// Operation has been finished
+				return
+			}
+			msg, err := decodeMessage(data)
+			if err != nil {
+				continue
+			}
+			switch msg.Type {
+			case messageNext:
+				resp := GraphQLResponse{}
+				if json.Unmarshal(msg.Payload, &resp) != nil {
+					// Discard invalid results
+					continue
+				}
+				select {
+				case op.responses <- resp:
+				case <-ctx.Done():
+					client.finish(id, ctx.Err(), true)
+					return
+				}
+			case messageError:
+				errs := GraphQLErrors{}
+				json.Unmarshal(msg.Payload, &errs)
+				client.finish(id, errs, false)
+				select {
+				case op.responses <- GraphQLResponse{Errors: errs}:
+				case <-ctx.Done():
+				}
+				return
+			case messageComplete:
+				client.finish(id, nil, false)
+				return
+			}
+		}
+	}
+}
+
+// Remove the operation, stop routing its results and optionally notify the server the operation
+// is complete. Does nothing if the operation has already been finished.
+func (client *GraphQLWSClient) finish(id string, err error, notify bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	op, ok := client.operations[id]
+	if !ok {
+		return
+	}
+	delete(client.operations, id)
+	op.err = err
+	op.cancel()
+	if notify && client.acknowledged {
+		// Provided context may be canceled - use a fresh one. Errors are ignored as the server
+		// drops operations when the connection is closed.
+		client.write(context.Background(), id, messageComplete, nil)
+	}
+}
+
+// Write a message on the current connection. Client mutex must be locked.
+func (client *GraphQLWSClient) write(ctx context.Context, id string, msgType string, payload any) error {
+	if client.conn == nil {
+		return fmt.Errorf("graphql-transport-ws client has no connection")
+	}
+	data, err := encodeMessage(id, msgType, payload)
+	if err != nil {
+		return err
+	}
+	return client.conn.Write(ctx, wsadapters.Text, data)
+}
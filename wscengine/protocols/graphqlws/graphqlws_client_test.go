@@ -0,0 +1,351 @@
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test GraphQLWSClient with a websocket engine and a fake GraphQL server
+type GraphQLWSClientIntegrationTestSuite struct {
+	suite.Suite
+	// Fake GraphQL server
+	server *testServer
+	// Server which hosts the fake GraphQL server
+	srv *httptest.Server
+}
+
+// Run GraphQLWSClientIntegrationTestSuite test suite
+func TestGraphQLWSClientIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphQLWSClientIntegrationTestSuite))
+}
+
+// Start fake server
+func (suite *GraphQLWSClientIntegrationTestSuite) SetupTest() {
+	suite.server = &testServer{token: "secret"}
+	suite.srv = httptest.NewServer(suite.server)
+}
+
+// Stop fake server
+func (suite *GraphQLWSClientIntegrationTestSuite) TearDownTest() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test interface compliance and factory.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestInterfaceCompliance() {
+	_, err := NewGraphQLWSClient(GraphQLWSClientOptions{BufferSize: -1})
+	require.Error(suite.T(), err)
+	client, err := NewGraphQLWSClient(GraphQLWSClientOptions{})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), DefaultBufferSize, client.opts.BufferSize)
+	var instance any = client
+	_, ok := instance.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test a subscription receives its results until the server completes it.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestSubscribe() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient()
+	// Subscribing before connecting fails
+	_, err := client.Subscribe(ctx, "subscription { counter }", nil)
+	require.Error(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	results, err := client.Subscribe(ctx, "subscription { counter(to: $to) }", map[string]any{"to": 3})
+	require.NoError(suite.T(), err)
+	counters := []string{}
+	for resp := range results {
+		require.Empty(suite.T(), resp.Errors)
+		counters = append(counters, string(resp.Data))
+	}
+	require.Equal(suite.T(), []string{`{"counter":1}`, `{"counter":2}`, `{"counter":3}`}, counters)
+}
+
+// Test the server is notified when the subscription context is canceled.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestSubscriptionCanceled() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient()
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	subCtx, subCancel := context.WithCancel(ctx)
+	results, err := client.Subscribe(subCtx, "subscription { infinite }", nil)
+	require.NoError(suite.T(), err)
+	<-results
+	subCancel()
+	// Channel is closed and server receives complete
+	require.Eventually(suite.T(), func() bool {
+		select {
+		case _, ok := <-results:
+			return !ok
+		default:
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+	require.Eventually(suite.T(), func() bool {
+		return suite.server.hasReceived(messageComplete)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test mutations return their result or the errors reported by the server.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestMutation() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient()
+	_, err := client.Mutation(ctx, "mutation { echo }", nil)
+	require.Error(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Successful mutation
+	resp, err := client.Mutation(ctx, "mutation { echo(msg: $msg) }", map[string]any{"msg": "hello"})
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"echo":"hello"}`, string(resp.Data))
+	// Mutation refused by the server
+	resp, err = client.Mutation(ctx, "mutation { invalid }", nil)
+	gqlErrs := GraphQLErrors{}
+	require.True(suite.T(), errors.As(err, &gqlErrs))
+	require.Equal(suite.T(), "Cannot query field invalid", gqlErrs[0].Message)
+	require.Equal(suite.T(), gqlErrs, resp.Errors)
+}
+
+// Test subscriptions are renewed when the engine reconnects.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestSubscriptionRenewed() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient()
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Sec-WebSocket-Protocol": {Subprotocol}})
+	engine, err := wscengine.NewWebsocketEngine(target, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	results, err := client.Subscribe(ctx, "subscription { infinite }", nil)
+	require.NoError(suite.T(), err)
+	<-results
+	// Server drops the connection - the subscription is sent again once reconnected
+	suite.server.dropConnections()
+	require.Eventually(suite.T(), func() bool {
+		return suite.server.subscribeCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case _, ok := <-results:
+		require.True(suite.T(), ok)
+	case <-ctx.Done():
+		suite.FailNow("result should have been received")
+	}
+}
+
+// Test Start fails when the server refuses the connection.
+func (suite *GraphQLWSClientIntegrationTestSuite) TestConnectionRefused() {
+	client, err := NewGraphQLWSClient(GraphQLWSClientOptions{ConnectionInitPayload: map[string]any{"token": "wrong"}})
+	require.NoError(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.Error(suite.T(), engine.Start(context.Background()))
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Create a client which authenticates with the fake server
+func (suite *GraphQLWSClientIntegrationTestSuite) newClient() *GraphQLWSClient {
+	client, err := NewGraphQLWSClient(GraphQLWSClientOptions{ConnectionInitPayload: map[string]any{"token": "secret"}})
+	require.NoError(suite.T(), err)
+	return client
+}
+
+// Create a websocket engine connected to the fake server
+func (suite *GraphQLWSClientIntegrationTestSuite) newEngine(client *GraphQLWSClient) *wscengine.WebsocketEngine {
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithOnOpenTimeoutMs(5000)
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Sec-WebSocket-Protocol": {Subprotocol}})
+	engine, err := wscengine.NewWebsocketEngine(target, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine
+}
+
+// Minimal graphql-transport-ws server. Operations are selected by the field in the query:
+//   - counter: sends 3 results and completes.
+//   - infinite: sends a result every 10ms until the client completes the subscription.
+//   - echo: sends the msg variable and completes.
+//   - invalid: sends an error message.
+type testServer struct {
+	// Token expected in the connection_init payload
+	token string
+	// Mutex which protects server state
+	mu sync.Mutex
+	// Received message types
+	messages []string
+	// Active connections
+	conns []*websocket.Conn
+}
+
+// Check whether the server has received a message type
+func (server *testServer) hasReceived(msgType string) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, t := range server.messages {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the number of subscribe messages received
+func (server *testServer) subscribeCount() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	count := 0
+	for _, t := range server.messages {
+		if t == messageSubscribe {
+			count++
+		}
+	}
+	return count
+}
+
+// Close active connections without close message
+func (server *testServer) dropConnections() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, conn := range server.conns {
+		conn.UnderlyingConn().Close()
+	}
+	server.conns = nil
+}
+
+// Serve a single graphql-transport-ws connection
+func (server *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{Subprotocol}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != Subprotocol {
+		return
+	}
+	server.mu.Lock()
+	server.conns = append(server.conns, conn)
+	server.mu.Unlock()
+	// Writes are serialized as results of infinite subscriptions are sent by goroutines
+	writeMu := sync.Mutex{}
+	send := func(id string, msgType string, payload any) error {
+		data, _ := encodeMessage(id, msgType, payload)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+	// Completed subscriptions by ID
+	completed := map[string]chan struct{}{}
+	defer func() {
+		for _, done := range completed {
+			close(done)
+		}
+	}()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return
+		}
+		server.mu.Lock()
+		server.messages = append(server.messages, msg.Type)
+		server.mu.Unlock()
+		switch msg.Type {
+		case messageConnectionInit:
+			init := map[string]any{}
+			json.Unmarshal(msg.Payload, &init)
+			if init["token"] != server.token {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4403, "Forbidden"), time.Now().Add(time.Second))
+				return
+			}
+			// Ping the client before acknowledging the connection
+			err = send("", messagePing, nil)
+			if err == nil {
+				err = send("", messageConnectionAck, nil)
+			}
+		case messageSubscribe:
+			payload := subscribePayload{}
+			json.Unmarshal(msg.Payload, &payload)
+			switch {
+			case strings.Contains(payload.Query, "counter"):
+				for i := 1; i <= 3 && err == nil; i++ {
+					err = send(msg.Id, messageNext, map[string]any{"data": map[string]any{"counter": i}})
+				}
+				if err == nil {
+					err = send(msg.Id, messageComplete, nil)
+				}
+			case strings.Contains(payload.Query, "infinite"):
+				done := make(chan struct{})
+				completed[msg.Id] = done
+				go func(id string) {
+					for {
+						select {
+						case <-done:
+							return
+						case <-time.After(10 * time.Millisecond):
+							if send(id, messageNext, map[string]any{"data": map[string]any{"infinite": true}}) != nil {
+								return
+							}
+						}
+					}
+				}(msg.Id)
+			case strings.Contains(payload.Query, "echo"):
+				err = send(msg.Id, messageNext, map[string]any{"data": map[string]any{"echo": payload.Variables["msg"]}})
+				if err == nil {
+					err = send(msg.Id, messageComplete, nil)
+				}
+			default:
+				err = send(msg.Id, messageError, []map[string]any{{"message": "Cannot query field invalid"}})
+			}
+		case messageComplete:
+			if done, ok := completed[msg.Id]; ok {
+				close(done)
+				delete(completed, msg.Id)
+			}
+		case messagePing:
+			err = send("", messagePong, nil)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
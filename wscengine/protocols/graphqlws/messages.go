@@ -0,0 +1,132 @@
+// The package contains a WebsocketClientInterface implementation for the GraphQL over WebSocket
+// protocol (graphql-transport-ws) used by Apollo, Hasura, PostGraphile and many GraphQL servers
+// (https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md).
+//
+// The websocket connection adapter provided to the engine must request the graphql-transport-ws
+// subprotocol (Sec-WebSocket-Protocol header) as required by GraphQL servers.
+package graphqlws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Subprotocol which must be requested during the websocket handshake.
+const Subprotocol = "graphql-transport-ws"
+
+// graphql-transport-ws message types
+const (
+	messageConnectionInit = "connection_init"
+	messageConnectionAck  = "connection_ack"
+	messagePing           = "ping"
+	messagePong           = "pong"
+	messageSubscribe      = "subscribe"
+	messageNext           = "next"
+	messageError          = "error"
+	messageComplete       = "complete"
+)
+
+// Location of a GraphQL error in the operation document.
+type GraphQLErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error returned by the GraphQL server.
+type GraphQLError struct {
+	// Error description
+	Message string `json:"message"`
+	// Optional locations in the operation document the error relates to
+	Locations []GraphQLErrorLocation `json:"locations,omitempty"`
+	// Optional path of the response field the error relates to
+	Path []any `json:"path,omitempty"`
+	// Optional server specific details
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+func (err GraphQLError) Error() string {
+	return err.Message
+}
+
+// Errors returned by the GraphQL server for an operation.
+type GraphQLErrors []GraphQLError
+
+func (errs GraphQLErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+	return fmt.Sprintf("graphql errors: %s", strings.Join(messages, "; "))
+}
+
+// Result of a GraphQL operation. Subscriptions produce one result per event.
+type GraphQLResponse struct {
+	// Raw JSON data of the result - can be null or absent if the operation failed
+	Data json.RawMessage `json:"data,omitempty"`
+	// Errors which occured while the operation was executed, if any
+	Errors GraphQLErrors `json:"errors,omitempty"`
+	// Optional server specific details
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// A graphql-transport-ws message.
+type message struct {
+	// Operation ID - only set for subscribe, next, error and complete messages
+	Id string `json:"id,omitempty"`
+	// Message type
+	Type string `json:"type"`
+	// Optional payload
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Payload of subscribe messages.
+type subscribePayload struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// Decode a graphql-transport-ws message.
+func decodeMessage(data []byte) (message, error) {
+	msg := message{}
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return message{}, fmt.Errorf("invalid graphql-transport-ws message: %w", err)
+	}
+	if msg.Type == "" {
+		return message{}, fmt.Errorf("invalid graphql-transport-ws message: missing message type")
+	}
+	return msg, nil
+}
+
+// Encode a graphql-transport-ws message with the provided payload (can be nil).
+func encodeMessage(id string, msgType string, payload any) ([]byte, error) {
+	msg := message{Id: id, Type: msgType}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode graphql-transport-ws payload: %w", err)
+		}
+		msg.Payload = raw
+	}
+	return json.Marshal(msg)
+}
+
+// Extract the operation ID of next, error and complete messages. Used to route messages to
+// operations.
+func extractOperationId(data []byte) (string, error) {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		return "", err
+	}
+	switch msg.Type {
+	case messageNext, messageError, messageComplete:
+		return msg.Id, nil
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,68 @@
+package graphqlws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for graphql-transport-ws messages unit tests
+type GraphQLWSMessagesUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run GraphQLWSMessagesUnitTestSuite test suite
+func TestGraphQLWSMessagesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphQLWSMessagesUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test encodeMessage and decodeMessage.
+func (suite *GraphQLWSMessagesUnitTestSuite) TestEncodeDecodeMessage() {
+	data, err := encodeMessage("1", messageSubscribe, subscribePayload{Query: "{ a }", Variables: map[string]any{"b": 1}})
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"id":"1","type":"subscribe","payload":{"query":"{ a }","variables":{"b":1}}}`, string(data))
+	msg, err := decodeMessage(data)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "1", msg.Id)
+	require.Equal(suite.T(), messageSubscribe, msg.Type)
+	// Messages without payload
+	data, err = encodeMessage("", messageConnectionInit, nil)
+	require.NoError(suite.T(), err)
+	require.JSONEq(suite.T(), `{"type":"connection_init"}`, string(data))
+	// Invalid messages
+	_, err = decodeMessage([]byte(`{"id":"1"}`))
+	require.Error(suite.T(), err)
+	_, err = decodeMessage([]byte(`[]`))
+	require.Error(suite.T(), err)
+}
+
+// Test extractOperationId only returns the ID of operation results.
+func (suite *GraphQLWSMessagesUnitTestSuite) TestExtractOperationId() {
+	id, err := extractOperationId([]byte(`{"id":"7","type":"next","payload":{"data":{}}}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "7", id)
+	id, err = extractOperationId([]byte(`{"id":"7","type":"complete"}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "7", id)
+	id, err = extractOperationId([]byte(`{"type":"ping"}`))
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), id)
+	_, err = extractOperationId([]byte(`invalid`))
+	require.Error(suite.T(), err)
+}
+
+// Test GraphQLErrors error message.
+func (suite *GraphQLWSMessagesUnitTestSuite) TestGraphQLErrors() {
+	errs := GraphQLErrors{{Message: "first"}, {Message: "second"}}
+	require.Equal(suite.T(), "graphql errors: first; second", errs.Error())
+	require.Equal(suite.T(), "first", errs[0].Error())
+}
@@ -0,0 +1,236 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/reqresp"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// A WebsocketClientInterface implementation which speaks JSON-RPC 2.0 over the websocket
+// connection managed by the websocket engine.
+//
+// Calls are correlated with their response by a RequestResponseRouter which assigns a random
+// UUID to each call. Notifications sent by the server are dispatched to the handler registered
+// for their method. Requests sent by the server are not supported: the client replies with a
+// method not found error.
+//
+// Call waits for the server response which is read by engine goroutines: it must not be called
+// from inside OnOpen or while the engine read mutex is locked.
+type JSONRPCClient struct {
+	// Mutex used to protect client state
+	mu sync.Mutex
+	// Current connection - set during OnOpen
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Router which correlates calls and responses - created during OnOpen
+	router *reqresp.RequestResponseRouter
+	// Indicates whether the connection is open
+	connected bool
+	// Notification handlers by method
+	handlers map[string]func(params json.RawMessage)
+}
+
+// # Description
+//
+// Factory which creates a new JSONRPCClient. Provide the client to the websocket engine factory
+// so the engine calls its callbacks.
+func NewJSONRPCClient() *JSONRPCClient {
+	return &JSONRPCClient{
+		handlers: map[string]func(params json.RawMessage){},
+	}
+}
+
+// # Description
+//
+// Register the handler called with the params of the notifications sent by the server for the
+// provided method. A previously registered handler is replaced. A nil handler unregisters the
+// method handler. Handlers are called by engine goroutines and must be safe for concurrent use if
+// the engine uses several reader goroutines.
+func (client *JSONRPCClient) RegisterHandler(method string, handler func(params json.RawMessage)) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if handler == nil {
+		delete(client.handlers, method)
+		return
+	}
+	client.handlers[method] = handler
+}
+
+// # Description
+//
+// Call the provided method and wait for its result.
+//
+// # Inputs
+//
+//   - ctx: Context used to write the request and to bound the time spent waiting for the result.
+//   - method: Name of the method to call.
+//   - params: Optional params (usually a struct, a map or a slice) encoded as JSON. Omitted if nil.
+//
+// # Returns
+//
+// The raw JSON result or an error if the client is not connected, if the request could not be
+// sent, if the call failed (*Error), if the connection is closed or if ctx is done before the
+// server responds.
+func (client *JSONRPCClient) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	payload, err := json.Marshal(request{JSONRPC: Version, Method: method, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode jsonrpc request: %w", err)
+	}
+	client.mu.Lock()
+	connected, router := client.connected, client.router
+	client.mu.Unlock()
+	if !connected {
+		return nil, fmt.Errorf("call failed because jsonrpc client is not connected")
+	}
+	data, err := router.Send(ctx, payload)
+	if err != nil {
+		return nil, fmt.Errorf("jsonrpc call %s failed: %w", method, err)
+	}
+	msg, err := decodeMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+// # Description
+//
+// Send a notification to the server. The server does not reply to notifications.
+//
+// # Inputs
+//
+//   - ctx: Context used to write the notification.
+//   - method: Name of the method to notify.
+//   - params: Optional params (usually a struct, a map or a slice) encoded as JSON. Omitted if nil.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is not connected or if the notification could
+// not be sent.
+func (client *JSONRPCClient) Notify(ctx context.Context, method string, params any) error {
+	payload, err := json.Marshal(request{JSONRPC: Version, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode jsonrpc notification: %w", err)
+	}
+	client.mu.Lock()
+	connected, conn := client.connected, client.conn
+	client.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("notify failed because jsonrpc client is not connected")
+	}
+	return conn.Write(ctx, wsadapters.Text, payload)
+}
+
+/*************************************************************************************************/
+/* WEBSOCKET CLIENT CALLBACKS                                                                    */
+/*************************************************************************************************/
+
+// Create the router used to correlate calls and responses.
+func (client *JSONRPCClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	router, err := reqresp.NewRequestResponseRouter(conn, reqresp.DefaultIDField)
+	if err != nil {
+		return err
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn = conn
+	client.router = router
+	client.connected = true
+	return nil
+}
+
+// Route responses to pending calls and dispatch notifications to their handler.
+func (client *JSONRPCClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.mu.Lock()
+	router := client.router
+	client.mu.Unlock()
+	if router != nil && router.Route(msg) {
+		return
+	}
+	decoded, err := decodeMessage(msg)
+	if err != nil || decoded.Method == "" {
+		// Discard invalid messages and responses to unknown calls
+		return
+	}
+	if len(decoded.Id) > 0 && string(decoded.Id) != "null" {
+		// Server request: reply it is not supported. Errors are ignored.
+		reply, _ := json.Marshal(response{
+			JSONRPC: Version,
+			Id:      decoded.Id,
+			Error:   &Error{Code: CodeMethodNotFound, Message: "Method not found"},
+		})
+		conn.Write(ctx, wsadapters.Text, reply)
+		return
+	}
+	client.mu.Lock()
+	handler, ok := client.handlers[decoded.Method]
+	client.mu.Unlock()
+	if ok {
+		handler(decoded.Params)
+	}
+}
+
+// Do nothing - read errors are handled by the engine.
+func (client *JSONRPCClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Fail pending calls as their response will not be received.
+func (client *JSONRPCClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.connected = false
+	if client.router != nil {
+		client.router.CancelPending(fmt.Errorf("jsonrpc connection closed"))
+	}
+	return &wsclient.CloseMessageDetails{
+		CloseReason:  wsadapters.NormalClosure,
+		CloseMessage: "jsonrpc client disconnected",
+	}
+}
+
+// Do nothing.
+func (client *JSONRPCClient) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine retries to connect.
+func (client *JSONRPCClient) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+}
@@ -0,0 +1,210 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test JSONRPCClient with a websocket engine and a fake JSON-RPC server
+type JSONRPCClientIntegrationTestSuite struct {
+	suite.Suite
+	// Fake server
+	server *testServer
+	// Server which hosts the fake server
+	srv *httptest.Server
+}
+
+// Run JSONRPCClientIntegrationTestSuite test suite
+func TestJSONRPCClientIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONRPCClientIntegrationTestSuite))
+}
+
+// Start fake server
+func (suite *JSONRPCClientIntegrationTestSuite) SetupTest() {
+	suite.server = &testServer{}
+	suite.srv = httptest.NewServer(suite.server)
+}
+
+// Stop fake server
+func (suite *JSONRPCClientIntegrationTestSuite) TearDownTest() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test interface compliance.
+func (suite *JSONRPCClientIntegrationTestSuite) TestInterfaceCompliance() {
+	var instance any = NewJSONRPCClient()
+	_, ok := instance.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test calls return their result or the error returned by the server.
+func (suite *JSONRPCClientIntegrationTestSuite) TestCall() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewJSONRPCClient()
+	_, err := client.Call(ctx, "echo", nil)
+	require.Error(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Concurrent calls are demultiplexed
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := client.Call(ctx, "echo", []int{i})
+			require.NoError(suite.T(), err)
+			require.JSONEq(suite.T(), "["+string(rune('0'+i))+"]", string(result))
+		}(i)
+	}
+	wg.Wait()
+	// Failed call
+	_, err = client.Call(ctx, "unknown", nil)
+	rpcErr := new(Error)
+	require.True(suite.T(), errors.As(err, &rpcErr))
+	require.Equal(suite.T(), CodeMethodNotFound, rpcErr.Code)
+}
+
+// Test pending calls fail when the connection is closed.
+func (suite *JSONRPCClientIntegrationTestSuite) TestCallFailsWhenConnectionCloses() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewJSONRPCClient()
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Server closes the connection instead of replying
+	_, err := client.Call(ctx, "close", nil)
+	require.ErrorContains(suite.T(), err, "jsonrpc connection closed")
+}
+
+// Test notifications are sent to the server and server notifications are dispatched to
+// handlers. Server requests are rejected.
+func (suite *JSONRPCClientIntegrationTestSuite) TestNotifications() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewJSONRPCClient()
+	require.Error(suite.T(), client.Notify(ctx, "subscribe", nil))
+	ticks := make(chan json.RawMessage, 10)
+	client.RegisterHandler("tick", func(params json.RawMessage) { ticks <- params })
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Server sends a request then a notification once it receives the subscribe notification
+	require.NoError(suite.T(), client.Notify(ctx, "subscribe", map[string]any{"topic": "ticks"}))
+	select {
+	case params := <-ticks:
+		require.JSONEq(suite.T(), `{"topic":"ticks"}`, string(params))
+	case <-ctx.Done():
+		suite.FailNow("notification should have been received")
+	}
+	require.Eventually(suite.T(), func() bool {
+		return suite.server.lastErrorCode() == CodeMethodNotFound
+	}, 5*time.Second, 10*time.Millisecond)
+	// Unregistered handler
+	client.RegisterHandler("tick", nil)
+	require.Empty(suite.T(), client.handlers)
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Create a websocket engine connected to the fake server
+func (suite *JSONRPCClientIntegrationTestSuite) newEngine(client *JSONRPCClient) *wscengine.WebsocketEngine {
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithReaderRoutinesCount(4)
+	engine, err := wscengine.NewWebsocketEngine(target, gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine
+}
+
+// Minimal JSON-RPC server:
+//   - echo: returns the params.
+//   - close: closes the connection without replying.
+//   - subscribe (notification): sends a request, then a tick notification with the params.
+//   - other methods fail with a method not found error.
+type testServer struct {
+	// Mutex which protects errorCode
+	mu sync.Mutex
+	// Code of the last error response received from the client
+	errorCode int
+}
+
+// Return the code of the last error response received from the client
+func (server *testServer) lastErrorCode() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.errorCode
+}
+
+// Serve a single JSON-RPC connection
+func (server *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	send := func(msg any) error {
+		data, _ := json.Marshal(msg)
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return
+		}
+		switch {
+		case msg.Error != nil:
+			server.mu.Lock()
+			server.errorCode = msg.Error.Code
+			server.mu.Unlock()
+		case msg.Method == "echo":
+			err = send(map[string]any{"jsonrpc": Version, "id": msg.Id, "result": msg.Params})
+		case msg.Method == "close":
+			return
+		case msg.Method == "subscribe":
+			err = send(map[string]any{"jsonrpc": Version, "id": 1, "method": "ping"})
+			if err == nil {
+				err = send(map[string]any{"jsonrpc": Version, "method": "tick", "params": msg.Params})
+			}
+		default:
+			err = send(map[string]any{"jsonrpc": Version, "id": msg.Id, "error": Error{Code: CodeMethodNotFound, Message: "Method not found"}})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
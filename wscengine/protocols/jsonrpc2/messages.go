@@ -0,0 +1,82 @@
+// The package contains a WebsocketClientInterface implementation for JSON-RPC 2.0 over websocket
+// (https://www.jsonrpc.org/specification), as used by Ethereum nodes, language servers and many
+// other services.
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON-RPC protocol version
+const Version = "2.0"
+
+// Error codes defined by the JSON-RPC 2.0 specification
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error object returned by the server when a call fails.
+type Error struct {
+	// Error code
+	Code int `json:"code"`
+	// Short description of the error
+	Message string `json:"message"`
+	// Optional additional information defined by the server
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", err.Code, err.Message)
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Request or notification sent by the client. The request ID is added by the request/response
+// router.
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// Message received from the server: a response to a call, a notification or a request.
+type message struct {
+	JSONRPC string `json:"jsonrpc"`
+	// Request ID - absent for notifications
+	Id json.RawMessage `json:"id,omitempty"`
+	// Method of notifications and requests
+	Method string `json:"method,omitempty"`
+	// Params of notifications and requests
+	Params json.RawMessage `json:"params,omitempty"`
+	// Result of successful calls
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error of failed calls
+	Error *Error `json:"error,omitempty"`
+}
+
+// Response sent by the client to a request from the server.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Error   *Error          `json:"error"`
+}
+
+// Decode a message received from the server.
+func decodeMessage(data []byte) (message, error) {
+	msg := message{}
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return message{}, fmt.Errorf("invalid jsonrpc message: %w", err)
+	}
+	if msg.JSONRPC != Version {
+		return message{}, fmt.Errorf("invalid jsonrpc message: unsupported version %q", msg.JSONRPC)
+	}
+	return msg, nil
+}
@@ -0,0 +1,48 @@
+package jsonrpc2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for JSON-RPC messages unit tests
+type JSONRPCMessagesUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run JSONRPCMessagesUnitTestSuite test suite
+func TestJSONRPCMessagesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONRPCMessagesUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test decodeMessage with responses, notifications and invalid messages.
+func (suite *JSONRPCMessagesUnitTestSuite) TestDecodeMessage() {
+	msg, err := decodeMessage([]byte(`{"jsonrpc":"2.0","id":"a","result":{"value":1}}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), `"a"`, string(msg.Id))
+	require.JSONEq(suite.T(), `{"value":1}`, string(msg.Result))
+	require.Nil(suite.T(), msg.Error)
+	msg, err = decodeMessage([]byte(`{"jsonrpc":"2.0","id":"a","error":{"code":-32602,"message":"Invalid params"}}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), &Error{Code: CodeInvalidParams, Message: "Invalid params"}, msg.Error)
+	require.Equal(suite.T(), "jsonrpc error -32602: Invalid params", msg.Error.Error())
+	msg, err = decodeMessage([]byte(`{"jsonrpc":"2.0","method":"tick","params":[1]}`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "tick", msg.Method)
+	require.Empty(suite.T(), msg.Id)
+	// Invalid messages
+	_, err = decodeMessage([]byte(`{"jsonrpc":"1.0","method":"tick"}`))
+	require.Error(suite.T(), err)
+	_, err = decodeMessage([]byte(`invalid`))
+	require.Error(suite.T(), err)
+}
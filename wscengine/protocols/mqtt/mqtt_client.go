@@ -0,0 +1,459 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// Default capacity of the channels returned by Subscribe.
+const DefaultBufferSize = 16
+
+// Application message received from the broker.
+type MQTTMessage struct {
+	// Topic the message has been published to
+	Topic string
+	// Message payload
+	Payload []byte
+	// Quality of service level used by the broker to deliver the message
+	QoS QoS
+	// Whether the message is a retained message
+	Retain bool
+}
+
+// MQTTClient options.
+type MQTTClientOptions struct {
+	// Client identifier sent in the CONNECT packet. Required.
+	ClientID string
+	// Optional user name used to authenticate with the broker.
+	Username string
+	// Optional password used to authenticate with the broker. Only used if Username is set.
+	Password string
+	// Capacity of the channels returned by Subscribe. DefaultBufferSize is used if 0.
+	BufferSize int
+}
+
+// Subscription managed by the client
+type subscription struct {
+	// Maximum quality of service level requested for the subscription
+	qos QoS
+	// Channel which receives the messages
+	ch chan MQTTMessage
+}
+
+// A WebsocketClientInterface implementation which speaks MQTT 3.1.1 over the websocket connection
+// managed by the websocket engine.
+//
+// The client sends a CONNECT packet for a clean session each time the engine (re)opens the
+// connection and waits for the CONNACK packet from OnOpen. Active subscriptions are automatically
+// renewed when the engine reconnects. QoS 1 and QoS 2 flows are handled for both published and
+// received messages. Messages in flight when the connection is lost are not sent again: Publish
+// returns an error.
+//
+// Subscribe, Unsubscribe and Publish with QoS 1 or 2 wait for the broker acknowledgement which is
+// read by engine goroutines: they must not be called from inside OnOpen or while the engine read
+// mutex is locked.
+type MQTTClient struct {
+	// Client options
+	opts MQTTClientOptions
+	// Mutex used to protect client state
+	mu sync.Mutex
+	// Current connection - set during OnOpen
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Indicates whether the MQTT session is established
+	connected bool
+	// Last packet identifier
+	packetId uint16
+	// Channels used to forward acknowledgements to pending requests by packet identifier
+	pending map[uint16]chan packet
+	// Active subscriptions by topic filter
+	subscriptions map[string]*subscription
+	// Identifiers of the QoS 2 messages received and not released yet by the broker
+	received map[uint16]struct{}
+}
+
+// # Description
+//
+// Factory which creates a new MQTTClient. Provide the client to the websocket engine factory so
+// the engine calls its callbacks.
+//
+// # Returns
+//
+// A new MQTTClient or an error if the client ID is empty or if the buffer size is negative.
+func NewMQTTClient(opts MQTTClientOptions) (*MQTTClient, error) {
+	if opts.ClientID == "" {
+		return nil, fmt.Errorf("client ID must be provided")
+	}
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must be at least 0: %d", opts.BufferSize)
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	return &MQTTClient{
+		opts:          opts,
+		pending:       map[uint16]chan packet{},
+		subscriptions: map[string]*subscription{},
+		received:      map[uint16]struct{}{},
+	}, nil
+}
+
+// # Description
+//
+// Publish a message and wait for the broker acknowledgement: PUBACK for QoS 1, PUBREC and PUBCOMP
+// for QoS 2. QoS 0 messages are not acknowledged.
+//
+// # Inputs
+//
+//   - ctx: Context used to send the message and to bound the time spent waiting for the
+//     acknowledgement.
+//   - topic: Topic to publish the message to.
+//   - payload: Message payload. Can be empty.
+//   - qos: Quality of service level.
+//
+// # Returns
+//
+// Nil in case of success or an error if the QoS is invalid, if the client is not connected, if the
+// message could not be sent, if the connection has been closed or if ctx is done before the
+// broker acknowledges the message.
+func (client *MQTTClient) Publish(ctx context.Context, topic string, payload []byte, qos QoS) error {
+	if qos > QoS2 {
+		return fmt.Errorf("invalid qos: %d", qos)
+	}
+	client.mu.Lock()
+	if !client.connected {
+		client.mu.Unlock()
+		return fmt.Errorf("publish failed because mqtt client is not connected")
+	}
+	if qos == QoS0 {
+		defer client.mu.Unlock()
+		return client.write(ctx, publishPacket(0, topic, payload, qos, false))
+	}
+	id := client.newPacketId()
+	reply, err := client.request(ctx, id, publishPacket(id, topic, payload, qos, false))
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	if qos == QoS1 {
+		return nil
+	}
+	// QoS 2: PUBREC has been received - release the message and wait for PUBCOMP
+	if reply.kind != packetPubrec {
+		return fmt.Errorf("failed to publish to %s: unexpected packet type %d", topic, reply.kind)
+	}
+	client.mu.Lock()
+	_, err = client.request(ctx, id, ackPacket(packetPubrel, id))
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// # Description
+//
+// Subscribe to the provided topic filter and wait for the broker acknowledgement.
+//
+// Messages are dropped if the channel is full. The channel is closed when the subscription is
+// canceled with Unsubscribe. The subscription is renewed when the engine reconnects.
+//
+// # Inputs
+//
+//   - ctx: Context used to send the subscription and to bound the time spent waiting for the
+//     acknowledgement.
+//   - topicFilter: Topic filter which can contain + and # wildcards.
+//   - qos: Maximum quality of service level used by the broker to deliver messages.
+//
+// # Returns
+//
+// The channel which receives the messages published to the matching topics or an error if the
+// QoS is invalid, if the client is not connected, if the client has already subscribed to the
+// topic filter, if the broker refused the subscription or if ctx is done before the broker
+// acknowledges the subscription.
+func (client *MQTTClient) Subscribe(ctx context.Context, topicFilter string, qos QoS) (<-chan MQTTMessage, error) {
+	if qos > QoS2 {
+		return nil, fmt.Errorf("invalid qos: %d", qos)
+	}
+	client.mu.Lock()
+	if !client.connected {
+		client.mu.Unlock()
+		return nil, fmt.Errorf("subscribe failed because mqtt client is not connected")
+	}
+	if _, ok := client.subscriptions[topicFilter]; ok {
+		client.mu.Unlock()
+		return nil, fmt.Errorf("already subscribed to %s", topicFilter)
+	}
+	// Register the subscription before it is sent so retained messages are not missed
+	sub := &subscription{qos: qos, ch: make(chan MQTTMessage, client.opts.BufferSize)}
+	client.subscriptions[topicFilter] = sub
+	id := client.newPacketId()
+	reply, err := client.request(ctx, id, subscribePacket(id, topicFilter, qos))
+	if err == nil && (len(reply.body) < 3 || reply.body[2] == subackFailure) {
+		err = fmt.Errorf("subscription refused by the broker")
+	}
+	if err != nil {
+		client.mu.Lock()
+		delete(client.subscriptions, topicFilter)
+		client.mu.Unlock()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", topicFilter, err)
+	}
+	return sub.ch, nil
+}
+
+// # Description
+//
+// Cancel the subscription to the provided topic filter and close its channel.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client has not subscribed to the topic filter, if the
+// UNSUBSCRIBE packet could not be sent or if ctx is done before the broker acknowledges it.
+func (client *MQTTClient) Unsubscribe(ctx context.Context, topicFilter string) error {
+	client.mu.Lock()
+	sub, ok := client.subscriptions[topicFilter]
+	if !ok {
+		client.mu.Unlock()
+		return fmt.Errorf("not subscribed to %s", topicFilter)
+	}
+	delete(client.subscriptions, topicFilter)
+	close(sub.ch)
+	if !client.connected {
+		client.mu.Unlock()
+		return nil
+	}
+	id := client.newPacketId()
+	_, err := client.request(ctx, id, unsubscribePacket(id, topicFilter))
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from %s: %w", topicFilter, err)
+	}
+	return nil
+}
+
+/*************************************************************************************************/
+/* WEBSOCKET CLIENT CALLBACKS                                                                    */
+/*************************************************************************************************/
+
+// Send a CONNECT packet, wait for the CONNACK packet and renew active subscriptions.
+func (client *MQTTClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn = conn
+	client.connected = false
+	client.received = map[uint16]struct{}{}
+	err := client.write(ctx, connectPacket(client.opts.ClientID, client.opts.Username, client.opts.Password))
+	if err != nil {
+		return fmt.Errorf("failed to send mqtt CONNECT packet: %w", err)
+	}
+	// Engine does not read messages until OnOpen completes: wait for CONNACK
+	for connack := false; !connack; {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read mqtt CONNACK packet: %w", err)
+		}
+		packets, err := decodePackets(data)
+		if err != nil {
+			return err
+		}
+		for _, p := range packets {
+			if p.kind != packetConnack {
+				continue
+			}
+			if len(p.body) < 2 {
+				return fmt.Errorf("invalid mqtt CONNACK packet")
+			}
+			if p.body[1] != 0 {
+				return ConnectError{ReturnCode: p.body[1]}
+			}
+			connack = true
+		}
+	}
+	// Renew subscriptions - SUBACK packets are ignored
+	for topicFilter, sub := range client.subscriptions {
+		err = client.write(ctx, subscribePacket(client.newPacketId(), topicFilter, sub.qos))
+		if err != nil {
+			return fmt.Errorf("failed to renew subscription to %s: %w", topicFilter, err)
+		}
+	}
+	client.connected = true
+	return nil
+}
+
+// Deliver received messages to subscriptions, acknowledge them and forward acknowledgements to
+// pending requests.
+func (client *MQTTClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	packets, err := decodePackets(msg)
+	if err != nil {
+		// Discard invalid messages
+		return
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for _, p := range packets {
+		switch p.kind {
+		case packetPublish:
+			message, id, err := decodePublish(p)
+			if err != nil {
+				continue
+			}
+			switch message.QoS {
+			case QoS0:
+				client.deliver(message)
+			case QoS1:
+				client.deliver(message)
+				client.write(ctx, ackPacket(packetPuback, id))
+			case QoS2:
+				// Deliver the message once until the broker releases it
+				if _, ok := client.received[id]; !ok {
+					client.received[id] = struct{}{}
+					client.deliver(message)
+				}
+				client.write(ctx, ackPacket(packetPubrec, id))
+			}
+		case packetPubrel:
+			if id, err := p.packetId(); err == nil {
+				delete(client.received, id)
+				client.write(ctx, ackPacket(packetPubcomp, id))
+			}
+		case packetPuback, packetPubrec, packetPubcomp, packetSuback, packetUnsuback:
+			if id, err := p.packetId(); err == nil {
+				if ch, ok := client.pending[id]; ok {
+					delete(client.pending, id)
+					ch <- p
+				}
+			}
+		}
+	}
+}
+
+// Do nothing - read errors are handled by the engine.
+func (client *MQTTClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Send a DISCONNECT packet if the connection is still open and fail pending requests.
+func (client *MQTTClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.connected && closeMessage == nil {
+		// Provided context is canceled - use a fresh one. Errors are ignored as the connection
+		// is about to be closed.
+		client.write(context.Background(), packet{kind: packetDisconnect})
+	}
+	client.connected = false
+	// Fail pending requests
+	for id, ch := range client.pending {
+		close(ch)
+		delete(client.pending, id)
+	}
+	return &wsclient.CloseMessageDetails{
+		CloseReason:  wsadapters.NormalClosure,
+		CloseMessage: "mqtt client disconnected",
+	}
+}
+
+// Do nothing.
+func (client *MQTTClient) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine retries to connect.
+func (client *MQTTClient) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Return a packet identifier which is not used by a pending request. Client mutex must be locked.
+func (client *MQTTClient) newPacketId() uint16 {
+	for {
+		client.packetId = client.packetId + 1
+		if client.packetId == 0 {
+			continue
+		}
+		if _, ok := client.pending[client.packetId]; !ok {
+			return client.packetId
+		}
+	}
+}
+
+// Send a packet and wait for the acknowledgement which carries the provided packet identifier.
+// Client mutex must be locked when called and is unlocked when the packet has been sent.
+func (client *MQTTClient) request(ctx context.Context, id uint16, p packet) (packet, error) {
+	ch := make(chan packet, 1)
+	client.pending[id] = ch
+	err := client.write(ctx, p)
+	if err != nil {
+		delete(client.pending, id)
+		client.mu.Unlock()
+		return packet{}, err
+	}
+	client.mu.Unlock()
+	// Wait for acknowledgement
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return packet{}, fmt.Errorf("mqtt connection closed before acknowledgement")
+		}
+		return reply, nil
+	case <-ctx.Done():
+		client.mu.Lock()
+		delete(client.pending, id)
+		client.mu.Unlock()
+		return packet{}, ctx.Err()
+	}
+}
+
+// Deliver a message to the matching subscriptions which are ready to receive it. Client mutex
+// must be locked.
+func (client *MQTTClient) deliver(msg MQTTMessage) {
+	for topicFilter, sub := range client.subscriptions {
+		if matchTopic(topicFilter, msg.Topic) {
+			select {
+			case sub.ch <- msg:
+			default:
+				// Subscriber is not ready - Drop message
+			}
+		}
+	}
+}
+
+// Write a packet on the current connection. Client mutex must be locked.
+func (client *MQTTClient) write(ctx context.Context, p packet) error {
+	if client.conn == nil {
+		return fmt.Errorf("mqtt client has no connection")
+	}
+	return client.conn.Write(ctx, wsadapters.Binary, p.encode())
+}
@@ -0,0 +1,259 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test MQTTClient with a websocket engine and a fake MQTT broker
+type MQTTClientIntegrationTestSuite struct {
+	suite.Suite
+	// Fake broker
+	broker *testBroker
+	// Server which hosts the broker
+	srv *httptest.Server
+}
+
+// Run MQTTClientIntegrationTestSuite test suite
+func TestMQTTClientIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(MQTTClientIntegrationTestSuite))
+}
+
+// Start fake broker
+func (suite *MQTTClientIntegrationTestSuite) SetupTest() {
+	suite.broker = &testBroker{password: "secret"}
+	suite.srv = httptest.NewServer(suite.broker)
+}
+
+// Stop fake broker
+func (suite *MQTTClientIntegrationTestSuite) TearDownTest() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test interface compliance and factory.
+func (suite *MQTTClientIntegrationTestSuite) TestInterfaceCompliance() {
+	_, err := NewMQTTClient(MQTTClientOptions{})
+	require.Error(suite.T(), err)
+	_, err = NewMQTTClient(MQTTClientOptions{ClientID: "client", BufferSize: -1})
+	require.Error(suite.T(), err)
+	client, err := NewMQTTClient(MQTTClientOptions{ClientID: "client"})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), DefaultBufferSize, client.opts.BufferSize)
+	var instance any = client
+	_, ok := instance.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test the client subscribes, publishes and receives messages with all QoS levels, unsubscribes
+// and disconnects.
+func (suite *MQTTClientIntegrationTestSuite) TestPublishSubscribe() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient("secret")
+	_, err := client.Subscribe(ctx, "sensors/+/temp", QoS2)
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), client.Publish(ctx, "sensors/a/temp", []byte("too early"), QoS0))
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	messages, err := client.Subscribe(ctx, "sensors/+/temp", QoS2)
+	require.NoError(suite.T(), err)
+	_, err = client.Subscribe(ctx, "sensors/+/temp", QoS2)
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), client.Publish(ctx, "sensors/a/temp", nil, QoS(3)))
+	// Publish with all QoS levels - broker delivers with the same QoS
+	for _, qos := range []QoS{QoS0, QoS1, QoS2} {
+		require.NoError(suite.T(), client.Publish(ctx, "sensors/a/temp", []byte{byte('0' + qos)}, qos))
+		select {
+		case msg := <-messages:
+			require.Equal(suite.T(), MQTTMessage{Topic: "sensors/a/temp", Payload: []byte{byte('0' + qos)}, QoS: qos}, msg)
+		case <-ctx.Done():
+			suite.FailNow("message should have been received")
+		}
+	}
+	// Messages to other topics are not delivered
+	require.NoError(suite.T(), client.Publish(ctx, "sensors/a/humidity", []byte("ignored"), QoS1))
+	// Broker has received the acknowledgements of the messages it delivered
+	require.Eventually(suite.T(), func() bool {
+		return suite.broker.hasReceived(packetPuback) && suite.broker.hasReceived(packetPubrec) && suite.broker.hasReceived(packetPubcomp)
+	}, 5*time.Second, 10*time.Millisecond)
+	// Unsubscribe closes the channel
+	require.NoError(suite.T(), client.Unsubscribe(ctx, "sensors/+/temp"))
+	require.Error(suite.T(), client.Unsubscribe(ctx, "sensors/+/temp"))
+	for range messages {
+	}
+	// Stop engine - DISCONNECT must be received by the broker
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Eventually(suite.T(), func() bool {
+		return suite.broker.hasReceived(packetDisconnect)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test Subscribe fails when the broker refuses the subscription.
+func (suite *MQTTClientIntegrationTestSuite) TestSubscribeRefused() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := suite.newClient("secret")
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	_, err := client.Subscribe(ctx, "forbidden/#", QoS0)
+	require.Error(suite.T(), err)
+	require.Empty(suite.T(), client.subscriptions)
+}
+
+// Test Start fails when the broker refuses the connection.
+func (suite *MQTTClientIntegrationTestSuite) TestConnectRefused() {
+	client := suite.newClient("wrong")
+	engine := suite.newEngine(client)
+	require.Error(suite.T(), engine.Start(context.Background()))
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Create a client which authenticates with the provided password
+func (suite *MQTTClientIntegrationTestSuite) newClient(password string) *MQTTClient {
+	client, err := NewMQTTClient(MQTTClientOptions{ClientID: "client", Username: "user", Password: password})
+	require.NoError(suite.T(), err)
+	return client
+}
+
+// Create a websocket engine connected to the fake broker
+func (suite *MQTTClientIntegrationTestSuite) newEngine(client *MQTTClient) *wscengine.WebsocketEngine {
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithOnOpenTimeoutMs(5000)
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Sec-WebSocket-Protocol": {Subprotocol}})
+	engine, err := wscengine.NewWebsocketEngine(target, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine
+}
+
+// Minimal MQTT broker which delivers published messages to the matching subscriptions of the
+// session with the QoS of the publication. Subscriptions to forbidden/# are refused.
+type testBroker struct {
+	// Password expected in CONNECT packets
+	password string
+	// Mutex which protects packets
+	mu sync.Mutex
+	// Received packet types
+	packets []byte
+}
+
+// Check whether the broker has received a packet type
+func (broker *testBroker) hasReceived(kind byte) bool {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for _, k := range broker.packets {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve a single MQTT session
+func (broker *testBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{Subprotocol}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != Subprotocol {
+		return
+	}
+	send := func(p packet) error {
+		return conn.WriteMessage(websocket.BinaryMessage, p.encode())
+	}
+	subscriptions := []string{}
+	packetId := uint16(0)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		packets, err := decodePackets(data)
+		if err != nil {
+			return
+		}
+		for _, p := range packets {
+			broker.mu.Lock()
+			broker.packets = append(broker.packets, p.kind)
+			broker.mu.Unlock()
+			switch p.kind {
+			case packetConnect:
+				// Password is the last string of the payload
+				code := byte(5)
+				if strings.HasSuffix(string(p.body), broker.password) {
+					code = 0
+				}
+				err = send(packet{kind: packetConnack, body: []byte{0, code}})
+			case packetSubscribe:
+				id, _ := p.packetId()
+				topicFilter, rest, _ := readString(p.body[2:])
+				returnCode := rest[0]
+				if strings.HasPrefix(topicFilter, "forbidden") {
+					returnCode = subackFailure
+				} else {
+					subscriptions = append(subscriptions, topicFilter)
+				}
+				err = send(packet{kind: packetSuback, body: append(binary.BigEndian.AppendUint16(nil, id), returnCode)})
+			case packetUnsubscribe:
+				id, _ := p.packetId()
+				subscriptions = nil
+				err = send(ackPacket(packetUnsuback, id))
+			case packetPublish:
+				msg, id, _ := decodePublish(p)
+				switch msg.QoS {
+				case QoS1:
+					err = send(ackPacket(packetPuback, id))
+				case QoS2:
+					err = send(ackPacket(packetPubrec, id))
+				}
+				for _, topicFilter := range subscriptions {
+					if err == nil && matchTopic(topicFilter, msg.Topic) {
+						packetId++
+						err = send(publishPacket(packetId, msg.Topic, msg.Payload, msg.QoS, false))
+					}
+				}
+			case packetPubrec:
+				id, _ := p.packetId()
+				err = send(ackPacket(packetPubrel, id))
+			case packetPubrel:
+				id, _ := p.packetId()
+				err = send(ackPacket(packetPubcomp, id))
+			case packetDisconnect:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
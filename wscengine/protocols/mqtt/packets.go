@@ -0,0 +1,268 @@
+// The package contains a WebsocketClientInterface implementation for MQTT 3.1.1 over websocket
+// (https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html).
+//
+// The websocket connection adapter provided to the engine must request the mqtt subprotocol
+// (Sec-WebSocket-Protocol header) as required by MQTT brokers. The client expects each websocket
+// message to carry one or several complete MQTT control packets, which is what brokers do.
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Subprotocol which must be requested during the websocket handshake.
+const Subprotocol = "mqtt"
+
+// Quality of service level of a message.
+type QoS byte
+
+const (
+	// The message is delivered at most once
+	QoS0 QoS = 0
+	// The message is delivered at least once
+	QoS1 QoS = 1
+	// The message is delivered exactly once
+	QoS2 QoS = 2
+)
+
+// MQTT control packet types
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// Return code used in SUBACK packets when the subscription is refused
+const subackFailure byte = 0x80
+
+// Error returned when the broker refuses the connection (CONNACK return code is not 0).
+type ConnectError struct {
+	// CONNACK return code
+	ReturnCode byte
+}
+
+func (err ConnectError) Error() string {
+	reasons := map[byte]string{
+		1: "unacceptable protocol version",
+		2: "identifier rejected",
+		3: "server unavailable",
+		4: "bad user name or password",
+		5: "not authorized",
+	}
+	reason, ok := reasons[err.ReturnCode]
+	if !ok {
+		reason = "unknown return code"
+	}
+	return fmt.Sprintf("mqtt connection refused: %d - %s", err.ReturnCode, reason)
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// A MQTT control packet.
+type packet struct {
+	// Packet type
+	kind byte
+	// Flags of the fixed header
+	flags byte
+	// Variable header and payload
+	body []byte
+}
+
+// Encode the packet: fixed header followed by the body.
+func (p packet) encode() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(p.body)+5))
+	buf.WriteByte(p.kind<<4 | p.flags&0x0F)
+	// Remaining length - variable length encoding
+	length := len(p.body)
+	for {
+		encoded := byte(length % 128)
+		length = length / 128
+		if length > 0 {
+			encoded = encoded | 0x80
+		}
+		buf.WriteByte(encoded)
+		if length == 0 {
+			break
+		}
+	}
+	buf.Write(p.body)
+	return buf.Bytes()
+}
+
+// Return the packet identifier which starts the variable header of acknowledgement packets.
+func (p packet) packetId() (uint16, error) {
+	if len(p.body) < 2 {
+		return 0, fmt.Errorf("invalid mqtt packet: missing packet identifier")
+	}
+	return binary.BigEndian.Uint16(p.body), nil
+}
+
+// Decode all control packets carried by a websocket message.
+func decodePackets(data []byte) ([]packet, error) {
+	packets := []packet{}
+	for len(data) > 0 {
+		p := packet{kind: data[0] >> 4, flags: data[0] & 0x0F}
+		// Decode remaining length
+		length, multiplier, index := 0, 1, 1
+		for {
+			if index >= len(data) || index > 4 {
+				return nil, fmt.Errorf("invalid mqtt packet: malformed remaining length")
+			}
+			encoded := data[index]
+			length = length + int(encoded&0x7F)*multiplier
+			multiplier = multiplier * 128
+			index++
+			if encoded&0x80 == 0 {
+				break
+			}
+		}
+		if length > len(data)-index {
+			return nil, fmt.Errorf("invalid mqtt packet: incomplete packet")
+		}
+		p.body = data[index : index+length]
+		packets = append(packets, p)
+		data = data[index+length:]
+	}
+	return packets, nil
+}
+
+// Build a CONNECT packet for a clean session without keep alive.
+func connectPacket(clientId string, username string, password string) packet {
+	body := &bytes.Buffer{}
+	writeString(body, "MQTT")
+	// Protocol level 4 = 3.1.1
+	body.WriteByte(4)
+	flags := byte(0x02)
+	if username != "" {
+		flags = flags | 0x80
+		if password != "" {
+			flags = flags | 0x40
+		}
+	}
+	body.WriteByte(flags)
+	// Keep alive disabled
+	body.Write([]byte{0, 0})
+	writeString(body, clientId)
+	if username != "" {
+		writeString(body, username)
+		if password != "" {
+			writeString(body, password)
+		}
+	}
+	return packet{kind: packetConnect, body: body.Bytes()}
+}
+
+// Build a PUBLISH packet. The packet identifier is only used if qos > 0.
+func publishPacket(id uint16, topic string, payload []byte, qos QoS, retain bool) packet {
+	body := &bytes.Buffer{}
+	writeString(body, topic)
+	if qos > QoS0 {
+		body.Write(binary.BigEndian.AppendUint16(nil, id))
+	}
+	body.Write(payload)
+	flags := byte(qos) << 1
+	if retain {
+		flags = flags | 0x01
+	}
+	return packet{kind: packetPublish, flags: flags, body: body.Bytes()}
+}
+
+// Decode a PUBLISH packet.
+func decodePublish(p packet) (MQTTMessage, uint16, error) {
+	msg := MQTTMessage{QoS: QoS((p.flags >> 1) & 0x03), Retain: p.flags&0x01 != 0}
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return MQTTMessage{}, 0, err
+	}
+	msg.Topic = topic
+	var id uint16
+	if msg.QoS > QoS0 {
+		if len(rest) < 2 {
+			return MQTTMessage{}, 0, fmt.Errorf("invalid mqtt packet: missing packet identifier")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	msg.Payload = append([]byte(nil), rest...)
+	return msg, id, nil
+}
+
+// Build an acknowledgement packet (PUBACK, PUBREC, PUBREL, PUBCOMP).
+func ackPacket(kind byte, id uint16) packet {
+	flags := byte(0)
+	if kind == packetPubrel {
+		flags = 0x02
+	}
+	return packet{kind: kind, flags: flags, body: binary.BigEndian.AppendUint16(nil, id)}
+}
+
+// Build a SUBSCRIBE packet for a single topic filter.
+func subscribePacket(id uint16, topicFilter string, qos QoS) packet {
+	body := bytes.NewBuffer(binary.BigEndian.AppendUint16(nil, id))
+	writeString(body, topicFilter)
+	body.WriteByte(byte(qos))
+	return packet{kind: packetSubscribe, flags: 0x02, body: body.Bytes()}
+}
+
+// Build an UNSUBSCRIBE packet for a single topic filter.
+func unsubscribePacket(id uint16, topicFilter string) packet {
+	body := bytes.NewBuffer(binary.BigEndian.AppendUint16(nil, id))
+	writeString(body, topicFilter)
+	return packet{kind: packetUnsubscribe, flags: 0x02, body: body.Bytes()}
+}
+
+// Write a length prefixed UTF-8 string.
+func writeString(buf *bytes.Buffer, value string) {
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(value))))
+	buf.WriteString(value)
+}
+
+// Read a length prefixed UTF-8 string and return the remaining data.
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, fmt.Errorf("invalid mqtt packet: missing string length")
+	}
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+length {
+		return "", nil, fmt.Errorf("invalid mqtt packet: incomplete string")
+	}
+	return string(data[2 : 2+length]), data[2+length:], nil
+}
+
+// Check whether a topic matches a topic filter which can contain + and # wildcards.
+func matchTopic(topicFilter string, topic string) bool {
+	filterLevels := strings.Split(topicFilter, "/")
+	topicLevels := strings.Split(topic, "/")
+	// Topics which start with $ are not matched by filters which start with a wildcard
+	if strings.HasPrefix(topic, "$") && (filterLevels[0] == "+" || filterLevels[0] == "#") {
+		return false
+	}
+	for index, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if index >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[index] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
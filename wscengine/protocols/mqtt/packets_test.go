@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for MQTT packets unit tests
+type MQTTPacketsUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run MQTTPacketsUnitTestSuite test suite
+func TestMQTTPacketsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MQTTPacketsUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test packets are encoded and decoded, including large packets and several packets in the same
+// message.
+func (suite *MQTTPacketsUnitTestSuite) TestEncodeDecodePackets() {
+	large := bytes.Repeat([]byte("x"), 20000)
+	data := append(publishPacket(10, "a/b", large, QoS1, true).encode(), ackPacket(packetPubrel, 11).encode()...)
+	// Remaining length uses 3 bytes
+	require.Equal(suite.T(), []byte{0x33, 0xA7, 0x9C, 0x01}, data[:4])
+	packets, err := decodePackets(data)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), packets, 2)
+	msg, id, err := decodePublish(packets[0])
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), uint16(10), id)
+	require.Equal(suite.T(), MQTTMessage{Topic: "a/b", Payload: large, QoS: QoS1, Retain: true}, msg)
+	require.Equal(suite.T(), packetPubrel, packets[1].kind)
+	require.Equal(suite.T(), byte(0x02), packets[1].flags)
+	id, err = packets[1].packetId()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), uint16(11), id)
+	// Invalid packets
+	_, err = decodePackets([]byte{0x30, 0x05, 0x00})
+	require.Error(suite.T(), err)
+	_, err = decodePackets([]byte{0x30, 0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+	require.Error(suite.T(), err)
+}
+
+// Test the CONNECT packet.
+func (suite *MQTTPacketsUnitTestSuite) TestConnectPacket() {
+	p := connectPacket("id", "user", "pass")
+	require.Equal(suite.T(), packetConnect, p.kind)
+	expected := []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0xC2, 0, 0, 0, 2, 'i', 'd', 0, 4, 'u', 's', 'e', 'r', 0, 4, 'p', 'a', 's', 's'}
+	require.Equal(suite.T(), expected, p.body)
+	// Without credentials
+	p = connectPacket("id", "", "pass")
+	require.Equal(suite.T(), []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0x02, 0, 0, 0, 2, 'i', 'd'}, p.body)
+}
+
+// Test topic filters matching.
+func (suite *MQTTPacketsUnitTestSuite) TestMatchTopic() {
+	cases := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+		{"a/b/c", "a/b", false},
+	}
+	for _, c := range cases {
+		require.Equal(suite.T(), c.match, matchTopic(c.filter, c.topic), "%s - %s", c.filter, c.topic)
+	}
+}
+
+// Test ConnectError messages.
+func (suite *MQTTPacketsUnitTestSuite) TestConnectError() {
+	require.Equal(suite.T(), "mqtt connection refused: 5 - not authorized", ConnectError{ReturnCode: 5}.Error())
+	require.Equal(suite.T(), "mqtt connection refused: 42 - unknown return code", ConnectError{ReturnCode: 42}.Error())
+}
@@ -0,0 +1,207 @@
+// The package contains a WebsocketClientInterface implementation for the STOMP 1.2 protocol over
+// websocket (https://stomp.github.io/stomp-specification-1.2.html).
+package stomp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// STOMP commands
+const (
+	// Client commands
+	CommandConnect     = "CONNECT"
+	CommandSend        = "SEND"
+	CommandSubscribe   = "SUBSCRIBE"
+	CommandUnsubscribe = "UNSUBSCRIBE"
+	CommandDisconnect  = "DISCONNECT"
+	// Server commands
+	CommandConnected = "CONNECTED"
+	CommandMessage   = "MESSAGE"
+	CommandReceipt   = "RECEIPT"
+	CommandError     = "ERROR"
+)
+
+// STOMP headers used by the client
+const (
+	HeaderAcceptVersion = "accept-version"
+	HeaderHost          = "host"
+	HeaderLogin         = "login"
+	HeaderPasscode      = "passcode"
+	HeaderHeartBeat     = "heart-beat"
+	HeaderDestination   = "destination"
+	HeaderId            = "id"
+	HeaderAck           = "ack"
+	HeaderSubscription  = "subscription"
+	HeaderContentLength = "content-length"
+	HeaderMessage       = "message"
+	HeaderReceipt       = "receipt"
+	HeaderReceiptId     = "receipt-id"
+)
+
+// A STOMP frame.
+type STOMPFrame struct {
+	// Frame command (CONNECT, SEND, MESSAGE, ...)
+	Command string
+	// Frame headers
+	Headers map[string]string
+	// Frame body. Can be empty.
+	Body []byte
+}
+
+// # Description
+//
+// Encode the frame. Header names and values are escaped except for CONNECT and CONNECTED frames
+// as required by the STOMP 1.2 specification. A content-length header is added when the frame
+// has a body and does not already have one. Headers are written in lexicographic order so
+// encoding is deterministic.
+//
+// # Returns
+//
+// The encoded frame, terminated by a NULL octet.
+func (frame STOMPFrame) Encode() []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString(frame.Command)
+	buf.WriteByte('\n')
+	escape := frame.Command != CommandConnect && frame.Command != CommandConnected
+	// Sort header names
+	names := make([]string, 0, len(frame.Headers)+1)
+	for name := range frame.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHeader(&buf, name, frame.Headers[name], escape)
+	}
+	if _, ok := frame.Headers[HeaderContentLength]; !ok && len(frame.Body) > 0 {
+		writeHeader(&buf, HeaderContentLength, strconv.Itoa(len(frame.Body)), escape)
+	}
+	buf.WriteByte('\n')
+	buf.Write(frame.Body)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+// # Description
+//
+// Decode a single STOMP frame. Header values are unescaped except for CONNECT and CONNECTED
+// frames. If a header is repeated, the first value is used.
+//
+// # Returns
+//
+// The decoded frame or an error if data is not a valid STOMP frame.
+func DecodeFrame(data []byte) (STOMPFrame, error) {
+	frame := STOMPFrame{Headers: map[string]string{}}
+	// Skip heart-beats and EOLs which may precede the frame
+	data = bytes.TrimLeft(data, "\r\n")
+	// Read command
+	line, rest, ok := readLine(data)
+	if !ok || line == "" {
+		return STOMPFrame{}, fmt.Errorf("invalid stomp frame: missing command")
+	}
+	frame.Command = line
+	unescape := frame.Command != CommandConnect && frame.Command != CommandConnected
+	// Read headers until empty line
+	for {
+		line, rest, ok = readLine(rest)
+		if !ok {
+			return STOMPFrame{}, fmt.Errorf("invalid stomp frame: missing end of headers")
+		}
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return STOMPFrame{}, fmt.Errorf("invalid stomp frame: invalid header: %s", line)
+		}
+		if unescape {
+			var err error
+			if name, err = unescapeHeader(name); err != nil {
+				return STOMPFrame{}, err
+			}
+			if value, err = unescapeHeader(value); err != nil {
+				return STOMPFrame{}, err
+			}
+		}
+		if _, exists := frame.Headers[name]; !exists {
+			frame.Headers[name] = value
+		}
+	}
+	// Read body
+	if cl, ok := frame.Headers[HeaderContentLength]; ok {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 || length >= len(rest) || rest[length] != 0 {
+			return STOMPFrame{}, fmt.Errorf("invalid stomp frame: invalid content-length: %s", cl)
+		}
+		frame.Body = rest[:length]
+	} else {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return STOMPFrame{}, fmt.Errorf("invalid stomp frame: missing NULL terminator")
+		}
+		frame.Body = rest[:end]
+	}
+	return frame, nil
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Header escaper used by STOMP 1.2
+var headerEscaper = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+
+// Write a header line.
+func writeHeader(buf *bytes.Buffer, name string, value string, escape bool) {
+	if escape {
+		name = headerEscaper.Replace(name)
+		value = headerEscaper.Replace(value)
+	}
+	buf.WriteString(name)
+	buf.WriteByte(':')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// Unescape a header name or value. Undefined escape sequences are errors.
+func unescapeHeader(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	sb := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			sb.WriteByte(value[i])
+			continue
+		}
+		i++
+		if i >= len(value) {
+			return "", fmt.Errorf("invalid stomp frame: invalid escape sequence in header: %s", value)
+		}
+		switch value[i] {
+		case 'r':
+			sb.WriteByte('\r')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'c':
+			sb.WriteByte(':')
+		case '\\':
+			sb.WriteByte('\\')
+		default:
+			return "", fmt.Errorf("invalid stomp frame: invalid escape sequence in header: %s", value)
+		}
+	}
+	return sb.String(), nil
+}
+
+// Read a line terminated by LF or CRLF.
+func readLine(data []byte) (string, []byte, bool) {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return "", data, false
+	}
+	return string(bytes.TrimSuffix(data[:end], []byte{'\r'})), data[end+1:], true
+}
@@ -0,0 +1,77 @@
+package stomp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for STOMPFrame unit tests
+type STOMPFrameUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run STOMPFrameUnitTestSuite test suite
+func TestSTOMPFrameUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(STOMPFrameUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Encode output.
+func (suite *STOMPFrameUnitTestSuite) TestEncode() {
+	frame := STOMPFrame{
+		Command: CommandSend,
+		Headers: map[string]string{HeaderDestination: "/queue/a", "x-key": "a:b\nc"},
+		Body:    []byte("hello"),
+	}
+	expected := "SEND\ndestination:/queue/a\nx-key:a\\cb\\nc\ncontent-length:5\n\nhello\x00"
+	require.Equal(suite.T(), expected, string(frame.Encode()))
+	// CONNECT headers are not escaped
+	connect := STOMPFrame{Command: CommandConnect, Headers: map[string]string{"passcode": "a:b"}}
+	require.Equal(suite.T(), "CONNECT\npasscode:a:b\n\n\x00", string(connect.Encode()))
+}
+
+// Test Encode and Decode round trip.
+func (suite *STOMPFrameUnitTestSuite) TestRoundTrip() {
+	frame := STOMPFrame{
+		Command: CommandMessage,
+		Headers: map[string]string{HeaderSubscription: "sub-1", "x-key": "a:b\\c", HeaderContentLength: "4"},
+		Body:    []byte{'a', 0, 'b', 'c'},
+	}
+	decoded, err := DecodeFrame(frame.Encode())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), frame, decoded)
+}
+
+// Test Decode with CRLF, leading heart-beats, repeated headers and no content-length.
+func (suite *STOMPFrameUnitTestSuite) TestDecode() {
+	decoded, err := DecodeFrame([]byte("\r\n\nMESSAGE\r\nfoo:first\r\nfoo:second\r\n\r\nbody\x00\n"))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), CommandMessage, decoded.Command)
+	require.Equal(suite.T(), "first", decoded.Headers["foo"])
+	require.Equal(suite.T(), "body", string(decoded.Body))
+}
+
+// Test Decode with invalid frames.
+func (suite *STOMPFrameUnitTestSuite) TestDecodeInvalidFrames() {
+	for _, data := range []string{
+		"",
+		"MESSAGE\nfoo:bar\n",
+		"MESSAGE\ninvalid\n\n\x00",
+		"MESSAGE\nfoo:\\t\n\n\x00",
+		"MESSAGE\n\nbody",
+		"MESSAGE\ncontent-length:10\n\nbody\x00",
+		"MESSAGE\ncontent-length:abc\n\nbody\x00",
+	} {
+		_, err := DecodeFrame([]byte(data))
+		require.Error(suite.T(), err, data)
+	}
+}
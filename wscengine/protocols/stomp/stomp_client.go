@@ -0,0 +1,572 @@
+package stomp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// Identifier of a subscription.
+type SubscriptionID string
+
+// STOMPClient options.
+type STOMPClientOptions struct {
+	// Virtual host sent in the CONNECT frame host header. Required by STOMP 1.2 brokers.
+	Host string
+	// Optional login used to authenticate with the broker.
+	Login string
+	// Optional passcode used to authenticate with the broker.
+	Passcode string
+	// Optional handler called when an ERROR frame is received after the client has connected.
+	OnError func(frame STOMPFrame)
+}
+
+// Subscription managed by the client
+type subscription struct {
+	// Subscribed destination
+	destination string
+	// Handler called for each received message
+	handler func(frame STOMPFrame)
+	// Optional function called when the subscription is canceled
+	cancel func()
+}
+
+// Channel which receives the messages of a subscription created with SubscribeChannel
+type channelSubscription struct {
+	// Mutex used to protect ch and closed
+	mu sync.Mutex
+	// Channel which receives the messages
+	ch chan STOMPFrame
+	// Whether ch has been closed
+	closed bool
+}
+
+// A WebsocketClientInterface implementation which speaks STOMP 1.2 over the websocket connection
+// managed by the websocket engine.
+//
+// The client sends a CONNECT frame each time the engine (re)opens the connection and waits for
+// the CONNECTED frame from OnOpen. Active subscriptions are automatically renewed when the engine
+// reconnects. Received MESSAGE frames are dispatched to the handler of their subscription.
+//
+// RECEIPT frames are used to confirm frames sent with SendWithReceipt and Disconnect.
+type STOMPClient struct {
+	// Client options
+	opts STOMPClientOptions
+	// Mutex used to protect client state
+	mu sync.Mutex
+	// Current connection - set during OnOpen
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Indicates whether the STOMP session is established
+	connected bool
+	// Channel closed once the STOMP session is established - replaced when the session ends
+	ready chan struct{}
+	// Active subscriptions
+	subscriptions map[SubscriptionID]subscription
+	// Counter used to build subscription IDs and receipts
+	nextId int
+	// Channels used to forward the broker confirmation to pending receipts by receipt ID
+	receipts map[string]chan error
+	// Receipt of the DISCONNECT frame sent by Disconnect - empty if none
+	disconnectReceipt string
+}
+
+// # Description
+//
+// Factory which creates a new STOMPClient. Provide the client to the websocket engine factory so
+// the engine calls its callbacks.
+func NewSTOMPClient(opts STOMPClientOptions) *STOMPClient {
+	return &STOMPClient{
+		opts:          opts,
+		ready:         make(chan struct{}),
+		subscriptions: map[SubscriptionID]subscription{},
+		receipts:      map[string]chan error{},
+	}
+}
+
+// # Description
+//
+// Wait until the STOMP session is established. The client establishes the session each time the
+// engine (re)opens the websocket connection: Connect can be used to wait for the client to be
+// ready when the engine is started in the background or is reconnecting.
+//
+// # Returns
+//
+// Nil once the session is established or the context error if ctx is done before.
+func (client *STOMPClient) Connect(ctx context.Context) error {
+	client.mu.Lock()
+	ready := client.ready
+	client.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// # Description
+//
+// Gracefully close the STOMP session: send a DISCONNECT frame, wait for the broker to confirm
+// all frames have been processed and stop the engine for good. No frames can be sent once
+// Disconnect has been called.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is not connected, if the DISCONNECT frame
+// could not be sent or if ctx is done before the broker confirms the disconnection.
+func (client *STOMPClient) Disconnect(ctx context.Context) error {
+	client.mu.Lock()
+	if !client.connected {
+		client.mu.Unlock()
+		return fmt.Errorf("disconnect failed because stomp client is not connected")
+	}
+	receipt, ch := client.newReceipt()
+	err := client.write(ctx, STOMPFrame{
+		Command: CommandDisconnect,
+		Headers: map[string]string{HeaderReceipt: receipt},
+	})
+	if err != nil {
+		delete(client.receipts, receipt)
+		client.mu.Unlock()
+		return fmt.Errorf("failed to send stomp DISCONNECT frame: %w", err)
+	}
+	client.connected = false
+	client.disconnectReceipt = receipt
+	client.mu.Unlock()
+	return client.waitReceipt(ctx, receipt, ch)
+}
+
+// # Description
+//
+// Subscribe to the provided destination. The provided handler is called for each MESSAGE frame
+// received for the subscription. The handler is called by engine goroutines and must be safe for
+// concurrent use if the engine uses several reader goroutines.
+//
+// If the client is not connected yet, the SUBSCRIBE frame is sent once the client connects.
+//
+// # Returns
+//
+// The subscription ID which can be used to unsubscribe or an error if the SUBSCRIBE frame could
+// not be sent.
+func (client *STOMPClient) Subscribe(destination string, handler func(frame STOMPFrame)) (SubscriptionID, error) {
+	if handler == nil {
+		return "", fmt.Errorf("provided handler is nil")
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.subscribe(destination, subscription{destination: destination, handler: handler})
+}
+
+// # Description
+//
+// Subscribe to the provided destination and receive MESSAGE frames on the returned channel.
+// Messages are dropped if the channel is full. The channel is closed when the subscription is
+// canceled with Unsubscribe.
+//
+// If the client is not connected yet, the SUBSCRIBE frame is sent once the client connects.
+//
+// # Inputs
+//
+//   - destination: Destination to subscribe to.
+//   - bufferSize: Capacity of the returned channel. Must be at least 0.
+//
+// # Returns
+//
+// The subscription ID which can be used to unsubscribe, the channel which receives the messages
+// or an error if bufferSize is negative or if the SUBSCRIBE frame could not be sent.
+func (client *STOMPClient) SubscribeChannel(destination string, bufferSize int) (SubscriptionID, <-chan STOMPFrame, error) {
+	if bufferSize < 0 {
+		return "", nil, fmt.Errorf("buffer size must be at least 0: %d", bufferSize)
+	}
+	sub := &channelSubscription{ch: make(chan STOMPFrame, bufferSize)}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	id, err := client.subscribe(destination, subscription{destination: destination, handler: sub.deliver, cancel: sub.close})
+	if err != nil {
+		return "", nil, err
+	}
+	return id, sub.ch, nil
+}
+
+// # Description
+//
+// Cancel the subscription with the provided ID.
+//
+// # Returns
+//
+// Nil in case of success or an error if the subscription does not exist or if the UNSUBSCRIBE
+// frame could not be sent.
+func (client *STOMPClient) Unsubscribe(id SubscriptionID) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	sub, ok := client.subscriptions[id]
+	if !ok {
+		return fmt.Errorf("unknown subscription: %s", id)
+	}
+	delete(client.subscriptions, id)
+	if sub.cancel != nil {
+		sub.cancel()
+	}
+	if client.connected {
+		err := client.write(context.Background(), STOMPFrame{
+			Command: CommandUnsubscribe,
+			Headers: map[string]string{HeaderId: string(id)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to unsubscribe %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// # Description
+//
+// Send a message to the provided destination.
+//
+// # Inputs
+//
+//   - destination: Destination of the message.
+//   - body: Message body. Can be empty.
+//   - headers: Optional additional headers. Destination and content-length headers are set by
+//     the client.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is not connected or if the frame could not be
+// sent.
+func (client *STOMPClient) Send(destination string, body []byte, headers map[string]string) error {
+	frame := sendFrame(destination, body, headers)
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.connected {
+		return fmt.Errorf("send failed because stomp client is not connected")
+	}
+	return client.write(context.Background(), frame)
+}
+
+// # Description
+//
+// Send a message to the provided destination and wait for the broker to confirm it has been
+// processed with a RECEIPT frame.
+//
+// # Inputs
+//
+//   - ctx: Context used to cancel the wait for the receipt.
+//   - destination: Destination of the message.
+//   - body: Message body. Can be empty.
+//   - headers: Optional additional headers. Destination, content-length and receipt headers are
+//     set by the client.
+//
+// # Returns
+//
+// Nil once the broker has confirmed the message or an error if the client is not connected, if
+// the frame could not be sent, if the broker replied with an ERROR frame, if the session has been
+// closed or if ctx is done before the broker confirms the message.
+func (client *STOMPClient) SendWithReceipt(ctx context.Context, destination string, body []byte, headers map[string]string) error {
+	frame := sendFrame(destination, body, headers)
+	client.mu.Lock()
+	if !client.connected {
+		client.mu.Unlock()
+		return fmt.Errorf("send failed because stomp client is not connected")
+	}
+	receipt, ch := client.newReceipt()
+	frame.Headers[HeaderReceipt] = receipt
+	err := client.write(ctx, frame)
+	if err != nil {
+		delete(client.receipts, receipt)
+		client.mu.Unlock()
+		return err
+	}
+	client.mu.Unlock()
+	return client.waitReceipt(ctx, receipt, ch)
+}
+
+/*************************************************************************************************/
+/* WEBSOCKET CLIENT CALLBACKS                                                                    */
+/*************************************************************************************************/
+
+// Send a CONNECT frame, wait for the CONNECTED frame and renew active subscriptions.
+func (client *STOMPClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn = conn
+	client.connected = false
+	client.disconnectReceipt = ""
+	// Send CONNECT frame
+	connect := STOMPFrame{
+		Command: CommandConnect,
+		Headers: map[string]string{
+			HeaderAcceptVersion: "1.2",
+			HeaderHost:          client.opts.Host,
+			HeaderHeartBeat:     "0,0",
+		},
+	}
+	if client.opts.Login != "" {
+		connect.Headers[HeaderLogin] = client.opts.Login
+		connect.Headers[HeaderPasscode] = client.opts.Passcode
+	}
+	err := client.write(ctx, connect)
+	if err != nil {
+		return fmt.Errorf("failed to send stomp CONNECT frame: %w", err)
+	}
+	// Engine does not read messages until OnOpen completes: wait for CONNECTED frame
+	for {
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read stomp CONNECTED frame: %w", err)
+		}
+		if isHeartBeat(msg) {
+			continue
+		}
+		frame, err := DecodeFrame(msg)
+		if err != nil {
+			return err
+		}
+		if frame.Command == CommandError {
+			return fmt.Errorf("stomp broker refused connection: %s", frame.Headers[HeaderMessage])
+		}
+		if frame.Command == CommandConnected {
+			break
+		}
+	}
+	client.connected = true
+	// Renew subscriptions
+	for id, sub := range client.subscriptions {
+		err = client.write(ctx, subscribeFrame(id, sub.destination))
+		if err != nil {
+			return fmt.Errorf("failed to renew subscription to %s: %w", sub.destination, err)
+		}
+	}
+	close(client.ready)
+	return nil
+}
+
+// Decode received frames and dispatch MESSAGE frames to subscription handlers.
+func (client *STOMPClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	if isHeartBeat(msg) {
+		return
+	}
+	frame, err := DecodeFrame(msg)
+	if err != nil {
+		// Discard invalid frames
+		return
+	}
+	switch frame.Command {
+	case CommandMessage:
+		client.mu.Lock()
+		sub, ok := client.subscriptions[SubscriptionID(frame.Headers[HeaderSubscription])]
+		client.mu.Unlock()
+		if ok {
+			sub.handler(frame)
+		}
+	case CommandReceipt:
+		client.mu.Lock()
+		disconnected := frame.Headers[HeaderReceiptId] == client.disconnectReceipt && client.disconnectReceipt != ""
+		client.confirmReceipt(frame.Headers[HeaderReceiptId], nil)
+		client.mu.Unlock()
+		if disconnected {
+			// Broker has processed the DISCONNECT frame: stop the engine
+			exit()
+		}
+	case CommandError:
+		client.mu.Lock()
+		client.confirmReceipt(frame.Headers[HeaderReceiptId], fmt.Errorf("stomp broker reported an error: %s", frame.Headers[HeaderMessage]))
+		client.mu.Unlock()
+		if client.opts.OnError != nil {
+			client.opts.OnError(frame)
+		}
+		// Broker closes the connection after an ERROR frame: restart
+		restart()
+	}
+}
+
+// Do nothing - read errors are handled by the engine.
+func (client *STOMPClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Send a DISCONNECT frame if the connection is still open. The engine then closes the connection.
+func (client *STOMPClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.connected && closeMessage == nil {
+		// Provided context is canceled - use a fresh one. Errors are ignored as the connection
+		// is about to be closed.
+		client.write(context.Background(), STOMPFrame{Command: CommandDisconnect, Headers: map[string]string{}})
+	}
+	client.connected = false
+	// Reset ready channel if the session has been established
+	select {
+	case <-client.ready:
+		client.ready = make(chan struct{})
+	default:
+	}
+	// Fail pending receipts
+	for receipt := range client.receipts {
+		client.confirmReceipt(receipt, fmt.Errorf("stomp session closed before receipt %s", receipt))
+	}
+	return &wsclient.CloseMessageDetails{
+		CloseReason:  wsadapters.NormalClosure,
+		CloseMessage: "stomp client disconnected",
+	}
+}
+
+// Do nothing.
+func (client *STOMPClient) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine retries to connect.
+func (client *STOMPClient) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Register a subscription and send the SUBSCRIBE frame if connected. Client mutex must be locked.
+func (client *STOMPClient) subscribe(destination string, sub subscription) (SubscriptionID, error) {
+	client.nextId = client.nextId + 1
+	id := SubscriptionID("sub-" + strconv.Itoa(client.nextId))
+	if client.connected {
+		err := client.write(context.Background(), subscribeFrame(id, destination))
+		if err != nil {
+			if sub.cancel != nil {
+				sub.cancel()
+			}
+			return "", fmt.Errorf("failed to subscribe to %s: %w", destination, err)
+		}
+	}
+	client.subscriptions[id] = sub
+	return id, nil
+}
+
+// Register a new pending receipt. Client mutex must be locked.
+func (client *STOMPClient) newReceipt() (string, chan error) {
+	client.nextId = client.nextId + 1
+	receipt := "receipt-" + strconv.Itoa(client.nextId)
+	ch := make(chan error, 1)
+	client.receipts[receipt] = ch
+	return receipt, ch
+}
+
+// Forward the broker confirmation (nil) or error to the pending receipt if any. Client mutex
+// must be locked.
+func (client *STOMPClient) confirmReceipt(receipt string, err error) {
+	if ch, ok := client.receipts[receipt]; ok {
+		delete(client.receipts, receipt)
+		ch <- err
+	}
+}
+
+// Wait for the broker confirmation of a pending receipt.
+func (client *STOMPClient) waitReceipt(ctx context.Context, receipt string, ch chan error) error {
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		client.mu.Lock()
+		delete(client.receipts, receipt)
+		client.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Write a frame on the current connection. Client mutex must be locked.
+func (client *STOMPClient) write(ctx context.Context, frame STOMPFrame) error {
+	if client.conn == nil {
+		return fmt.Errorf("stomp client has no connection")
+	}
+	return client.conn.Write(ctx, wsadapters.Text, frame.Encode())
+}
+
+// Build a SEND frame.
+func sendFrame(destination string, body []byte, headers map[string]string) STOMPFrame {
+	frame := STOMPFrame{
+		Command: CommandSend,
+		Headers: make(map[string]string, len(headers)+3),
+		Body:    body,
+	}
+	for name, value := range headers {
+		frame.Headers[name] = value
+	}
+	frame.Headers[HeaderDestination] = destination
+	frame.Headers[HeaderContentLength] = strconv.Itoa(len(body))
+	return frame
+}
+
+// Build a SUBSCRIBE frame.
+func subscribeFrame(id SubscriptionID, destination string) STOMPFrame {
+	return STOMPFrame{
+		Command: CommandSubscribe,
+		Headers: map[string]string{
+			HeaderId:          string(id),
+			HeaderDestination: destination,
+			HeaderAck:         "auto",
+		},
+	}
+}
+
+// Check whether the message only contains EOLs (heart-beat).
+func isHeartBeat(msg []byte) bool {
+	return len(bytes.Trim(msg, "\r\n")) == 0
+}
+
+// Deliver the frame if the channel is not closed and is ready to receive it.
+func (sub *channelSubscription) deliver(frame STOMPFrame) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	select {
+	case sub.ch <- frame:
+	default:
+		// Channel is full - Drop message
+	}
+}
+
+// Close the channel once.
+func (sub *channelSubscription) close() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,256 @@
+package stomp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test STOMPClient with a websocket engine and a fake STOMP broker
+type STOMPClientIntegrationTestSuite struct {
+	suite.Suite
+	// Fake broker
+	broker *testBroker
+	// Server which hosts the broker
+	srv *httptest.Server
+}
+
+// Run STOMPClientIntegrationTestSuite test suite
+func TestSTOMPClientIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(STOMPClientIntegrationTestSuite))
+}
+
+// Start fake broker
+func (suite *STOMPClientIntegrationTestSuite) SetupTest() {
+	suite.broker = &testBroker{passcode: "secret"}
+	suite.srv = httptest.NewServer(suite.broker)
+}
+
+// Stop fake broker
+func (suite *STOMPClientIntegrationTestSuite) TearDownTest() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test interface compliance.
+func (suite *STOMPClientIntegrationTestSuite) TestInterfaceCompliance() {
+	var instance any = NewSTOMPClient(STOMPClientOptions{})
+	_, ok := instance.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test the client connects, subscribes, sends and receives messages, unsubscribes and disconnects.
+func (suite *STOMPClientIntegrationTestSuite) TestSession() {
+	client := NewSTOMPClient(STOMPClientOptions{Host: "localhost", Login: "user", Passcode: "secret"})
+	// Subscribe before connecting
+	received := make(chan STOMPFrame, 10)
+	id, err := client.Subscribe("/topic/a", func(frame STOMPFrame) { received <- frame })
+	require.NoError(suite.T(), err)
+	// Sending before connecting fails
+	require.Error(suite.T(), client.Send("/topic/a", []byte("too early"), nil))
+	// Start engine
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(context.Background()))
+	// Send message and wait for it
+	require.NoError(suite.T(), client.Send("/topic/a", []byte("hello"), map[string]string{"x-key": "value"}))
+	select {
+	case frame := <-received:
+		require.Equal(suite.T(), CommandMessage, frame.Command)
+		require.Equal(suite.T(), string(id), frame.Headers[HeaderSubscription])
+		require.Equal(suite.T(), "value", frame.Headers["x-key"])
+		require.Equal(suite.T(), "hello", string(frame.Body))
+	case <-time.After(5 * time.Second):
+		suite.FailNow("message should have been received")
+	}
+	// Unsubscribe
+	require.NoError(suite.T(), client.Unsubscribe(id))
+	require.Error(suite.T(), client.Unsubscribe(id))
+	// Stop engine - DISCONNECT must be received by the broker
+	require.NoError(suite.T(), engine.Stop(context.Background()))
+	require.Eventually(suite.T(), func() bool {
+		return suite.broker.hasReceived(CommandUnsubscribe) && suite.broker.hasReceived(CommandDisconnect)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test messages are delivered to channel subscriptions and sends are confirmed by receipts.
+func (suite *STOMPClientIntegrationTestSuite) TestSubscribeChannelAndReceipts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewSTOMPClient(STOMPClientOptions{Host: "localhost", Login: "user", Passcode: "secret"})
+	_, _, err := client.SubscribeChannel("/topic/a", -1)
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), client.SendWithReceipt(ctx, "/topic/a", []byte("too early"), nil))
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	id, messages, err := client.SubscribeChannel("/topic/a", 10)
+	require.NoError(suite.T(), err)
+	// Confirmed send - SUBSCRIBE has been processed before SEND
+	require.NoError(suite.T(), client.SendWithReceipt(ctx, "/topic/a", []byte("hello"), nil))
+	select {
+	case frame := <-messages:
+		require.Equal(suite.T(), string(id), frame.Headers[HeaderSubscription])
+		require.Equal(suite.T(), "hello", string(frame.Body))
+	case <-ctx.Done():
+		suite.FailNow("message should have been received")
+	}
+	// Channel is closed when the subscription is canceled
+	require.NoError(suite.T(), client.Unsubscribe(id))
+	_, ok := <-messages
+	require.False(suite.T(), ok)
+	// Send refused by the broker
+	err = client.SendWithReceipt(ctx, "/queue/forbidden", []byte("hello"), nil)
+	require.ErrorContains(suite.T(), err, "access denied")
+}
+
+// Test Connect waits for the session and Disconnect stops the engine once the broker confirms
+// the disconnection.
+func (suite *STOMPClientIntegrationTestSuite) TestConnectAndDisconnect() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewSTOMPClient(STOMPClientOptions{Host: "localhost", Login: "user", Passcode: "secret"})
+	// Session is not established yet
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer timeoutCancel()
+	require.ErrorIs(suite.T(), client.Connect(timeoutCtx), context.DeadlineExceeded)
+	require.Error(suite.T(), client.Disconnect(ctx))
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.NoError(suite.T(), client.Connect(ctx))
+	// Disconnect and wait for the engine to stop
+	require.NoError(suite.T(), client.Disconnect(ctx))
+	require.Error(suite.T(), client.Send("/topic/a", []byte("too late"), nil))
+	require.Eventually(suite.T(), func() bool {
+		return engine.State() == wscengine.EngineStateStopped
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(suite.T(), suite.broker.hasReceived(CommandDisconnect))
+}
+
+// Test Start fails when the broker refuses the connection.
+func (suite *STOMPClientIntegrationTestSuite) TestConnectRefused() {
+	client := NewSTOMPClient(STOMPClientOptions{Host: "localhost", Login: "user", Passcode: "wrong"})
+	engine := suite.newEngine(client)
+	require.Error(suite.T(), engine.Start(context.Background()))
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Create a websocket engine connected to the fake broker
+func (suite *STOMPClientIntegrationTestSuite) newEngine(client *STOMPClient) *wscengine.WebsocketEngine {
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithOnOpenTimeoutMs(5000)
+	engine, err := wscengine.NewWebsocketEngine(target, gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine
+}
+
+// Minimal STOMP broker which echoes SEND frames to subscriptions of the destination and confirms
+// frames which have a receipt header. SEND frames to /queue/forbidden are refused.
+type testBroker struct {
+	// Passcode expected in CONNECT frames
+	passcode string
+	// Mutex which protects commands
+	mu sync.Mutex
+	// Received commands
+	commands []string
+}
+
+// Check whether the broker has received a command
+func (broker *testBroker) hasReceived(command string) bool {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for _, c := range broker.commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve a single STOMP session
+func (broker *testBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	// Subscriptions: destination -> subscription IDs
+	subscriptions := map[string][]string{}
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame, err := DecodeFrame(msg)
+		if err != nil {
+			return
+		}
+		broker.mu.Lock()
+		broker.commands = append(broker.commands, frame.Command)
+		broker.mu.Unlock()
+		var reply *STOMPFrame
+		switch frame.Command {
+		case CommandConnect:
+			if frame.Headers[HeaderPasscode] != broker.passcode {
+				reply = &STOMPFrame{Command: CommandError, Headers: map[string]string{HeaderMessage: "bad credentials"}}
+			} else {
+				reply = &STOMPFrame{Command: CommandConnected, Headers: map[string]string{"version": "1.2"}}
+			}
+		case CommandSubscribe:
+			destination := frame.Headers[HeaderDestination]
+			subscriptions[destination] = append(subscriptions[destination], frame.Headers[HeaderId])
+		case CommandSend:
+			if frame.Headers[HeaderDestination] == "/queue/forbidden" {
+				refused := STOMPFrame{Command: CommandError, Headers: map[string]string{
+					HeaderMessage:   "access denied",
+					HeaderReceiptId: frame.Headers[HeaderReceipt],
+				}}
+				conn.WriteMessage(websocket.TextMessage, refused.Encode())
+				return
+			}
+			for _, id := range subscriptions[frame.Headers[HeaderDestination]] {
+				headers := map[string]string{HeaderSubscription: id}
+				for name, value := range frame.Headers {
+					headers[name] = value
+				}
+				out := STOMPFrame{Command: CommandMessage, Headers: headers, Body: frame.Body}
+				if err := conn.WriteMessage(websocket.TextMessage, out.Encode()); err != nil {
+					return
+				}
+			}
+		}
+		if receipt, ok := frame.Headers[HeaderReceipt]; ok {
+			reply = &STOMPFrame{Command: CommandReceipt, Headers: map[string]string{HeaderReceiptId: receipt}}
+		}
+		if reply != nil {
+			if err := conn.WriteMessage(websocket.TextMessage, reply.Encode()); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// The package contains a WebsocketClientInterface implementation for the WAMP v2 protocol over
+// websocket using JSON serialization (https://wamp-proto.org/spec.html).
+//
+// The websocket connection adapter provided to the engine must request the wamp.2.json
+// subprotocol (Sec-WebSocket-Protocol header) as required by WAMP routers.
+package wamp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Subprotocol which must be requested during the websocket handshake.
+const Subprotocol = "wamp.2.json"
+
+// WAMP message types
+const (
+	messageHello        = 1
+	messageWelcome      = 2
+	messageAbort        = 3
+	messageGoodbye      = 6
+	messageError        = 8
+	messagePublish      = 16
+	messagePublished    = 17
+	messageSubscribe    = 32
+	messageSubscribed   = 33
+	messageUnsubscribe  = 34
+	messageUnsubscribed = 35
+	messageEvent        = 36
+	messageCall         = 48
+	messageResult       = 50
+)
+
+// Reasons and errors URI used by the client
+const (
+	// Reason used when the client closes the session
+	reasonSystemShutdown = "wamp.close.system_shutdown"
+	// Reason used to reply to a GOODBYE sent by the router
+	reasonGoodbyeAndOut = "wamp.close.goodbye_and_out"
+)
+
+// Error returned when the router replies with an ERROR or ABORT message.
+type WAMPError struct {
+	// Error URI
+	URI string
+	// Optional error arguments
+	Args []any
+	// Optional error keyword arguments
+	ArgsKw map[string]any
+}
+
+func (err WAMPError) Error() string {
+	if len(err.Args) > 0 {
+		return fmt.Sprintf("wamp error: %s %v", err.URI, err.Args)
+	}
+	return fmt.Sprintf("wamp error: %s", err.URI)
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// A decoded WAMP message: a JSON array which starts with the message type.
+type message []any
+
+// Decode a WAMP message.
+func decodeMessage(data []byte) (message, error) {
+	msg := message{}
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wamp message: %w", err)
+	}
+	if len(msg) == 0 {
+		return nil, fmt.Errorf("invalid wamp message: empty message")
+	}
+	if _, ok := msg.id(0); !ok {
+		return nil, fmt.Errorf("invalid wamp message: invalid message type")
+	}
+	return msg, nil
+}
+
+// Return the message type.
+func (msg message) messageType() int {
+	t, _ := msg.id(0)
+	return int(t)
+}
+
+// Return the ID (or integer) at the provided index.
+func (msg message) id(index int) (uint64, bool) {
+	if index >= len(msg) {
+		return 0, false
+	}
+	value, ok := msg[index].(float64)
+	if !ok || value < 0 {
+		return 0, false
+	}
+	return uint64(value), true
+}
+
+// Return the string at the provided index.
+func (msg message) str(index int) string {
+	if index >= len(msg) {
+		return ""
+	}
+	value, _ := msg[index].(string)
+	return value
+}
+
+// Return the list at the provided index.
+func (msg message) list(index int) []any {
+	if index >= len(msg) {
+		return nil
+	}
+	value, _ := msg[index].([]any)
+	return value
+}
+
+// Return the dictionary at the provided index.
+func (msg message) dict(index int) map[string]any {
+	if index >= len(msg) {
+		return nil
+	}
+	value, _ := msg[index].(map[string]any)
+	return value
+}
+
+// Build the WAMPError carried by an ERROR message.
+func errorFromMessage(msg message) WAMPError {
+	// [ERROR, REQUEST.Type, REQUEST.Request, Details, Error, Arguments, ArgumentsKw]
+	return WAMPError{
+		URI:    msg.str(4),
+		Args:   msg.list(5),
+		ArgsKw: msg.dict(6),
+	}
+}
@@ -0,0 +1,63 @@
+package wamp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for WAMP messages unit tests
+type WAMPMessagesUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run WAMPMessagesUnitTestSuite test suite
+func TestWAMPMessagesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(WAMPMessagesUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test decodeMessage and accessors.
+func (suite *WAMPMessagesUnitTestSuite) TestDecodeMessage() {
+	msg, err := decodeMessage([]byte(`[36, 5512315355, 4429313566, {}, ["hello", 1], {"key": "value"}]`))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), messageEvent, msg.messageType())
+	id, ok := msg.id(1)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), uint64(5512315355), id)
+	require.Equal(suite.T(), []any{"hello", float64(1)}, msg.list(4))
+	require.Equal(suite.T(), map[string]any{"key": "value"}, msg.dict(5))
+	// Out of range or mistyped values
+	_, ok = msg.id(10)
+	require.False(suite.T(), ok)
+	require.Empty(suite.T(), msg.str(1))
+	require.Nil(suite.T(), msg.list(3))
+	require.Nil(suite.T(), msg.dict(10))
+}
+
+// Test decodeMessage fails with invalid messages.
+func (suite *WAMPMessagesUnitTestSuite) TestDecodeInvalidMessage() {
+	for _, data := range []string{`{}`, `[]`, `["hello"]`, `not json`} {
+		_, err := decodeMessage([]byte(data))
+		require.Error(suite.T(), err, data)
+	}
+}
+
+// Test errorFromMessage.
+func (suite *WAMPMessagesUnitTestSuite) TestErrorFromMessage() {
+	msg, err := decodeMessage([]byte(`[8, 48, 7, {}, "com.example.error", ["boom"], {"code": 42}]`))
+	require.NoError(suite.T(), err)
+	wampErr := errorFromMessage(msg)
+	require.Equal(suite.T(), "com.example.error", wampErr.URI)
+	require.Equal(suite.T(), []any{"boom"}, wampErr.Args)
+	require.Equal(suite.T(), map[string]any{"code": float64(42)}, wampErr.ArgsKw)
+	require.Contains(suite.T(), wampErr.Error(), "com.example.error")
+}
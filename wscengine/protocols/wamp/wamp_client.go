@@ -0,0 +1,453 @@
+package wamp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// WAMPClient options.
+type WAMPClientOptions struct {
+	// Realm the client joins. Required.
+	Realm string
+}
+
+// Subscription managed by the client
+type subscription struct {
+	// Handler called for each received event
+	handler func(args []any)
+	// Subscription ID assigned by the router for the current session - 0 if not subscribed
+	id uint64
+}
+
+// A WebsocketClientInterface implementation which speaks WAMP v2 (JSON serialization) over the
+// websocket connection managed by the websocket engine. The client implements the subscriber,
+// publisher and caller roles.
+//
+// The client joins the realm each time the engine (re)opens the connection and waits for the
+// WELCOME message from OnOpen. Active subscriptions are automatically renewed when the engine
+// reconnects.
+//
+// Subscribe, Unsubscribe and Call wait for the router reply which is read by engine goroutines:
+// they must not be called from inside OnOpen or while the engine read mutex is locked.
+type WAMPClient struct {
+	// Client options
+	opts WAMPClientOptions
+	// Mutex used to protect client state
+	mu sync.Mutex
+	// Current connection - set during OnOpen
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Indicates whether the WAMP session is established
+	connected bool
+	// WAMP session ID assigned by the router
+	sessionId uint64
+	// Last request ID - request IDs are sequential in the session scope
+	requestId uint64
+	// Channels used to forward replies to pending requests by request ID
+	pending map[uint64]chan message
+	// Subscriptions by topic
+	subscriptions map[string]*subscription
+	// Topics by router subscription ID
+	topics map[uint64]string
+}
+
+// # Description
+//
+// Factory which creates a new WAMPClient. Provide the client to the websocket engine factory so
+// the engine calls its callbacks.
+//
+// # Returns
+//
+// A new WAMPClient or an error if the realm is empty.
+func NewWAMPClient(opts WAMPClientOptions) (*WAMPClient, error) {
+	if opts.Realm == "" {
+		return nil, fmt.Errorf("realm must be provided")
+	}
+	return &WAMPClient{
+		opts:          opts,
+		pending:       map[uint64]chan message{},
+		subscriptions: map[string]*subscription{},
+		topics:        map[uint64]string{},
+	}, nil
+}
+
+// # Description
+//
+// Return the WAMP session ID assigned by the router or 0 if the client is not connected.
+func (client *WAMPClient) SessionID() uint64 {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.sessionId
+}
+
+// # Description
+//
+// Subscribe to the provided topic. The handler is called with the event positional arguments for
+// each received event. The handler is called by engine goroutines and must be safe for concurrent
+// use if the engine uses several reader goroutines.
+//
+// If the client is not connected, the subscription is recorded and made once the client joins
+// the realm.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is already subscribed to the topic, if the
+// router refused the subscription (WAMPError) or if ctx is done before the router replies.
+func (client *WAMPClient) Subscribe(ctx context.Context, topic string, handler func(args []any)) error {
+	if handler == nil {
+		return fmt.Errorf("provided handler is nil")
+	}
+	client.mu.Lock()
+	if _, ok := client.subscriptions[topic]; ok {
+		client.mu.Unlock()
+		return fmt.Errorf("already subscribed to %s", topic)
+	}
+	sub := &subscription{handler: handler}
+	client.subscriptions[topic] = sub
+	if !client.connected {
+		client.mu.Unlock()
+		return nil
+	}
+	reply, err := client.request(ctx, func(requestId uint64) message {
+		return message{messageSubscribe, requestId, map[string]any{}, topic}
+	})
+	if err != nil {
+		client.mu.Lock()
+		delete(client.subscriptions, topic)
+		client.mu.Unlock()
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	sub.id, _ = reply.id(2)
+	client.topics[sub.id] = topic
+	return nil
+}
+
+// # Description
+//
+// Cancel the subscription to the provided topic.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is not subscribed to the topic, if the router
+// refused the request (WAMPError) or if ctx is done before the router replies.
+func (client *WAMPClient) Unsubscribe(ctx context.Context, topic string) error {
+	client.mu.Lock()
+	sub, ok := client.subscriptions[topic]
+	if !ok {
+		client.mu.Unlock()
+		return fmt.Errorf("not subscribed to %s", topic)
+	}
+	delete(client.subscriptions, topic)
+	delete(client.topics, sub.id)
+	if !client.connected || sub.id == 0 {
+		client.mu.Unlock()
+		return nil
+	}
+	_, err := client.request(ctx, func(requestId uint64) message {
+		return message{messageUnsubscribe, requestId, sub.id}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from %s: %w", topic, err)
+	}
+	return nil
+}
+
+// # Description
+//
+// Publish an event to the provided topic. The router does not acknowledge the publication.
+//
+// # Returns
+//
+// Nil in case of success or an error if the client is not connected or if the message could
+// not be sent.
+func (client *WAMPClient) Publish(ctx context.Context, topic string, args []any) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.connected {
+		return fmt.Errorf("publish failed because wamp client is not connected")
+	}
+	client.requestId = client.requestId + 1
+	return client.write(ctx, withArgs(message{messagePublish, client.requestId, map[string]any{}, topic}, args))
+}
+
+// # Description
+//
+// Call the provided remote procedure and wait for its result.
+//
+// # Returns
+//
+// The result positional arguments or an error if the client is not connected, if the call failed
+// (WAMPError) or if ctx is done before the router replies.
+func (client *WAMPClient) Call(ctx context.Context, procedure string, args []any) ([]any, error) {
+	client.mu.Lock()
+	if !client.connected {
+		client.mu.Unlock()
+		return nil, fmt.Errorf("call failed because wamp client is not connected")
+	}
+	reply, err := client.request(ctx, func(requestId uint64) message {
+		return withArgs(message{messageCall, requestId, map[string]any{}, procedure}, args)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// [RESULT, CALL.Request, Details, YIELD.Arguments]
+	return reply.list(3), nil
+}
+
+/*************************************************************************************************/
+/* WEBSOCKET CLIENT CALLBACKS                                                                    */
+/*************************************************************************************************/
+
+// Join the realm and renew active subscriptions.
+func (client *WAMPClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn = conn
+	client.connected = false
+	client.requestId = 0
+	client.topics = map[uint64]string{}
+	// Send HELLO
+	err := client.write(ctx, message{messageHello, client.opts.Realm, map[string]any{
+		"roles": map[string]any{
+			"subscriber": map[string]any{},
+			"publisher":  map[string]any{},
+			"caller":     map[string]any{},
+		},
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to send wamp HELLO message: %w", err)
+	}
+	// Engine does not read messages until OnOpen completes: wait for WELCOME
+	welcome, err := client.readUntil(ctx, func(msg message) bool {
+		return msg.messageType() == messageWelcome || msg.messageType() == messageAbort
+	})
+	if err != nil {
+		return fmt.Errorf("failed to join wamp realm: %w", err)
+	}
+	if welcome.messageType() == messageAbort {
+		// [ABORT, Details, Reason]
+		return WAMPError{URI: welcome.str(2)}
+	}
+	client.sessionId, _ = welcome.id(1)
+	// Renew subscriptions
+	for topic, sub := range client.subscriptions {
+		client.requestId = client.requestId + 1
+		requestId := client.requestId
+		err = client.write(ctx, message{messageSubscribe, requestId, map[string]any{}, topic})
+		if err != nil {
+			return fmt.Errorf("failed to renew subscription to %s: %w", topic, err)
+		}
+		reply, err := client.readUntil(ctx, func(msg message) bool {
+			switch msg.messageType() {
+			case messageSubscribed:
+				// [SUBSCRIBED, SUBSCRIBE.Request, Subscription]
+				id, _ := msg.id(1)
+				return id == requestId
+			case messageError:
+				// [ERROR, SUBSCRIBE, SUBSCRIBE.Request, Details, Error]
+				id, _ := msg.id(2)
+				return id == requestId
+			default:
+				return false
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to renew subscription to %s: %w", topic, err)
+		}
+		if reply.messageType() == messageError {
+			return fmt.Errorf("failed to renew subscription to %s: %w", topic, errorFromMessage(reply))
+		}
+		sub.id, _ = reply.id(2)
+		client.topics[sub.id] = topic
+	}
+	client.connected = true
+	return nil
+}
+
+// Dispatch events to subscription handlers and replies to pending requests.
+func (client *WAMPClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	decoded, err := decodeMessage(msg)
+	if err != nil {
+		// Discard invalid messages
+		return
+	}
+	switch decoded.messageType() {
+	case messageEvent:
+		// [EVENT, SUBSCRIBED.Subscription, PUBLISHED.Publication, Details, PUBLISH.Arguments]
+		subscriptionId, _ := decoded.id(1)
+		client.mu.Lock()
+		sub, ok := client.subscriptions[client.topics[subscriptionId]]
+		client.mu.Unlock()
+		if ok {
+			sub.handler(decoded.list(4))
+		}
+	case messageSubscribed, messageUnsubscribed, messageResult:
+		requestId, _ := decoded.id(1)
+		client.reply(requestId, decoded)
+	case messageError:
+		requestId, _ := decoded.id(2)
+		client.reply(requestId, decoded)
+	case messageGoodbye:
+		// Router closes the session: reply and restart
+		client.mu.Lock()
+		client.write(ctx, message{messageGoodbye, map[string]any{}, reasonGoodbyeAndOut})
+		client.connected = false
+		client.mu.Unlock()
+		restart()
+	}
+}
+
+// Do nothing - read errors are handled by the engine.
+func (client *WAMPClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+}
+
+// Leave the realm if the connection is still open and fail pending requests.
+func (client *WAMPClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.connected && closeMessage == nil {
+		// Provided context is canceled - use a fresh one. Errors are ignored as the connection
+		// is about to be closed.
+		client.write(context.Background(), message{messageGoodbye, map[string]any{}, reasonSystemShutdown})
+	}
+	client.connected = false
+	client.sessionId = 0
+	// Fail pending requests
+	for requestId, ch := range client.pending {
+		close(ch)
+		delete(client.pending, requestId)
+	}
+	return &wsclient.CloseMessageDetails{
+		CloseReason:  wsadapters.NormalClosure,
+		CloseMessage: "wamp client disconnected",
+	}
+}
+
+// Do nothing.
+func (client *WAMPClient) OnCloseError(ctx context.Context, sessionId string, err error) {}
+
+// Do nothing - the engine retries to connect.
+func (client *WAMPClient) OnRestartError(
+	ctx context.Context,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int) {
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Send a request built with a new request ID and wait for the router reply. Client mutex must be
+// locked when called and is unlocked when the request has been sent.
+func (client *WAMPClient) request(ctx context.Context, build func(requestId uint64) message) (message, error) {
+	client.requestId = client.requestId + 1
+	requestId := client.requestId
+	ch := make(chan message, 1)
+	client.pending[requestId] = ch
+	err := client.write(ctx, build(requestId))
+	if err != nil {
+		delete(client.pending, requestId)
+		client.mu.Unlock()
+		return nil, err
+	}
+	client.mu.Unlock()
+	// Wait for reply
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("wamp session closed before reply")
+		}
+		if reply.messageType() == messageError {
+			return nil, errorFromMessage(reply)
+		}
+		return reply, nil
+	case <-ctx.Done():
+		client.mu.Lock()
+		delete(client.pending, requestId)
+		client.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Forward a reply to the pending request.
+func (client *WAMPClient) reply(requestId uint64, reply message) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if ch, ok := client.pending[requestId]; ok {
+		delete(client.pending, requestId)
+		ch <- reply
+	}
+}
+
+// Read messages from the connection until the predicate matches. Used during OnOpen only.
+func (client *WAMPClient) readUntil(ctx context.Context, predicate func(msg message) bool) (message, error) {
+	for {
+		_, data, err := client.conn.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		if predicate(msg) {
+			return msg, nil
+		}
+	}
+}
+
+// Write a message on the current connection. Client mutex must be locked.
+func (client *WAMPClient) write(ctx context.Context, msg message) error {
+	if client.conn == nil {
+		return fmt.Errorf("wamp client has no connection")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode wamp message: %w", err)
+	}
+	return client.conn.Write(ctx, wsadapters.Text, data)
+}
+
+// Append positional arguments to a message if any.
+func withArgs(msg message, args []any) message {
+	if len(args) > 0 {
+		msg = append(msg, args)
+	}
+	return msg
+}
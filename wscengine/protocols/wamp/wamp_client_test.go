@@ -0,0 +1,252 @@
+package wamp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test WAMPClient with a websocket engine and a fake WAMP router
+type WAMPClientIntegrationTestSuite struct {
+	suite.Suite
+	// Fake router
+	router *testRouter
+	// Server which hosts the router
+	srv *httptest.Server
+}
+
+// Run WAMPClientIntegrationTestSuite test suite
+func TestWAMPClientIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(WAMPClientIntegrationTestSuite))
+}
+
+// Start fake router
+func (suite *WAMPClientIntegrationTestSuite) SetupTest() {
+	suite.router = &testRouter{realm: "realm1"}
+	suite.srv = httptest.NewServer(suite.router)
+}
+
+// Stop fake router
+func (suite *WAMPClientIntegrationTestSuite) TearDownTest() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test interface compliance and factory.
+func (suite *WAMPClientIntegrationTestSuite) TestInterfaceCompliance() {
+	_, err := NewWAMPClient(WAMPClientOptions{})
+	require.Error(suite.T(), err)
+	client, err := NewWAMPClient(WAMPClientOptions{Realm: "realm1"})
+	require.NoError(suite.T(), err)
+	var instance any = client
+	_, ok := instance.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test the client joins the realm, subscribes, publishes, receives events, unsubscribes and
+// leaves the realm.
+func (suite *WAMPClientIntegrationTestSuite) TestPubSub() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := NewWAMPClient(WAMPClientOptions{Realm: "realm1"})
+	require.NoError(suite.T(), err)
+	// Subscribe before connecting
+	received := make(chan []any, 10)
+	require.NoError(suite.T(), client.Subscribe(ctx, "com.example.a", func(args []any) { received <- args }))
+	require.Error(suite.T(), client.Subscribe(ctx, "com.example.a", func(args []any) {}))
+	// Publishing before connecting fails
+	require.Error(suite.T(), client.Publish(ctx, "com.example.a", []any{"too early"}))
+	// Start engine
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.NotZero(suite.T(), client.SessionID())
+	// Subscribe once connected
+	receivedB := make(chan []any, 10)
+	require.NoError(suite.T(), client.Subscribe(ctx, "com.example.b", func(args []any) { receivedB <- args }))
+	// Publish and wait for events
+	require.NoError(suite.T(), client.Publish(ctx, "com.example.a", []any{"hello", 1}))
+	require.NoError(suite.T(), client.Publish(ctx, "com.example.b", []any{"world"}))
+	select {
+	case args := <-received:
+		require.Equal(suite.T(), []any{"hello", float64(1)}, args)
+	case <-ctx.Done():
+		suite.FailNow("event should have been received")
+	}
+	select {
+	case args := <-receivedB:
+		require.Equal(suite.T(), []any{"world"}, args)
+	case <-ctx.Done():
+		suite.FailNow("event should have been received")
+	}
+	// Unsubscribe
+	require.NoError(suite.T(), client.Unsubscribe(ctx, "com.example.a"))
+	require.Error(suite.T(), client.Unsubscribe(ctx, "com.example.a"))
+	// Stop engine - GOODBYE must be received by the router
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Eventually(suite.T(), func() bool {
+		return suite.router.hasReceived(messageUnsubscribe) && suite.router.hasReceived(messageGoodbye)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Zero(suite.T(), client.SessionID())
+}
+
+// Test remote procedure calls.
+func (suite *WAMPClientIntegrationTestSuite) TestCall() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := NewWAMPClient(WAMPClientOptions{Realm: "realm1"})
+	require.NoError(suite.T(), err)
+	_, err = client.Call(ctx, "com.example.echo", nil)
+	require.Error(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Successful call
+	result, err := client.Call(ctx, "com.example.echo", []any{"a", 2})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []any{"a", float64(2)}, result)
+	// Call which fails
+	_, err = client.Call(ctx, "com.example.unknown", nil)
+	wampErr := WAMPError{}
+	require.True(suite.T(), errors.As(err, &wampErr))
+	require.Equal(suite.T(), "wamp.error.no_such_procedure", wampErr.URI)
+}
+
+// Test Start fails when the router aborts the session.
+func (suite *WAMPClientIntegrationTestSuite) TestHelloAborted() {
+	client, err := NewWAMPClient(WAMPClientOptions{Realm: "unknown"})
+	require.NoError(suite.T(), err)
+	engine := suite.newEngine(client)
+	require.Error(suite.T(), engine.Start(context.Background()))
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Create a websocket engine connected to the fake router
+func (suite *WAMPClientIntegrationTestSuite) newEngine(client *WAMPClient) *wscengine.WebsocketEngine {
+	target, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithAutoReconnect(false).
+		WithOnOpenTimeoutMs(5000)
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Sec-WebSocket-Protocol": {Subprotocol}})
+	engine, err := wscengine.NewWebsocketEngine(target, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	return engine
+}
+
+// Minimal WAMP router which dispatches publications to the session subscriptions and implements
+// a com.example.echo procedure.
+type testRouter struct {
+	// Accepted realm
+	realm string
+	// Mutex which protects messages
+	mu sync.Mutex
+	// Received message types
+	messages []int
+}
+
+// Check whether the router has received a message type
+func (router *testRouter) hasReceived(messageType int) bool {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	for _, t := range router.messages {
+		if t == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve a single WAMP session
+func (router *testRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{Subprotocol}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != Subprotocol {
+		return
+	}
+	// Subscriptions: topic -> subscription ID
+	subscriptions := map[string]uint64{}
+	nextId := uint64(1000)
+	send := func(msg message) error {
+		data, _ := json.Marshal(msg)
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, err := decodeMessage(data)
+		if err != nil {
+			return
+		}
+		router.mu.Lock()
+		router.messages = append(router.messages, msg.messageType())
+		router.mu.Unlock()
+		requestId, _ := msg.id(1)
+		switch msg.messageType() {
+		case messageHello:
+			if msg.str(1) != router.realm {
+				send(message{messageAbort, map[string]any{}, "wamp.error.no_such_realm"})
+				return
+			}
+			err = send(message{messageWelcome, 42, map[string]any{"roles": map[string]any{"broker": map[string]any{}, "dealer": map[string]any{}}}})
+		case messageSubscribe:
+			nextId++
+			subscriptions[msg.str(3)] = nextId
+			err = send(message{messageSubscribed, requestId, nextId})
+		case messageUnsubscribe:
+			subscriptionId, _ := msg.id(2)
+			for topic, id := range subscriptions {
+				if id == subscriptionId {
+					delete(subscriptions, topic)
+				}
+			}
+			err = send(message{messageUnsubscribed, requestId})
+		case messagePublish:
+			if subscriptionId, ok := subscriptions[msg.str(3)]; ok {
+				nextId++
+				err = send(message{messageEvent, subscriptionId, nextId, map[string]any{}, msg.list(4)})
+			}
+		case messageCall:
+			if msg.str(3) == "com.example.echo" {
+				err = send(message{messageResult, requestId, map[string]any{}, msg.list(4)})
+			} else {
+				err = send(message{messageError, messageCall, requestId, map[string]any{}, "wamp.error.no_such_procedure"})
+			}
+		case messageGoodbye:
+			send(message{messageGoodbye, map[string]any{}, reasonGoodbyeAndOut})
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// The package contains a websocket proxy which bridges clients using an older API version with a
+// server which only supports the newer API version.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+)
+
+// Interface for transformers which convert messages between v1 and v2 API formats.
+type MessageTransformer interface {
+	// # Description
+	//
+	// Transform a message sent by a v1 client into a v2 message forwarded to the server.
+	//
+	// # Returns
+	//
+	// The transformed message or an error. Messages which cannot be transformed are dropped.
+	ToV2(ctx context.Context, msgType wsadapters.MessageType, msg []byte) (wsadapters.MessageType, []byte, error)
+	// # Description
+	//
+	// Transform a v2 message received from the server into a v1 message sent to the client.
+	//
+	// # Returns
+	//
+	// The transformed message or an error. Messages which cannot be transformed are dropped.
+	ToV1(ctx context.Context, msgType wsadapters.MessageType, msg []byte) (wsadapters.MessageType, []byte, error)
+}
+
+// VersionBridgeProxy options.
+type VersionBridgeProxyOptions struct {
+	// Subprotocol (Sec-WebSocket-Protocol) offered by v1 clients. Required.
+	V1Subprotocol string
+	// Subprotocol (Sec-WebSocket-Protocol) offered by v2 clients. Required.
+	V2Subprotocol string
+	// Optional upgrader used to accept client connections. Subprotocols are managed by the proxy.
+	// If nil, a default upgrader will be used.
+	Upgrader *websocket.Upgrader
+	// Logger used to report proxy errors. If nil, default logger will be used.
+	Logger *log.Logger
+}
+
+// A http.Handler which accepts v1 and v2 websocket clients and proxies their connection to a v2
+// server. Messages from v1 clients are transformed to v2 format before being forwarded to the
+// server and messages from the server are transformed back to v1 format. Messages from v2 clients
+// are forwarded as is.
+//
+// The client version is detected using the Sec-WebSocket-Protocol header: v2 is used if the
+// client offers the v2 subprotocol, v1 is used if the client only offers the v1 subprotocol.
+// Other clients are rejected.
+type VersionBridgeProxy struct {
+	// Target v2 server
+	target url.URL
+	// Factory used to create a connection adapter to the v2 server for each client
+	adapterFactory func() wsadapters.WebsocketConnectionAdapterInterface
+	// Transformer used for v1 clients
+	transformer MessageTransformer
+	// Options with defaults applied
+	opts VersionBridgeProxyOptions
+}
+
+// # Description
+//
+// Factory which creates a new VersionBridgeProxy.
+//
+// # Inputs
+//
+//   - target: URL of the v2 websocket server.
+//   - adapterFactory: Factory used to create a new connection adapter to the v2 server for each
+//     accepted client. Adapters must be configured to use the v2 subprotocol if the server needs
+//     it. Must not be nil.
+//   - transformer: Transformer used to convert messages for v1 clients. Must not be nil.
+//   - opts: Proxy options.
+//
+// # Returns
+//
+// A new VersionBridgeProxy or an error if inputs are not valid.
+func NewVersionBridgeProxy(
+	target url.URL,
+	adapterFactory func() wsadapters.WebsocketConnectionAdapterInterface,
+	transformer MessageTransformer,
+	opts VersionBridgeProxyOptions,
+) (*VersionBridgeProxy, error) {
+	if adapterFactory == nil {
+		return nil, fmt.Errorf("provided adapter factory is nil")
+	}
+	if transformer == nil {
+		return nil, fmt.Errorf("provided transformer is nil")
+	}
+	if opts.V1Subprotocol == "" || opts.V2Subprotocol == "" {
+		return nil, fmt.Errorf("v1 and v2 subprotocols must be provided")
+	}
+	if opts.V1Subprotocol == opts.V2Subprotocol {
+		return nil, fmt.Errorf("v1 and v2 subprotocols must be different")
+	}
+	if opts.Upgrader == nil {
+		opts.Upgrader = &websocket.Upgrader{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	return &VersionBridgeProxy{
+		target:         target,
+		adapterFactory: adapterFactory,
+		transformer:    transformer,
+		opts:           opts,
+	}, nil
+}
+
+// # Description
+//
+// Accept the client connection, connect to the v2 server and proxy messages until one of the
+// connections is closed.
+func (proxy *VersionBridgeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Detect client version
+	subprotocol := proxy.detectSubprotocol(r)
+	if subprotocol == "" {
+		http.Error(w, "unsupported API version", http.StatusBadRequest)
+		return
+	}
+	bridged := subprotocol == proxy.opts.V1Subprotocol
+	// Connect to the v2 server before accepting the client connection
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	upstream := proxy.adapterFactory()
+	_, err := upstream.Dial(ctx, proxy.target)
+	if err != nil {
+		proxy.opts.Logger.Println("version bridge proxy failed to connect to server:", err)
+		http.Error(w, "failed to connect to server", http.StatusBadGateway)
+		return
+	}
+	// Accept client connection with the detected subprotocol
+	upgrader := *proxy.opts.Upgrader
+	upgrader.Subprotocols = []string{subprotocol}
+	client, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client
+		upstream.Close(ctx, wsadapters.GoingAway, "")
+		return
+	}
+	defer client.Close()
+	// Proxy messages in both directions until one side fails
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proxy.serverToClient(ctx, upstream, client, bridged)
+	}()
+	proxy.clientToServer(ctx, client, upstream, bridged)
+	// Stop the other direction
+	cancel()
+	client.Close()
+	<-done
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Return the subprotocol to use for the client or an empty string if the client offers none of
+// the supported subprotocols. v2 is preferred.
+func (proxy *VersionBridgeProxy) detectSubprotocol(r *http.Request) string {
+	v1 := false
+	for _, subprotocol := range websocket.Subprotocols(r) {
+		if subprotocol == proxy.opts.V2Subprotocol {
+			return subprotocol
+		}
+		if subprotocol == proxy.opts.V1Subprotocol {
+			v1 = true
+		}
+	}
+	if v1 {
+		return proxy.opts.V1Subprotocol
+	}
+	return ""
+}
+
+// Forward client messages to the server until the client connection is closed. The server
+// connection is closed with the client close code when the client closes its connection.
+func (proxy *VersionBridgeProxy) clientToServer(
+	ctx context.Context,
+	client *websocket.Conn,
+	upstream wsadapters.WebsocketConnectionAdapterInterface,
+	bridged bool) {
+	for {
+		msgType, msg, err := client.ReadMessage()
+		if err != nil {
+			// Close server connection using the client close code if any
+			code, reason := wsadapters.GoingAway, ""
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				code, reason = wsadapters.StatusCode(ce.Code), ce.Text
+			}
+			upstream.Close(ctx, forwardableCloseCode(code), reason)
+			return
+		}
+		mt := wsadapters.MessageType(msgType)
+		if bridged {
+			mt, msg, err = proxy.transformer.ToV2(ctx, mt, msg)
+			if err != nil {
+				proxy.opts.Logger.Println("version bridge proxy dropped client message:", err)
+				continue
+			}
+		}
+		err = upstream.Write(ctx, mt, msg)
+		if err != nil {
+			// Close server connection to unblock serverToClient
+			upstream.Close(ctx, wsadapters.GoingAway, "")
+			return
+		}
+	}
+}
+
+// Forward server messages to the client until the server connection is closed. The client
+// connection is closed with the server close code when the server closes its connection.
+func (proxy *VersionBridgeProxy) serverToClient(
+	ctx context.Context,
+	upstream wsadapters.WebsocketConnectionAdapterInterface,
+	client *websocket.Conn,
+	bridged bool) {
+	for {
+		msgType, msg, err := upstream.Read(ctx)
+		if err != nil {
+			// Close client connection using the server close code if any
+			code, reason := wsadapters.GoingAway, ""
+			var ce wsadapters.WebsocketCloseError
+			if errors.As(err, &ce) {
+				code = ce.Code
+			}
+			client.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(int(forwardableCloseCode(code)), reason),
+				time.Now().Add(5*time.Second))
+			// Unblock clientToServer
+			client.Close()
+			return
+		}
+		if bridged {
+			msgType, msg, err = proxy.transformer.ToV1(ctx, msgType, msg)
+			if err != nil {
+				proxy.opts.Logger.Println("version bridge proxy dropped server message:", err)
+				continue
+			}
+		}
+		err = client.WriteMessage(int(msgType), msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Replace reserved status codes which must not be sent in a close frame.
+func forwardableCloseCode(code wsadapters.StatusCode) wsadapters.StatusCode {
+	switch code {
+	case wsadapters.NoStatusReceived:
+		return wsadapters.NormalClosure
+	case wsadapters.AbnormalClosure, wsadapters.TLSHandshake:
+		return wsadapters.GoingAway
+	default:
+		return code
+	}
+}
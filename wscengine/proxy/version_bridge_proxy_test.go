@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for VersionBridgeProxy unit tests
+type VersionBridgeProxyUnitTestSuite struct {
+	suite.Suite
+	// v2 echo server
+	server *httptest.Server
+	// Proxy in front of the v2 server
+	proxy *httptest.Server
+}
+
+// Run VersionBridgeProxyUnitTestSuite test suite
+func TestVersionBridgeProxyUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionBridgeProxyUnitTestSuite))
+}
+
+// Start a v2 echo server and a proxy in front of it
+func (suite *VersionBridgeProxyUnitTestSuite) SetupTest() {
+	suite.server = newTestV2EchoServer()
+	target, err := url.Parse(toWebsocketURL(suite.server.URL))
+	require.NoError(suite.T(), err)
+	proxy, err := NewVersionBridgeProxy(*target, func() wsadapters.WebsocketConnectionAdapterInterface {
+		return gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil)
+	}, prefixTransformer{}, VersionBridgeProxyOptions{
+		V1Subprotocol: "api.v1",
+		V2Subprotocol: "api.v2",
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	require.NoError(suite.T(), err)
+	suite.proxy = httptest.NewServer(proxy)
+}
+
+// Stop servers
+func (suite *VersionBridgeProxyUnitTestSuite) TearDownTest() {
+	suite.proxy.Close()
+	suite.server.Close()
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test messages from v1 clients are transformed in both directions.
+func (suite *VersionBridgeProxyUnitTestSuite) TestV1Client() {
+	conn := suite.dial("api.v1")
+	defer conn.Close()
+	require.Equal(suite.T(), "api.v1", conn.Subprotocol())
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("v1:hello")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(suite.T(), err)
+	// Echo server replies to v2 messages only
+	require.Equal(suite.T(), "v1:echo:hello", string(msg))
+}
+
+// Test messages from v2 clients are forwarded as is.
+func (suite *VersionBridgeProxyUnitTestSuite) TestV2Client() {
+	conn := suite.dial("api.v1", "api.v2")
+	defer conn.Close()
+	require.Equal(suite.T(), "api.v2", conn.Subprotocol())
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("v2:hello")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "v2:echo:hello", string(msg))
+}
+
+// Test messages which cannot be transformed are dropped.
+func (suite *VersionBridgeProxyUnitTestSuite) TestDroppedMessage() {
+	conn := suite.dial("api.v1")
+	defer conn.Close()
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("unknown")))
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("v1:next")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "v1:echo:next", string(msg))
+}
+
+// Test clients which offer no supported subprotocol are rejected.
+func (suite *VersionBridgeProxyUnitTestSuite) TestUnsupportedVersion() {
+	_, resp, err := websocket.DefaultDialer.Dial(toWebsocketURL(suite.proxy.URL), http.Header{
+		"Sec-WebSocket-Protocol": []string{"api.v3"},
+	})
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+// Test the server close code is forwarded to the client.
+func (suite *VersionBridgeProxyUnitTestSuite) TestServerClose() {
+	conn := suite.dial("api.v1")
+	defer conn.Close()
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("v1:close")))
+	_, _, err := conn.ReadMessage()
+	require.True(suite.T(), websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+}
+
+// Test factory with invalid inputs.
+func (suite *VersionBridgeProxyUnitTestSuite) TestFactoryWithInvalidInputs() {
+	factory := func() wsadapters.WebsocketConnectionAdapterInterface { return nil }
+	_, err := NewVersionBridgeProxy(url.URL{}, nil, prefixTransformer{}, VersionBridgeProxyOptions{V1Subprotocol: "a", V2Subprotocol: "b"})
+	require.Error(suite.T(), err)
+	_, err = NewVersionBridgeProxy(url.URL{}, factory, nil, VersionBridgeProxyOptions{V1Subprotocol: "a", V2Subprotocol: "b"})
+	require.Error(suite.T(), err)
+	_, err = NewVersionBridgeProxy(url.URL{}, factory, prefixTransformer{}, VersionBridgeProxyOptions{V1Subprotocol: "a"})
+	require.Error(suite.T(), err)
+	_, err = NewVersionBridgeProxy(url.URL{}, factory, prefixTransformer{}, VersionBridgeProxyOptions{V1Subprotocol: "a", V2Subprotocol: "a"})
+	require.Error(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Dial the proxy with the provided subprotocols
+func (suite *VersionBridgeProxyUnitTestSuite) dial(subprotocols ...string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: subprotocols, HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.Dial(toWebsocketURL(suite.proxy.URL), nil)
+	require.NoError(suite.T(), err)
+	return conn
+}
+
+// Convert a httptest server URL to a websocket URL
+func toWebsocketURL(u string) string {
+	return "ws" + strings.TrimPrefix(u, "http")
+}
+
+// Transformer which converts v1: prefixes to v2: prefixes and back.
+type prefixTransformer struct{}
+
+func (prefixTransformer) ToV2(ctx context.Context, msgType wsadapters.MessageType, msg []byte) (wsadapters.MessageType, []byte, error) {
+	if !bytes.HasPrefix(msg, []byte("v1:")) {
+		return msgType, nil, fmt.Errorf("not a v1 message")
+	}
+	return msgType, append([]byte("v2:"), msg[3:]...), nil
+}
+
+func (prefixTransformer) ToV1(ctx context.Context, msgType wsadapters.MessageType, msg []byte) (wsadapters.MessageType, []byte, error) {
+	if !bytes.HasPrefix(msg, []byte("v2:")) {
+		return msgType, nil, fmt.Errorf("not a v2 message")
+	}
+	return msgType, append([]byte("v1:"), msg[3:]...), nil
+}
+
+// Create a server which replies v2:echo:<content> to v2:<content> messages and closes the
+// connection with going away code when it receives v2:close.
+func newTestV2EchoServer() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if !bytes.HasPrefix(msg, []byte("v2:")) {
+				continue
+			}
+			if string(msg) == "v2:close" {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+				return
+			}
+			if err := conn.WriteMessage(msgType, append([]byte("v2:echo:"), msg[3:]...)); err != nil {
+				return
+			}
+		}
+	}))
+}
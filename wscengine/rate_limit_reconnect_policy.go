@@ -0,0 +1,112 @@
+package wscengine
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Close code commonly used by servers to close the connection of a rate limited client.
+const RateLimitedCloseCode wsadapters.StatusCode = 4008
+
+// Regex used to extract the retry delay (seconds) from a close reason
+var retryAfterReasonRegex = regexp.MustCompile(`retry_after:\s*([0-9]+(?:\.[0-9]+)?)`)
+
+// ReconnectPolicy implementation which respects the retry delay provided by the server when it
+// closes the connection because the client is rate limited.
+//
+// The retry delay is embedded in the close reason as "retry_after:<seconds>" (like "Rate limited,
+// retry_after:30"). Until the delay has elapsed, Allow returns false and the engine waits. After
+// the delay, the normal reconnect logic resumes. Rate limit closes without a retry delay are
+// ignored.
+type RateLimitAwareReconnectPolicy struct {
+	// Close code used by the server for rate limit closes
+	closeCode wsadapters.StatusCode
+	// Logger used to log rate limit events
+	logger *log.Logger
+	// Mutex used to protect until
+	mu sync.Mutex
+	// Time until which reconnecting is not allowed
+	until time.Time
+	// Function used to get current time
+	now func() time.Time
+}
+
+// # Description
+//
+// Factory which creates a new RateLimitAwareReconnectPolicy.
+//
+// # Inputs
+//
+//   - closeCode: Close code used by the server for rate limit closes (like RateLimitedCloseCode).
+//   - logger: Optional logger used to log rate limit events. If nil, log.Default() is used.
+//
+// # Returns
+//
+// A new RateLimitAwareReconnectPolicy which allows reconnecting until a rate limit close is
+// received.
+func NewRateLimitAwareReconnectPolicy(closeCode wsadapters.StatusCode, logger *log.Logger) *RateLimitAwareReconnectPolicy {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RateLimitAwareReconnectPolicy{
+		closeCode: closeCode,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// # Description
+//
+// Forbid reconnecting for the retry delay embedded in the close reason if the session has been
+// closed with the rate limit close code.
+func (policy *RateLimitAwareReconnectPolicy) OnSessionClosed(closeErr wsadapters.WebsocketCloseError) {
+	if closeErr.Code != policy.closeCode {
+		return
+	}
+	delay, ok := parseRetryAfterReason(closeErr.Reason)
+	if !ok {
+		return
+	}
+	policy.mu.Lock()
+	policy.until = policy.now().Add(delay)
+	policy.mu.Unlock()
+	policy.logger.Printf("rate limited by the server (close code %d): reconnect delayed by %s", closeErr.Code, delay)
+}
+
+// # Description
+//
+// Return true if the retry delay provided by the server has elapsed.
+func (policy *RateLimitAwareReconnectPolicy) Allow() bool {
+	return policy.RetryAfter() == 0
+}
+
+// # Description
+//
+// Return the remaining retry delay or zero if reconnecting is allowed.
+func (policy *RateLimitAwareReconnectPolicy) RetryAfter() time.Duration {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	remaining := policy.until.Sub(policy.now())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Extract the retry delay from a close reason which contains "retry_after:<seconds>".
+func parseRetryAfterReason(reason string) (time.Duration, bool) {
+	match := retryAfterReasonRegex.FindStringSubmatch(reason)
+	if match == nil {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,119 @@
+package wscengine
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for RateLimitAwareReconnectPolicy tests
+type RateLimitAwareReconnectPolicyTestSuite struct {
+	suite.Suite
+}
+
+// Run RateLimitAwareReconnectPolicyTestSuite test suite
+func TestRateLimitAwareReconnectPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitAwareReconnectPolicyTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the policy forbids reconnecting for the delay embedded in rate limit close reasons only.
+func (suite *RateLimitAwareReconnectPolicyTestSuite) TestOnSessionClosed() {
+	logs := &bytes.Buffer{}
+	policy := NewRateLimitAwareReconnectPolicy(RateLimitedCloseCode, log.New(logs, "", 0))
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+	require.True(suite.T(), policy.Allow())
+	// Other close codes and rate limit closes without delay are ignored
+	policy.OnSessionClosed(wsadapters.WebsocketCloseError{Code: wsadapters.GoingAway, Reason: "retry_after:30"})
+	policy.OnSessionClosed(wsadapters.WebsocketCloseError{Code: RateLimitedCloseCode, Reason: "Rate limited"})
+	policy.OnSessionClosed(wsadapters.WebsocketCloseError{Code: RateLimitedCloseCode, Reason: "retry_after:abc"})
+	require.True(suite.T(), policy.Allow())
+	require.Zero(suite.T(), policy.RetryAfter())
+	require.Empty(suite.T(), logs.String())
+	// Rate limit close with a retry delay
+	policy.OnSessionClosed(wsadapters.WebsocketCloseError{Code: RateLimitedCloseCode, Reason: "Rate limited, retry_after:2.5"})
+	require.False(suite.T(), policy.Allow())
+	require.Equal(suite.T(), 2500*time.Millisecond, policy.RetryAfter())
+	require.Contains(suite.T(), logs.String(), "reconnect delayed by 2.5s")
+	// Delay elapses
+	now = now.Add(time.Second)
+	require.Equal(suite.T(), 1500*time.Millisecond, policy.RetryAfter())
+	now = now.Add(2 * time.Second)
+	require.True(suite.T(), policy.Allow())
+	require.Zero(suite.T(), policy.RetryAfter())
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the engine waits for the retry delay provided by the server in a rate limit close before it
+// reconnects.
+func (suite *RateLimitAwareReconnectPolicyTestSuite) TestEngineWaitsRetryAfter() {
+	// Server which rate limits the first connection
+	mu := sync.Mutex{}
+	connected := []time.Time{}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		mu.Lock()
+		connected = append(connected, time.Now())
+		first := len(connected) == 1
+		mu.Unlock()
+		if first {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(int(RateLimitedCloseCode), "Rate limited, retry_after:1"))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	// Start engine
+	policy := NewRateLimitAwareReconnectPolicy(RateLimitedCloseCode, nil)
+	opts := NewWebsocketEngineConfigurationOptions().WithReconnectPolicy(policy)
+	client := wstest.NewRecordingClient()
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil), client, opts, nil)
+	require.NoError(suite.T(), err)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(timeoutCtx))
+	defer engine.Stop(timeoutCtx)
+	// Engine reconnects once the retry delay has elapsed
+	require.Eventually(suite.T(), func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(connected) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	closes := client.RecordedOnCloses()
+	require.NotEmpty(suite.T(), closes)
+	require.Equal(suite.T(), RateLimitedCloseCode, closes[0].CloseMessage.CloseReason)
+	mu.Lock()
+	defer mu.Unlock()
+	require.GreaterOrEqual(suite.T(), connected[1].Sub(closes[0].Timestamp), 900*time.Millisecond)
+}
@@ -0,0 +1,102 @@
+package wscengine
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// # Description
+//
+// Engine internal goroutine task which detects silently dropped connections while the session is
+// up. The connection is considered dead when no message has been read and no ping sent by the
+// engine has succeeded during ReadIdleTimeout.
+//
+// When the connection is considered dead, the goroutine calls OnReadError with
+// ErrReadIdleTimeout, cancels the session, shuts down the engine (OnClose callback + close
+// connection + restart if applicable) and exits. The underlying connection is then forcefully
+// closed, if possible, so the engine goroutines blocked on the dead connection are released.
+//
+// # Inputs
+//
+//   - sessionCtx: Context produced from engine context and bound to websocket connection lifetime.
+//   - cancelSession: Function to call to cancel session context and stop all other goroutines.
+//   - shutdownSync: Object used to ensure engine shutdown is performed exactly once.
+//   - sessionId: Id bound to the connection lifecycle. Used to correlate traces.
+func (wsengine *WebsocketEngine) runReadIdleWatchdog(
+	sessionCtx context.Context,
+	cancelSession context.CancelFunc,
+	shutdownSync *sync.Once,
+	sessionId string) {
+	timeout := wsengine.engineCfgOpts.ReadIdleTimeout
+	// Activity which occured before the session started is not taken into account
+	sessionStart := time.Now().UnixNano()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sessionCtx.Done():
+			// Session has ended - exit
+			return
+		case <-timer.C:
+			// Compute the deadline from the last activity on the connection
+			lastActivity := max(sessionStart, wsengine.lastMessageAt.Load(), wsengine.lastPongAt.Load())
+			remaining := time.Until(time.Unix(0, lastActivity).Add(timeout))
+			if remaining > 0 {
+				timer.Reset(remaining)
+				continue
+			}
+			wsengine.handleReadIdleTimeout(sessionCtx, cancelSession, shutdownSync, sessionId)
+			return
+		}
+	}
+}
+
+// # Description
+//
+// Handle a connection on which no message has been received during ReadIdleTimeout as a dead
+// connection.
+func (wsengine *WebsocketEngine) handleReadIdleTimeout(
+	sessionCtx context.Context,
+	cancelSession context.CancelFunc,
+	shutdownSync *sync.Once,
+	sessionId string) {
+	// Start span with fresh context which carries the session ID
+	ctx, span := wsengine.tracer.Start(middleware.ContextWithSessionId(context.Background(), sessionId), spanEngineBackgroundReadIdle,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(attrSessionId, sessionId),
+		))
+	defer span.End()
+	// Ignore the timeout if the session has ended in the meantime
+	select {
+	case <-sessionCtx.Done():
+		span.SetStatus(codes.Ok, codes.Ok.String())
+		return
+	default:
+	}
+	span.RecordError(ErrReadIdleTimeout)
+	span.AddEvent(eventReadIdleTimeout)
+	span.SetStatus(codes.Error, codes.Error.String())
+	wsengine.logger.WarnContext(ctx, "no message received during the read idle timeout, connection is considered dead",
+		logKeySessionId, sessionId,
+		logKeyError, ErrReadIdleTimeout)
+	// Keep a reference to the underlying connection: the adapter drops it when it is closed
+	underlying := wsengine.conn.GetUnderlyingWebsocketConnection()
+	// The read mutex is not provided locked: engine goroutines hold it while they are blocked on
+	// the dead connection
+	wsengine.wsclient.OnReadError(ctx, wsengine.conn, wsengine.readMutex, cancelSession, wsengine.engineStopFunc, sessionId, ErrReadIdleTimeout)
+	cancelSession()
+	shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, false) })
+	// The peer will not answer the close message: close the underlying connection so pending
+	// reads on the dead connection return
+	if closer, ok := underlying.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,98 @@
+package wscengine
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine read idle timeout unit tests
+type ReadIdleTimeoutUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ReadIdleTimeoutUnitTestSuite test suite
+func TestReadIdleTimeoutUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReadIdleTimeoutUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test a connection on which no message is received is considered dead: OnReadError is called
+// with ErrReadIdleTimeout and the engine reconnects.
+func (suite *ReadIdleTimeoutUnitTestSuite) TestReadIdleTimeoutRestartsEngine() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(2).
+		WithSilentDeadlineDetection(20 * time.Millisecond).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) >= 2
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), engine.Stop(ctx))
+	// OnReadError has been called with ErrReadIdleTimeout and the engine has reconnected
+	require.True(suite.T(), client.RecordedOnOpens()[1].Restarting)
+	require.NotEmpty(suite.T(), client.RecordedOnReadErrors())
+	require.ErrorIs(suite.T(), client.RecordedOnReadErrors()[0].Err, ErrReadIdleTimeout)
+	require.Equal(suite.T(), wsadapters.GoingAway, adapter.CloseMessages()[0].Code)
+}
+
+// Test the connection is not considered dead while messages are received.
+func (suite *ReadIdleTimeoutUnitTestSuite) TestReadIdleTimeoutResetByMessages() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithSilentDeadlineDetection(100 * time.Millisecond)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Keep the connection busy for several timeouts
+	for i := 0; i < 50; i++ {
+		adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Empty(suite.T(), client.RecordedOnReadErrors())
+	require.Len(suite.T(), client.RecordedOnOpens(), 1)
+}
+
+// Test the pongs of the pings sent by the engine keep a quiet connection alive.
+func (suite *ReadIdleTimeoutUnitTestSuite) TestReadIdleTimeoutResetByPongs() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithPingInterval(10*time.Millisecond, time.Second).
+		WithSilentDeadlineDetection(100 * time.Millisecond)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	time.Sleep(500 * time.Millisecond)
+	require.Empty(suite.T(), client.RecordedOnReadErrors())
+	require.Len(suite.T(), client.RecordedOnOpens(), 1)
+}
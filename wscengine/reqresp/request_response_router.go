@@ -0,0 +1,217 @@
+// The package provides request/response helpers for websocket APIs which use a request ID: the
+// client sends a JSON object with an ID field and the server replies with a JSON object which
+// carries the same ID.
+package reqresp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/google/uuid"
+)
+
+// Default name of the JSON field which carries the request ID.
+const DefaultIDField = "id"
+
+// Error returned by Send when the pending requests are canceled with CancelPending and no error is
+// provided.
+var ErrRequestCanceled = errors.New("request canceled")
+
+// Router which sends requests and routes the responses of the server to the waiting senders.
+//
+// Send adds a random UUID to the request in the ID field, writes the request and waits for the
+// response which carries the same ID. Responses are routed by calling Route from the OnMessage
+// callback of the websocket client or by adding the router Middleware to the engine middlewares.
+//
+// Call CancelPending from the OnClose callback so pending requests fail when the connection is
+// closed: responses are not received once the connection is lost.
+type RequestResponseRouter struct {
+	// Connection used to write requests
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Name of the JSON field which carries the request ID
+	idField string
+	// Mutex used to protect pending
+	mu sync.Mutex
+	// Pending requests indexed by request ID
+	pending map[string]*pendingRequest
+}
+
+// Request waiting for its response
+type pendingRequest struct {
+	// Channel which receives the response - capacity 1
+	response chan []byte
+	// Channel which receives the error which cancels the request - capacity 1
+	canceled chan error
+}
+
+// # Description
+//
+// Factory which creates a new RequestResponseRouter.
+//
+// # Inputs
+//
+//   - conn: Connection used to write requests. Required. Use the connection provided to the
+//     websocket client callbacks: the engine reuses it when it reconnects.
+//   - idField: Name of the JSON field which carries the request ID. If empty, DefaultIDField is
+//     used.
+//
+// # Returns
+//
+// A new RequestResponseRouter or an error if conn is nil.
+func NewRequestResponseRouter(conn wsadapters.WebsocketConnectionAdapterInterface, idField string) (*RequestResponseRouter, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("provided connection is nil")
+	}
+	if idField == "" {
+		idField = DefaultIDField
+	}
+	return &RequestResponseRouter{
+		conn:    conn,
+		idField: idField,
+		mu:      sync.Mutex{},
+		pending: map[string]*pendingRequest{},
+	}, nil
+}
+
+// # Description
+//
+// Add a random UUID to the request in the ID field, write the request as a text message and
+// wait for the response which carries the same ID.
+//
+// # Inputs
+//
+//   - ctx: Context used to write the request and to bound the time spent waiting for the response.
+//   - payload: Request - a JSON object. An existing ID field is replaced.
+//
+// # Returns
+//
+// The response or an error if the payload is not a JSON object, if the write has failed, if the
+// context is done before the response is received or if the request is canceled by
+// CancelPending.
+func (router *RequestResponseRouter) Send(ctx context.Context, payload []byte) ([]byte, error) {
+	// Add the request ID to the payload
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(payload, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("request payload must be a JSON object: %w", err)
+	}
+	id := uuid.New().String()
+	encodedId, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	fields[router.idField] = encodedId
+	request, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	// Park the request before it is written so the response cannot be missed
+	pending := &pendingRequest{response: make(chan []byte, 1), canceled: make(chan error, 1)}
+	router.mu.Lock()
+	router.pending[id] = pending
+	router.mu.Unlock()
+	defer router.forget(id)
+	err = router.conn.Write(ctx, wsadapters.Text, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write request %s: %w", id, err)
+	}
+	// Wait for the response
+	select {
+	case response := <-pending.response:
+		return response, nil
+	case err := <-pending.canceled:
+		return nil, fmt.Errorf("request %s: %w", id, err)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request %s: %w", id, ctx.Err())
+	}
+}
+
+// # Description
+//
+// Hand over a received message to the request which waits for it. Call Route from the OnMessage
+// callback of the websocket client.
+//
+// # Returns
+//
+// True if the message is the response of a pending request, false otherwise (the message is not
+// a JSON object, has no ID or the ID is unknown).
+func (router *RequestResponseRouter) Route(msg []byte) bool {
+	fields := map[string]json.RawMessage{}
+	if json.Unmarshal(msg, &fields) != nil {
+		return false
+	}
+	var id string
+	if json.Unmarshal(fields[router.idField], &id) != nil {
+		return false
+	}
+	router.mu.Lock()
+	pending, ok := router.pending[id]
+	if ok {
+		delete(router.pending, id)
+	}
+	router.mu.Unlock()
+	if !ok {
+		return false
+	}
+	pending.response <- msg
+	return true
+}
+
+// # Description
+//
+// Cancel all pending requests: Send returns an error which wraps the provided error or
+// ErrRequestCanceled if err is nil. Call CancelPending from the OnClose callback of the
+// websocket client.
+func (router *RequestResponseRouter) CancelPending(err error) {
+	if err == nil {
+		err = ErrRequestCanceled
+	}
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	for id, pending := range router.pending {
+		pending.canceled <- err
+		delete(router.pending, id)
+	}
+}
+
+// # Description
+//
+// Return the number of requests which wait for their response.
+func (router *RequestResponseRouter) PendingCount() int {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	return len(router.pending)
+}
+
+// # Description
+//
+// Build a middleware which routes responses to the pending requests.
+//
+// # Inputs
+//
+//   - forward: If true, responses are handed over to the next handler. Otherwise they are dropped
+//     by the middleware. Messages which are not responses are always handed over to the next
+//     handler.
+//
+// # Returns
+//
+// The middleware.
+func (router *RequestResponseRouter) Middleware(forward bool) middleware.MessageMiddleware {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		if !router.Route(msg) || forward {
+			next(ctx, msgType, msg)
+		}
+	}
+}
+
+// Remove a request from the pending requests.
+func (router *RequestResponseRouter) forget(id string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	delete(router.pending, id)
+}
@@ -0,0 +1,145 @@
+package reqresp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for RequestResponseRouter unit tests
+type RequestResponseRouterUnitTestSuite struct {
+	suite.Suite
+	// Connection used by the tested router
+	conn *serverStubAdapter
+}
+
+// Run RequestResponseRouterUnitTestSuite test suite
+func TestRequestResponseRouterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestResponseRouterUnitTestSuite))
+}
+
+// Create a connected mock connection
+func (suite *RequestResponseRouterUnitTestSuite) SetupTest() {
+	suite.conn = &serverStubAdapter{MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter()}
+	_, err := suite.conn.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails with a nil connection.
+func (suite *RequestResponseRouterUnitTestSuite) TestFactoryWithNilConnection() {
+	_, err := NewRequestResponseRouter(nil, "")
+	require.Error(suite.T(), err)
+}
+
+// Test Send adds an ID to the request and returns the response which carries the same ID.
+func (suite *RequestResponseRouterUnitTestSuite) TestSendAndRoute() {
+	router, err := NewRequestResponseRouter(suite.conn, "reqid")
+	require.NoError(suite.T(), err)
+	routed := []bool{}
+	suite.conn.reply = func(request map[string]any) {
+		// Messages without ID or with an unknown ID are not routed
+		routed = append(routed,
+			router.Route([]byte(`not json`)),
+			router.Route([]byte(`{"result":1}`)),
+			router.Route([]byte(`{"reqid":"unknown"}`)),
+			router.Route([]byte(fmt.Sprintf(`{"reqid":%q,"result":42}`, request["reqid"]))))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := router.Send(ctx, []byte(`{"method":"ping","reqid":1}`))
+	require.NoError(suite.T(), err)
+	require.Contains(suite.T(), string(response), `"result":42`)
+	require.Equal(suite.T(), []bool{false, false, false, true}, routed)
+	require.Zero(suite.T(), router.PendingCount())
+	// Request has been written with the ID and the other fields
+	request := map[string]any{}
+	require.NoError(suite.T(), json.Unmarshal(suite.conn.WrittenMessages()[0].Msg, &request))
+	require.Equal(suite.T(), "ping", request["method"])
+	require.IsType(suite.T(), "", request["reqid"])
+}
+
+// Test Send fails when the payload is not a JSON object, when the write fails, when the context
+// is done or when pending requests are canceled.
+func (suite *RequestResponseRouterUnitTestSuite) TestSendFailures() {
+	router, err := NewRequestResponseRouter(suite.conn, "")
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = router.Send(ctx, []byte(`[1,2]`))
+	require.Error(suite.T(), err)
+	// Context done before the response is received
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelTimeout()
+	_, err = router.Send(timeoutCtx, []byte(`{}`))
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	require.Zero(suite.T(), router.PendingCount())
+	// Pending requests are canceled
+	suite.conn.reply = func(request map[string]any) {
+		router.CancelPending(nil)
+	}
+	_, err = router.Send(ctx, []byte(`{}`))
+	require.ErrorIs(suite.T(), err, ErrRequestCanceled)
+	// Write fails once the connection is closed
+	require.NoError(suite.T(), suite.conn.Close(ctx, wsadapters.NormalClosure, "bye"))
+	_, err = router.Send(ctx, []byte(`{}`))
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+}
+
+// Test the middleware routes responses and forwards other messages.
+func (suite *RequestResponseRouterUnitTestSuite) TestMiddleware() {
+	router, err := NewRequestResponseRouter(suite.conn, "")
+	require.NoError(suite.T(), err)
+	forwarded := make(chan string, 10)
+	handler := middleware.Chain(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		forwarded <- string(msg)
+	}, router.Middleware(false))
+	suite.conn.reply = func(request map[string]any) {
+		handler(context.Background(), wsadapters.Text, []byte(`{"event":"heartbeat"}`))
+		handler(context.Background(), wsadapters.Text, []byte(fmt.Sprintf(`{"id":%q}`, request["id"])))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = router.Send(ctx, []byte(`{}`))
+	require.NoError(suite.T(), err)
+	// Only the message which is not a response has been forwarded
+	require.Equal(suite.T(), `{"event":"heartbeat"}`, <-forwarded)
+	require.Empty(suite.T(), forwarded)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Mock adapter which calls reply with the decoded request each time a request is written
+type serverStubAdapter struct {
+	*mock.MockWebsocketConnectionAdapter
+	reply func(request map[string]any)
+}
+
+func (adapter *serverStubAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	err := adapter.MockWebsocketConnectionAdapter.Write(ctx, msgType, msg)
+	if err == nil && adapter.reply != nil {
+		request := map[string]any{}
+		if json.Unmarshal(msg, &request) == nil {
+			adapter.reply(request)
+		}
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+// The package contains a router which dispatches received messages to per-topic subscribers,
+// for websocket APIs which tag the messages they push with a channel or stream name.
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Function which extracts the topic (channel or stream name) of a received message. An error or
+// an empty topic means the message cannot be routed.
+type TopicExtractFunc func(msg []byte) (string, error)
+
+// Function which cancels a subscription and closes its channel. The function can be called
+// several times.
+type CancelFunc func()
+
+// Router which dispatches received messages to the subscribers of their topic.
+//
+// Each subscriber receives messages on a dedicated buffered channel. Routing never blocks: a
+// message is dropped for a subscriber whose channel is full. The same message slice is delivered
+// to all subscribers: subscribers must not modify it.
+//
+// Messages which cannot be routed (the extractor fails or returns an empty topic, or the topic
+// has no subscriber) are handed over to the catch-all handler.
+//
+// The router is safe for concurrent use.
+type SubscriptionRouter struct {
+	// Function used to extract the topic of messages
+	extract TopicExtractFunc
+	// Handler which receives the messages which cannot be routed
+	catchAll middleware.MessageHandler
+	// Capacity of subscribers channels
+	bufferSize int
+	// Mutex used to protect subscribers
+	mu sync.RWMutex
+	// Subscribers channels by topic
+	subscribers map[string]map[*subscription]struct{}
+}
+
+// Subscription to a topic
+type subscription struct {
+	// Channel which receives the messages
+	ch chan []byte
+	// Used to cancel the subscription once
+	cancel sync.Once
+}
+
+// # Description
+//
+// Factory which creates a new SubscriptionRouter without subscribers.
+//
+// # Inputs
+//
+//   - extract: Function used to extract the topic of messages. Required.
+//   - catchAll: Optional handler which receives the messages which cannot be routed. If nil,
+//     these messages are discarded.
+//   - bufferSize: Capacity of the channels returned by Subscribe. Must be at least 0.
+//
+// # Returns
+//
+// A new SubscriptionRouter or an error if extract is nil or bufferSize is negative.
+func NewSubscriptionRouter(extract TopicExtractFunc, catchAll middleware.MessageHandler, bufferSize int) (*SubscriptionRouter, error) {
+	if extract == nil {
+		return nil, fmt.Errorf("provided topic extractor is nil")
+	}
+	if bufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must be at least 0: %d", bufferSize)
+	}
+	if catchAll == nil {
+		catchAll = func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {}
+	}
+	return &SubscriptionRouter{
+		extract:     extract,
+		catchAll:    catchAll,
+		bufferSize:  bufferSize,
+		mu:          sync.RWMutex{},
+		subscribers: map[string]map[*subscription]struct{}{},
+	}, nil
+}
+
+// # Description
+//
+// Subscribe to a topic.
+//
+// # Returns
+//
+// The channel which receives the messages of the topic and the function to call to cancel the
+// subscription. The channel is closed when the subscription is canceled.
+func (router *SubscriptionRouter) Subscribe(topic string) (<-chan []byte, CancelFunc) {
+	sub := &subscription{ch: make(chan []byte, router.bufferSize)}
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	subscribers, found := router.subscribers[topic]
+	if !found {
+		subscribers = map[*subscription]struct{}{}
+		router.subscribers[topic] = subscribers
+	}
+	subscribers[sub] = struct{}{}
+	return sub.ch, func() {
+		sub.cancel.Do(func() {
+			router.mu.Lock()
+			defer router.mu.Unlock()
+			delete(router.subscribers[topic], sub)
+			if len(router.subscribers[topic]) == 0 {
+				delete(router.subscribers, topic)
+			}
+			close(sub.ch)
+		})
+	}
+}
+
+// # Description
+//
+// Deliver the message to the subscribers of its topic which are ready to receive it or hand it
+// over to the catch-all handler if it cannot be routed.
+//
+// # Returns
+//
+// The number of subscribers the message has been delivered to.
+func (router *SubscriptionRouter) Route(ctx context.Context, msgType wsadapters.MessageType, msg []byte) int {
+	topic, err := router.extract(msg)
+	if err == nil && topic != "" {
+		router.mu.RLock()
+		subscribers, found := router.subscribers[topic]
+		delivered := 0
+		for sub := range subscribers {
+			select {
+			case sub.ch <- msg:
+				delivered++
+			default:
+				// Subscriber is not ready - Skip
+			}
+		}
+		router.mu.RUnlock()
+		if found {
+			return delivered
+		}
+	}
+	router.catchAll(ctx, msgType, msg)
+	return 0
+}
+
+// # Description
+//
+// Create a message handler which routes received messages. The handler can be used as the
+// innermost handler of a middleware chain or be called from the user provided OnMessage callback.
+func (router *SubscriptionRouter) Handler() middleware.MessageHandler {
+	return func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		router.Route(ctx, msgType, msg)
+	}
+}
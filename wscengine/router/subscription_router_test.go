@@ -0,0 +1,127 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for SubscriptionRouter unit tests
+type SubscriptionRouterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run SubscriptionRouterUnitTestSuite test suite
+func TestSubscriptionRouterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionRouterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails with invalid inputs.
+func (suite *SubscriptionRouterUnitTestSuite) TestFactoryWithInvalidInputs() {
+	_, err := NewSubscriptionRouter(nil, nil, 1)
+	require.Error(suite.T(), err)
+	_, err = NewSubscriptionRouter(channelExtractor, nil, -1)
+	require.Error(suite.T(), err)
+}
+
+// Test messages are delivered to the subscribers of their topic only and subscriptions can be
+// canceled.
+func (suite *SubscriptionRouterUnitTestSuite) TestSubscribeAndRoute() {
+	router, err := NewSubscriptionRouter(channelExtractor, nil, 1)
+	require.NoError(suite.T(), err)
+	first, cancelFirst := router.Subscribe("trades")
+	second, cancelSecond := router.Subscribe("trades")
+	other, cancelOther := router.Subscribe("book")
+	defer cancelOther()
+	trade := []byte(`{"channel":"trades","price":1}`)
+	require.Equal(suite.T(), 2, router.Route(context.Background(), wsadapters.Text, trade))
+	require.Equal(suite.T(), trade, <-first)
+	require.Equal(suite.T(), trade, <-second)
+	require.Empty(suite.T(), other)
+	// Full subscribers are skipped
+	require.Equal(suite.T(), 2, router.Route(context.Background(), wsadapters.Text, trade))
+	require.Equal(suite.T(), 0, router.Route(context.Background(), wsadapters.Text, trade))
+	<-first
+	<-second
+	// Canceled subscriptions are closed and do not receive messages anymore
+	cancelFirst()
+	cancelFirst()
+	_, ok := <-first
+	require.False(suite.T(), ok)
+	require.Equal(suite.T(), 1, router.Route(context.Background(), wsadapters.Text, trade))
+	require.Equal(suite.T(), trade, <-second)
+	cancelSecond()
+	require.NotContains(suite.T(), router.subscribers, "trades")
+}
+
+// Test messages which cannot be routed are handed over to the catch-all handler.
+func (suite *SubscriptionRouterUnitTestSuite) TestCatchAll() {
+	unrouted := [][]byte{}
+	router, err := NewSubscriptionRouter(channelExtractor, func(ctx context.Context, msgType wsadapters.MessageType, msg []byte) {
+		unrouted = append(unrouted, msg)
+	}, 1)
+	require.NoError(suite.T(), err)
+	_, cancel := router.Subscribe("trades")
+	defer cancel()
+	handler := middleware.Chain(router.Handler())
+	handler(context.Background(), wsadapters.Text, []byte(`not json`))
+	handler(context.Background(), wsadapters.Text, []byte(`{"event":"heartbeat"}`))
+	handler(context.Background(), wsadapters.Text, []byte(`{"channel":"book"}`))
+	handler(context.Background(), wsadapters.Text, []byte(`{"channel":"trades"}`))
+	require.Equal(suite.T(), [][]byte{
+		[]byte(`not json`),
+		[]byte(`{"event":"heartbeat"}`),
+		[]byte(`{"channel":"book"}`),
+	}, unrouted)
+}
+
+// Test concurrent subscriptions, cancellations and routing.
+func (suite *SubscriptionRouterUnitTestSuite) TestConcurrentUse() {
+	router, err := NewSubscriptionRouter(channelExtractor, nil, 1)
+	require.NoError(suite.T(), err)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, cancel := router.Subscribe("trades")
+			cancel()
+		}()
+		go func() {
+			defer wg.Done()
+			router.Route(context.Background(), wsadapters.Text, []byte(`{"channel":"trades"}`))
+		}()
+	}
+	wg.Wait()
+	require.Empty(suite.T(), router.subscribers)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Extract the topic from the channel field of a JSON object
+func channelExtractor(msg []byte) (string, error) {
+	fields := struct {
+		Channel string `json:"channel"`
+	}{}
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return "", fmt.Errorf("failed to extract topic: %w", err)
+	}
+	return fields.Channel, nil
+}
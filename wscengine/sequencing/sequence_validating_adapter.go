@@ -0,0 +1,228 @@
+// The package contains a connection adapter decorator which checks the sequence numbers carried
+// by the messages of APIs which number their messages, to detect replayed and missed messages.
+package sequencing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Error wrapped in the error returned by Read when the sequence number of a message is greater
+// than the next expected one: messages have been missed.
+var ErrSequenceGap = errors.New("sequence gap detected")
+
+// Error wrapped in the error returned by Read when the sequence number of a message is lower or
+// equal to the last observed one: the message has been replayed.
+var ErrReplayDetected = errors.New("replayed message detected")
+
+// Error the sequence extractor can return for messages which do not carry a sequence number
+// (heartbeats, acknowledgements, ...): such messages are returned without being checked.
+var ErrNoSequence = errors.New("message has no sequence number")
+
+// Function which extracts the sequence number from a message. The function must return
+// ErrNoSequence (or an error which wraps it) for messages which do not carry a sequence number.
+type SequenceExtractor func(msg []byte) (int64, error)
+
+// Error returned by Read when a message fails the sequence check. The error wraps ErrSequenceGap
+// or ErrReplayDetected and carries the message so OnReadError can still process it.
+type SequenceError struct {
+	// ErrSequenceGap or ErrReplayDetected
+	Err error
+	// Last sequence number observed before the message
+	Last int64
+	// Sequence number of the message
+	Received int64
+	// Message type
+	MsgType wsadapters.MessageType
+	// Message content
+	Msg []byte
+}
+
+func (err SequenceError) Error() string {
+	return fmt.Sprintf("%v: received sequence number %d after %d", err.Err, err.Received, err.Last)
+}
+
+func (err SequenceError) Unwrap() error {
+	return err.Err
+}
+
+// A decorator which checks that each message read with the decorated adapter carries a sequence
+// number which immediately follows the one of the previous message.
+//
+// The first message read after each Dial sets the initial sequence number. Then, a message whose
+// sequence number is lower or equal to the last observed one is dropped and Read returns a
+// SequenceError which wraps ErrReplayDetected. A message whose sequence number skips some values
+// is accepted as the new reference but Read returns a SequenceError which wraps ErrSequenceGap.
+// Used with the websocket engine, both errors are provided to OnReadError.
+type SequenceValidatingAdapter struct {
+	// Decorated WebsocketConnectionAdapterInterface implementation
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// Function used to extract sequence numbers from messages
+	extractSeq SequenceExtractor
+	// Mutex used to protect last and hasLast
+	mu sync.Mutex
+	// Last observed sequence number
+	last int64
+	// Flag set once a sequence number has been observed on the current connection
+	hasLast bool
+}
+
+// # Description
+//
+// Create a new decorator which checks the sequence numbers of the messages read with the provided
+// implementation of WebsocketConnectionAdapterInterface.
+//
+// # Inputs
+//
+//   - inner: Decorated connection adapter.
+//   - extractSeq: Function used to extract the sequence number from messages.
+//
+// # Returns
+//
+// The decorator or an error if inner or extractSeq is nil.
+func NewSequenceValidatingAdapter(inner wsadapters.WebsocketConnectionAdapterInterface, extractSeq SequenceExtractor) (*SequenceValidatingAdapter, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("provided inner adapter is nil")
+	}
+	if extractSeq == nil {
+		return nil, fmt.Errorf("provided sequence extractor is nil")
+	}
+	return &SequenceValidatingAdapter{
+		decorated:  inner,
+		extractSeq: extractSeq,
+	}, nil
+}
+
+// # Description
+//
+// Return the last observed sequence number.
+//
+// # Returns
+//
+// The last observed sequence number and false if no sequence number has been observed on the
+// current connection yet.
+func (adapter *SequenceValidatingAdapter) LastSequence() (int64, bool) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	return adapter.last, adapter.hasLast
+}
+
+// Decorate the Dial method to reset the last observed sequence number: sequences restart with
+// each connection.
+func (adapter *SequenceValidatingAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	adapter.mu.Lock()
+	adapter.last, adapter.hasLast = 0, false
+	adapter.mu.Unlock()
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method.
+func (adapter *SequenceValidatingAdapter) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *SequenceValidatingAdapter) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Simple proxy for Ping method.
+func (adapter *SequenceValidatingAdapter) Ping(ctx context.Context) error {
+	return adapter.decorated.Ping(ctx)
+}
+
+// Decorate the Read method to check the sequence number of the message.
+func (adapter *SequenceValidatingAdapter) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	msgType, msg, err := adapter.decorated.Read(ctx)
+	if err != nil {
+		return msgType, msg, err
+	}
+	if err := adapter.check(msgType, msg); err != nil {
+		return msgType, nil, err
+	}
+	return msgType, msg, nil
+}
+
+// Decorate the ReadStream method to read the whole message and check its sequence number.
+func (adapter *SequenceValidatingAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, reader, err := adapter.decorated.ReadStream(ctx)
+	if err != nil {
+		return msgType, reader, err
+	}
+	msg, err := io.ReadAll(reader)
+	if err != nil {
+		return msgType, nil, err
+	}
+	if err := adapter.check(msgType, msg); err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
+// Simple proxy for Write method.
+func (adapter *SequenceValidatingAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	return adapter.decorated.Write(ctx, msgType, msg)
+}
+
+// Simple proxy for WritePropagated method.
+func (adapter *SequenceValidatingAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	return adapter.decorated.WritePropagated(ctx, msgType, msg, propagator)
+}
+
+// Simple proxy for WriteStream method.
+func (adapter *SequenceValidatingAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return adapter.decorated.WriteStream(ctx, msgType)
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *SequenceValidatingAdapter) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *SequenceValidatingAdapter) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *SequenceValidatingAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Check the sequence number of the message and record it as the last observed one unless the
+// message has been replayed.
+func (adapter *SequenceValidatingAdapter) check(msgType wsadapters.MessageType, msg []byte) error {
+	seq, err := adapter.extractSeq(msg)
+	if errors.Is(err, ErrNoSequence) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract sequence number: %w", err)
+	}
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	last, hasLast := adapter.last, adapter.hasLast
+	switch {
+	case !hasLast || seq == last+1:
+		adapter.last, adapter.hasLast = seq, true
+		return nil
+	case seq <= last:
+		return SequenceError{Err: ErrReplayDetected, Last: last, Received: seq, MsgType: msgType, Msg: msg}
+	default:
+		adapter.last = seq
+		return SequenceError{Err: ErrSequenceGap, Last: last, Received: seq, MsgType: msgType, Msg: msg}
+	}
+}
@@ -0,0 +1,137 @@
+package sequencing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for SequenceValidatingAdapter unit tests
+type SequenceValidatingAdapterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run SequenceValidatingAdapterUnitTestSuite test suite
+func TestSequenceValidatingAdapterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SequenceValidatingAdapterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory validates its inputs.
+func (suite *SequenceValidatingAdapterUnitTestSuite) TestNewSequenceValidatingAdapter() {
+	_, err := NewSequenceValidatingAdapter(nil, extractSeq)
+	require.Error(suite.T(), err)
+	_, err = NewSequenceValidatingAdapter(mock.NewMockWebsocketConnectionAdapter(), nil)
+	require.Error(suite.T(), err)
+}
+
+// Test consecutive sequence numbers are accepted, replayed messages are dropped and gaps are
+// reported with the message.
+func (suite *SequenceValidatingAdapterUnitTestSuite) TestSequenceCheck() {
+	inner := mock.NewMockWebsocketConnectionAdapter()
+	adapter, err := NewSequenceValidatingAdapter(inner, extractSeq)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	_, found := adapter.LastSequence()
+	require.False(suite.T(), found)
+	for _, msg := range []string{"10:a", "11:b", "heartbeat", "11:b", "9:z", "14:e", "15:f"} {
+		inner.EnqueueMessage(wsadapters.Text, []byte(msg))
+	}
+	// First message sets the initial sequence number
+	for _, expected := range []string{"10:a", "11:b", "heartbeat"} {
+		_, msg, err := adapter.Read(ctx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), expected, string(msg))
+	}
+	// Replayed messages
+	for _, replayed := range []int64{11, 9} {
+		_, msg, err := adapter.Read(ctx)
+		require.ErrorIs(suite.T(), err, ErrReplayDetected)
+		require.Nil(suite.T(), msg)
+		seqErr := new(SequenceError)
+		require.ErrorAs(suite.T(), err, seqErr)
+		require.Equal(suite.T(), int64(11), seqErr.Last)
+		require.Equal(suite.T(), replayed, seqErr.Received)
+	}
+	// Gap - message is carried by the error and becomes the reference
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, ErrSequenceGap)
+	seqErr := new(SequenceError)
+	require.ErrorAs(suite.T(), err, seqErr)
+	require.Equal(suite.T(), SequenceError{Err: ErrSequenceGap, Last: 11, Received: 14, MsgType: wsadapters.Text, Msg: []byte("14:e")}, *seqErr)
+	_, reader, err := adapter.ReadStream(ctx)
+	require.NoError(suite.T(), err)
+	msg, err := io.ReadAll(reader)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "15:f", string(msg))
+	last, found := adapter.LastSequence()
+	require.True(suite.T(), found)
+	require.Equal(suite.T(), int64(15), last)
+	// Invalid message
+	inner.EnqueueMessage(wsadapters.Text, []byte("x:y"))
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, strconv.ErrSyntax)
+	// Sequence restarts with the next connection
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	_, err = adapter.Dial(ctx, url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	inner.EnqueueMessage(wsadapters.Text, []byte("1:a"))
+	_, _, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+}
+
+// Test the engine provides replay errors to OnReadError and goes on processing messages.
+func (suite *SequenceValidatingAdapterUnitTestSuite) TestWithEngine() {
+	inner := mock.NewMockWebsocketConnectionAdapter()
+	adapter, err := NewSequenceValidatingAdapter(inner, extractSeq)
+	require.NoError(suite.T(), err)
+	client := wstest.NewRecordingClient()
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().WithReaderRoutinesCount(1)
+	engine, err := wscengine.NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	for _, msg := range []string{"1:a", "1:a", "2:b"} {
+		inner.EnqueueMessage(wsadapters.Text, []byte(msg))
+	}
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 2, 5*time.Second))
+	require.Len(suite.T(), client.RecordedOnReadErrors(), 1)
+	require.ErrorIs(suite.T(), client.RecordedOnReadErrors()[0].Err, ErrReplayDetected)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Extract the sequence number of messages formatted as "<seq>:<payload>"
+func extractSeq(msg []byte) (int64, error) {
+	seq, _, found := strings.Cut(string(msg), ":")
+	if !found {
+		return 0, fmt.Errorf("%w: %s", ErrNoSequence, msg)
+	}
+	return strconv.ParseInt(seq, 10, 64)
+}
@@ -0,0 +1,93 @@
+package wscengine
+
+import (
+	"context"
+	"crypto/rand"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Generator of the session IDs bound to each connection lifecycle.
+//
+// A session ID is generated each time the engine starts or restarts, before the connection is
+// opened. The session ID is provided to OnMessage and is available to all callbacks through their
+// context (see SessionIDFromContext).
+//
+// Implementations must be safe for concurrent use.
+type SessionIDGenerator interface {
+	// Generate a new session ID for a connection to the target URL. ctx carries the active span
+	// of the engine start.
+	Generate(ctx context.Context, target url.URL) string
+}
+
+// Adapter which allows the use of an ordinary function as a SessionIDGenerator, for example to
+// generate sortable IDs like ULIDs:
+//
+//	opts.WithSessionIDGenerator(SessionIDGeneratorFunc(func() string { return ulid.Make().String() }))
+type SessionIDGeneratorFunc func() string
+
+// Call the function.
+func (fn SessionIDGeneratorFunc) Generate(ctx context.Context, target url.URL) string {
+	return fn()
+}
+
+// SessionIDGenerator implementation which generates random (version 4) UUIDs formatted as
+// described in RFC 4122. The 122 random bits are read from crypto/rand so session IDs are
+// unpredictable and unique across replicas. This is the default generator.
+type UUIDSessionIDGenerator struct{}
+
+// Generate a new random UUID.
+func (generator UUIDSessionIDGenerator) Generate(ctx context.Context, target url.URL) string {
+	return newRandomSessionID()
+}
+
+// SessionIDGenerator implementation which uses the trace ID of the active OpenTelemetry span so
+// sessions can be correlated with traces. A random UUID is generated when there is no active
+// trace (no tracer provider configured for example).
+type TraceIDSessionIDGenerator struct{}
+
+// Return the trace ID of the active span or a random UUID if there is no active trace.
+func (generator TraceIDSessionIDGenerator) Generate(ctx context.Context, target url.URL) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if spanCtx.HasTraceID() {
+		return spanCtx.TraceID().String()
+	}
+	return newRandomSessionID()
+}
+
+// SessionIDGenerator implementation which generates session IDs from a monotonic counter: the
+// first session ID is "1", the next one is "2", ... The zero value is ready to use.
+type SequenceSessionIDGenerator struct {
+	// Last generated sequence number
+	seq atomic.Uint64
+}
+
+// Increment the counter and return its value.
+func (generator *SequenceSessionIDGenerator) Generate(ctx context.Context, target url.URL) string {
+	return strconv.FormatUint(generator.seq.Add(1), 10)
+}
+
+// Generate a random UUID from crypto/rand. Unlike uuid.New, the function does not depend on the
+// random source configured in the uuid package (see uuid.SetRand and uuid.EnableRandPool).
+func newRandomSessionID() string {
+	return uuid.Must(uuid.NewRandomFromReader(rand.Reader)).String()
+}
+
+// # Description
+//
+// Return the session ID stored in the context by the engine, if any. The session ID is stored
+// with middleware.ContextWithSessionId: the function is a shortcut for
+// middleware.SessionIdFromContext which tells whether a session ID is set.
+//
+// # Returns
+//
+// The session ID and true or an empty string and false if ctx does not carry a session ID.
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionId := middleware.SessionIdFromContext(ctx)
+	return sessionId, sessionId != ""
+}
@@ -0,0 +1,118 @@
+package wscengine
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for session ID generators tests
+type SessionIDGeneratorTestSuite struct {
+	suite.Suite
+}
+
+// Run SessionIDGeneratorTestSuite test suite
+func TestSessionIDGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionIDGeneratorTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the provided generators.
+func (suite *SessionIDGeneratorTestSuite) TestGenerators() {
+	target := url.URL{Scheme: "ws", Host: "localhost"}
+	// UUID - random version 4 UUIDs formatted as described in RFC 4122
+	id, err := uuid.Parse(UUIDSessionIDGenerator{}.Generate(context.Background(), target))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), uuid.Version(4), id.Version())
+	require.Equal(suite.T(), uuid.RFC4122, id.Variant())
+	require.NotEqual(suite.T(), id.String(), UUIDSessionIDGenerator{}.Generate(context.Background(), target))
+	// Function adapter
+	require.Equal(suite.T(), "custom", SessionIDGeneratorFunc(func() string { return "custom" }).Generate(context.Background(), target))
+	// Trace ID falls back to a UUID when there is no active trace
+	_, err = uuid.Parse(TraceIDSessionIDGenerator{}.Generate(context.Background(), target))
+	require.NoError(suite.T(), err)
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+	require.Equal(suite.T(), "4bf92f3577b34da6a3ce929d0e0e4736", TraceIDSessionIDGenerator{}.Generate(ctx, target))
+	// Sequence is safe for concurrent use
+	generator := &SequenceSessionIDGenerator{}
+	ids := sync.Map{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, loaded := ids.LoadOrStore(generator.Generate(context.Background(), target), true)
+			require.False(suite.T(), loaded)
+		}()
+	}
+	wg.Wait()
+	require.Equal(suite.T(), "101", generator.Generate(context.Background(), target))
+	// Session ID stored in context
+	_, ok := SessionIDFromContext(context.Background())
+	require.False(suite.T(), ok)
+	sessionId, ok := SessionIDFromContext(middleware.ContextWithSessionId(context.Background(), "42"))
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), "42", sessionId)
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the generated session ID is provided to callbacks and a new ID is generated on restart.
+func (suite *SessionIDGeneratorTestSuite) TestEngineSessionID() {
+	srv, _ := newCountingEchoServer()
+	defer srv.Close()
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil)
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().WithSessionIDGenerator(&SequenceSessionIDGenerator{})
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(timeoutCtx))
+	// Session ID is available in OnOpen and OnMessage
+	require.NoError(suite.T(), adapter.Write(timeoutCtx, wsadapters.Text, []byte("hello")))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	sessionId, ok := SessionIDFromContext(client.RecordedOnOpens()[0].Ctx)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), "1", sessionId)
+	require.Equal(suite.T(), "1", client.RecordedOnOpens()[0].SessionId)
+	require.Equal(suite.T(), "1", middleware.SessionIdFromContext(client.RecordedOnOpens()[0].Ctx))
+	msg := client.RecordedOnMessages()[0]
+	require.Equal(suite.T(), "1", msg.SessionId)
+	sessionId, _ = SessionIDFromContext(msg.Ctx)
+	require.Equal(suite.T(), "1", sessionId)
+	// Restart: OnClose receives the previous session ID and the new session gets a new ID
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.GoingAway, "restart"))
+	require.Eventually(suite.T(), func() bool { return len(client.RecordedOnOpens()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	sessionId, _ = SessionIDFromContext(client.RecordedOnCloses()[0].Ctx)
+	require.Equal(suite.T(), "1", sessionId)
+	require.Equal(suite.T(), "1", client.RecordedOnCloses()[0].SessionId)
+	sessionId, _ = SessionIDFromContext(client.RecordedOnOpens()[1].Ctx)
+	require.Equal(suite.T(), "2", sessionId)
+	require.Equal(suite.T(), "2", client.RecordedOnOpens()[1].SessionId)
+	require.NoError(suite.T(), engine.Stop(timeoutCtx))
+}
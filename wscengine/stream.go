@@ -0,0 +1,60 @@
+package wscengine
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+)
+
+// Return the provided client as a WebsocketClientStreamInterface if it implements it or nil.
+func asStreamClient(client wsclient.WebsocketClientInterface) wsclient.WebsocketClientStreamInterface {
+	streamClient, ok := client.(wsclient.WebsocketClientStreamInterface)
+	if !ok {
+		return nil
+	}
+	return streamClient
+}
+
+// # Description
+//
+// Read the next message. If the client cannot receive streamed messages, the message is read
+// with conn.Read. Otherwise, the message is read with conn.ReadStream: messages up to the stream
+// threshold are read in memory and messages larger than the threshold are returned as a stream.
+//
+// Messages are not streamed while the engine holds messages (see Pause): the held messages are
+// handed over to OnMessage.
+//
+// # Returns
+//
+// The message type and either the message content or a stream on the message content (the
+// other one is nil). An error is returned if the message could not be read.
+func (wsengine *WebsocketEngine) readMessage(ctx context.Context) (wsadapters.MessageType, []byte, io.Reader, error) {
+	if wsengine.streamClient == nil || wsengine.isHoldingMessages() {
+		msgType, msg, err := wsengine.conn.Read(ctx)
+		return msgType, msg, nil, err
+	}
+	msgType, reader, err := wsengine.conn.ReadStream(ctx)
+	if err != nil {
+		return msgType, nil, nil, err
+	}
+	threshold := int64(wsengine.engineCfgOpts.StreamThreshold)
+	if threshold == 0 {
+		return msgType, nil, reader, nil
+	}
+	// Read up to threshold + 1 bytes to know whether the message exceeds the threshold
+	buf := &bytes.Buffer{}
+	_, err = io.CopyN(buf, reader, threshold+1)
+	switch {
+	case err == io.EOF:
+		// Whole message has been read
+		return msgType, buf.Bytes(), nil, nil
+	case err != nil:
+		return msgType, nil, nil, err
+	default:
+		// Message exceeds the threshold - stream the bytes already read and then the rest
+		return msgType, nil, io.MultiReader(buf, reader), nil
+	}
+}
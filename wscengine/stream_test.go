@@ -0,0 +1,249 @@
+package wscengine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for streamed messages unit tests
+type StreamUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run StreamUnitTestSuite test suite
+func TestStreamUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StreamUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test messages larger than the stream threshold are routed to OnMessageStream and smaller
+// messages to OnMessage.
+func (suite *StreamUnitTestSuite) TestStreamThreshold() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(0)
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(2).
+		WithStreamThreshold(10)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), engine.streamClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	large := bytes.Repeat([]byte("x"), 100)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("0123456789"))
+	adapter.EnqueueMessage(wsadapters.Binary, large)
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	require.Equal(suite.T(), []byte("0123456789"), client.RecordedOnMessages()[0].Msg)
+	select {
+	case msg := <-client.streamed:
+		require.Equal(suite.T(), large, msg)
+	case <-ctx.Done():
+		suite.FailNow("large message should have been streamed")
+	}
+}
+
+// Test only messages larger than 1 MiB are streamed with the default threshold.
+func (suite *StreamUnitTestSuite) TestDefaultThreshold() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(0)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, nil, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	large := bytes.Repeat([]byte("x"), 1048577)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	adapter.EnqueueMessage(wsadapters.Binary, large)
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	require.Equal(suite.T(), []byte("hello"), client.RecordedOnMessages()[0].Msg)
+	select {
+	case msg := <-client.streamed:
+		require.Equal(suite.T(), large, msg)
+	case <-ctx.Done():
+		suite.FailNow("large message should have been streamed")
+	}
+}
+
+// Test all messages are streamed with a zero threshold and the unread part of a message is
+// discarded.
+func (suite *StreamUnitTestSuite) TestZeroThreshold() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(2)
+	opts := NewWebsocketEngineConfigurationOptions().WithStreamThreshold(0)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	adapter.EnqueueMessage(wsadapters.Text, []byte("world"))
+	for _, expected := range []string{"he", "wo"} {
+		select {
+		case msg := <-client.streamed:
+			require.Equal(suite.T(), expected, string(msg))
+		case <-ctx.Done():
+			suite.FailNow("message should have been streamed")
+		}
+	}
+	require.Empty(suite.T(), client.RecordedOnMessages())
+}
+
+// Test messages are not streamed when message middlewares are configured: all messages go
+// through the middlewares and are handed over to OnMessage.
+func (suite *StreamUnitTestSuite) TestStreamClientWithMiddleware() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(0)
+	seen := make(chan []byte, 10)
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithStreamThreshold(0).
+		WithMessageMiddleware(func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+			seen <- msg
+			next(ctx, msgType, bytes.ToUpper(msg))
+		})
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), engine.streamClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	require.Equal(suite.T(), []byte("hello"), <-seen)
+	require.Equal(suite.T(), []byte("HELLO"), client.RecordedOnMessages()[0].Msg)
+	require.Empty(suite.T(), client.streamed)
+}
+
+// Test a span is started for each OnMessageStream call.
+func (suite *StreamUnitTestSuite) TestStreamedMessageSpan() {
+	provider := &recordingTracerProvider{}
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(0)
+	opts := NewWebsocketEngineConfigurationOptions().WithStreamThreshold(0)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, provider)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Binary, []byte("hello"))
+	select {
+	case <-client.streamed:
+	case <-ctx.Done():
+		suite.FailNow("message should have been streamed")
+	}
+	_, found := provider.find(func(span recordedSpan) bool { return span.name == spanEngineOnMessageStream })
+	require.True(suite.T(), found)
+}
+
+// Test a streamed message takes a message slot while OnMessageStream runs.
+func (suite *StreamUnitTestSuite) TestStreamedMessageTakesSlot() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := newStreamRecordingClient(0)
+	client.block = make(chan struct{})
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithStreamThreshold(0).
+		WithMaxConcurrentMessages(1)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Binary, []byte("hello"))
+	require.Eventually(suite.T(), func() bool {
+		return len(engine.messageSlots) == 1
+	}, 5*time.Second, time.Millisecond)
+	close(client.block)
+	select {
+	case <-client.streamed:
+	case <-ctx.Done():
+		suite.FailNow("message should have been streamed")
+	}
+	require.Eventually(suite.T(), func() bool {
+		return len(engine.messageSlots) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+// Test clients which do not implement WebsocketClientStreamInterface receive all messages with
+// OnMessage.
+func (suite *StreamUnitTestSuite) TestClientWithoutStream() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, nil, nil)
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), engine.streamClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Recording client which also records streamed messages
+type streamRecordingClient struct {
+	*wstest.RecordingClient
+	// Number of bytes read from each stream - 0 to read the whole message
+	limit int64
+	// Streamed messages
+	streamed chan []byte
+	// Optional channel OnMessageStream waits for before it reads the stream
+	block chan struct{}
+}
+
+// Create a new streamRecordingClient which reads up to limit bytes from each stream
+func newStreamRecordingClient(limit int64) *streamRecordingClient {
+	return &streamRecordingClient{
+		RecordingClient: wstest.NewRecordingClient(),
+		limit:           limit,
+		streamed:        make(chan []byte, 10),
+	}
+}
+
+// Record the streamed message
+func (client *streamRecordingClient) OnMessageStream(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	reader io.Reader) {
+	if client.block != nil {
+		<-client.block
+	}
+	if client.limit > 0 {
+		reader = io.LimitReader(reader, client.limit)
+	}
+	msg, _ := io.ReadAll(reader)
+	client.streamed <- msg
+}
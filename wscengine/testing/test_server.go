@@ -0,0 +1,395 @@
+// The package provides a websocket server which can be used in integration tests of applications
+// which use the websocket engine: the server records all frames it receives and lets the test
+// send messages, close frames, ping floods and malformed frames to the clients.
+//
+// Import the package with an alias as its name collides with the standard testing package:
+//
+//	import wstesting "github.com/gbdevw/gowse/wscengine/testing"
+package testing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+)
+
+// Type of a websocket frame. Values are the frame opcodes defined by RFC6455.
+type FrameType int
+
+const (
+	// Text data frame
+	TextFrame FrameType = websocket.TextMessage
+	// Binary data frame
+	BinaryFrame FrameType = websocket.BinaryMessage
+	// Close control frame
+	CloseFrame FrameType = websocket.CloseMessage
+	// Ping control frame
+	PingFrame FrameType = websocket.PingMessage
+	// Pong control frame
+	PongFrame FrameType = websocket.PongMessage
+)
+
+// Frame received by the test server. Fragmented messages are recorded as a single frame.
+type Frame struct {
+	// Time when the frame has been received
+	Timestamp time.Time
+	// ID of the connection the frame has been received on
+	ConnID int
+	// Frame type
+	Type FrameType
+	// Frame payload - the close reason for close frames
+	Payload []byte
+	// Close code - only set for close frames
+	CloseCode wsadapters.StatusCode
+}
+
+// Callbacks called by the test server. All callbacks are optional.
+type TestServerHandler struct {
+	// Called in the goroutine of the connection once it has been upgraded, before frames are read.
+	OnConnect func(conn *TestConn)
+	// Called in the goroutine of the connection for each data frame (text or binary) received.
+	// Control frames are answered by the server: pings with a pong and close frames with a close
+	// frame which carries the same close code.
+	OnFrame func(conn *TestConn, frame Frame)
+}
+
+// # Description
+//
+// Return a handler which echoes the data frames received by the server.
+func EchoHandler() TestServerHandler {
+	return TestServerHandler{
+		OnFrame: func(conn *TestConn, frame Frame) {
+			conn.Send(wsadapters.MessageType(frame.Type), frame.Payload)
+		},
+	}
+}
+
+// Websocket server which runs over TLS on a random local port.
+//
+// The server accepts all websocket handshakes, records all frames it receives and calls the
+// handler callbacks. The server is closed when the test and its subtests complete.
+type TestServer struct {
+	// Underlying HTTP server
+	srv *httptest.Server
+	// Callbacks called by the server
+	handler TestServerHandler
+	// Upgrader used to upgrade HTTP requests
+	upgrader websocket.Upgrader
+	// Mutex used to protect conns, frames and lastId
+	mu sync.Mutex
+	// Open connections indexed by ID
+	conns map[int]*TestConn
+	// Received frames in reception order
+	frames []Frame
+	// ID of the last accepted connection
+	lastId int
+}
+
+// # Description
+//
+// Start a new TestServer which is closed when the test and its subtests complete.
+//
+// # Inputs
+//
+//   - t: Test which uses the server.
+//   - handler: Callbacks called by the server. Use a zero value to only record frames.
+//
+// # Returns
+//
+// The started server.
+func NewTestServer(t testing.TB, handler TestServerHandler) *TestServer {
+	server := &TestServer{
+		handler: handler,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		conns: map[int]*TestConn{},
+	}
+	server.srv = httptest.NewTLSServer(http.HandlerFunc(server.serveHTTP))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// # Description
+//
+// Return the URL (wss://...) clients must connect to.
+func (server *TestServer) URL() *url.URL {
+	u, _ := url.Parse("wss" + strings.TrimPrefix(server.srv.URL, "https"))
+	return u
+}
+
+// # Description
+//
+// Return a TLS configuration which trusts the self-signed certificate of the server. Use it to
+// configure the connection adapter of the client.
+func (server *TestServer) TLSConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(server.srv.Certificate())
+	return &tls.Config{RootCAs: pool}
+}
+
+// # Description
+//
+// Return a copy of all frames received by the server in reception order.
+func (server *TestServer) ReceivedFrames() []Frame {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]Frame(nil), server.frames...)
+}
+
+// # Description
+//
+// Return the open connections ordered by ID.
+func (server *TestServer) Connections() []*TestConn {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	conns := make([]*TestConn, 0, len(server.conns))
+	for id := 1; id <= server.lastId; id++ {
+		if conn, ok := server.conns[id]; ok {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// # Description
+//
+// Wait until at least count connections are open.
+//
+// # Returns
+//
+// True if count connections are open before the timeout elapses, false otherwise.
+func (server *TestServer) WaitForConnections(count int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(server.Connections()) >= count {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// # Description
+//
+// Send a message to all open connections.
+//
+// # Returns
+//
+// The joined errors of the connections the message could not be sent to, if any.
+func (server *TestServer) SendToAll(msgType wsadapters.MessageType, payload []byte) error {
+	errs := []error{}
+	for _, conn := range server.Connections() {
+		if err := conn.Send(msgType, payload); err != nil {
+			errs = append(errs, fmt.Errorf("connection %d: %w", conn.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// # Description
+//
+// Send a close frame to all open connections.
+//
+// # Returns
+//
+// The joined errors of the connections the close frame could not be sent to, if any.
+func (server *TestServer) CloseAll(code wsadapters.StatusCode, reason string) error {
+	errs := []error{}
+	for _, conn := range server.Connections() {
+		if err := conn.SendClose(code, reason); err != nil {
+			errs = append(errs, fmt.Errorf("connection %d: %w", conn.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// # Description
+//
+// Drop all open connections without sending a close frame.
+func (server *TestServer) DropAll() {
+	for _, conn := range server.Connections() {
+		conn.Drop()
+	}
+}
+
+// # Description
+//
+// Drop all connections and stop the server. Calling Close more than once has no effect.
+func (server *TestServer) Close() {
+	server.DropAll()
+	server.srv.Close()
+}
+
+/*************************************************************************************************/
+/* TEST CONNECTION                                                                               */
+/*************************************************************************************************/
+
+// Connection accepted by the test server.
+type TestConn struct {
+	// Connection ID - connections are numbered from 1 in acceptance order
+	id int
+	// Handshake request
+	request *http.Request
+	// Underlying connection
+	conn *websocket.Conn
+	// Mutex used to serialize writes of data frames
+	writeMu sync.Mutex
+}
+
+// # Description
+//
+// Return the ID of the connection. Connections are numbered from 1 in acceptance order.
+func (conn *TestConn) ID() int {
+	return conn.id
+}
+
+// # Description
+//
+// Return the handshake request of the connection.
+func (conn *TestConn) Request() *http.Request {
+	return conn.request
+}
+
+// # Description
+//
+// Send a text or binary message.
+func (conn *TestConn) Send(msgType wsadapters.MessageType, payload []byte) error {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	return conn.conn.WriteMessage(int(msgType), payload)
+}
+
+// # Description
+//
+// Send a close frame with the provided close code and reason. The connection is dropped once the
+// client has answered with its close frame.
+func (conn *TestConn) SendClose(code wsadapters.StatusCode, reason string) error {
+	return conn.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(int(code), reason), time.Now().Add(time.Second))
+}
+
+// # Description
+//
+// Send a ping frame with the provided payload.
+func (conn *TestConn) Ping(payload []byte) error {
+	return conn.conn.WriteControl(websocket.PingMessage, payload, time.Now().Add(time.Second))
+}
+
+// # Description
+//
+// Send count ping frames in a row to simulate a ping flood.
+func (conn *TestConn) PingFlood(count int) error {
+	for i := 0; i < count; i++ {
+		if err := conn.Ping([]byte(fmt.Sprint(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// # Description
+//
+// Simulate an error in the middle of a message: send the header of a frame which announces the
+// whole payload, send the first half of the payload and drop the connection.
+func (conn *TestConn) SendTruncated(msgType wsadapters.MessageType, payload []byte) error {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	// Server frames are not masked: FIN + opcode, then payload length
+	header := []byte{0x80 | byte(msgType)}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(length))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(length))
+	}
+	raw := conn.conn.UnderlyingConn()
+	_, err := raw.Write(append(header, payload[:len(payload)/2]...))
+	raw.Close()
+	return err
+}
+
+// # Description
+//
+// Drop the connection without sending a close frame.
+func (conn *TestConn) Drop() error {
+	return conn.conn.UnderlyingConn().Close()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Upgrade the request, register the connection and read frames until the connection is closed.
+func (server *TestServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := server.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	server.mu.Lock()
+	server.lastId++
+	conn := &TestConn{id: server.lastId, request: r, conn: ws}
+	server.conns[conn.id] = conn
+	server.mu.Unlock()
+	defer func() {
+		server.mu.Lock()
+		delete(server.conns, conn.id)
+		server.mu.Unlock()
+		ws.Close()
+	}()
+	// Record control frames and answer them
+	ws.SetPingHandler(func(appData string) error {
+		server.record(Frame{ConnID: conn.id, Type: PingFrame, Payload: []byte(appData)})
+		err := ws.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	ws.SetPongHandler(func(appData string) error {
+		server.record(Frame{ConnID: conn.id, Type: PongFrame, Payload: []byte(appData)})
+		return nil
+	})
+	ws.SetCloseHandler(func(code int, text string) error {
+		server.record(Frame{ConnID: conn.id, Type: CloseFrame, Payload: []byte(text), CloseCode: wsadapters.StatusCode(code)})
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+		return nil
+	})
+	if server.handler.OnConnect != nil {
+		server.handler.OnConnect(conn)
+	}
+	for {
+		msgType, payload, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame := Frame{ConnID: conn.id, Type: FrameType(msgType), Payload: payload}
+		server.record(frame)
+		if server.handler.OnFrame != nil {
+			server.handler.OnFrame(conn, frame)
+		}
+	}
+}
+
+// Record a received frame.
+func (server *TestServer) record(frame Frame) {
+	frame.Timestamp = time.Now()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.frames = append(server.frames, frame)
+}
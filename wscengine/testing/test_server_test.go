@@ -0,0 +1,178 @@
+package testing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for TestServer integration tests
+type TestServerIntegrationTestSuite struct {
+	suite.Suite
+}
+
+// Run TestServerIntegrationTestSuite test suite
+func TestTestServerIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(TestServerIntegrationTestSuite))
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the server echoes messages and records data and control frames.
+func (suite *TestServerIntegrationTestSuite) TestEchoAndReceivedFrames() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), EchoHandler())
+	require.Equal(suite.T(), "wss", server.URL().Scheme)
+	adapter := suite.dial(ctx, server)
+	require.True(suite.T(), server.WaitForConnections(1, 5*time.Second))
+	require.NotNil(suite.T(), server.Connections()[0].Request())
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("hello")))
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), "hello", string(msg))
+	// Ping and close - pong and close frames are answered by the server
+	go adapter.Read(ctx)
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.GoingAway, "bye"))
+	require.Eventually(suite.T(), func() bool {
+		return len(server.ReceivedFrames()) == 3
+	}, 5*time.Second, time.Millisecond)
+	frames := server.ReceivedFrames()
+	require.Equal(suite.T(), TextFrame, frames[0].Type)
+	require.Equal(suite.T(), []byte("hello"), frames[0].Payload)
+	require.Equal(suite.T(), 1, frames[0].ConnID)
+	require.Equal(suite.T(), PingFrame, frames[1].Type)
+	require.Equal(suite.T(), CloseFrame, frames[2].Type)
+	require.Equal(suite.T(), wsadapters.GoingAway, frames[2].CloseCode)
+	require.Equal(suite.T(), []byte("bye"), frames[2].Payload)
+	// Connection is forgotten once closed
+	require.Eventually(suite.T(), func() bool {
+		return len(server.Connections()) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+// Test SendToAll and OnConnect.
+func (suite *TestServerIntegrationTestSuite) TestSendToAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), TestServerHandler{
+		OnConnect: func(conn *TestConn) {
+			conn.Send(wsadapters.Text, []byte("welcome"))
+		},
+	})
+	first := suite.dial(ctx, server)
+	second := suite.dial(ctx, server)
+	require.True(suite.T(), server.WaitForConnections(2, 5*time.Second))
+	require.Equal(suite.T(), 1, server.Connections()[0].ID())
+	require.Equal(suite.T(), 2, server.Connections()[1].ID())
+	require.NoError(suite.T(), server.SendToAll(wsadapters.Binary, []byte("news")))
+	for _, adapter := range []*gorilla.GorillaWebsocketConnectionAdapter{first, second} {
+		_, msg, err := adapter.Read(ctx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), "welcome", string(msg))
+		msgType, msg, err := adapter.Read(ctx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), wsadapters.Binary, msgType)
+		require.Equal(suite.T(), "news", string(msg))
+	}
+}
+
+// Test CloseAll sends a close frame to the clients.
+func (suite *TestServerIntegrationTestSuite) TestCloseAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), TestServerHandler{})
+	adapter := suite.dial(ctx, server)
+	require.True(suite.T(), server.WaitForConnections(1, 5*time.Second))
+	require.NoError(suite.T(), server.CloseAll(wsadapters.PolicyViolation, "maintenance"))
+	_, _, err := adapter.Read(ctx)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.PolicyViolation, closeErr.Code)
+	require.Contains(suite.T(), closeErr.Reason, "maintenance")
+}
+
+// Test the pongs answered by the client to a ping flood are recorded.
+func (suite *TestServerIntegrationTestSuite) TestPingFlood() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), TestServerHandler{})
+	adapter := suite.dial(ctx, server)
+	require.True(suite.T(), server.WaitForConnections(1, 5*time.Second))
+	// Client answers pings while it reads
+	go adapter.Read(ctx)
+	require.NoError(suite.T(), server.Connections()[0].PingFlood(50))
+	require.Eventually(suite.T(), func() bool {
+		return len(server.ReceivedFrames()) == 50
+	}, 5*time.Second, time.Millisecond)
+	for _, frame := range server.ReceivedFrames() {
+		require.Equal(suite.T(), PongFrame, frame.Type)
+	}
+}
+
+// Test a truncated message and a dropped connection make the client read fail.
+func (suite *TestServerIntegrationTestSuite) TestMidStreamErrors() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), TestServerHandler{})
+	truncated := suite.dial(ctx, server)
+	dropped := suite.dial(ctx, server)
+	require.True(suite.T(), server.WaitForConnections(2, 5*time.Second))
+	conns := server.Connections()
+	require.NoError(suite.T(), conns[0].SendTruncated(wsadapters.Binary, make([]byte, 70000)))
+	_, _, err := truncated.Read(ctx)
+	require.Error(suite.T(), err)
+	require.NoError(suite.T(), conns[1].Drop())
+	_, _, err = dropped.Read(ctx)
+	require.Error(suite.T(), err)
+}
+
+// Test the server with a websocket engine: the engine receives the messages and reconnects when
+// the connection is dropped.
+func (suite *TestServerIntegrationTestSuite) TestWithEngine() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := NewTestServer(suite.T(), TestServerHandler{})
+	client := wstest.NewRecordingClient()
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithReconnectBackoff(func(retryCount int) time.Duration { return 10 * time.Millisecond })
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapterWithTLS(server.TLSConfig(), nil)
+	engine, err := wscengine.NewWebsocketEngine(server.URL(), adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	require.True(suite.T(), server.WaitForConnections(1, 5*time.Second))
+	require.NoError(suite.T(), server.SendToAll(wsadapters.Text, []byte("tick")))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	server.DropAll()
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2
+	}, 5*time.Second, time.Millisecond)
+}
+
+/*************************************************************************************************/
+/* UTILITIES                                                                                     */
+/*************************************************************************************************/
+
+// Connect a gorilla adapter to the server
+func (suite *TestServerIntegrationTestSuite) dial(ctx context.Context, server *TestServer) *gorilla.GorillaWebsocketConnectionAdapter {
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapterWithTLS(server.TLSConfig(), nil)
+	_, err := adapter.Dial(ctx, *server.URL())
+	require.NoError(suite.T(), err)
+	return adapter
+}
@@ -37,6 +37,8 @@ const (
 	spanEngineOnReadError = callbacksNamespace + ".on_read_error"
 	// Name of span used to trace OnMessage call
 	spanEngineOnMessage = callbacksNamespace + ".on_message"
+	// Name of span used to trace OnMessageStream call
+	spanEngineOnMessageStream = callbacksNamespace + ".on_message_stream"
 	// Name of span used to trace OnClose call
 	spanEngineOnClose = callbacksNamespace + ".on_close"
 	// Name of span used to trace OnCloseError call
@@ -45,6 +47,10 @@ const (
 	spanEngineShutdown = engineBackgroundNamespace + ".shutdown"
 	// Name of span used to trace restart call
 	spanEngineRestart = engineBackgroundNamespace + ".restart"
+	// Name of span used to trace pings sent by the engine
+	spanEngineBackgroundPing = engineBackgroundNamespace + ".ping"
+	// Name of span used to trace the handling of a silently dropped connection
+	spanEngineBackgroundReadIdle = engineBackgroundNamespace + ".read_idle"
 	// Name of span used to trace OnRestartError callback call
 	spanEngineOnRestartError = callbacksNamespace + ".on_restart_error"
 
@@ -52,8 +58,26 @@ const (
 	eventEngineGoroutineExit = namespace + ".worker_exit"
 	// Event used in span to signal connection has been closed
 	eventConnectionClosed = namespace + ".connection_closed"
+	// Event used in span to signal connection has been dropped without close message
+	eventConnectionDropped = namespace + ".connection_dropped"
 	// Event used in span to indicate engine definitely stops
 	eventEngineExit = namespace + ".exit"
+	// Event used in span to indicate a persisted engine state has been restored
+	eventStateRestored = namespace + ".state_restored"
+	// Event used in span to indicate the target hostname has been resolved before dial
+	eventTargetResolved = namespace + ".target_resolved"
+	// Event used in span to indicate the server has provided the next reconnect delay
+	eventServerRetryAfter = namespace + ".server_retry_after"
+	// Event used in span to indicate the engine failed to reconnect to the previous server
+	eventServerAffinityFailed = namespace + ".server_affinity_failed"
+	// Event used in span to indicate the reconnect policy delays the next reconnect attempt
+	eventReconnectPolicyWait = namespace + ".reconnect_policy_wait"
+	// Event used in span to indicate a ping sent by the engine has failed
+	eventPingFailed = namespace + ".ping_failed"
+	// Event used in span to indicate no message has been received during the read idle timeout
+	eventReadIdleTimeout = namespace + ".read_idle_timeout"
+	// Event used in span to indicate the drain timeout elapsed before in-flight messages were processed
+	eventDrainTimeout = namespace + ".drain_timeout"
 
 	// Attribute used to indicate close reason code
 	attrCloseCode = namespace + ".close_code"
@@ -77,6 +101,14 @@ const (
 	attrAutoReconnect = namespace + ".auto_reconnect"
 	// Attribute used to count the number of retries performed
 	attrRetryCount = namespace + ".retry_count"
+	// Attribute used to store the target hostname resolved before dial
+	attrTargetHost = namespace + ".target.host"
+	// Attribute used to store the IP address the target hostname resolved to
+	attrTargetIP = namespace + ".target.ip"
+	// Attribute used to store the reconnect delay provided by the server (milliseconds)
+	attrRetryAfterMs = namespace + ".retry_after_ms"
+	// Attribute used to store the address of the server the previous session was connected to
+	attrAffinityAddr = namespace + ".affinity_addr"
 )
 
 // # Description
@@ -0,0 +1,158 @@
+package wscengine
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for engine tracing unit tests
+type TracingUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run TracingUnitTestSuite test suite
+func TestTracingUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test a span started in OnMessage is the parent of the write span created by the adapter
+// decorators.
+func (suite *TracingUnitTestSuite) TestWriteSpanParentIsCallbackSpan() {
+	suite.testWriteSpanParent(NewWebsocketEngineConfigurationOptions().WithReaderRoutinesCount(1))
+}
+
+// Test a span started in OnMessage is the parent of the write span when the message is written
+// by the write queue goroutine.
+func (suite *TracingUnitTestSuite) TestWriteSpanParentIsCallbackSpanWithWriteQueue() {
+	suite.testWriteSpanParent(NewWebsocketEngineConfigurationOptions().WithReaderRoutinesCount(1).WithWriteQueue(4))
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Start an engine with the provided options and check the write span created when OnMessage
+// replies is a child of the span started by OnMessage.
+func (suite *TracingUnitTestSuite) testWriteSpanParent(opts *WebsocketEngineConfigurationOptions) {
+	provider := &recordingTracerProvider{}
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	client := &tracingClient{RecordingClient: wstest.NewRecordingClient(), tracer: provider.Tracer("test")}
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, provider)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	adapter.EnqueueMessage(wsadapters.Text, []byte("ping"))
+	require.Eventually(suite.T(), func() bool {
+		return len(adapter.WrittenMessages()) == 1
+	}, 5*time.Second, time.Millisecond)
+	// Find the callback span and check it is the parent of the write span
+	callbackSpan, found := provider.find(func(span recordedSpan) bool { return span.name == "test.on_message" })
+	require.True(suite.T(), found)
+	require.Eventually(suite.T(), func() bool {
+		_, found := provider.find(func(span recordedSpan) bool {
+			return strings.HasSuffix(span.name, ".write") && span.parent == callbackSpan.id
+		})
+		return found
+	}, 5*time.Second, time.Millisecond)
+}
+
+// Recording client which starts a span in OnMessage and replies "pong" with the span context
+type tracingClient struct {
+	*wstest.RecordingClient
+	tracer trace.Tracer
+}
+
+// Record the call and reply with the context of a new span
+func (client *tracingClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.RecordingClient.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	ctx, span := client.tracer.Start(ctx, "test.on_message")
+	defer span.End()
+	conn.Write(ctx, wsadapters.Text, []byte("pong"))
+}
+
+// Span recorded by recordingTracerProvider
+type recordedSpan struct {
+	name   string
+	id     trace.SpanID
+	parent trace.SpanID
+}
+
+// Minimal TracerProvider which records the name, ID and parent ID of started spans
+type recordingTracerProvider struct {
+	embedded.TracerProvider
+	mu     sync.Mutex
+	spans  []recordedSpan
+	nextId uint64
+}
+
+func (provider *recordingTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{provider: provider}
+}
+
+// Return the first recorded span which matches the filter
+func (provider *recordingTracerProvider) find(filter func(span recordedSpan) bool) (recordedSpan, bool) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	for _, span := range provider.spans {
+		if filter(span) {
+			return span, true
+		}
+	}
+	return recordedSpan{}, false
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	provider *recordingTracerProvider
+}
+
+// Record the span and return a non recording span with a new span context
+func (tracer *recordingTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	tracer.provider.mu.Lock()
+	defer tracer.provider.mu.Unlock()
+	tracer.provider.nextId++
+	id := trace.SpanID{}
+	for i := range id {
+		id[i] = byte(tracer.provider.nextId >> (8 * (len(id) - 1 - i)))
+	}
+	tracer.provider.spans = append(tracer.provider.spans, recordedSpan{
+		name:   spanName,
+		id:     id,
+		parent: trace.SpanContextFromContext(ctx).SpanID(),
+	})
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x01},
+		SpanID:  id,
+	}))
+	return ctx, trace.SpanFromContext(ctx)
+}
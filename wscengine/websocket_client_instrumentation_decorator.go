@@ -3,6 +3,7 @@ package wscengine
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 
@@ -58,6 +59,7 @@ func (decorator *websocketClientInstrumentationDecorator) OnOpen(
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
 	exit context.CancelFunc,
+	sessionId string,
 	restarting bool) error {
 	// Start a span
 	ctx, span := decorator.tracer.Start(ctx, spanEngineOnOpen,
@@ -67,7 +69,7 @@ func (decorator *websocketClientInstrumentationDecorator) OnOpen(
 		))
 	defer span.End()
 	// Call decorated.OnOpen, handle and return results
-	err := decorator.decorated.OnOpen(ctx, resp, conn, readMutex, exit, restarting)
+	err := decorator.decorated.OnOpen(ctx, resp, conn, readMutex, exit, sessionId, restarting)
 	return handlePotentialError(err, span)
 }
 
@@ -94,6 +96,33 @@ func (decorator *websocketClientInstrumentationDecorator) OnMessage(
 	decorator.decorated.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
 }
 
+// Instrument decorated.OnMessageStream call. The stream is discarded by the engine if decorated
+// does not implement wsclient.WebsocketClientStreamInterface.
+func (decorator *websocketClientInstrumentationDecorator) OnMessageStream(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	reader io.Reader) {
+	streamClient, ok := decorator.decorated.(wsclient.WebsocketClientStreamInterface)
+	if !ok {
+		return
+	}
+	// Start a span
+	ctx, span := decorator.tracer.Start(ctx, spanEngineOnMessageStream,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.Int(attrMsgType, int(msgType)),
+		))
+	defer span.End()
+	defer span.SetStatus(codes.Ok, codes.Ok.String())
+	// Call decorated.OnMessageStream
+	streamClient.OnMessageStream(ctx, conn, readMutex, restart, exit, sessionId, msgType, reader)
+}
+
 // Instrument decorated.OnReadError call
 func (decorator *websocketClientInstrumentationDecorator) OnReadError(
 	ctx context.Context,
@@ -101,6 +130,7 @@ func (decorator *websocketClientInstrumentationDecorator) OnReadError(
 	readMutex *sync.Mutex,
 	restart context.CancelFunc,
 	exit context.CancelFunc,
+	sessionId string,
 	err error) {
 	// Start span
 	ctx, span := decorator.tracer.Start(ctx, spanEngineOnReadError,
@@ -108,7 +138,7 @@ func (decorator *websocketClientInstrumentationDecorator) OnReadError(
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
 	// Call decorated.OnReadError
-	decorator.decorated.OnReadError(ctx, conn, readMutex, restart, exit, err)
+	decorator.decorated.OnReadError(ctx, conn, readMutex, restart, exit, sessionId, err)
 }
 
 // Instument decorated.OnClose call
@@ -116,6 +146,7 @@ func (decorator *websocketClientInstrumentationDecorator) OnClose(
 	ctx context.Context,
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
+	sessionId string,
 	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
 	// Start span
 	ctx, span := decorator.tracer.Start(ctx, spanEngineOnClose,
@@ -123,12 +154,13 @@ func (decorator *websocketClientInstrumentationDecorator) OnClose(
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
 	// Call decorated.OnClose and return results
-	return decorator.decorated.OnClose(ctx, conn, readMutex, closeMessage)
+	return decorator.decorated.OnClose(ctx, conn, readMutex, sessionId, closeMessage)
 }
 
 // Instrument decorated.OnCloseError call
 func (decorator *websocketClientInstrumentationDecorator) OnCloseError(
 	ctx context.Context,
+	sessionId string,
 	err error) {
 	// Start span
 	ctx, span := decorator.tracer.Start(ctx, spanEngineOnCloseError,
@@ -136,13 +168,14 @@ func (decorator *websocketClientInstrumentationDecorator) OnCloseError(
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
 	// Call decorated.OnCloseError
-	decorator.decorated.OnCloseError(ctx, err)
+	decorator.decorated.OnCloseError(ctx, sessionId, err)
 }
 
 // Instrument decorated.OnRestartError call
 func (decorator *websocketClientInstrumentationDecorator) OnRestartError(
 	ctx context.Context,
 	exit context.CancelFunc,
+	sessionId string,
 	err error,
 	retryCount int) {
 	// Start span
@@ -154,5 +187,5 @@ func (decorator *websocketClientInstrumentationDecorator) OnRestartError(
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
 	// Call decorated.OnCloseError
-	decorator.decorated.OnRestartError(ctx, exit, err, retryCount)
+	decorator.decorated.OnRestartError(ctx, exit, sessionId, err, retryCount)
 }
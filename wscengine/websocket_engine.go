@@ -4,11 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
+	"net"
+	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	wscontext "github.com/gbdevw/gowse/wscengine/context"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/persistence"
 	"github.com/gbdevw/gowse/wscengine/wsadapters"
 	"github.com/gbdevw/gowse/wscengine/wsclient"
 	"github.com/google/uuid"
@@ -16,6 +24,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 // Engine which manages a websocket connection, read incoming messages, calls appropriate client
@@ -47,6 +56,50 @@ type WebsocketEngine struct {
 	readMutex *sync.Mutex
 	// Used to ensure shutdown is performed once
 	shutdownSync *sync.Once
+	// Used to track the OnMessage callbacks of the current session which are still running
+	inFlightMessages *sync.WaitGroup
+	// Current engine state - saved with the configured state persister if any
+	state persistence.EngineState
+	// State loaded from the state persister when engine has started - nil if none
+	restoredState *persistence.EngineState
+	// Mutex used to protect state and restoredState
+	stateMutex *sync.Mutex
+	// Handshake response returned by the last failed dial - nil if none
+	lastDialResponse *http.Response
+	// Close error received when the last session ended - zero value if none
+	lastCloseError wsadapters.WebsocketCloseError
+	// Mutex used to protect lastDialResponse and lastCloseError
+	lastFailureMutex *sync.Mutex
+	// Remote address of the last successful connection used as server affinity hint - empty if
+	// none. Protected by startMutex.
+	affinityAddr string
+	// Current state of the feature flags
+	featureFlags map[FeatureFlagKey]bool
+	// Changes of feature flags which require a restart, applied when the engine (re)starts
+	pendingFeatureFlags map[FeatureFlagKey]bool
+	// Mutex used to protect featureFlags and pendingFeatureFlags
+	featureFlagsMutex *sync.Mutex
+	// Last dial attempts
+	dialHistory *dialHistory
+	// Publisher of the engine state changes
+	stateNotifier *engineStateNotifier
+	// Logger used to log engine activity - discards records if no logger is configured
+	logger *slog.Logger
+	// Time (unix nanoseconds) the last message has been read. Used to skip unneeded pings.
+	lastMessageAt atomic.Int64
+	// Time (unix nanoseconds) the last ping sent by the engine has succeeded. Used to detect
+	// silently dropped connections.
+	lastPongAt atomic.Int64
+	// Limiter used to limit the write rate - nil if write rate is not limited
+	writeLimiter *rate.Limiter
+	// Decorated client used to stream large messages if the user provided client can receive
+	// streamed messages and no middleware is configured - nil otherwise
+	streamClient *websocketClientInstrumentationDecorator
+	// Gate which holds the messages received while the engine is paused
+	pauseGate *pauseGate
+	// Slots of the OnMessage callbacks which run in a dedicated goroutine - nil if the engine
+	// goroutines call OnMessage
+	messageSlots chan struct{}
 }
 
 // # Description
@@ -103,26 +156,77 @@ func NewWebsocketEngine(
 			return nil, err
 		}
 	}
+	// Report messages and pings to the configured metrics
+	if opts.Metrics != nil {
+		conn = &metricsConnectionDecorator{decorated: conn, metrics: opts.Metrics}
+	}
+	// Log ping/pong cycles if a logger is configured
+	if opts.Logger != nil {
+		conn = &loggingConnectionDecorator{decorated: conn, logger: opts.Logger}
+	}
+	// Limit the write rate if enabled - applies to the writer goroutine if write queue is enabled
+	var writeLimiter *rate.Limiter
+	if opts.WriteRateLimit > 0 {
+		writeLimiter = rate.NewLimiter(rate.Limit(opts.WriteRateLimit), max(opts.WriteRateBurst, 1))
+		conn = &writeRateLimitConnectionDecorator{decorated: conn, limiter: writeLimiter}
+	}
+	// Queue written messages if enabled
+	if opts.WriteQueueDepth > 0 {
+		conn = newWriteQueueConnectionDecorator(conn, opts.WriteQueueDepth, opts.Logger)
+	}
+	// Run received messages through the configured middlewares before user OnMessage callback
+	if len(opts.MessageMiddlewares) > 0 {
+		wsclient, err = middleware.NewWebsocketClientMiddlewareDecorator(wsclient, opts.MessageMiddlewares...)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Create tracing decorator for user provided callbacks
 	decorated, err := NewWebsocketClientInstrumentationDecorator(wsclient, tracerProvider)
 	if err != nil {
 		return nil, err
 	}
+	// Stream large messages through the tracing decorator if the user provided client can receive
+	// them. Middlewares process whole messages: messages are not streamed when middlewares are set.
+	var streamClient *websocketClientInstrumentationDecorator
+	if len(opts.MessageMiddlewares) == 0 && asStreamClient(wsclient) != nil {
+		streamClient = decorated
+	}
+	// Bound the number of OnMessage callbacks which run at once if enabled
+	var messageSlots chan struct{}
+	if opts.MaxConcurrentMessages > 0 {
+		messageSlots = make(chan struct{}, opts.MaxConcurrentMessages)
+	}
 	// Return websocket engine
 	return &WebsocketEngine{
 		engineCtx: nil,
 		engineStopFunc: func() {
 		},
-		target:         url,
-		conn:           conn,
-		wsclient:       decorated,
-		engineCfgOpts:  opts,
-		tracer:         tracerProvider.Tracer(pkgName, trace.WithInstrumentationVersion(pkgVersion)),
-		started:        false,
-		stoppedChannel: make(chan bool, 1),
-		startMutex:     &sync.Mutex{},
-		readMutex:      &sync.Mutex{},
-		shutdownSync:   &sync.Once{},
+		target:              url,
+		conn:                conn,
+		wsclient:            decorated,
+		engineCfgOpts:       opts,
+		tracer:              tracerProvider.Tracer(pkgName, trace.WithInstrumentationVersion(pkgVersion)),
+		started:             false,
+		stoppedChannel:      make(chan bool, 1),
+		startMutex:          &sync.Mutex{},
+		readMutex:           &sync.Mutex{},
+		shutdownSync:        &sync.Once{},
+		inFlightMessages:    &sync.WaitGroup{},
+		state:               persistence.EngineState{TargetURL: url.String()},
+		restoredState:       nil,
+		stateMutex:          &sync.Mutex{},
+		lastFailureMutex:    &sync.Mutex{},
+		featureFlags:        newFeatureFlags(),
+		pendingFeatureFlags: map[FeatureFlagKey]bool{},
+		featureFlagsMutex:   &sync.Mutex{},
+		dialHistory:         &dialHistory{},
+		stateNotifier:       newEngineStateNotifier(),
+		logger:              loggerOrDiscard(opts.Logger),
+		writeLimiter:        writeLimiter,
+		streamClient:        streamClient,
+		pauseGate:           newPauseGate(),
+		messageSlots:        messageSlots,
 	}, nil
 }
 
@@ -189,18 +293,24 @@ func (wsengine *WebsocketEngine) Start(ctx context.Context) error {
 				time.Duration(wsengine.engineCfgOpts.OnOpenTimeoutMs*int64(time.Millisecond)))
 			defer cancel()
 		}
+		// Restore persisted state before the first dial
+		wsengine.restoreState(span)
 		// Create internal channel to wait for the engine start completion signal
 		startupChannel := make(chan error, 1)
 		// Start a goroutine that will kick off the websocket engine.
-		go wsengine.startEngine(ctx, false, startupChannel, wsengine.engineStopFunc)
+		go wsengine.startEngine(ctx, false, 1, wsengine.generateSessionID(ctx), startupChannel, wsengine.engineStopFunc)
 		// Read from error channel or context done channel to know when the engine has finished
 		// starting or if a timeout has occured
 		select {
 		case err := <-startupChannel:
 			// Engine has finished starting and sent back either a nil value (OK) or an error.
+			if err != nil {
+				wsengine.logger.ErrorContext(ctx, "engine failed to start", logKeyTarget, wsengine.target.String(), logKeyError, err)
+			}
 			return handlePotentialError(err, span)
 		case <-startCtx.Done():
 			// A timeout has occured or provided parent context has been canceled.
+			wsengine.logger.ErrorContext(ctx, "engine failed to start", logKeyTarget, wsengine.target.String(), logKeyError, startCtx.Err())
 			return handleError(EngineStartError{Err: ctx.Err()}, span, codes.Error, codes.Error.String())
 		}
 	}
@@ -302,6 +412,45 @@ func (wsengine *WebsocketEngine) GetReadMutex() *sync.Mutex {
 	return wsengine.readMutex
 }
 
+// # Description
+//
+// Return the engine state which has been loaded from the configured state persister when the
+// engine has started.
+//
+// # Return
+//
+// The restored state and true if a persisted state has been loaded, an empty state and false
+// otherwise.
+func (wsengine *WebsocketEngine) GetRestoredState() (persistence.EngineState, bool) {
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	if wsengine.restoredState == nil {
+		return persistence.EngineState{}, false
+	}
+	return *wsengine.restoredState, true
+}
+
+// # Description
+//
+// Set the opaque blob which describes active subscriptions and persist the engine state with the
+// configured state persister if any. The blob is persisted each time the engine state is saved and
+// can be retrieved from GetRestoredState after a restart.
+//
+// The method can be called from inside callbacks.
+//
+// # Return
+//
+// Nil in case of success or the error returned by the state persister.
+func (wsengine *WebsocketEngine) SetSubscriptions(subscriptions []byte) error {
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	wsengine.state.Subscriptions = subscriptions
+	if wsengine.engineCfgOpts.StatePersister == nil {
+		return nil
+	}
+	return wsengine.engineCfgOpts.StatePersister.Save(wsengine.state)
+}
+
 /*************************************************************************************************/
 /* WEBSOCKET ENGINE                                                                              */
 /*************************************************************************************************/
@@ -334,16 +483,32 @@ func (wsengine *WebsocketEngine) GetReadMutex() *sync.Mutex {
 //   - ctx: Context used for tracing/coordination purposes
 //   - startupChannel: Channel used by the engine to signal it has finished starting.
 //   - restart: Indicates if the method is called because the engine starts or is restarting
+//   - attempt: Number of the connection attempt - 1 when the engine starts, retry count + 1 when
+//     the engine restarts. Provided in DialError.
+//   - sessionId: ID of the session - provided to all callbacks of the session.
 //   - exit: Function to call to prevent the engine from restarting
 func (wsengine *WebsocketEngine) startEngine(
 	ctx context.Context,
 	restart bool,
+	attempt int,
+	sessionId string,
 	startupChannel chan error,
 	exit context.CancelFunc) {
 
 	// Lock start mutex
 	wsengine.startMutex.Lock()
 	defer wsengine.startMutex.Unlock()
+	// Publish the connecting state when the engine starts and the stopped state if it fails to start
+	if !restart && !wsengine.started {
+		wsengine.stateNotifier.reset(EngineStateConnecting)
+		defer func() {
+			if !wsengine.started {
+				wsengine.stateNotifier.stop()
+			}
+		}()
+	}
+	// Apply feature flags changes which require a restart
+	wsengine.applyPendingFeatureFlags()
 	// Create span
 	ctx, span := wsengine.tracer.Start(ctx, spanEngineBackgroundStart,
 		trace.WithSpanKind(trace.SpanKindInternal),
@@ -351,6 +516,9 @@ func (wsengine *WebsocketEngine) startEngine(
 			attribute.Bool(attrRestart, restart),
 		))
 	defer span.End()
+	// Provide the ID of the session, the engine tracer and logger to callbacks through the context
+	span.SetAttributes(attribute.String(attrSessionId, sessionId))
+	ctx = wsengine.callbackContext(ctx, sessionId)
 	// Check provided context is not canceled
 	select {
 	case <-ctx.Done():
@@ -361,8 +529,8 @@ func (wsengine *WebsocketEngine) startEngine(
 	default:
 		// Check if engine is not started or is restarting
 		if !wsengine.started || restart {
-			// Open websocket connection to the target server
-			resp, err := wsengine.conn.Dial(ctx, *wsengine.target)
+			// Open websocket connection to the previous server if enabled or to the target
+			resp, err := wsengine.dial(ctx, span, restart)
 			// Check channel done to detect timeout
 			select {
 			case <-ctx.Done():
@@ -381,6 +549,7 @@ func (wsengine *WebsocketEngine) startEngine(
 			default:
 				if err != nil {
 					// Trace, channel error and exit
+					err = DialError{Cause: err, Attempt: attempt}
 					startupChannel <- handleError(EngineStartError{Err: err}, span, codes.Error, codes.Error.String())
 					return
 				}
@@ -391,6 +560,7 @@ func (wsengine *WebsocketEngine) startEngine(
 					wsengine.conn,
 					wsengine.readMutex,
 					exit,
+					sessionId,
 					restart)
 				if err != nil {
 					// Close websocket connection withtout calling callbacks
@@ -406,12 +576,22 @@ func (wsengine *WebsocketEngine) startEngine(
 					startupChannel <- handleError(EngineStartError{Err: err}, span, codes.Error, codes.Error.String())
 					return
 				} else {
-					// Startup finished - Create a session context from the engine context
+					// Startup finished - Forget failures related to the previous session
+					wsengine.recordCloseError(wsadapters.WebsocketCloseError{})
+					// Store the server address for server affinity
+					wsengine.recordAffinityAddr()
+					// Persist engine state - failure does not prevent the engine from starting.
+					// State is saved before goroutines start so shutdown gets the ID of this session
+					err = wsengine.saveState(sessionId, restart)
+					if err != nil {
+						span.RecordError(err)
+					}
+					// Create a session context from the engine context
 					sessionCtx, sessionCancelFunc := context.WithCancel(wsengine.engineCtx)
 					// Create a monitor all goroutines will share to ensure shutdown is called once
 					wsengine.shutdownSync = &sync.Once{}
-					// Create a UUID which will identify the session
-					sessionUuid := uuid.New()
+					// Track the OnMessage callbacks of this session for graceful drain
+					wsengine.inFlightMessages = &sync.WaitGroup{}
 					// Start the first goroutine which will run the engine.
 					// Used to prevent compiler warning -> cancelFunc not used on all paths
 					go wsengine.runEngine(
@@ -420,7 +600,8 @@ func (wsengine *WebsocketEngine) startEngine(
 						exit,
 						wsengine.conn,
 						wsengine.shutdownSync,
-						sessionUuid.String(),
+						wsengine.inFlightMessages,
+						sessionId,
 						uuid.New().String(),
 					)
 					// Start additional go routines that will run the engine
@@ -431,12 +612,32 @@ func (wsengine *WebsocketEngine) startEngine(
 							exit,
 							wsengine.conn,
 							wsengine.shutdownSync,
-							sessionUuid.String(),
+							wsengine.inFlightMessages,
+							sessionId,
 							uuid.New().String(),
 						)
 					}
+					// Start the goroutine which periodically pings the server if enabled
+					if wsengine.engineCfgOpts.PingInterval > 0 {
+						go wsengine.runPingLoop(sessionCtx, sessionCancelFunc, wsengine.shutdownSync, sessionId)
+					}
+					// Start the goroutine which detects silently dropped connections if enabled
+					if wsengine.engineCfgOpts.ReadIdleTimeout > 0 {
+						go wsengine.runReadIdleWatchdog(sessionCtx, sessionCancelFunc, wsengine.shutdownSync, sessionId)
+					}
 					// Set engine started flag, channel nil (success) and exit
 					wsengine.started = true
+					wsengine.stateNotifier.set(EngineStateConnected)
+					wsengine.logger.InfoContext(ctx, "websocket connection opened",
+						logKeySessionId, sessionId,
+						logKeyTarget, wsengine.target.String(),
+						logKeyRestart, restart)
+					if m := wsengine.engineCfgOpts.Metrics; m != nil {
+						m.SetConnectionUp(true)
+						if restart {
+							m.Reconnected()
+						}
+					}
 					span.SetStatus(codes.Ok, codes.Ok.String())
 					startupChannel <- nil
 				}
@@ -466,12 +667,15 @@ func (wsengine *WebsocketEngine) startEngine(
 //   - Error is not a WebsocketCloseError. Goroutine will call OnReadError and provide the error. When
 //     OnReadError completes, gouroutine will check the session context again: if session context has
 //     been canceled during OnReadError call (by user), goroutine will shutdown the engine, realease
-//     read mutex and exit. If session context has not been canceled during OnReadError call, goroutine
-//     will release read mutex and loop.
+//     read mutex and exit. If the error wraps ErrNotConnected (connection has been dropped by the
+//     adapter), the session is canceled after OnReadError so the engine restarts (or stops if exit
+//     has been called). Otherwise, goroutine will release read mutex and loop.
 //
 // Finally, in case conn.Read returns a message, no error has occured and session context has not
 // been canceled, goroutine will release read mutex and call OnMessage callback to process the
-// received message. Once OnMessage callback completes, goroutine will loop.
+// received message. Once OnMessage callback completes, goroutine will loop. If concurrent messages
+// are limited (see WithMaxConcurrentMessages), OnMessage is called in a dedicated goroutine and
+// goroutine loops as soon as the callback has been dispatched.
 //
 // # Inputs
 //
@@ -480,6 +684,7 @@ func (wsengine *WebsocketEngine) startEngine(
 //   - exit: Same effect as cancelSession plus engine definitely stop.
 //   - conn: Websocket connection adapter used to read messages and manage connection.
 //   - shutdownSync: Object used to ensure engine shutdown is performed exactly once.
+//   - inFlightMessages: Wait group used to track the OnMessage callbacks which are running.
 //   - sessionId: Id bound to the connection lifecycle. Used to correlate traces.
 //   - routineId: Unique ID bound to the goroutine lifetime.
 func (wsengine *WebsocketEngine) runEngine(
@@ -488,14 +693,17 @@ func (wsengine *WebsocketEngine) runEngine(
 	exit context.CancelFunc,
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	shutdownSync *sync.Once,
+	inFlightMessages *sync.WaitGroup,
 	sessionId string,
 	routineId string) {
+	// Fresh context which carries the session ID, the engine tracer and logger
+	callbackCtx := wsengine.callbackContext(context.Background(), sessionId)
 	// Run continuously until exit
 	for {
 		// Lock read mutex
 		wsengine.readMutex.Lock()
-		// Start span with fresh context
-		ctx, span := wsengine.tracer.Start(context.Background(), spanEngineBackgroundRun,
+		// Start span with fresh context which carries the session ID
+		ctx, span := wsengine.tracer.Start(callbackCtx, spanEngineBackgroundRun,
 			trace.WithSpanKind(trace.SpanKindInternal),
 			trace.WithAttributes(
 				attribute.String(attrSessionId, sessionId),
@@ -506,10 +714,10 @@ func (wsengine *WebsocketEngine) runEngine(
 		// Check session context first for cancellation signal
 		select {
 		case <-sessionCtx.Done():
-			// Session has been canceled. Call once shutdownEngine by safety and exit.
-			// Skip websocket connection close as it will be closed either during Stop()
-			// or after Read returns a close error
-			shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, true) })
+			// Session has been canceled (Stop, restart or exit). Call once shutdownEngine
+			// and exit. Connection is still open: it is closed with the close message
+			// returned by OnClose.
+			shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, false) })
 			// Unlock read mutex - All other engine goroutines will exit
 			wsengine.readMutex.Unlock()
 			// Add event about worker exit
@@ -520,15 +728,28 @@ func (wsengine *WebsocketEngine) runEngine(
 			// Exit
 			return
 		default:
-			// Read message
-			msgType, msg, err := wsengine.conn.Read(ctx)
+			// Read message - large messages are returned as a stream if the client supports it
+			msgType, msg, stream, err := wsengine.readMessage(ctx)
 			// Check cancellation signal first
 			select {
 			case <-sessionCtx.Done():
-				// Session has been canceled because of Stop() call
+				// Session has been canceled (Stop, restart or exit)
 				span.RecordError(sessionCtx.Err())
-				// Shutdown the engine - skip websocket connection close (Stop has handled it)
-				shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, true) })
+				closeErr := new(wsadapters.WebsocketCloseError)
+				if errors.As(err, closeErr) {
+					// Shutdown the engine - skip websocket connection close as the connection
+					// has already been closed (by the server or by a previous shutdown)
+					shutdownSync.Do(func() {
+						wsengine.shutdownEngine(ctx, &wsclient.CloseMessageDetails{
+							CloseReason:  closeErr.Code,
+							CloseMessage: closeErr.Reason,
+						}, true)
+					})
+				} else {
+					// Shutdown the engine - close the connection with the close message returned
+					// by OnClose
+					shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, false) })
+				}
 				// Unnlock mutex - All other engine goroutines will exit
 				wsengine.readMutex.Unlock()
 				// Add event about worker exit
@@ -551,6 +772,15 @@ func (wsengine *WebsocketEngine) runEngine(
 							attribute.String(attrCloseReason, closeErr.Reason),
 							attribute.Int(attrCloseCode, int(closeErr.Code)),
 						))
+						wsengine.logger.InfoContext(ctx, "websocket connection closed by server",
+							logKeySessionId, sessionId,
+							logKeyCloseCode, int(closeErr.Code),
+							logKeyReason, closeErr.Reason)
+						// Record close error for the retry after extractor
+						wsengine.recordCloseError(*closeErr)
+						if wsengine.engineCfgOpts.ReconnectPolicy != nil {
+							wsengine.engineCfgOpts.ReconnectPolicy.OnSessionClosed(*closeErr)
+						}
 						// Craft close message from close error data
 						closeMsg := &wsclient.CloseMessageDetails{
 							CloseReason:  closeErr.Code,
@@ -571,12 +801,18 @@ func (wsengine *WebsocketEngine) runEngine(
 						return
 					} else {
 						// An error occured - call OnReadError callback
-						wsengine.wsclient.OnReadError(ctx, conn, wsengine.readMutex, cancelSession, exit, err)
+						wsengine.logger.ErrorContext(ctx, "failed to read message", logKeySessionId, sessionId, logKeyError, err)
+						wsengine.wsclient.OnReadError(ctx, conn, wsengine.readMutex, cancelSession, exit, sessionId, err)
+						// Cancel the session if the connection has been dropped: reading again would fail
+						connLost := errors.Is(err, wsadapters.ErrNotConnected)
+						if connLost {
+							cancelSession()
+						}
 						// Check session cancellation signal to determine if shutdownEngine has to be called
 						select {
 						case <-sessionCtx.Done():
-							// Shutdown the engine - do not skip websocket connection close
-							shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, false) })
+							// Shutdown the engine - skip websocket connection close if connection is lost
+							shutdownSync.Do(func() { wsengine.shutdownEngine(ctx, nil, connLost) })
 							// Unlock mutex - All other engine goroutines will exit
 							wsengine.readMutex.Unlock()
 							// Add event about worker exit
@@ -591,12 +827,48 @@ func (wsengine *WebsocketEngine) runEngine(
 							wsengine.readMutex.Unlock()
 						}
 					}
+				} else if stream != nil {
+					// Track the message so shutdown can wait for its processing
+					inFlightMessages.Add(1)
+					// Record message reception so the next ping can be skipped
+					wsengine.lastMessageAt.Store(time.Now().UnixNano())
+					// Call OnMessageStream callback while holding the read mutex as the next
+					// read invalidates the stream. The callback takes a message slot if concurrent
+					// messages are limited. Discard the unread part of the message so the next
+					// message can be read and loop.
+					release := wsengine.acquireMessageSlot(sessionCtx)
+					wsengine.streamClient.OnMessageStream(ctx, wsengine.conn, wsengine.readMutex, cancelSession, wsengine.engineStopFunc, sessionId, msgType, stream)
+					release()
+					io.Copy(io.Discard, stream)
+					wsengine.readMutex.Unlock()
+					inFlightMessages.Done()
 				} else {
+					// Track the message so shutdown can wait for its processing. This is done
+					// before the mutex is released so a shutdown holding the mutex sees it.
+					inFlightMessages.Add(1)
 					// We have a message to process -> release mutex first to allow other routines
 					// to process new messages while goroutine process this one.
 					wsengine.readMutex.Unlock()
-					// Call OnMessage callback and loop
-					wsengine.wsclient.OnMessage(ctx, wsengine.conn, wsengine.readMutex, cancelSession, wsengine.engineStopFunc, sessionId, msgType, msg)
+					// Record message reception so the next ping can be skipped
+					wsengine.lastMessageAt.Store(time.Now().UnixNano())
+					// Hold the message if the engine is paused, otherwise call OnMessage callback
+					held := wsengine.holdMessage(sessionCtx, heldMessage{
+						ctx:              ctx,
+						sessionCtx:       sessionCtx,
+						cancelSession:    cancelSession,
+						inFlightMessages: inFlightMessages,
+						sessionId:        sessionId,
+						msgType:          msgType,
+						msg:              msg,
+					})
+					if held {
+						inFlightMessages.Done()
+					} else {
+						wsengine.dispatchMessage(sessionCtx, func() {
+							wsengine.wsclient.OnMessage(ctx, wsengine.conn, wsengine.readMutex, cancelSession, wsengine.engineStopFunc, sessionId, msgType, msg)
+							inFlightMessages.Done()
+						})
+					}
 				}
 			}
 		}
@@ -606,7 +878,9 @@ func (wsengine *WebsocketEngine) runEngine(
 // # Description
 //
 // Method called when engine has to restart or stop. Method will close the websocket connection
-// if requuired with the provided close message or a defalt one (1001 - Going away).
+// if requuired with the close message returned by OnClose or a defalt one (1001 - Going away).
+// If OnClose returns nil and SkipCloseFrameOnNilOnClose is enabled, the connection is dropped
+// without close message.
 //
 // Method MUST be called once when engine stops. It is up to the engine developper to ensure this.
 //
@@ -631,30 +905,52 @@ func (wsengine *WebsocketEngine) shutdownEngine(
 		))
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
+	// Get the ID of the session which ends
+	sessionId := wsengine.lastSessionID()
+	// Wait for in-flight OnMessage callbacks if graceful drain is enabled
+	wsengine.drainInFlightMessages(ctx, span, sessionId)
 	// Call OnClose callback
-	cmsg := wsengine.wsclient.OnClose(ctx, wsengine.conn, wsengine.readMutex, closeMessage)
+	cmsg := wsengine.wsclient.OnClose(ctx, wsengine.conn, wsengine.readMutex, sessionId, closeMessage)
 	// Skip close if instructed to
 	if !skipWebsocketClose {
-		if cmsg == nil {
-			cmsg = &wsclient.CloseMessageDetails{
-				CloseReason:  wsadapters.GoingAway,
-				CloseMessage: "Going away",
+		var err error
+		if cmsg == nil && wsengine.engineCfgOpts.SkipCloseFrameOnNilOnClose {
+			// Drop the connection without close message as instructed by OnClose
+			span.AddEvent(eventConnectionDropped)
+			wsengine.logger.InfoContext(ctx, "dropping websocket connection")
+			err = wsengine.conn.Drop(ctx)
+		} else {
+			// A nil close message still sends a close frame by default: clients which do not
+			// care about the close message return nil and dropping their connection without a
+			// closing handshake would be reported as an abnormal closure (1006) by the server.
+			if cmsg == nil {
+				cmsg = &wsclient.CloseMessageDetails{
+					CloseReason:  wsadapters.GoingAway,
+					CloseMessage: "Going away",
+				}
 			}
+			// Add an event to span with close message details
+			span.AddEvent(eventConnectionClosed, trace.WithAttributes(
+				attribute.String(attrCloseReason, cmsg.CloseMessage),
+				attribute.Int(attrCloseCode, int(cmsg.CloseReason)),
+			))
+			// Close websocket connection
+			wsengine.logger.InfoContext(ctx, "closing websocket connection",
+				logKeyCloseCode, int(cmsg.CloseReason),
+				logKeyReason, cmsg.CloseMessage)
+			err = wsengine.conn.Close(ctx, cmsg.CloseReason, cmsg.CloseMessage)
 		}
-		// Add an event to span with close message details
-		span.AddEvent(eventConnectionClosed, trace.WithAttributes(
-			attribute.String(attrCloseReason, cmsg.CloseMessage),
-			attribute.Int(attrCloseCode, int(cmsg.CloseReason)),
-		))
-		// Close websocket connection
-		err := wsengine.conn.Close(ctx, cmsg.CloseReason, cmsg.CloseMessage)
 		if err != nil {
+			wsengine.logger.ErrorContext(ctx, "failed to close websocket connection", logKeyError, err)
 			// Record close error
 			span.RecordError(err)
 			// Call OnWebsocketConnectionCloseError callback
-			wsengine.wsclient.OnCloseError(ctx, err)
+			wsengine.wsclient.OnCloseError(ctx, sessionId, CloseError{Cause: err})
 		}
 	}
+	if m := wsengine.engineCfgOpts.Metrics; m != nil {
+		m.SetConnectionUp(false)
+	}
 	// Check if engine must reconnect
 	if wsengine.engineCfgOpts.AutoReconnect {
 		// Check engine context to know if engine can restart
@@ -663,6 +959,8 @@ func (wsengine *WebsocketEngine) shutdownEngine(
 			// Send signal on stopped channel -> the engine has finished stopping
 			span.RecordError(wsengine.engineCtx.Err())
 			span.AddEvent(eventEngineExit)
+			wsengine.stateNotifier.stop()
+			wsengine.logger.InfoContext(ctx, "engine stopped")
 			wsengine.stoppedChannel <- true
 		default:
 			// Create a separate goroutine which will restart the engine. This goroutine will exit
@@ -672,6 +970,8 @@ func (wsengine *WebsocketEngine) shutdownEngine(
 	} else {
 		// Send signal on stopped channel -> the engine has finished stopping
 		span.AddEvent(eventEngineExit)
+		wsengine.stateNotifier.stop()
+		wsengine.logger.InfoContext(ctx, "engine stopped")
 		wsengine.stoppedChannel <- true
 	}
 }
@@ -690,20 +990,44 @@ func (wsengine *WebsocketEngine) restartEngine(
 		trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 	defer span.SetStatus(codes.Ok, codes.Ok.String())
+	wsengine.stateNotifier.set(EngineStateReconnecting)
 	// Continuously try to restart until engine restarts or engine context is canceled
 	retryCount := 0
+	// Retry delay provided by the server - Overrides the exponential retry delay if set
+	var retryAfter *time.Duration
+	// ID of the last failed restart attempt - ID of the session which has ended until then
+	sessionId := wsengine.lastSessionID()
 	for {
 		// Check cancellation signal
 		select {
 		case <-wsengine.engineCtx.Done():
 			// Send signal on stopped channel as the engine will definitly stop
+			wsengine.stateNotifier.stop()
+			wsengine.logger.InfoContext(ctx, "engine stopped")
 			stoppedChannel <- true
 			// Record error and exit
 			span.RecordError(wsengine.engineCtx.Err())
 			span.AddEvent(eventEngineExit)
 			return
 		default:
-			if retryCount > 0 {
+			// Compute the delay provided by the reconnect backoff function if any
+			var backoff *time.Duration
+			if wsengine.engineCfgOpts.ReconnectBackoff != nil {
+				delay := wsengine.engineCfgOpts.ReconnectBackoff(retryCount)
+				if delay == StopReconnecting {
+					// Stop reconnecting
+					wsengine.abortRestart(ctx, span, stoppedChannel, exit, sessionId, ErrReconnectAborted, retryCount)
+					return
+				}
+				backoff = &delay
+			}
+			if retryAfter != nil {
+				// Use the retry delay provided by the server
+				time.Sleep(*retryAfter)
+			} else if backoff != nil {
+				// Use the delay provided by the reconnect backoff function
+				time.Sleep(*backoff)
+			} else if retryCount > 0 {
 				// Exponential retry delay
 				delay := int(math.Ceil(math.Pow(
 					float64(wsengine.engineCfgOpts.AutoReconnectRetryDelayBaseSeconds),
@@ -712,6 +1036,14 @@ func (wsengine *WebsocketEngine) restartEngine(
 						float64(wsengine.engineCfgOpts.AutoReconnectRetryDelayMaxExponent)))))
 				time.Sleep(time.Duration(delay) * time.Second)
 			}
+			wsengine.logger.WarnContext(ctx, "reconnecting to websocket server",
+				logKeyTarget, wsengine.target.String(),
+				logKeyRetryCount, retryCount)
+			// Wait until the reconnect policy allows the engine to reconnect
+			if !wsengine.waitReconnectPolicy(span) {
+				// Engine has been stopped while waiting
+				continue
+			}
 			// If enabled, create a subcontext with timeout for start operation
 			timeoutCtx := ctx
 			cancel := func() {}
@@ -722,8 +1054,9 @@ func (wsengine *WebsocketEngine) restartEngine(
 			}
 			// Create internal channel to wait for the engine start completion signal
 			startupChannel := make(chan error, 1)
-			// Start a goroutine that will kick off the websocket engine.
-			go wsengine.startEngine(timeoutCtx, true, startupChannel, exit)
+			// Start a goroutine that will kick off the websocket engine with a new session ID
+			sessionId = wsengine.generateSessionID(ctx)
+			go wsengine.startEngine(timeoutCtx, true, retryCount+1, sessionId, startupChannel, exit)
 			// Read from error channel or context done channel to know when the engine has finished
 			// starting or if a timeout has occured or if engine context has been canceled.
 			var err error
@@ -740,10 +1073,18 @@ func (wsengine *WebsocketEngine) restartEngine(
 			if err != nil {
 				// An error occured while engine was restarting - Record error
 				span.RecordError(err)
+				wsengine.logger.WarnContext(ctx, "reconnect attempt failed", logKeyRetryCount, retryCount, logKeyError, err)
 				// Call OnRestartError
-				wsengine.wsclient.OnRestartError(ctx, wsengine.engineStopFunc, err, retryCount)
+				wsengine.wsclient.OnRestartError(ctx, wsengine.engineStopFunc, sessionId, RestartError{Cause: err, RetryCount: retryCount}, retryCount)
+				// Extract the retry delay provided by the server if any
+				retryAfter = wsengine.extractRetryAfter(span)
 				// Let loop
 				retryCount = retryCount + 1
+				// Stop reconnecting if the maximum number of reconnect attempts is reached
+				if maxAttempts := wsengine.engineCfgOpts.MaxReconnectAttempts; maxAttempts > 0 && retryCount >= maxAttempts {
+					wsengine.abortRestart(ctx, span, stoppedChannel, exit, sessionId, ErrMaxReconnectAttemptsExceeded, retryCount)
+					return
+				}
 			} else {
 				// Engine has started - Exit
 				return
@@ -751,3 +1092,320 @@ func (wsengine *WebsocketEngine) restartEngine(
 		}
 	}
 }
+
+// # Description
+//
+// Stop reconnecting: the provided error is recorded and provided to OnCloseError, the engine
+// context is canceled with the exit function and the engine signals it has definitly stopped.
+func (wsengine *WebsocketEngine) abortRestart(
+	ctx context.Context,
+	span trace.Span,
+	stoppedChannel chan bool,
+	exit context.CancelFunc,
+	sessionId string,
+	err error,
+	retryCount int,
+) {
+	span.RecordError(err)
+	wsengine.logger.ErrorContext(ctx, "engine stopped reconnecting", logKeyRetryCount, retryCount, logKeyError, err)
+	wsengine.wsclient.OnCloseError(ctx, sessionId, err)
+	exit()
+	wsengine.stateNotifier.stop()
+	stoppedChannel <- true
+	span.AddEvent(eventEngineExit, trace.WithAttributes(
+		attribute.Int(attrRetryCount, retryCount),
+	))
+}
+
+// # Description
+//
+// Load the engine state from the configured state persister if any. Restart count and
+// subscriptions are restored in the current engine state. Load failures are recorded in the
+// provided span and the engine starts with a fresh state.
+func (wsengine *WebsocketEngine) restoreState(span trace.Span) {
+	if wsengine.engineCfgOpts.StatePersister == nil {
+		return
+	}
+	state, err := wsengine.engineCfgOpts.StatePersister.Load()
+	if err != nil {
+		if !errors.Is(err, persistence.ErrNoState) {
+			span.RecordError(err)
+		}
+		return
+	}
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	wsengine.restoredState = &state
+	wsengine.state.LastSessionID = state.LastSessionID
+	wsengine.state.RestartCount = state.RestartCount
+	wsengine.state.Subscriptions = state.Subscriptions
+	span.AddEvent(eventStateRestored, trace.WithAttributes(
+		attribute.String(attrSessionId, state.LastSessionID),
+		attribute.Int(attrRetryCount, state.RestartCount),
+	))
+}
+
+// # Description
+//
+// Update the engine state with the new session and persist it with the configured state persister
+// if any.
+//
+// # Inputs
+//
+//   - sessionId: ID of the session which has just started.
+//   - restart: Indicates whether the engine has restarted. If true, restart count is incremented.
+//
+// # Return
+//
+// Nil in case of success or the error returned by the state persister.
+func (wsengine *WebsocketEngine) saveState(sessionId string, restart bool) error {
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	wsengine.state.TargetURL = wsengine.target.String()
+	wsengine.state.LastSessionID = sessionId
+	if restart {
+		wsengine.state.RestartCount = wsengine.state.RestartCount + 1
+	}
+	if wsengine.engineCfgOpts.StatePersister == nil {
+		return nil
+	}
+	return wsengine.engineCfgOpts.StatePersister.Save(wsengine.state)
+}
+
+// # Description
+//
+// Open the websocket connection. When the engine restarts and server affinity is enabled, the
+// engine first dials the remote address of the last successful connection and falls back to the
+// target URL if it fails. The target hostname is resolved before dial if FreshDNS is enabled.
+//
+// # Return
+//
+// The server response to websocket handshake or an error if any.
+func (wsengine *WebsocketEngine) dial(ctx context.Context, span trace.Span, restart bool) (*http.Response, error) {
+	affinity := wsengine.engineCfgOpts.ServerAffinity
+	if restart && affinity != nil && wsengine.affinityAddr != "" {
+		// Dial the previous server and keep the original host for Host header
+		target := *wsengine.target
+		target.Host = wsengine.affinityAddr
+		if target.Host != wsengine.target.Host {
+			wsengine.logger.InfoContext(ctx, "reconnecting to previous server instead of target",
+				logKeyAffinityAddr, target.Host,
+				logKeyTarget, wsengine.target.String())
+		}
+		resp, err := wsengine.dialAndRecord(wsadapters.ContextWithDialHost(ctx, wsengine.target.Host), target)
+		if err == nil {
+			wsengine.recordDialResponse(resp, err)
+			return resp, nil
+		}
+		// Fall back to the target URL
+		span.RecordError(err)
+		span.AddEvent(eventServerAffinityFailed, trace.WithAttributes(
+			attribute.String(attrAffinityAddr, target.Host),
+		))
+	}
+	// Resolve the target hostname if enabled and open websocket connection to the target
+	dialCtx, target, err := wsengine.resolveTarget(ctx, span)
+	if err != nil {
+		wsengine.dialHistory.add(DialAttempt{
+			AttemptedAt: time.Now(),
+			TargetURL:   *wsengine.target,
+			Error:       err.Error(),
+		})
+		return nil, err
+	}
+	resp, err := wsengine.dialAndRecord(dialCtx, target)
+	// Record the handshake response of a failed dial for the retry after extractor
+	wsengine.recordDialResponse(resp, err)
+	return resp, err
+}
+
+// # Description
+//
+// Open the websocket connection to the provided target with the connection adapter and record
+// the attempt in the dial history.
+func (wsengine *WebsocketEngine) dialAndRecord(ctx context.Context, target url.URL) (*http.Response, error) {
+	start := time.Now()
+	resp, err := wsengine.conn.Dial(ctx, target)
+	attempt := DialAttempt{
+		AttemptedAt: start,
+		TargetURL:   target,
+		Success:     err == nil,
+		Duration:    time.Since(start),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	wsengine.dialHistory.add(attempt)
+	return resp, err
+}
+
+// # Description
+//
+// Store the remote address of the current connection as server affinity hint if server affinity
+// is enabled and if the underlying websocket connection exposes its remote address.
+func (wsengine *WebsocketEngine) recordAffinityAddr() {
+	if wsengine.engineCfgOpts.ServerAffinity == nil {
+		return
+	}
+	wsengine.affinityAddr = ""
+	conn, ok := wsengine.conn.GetUnderlyingWebsocketConnection().(interface{ RemoteAddr() net.Addr })
+	if !ok {
+		return
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		wsengine.affinityAddr = addr.String()
+	}
+}
+
+// # Description
+//
+// Record the handshake response returned by Dial when it has failed. The response is forgotten
+// when Dial succeeds.
+func (wsengine *WebsocketEngine) recordDialResponse(resp *http.Response, err error) {
+	wsengine.lastFailureMutex.Lock()
+	defer wsengine.lastFailureMutex.Unlock()
+	if err != nil {
+		wsengine.lastDialResponse = resp
+	} else {
+		wsengine.lastDialResponse = nil
+	}
+}
+
+// # Description
+//
+// Record the close error received when the session ended.
+func (wsengine *WebsocketEngine) recordCloseError(closeErr wsadapters.WebsocketCloseError) {
+	wsengine.lastFailureMutex.Lock()
+	defer wsengine.lastFailureMutex.Unlock()
+	wsengine.lastCloseError = closeErr
+}
+
+// # Description
+//
+// Call the configured ServerRetryAfterExtractor, if any, with the last failed handshake response
+// and the last close error.
+//
+// # Return
+//
+// The retry delay provided by the server or nil if the exponential retry delay must be used.
+func (wsengine *WebsocketEngine) extractRetryAfter(span trace.Span) *time.Duration {
+	if wsengine.engineCfgOpts.ServerRetryAfterExtractor == nil {
+		return nil
+	}
+	wsengine.lastFailureMutex.Lock()
+	resp, closeErr := wsengine.lastDialResponse, wsengine.lastCloseError
+	wsengine.lastFailureMutex.Unlock()
+	delay, ok := wsengine.engineCfgOpts.ServerRetryAfterExtractor(resp, closeErr)
+	if !ok {
+		return nil
+	}
+	span.AddEvent(eventServerRetryAfter, trace.WithAttributes(
+		attribute.Int64(attrRetryAfterMs, delay.Milliseconds()),
+	))
+	return &delay
+}
+
+// # Description
+//
+// Generate a new session ID with the configured SessionIDGenerator or a random UUID if none is
+// configured.
+func (wsengine *WebsocketEngine) generateSessionID(ctx context.Context) string {
+	if wsengine.engineCfgOpts.SessionIDGenerator == nil {
+		return newRandomSessionID()
+	}
+	return wsengine.engineCfgOpts.SessionIDGenerator.Generate(ctx, *wsengine.target)
+}
+
+// Return the ID of the last session which has started.
+func (wsengine *WebsocketEngine) lastSessionID() string {
+	wsengine.stateMutex.Lock()
+	defer wsengine.stateMutex.Unlock()
+	return wsengine.state.LastSessionID
+}
+
+// # Description
+//
+// Return a copy of the provided context which holds the session ID (see SessionIDFromContext),
+// the engine tracer and the engine logger (see wscontext.LazyContext). The context is built once
+// per session and engine goroutine so callbacks do not pay for it on each message.
+func (wsengine *WebsocketEngine) callbackContext(ctx context.Context, sessionId string) context.Context {
+	ctx = middleware.ContextWithSessionId(ctx, sessionId)
+	ctx = wscontext.WithTracer(ctx, wsengine.tracer)
+	return wscontext.WithLogger(ctx, wsengine.logger)
+}
+
+// # Description
+//
+// Wait until the configured ReconnectPolicy, if any, allows the engine to reconnect.
+//
+// # Return
+//
+// False if the engine context has been canceled while waiting, true otherwise.
+func (wsengine *WebsocketEngine) waitReconnectPolicy(span trace.Span) bool {
+	policy := wsengine.engineCfgOpts.ReconnectPolicy
+	if policy == nil {
+		return true
+	}
+	for !policy.Allow() {
+		delay := policy.RetryAfter()
+		span.AddEvent(eventReconnectPolicyWait, trace.WithAttributes(
+			attribute.Int64(attrRetryAfterMs, delay.Milliseconds()),
+		))
+		timer := time.NewTimer(delay)
+		select {
+		case <-wsengine.engineCtx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+	return true
+}
+
+// # Description
+//
+// Build the context and target URL provided to the connection adapter Dial method. If FreshDNS is
+// enabled and the target host is not an IP address, the hostname is looked up and the returned
+// target uses the first resolved IP address. In this case, the original host is carried by the
+// returned context (see wsadapters.ContextWithDialHost) so it is used for the Host header.
+//
+// # Return
+//
+// The context and target URL to use for Dial or an error if the hostname cannot be resolved.
+func (wsengine *WebsocketEngine) resolveTarget(ctx context.Context, span trace.Span) (context.Context, url.URL, error) {
+	target := *wsengine.target
+	opts := wsengine.engineCfgOpts.FreshDNS
+	if opts == nil {
+		return ctx, target, nil
+	}
+	hostname := target.Hostname()
+	if hostname == "" || net.ParseIP(hostname) != nil {
+		return ctx, target, nil
+	}
+	// Use a fresh resolver for each lookup if none is provided
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = &net.Resolver{}
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return ctx, target, fmt.Errorf("failed to resolve target host %s: %w", hostname, err)
+	}
+	if len(addrs) == 0 {
+		return ctx, target, fmt.Errorf("failed to resolve target host %s: no address found", hostname)
+	}
+	ip := addrs[0].IP.String()
+	// Replace the hostname by the resolved IP and keep the port if any
+	if port := target.Port(); port != "" {
+		target.Host = net.JoinHostPort(ip, port)
+	} else if addrs[0].IP.To4() == nil {
+		target.Host = "[" + ip + "]"
+	} else {
+		target.Host = ip
+	}
+	span.AddEvent(eventTargetResolved, trace.WithAttributes(
+		attribute.String(attrTargetHost, hostname),
+		attribute.String(attrTargetIP, ip),
+	))
+	return wsadapters.ContextWithDialHost(ctx, wsengine.target.Host), target, nil
+}
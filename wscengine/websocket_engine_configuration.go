@@ -1,6 +1,15 @@
 package wscengine
 
 import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/metrics"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/persistence"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -36,6 +45,201 @@ type WebsocketEngineConfigurationOptions struct {
 	//
 	// Default to 300000 (5 minutes) - 0 disables the timeout.
 	StopTimeoutMs int64 `validate:"gte=0"`
+	// Optional persister used to save the engine state each time the engine (re)starts and to
+	// load the previously persisted state when the engine starts.
+	//
+	// Defaults to nil (= state is not persisted).
+	StatePersister persistence.StatePersister
+	// Optional settings used to resolve the target hostname before each dial so the engine
+	// connects to the current IP address of the server after a failover.
+	//
+	// Defaults to nil (= target URL is provided as is to the connection adapter).
+	FreshDNS *FreshDNSOption
+	// Optional function used to extract a server provided retry delay from the last failed
+	// handshake response or from the last close error. If the function returns a delay, the
+	// engine uses it as the next reconnect delay instead of the exponential retry delay.
+	//
+	// Defaults to nil (= exponential retry delay is always used).
+	ServerRetryAfterExtractor ServerRetryAfterExtractor
+	// Optional settings used to reconnect to the same server as the previous session first.
+	//
+	// Defaults to nil (= engine always reconnects to the target URL).
+	ServerAffinity *ServerAffinityOption
+	// Optional policy consulted before each reconnect attempt. The engine waits until the policy
+	// allows it to reconnect.
+	//
+	// Defaults to nil (= engine reconnects as soon as the retry delay has elapsed).
+	ReconnectPolicy ReconnectPolicy
+	// Optional generator used to create the ID of each session.
+	//
+	// Defaults to nil (= random UUIDs generated with crypto/rand, see UUIDSessionIDGenerator).
+	SessionIDGenerator SessionIDGenerator
+	// Optional function used to compute the delay to wait before each reconnect attempt. If set,
+	// the function replaces the exponential retry delay.
+	//
+	// Defaults to nil (= exponential retry delay is used).
+	ReconnectBackoff ReconnectBackoffFunc
+	// Maximum number of consecutive failed reconnect attempts. Once reached, the engine stops
+	// reconnecting: OnCloseError is called with ErrMaxReconnectAttemptsExceeded and the engine
+	// stops.
+	//
+	// Defaults to 0 (= unlimited). Must be at least 0.
+	MaxReconnectAttempts int `validate:"gte=0"`
+	// Middlewares applied, in order, to each received message before it is handed over to the
+	// user provided OnMessage callback.
+	//
+	// Defaults to nil (= messages are directly handed over to OnMessage).
+	MessageMiddlewares []middleware.MessageMiddleware
+	// Optional metrics the engine reports received and sent messages, reconnects, ping durations
+	// and connection state to.
+	//
+	// Defaults to nil (= no metrics are reported).
+	Metrics metrics.Metrics
+	// Optional structured logger used by the engine to log its activity: ping/pong cycles at
+	// DEBUG level, connection open/close at INFO level, reconnect attempts at WARN level and
+	// unexpected errors at ERROR level.
+	//
+	// Defaults to nil (= engine does not log).
+	Logger *slog.Logger
+	// Interval at which the engine pings the server to detect lost connections. The ping is
+	// skipped when a message has been received during the last interval. A failed ping is handled
+	// as a connection loss: the connection is closed and the engine restarts if AutoReconnect is
+	// enabled.
+	//
+	// Defaults to 0 (= engine does not ping the server). Must be at least 0.
+	PingInterval time.Duration `validate:"gte=0"`
+	// Delay to receive the pong response of a ping sent by the engine.
+	//
+	// Defaults to 0 (= PingInterval is used as timeout). Must be at least 0.
+	PingTimeout time.Duration `validate:"gte=0"`
+	// Maximum delay without receiving any message before the connection is considered dead. Data
+	// messages and the pongs of the pings sent by the engine (see PingInterval) are taken into
+	// account. When the delay elapses, OnReadError is called with ErrReadIdleTimeout and the
+	// engine restarts if AutoReconnect is enabled.
+	//
+	// Defaults to 0 (= silently dropped connections are not detected). Must be at least 0.
+	ReadIdleTimeout time.Duration `validate:"gte=0"`
+	// Maximum number of messages which can wait in the write queue. When enabled, Write calls on
+	// the connection provided to callbacks queue the message and return: a single goroutine
+	// writes the queued messages in order.
+	//
+	// Defaults to 0 (= write queue is disabled, Write blocks until the message is written). Must
+	// be at least 0.
+	WriteQueueDepth int `validate:"gte=0"`
+	// Maximum number of messages per second which can be written on the connection provided to
+	// callbacks. Write calls which would exceed the limit wait until the limit allows it.
+	//
+	// Defaults to 0 (= write rate is not limited). Must be at least 0.
+	WriteRateLimit float64 `validate:"gte=0"`
+	// Maximum number of messages which can be written at once when WriteRateLimit is enabled.
+	//
+	// Defaults to 0 (= 1 message). Must be at least 0.
+	WriteRateBurst int `validate:"gte=0"`
+	// Maximum delay the engine waits for the in-flight OnMessage callbacks to return before it
+	// calls OnClose and closes the connection when the session ends.
+	//
+	// Defaults to 0 (= engine does not wait for in-flight OnMessage callbacks). Must be at least
+	// 0.
+	DrainTimeout time.Duration `validate:"gte=0"`
+	// Size in bytes above which messages are streamed to the OnMessageStream callback when the
+	// websocket client implements wsclient.WebsocketClientStreamInterface. Smaller messages are
+	// read in memory and handed over to OnMessage. The option is ignored when the websocket
+	// client does not implement wsclient.WebsocketClientStreamInterface or when message
+	// middlewares are configured.
+	//
+	// Defaults to 1048576 (1 MiB) - 0 streams all messages. Must be at least 0.
+	StreamThreshold int `validate:"gte=0"`
+	// Maximum number of messages held while the engine is paused (see WebsocketEngine.Pause).
+	// When the limit is reached, the engine stops reading until it resumes.
+	//
+	// Defaults to 1000. Must be at least 0.
+	PauseBufferSize int `validate:"gte=0"`
+	// Maximum number of OnMessage callbacks which can run at once. When enabled, the engine
+	// goroutines call OnMessage in a dedicated goroutine and go on reading: when the limit is
+	// reached, the engine stops reading until a callback returns.
+	//
+	// Defaults to 0 (= each engine goroutine calls OnMessage and waits for it to return before
+	// reading the next message). Must be at least 0.
+	MaxConcurrentMessages int `validate:"gte=0"`
+	// If true, the engine drops the connection without sending a close message when OnClose
+	// returns nil. Otherwise, the engine closes the connection with 1001 (Going away).
+	//
+	// Defaults to false.
+	SkipCloseFrameOnNilOnClose bool
+}
+
+// Value returned by a ReconnectBackoffFunc to stop reconnecting.
+const StopReconnecting time.Duration = -1
+
+// Function which computes the delay to wait before a reconnect attempt.
+//
+// The function is called by the engine before each reconnect attempt with the number of failed
+// reconnect attempts so far (0 before the first attempt). The engine waits for the returned
+// delay before it dials the server again. If the function returns StopReconnecting (-1), the
+// engine stops reconnecting: OnCloseError is called with ErrReconnectAborted and the engine stops.
+//
+// A retry delay provided by the server (see ServerRetryAfterExtractor) overrides the returned
+// delay.
+type ReconnectBackoffFunc func(retryCount int) time.Duration
+
+// Policy which can delay reconnect attempts, for example when the server has closed the
+// connection because the client is rate limited.
+//
+// Implementations must be safe for concurrent use.
+type ReconnectPolicy interface {
+	// Called by the engine with the close error received when a session ends.
+	OnSessionClosed(closeErr wsadapters.WebsocketCloseError)
+	// Return true if the engine can reconnect now.
+	Allow() bool
+	// Return the delay to wait until Allow returns true. Zero if the engine can reconnect now.
+	RetryAfter() time.Duration
+}
+
+// Settings used to reconnect to the same server as the previous session.
+//
+// Once a connection is established, the engine stores the remote address of the connection. On
+// reconnect, the engine first dials the stored address and falls back to the target URL if it
+// fails. The original host is provided to the adapter through the context (see
+// wsadapters.ContextWithDialHost) so it is used for the Host header and TLS server name
+// verification.
+//
+// The remote address is retrieved from the underlying websocket connection returned by the
+// adapter GetUnderlyingWebsocketConnection method which must have a RemoteAddr() net.Addr method
+// (like gorilla/websocket connections). Affinity is disabled for other connections.
+//
+// This is useful for stateful servers which lose session state when the client connects to
+// another server.
+//
+// The engine logs when it reconnects to a server which is different from the target URL with the
+// logger set with WithLogger.
+type ServerAffinityOption struct{}
+
+// Function which extracts a retry delay provided by the server.
+//
+// The function is called by the engine after OnRestartError, before the next reconnect delay is
+// applied. It receives:
+//   - resp: Handshake response returned by the last failed dial (like a 503 response with a
+//     Retry-After header). Nil if the connection adapter did not return a response.
+//   - closeErr: Close error received when the last session ended. Zero value if the session has
+//     not been closed by a close message.
+//
+// The function returns the delay to wait before the next reconnect attempt and true, or false to
+// use the exponential retry delay.
+type ServerRetryAfterExtractor func(resp *http.Response, closeErr wsadapters.WebsocketCloseError) (time.Duration, bool)
+
+// Settings used to resolve the target hostname before each dial.
+//
+// Before each Dial, including reconnects, the engine looks up the target hostname and provides the
+// connection adapter with a target URL whose host is the first resolved IP address. The original
+// host is provided to the adapter through the context (see wsadapters.ContextWithDialHost) so it is
+// used for the Host header and TLS server name verification.
+//
+// This is useful when the server IP address changes during failover (Anycast, DNS based load
+// balancing) while the connection adapter or the system keeps using a stale address.
+type FreshDNSOption struct {
+	// Optional resolver used to look up the target hostname. If nil, a new net.Resolver with
+	// default settings is used for each lookup.
+	Resolver *net.Resolver
 }
 
 // # Description
@@ -167,6 +371,529 @@ func (opts *WebsocketEngineConfigurationOptions) WithStopTimeoutMs(
 	return opts
 }
 
+// # Description
+//
+// Set opts.StatePersister and return the modified object. The method does not validate inputs.
+//
+// # StatePersister
+//
+// This option defines the persister the engine uses to save its state (target URL, last session
+// ID, restart count and user provided subscriptions) each time it (re)starts. When the engine
+// starts, the previously persisted state is loaded before the first dial so it can be restored
+// after a crash.
+//
+// Defaults to nil (= state is not persisted).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithStatePersister(
+	value persistence.StatePersister) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.StatePersister = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.FreshDNS and return the modified object. The method does not validate inputs.
+//
+// # FreshDNS
+//
+// This option enables the resolution of the target hostname before each dial (including
+// reconnects) so the engine connects to the current IP address of the server. The original host is
+// preserved in the Host header. Targets whose host is already an IP address are not modified.
+//
+// Defaults to nil (= disabled).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithFreshDNS(
+	value *FreshDNSOption) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.FreshDNS = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.ServerRetryAfterExtractor and return the modified object. The method does not validate
+// inputs.
+//
+// # ServerRetryAfterExtractor
+//
+// This option defines a function which extracts a retry delay provided by the server (Retry-After
+// header of the last failed handshake, custom close reason, ...). When the function returns a
+// delay, the engine waits for this delay before the next reconnect attempt instead of the
+// exponential retry delay.
+//
+// Defaults to nil (= exponential retry delay is always used).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithServerRetryAfterExtractor(
+	value ServerRetryAfterExtractor) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.ServerRetryAfterExtractor = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.ServerAffinity and return the modified object. The method does not validate inputs.
+//
+// # ServerAffinity
+//
+// This option enables server affinity: on reconnect, the engine first tries to connect to the
+// remote address of the last successful connection and falls back to the target URL if it fails.
+//
+// Defaults to nil (= disabled).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithServerAffinity(
+	value *ServerAffinityOption) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.ServerAffinity = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.ReconnectPolicy and return the modified object. The method does not validate inputs.
+//
+// # ReconnectPolicy
+//
+// This option defines a policy consulted before each reconnect attempt (see
+// RateLimitAwareReconnectPolicy). While the policy does not allow the engine to reconnect, the
+// engine waits. The wait happens in addition to the retry delay.
+//
+// Defaults to nil (= engine reconnects as soon as the retry delay has elapsed).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithReconnectPolicy(
+	value ReconnectPolicy) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.ReconnectPolicy = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.SessionIDGenerator and return the modified object. The method does not validate inputs.
+//
+// # SessionIDGenerator
+//
+// This option defines the generator used to create the ID of each session, for example to
+// correlate sessions with external IDs (see TraceIDSessionIDGenerator and
+// SequenceSessionIDGenerator). Use SessionIDGeneratorFunc to provide a plain function.
+//
+// Defaults to nil (= random UUIDs generated with crypto/rand).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithSessionIDGenerator(
+	value SessionIDGenerator) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.SessionIDGenerator = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.ReconnectBackoff and return the modified object. The method does not validate inputs.
+//
+// # ReconnectBackoff
+//
+// This option defines the function used to compute the delay to wait before each reconnect
+// attempt, for example to use a backoff strategy with jitter. The function replaces the
+// exponential retry delay computed from AutoReconnectRetryDelayBaseSeconds and
+// AutoReconnectRetryDelayMaxExponent. The engine stops reconnecting when the function returns
+// StopReconnecting.
+//
+// Defaults to nil (= exponential retry delay is used).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithReconnectBackoff(
+	value ReconnectBackoffFunc) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.ReconnectBackoff = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.MaxReconnectAttempts and return the modified object. Method does not validate inputs.
+//
+// # MaxReconnectAttempts
+//
+// This option defines the maximum number of consecutive failed reconnect attempts. Once reached,
+// the engine stops reconnecting, calls OnCloseError with ErrMaxReconnectAttemptsExceeded and
+// cancels the engine context: the channel returned by the Done method of the engine is closed.
+//
+// Defaults to 0 (= unlimited). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithMaxReconnectAttempts(
+	value int) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.MaxReconnectAttempts = value
+	return opts
+}
+
+// # Description
+//
+// Append the provided middlewares to opts.MessageMiddlewares and return the modified object. The
+// method does not validate inputs.
+//
+// # MessageMiddlewares
+//
+// This option defines middlewares which process each received message before it is handed over
+// to the user provided OnMessage callback (logging, decryption, rate limiting, ...). Middlewares
+// are called in registration order: the first middleware is the outermost one and the last one
+// calls OnMessage when it calls next. A middleware can drop a message by not calling next. The
+// session ID is available to middlewares through middleware.SessionIdFromContext (or
+// SessionIDFromContext which reads the same context value).
+//
+// Middlewares process whole messages: when middlewares are configured, messages are not streamed
+// to a client which implements wsclient.WebsocketClientStreamInterface (see WithStreamThreshold)
+// and all messages are handed over to OnMessage.
+//
+// Defaults to nil (= messages are directly handed over to OnMessage).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithMessageMiddleware(
+	middlewares ...middleware.MessageMiddleware) *WebsocketEngineConfigurationOptions {
+	// Append and return
+	opts.MessageMiddlewares = append(opts.MessageMiddlewares, middlewares...)
+	return opts
+}
+
+// # Description
+//
+// Set opts.Metrics and return the modified object. The method does not validate inputs.
+//
+// # Metrics
+//
+// This option defines the metrics the engine reports to: each message read and written, each
+// successful reconnect, the duration of each successful ping and whether a connection is up.
+// Use metrics.NewPrometheusMetrics to expose the engine metrics to Prometheus.
+//
+// Defaults to nil (= no metrics are reported).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithMetrics(
+	value metrics.Metrics) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.Metrics = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.Logger and return the modified object. The method does not validate inputs.
+//
+// # Logger
+//
+// This option defines the structured logger the engine logs its activity to:
+//   - DEBUG: ping/pong cycles (pings sent with the connection provided to callbacks).
+//   - INFO: connection opened and closed, engine stopped.
+//   - WARN: reconnect attempts and failures.
+//   - ERROR: read errors, close errors, start failures and engine giving up reconnecting.
+//
+// Defaults to nil (= engine does not log).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithLogger(
+	value *slog.Logger) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.Logger = value
+	return opts
+}
+
+// # Description
+//
+// Set opts.PingInterval and opts.PingTimeout and return the modified object. The method does not
+// validate inputs.
+//
+// # PingInterval
+//
+// This option defines the interval at which the engine pings the server while a connection is
+// up. The ping is skipped when a message has been received during the last interval as the
+// connection is known to be alive. If the ping fails or times out, the engine handles it as a
+// connection loss: OnClose is called, the connection is closed and the engine restarts if
+// AutoReconnect is enabled.
+//
+// Defaults to 0 (= engine does not ping the server). Must be greater or equal to 0.
+//
+// # PingTimeout
+//
+// This option defines the maximum delay to receive the pong response of a ping.
+//
+// Defaults to 0 (= PingInterval is used as timeout). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithPingInterval(
+	interval time.Duration, timeout time.Duration) *WebsocketEngineConfigurationOptions {
+	// Set values and return
+	opts.PingInterval = interval
+	opts.PingTimeout = timeout
+	return opts
+}
+
+// # Description
+//
+// Set opts.ReadIdleTimeout and return the modified object. The method does not validate inputs.
+//
+// # ReadIdleTimeout
+//
+// Some middleboxes silently drop TCP connections without sending a close message: reads on such
+// connections block forever. This option defines the maximum delay without receiving any message
+// before the engine considers the connection as dead. Data messages are taken into account, as
+// well as the pongs of the pings sent by the engine: control frames are not visible to the engine
+// otherwise. This option should be combined with WithPingInterval and a ping interval shorter
+// than the read idle timeout so quiet but healthy connections are not considered dead.
+//
+// When the delay elapses, the engine calls OnReadError with ErrReadIdleTimeout, closes the
+// connection and restarts if AutoReconnect is enabled.
+//
+// Defaults to 0 (= silently dropped connections are not detected). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithSilentDeadlineDetection(
+	readIdleTimeout time.Duration) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.ReadIdleTimeout = readIdleTimeout
+	return opts
+}
+
+// # Description
+//
+// Set opts.WriteQueueDepth and return the modified object. The method does not validate inputs.
+//
+// # WriteQueueDepth
+//
+// This option enables the write queue which decouples the goroutines which write messages from
+// the network: Write calls on the connection provided to callbacks queue the message and return
+// instead of waiting for the connection. A single goroutine writes the queued messages in the
+// order they have been queued. A copy of the message is queued: callers can reuse their buffer
+// once Write returns.
+//
+// When the queue is full, Write waits for room until the provided context is done and then
+// returns an error which wraps ErrWriteQueueFull. Errors which occur when queued messages are
+// written are logged with the configured logger. Closing the connection waits until the queued
+// messages have been written.
+//
+// Defaults to 0 (= write queue is disabled). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithWriteQueue(
+	depth int) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.WriteQueueDepth = depth
+	return opts
+}
+
+// # Description
+//
+// Set opts.WriteRateLimit and opts.WriteRateBurst and return the modified object. The method does
+// not validate inputs.
+//
+// # WriteRateLimit
+//
+// This option limits the number of messages per second written on the connection provided to
+// callbacks. It can be used to comply with the rate limits enforced by servers which close the
+// connection of clients which send too many messages. Write calls which would exceed the limit
+// block until the limit allows it or until the provided context is done: Write then returns an
+// error which wraps ErrWriteRateLimited and the context error.
+//
+// When the write queue is enabled, the limit applies to the goroutine which writes the queued
+// messages: Write calls queue the message and return as usual.
+//
+// The number of messages which can currently be written without waiting can be observed with the
+// TokensRemaining method of the engine.
+//
+// Defaults to 0 (= write rate is not limited). Must be greater or equal to 0.
+//
+// # WriteRateBurst
+//
+// This option defines the maximum number of messages which can be written at once without
+// waiting.
+//
+// Defaults to 0 (= 1 message). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithWriteRateLimit(
+	rps float64, burst int) *WebsocketEngineConfigurationOptions {
+	// Set values and return
+	opts.WriteRateLimit = rps
+	opts.WriteRateBurst = burst
+	return opts
+}
+
+// # Description
+//
+// Set opts.DrainTimeout and return the modified object. The method does not validate inputs.
+//
+// # DrainTimeout
+//
+// This option enables the graceful drain of the session: when the session ends (exit, Stop,
+// restart or connection loss), the engine waits for the OnMessage callbacks which are still
+// running to return before it calls OnClose and closes the connection. The engine waits at most
+// DrainTimeout and then proceeds with the shutdown, even if some callbacks are still running.
+//
+// The engine may wait while it holds the read mutex: OnMessage callbacks which lock the read
+// mutex will not return before the drain timeout elapses.
+//
+// Defaults to 0 (= engine does not wait for in-flight OnMessage callbacks). Must be greater or
+// equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithDrainTimeout(
+	timeout time.Duration) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.DrainTimeout = timeout
+	return opts
+}
+
+// # Description
+//
+// Set opts.StreamThreshold and return the modified object. The method does not validate inputs.
+//
+// # StreamThreshold
+//
+// This option defines which messages are streamed when the websocket client implements
+// wsclient.WebsocketClientStreamInterface: the engine then reads messages with conn.ReadStream
+// and hands over the messages larger than the threshold to OnMessageStream without loading them
+// in memory. Messages up to the threshold are read in memory and handed over to OnMessage.
+//
+// The engine holds the read mutex until OnMessageStream returns and the message has been
+// consumed: streamed messages are processed one at a time.
+//
+// Messages are not streamed when message middlewares are configured (see WithMessageMiddleware):
+// middlewares process whole messages, so all messages are read in memory and handed over to
+// OnMessage through the middlewares.
+//
+// Defaults to 1048576 (1 MiB). 0 streams all messages. Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithStreamThreshold(
+	bytes int) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.StreamThreshold = bytes
+	return opts
+}
+
+// # Description
+//
+// Set opts.PauseBufferSize and return the modified object. The method does not validate inputs.
+//
+// # PauseBufferSize
+//
+// While the engine is paused (see WebsocketEngine.Pause), the engine goes on reading the
+// connection to process control frames but the received messages are held until the engine
+// resumes. This option defines the maximum number of held messages: when the limit is reached,
+// the engine stops reading until it resumes, which lets the TCP flow control slow down the server.
+// Control frames are not processed anymore in this case and pings may time out: the limit should
+// be large enough to cover the expected pause duration.
+//
+// Defaults to 1000. Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithPauseBuffer(
+	size int) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.PauseBufferSize = size
+	return opts
+}
+
+// # Description
+//
+// Set opts.MaxConcurrentMessages and return the modified object. The method does not validate
+// inputs.
+//
+// # MaxConcurrentMessages
+//
+// By default, each engine goroutine (see ReaderRoutinesCount) calls OnMessage and waits for it to
+// return before reading the next message: the number of messages processed at once is bounded by
+// the number of engine goroutines. When this option is enabled, the engine goroutines call
+// OnMessage in a dedicated goroutine and go on reading so slow callbacks do not delay the reading
+// of the next messages. At most maxConcurrentMessages callbacks run at once: when the limit is
+// reached, the engine stops reading until a callback returns, which lets the TCP flow control
+// slow down the server.
+//
+// The order in which messages are processed is not guaranteed when the option is enabled.
+//
+// Streamed messages (see WithStreamThreshold) also take a slot while OnMessageStream runs.
+//
+// Defaults to 0 (= OnMessage is called by the engine goroutines). Must be greater or equal to 0.
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithMaxConcurrentMessages(
+	maxConcurrentMessages int) *WebsocketEngineConfigurationOptions {
+	// Set value and return
+	opts.MaxConcurrentMessages = maxConcurrentMessages
+	return opts
+}
+
+// # Description
+//
+// Set opts.SkipCloseFrameOnNilOnClose and return the modified object.
+//
+// # SkipCloseFrameOnNilOnClose
+//
+// When the engine closes the connection (Stop, restart or exit), it sends the close message
+// returned by OnClose. By default, when OnClose returns nil, the engine closes the connection
+// with 1001 (Going away) so the server sees a clean closing handshake.
+//
+// When this option is enabled, a nil close message returned by OnClose means no close message
+// is sent: the engine drops the network connection (see conn.Drop) and the server sees an
+// abnormal closure (1006).
+//
+// Defaults to false (= a nil close message is replaced by 1001 - Going away).
+//
+// # Return
+//
+// The modified options.
+func (opts *WebsocketEngineConfigurationOptions) WithSkipCloseFrameOnNilOnClose(
+	value bool) *WebsocketEngineConfigurationOptions {
+	// Set and return
+	opts.SkipCloseFrameOnNilOnClose = value
+	return opts
+}
+
 // # Description
 //
 // Factory which creates a new WebsocketEngineConfigurationOptions object with nice defaults.
@@ -182,6 +909,29 @@ func (opts *WebsocketEngineConfigurationOptions) WithStopTimeoutMs(
 //     exponent to compute the delay (5s^0 = 1s as delay on first retry, 5s^1 = 5s as next delays).
 //   - OnOpenTimeoutMs = 300000 (5 minutes).
 //   - StopTimeoutMs = 300000 (5 minutes).
+//   - StatePersister = nil , engine state is not persisted.
+//   - FreshDNS = nil , target URL is provided as is to the connection adapter.
+//   - ServerRetryAfterExtractor = nil , exponential retry delay is always used.
+//   - ServerAffinity = nil , engine always reconnects to the target URL.
+//   - ReconnectPolicy = nil , engine reconnects as soon as the retry delay has elapsed.
+//   - SessionIDGenerator = nil , session IDs are random UUIDs generated with crypto/rand.
+//   - ReconnectBackoff = nil , exponential retry delay is used.
+//   - MaxReconnectAttempts = 0 , engine reconnects until it is stopped.
+//   - MessageMiddlewares = nil , messages are directly handed over to OnMessage.
+//   - Metrics = nil , no metrics are reported.
+//   - Logger = nil , engine does not log.
+//   - PingInterval = 0 , engine does not ping the server.
+//   - PingTimeout = 0 , PingInterval is used as ping timeout.
+//   - ReadIdleTimeout = 0 , silently dropped connections are not detected.
+//   - WriteQueueDepth = 0 , write queue is disabled.
+//   - WriteRateLimit = 0 , write rate is not limited.
+//   - WriteRateBurst = 0 , 1 message can be written at once when write rate is limited.
+//   - DrainTimeout = 0 , engine does not wait for in-flight OnMessage callbacks.
+//   - StreamThreshold = 1048576 , messages larger than 1 MiB are streamed if the client
+//     implements wsclient.WebsocketClientStreamInterface.
+//   - PauseBufferSize = 1000 , up to 1000 messages are held while the engine is paused.
+//   - MaxConcurrentMessages = 0 , OnMessage is called by the engine goroutines.
+//   - SkipCloseFrameOnNilOnClose = false , a nil OnClose result closes the connection with 1001.
 func NewWebsocketEngineConfigurationOptions() *WebsocketEngineConfigurationOptions {
 	return &WebsocketEngineConfigurationOptions{
 		ReaderRoutinesCount:                4,
@@ -190,6 +940,8 @@ func NewWebsocketEngineConfigurationOptions() *WebsocketEngineConfigurationOptio
 		AutoReconnectRetryDelayMaxExponent: 1,
 		OnOpenTimeoutMs:                    300000,
 		StopTimeoutMs:                      300000,
+		StreamThreshold:                    1048576,
+		PauseBufferSize:                    1000,
 	}
 }
 
@@ -202,6 +954,17 @@ func NewWebsocketEngineConfigurationOptions() *WebsocketEngineConfigurationOptio
 //   - opts.AutoReconnectRetryDelayMaxExponent is greater or equal to 1
 //   - opts.OnOpenTimeoutMs is greater or equal to 0
 //   - opts.StopTimeoutMs is greater or equal to 0
+//   - opts.MaxReconnectAttempts is greater or equal to 0
+//   - opts.PingInterval is greater or equal to 0
+//   - opts.PingTimeout is greater or equal to 0
+//   - opts.ReadIdleTimeout is greater or equal to 0
+//   - opts.WriteQueueDepth is greater or equal to 0
+//   - opts.WriteRateLimit is greater or equal to 0
+//   - opts.WriteRateBurst is greater or equal to 0
+//   - opts.DrainTimeout is greater or equal to 0
+//   - opts.StreamThreshold is greater or equal to 0
+//   - opts.PauseBufferSize is greater or equal to 0
+//   - opts.MaxConcurrentMessages is greater or equal to 0
 //
 // # Returns
 //
@@ -2,6 +2,7 @@ package wscengine
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -78,4 +79,48 @@ func (suite *WebsocketEngineOptionsUnitTestSuite) TestValidate() {
 	err = Validate(NewWebsocketEngineConfigurationOptions().
 		WithStopTimeoutMs(-1))
 	require.Error(suite.T(), err)
+	// Test invalid MaxReconnectAttempts
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithMaxReconnectAttempts(-1))
+	require.Error(suite.T(), err)
+	// Test invalid PingInterval
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithPingInterval(-time.Second, 0))
+	require.Error(suite.T(), err)
+	// Test invalid PingTimeout
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithPingInterval(time.Second, -time.Second))
+	require.Error(suite.T(), err)
+	// Test invalid ReadIdleTimeout
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithSilentDeadlineDetection(-time.Second))
+	require.Error(suite.T(), err)
+	// Test invalid WriteQueueDepth
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithWriteQueue(-1))
+	require.Error(suite.T(), err)
+	// Test invalid WriteRateLimit
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithWriteRateLimit(-1, 1))
+	require.Error(suite.T(), err)
+	// Test invalid WriteRateBurst
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithWriteRateLimit(1, -1))
+	require.Error(suite.T(), err)
+	// Test invalid DrainTimeout
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithDrainTimeout(-time.Second))
+	require.Error(suite.T(), err)
+	// Test invalid StreamThreshold
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithStreamThreshold(-1))
+	require.Error(suite.T(), err)
+	// Test invalid PauseBufferSize
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithPauseBuffer(-1))
+	require.Error(suite.T(), err)
+	// Test invalid MaxConcurrentMessages
+	err = Validate(NewWebsocketEngineConfigurationOptions().
+		WithMaxConcurrentMessages(-1))
+	require.Error(suite.T(), err)
 }
@@ -1,24 +1,41 @@
 package wscengine
 
 import (
+	"compress/flate"
 	"context"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gbdevw/gowse/echowsserver"
+	"github.com/gbdevw/gowse/wscengine/metrics"
+	"github.com/gbdevw/gowse/wscengine/middleware"
+	"github.com/gbdevw/gowse/wscengine/persistence"
 	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	wsadaptermock "github.com/gbdevw/gowse/wscengine/wsadapters/mock"
 	wsadapternhooyr "github.com/gbdevw/gowse/wscengine/wsadapters/nhooyr"
 	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gbdevw/gowse/wscengine/wstest"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
 )
 
 /*************************************************************************************************/
@@ -114,6 +131,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestOnReadErrorHandlingAndThenShutdow
 			mock.Anything,
 			mock.Anything,
 			mock.Anything,
+			mock.Anything,
 			(*wsclient.CloseMessageDetails)(nil)).Return(&closeMsg).
 		On("OnReadError",
 			mock.Anything,
@@ -121,6 +139,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestOnReadErrorHandlingAndThenShutdow
 			mock.Anything,
 			mock.Anything,
 			mock.Anything,
+			mock.Anything,
 			connReadErr).Return().Once().
 		On("OnReadError",
 			mock.Anything,
@@ -128,6 +147,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestOnReadErrorHandlingAndThenShutdow
 			mock.Anything,
 			mock.Anything,
 			mock.Anything,
+			mock.Anything,
 			connReadErr).Return().Run(func(args mock.Arguments) {
 		cancel()
 	})
@@ -147,6 +167,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestOnReadErrorHandlingAndThenShutdow
 		cancel,
 		connMock,
 		&sync.Once{},
+		&sync.WaitGroup{},
 		uuid.New().String(),
 		uuid.New().String(),
 	)
@@ -175,15 +196,15 @@ func (suite *WebsocketEngineUnitTestSuite) TestShutdownAfterReadMutexUnlock() {
 	// Create & configure connection mock
 	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
 	connMock.
-		On("Close", mock.Anything, closeMsg.CloseReason, mock.Anything).Return(nil)
-	// Configure OnReadError mock which does nothing on first call and
-	// call the provided engine exit function on second call
+		On("Close", mock.Anything, closeMsg.CloseReason, closeMsg.CloseMessage).Return(nil)
+	// Configure OnClose mock which returns the close message to send to the server
 	mockWsClient := wsclient.NewWebsocketClientMock()
 	mockWsClient.
 		On("OnClose",
 			mock.Anything,
 			mock.Anything,
 			mock.Anything,
+			mock.Anything,
 			(*wsclient.CloseMessageDetails)(nil)).Return(&closeMsg)
 	// Create engine with mocks
 	srvUrl, err := url.Parse("ws://localhost")
@@ -202,6 +223,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestShutdownAfterReadMutexUnlock() {
 		cancel,
 		connMock,
 		&sync.Once{},
+		&sync.WaitGroup{},
 		uuid.New().String(),
 		uuid.New().String(),
 	)
@@ -211,8 +233,8 @@ func (suite *WebsocketEngineUnitTestSuite) TestShutdownAfterReadMutexUnlock() {
 	engine.GetReadMutex().Unlock()
 	// Read on stop channel to know when goroutine has finished
 	<-engine.stoppedChannel
-	// Check on mocks
-	connMock.AssertNumberOfCalls(suite.T(), "Close", 0)
+	// Check on mocks - connection is closed with the close message returned by OnClose
+	connMock.AssertNumberOfCalls(suite.T(), "Close", 1)
 	mockWsClient.AssertNumberOfCalls(suite.T(), "OnClose", 1)
 	mockWsClient.AssertNumberOfCalls(suite.T(), "OnCloseError", 0)
 }
@@ -292,7 +314,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestStartWithOnOpenError() {
 		On("Dial", mock.Anything, mock.Anything).Return((*http.Response)(nil), nil).
 		On("Close", mock.Anything, wsadapters.GoingAway, mock.Anything).Return(nil)
 	// Configure client mock OnOpen to return an error when called
-	clientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).
+	clientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).
 		Return(expectedErr)
 	// Create engine
 	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, nil, nil)
@@ -328,7 +350,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestStartEngineWithCanceledCtx() {
 	// Create startupChannel
 	startupChannel := make(chan error, 1)
 	// Call startEngine
-	engine.startEngine(ctx, false, startupChannel, func() {})
+	engine.startEngine(ctx, false, 1, "s1", startupChannel, func() {})
 	// Read error from channel
 	select {
 	case err := <-startupChannel:
@@ -360,7 +382,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestStartEngineWithEngineAlreadyStart
 	// Create startupChannel
 	startupChannel := make(chan error, 1)
 	// Call startEngine
-	engine.startEngine(context.Background(), false, startupChannel, func() {})
+	engine.startEngine(context.Background(), false, 1, "s1", startupChannel, func() {})
 	// Read error from channel
 	select {
 	case err := <-startupChannel:
@@ -410,7 +432,7 @@ func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineErrorPath() {
 	clientMock := wsclient.NewWebsocketClientMock()
 	// Configure conn.Dial to fail to fail engine restart
 	dialErr := fmt.Errorf("error on dial call")
-	expectedErr := EngineStartError{Err: dialErr}
+	expectedErr := RestartError{Cause: EngineStartError{Err: DialError{Cause: dialErr, Attempt: 2}}, RetryCount: 1}
 	connMock.
 		// First call will timeout
 		On("Dial", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
@@ -424,9 +446,9 @@ func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineErrorPath() {
 	// Configure client OnRestartError to call cancel function
 	clientMock.
 		// First call does nothing
-		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, 0).
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 0).
 		// Second call interrupt retry loop with exit function
-		On("OnRestartError", mock.Anything, mock.Anything, expectedErr, 1).
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, expectedErr, 1).
 		Run(func(args mock.Arguments) {
 			cancel()
 		})
@@ -510,6 +532,463 @@ func (suite *WebsocketEngineUnitTestSuite) TestEngineFactoryInvalidParameters()
 	require.Nil(suite.T(), engine)
 }
 
+// # Description
+//
+// Test will ensure the engine loads the persisted state before the first dial and persists its
+// state once started.
+//
+// Test will succeed if:
+//   - Engine starts and the persisted state is available through GetRestoredState.
+//   - Persisted state is updated with the new session ID and keeps the restored restart count.
+//   - Subscriptions set with SetSubscriptions are persisted.
+func (suite *WebsocketEngineUnitTestSuite) TestStartWithStatePersister() {
+	// Create persister with a previous state
+	persister := persistence.NewFileStatePersister(filepath.Join(suite.T().TempDir(), "state.json"))
+	previous := persistence.EngineState{
+		TargetURL:     "ws://localhost",
+		LastSessionID: "previous",
+		RestartCount:  3,
+		Subscriptions: []byte("subscriptions"),
+	}
+	require.NoError(suite.T(), persister.Save(previous))
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - conn.Read reports the connection has been closed
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	connMock.
+		On("Dial", mock.Anything, mock.Anything).Return((*http.Response)(nil), nil).
+		On("Read", mock.Anything).Return(-1, []byte{}, wsadapters.WebsocketCloseError{Code: wsadapters.NormalClosure}).
+		On("Close", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	clientMock.
+		On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).Return(nil).
+		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	// Create engine and start it
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithAutoReconnect(false).
+		WithStatePersister(persister)
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), engine.Start(context.Background()))
+	// Check restored state
+	restored, ok := engine.GetRestoredState()
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), previous, restored)
+	// Check persisted state
+	current, err := persister.Load()
+	require.NoError(suite.T(), err)
+	require.NotEqual(suite.T(), previous.LastSessionID, current.LastSessionID)
+	require.Equal(suite.T(), previous.RestartCount, current.RestartCount)
+	require.Equal(suite.T(), previous.Subscriptions, current.Subscriptions)
+	// Persist new subscriptions
+	require.NoError(suite.T(), engine.SetSubscriptions([]byte("updated")))
+	current, err = persister.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("updated"), current.Subscriptions)
+	// Wait engine stops as connection is closed
+	select {
+	case <-engine.stoppedChannel:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("engine should have stopped")
+	}
+}
+
+// # Description
+//
+// Test will ensure restartEngine uses the retry delay provided by the server extractor instead of
+// the exponential retry delay.
+//
+// Test will succeed if:
+//   - Extractor is called with the last failed handshake response and the last close error.
+//   - Engine retries after the delay returned by the extractor (exponential delay would be 5s).
+func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineWithServerRetryAfter() {
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(context.Background())
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - Dial fails with a 503 response
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	dialErr := fmt.Errorf("error on dial call")
+	dialResp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": []string{"0"}}}
+	connMock.On("Dial", mock.Anything, mock.Anything).Return(dialResp, dialErr)
+	clientMock.
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 0).
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 1).
+		Run(func(args mock.Arguments) {
+			cancel()
+		})
+	// Create engine with an extractor which records its inputs
+	closeErr := wsadapters.WebsocketCloseError{Code: wsadapters.GoingAway, Reason: "retry-after=0"}
+	var extractedResp *http.Response
+	var extractedCloseErr wsadapters.WebsocketCloseError
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithServerRetryAfterExtractor(func(resp *http.Response, closeErr wsadapters.WebsocketCloseError) (time.Duration, bool) {
+			extractedResp, extractedCloseErr = resp, closeErr
+			return 10 * time.Millisecond, true
+		})
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	// Set started flag, ctx, exit function and close error of the previous session
+	engine.started = true
+	engine.engineCtx = ctx
+	engine.engineStopFunc = cancel
+	engine.recordCloseError(closeErr)
+	// Call restartEngine and check the exponential retry delay has not been used
+	start := time.Now()
+	engine.restartEngine(engine.engineCtx, engine.stoppedChannel, engine.engineStopFunc)
+	require.Less(suite.T(), time.Since(start), 2*time.Second)
+	// Verify engine has stopped and extractor inputs
+	select {
+	case <-engine.stoppedChannel:
+		connMock.AssertNumberOfCalls(suite.T(), "Dial", 2)
+		clientMock.AssertNumberOfCalls(suite.T(), "OnRestartError", 2)
+		require.Equal(suite.T(), dialResp, extractedResp)
+		require.Equal(suite.T(), closeErr, extractedCloseErr)
+	default:
+		suite.FailNow("something should have been read on stopped channel")
+	}
+}
+
+// # Description
+//
+// Test will ensure restartEngine waits for the delay returned by the reconnect backoff function
+// before each reconnect attempt.
+//
+// Test will succeed if:
+//   - Backoff function is called with the retry count before each reconnect attempt.
+//   - Engine retries after the delay returned by the backoff function (exponential delay would be
+//     5s).
+func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineWithReconnectBackoff() {
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(context.Background())
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - Dial always fails
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	connMock.On("Dial", mock.Anything, mock.Anything).Return((*http.Response)(nil), fmt.Errorf("error on dial call"))
+	clientMock.
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 0).
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 1).
+		Run(func(args mock.Arguments) {
+			cancel()
+		})
+	// Create engine with a backoff function which records the retry counts
+	retryCounts := []int{}
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReconnectBackoff(func(retryCount int) time.Duration {
+			retryCounts = append(retryCounts, retryCount)
+			return 10 * time.Millisecond
+		})
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	// Set started flag, ctx and exit function
+	engine.started = true
+	engine.engineCtx = ctx
+	engine.engineStopFunc = cancel
+	// Call restartEngine and check the exponential retry delay has not been used
+	start := time.Now()
+	engine.restartEngine(engine.engineCtx, engine.stoppedChannel, engine.engineStopFunc)
+	require.Less(suite.T(), time.Since(start), 2*time.Second)
+	// Verify engine has stopped and backoff function inputs
+	select {
+	case <-engine.stoppedChannel:
+		connMock.AssertNumberOfCalls(suite.T(), "Dial", 2)
+		clientMock.AssertNumberOfCalls(suite.T(), "OnRestartError", 2)
+		require.Equal(suite.T(), []int{0, 1}, retryCounts)
+	default:
+		suite.FailNow("something should have been read on stopped channel")
+	}
+}
+
+// # Description
+//
+// Test will ensure restartEngine stops reconnecting when the reconnect backoff function returns
+// StopReconnecting.
+//
+// Test will succeed if:
+//   - Engine stops reconnecting after the backoff function returns StopReconnecting.
+//   - OnCloseError is called with ErrReconnectAborted.
+//   - Engine context is canceled and the engine signals it has stopped.
+func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineStopReconnecting() {
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - Dial always fails
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	connMock.On("Dial", mock.Anything, mock.Anything).Return((*http.Response)(nil), fmt.Errorf("error on dial call"))
+	clientMock.
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, 0).
+		On("OnCloseError", mock.Anything, mock.Anything, ErrReconnectAborted)
+	// Create engine with a backoff function which stops after the first attempt
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReconnectBackoff(func(retryCount int) time.Duration {
+			if retryCount > 0 {
+				return StopReconnecting
+			}
+			return 0
+		})
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	// Set started flag, ctx and exit function
+	engine.started = true
+	engine.engineCtx = ctx
+	engine.engineStopFunc = cancel
+	// Call restartEngine
+	engine.restartEngine(engine.engineCtx, engine.stoppedChannel, engine.engineStopFunc)
+	// Verify engine has stopped
+	select {
+	case <-engine.stoppedChannel:
+		connMock.AssertNumberOfCalls(suite.T(), "Dial", 1)
+		clientMock.AssertNumberOfCalls(suite.T(), "OnRestartError", 1)
+		clientMock.AssertCalled(suite.T(), "OnCloseError", mock.Anything, mock.Anything, ErrReconnectAborted)
+		require.ErrorIs(suite.T(), engine.engineCtx.Err(), context.Canceled)
+	default:
+		suite.FailNow("something should have been read on stopped channel")
+	}
+}
+
+// # Description
+//
+// Test will ensure restartEngine stops reconnecting once the maximum number of reconnect attempts
+// is reached.
+//
+// Test will succeed if:
+//   - Engine dials the server MaxReconnectAttempts times.
+//   - OnCloseError is called with ErrMaxReconnectAttemptsExceeded.
+//   - Engine context is canceled, Done channel is closed and the engine signals it has stopped.
+func (suite *WebsocketEngineUnitTestSuite) TestRestartEngineMaxReconnectAttempts() {
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - Dial always fails
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	connMock.On("Dial", mock.Anything, mock.Anything).Return((*http.Response)(nil), fmt.Errorf("error on dial call"))
+	clientMock.
+		On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		On("OnCloseError", mock.Anything, mock.Anything, ErrMaxReconnectAttemptsExceeded)
+	// Create engine which gives up after 3 attempts - Use a short backoff to speed up the test
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithMaxReconnectAttempts(3).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond })
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	// Set started flag, ctx and exit function
+	engine.started = true
+	engine.engineCtx = ctx
+	engine.engineStopFunc = cancel
+	// Call restartEngine
+	engine.restartEngine(engine.engineCtx, engine.stoppedChannel, engine.engineStopFunc)
+	// Verify engine has stopped
+	select {
+	case <-engine.stoppedChannel:
+		connMock.AssertNumberOfCalls(suite.T(), "Dial", 3)
+		clientMock.AssertNumberOfCalls(suite.T(), "OnRestartError", 3)
+		clientMock.AssertCalled(suite.T(), "OnCloseError", mock.Anything, mock.Anything, ErrMaxReconnectAttemptsExceeded)
+		require.ErrorIs(suite.T(), engine.engineCtx.Err(), context.Canceled)
+	default:
+		suite.FailNow("something should have been read on stopped channel")
+	}
+	select {
+	case <-engine.Done():
+	default:
+		suite.FailNow("done channel should have been closed")
+	}
+}
+
+// # Description
+//
+// Test will ensure received messages are run through the configured message middlewares before
+// they are handed over to OnMessage.
+//
+// Test will succeed if:
+//   - Middlewares are called in registration order and can transform messages.
+//   - The session ID is available to middlewares.
+//   - A message dropped by a middleware does not reach OnMessage.
+func (suite *WebsocketEngineUnitTestSuite) TestMessageMiddlewares() {
+	// Start echo server
+	srv, _ := newCountingEchoServer()
+	defer srv.Close()
+	// Middleware which records the session ID and drops messages named "drop"
+	sessionIds := make(chan string, 10)
+	first := func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		sessionIds <- middleware.SessionIdFromContext(ctx)
+		if string(msg) == "drop" {
+			return
+		}
+		next(ctx, msgType, append(msg, []byte("-a")...))
+	}
+	second := func(ctx context.Context, msgType wsadapters.MessageType, msg []byte, next middleware.MessageHandler) {
+		next(ctx, msgType, append(msg, []byte("-b")...))
+	}
+	// Create and start engine
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithMessageMiddleware(first).
+		WithMessageMiddleware(second)
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil)
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Send a message which is dropped and a message which is transformed
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("drop")))
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("msg")))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	messages := client.RecordedOnMessages()
+	require.Len(suite.T(), messages, 1)
+	require.Equal(suite.T(), "msg-a-b", string(messages[0].Msg))
+	// Middlewares have received the session ID
+	require.Len(suite.T(), sessionIds, 2)
+	require.Equal(suite.T(), messages[0].SessionId, <-sessionIds)
+}
+
+// # Description
+//
+// Test will ensure the engine reports its activity to the configured metrics.
+//
+// Test will succeed if:
+//   - Received and sent messages and pings are counted.
+//   - Connection state is reported when the connection opens and closes.
+//   - Reconnects are counted.
+func (suite *WebsocketEngineUnitTestSuite) TestMetrics() {
+	// Create engine with metrics
+	registry := prometheus.NewRegistry()
+	engineMetrics, err := metrics.NewPrometheusMetrics(registry, nil)
+	require.NoError(suite.T(), err)
+	adapter := wsadaptermock.NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return time.Millisecond }).
+		WithMetrics(engineMetrics)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	require.Equal(suite.T(), 1.0, gatheredValue(suite.T(), registry, "wscengine_connection_up"))
+	// Receive a message, send a message and ping
+	adapter.EnqueueMessage(wsadapters.Text, []byte("msg"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 1, 5*time.Second))
+	conn := client.RecordedOnOpens()[0].Conn
+	require.NoError(suite.T(), conn.Write(ctx, wsadapters.Text, []byte("msg")))
+	require.NoError(suite.T(), conn.Ping(ctx))
+	require.Equal(suite.T(), 1.0, gatheredValue(suite.T(), registry, "wscengine_messages_received_total"))
+	require.Equal(suite.T(), 1.0, gatheredValue(suite.T(), registry, "wscengine_messages_sent_total"))
+	require.Equal(suite.T(), 1.0, gatheredValue(suite.T(), registry, "wscengine_ping_duration_seconds"))
+	// Server closes the connection: engine reconnects
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return gatheredValue(suite.T(), registry, "wscengine_reconnects_total") == 1.0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(suite.T(), 1.0, gatheredValue(suite.T(), registry, "wscengine_connection_up"))
+	// Engine stops: connection is down
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Equal(suite.T(), 0.0, gatheredValue(suite.T(), registry, "wscengine_connection_up"))
+}
+
+// # Description
+//
+// Test will ensure the engine resolves the target hostname before dial when FreshDNS is enabled.
+//
+// Test will succeed if:
+//   - Target is provided as is when FreshDNS is disabled.
+//   - Target host is replaced by a resolved IP address and the port is preserved.
+//   - Original host is carried by the dial context.
+func (suite *WebsocketEngineUnitTestSuite) TestResolveTargetWithFreshDNS() {
+	// Create valid URL
+	srvUrl, err := url.Parse("ws://localhost:8080/path")
+	require.NoError(suite.T(), err)
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	// Create engine without FreshDNS and check target is unchanged
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, nil, nil)
+	require.NoError(suite.T(), err)
+	ctx, target, err := engine.resolveTarget(context.Background(), trace.SpanFromContext(context.Background()))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), *srvUrl, target)
+	_, ok := wsadapters.DialHostFromContext(ctx)
+	require.False(suite.T(), ok)
+	// Create engine with FreshDNS and check target host has been resolved
+	opts := NewWebsocketEngineConfigurationOptions().WithFreshDNS(&FreshDNSOption{})
+	engine, err = NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, target, err = engine.resolveTarget(context.Background(), trace.SpanFromContext(context.Background()))
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), net.ParseIP(target.Hostname()))
+	require.Equal(suite.T(), "8080", target.Port())
+	require.Equal(suite.T(), "/path", target.Path)
+	host, ok := wsadapters.DialHostFromContext(ctx)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), "localhost:8080", host)
+}
+
+// # Description
+//
+// Test will ensure the engine reconnects to the server of the previous session first when server
+// affinity is enabled and falls back to the target URL if it fails.
+//
+// Test will succeed if:
+//   - Remote address of the underlying connection is stored as affinity hint.
+//   - Engine dials the affinity address with the original host carried by the dial context.
+//   - Engine dials the target URL when the affinity dial fails.
+func (suite *WebsocketEngineUnitTestSuite) TestDialWithServerAffinity() {
+	// Create valid URL
+	srvUrl, err := url.Parse("wss://example.com/path")
+	require.NoError(suite.T(), err)
+	// Create Conn & Client mocks - Dial to the previous server fails
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	clientMock := wsclient.NewWebsocketClientMock()
+	dialErr := fmt.Errorf("previous server is down")
+	connMock.
+		On("GetUnderlyingWebsocketConnection").Return(remoteAddrStub{addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}}).
+		On("Dial", mock.MatchedBy(func(ctx context.Context) bool {
+			host, ok := wsadapters.DialHostFromContext(ctx)
+			return ok && host == "example.com"
+		}), mock.MatchedBy(func(target url.URL) bool {
+			return target.Host == "10.0.0.1:443" && target.Path == "/path"
+		})).Return((*http.Response)(nil), dialErr).Once().
+		On("Dial", mock.Anything, *srvUrl).Return((*http.Response)(nil), nil).Once()
+	// Create engine with server affinity and record the remote address
+	logs := &lockedLogBuffer{}
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithServerAffinity(&ServerAffinityOption{}).
+		WithLogger(slog.New(slog.NewTextHandler(logs, nil)))
+	engine, err := NewWebsocketEngine(srvUrl, connMock, clientMock, opts, nil)
+	require.NoError(suite.T(), err)
+	engine.recordAffinityAddr()
+	require.Equal(suite.T(), "10.0.0.1:443", engine.affinityAddr)
+	// Affinity is not used on first start
+	span := trace.SpanFromContext(context.Background())
+	_, err = engine.dial(context.Background(), span, false)
+	require.NoError(suite.T(), err)
+	connMock.AssertNumberOfCalls(suite.T(), "Dial", 1)
+	// Affinity is used on restart and engine falls back to the target URL
+	connMock.On("Dial", mock.Anything, *srvUrl).Return((*http.Response)(nil), nil).Once()
+	_, err = engine.dial(context.Background(), span, true)
+	require.NoError(suite.T(), err)
+	connMock.AssertNumberOfCalls(suite.T(), "Dial", 3)
+	// Reconnection to the previous server is logged with the engine logger
+	require.Contains(suite.T(), logs.String(), "affinity_addr=10.0.0.1:443")
+}
+
 /*************************************************************************************************/
 /* INTEGRATION TESTS                                                                             */
 /*************************************************************************************************/
@@ -527,9 +1006,9 @@ func (suite *WebsocketEngineIntegrationTestSuite) TestOnOpenAndOnClose() {
 	// Create websocket client mock
 	wsClientMock := wsclient.NewWebsocketClientMock()
 	// Configure mock
-	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
-		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
-		On("OnCloseError", mock.Anything, mock.Anything).Return(nil)
+	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+		On("OnCloseError", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	// Create engine
 	conn := wsadapternhooyr.NewNhooyrWebsocketConnectionAdapter(nil)
 	engine, err := NewWebsocketEngine(suite.srvUrl, conn, wsClientMock, nil, nil)
@@ -585,10 +1064,10 @@ func (suite *WebsocketEngineIntegrationTestSuite) TestClientSession() {
 	// Create websocket client mock
 	wsClientMock := wsclient.NewWebsocketClientMock()
 	// Configure mock
-	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
-		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
 		On("OnMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-		On("OnCloseError", mock.Anything, mock.Anything)
+		On("OnCloseError", mock.Anything, mock.Anything, mock.Anything)
 	// Create engine
 	conn := wsadapternhooyr.NewNhooyrWebsocketConnectionAdapter(nil)
 	engine, err := NewWebsocketEngine(suite.srvUrl, conn, wsClientMock, nil, nil)
@@ -669,9 +1148,9 @@ func (suite *WebsocketEngineIntegrationTestSuite) TestEngineAutoReconnect() {
 	// Create websocket client mock
 	wsClientMock := wsclient.NewWebsocketClientMock()
 	// Configure mock
-	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
-		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
-		On("OnCloseError", mock.Anything, mock.Anything)
+	wsClientMock.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+		On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).
+		On("OnCloseError", mock.Anything, mock.Anything, mock.Anything)
 	// Create engine
 	conn := wsadapternhooyr.NewNhooyrWebsocketConnectionAdapter(nil)
 	engine, err := NewWebsocketEngine(suite.srvUrl, conn, wsClientMock, nil, nil)
@@ -718,13 +1197,141 @@ func (suite *WebsocketEngineIntegrationTestSuite) TestEngineAutoReconnect() {
 	require.False(suite.T(), engine.IsStarted())
 	// Check mock OnOpen and OnClose were called
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnOpen", 2)
-	wsClientMock.Calls[0].Arguments.Assert(suite.T(), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false)
-	wsClientMock.Calls[2].Arguments.Assert(suite.T(), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, true)
+	wsClientMock.Calls[0].Arguments.Assert(suite.T(), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false)
+	wsClientMock.Calls[2].Arguments.Assert(suite.T(), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, true)
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnClose", 2)
 	require.NotNil(suite.T(), wsClientMock.Calls[1].Arguments.Get(2)) // Must have a close message as server shutdown the connection (eiter with or without a close message)
-	require.Nil(suite.T(), wsClientMock.Calls[3].Arguments.Get(3))    // Calling stop = no closeMessage in OnClose callback
+	require.Nil(suite.T(), wsClientMock.Calls[3].Arguments.Get(4))    // Calling stop = no closeMessage in OnClose callback
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnReadError", 0)
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnCloseError", 0)
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnMessage", 0)
 	wsClientMock.AssertNumberOfCalls(suite.T(), "OnRestartError", 0)
 }
+
+// Test the handshake response returned by the adapter Dial method is provided intact to OnOpen,
+// with the response headers (cookies, negotiated extensions and custom headers), when the engine
+// starts and when it restarts.
+func (suite *WebsocketEngineIntegrationTestSuite) TestOnOpenReceivesHandshakeResponse() {
+	// Start a server which sets response headers and records the opened connections
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	conns := make(chan *websocket.Conn, 2)
+	count := atomic.Int64{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{}
+		header.Add("Set-Cookie", "session=abc; Path=/")
+		header.Set("X-Connection", strconv.FormatInt(count.Add(1), 10))
+		conn, err := upgrader.Upgrade(w, r, header)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conns <- conn
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	// Create and start the engine with decorators enabled
+	client := wstest.NewRecordingClient()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithWriteQueue(8).
+		WithReconnectBackoff(func(retryCount int) time.Duration { return 10 * time.Millisecond })
+	adapter := gorilla.NewGorillaWebsocketConnectionAdapter(nil, nil, gorilla.WithCompression(flate.BestSpeed))
+	engine, err := NewWebsocketEngine(toWebsocketURL(suite.T(), srv.URL), adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Drop the first connection so the engine restarts
+	(<-conns).UnderlyingConn().Close()
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2
+	}, 5*time.Second, time.Millisecond)
+	for index, call := range client.RecordedOnOpens() {
+		require.NotNil(suite.T(), call.Resp)
+		require.Equal(suite.T(), http.StatusSwitchingProtocols, call.Resp.StatusCode)
+		require.Equal(suite.T(), index == 1, call.Restarting)
+		require.Equal(suite.T(), strconv.Itoa(index+1), call.Resp.Header.Get("X-Connection"))
+		require.Contains(suite.T(), call.Resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+		cookies := call.Resp.Cookies()
+		require.Len(suite.T(), cookies, 1)
+		require.Equal(suite.T(), "session", cookies[0].Name)
+		require.Equal(suite.T(), "abc", cookies[0].Value)
+	}
+}
+
+/*************************************************************************************************/
+/* STUBS                                                                                         */
+/*************************************************************************************************/
+
+// Stub of an underlying websocket connection which exposes its remote address
+type remoteAddrStub struct {
+	addr net.Addr
+}
+
+func (stub remoteAddrStub) RemoteAddr() net.Addr {
+	return stub.addr
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Return the value of the counter or gauge with the provided name, or the sample count if the
+// metric is a histogram.
+func gatheredValue(t *testing.T, gatherer prometheus.Gatherer, name string) float64 {
+	families, err := gatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		require.Len(t, family.GetMetric(), 1)
+		metric := family.GetMetric()[0]
+		switch {
+		case metric.GetCounter() != nil:
+			return metric.GetCounter().GetValue()
+		case metric.GetGauge() != nil:
+			return metric.GetGauge().GetValue()
+		case metric.GetHistogram() != nil:
+			return float64(metric.GetHistogram().GetSampleCount())
+		}
+	}
+	require.Failf(t, "metric not found", "metric %s has not been gathered", name)
+	return 0
+}
+
+// Start an echo server which counts echoed messages.
+func newCountingEchoServer() (*httptest.Server, *atomic.Int64) {
+	echoed := &atomic.Int64{}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+			echoed.Add(1)
+		}
+	}))
+	return srv, echoed
+}
+
+// Convert a http test server URL to a websocket URL.
+func toWebsocketURL(t *testing.T, httpURL string) *url.URL {
+	u, err := url.Parse("ws" + strings.TrimPrefix(httpURL, "http"))
+	require.NoError(t, err)
+	return u
+}
@@ -0,0 +1,249 @@
+package wscengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Message waiting in the write queue
+type writeRequest struct {
+	// Context provided to Write - not canceled when the caller cancels it
+	ctx context.Context
+	// Message type
+	msgType wsadapters.MessageType
+	// Copy of the message content - the caller can reuse its buffer once the message is queued
+	msg []byte
+	// Indicates whether the message must be written with WritePropagated
+	propagated bool
+	// Propagator provided to WritePropagated
+	propagator propagation.TextMapPropagator
+}
+
+// Decorator used by the engine to queue written messages and write them from a single goroutine.
+//
+// Write returns as soon as the message is queued. Messages are written in the order they have
+// been queued by a writer goroutine which runs while the queue is not empty. Close waits until
+// queued messages have been written before it closes the connection.
+type writeQueueConnectionDecorator struct {
+	// Decorated connection adapter
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// Logger used to log failed writes
+	logger *slog.Logger
+	// Queued messages
+	queue chan writeRequest
+	// Mutex used to protect the fields below
+	mu sync.Mutex
+	// Indicates whether the writer goroutine is running
+	running bool
+	// Number of messages queued or being queued which have not been written yet
+	pending int
+	// Channel closed when there is no pending message
+	idle chan struct{}
+}
+
+// Create a new write queue decorator which can hold up to depth messages.
+func newWriteQueueConnectionDecorator(
+	decorated wsadapters.WebsocketConnectionAdapterInterface,
+	depth int,
+	logger *slog.Logger) *writeQueueConnectionDecorator {
+	idle := make(chan struct{})
+	close(idle)
+	return &writeQueueConnectionDecorator{
+		decorated: decorated,
+		logger:    loggerOrDiscard(logger),
+		queue:     make(chan writeRequest, depth),
+		mu:        sync.Mutex{},
+		running:   false,
+		pending:   0,
+		idle:      idle,
+	}
+}
+
+// Simple proxy for Dial method.
+func (adapter *writeQueueConnectionDecorator) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Proxy for Close method which waits until queued messages have been written or the context is
+// done before it closes the connection.
+func (adapter *writeQueueConnectionDecorator) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	adapter.mu.Lock()
+	idle := adapter.idle
+	adapter.mu.Unlock()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method. Queued messages are not waited for: they fail to be written.
+func (adapter *writeQueueConnectionDecorator) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Simple proxy for Ping method.
+func (adapter *writeQueueConnectionDecorator) Ping(ctx context.Context) error {
+	return adapter.decorated.Ping(ctx)
+}
+
+// Simple proxy for Read method.
+func (adapter *writeQueueConnectionDecorator) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	return adapter.decorated.Read(ctx)
+}
+
+// Simple proxy for ReadStream method.
+func (adapter *writeQueueConnectionDecorator) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	return adapter.decorated.ReadStream(ctx)
+}
+
+// # Description
+//
+// Queue a copy of the message and return. The message is written later by the writer goroutine:
+// write errors are logged and are not returned to the caller. The caller can reuse msg once the
+// method returns.
+//
+// # Returns
+//
+// nil once the message is queued or an error which wraps ErrWriteQueueFull and the context error
+// if the queue is full and the context is done before the message can be queued.
+func (adapter *writeQueueConnectionDecorator) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	return adapter.enqueue(ctx, writeRequest{ctx: context.WithoutCancel(ctx), msgType: msgType, msg: bytes.Clone(msg)})
+}
+
+// # Description
+//
+// Queue the message like Write. The message is written later with the decorated WritePropagated
+// method: the trace context of ctx is propagated as ctx is kept in the queued request.
+//
+// # Returns
+//
+// nil once the message is queued or an error which wraps ErrWriteQueueFull and the context error
+// if the queue is full and the context is done before the message can be queued.
+func (adapter *writeQueueConnectionDecorator) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	return adapter.enqueue(ctx, writeRequest{
+		ctx:        context.WithoutCancel(ctx),
+		msgType:    msgType,
+		msg:        bytes.Clone(msg),
+		propagated: true,
+		propagator: propagator,
+	})
+}
+
+// # Description
+//
+// Wait until queued messages have been written or the context is done and return a writer from
+// the decorated connection. Messages streamed with the writer are not queued.
+//
+// # Returns
+//
+// The writer returned by the decorated WriteStream method or the context error if the context is
+// done before queued messages have been written.
+func (adapter *writeQueueConnectionDecorator) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	adapter.mu.Lock()
+	idle := adapter.idle
+	adapter.mu.Unlock()
+	select {
+	case <-idle:
+		return adapter.decorated.WriteStream(ctx, msgType)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *writeQueueConnectionDecorator) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *writeQueueConnectionDecorator) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *writeQueueConnectionDecorator) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+// Queue the request and start the writer goroutine if it is not running.
+func (adapter *writeQueueConnectionDecorator) enqueue(ctx context.Context, req writeRequest) error {
+	adapter.addPending()
+	select {
+	case adapter.queue <- req:
+	default:
+		// Queue is full - wait for room until the context is done
+		select {
+		case adapter.queue <- req:
+		case <-ctx.Done():
+			adapter.donePending()
+			return fmt.Errorf("%w: %w", ErrWriteQueueFull, ctx.Err())
+		}
+	}
+	// Start the writer goroutine if it is not running
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if !adapter.running {
+		adapter.running = true
+		go adapter.runWriter()
+	}
+	return nil
+}
+
+// Write queued messages until the queue is empty.
+func (adapter *writeQueueConnectionDecorator) runWriter() {
+	for {
+		select {
+		case req := <-adapter.queue:
+			var err error
+			if req.propagated {
+				err = adapter.decorated.WritePropagated(req.ctx, req.msgType, req.msg, req.propagator)
+			} else {
+				err = adapter.decorated.Write(req.ctx, req.msgType, req.msg)
+			}
+			if err != nil {
+				adapter.logger.ErrorContext(req.ctx, "failed to write queued message", logKeyError, err)
+			}
+			adapter.donePending()
+		default:
+			// Exit if the queue is still empty once the mutex is held: Write starts a new writer
+			// after it has queued a message if the writer is not running.
+			adapter.mu.Lock()
+			if len(adapter.queue) == 0 {
+				adapter.running = false
+				adapter.mu.Unlock()
+				return
+			}
+			adapter.mu.Unlock()
+		}
+	}
+}
+
+// Increment the number of pending messages.
+func (adapter *writeQueueConnectionDecorator) addPending() {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.pending == 0 {
+		adapter.idle = make(chan struct{})
+	}
+	adapter.pending++
+}
+
+// Decrement the number of pending messages and signal when there is no pending message.
+func (adapter *writeQueueConnectionDecorator) donePending() {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.pending--
+	if adapter.pending == 0 {
+		close(adapter.idle)
+	}
+}
@@ -0,0 +1,192 @@
+package wscengine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for write queue unit tests
+type WriteQueueUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run WriteQueueUnitTestSuite test suite
+func TestWriteQueueUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(WriteQueueUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test queued messages are written in order and Close waits for them.
+func (suite *WriteQueueUnitTestSuite) TestWritesInOrder() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	queue := newWriteQueueConnectionDecorator(adapter, 4, nil)
+	for i := 0; i < 20; i++ {
+		require.NoError(suite.T(), queue.Write(context.Background(), wsadapters.Text, []byte(fmt.Sprint(i))))
+	}
+	require.NoError(suite.T(), queue.Close(context.Background(), wsadapters.NormalClosure, "bye"))
+	written := adapter.WrittenMessages()
+	require.Len(suite.T(), written, 20)
+	for i, msg := range written {
+		require.Equal(suite.T(), fmt.Sprint(i), string(msg.Msg))
+	}
+}
+
+// Test a caller can reuse its buffer once Write returns without altering the queued message.
+func (suite *WriteQueueUnitTestSuite) TestBufferReuse() {
+	adapter := &blockingWriteAdapter{
+		MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter(),
+		unblock:                        make(chan struct{}),
+	}
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	queue := newWriteQueueConnectionDecorator(adapter, 4, nil)
+	// Messages are queued while the writer goroutine is blocked - buffer is reused after each call
+	buf := []byte("first")
+	require.NoError(suite.T(), queue.Write(context.Background(), wsadapters.Text, buf))
+	copy(buf, "xxxxx")
+	buf = buf[:0]
+	buf = append(buf, "other"...)
+	require.NoError(suite.T(), queue.WritePropagated(context.Background(), wsadapters.Text, buf, propagation.TraceContext{}))
+	copy(buf, "yyyyy")
+	close(adapter.unblock)
+	require.NoError(suite.T(), queue.Close(context.Background(), wsadapters.NormalClosure, "bye"))
+	written := adapter.WrittenMessages()
+	require.Len(suite.T(), written, 2)
+	require.Equal(suite.T(), "first", string(written[0].Msg))
+	require.Equal(suite.T(), "other", string(written[1].Msg))
+}
+
+// Test Write returns ErrWriteQueueFull when the message cannot be queued before the context is
+// done.
+func (suite *WriteQueueUnitTestSuite) TestQueueFull() {
+	adapter := &blockingWriteAdapter{
+		MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter(),
+		unblock:                        make(chan struct{}),
+	}
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	queue := newWriteQueueConnectionDecorator(adapter, 1, nil)
+	// First message is being written, second message fills the queue
+	require.NoError(suite.T(), queue.Write(context.Background(), wsadapters.Text, []byte("1")))
+	require.Eventually(suite.T(), func() bool {
+		return len(queue.queue) == 0
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(suite.T(), queue.Write(context.Background(), wsadapters.Text, []byte("2")))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = queue.Write(ctx, wsadapters.Text, []byte("3"))
+	require.ErrorIs(suite.T(), err, ErrWriteQueueFull)
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	// Close gives up waiting for queued messages when its context is done
+	require.NoError(suite.T(), queue.Close(ctx, wsadapters.NormalClosure, "bye"))
+	close(adapter.unblock)
+}
+
+// Test WriteStream waits for queued messages to be written.
+func (suite *WriteQueueUnitTestSuite) TestWriteStream() {
+	adapter := &blockingWriteAdapter{
+		MockWebsocketConnectionAdapter: mock.NewMockWebsocketConnectionAdapter(),
+		unblock:                        make(chan struct{}),
+	}
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	queue := newWriteQueueConnectionDecorator(adapter, 4, nil)
+	require.NoError(suite.T(), queue.Write(context.Background(), wsadapters.Text, []byte("queued")))
+	// Queued message is blocked - WriteStream gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = queue.WriteStream(ctx, wsadapters.Text)
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	// Unblock the queued message - streamed message is written after it
+	close(adapter.unblock)
+	writer, err := queue.WriteStream(context.Background(), wsadapters.Text)
+	require.NoError(suite.T(), err)
+	_, err = writer.Write([]byte("streamed"))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+	written := adapter.WrittenMessages()
+	require.Len(suite.T(), written, 2)
+	require.Equal(suite.T(), "queued", string(written[0].Msg))
+	require.Equal(suite.T(), "streamed", string(written[1].Msg))
+}
+
+// Test propagated messages are queued and written with the decorated WritePropagated method.
+func (suite *WriteQueueUnitTestSuite) TestWritePropagated() {
+	adapter := mock.NewMockWebsocketConnectionAdapter().WithPropagationMode(wsadapters.BinaryExtensionFrame)
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	queue := newWriteQueueConnectionDecorator(adapter, 4, nil)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	require.NoError(suite.T(), queue.Write(ctx, wsadapters.Text, []byte("plain")))
+	require.NoError(suite.T(), queue.WritePropagated(ctx, wsadapters.Text, []byte("traced"), propagation.TraceContext{}))
+	require.NoError(suite.T(), queue.Close(context.Background(), wsadapters.NormalClosure, "bye"))
+	written := adapter.WrittenMessages()
+	require.Len(suite.T(), written, 2)
+	require.Equal(suite.T(), "plain", string(written[0].Msg))
+	require.Equal(suite.T(), wsadapters.Binary, written[1].MsgType)
+	extracted, msgType, msg, err := wsadapters.ExtractTraceContext(context.Background(), wsadapters.BinaryExtensionFrame, written[1].MsgType, written[1].Msg, propagation.TraceContext{})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), "traced", string(msg))
+	require.Equal(suite.T(), trace.TraceID{1}, trace.SpanContextFromContext(extracted).TraceID())
+}
+
+// Test messages written by callbacks go through the write queue when it is enabled.
+func (suite *WriteQueueUnitTestSuite) TestWithEngine() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithWriteQueue(8)
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	_, ok := engine.conn.(*writeQueueConnectionDecorator)
+	require.True(suite.T(), ok)
+	require.NoError(suite.T(), engine.conn.Write(ctx, wsadapters.Text, []byte("hello")))
+	require.NoError(suite.T(), engine.Stop(ctx))
+	require.Len(suite.T(), adapter.WrittenMessages(), 1)
+	// Failed writes are not returned to the caller
+	require.NoError(suite.T(), engine.conn.Write(ctx, wsadapters.Text, []byte("hello")))
+	require.ErrorIs(suite.T(), engine.conn.Close(ctx, wsadapters.NormalClosure, "bye"), wsadapters.ErrNotConnected)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Mock adapter which blocks Write calls until unblock is closed
+type blockingWriteAdapter struct {
+	*mock.MockWebsocketConnectionAdapter
+	unblock chan struct{}
+}
+
+func (adapter *blockingWriteAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	<-adapter.unblock
+	return adapter.MockWebsocketConnectionAdapter.Write(ctx, msgType, msg)
+}
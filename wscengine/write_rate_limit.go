@@ -0,0 +1,144 @@
+package wscengine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/time/rate"
+)
+
+// Decorator used by the engine to limit the number of messages written per second.
+type writeRateLimitConnectionDecorator struct {
+	// Decorated connection adapter
+	decorated wsadapters.WebsocketConnectionAdapterInterface
+	// Limiter shared with the engine
+	limiter *rate.Limiter
+}
+
+// Simple proxy for Dial method.
+func (adapter *writeRateLimitConnectionDecorator) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method.
+func (adapter *writeRateLimitConnectionDecorator) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *writeRateLimitConnectionDecorator) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Simple proxy for Ping method.
+func (adapter *writeRateLimitConnectionDecorator) Ping(ctx context.Context) error {
+	return adapter.decorated.Ping(ctx)
+}
+
+// Simple proxy for Read method.
+func (adapter *writeRateLimitConnectionDecorator) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	return adapter.decorated.Read(ctx)
+}
+
+// Simple proxy for ReadStream method.
+func (adapter *writeRateLimitConnectionDecorator) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	return adapter.decorated.ReadStream(ctx)
+}
+
+// # Description
+//
+// Wait until the rate limit allows to write the message and then write it.
+//
+// # Returns
+//
+// The error returned by the decorated Write method or an error which wraps ErrWriteRateLimited
+// and the context error if the context is done before the message can be written.
+func (adapter *writeRateLimitConnectionDecorator) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	err := adapter.wait(ctx)
+	if err != nil {
+		return err
+	}
+	return adapter.decorated.Write(ctx, msgType, msg)
+}
+
+// Proxy for WritePropagated method which waits for the rate limiter like Write.
+func (adapter *writeRateLimitConnectionDecorator) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	err := adapter.wait(ctx)
+	if err != nil {
+		return err
+	}
+	return adapter.decorated.WritePropagated(ctx, msgType, msg, propagator)
+}
+
+// # Description
+//
+// Wait until the rate limit allows to write a message and then return a writer for the message.
+//
+// # Returns
+//
+// The writer returned by the decorated WriteStream method or an error which wraps
+// ErrWriteRateLimited and the context error if the context is done before the message can be
+// written.
+func (adapter *writeRateLimitConnectionDecorator) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	err := adapter.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.decorated.WriteStream(ctx, msgType)
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *writeRateLimitConnectionDecorator) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *writeRateLimitConnectionDecorator) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *writeRateLimitConnectionDecorator) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+// # Description
+//
+// Return the number of messages which can currently be written without waiting when the write
+// rate is limited (see WithWriteRateLimit). The value is negative when writers are waiting for
+// the limit to allow their messages.
+//
+// # Returns
+//
+// The number of available tokens or +Inf if the write rate is not limited.
+func (wsengine *WebsocketEngine) TokensRemaining() float64 {
+	if wsengine.writeLimiter == nil {
+		return math.Inf(1)
+	}
+	return wsengine.writeLimiter.Tokens()
+}
+
+// Reserve a token and wait until it can be used. Reservation is canceled if the context is done
+// so the token can be used by other writers.
+func (adapter *writeRateLimitConnectionDecorator) wait(ctx context.Context) error {
+	reservation := adapter.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			reservation.Cancel()
+			return fmt.Errorf("%w: %w", ErrWriteRateLimited, ctx.Err())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package wscengine
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/mock"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for write rate limit unit tests
+type WriteRateLimitUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run WriteRateLimitUnitTestSuite test suite
+func TestWriteRateLimitUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(WriteRateLimitUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test Write waits for the rate limit and returns ErrWriteRateLimited when the context is done
+// before the message can be written.
+func (suite *WriteRateLimitUnitTestSuite) TestWriteWaitsForLimit() {
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	_, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost"})
+	require.NoError(suite.T(), err)
+	limiter := rate.NewLimiter(rate.Limit(20), 2)
+	decorator := &writeRateLimitConnectionDecorator{decorated: adapter, limiter: limiter}
+	// Burst is written at once, next message waits ~50ms
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(suite.T(), decorator.Write(context.Background(), wsadapters.Text, []byte("hello")))
+	}
+	require.GreaterOrEqual(suite.T(), time.Since(start), 40*time.Millisecond)
+	require.Len(suite.T(), adapter.WrittenMessages(), 3)
+	// Context is done before the limit allows the message
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = decorator.Write(ctx, wsadapters.Text, []byte("hello"))
+	require.ErrorIs(suite.T(), err, ErrWriteRateLimited)
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	require.Len(suite.T(), adapter.WrittenMessages(), 3)
+}
+
+// Test the engine limits the write rate and exposes the remaining tokens.
+func (suite *WriteRateLimitUnitTestSuite) TestWithEngine() {
+	// Write rate is not limited by default
+	engine, err := NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, mock.NewMockWebsocketConnectionAdapter(), wstest.NewRecordingClient(), nil, nil)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), math.IsInf(engine.TokensRemaining(), 1))
+	// Enable write rate limit
+	adapter := mock.NewMockWebsocketConnectionAdapter()
+	opts := NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithWriteRateLimit(1, 0)
+	engine, err = NewWebsocketEngine(&url.URL{Scheme: "ws", Host: "localhost"}, adapter, wstest.NewRecordingClient(), opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	_, ok := engine.conn.(*writeRateLimitConnectionDecorator)
+	require.True(suite.T(), ok)
+	require.InDelta(suite.T(), 1, engine.TokensRemaining(), 0.1)
+	require.NoError(suite.T(), engine.conn.Write(ctx, wsadapters.Text, []byte("hello")))
+	require.InDelta(suite.T(), 0, engine.TokensRemaining(), 0.1)
+	// Burst defaults to 1 message: the second message cannot be written right away
+	wctx, wcancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer wcancel()
+	require.ErrorIs(suite.T(), engine.conn.Write(wctx, wsadapters.Text, []byte("hello")), ErrWriteRateLimited)
+	require.Len(suite.T(), adapter.WrittenMessages(), 1)
+}
@@ -0,0 +1,71 @@
+package wsadapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// Writer which buffers the content of a message and writes the whole message when it is closed.
+// Adapters for libraries which cannot stream messages can return it from WriteStream.
+type BufferedMessageWriter struct {
+	// Context provided to the write function
+	ctx context.Context
+	// Message type
+	msgType MessageType
+	// Function used to write the message
+	write func(ctx context.Context, msgType MessageType, msg []byte) error
+	// Buffered message content
+	buf bytes.Buffer
+	// Flag set once the writer is closed
+	closed bool
+}
+
+// # Description
+//
+// Factory which creates a new BufferedMessageWriter.
+//
+// # Inputs
+//
+//   - ctx: Context provided to the write function when the writer is closed.
+//   - msgType: Message type (Binary | Text)
+//   - write: Function used to write the whole message, usually the Write method of the adapter.
+//
+// # Returns
+//
+// New BufferedMessageWriter
+func NewBufferedMessageWriter(
+	ctx context.Context,
+	msgType MessageType,
+	write func(ctx context.Context, msgType MessageType, msg []byte) error) *BufferedMessageWriter {
+	return &BufferedMessageWriter{
+		ctx:     ctx,
+		msgType: msgType,
+		write:   write,
+	}
+}
+
+// # Description
+//
+// Append p to the message content.
+func (writer *BufferedMessageWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, fmt.Errorf("write failed: message writer is closed")
+	}
+	return writer.buf.Write(p)
+}
+
+// # Description
+//
+// Write the whole message with the write function.
+//
+// # Returns
+//
+// The error returned by the write function or an error if the writer is already closed.
+func (writer *BufferedMessageWriter) Close() error {
+	if writer.closed {
+		return fmt.Errorf("close failed: message writer is already closed")
+	}
+	writer.closed = true
+	return writer.write(writer.ctx, writer.msgType, writer.buf.Bytes())
+}
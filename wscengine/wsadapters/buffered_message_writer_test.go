@@ -0,0 +1,59 @@
+package wsadapters
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for BufferedMessageWriter unit tests
+type BufferedMessageWriterUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run BufferedMessageWriterUnitTestSuite test suite
+func TestBufferedMessageWriterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(BufferedMessageWriterUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the whole message is written once the writer is closed.
+func (suite *BufferedMessageWriterUnitTestSuite) TestWriteOnClose() {
+	written := [][]byte{}
+	write := func(ctx context.Context, msgType MessageType, msg []byte) error {
+		require.Equal(suite.T(), Binary, msgType)
+		written = append(written, msg)
+		return nil
+	}
+	writer := NewBufferedMessageWriter(context.Background(), Binary, write)
+	_, err := writer.Write([]byte("hello "))
+	require.NoError(suite.T(), err)
+	_, err = writer.Write([]byte("world"))
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), written)
+	require.NoError(suite.T(), writer.Close())
+	require.Equal(suite.T(), [][]byte{[]byte("hello world")}, written)
+	// Writer cannot be used once closed
+	_, err = writer.Write([]byte("!"))
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), writer.Close())
+	require.Len(suite.T(), written, 1)
+}
+
+// Test Close returns the error returned by the write function.
+func (suite *BufferedMessageWriterUnitTestSuite) TestWriteError() {
+	writer := NewBufferedMessageWriter(context.Background(), Text, func(ctx context.Context, msgType MessageType, msg []byte) error {
+		return fmt.Errorf("write failed: %w", ErrNotConnected)
+	})
+	require.ErrorIs(suite.T(), writer.Close(), ErrNotConnected)
+}
@@ -0,0 +1,615 @@
+// Package which contains a WebsocketConnectionAdapterInterface implementation for
+// cdr/websocket library (https://github.com/coder/websocket), the maintained fork of
+// nhooyr/websocket.
+//
+// The library integrates natively with net/http: adapters can be created for client connections
+// with Dial or for server connections with NewCDRWebsocketServerConnectionAdapter which accepts
+// an incoming request (with httptest.Server for example).
+package cdr
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/coder/websocket"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Adapter for cdr/websocket library
+type CDRWebsocketConnectionAdapter struct {
+	// Undelrying websocket connection
+	conn *websocket.Conn
+	// Dial options to use when opening a connection
+	opts *websocket.DialOptions
+	// Internal mutex
+	mu sync.Mutex
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsadapters.PropagationMode
+}
+
+// # Description
+//
+// Factory which creates a new CDRWebsocketConnectionAdapter.
+//
+// # Inputs
+//
+//   - opts: Optional dial options to use when calling Dial method. Can be nil.
+//
+// # Returns
+//
+// New CDRWebsocketConnectionAdapter
+func NewCDRWebsocketConnectionAdapter(opts *websocket.DialOptions) *CDRWebsocketConnectionAdapter {
+	return &CDRWebsocketConnectionAdapter{
+		conn: nil,
+		opts: opts,
+		mu:   sync.Mutex{},
+	}
+}
+
+// # Description
+//
+// Factory which accepts an incoming websocket handshake and creates a new
+// CDRWebsocketConnectionAdapter for the accepted connection.
+//
+// In case of failure, the response has already been written (HTTP error) and the caller must not
+// write to w. The returned adapter must not be used to call Dial.
+//
+// # Inputs
+//
+//   - w: Response writer of the HTTP request.
+//   - r: HTTP request to upgrade.
+//   - opts: Optional accept options (subprotocols, origin patterns, ...). Can be nil.
+//
+// # Returns
+//
+// An adapter for the accepted connection or an error if the handshake failed.
+func NewCDRWebsocketServerConnectionAdapter(w http.ResponseWriter, r *http.Request, opts *websocket.AcceptOptions) (*CDRWebsocketConnectionAdapter, error) {
+	conn, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &CDRWebsocketConnectionAdapter{
+		conn: conn,
+		opts: nil,
+		mu:   sync.Mutex{},
+	}, nil
+}
+
+// # Description
+//
+// Dial opens a connection to the websocket server and performs a WebSocket handshake.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - target: Target server URL
+//
+// # Returns
+//
+// The server response to websocket handshake or an error if any.
+func (adapter *CDRWebsocketConnectionAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return nil, ctx.Err()
+	default:
+		// Lock internal mutex before accessing internal state
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if adapter.conn != nil {
+			// Return error in case a connection has already been set
+			return nil, wsadapters.ErrAlreadyConnected
+		}
+		// Use the dial host for Host header and TLS server name if target host has been resolved
+		opts := adapter.opts
+		if host, ok := wsadapters.DialHostFromContext(ctx); ok {
+			opts = withDialHost(opts, host)
+		}
+		// Open websocket connection
+		conn, res, err := websocket.Dial(ctx, target.String(), opts)
+		if err != nil {
+			// Return response and error
+			return res, err
+		}
+		// Persist connection internally and return
+		adapter.conn = conn
+		return res, nil
+	}
+}
+
+// # Description
+//
+// Send a close message with the provided status code and an optional close reason and drop
+// the websocket connection.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//   - code: Status code to use in close message
+//   - reason: Optional reason joined in clsoe message. Can be empty.
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: server unreachable, connection already closed, ...
+func (adapter *CDRWebsocketConnectionAdapter) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.conn == nil {
+		return fmt.Errorf("close failed: %w", wsadapters.ErrNotConnected)
+	}
+	// Close connection - error wraps net.ErrClosed if connection is already closed
+	err := adapter.conn.Close(convertToCDRStatusCodes(code), reason)
+	// Void connection in any case
+	adapter.conn = nil
+	// Return result
+	return err
+}
+
+// # Description
+//
+// Close the underlying network connection without sending a close message and drop the
+// websocket connection. The server sees an abnormal closure (1006).
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (adapter *CDRWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.conn == nil {
+		return fmt.Errorf("drop failed: %w", wsadapters.ErrNotConnected)
+	}
+	// Close the network connection without closing handshake
+	err := adapter.conn.CloseNow()
+	// Void connection in any case
+	adapter.conn = nil
+	// Return result
+	return err
+}
+
+// # Description
+//
+// Send a Ping message to the websocket server and blocks until a Pong response is received, a
+// timeout occurs or until connection is closed.
+//
+// A concrrent gorotine must call Read method so that contorl frames, pong inclded, are processed
+// and ping does not hang.
+//
+// # Inputs
+//
+//   - ctx: context used for tracing/timeout purpose.
+//
+// # Returns
+//
+// - nil in case of success: if a Ping message is sent to the server and if a Pong is received.
+// - error: connection is closed, context timeout/cancellation, ...
+func (adapter *CDRWebsocketConnectionAdapter) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock internal mutex before and store current conn reference in local variable to allow
+		// other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			return fmt.Errorf("%w: %w", wsadapters.ErrPingFailed, wsadapters.ErrNotConnected)
+		}
+		// Call Ping and return results
+		return conn.Ping(ctx)
+	}
+}
+
+// # Description
+//
+// Read a single message from the websocket server. Read blocks until a message is received
+// from the server or until connection closes
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *CDRWebsocketConnectionAdapter) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return -1, nil, ctx.Err()
+	default:
+		// Lock internal mutex before and store current conn reference in local variable to allow
+		// other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			return -1, nil, fmt.Errorf("read failed: %w", wsadapters.ErrNotConnected)
+		}
+		// Call Read
+		cdrMsgType, msg, err := conn.Read(ctx)
+		if err != nil {
+			// Check if error is due to connection being closed
+			if websocket.CloseStatus(err) != -1 || errors.Is(err, io.EOF) {
+				// Drop the existing connection so a new one can be established - unless it has
+				// already been replaced
+				adapter.mu.Lock()
+				if adapter.conn == conn {
+					adapter.conn = nil
+				}
+				adapter.mu.Unlock()
+				// Error is because connection has been closed
+				if websocket.CloseStatus(err) != -1 {
+					// We have a close status code - return typed error
+					return -1, nil, wsadapters.WebsocketCloseError{
+						Code:   convertFromCDRStatusCodes(websocket.CloseStatus(err)),
+						Reason: err.Error(),
+						Err:    err,
+					}
+				} else {
+					// We do not have close status -> use default 1006 for typed error
+					return -1, nil, wsadapters.WebsocketCloseError{
+						Code:   wsadapters.AbnormalClosure,
+						Reason: "websocket connection abnormal closure",
+						Err:    err,
+					}
+				}
+			} else {
+				// Error is not because connection was closed
+				return -1, nil, err
+			}
+		}
+		// Return result with converted msgtype
+		return convertFromCDRMsgTypes(cdrMsgType), msg, nil
+	}
+}
+
+// # Description
+//
+// The adapter does not stream messages: ReadStream reads the whole message with Read and returns
+// a reader on the message content.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+// The message type and a reader on the message content or the error returned by Read.
+func (adapter *CDRWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, msg, err := adapter.Read(ctx)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
+// # Description
+//
+// Write a single message to the websocket server. Write blocks until message is sent to the
+// server or until an error occurs: context timeout, cancellation, connection closed, ....
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *CDRWebsocketConnectionAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock internal mutex before and store current conn reference in local variable to allow
+		// other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+		}
+		// Call Write and retuurn results
+		return conn.Write(ctx, convertToCDRMsgTypes(msgType), msg)
+	}
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode of the adapter
+// and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *CDRWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	adapter.mu.Lock()
+	mode := adapter.propagationMode
+	adapter.mu.Unlock()
+	msgType, msg, err := wsadapters.InjectTraceContext(ctx, mode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in outgoing
+// messages. Defaults to wsadapters.None: messages are written as is.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *CDRWebsocketConnectionAdapter) WithPropagationMode(mode wsadapters.PropagationMode) *CDRWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// # Description
+//
+// The adapter does not stream messages: the returned writer buffers the message content and
+// writes the whole message with Write when it is closed.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose. Provided to Write when the writer is closed.
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+// A writer for the message content. Close returns the error returned by Write.
+func (adapter *CDRWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return wsadapters.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
+// # Description
+//
+// Return the underlying websocket connection if any. Returned value has to be type asserted.
+//
+// # Returns
+//
+// The underlying websocket connection if any. Returned value has to be type asserted.
+func (adapter *CDRWebsocketConnectionAdapter) GetUnderlyingWebsocketConnection() any {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Return underlying connection
+	return adapter.conn
+}
+
+// # Description
+//
+// Return the subprotocol selected by the server for the current connection. Subprotocols are
+// proposed to the server with the Subprotocols field of the dial options.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if the server has not selected any subprotocol
+// or if no connection is up.
+func (adapter *CDRWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.conn == nil {
+		return ""
+	}
+	return adapter.conn.Subprotocol()
+}
+
+// # Description
+//
+// The adapter does not track its read throughput.
+//
+// # Returns
+//
+// A zero value.
+func (adapter *CDRWebsocketConnectionAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return wsadapters.AdapterReadStats{}
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// # Description
+//
+// Convert a status code to cdr enum.
+//
+// # input
+//
+//   - code: Status code to convert
+//
+// # Returns
+//
+// Converted code or websocket.StatusAbnormalClosure if none is corresponding.
+func convertToCDRStatusCodes(code wsadapters.StatusCode) websocket.StatusCode {
+	if code == wsadapters.NormalClosure {
+		return websocket.StatusNormalClosure
+	}
+	if code == wsadapters.GoingAway {
+		return websocket.StatusGoingAway
+	}
+	if code == wsadapters.ProtocolError {
+		return websocket.StatusProtocolError
+	}
+	if code == wsadapters.UnsupportedData {
+		return websocket.StatusUnsupportedData
+	}
+	if code == wsadapters.NoStatusReceived {
+		return websocket.StatusNoStatusRcvd
+	}
+	if code == wsadapters.InvalidFramePayloadData {
+		return websocket.StatusInvalidFramePayloadData
+	}
+	if code == wsadapters.PolicyViolation {
+		return websocket.StatusPolicyViolation
+	}
+	if code == wsadapters.MessageTooBig {
+		return websocket.StatusMessageTooBig
+
+	}
+	if code == wsadapters.MandatoryExtension {
+		return websocket.StatusMandatoryExtension
+	}
+	if code == wsadapters.InternalError {
+		return websocket.StatusInternalError
+	}
+	if code == wsadapters.TLSHandshake {
+		return websocket.StatusTLSHandshake
+	}
+	return websocket.StatusAbnormalClosure
+}
+
+// # Description
+//
+// Convert a status code from cdr enum.
+//
+// # input
+//
+//   - code: Status code to convert
+//
+// # Returns
+//
+// Converted code or wsadapters.AbnormalClosure if none is corresponding.
+func convertFromCDRStatusCodes(code websocket.StatusCode) wsadapters.StatusCode {
+	if code == websocket.StatusNormalClosure {
+		return wsadapters.NormalClosure
+	}
+	if code == websocket.StatusGoingAway {
+		return wsadapters.GoingAway
+	}
+	if code == websocket.StatusProtocolError {
+		return wsadapters.ProtocolError
+	}
+	if code == websocket.StatusUnsupportedData {
+		return wsadapters.UnsupportedData
+	}
+	if code == websocket.StatusNoStatusRcvd {
+		return wsadapters.NoStatusReceived
+	}
+	if code == websocket.StatusInvalidFramePayloadData {
+		return wsadapters.InvalidFramePayloadData
+	}
+	if code == websocket.StatusPolicyViolation {
+		return wsadapters.PolicyViolation
+	}
+	if code == websocket.StatusMessageTooBig {
+		return wsadapters.MessageTooBig
+
+	}
+	if code == websocket.StatusMandatoryExtension {
+		return wsadapters.MandatoryExtension
+	}
+	if code == websocket.StatusInternalError {
+		return wsadapters.InternalError
+	}
+	if code == websocket.StatusTLSHandshake {
+		return wsadapters.TLSHandshake
+	}
+	return wsadapters.AbnormalClosure
+}
+
+// # Description
+//
+// Convert messages types from MessageType to CDR specific types.
+//
+// # Inputs
+//
+//   - msgType: code to convert
+//
+// # Returns
+//
+// Converted code. Default to binary message type if no match.
+func convertToCDRMsgTypes(msgType wsadapters.MessageType) websocket.MessageType {
+	if msgType == wsadapters.Text {
+		return websocket.MessageText
+	}
+	return websocket.MessageBinary
+}
+
+// # Description
+//
+// Convert messages types from CDR specific types to MessageType.
+//
+// # Inputs
+//
+//   - msgType: code to convert
+//
+// # Returns
+//
+// Converted code. Default to binary message type if no match.
+func convertFromCDRMsgTypes(msgType websocket.MessageType) wsadapters.MessageType {
+	if msgType == websocket.MessageText {
+		return wsadapters.Text
+	}
+	return wsadapters.Binary
+}
+
+// Return a copy of the dial options which use the provided host for the Host header and for TLS
+// server name verification. TLS server name is only set when the HTTP client uses a
+// *http.Transport (the default).
+func withDialHost(opts *websocket.DialOptions, host string) *websocket.DialOptions {
+	copyOpts := websocket.DialOptions{}
+	if opts != nil {
+		copyOpts = *opts
+	}
+	copyOpts.Host = host
+	client := http.DefaultClient
+	if copyOpts.HTTPClient != nil {
+		client = copyOpts.HTTPClient
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if ok {
+		transport = transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		if transport.TLSClientConfig.ServerName == "" {
+			serverName := host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				serverName = h
+			}
+			transport.TLSClientConfig.ServerName = serverName
+		}
+		clientCopy := *client
+		clientCopy.Transport = transport
+		copyOpts.HTTPClient = &clientCopy
+	}
+	return &copyOpts
+}
@@ -0,0 +1,158 @@
+package cdr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+type CDRWebsocketConnectionAdapterTestSuite struct {
+	suite.Suite
+	// Websocket server address
+	srvUrl *url.URL
+	// Websocket test server which echoes messages with a server side adapter
+	srv *httptest.Server
+}
+
+// Run CDRWebsocketConnectionAdapterTestSuite test suite
+func TestCDRWebsocketConnectionAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(CDRWebsocketConnectionAdapterTestSuite))
+}
+
+// CDRWebsocketConnectionAdapterTestSuite - Before all tests
+func (suite *CDRWebsocketConnectionAdapterTestSuite) SetupSuite() {
+	suite.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adapter, err := NewCDRWebsocketServerConnectionAdapter(w, r, nil)
+		if err != nil {
+			return
+		}
+		ctx := r.Context()
+		// Close the connection from the server side if requested
+		if r.URL.Path == "/close" {
+			adapter.Close(ctx, wsadapters.GoingAway, "server shutdown")
+			return
+		}
+		// Echo messages until connection is closed
+		for {
+			msgType, msg, err := adapter.Read(ctx)
+			if err != nil {
+				return
+			}
+			if err := adapter.Write(ctx, msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	u, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	suite.srvUrl = u
+}
+
+// CDRWebsocketConnectionAdapterTestSuite - After all tests
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TearDownSuite() {
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* TESTS                                                                                         */
+/*************************************************************************************************/
+
+// Check adapter fully implements interface.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestInterfaceCompliance() {
+	var impl interface{} = NewCDRWebsocketConnectionAdapter(nil)
+	_, ok := impl.(wsadapters.WebsocketConnectionAdapterInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test Dial, Write, Read, Close and GetUnderlyingWebsocketConnection against the test server.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestEcho() {
+	adapter := NewCDRWebsocketConnectionAdapter(nil)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Connect to server and check a second Dial fails
+	resp, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+	_, ok := adapter.GetUnderlyingWebsocketConnection().(*websocket.Conn)
+	require.True(suite.T(), ok)
+	_, err = adapter.Dial(ctx, *suite.srvUrl)
+	require.Error(suite.T(), err)
+	// Echo text and binary messages
+	for _, msgType := range []wsadapters.MessageType{wsadapters.Text, wsadapters.Binary} {
+		require.NoError(suite.T(), adapter.Write(ctx, msgType, []byte("hello")))
+		readType, msg, err := adapter.Read(ctx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), msgType, readType)
+		require.Equal(suite.T(), []byte("hello"), msg)
+	}
+	// Close connection and check a second Close fails
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	require.Error(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test Ping while a concurrent goroutine reads messages so pong is processed.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestPing() {
+	adapter := NewCDRWebsocketConnectionAdapter(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	go adapter.Read(ctx)
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test Read returns a WebsocketCloseError when the server closes the connection.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestReadWhenServerCloses() {
+	adapter := NewCDRWebsocketConnectionAdapter(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target := *suite.srvUrl
+	target.Path = "/close"
+	_, err := adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	_, _, err = adapter.Read(ctx)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.GoingAway, closeErr.Code)
+	// Connection is dropped so a new one can be established
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+}
+
+// Test server side constructor fails when request is not a websocket handshake.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestServerAdapterWithInvalidHandshake() {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	adapter, err := NewCDRWebsocketServerConnectionAdapter(w, r, nil)
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), adapter)
+	require.NotEqual(suite.T(), http.StatusSwitchingProtocols, w.Code)
+}
+
+// Test adapter methods when called without an active connection or with a canceled context.
+func (suite *CDRWebsocketConnectionAdapterTestSuite) TestMethodsWithoutActiveConnection() {
+	adapter := NewCDRWebsocketConnectionAdapter(nil)
+	require.Error(suite.T(), adapter.Ping(context.Background()))
+	_, _, err := adapter.Read(context.Background())
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), adapter.Write(context.Background(), wsadapters.Text, []byte("hello")))
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapter.Dial(canceledCtx, *suite.srvUrl)
+	require.ErrorIs(suite.T(), err, context.Canceled)
+}
@@ -73,6 +73,29 @@ const (
 	TLSHandshake StatusCode = iota + 1000 + 3 // Skip 1012 to 1014
 )
 
+// # Description
+//
+// Map close status codes which are outside the ranges defined by RFC6455 (below 1000 or above
+// 4999, like 0, 999 or 5000) to AbnormalClosure (1006). Other codes are returned as is.
+//
+// https://www.rfc-editor.org/rfc/rfc6455.html#section-7.4.2
+func NormalizeCloseCode(code StatusCode) StatusCode {
+	return NewCloseCodeNormalizer(AbnormalClosure)(code)
+}
+
+// # Description
+//
+// Build a normalizer which maps close status codes which are outside the ranges defined by
+// RFC6455 (below 1000 or above 4999) to the provided default code. Other codes are returned as is.
+func NewCloseCodeNormalizer(defaultCode StatusCode) func(code StatusCode) StatusCode {
+	return func(code StatusCode) StatusCode {
+		if code < 1000 || code > 4999 {
+			return defaultCode
+		}
+		return code
+	}
+}
+
 // Websocket message types which can be received.
 //
 // Codes mimics RFC6455 frame opcodes. Control frames like continuation, close, ping, pong and
@@ -0,0 +1,23 @@
+package wsadapters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test close codes outside RFC6455 ranges are mapped to the default code.
+func TestNormalizeCloseCode(t *testing.T) {
+	for code, expected := range map[StatusCode]StatusCode{
+		0:             AbnormalClosure,
+		999:           AbnormalClosure,
+		NormalClosure: NormalClosure,
+		3000:          3000,
+		4999:          4999,
+		5000:          AbnormalClosure,
+	} {
+		require.Equal(t, expected, NormalizeCloseCode(code), "code %d", code)
+	}
+	require.Equal(t, GoingAway, NewCloseCodeNormalizer(GoingAway)(5000))
+	require.Equal(t, InternalError, NewCloseCodeNormalizer(GoingAway)(InternalError))
+}
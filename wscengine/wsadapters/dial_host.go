@@ -0,0 +1,30 @@
+package wsadapters
+
+import "context"
+
+// Key used to store the dial host in a context
+type dialHostKey struct{}
+
+// # Description
+//
+// Return a copy of the context which carries the host (host[:port]) the connection adapter must
+// use for the Host header and for TLS server name verification when Dial is called with a target
+// URL whose host has been replaced by a resolved IP address.
+//
+// Adapters provided by the library honor the dial host. Custom adapters should do the same so
+// virtual hosting and TLS verification keep working with resolved targets.
+func ContextWithDialHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, dialHostKey{}, host)
+}
+
+// # Description
+//
+// Return the dial host carried by the context, if any.
+//
+// # Returns
+//
+// The dial host and true if the context carries a non-empty dial host, false otherwise.
+func DialHostFromContext(ctx context.Context) (string, bool) {
+	host, ok := ctx.Value(dialHostKey{}).(string)
+	return host, ok && host != ""
+}
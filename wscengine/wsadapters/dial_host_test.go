@@ -0,0 +1,19 @@
+package wsadapters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test dial host is carried by the context.
+func TestDialHostContext(t *testing.T) {
+	_, ok := DialHostFromContext(context.Background())
+	require.False(t, ok)
+	_, ok = DialHostFromContext(ContextWithDialHost(context.Background(), ""))
+	require.False(t, ok)
+	host, ok := DialHostFromContext(ContextWithDialHost(context.Background(), "example.com:443"))
+	require.True(t, ok)
+	require.Equal(t, "example.com:443", host)
+}
@@ -1,6 +1,9 @@
 package wsadapters
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 /*************************************************************************************************/
 /* WEBSOCKET CLOSE ERROR                                                                         */
@@ -29,3 +32,32 @@ func (err WebsocketCloseError) Error() string {
 func (err WebsocketCloseError) Unwrap() error {
 	return err.Err
 }
+
+/*************************************************************************************************/
+/* CONNECTION STATE ERRORS                                                                       */
+/*************************************************************************************************/
+
+// Error returned by Dial when the adapter already has an open connection.
+var ErrAlreadyConnected = errors.New("a connection has already been established")
+
+// Error wrapped in the errors returned by adapter methods which require an open connection when
+// the adapter has no connection.
+var ErrNotConnected = errors.New("no connection is up")
+
+// Error wrapped in the errors returned by Ping when the ping message could not be sent or when the
+// adapter has no connection.
+var ErrPingFailed = errors.New("ping failed")
+
+/*************************************************************************************************/
+/* PROPAGATION ERRORS                                                                            */
+/*************************************************************************************************/
+
+// Error returned when a message cannot be wrapped in a JSON envelope because its content is not
+// valid JSON.
+var ErrInvalidEnvelopePayload = errors.New("message content is not valid JSON")
+
+// Error returned when a propagated message cannot be decoded.
+var ErrMalformedPropagatedMessage = errors.New("malformed propagated message")
+
+// Error returned when an unknown propagation mode is used.
+var ErrUnknownPropagationMode = errors.New("unknown propagation mode")
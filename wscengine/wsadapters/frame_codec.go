@@ -0,0 +1,135 @@
+package wsadapters
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*************************************************************************************************/
+/* FRAME CODEC                                                                                   */
+/*************************************************************************************************/
+
+// Error returned by DefaultFrameCodec when a frame cannot be encoded or decoded.
+var ErrInvalidFrame = errors.New("invalid websocket frame")
+
+// Codec used to encode and decode single websocket frames (RFC 6455 section 5.2). A custom codec
+// can be used to replace the default implementation with an optimized one (SIMD masking, ...).
+//
+// Frame codecs are only used by connection adapters which implement websocket framing themselves
+// (like the gnet adapter). Adapters built on a websocket library which handles framing (gorilla,
+// nhooyr) do not use them.
+//
+// Message types are RFC 6455 opcodes (1 = text, 2 = binary, 8 = close, 9 = ping, 10 = pong): they
+// match gorilla/websocket message type constants.
+type FrameCodec interface {
+	// Encode the payload in a single final frame (FIN bit set) with the provided opcode. The
+	// payload is masked with a random mask key if mask is true (client to server frames).
+	Encode(msgType int, payload []byte, mask bool) ([]byte, error)
+	// Decode a single, complete and final frame (FIN bit set) and return its opcode and its
+	// unmasked payload. Fragmented messages are reassembled by the connection adapter. The frame
+	// is only valid during the call: the returned payload must not reference it.
+	Decode(frame []byte) (msgType int, payload []byte, err error)
+}
+
+// Handler called with the opcode and the unmasked payload of received frames which have non-zero
+// RSV bits (RFC 6455 section 5.2), before the frames are processed as usual. Extensions can use
+// it to implement extension specific frames. An error returned by the handler is surfaced as a
+// read error and the frame is dropped.
+//
+// Like frame codecs, extension frame handlers are only used by connection adapters which
+// implement websocket framing themselves.
+type ExtensionFrameHandler func(opcode byte, payload []byte) error
+
+// FrameCodec implementation which encodes and decodes frames like gorilla/websocket does.
+type DefaultFrameCodec struct{}
+
+// # Description
+//
+// Encode the payload in a single final frame.
+//
+// # Returns
+//
+// The encoded frame or ErrInvalidFrame if msgType is not a valid opcode (0 to 15).
+func (codec DefaultFrameCodec) Encode(msgType int, payload []byte, mask bool) ([]byte, error) {
+	if msgType < 0 || msgType > 0x0F {
+		return nil, fmt.Errorf("%w: invalid opcode %d", ErrInvalidFrame, msgType)
+	}
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|byte(msgType))
+	var maskBit byte
+	if mask {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if !mask {
+		return append(frame, payload...), nil
+	}
+	maskKey := make([]byte, 4)
+	rand.Read(maskKey)
+	frame = append(frame, maskKey...)
+	start := len(frame)
+	frame = append(frame, payload...)
+	applyMask(frame[start:], maskKey)
+	return frame, nil
+}
+
+// # Description
+//
+// Decode a single complete frame. The returned payload is a copy of the frame payload.
+//
+// # Returns
+//
+// The frame opcode and its unmasked payload or ErrInvalidFrame if the frame is truncated or has
+// trailing data.
+func (codec DefaultFrameCodec) Decode(frame []byte) (int, []byte, error) {
+	if len(frame) < 2 {
+		return 0, nil, fmt.Errorf("%w: truncated header", ErrInvalidFrame)
+	}
+	headerLen := 2
+	length := uint64(frame[1] & 0x7F)
+	switch length {
+	case 126:
+		headerLen += 2
+		if len(frame) < headerLen {
+			return 0, nil, fmt.Errorf("%w: truncated header", ErrInvalidFrame)
+		}
+		length = uint64(binary.BigEndian.Uint16(frame[2:4]))
+	case 127:
+		headerLen += 8
+		if len(frame) < headerLen {
+			return 0, nil, fmt.Errorf("%w: truncated header", ErrInvalidFrame)
+		}
+		length = binary.BigEndian.Uint64(frame[2:10])
+	}
+	masked := frame[1]&0x80 != 0
+	if masked {
+		headerLen += 4
+	}
+	if uint64(len(frame)) < uint64(headerLen) || uint64(len(frame)-headerLen) != length {
+		return 0, nil, fmt.Errorf("%w: payload length does not match frame length", ErrInvalidFrame)
+	}
+	payload := make([]byte, length)
+	copy(payload, frame[headerLen:])
+	if masked {
+		applyMask(payload, frame[headerLen-4:headerLen])
+	}
+	return int(frame[0] & 0x0F), payload, nil
+}
+
+// Mask or unmask the payload in place with the provided mask key.
+func applyMask(payload []byte, maskKey []byte) {
+	for i := range payload {
+		payload[i] ^= maskKey[i%4]
+	}
+}
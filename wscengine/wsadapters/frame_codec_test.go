@@ -0,0 +1,50 @@
+package wsadapters
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test frames encoded by the default codec are decoded back, masked or not.
+func TestDefaultFrameCodecRoundTrip(t *testing.T) {
+	codec := DefaultFrameCodec{}
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		for _, mask := range []bool{true, false} {
+			payload := bytes.Repeat([]byte("x"), size)
+			frame, err := codec.Encode(1, payload, mask)
+			require.NoError(t, err)
+			require.Equal(t, byte(0x81), frame[0])
+			require.Equal(t, mask, frame[1]&0x80 != 0)
+			msgType, decoded, err := codec.Decode(frame)
+			require.NoError(t, err)
+			require.Equal(t, 1, msgType)
+			require.Equal(t, payload, decoded)
+		}
+	}
+	// Unmasked frames contain the payload as is
+	frame, err := codec.Encode(2, []byte("hi"), false)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x82, 2, 'h', 'i'}, frame)
+}
+
+// Test invalid frames are rejected.
+func TestDefaultFrameCodecInvalidFrames(t *testing.T) {
+	codec := DefaultFrameCodec{}
+	_, err := codec.Encode(16, nil, true)
+	require.ErrorIs(t, err, ErrInvalidFrame)
+	_, err = codec.Encode(-1, nil, true)
+	require.ErrorIs(t, err, ErrInvalidFrame)
+	for _, frame := range [][]byte{
+		{0x82},
+		{0x82, 126, 0},
+		{0x82, 127, 0, 0},
+		{0x82, 0x80 | 2, 1, 2},
+		{0x82, 2, 'h'},
+		{0x82, 2, 'h', 'i', '!'},
+	} {
+		_, _, err := codec.Decode(frame)
+		require.ErrorIs(t, err, ErrInvalidFrame, "frame %v", frame)
+	}
+}
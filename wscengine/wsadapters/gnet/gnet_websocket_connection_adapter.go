@@ -0,0 +1,1004 @@
+// Package which contains a WebsocketConnectionAdapterInterface implementation for gnet library
+// (https://github.com/panjf2000/gnet), an event-loop based networking library for ultra high
+// throughput scenarios.
+//
+// gnet does not provide a websocket implementation: the adapter performs the websocket handshake
+// and encodes/decodes websocket frames (RFC 6455) itself. A custom wsadapters.FrameCodec can be
+// provided with WithFrameCodec.
+//
+// # Event loop
+//
+// gnet event handlers run on the event loop goroutine which owns the connection and must never
+// block. The adapter decodes frames in the OnTraffic handler, on the event loop goroutine bound
+// to the connection, and hands complete messages over to Read through an in-memory queue. Write
+// and Close use the concurrency-safe gnet methods (AsyncWrite, Close) so they can be called from
+// any goroutine. Ping returns ErrBlockingOperationForbidden as waiting for a pong is a blocking
+// operation which is not allowed by the event loop model.
+//
+// Secured connections (wss) are not supported as gnet does not support TLS.
+package gnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	gnetv2 "github.com/panjf2000/gnet/v2"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Error returned by Ping: blocking operations are not allowed with gnet event loops.
+var ErrBlockingOperationForbidden = errors.New("blocking operations are forbidden with gnet event loops")
+
+const (
+	// GUID used to compute the Sec-WebSocket-Accept header (RFC 6455 section 1.3)
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// Max. size of a received message. Larger messages cause the connection to be closed with 1009.
+	maxMessageSize = 32 * 1024 * 1024
+	// Max. size of the handshake response headers
+	maxHandshakeSize = 64 * 1024
+	// Max. duration Close waits for the server close message before dropping the connection
+	closeHandshakeTimeout = 5 * time.Second
+)
+
+// Websocket frame opcodes (RFC 6455 section 5.2)
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// Adapter for gnet library
+type GnetWebsocketConnectionAdapter struct {
+	// gnet client which runs the event loops
+	client *gnetv2.Client
+	// Headers to use when opening a connection
+	requestHeader http.Header
+	// Active session - nil if there is no active connection
+	session *gnetSession
+	// Codec used to encode and decode frames of new connections
+	codec wsadapters.FrameCodec
+	// Handler called with extension frames of new connections - nil if none
+	extensionHandler wsadapters.ExtensionFrameHandler
+	// Internal mutex
+	mu sync.Mutex
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsadapters.PropagationMode
+}
+
+// # Description
+//
+// Factory which creates a new GnetWebsocketConnectionAdapter and starts the gnet client event
+// loops. Call Stop to stop the event loops once the adapter is not used anymore.
+//
+// # Inputs
+//
+//   - requestHeader: Headers which will be used during Dial to specify the origin (Origin),
+//     subprotocols (Sec-WebSocket-Protocol) and cookies (Cookie). Can be nil.
+//   - opts: Optional gnet options used to create the client (number of event loops, ...).
+//
+// # Returns
+//
+// New GnetWebsocketConnectionAdapter or an error if the gnet client could not be started.
+func NewGnetWebsocketConnectionAdapter(requestHeader http.Header, opts ...gnetv2.Option) (*GnetWebsocketConnectionAdapter, error) {
+	client, err := gnetv2.NewClient(&gnetEventHandler{}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gnet client: %w", err)
+	}
+	err = client.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start gnet client: %w", err)
+	}
+	return &GnetWebsocketConnectionAdapter{
+		client:        client,
+		requestHeader: requestHeader,
+		session:       nil,
+		codec:         wsadapters.DefaultFrameCodec{},
+		mu:            sync.Mutex{},
+	}, nil
+}
+
+// # Description
+//
+// Set the codec used to encode sent frames and decode received final frames. Fragmented messages
+// are still reassembled by the adapter. The codec is used by connections opened after the call.
+//
+// This is an advanced option for users who need an optimized frame codec.
+//
+// # Inputs
+//
+//   - codec: Frame codec to use. If nil, wsadapters.DefaultFrameCodec is used.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *GnetWebsocketConnectionAdapter) WithFrameCodec(codec wsadapters.FrameCodec) *GnetWebsocketConnectionAdapter {
+	if codec == nil {
+		codec = wsadapters.DefaultFrameCodec{}
+	}
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.codec = codec
+	return adapter
+}
+
+// # Description
+//
+// Set the handler called with received frames which have non-zero RSV bits, before they are
+// processed as usual. If the handler returns an error, the frame is dropped and the error is
+// returned by Read. The handler is used by connections opened after the call and is called on the
+// gnet event loop goroutine: it must not block.
+//
+// By default, RSV bits are ignored.
+//
+// # Inputs
+//
+//   - handler: Extension frame handler to use. If nil, RSV bits are ignored.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *GnetWebsocketConnectionAdapter) WithExtensionFrameHandler(handler wsadapters.ExtensionFrameHandler) *GnetWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.extensionHandler = handler
+	return adapter
+}
+
+// # Description
+//
+// Stop the gnet client event loops. Active connection is dropped.
+func (adapter *GnetWebsocketConnectionAdapter) Stop() error {
+	return adapter.client.Stop()
+}
+
+// # Description
+//
+// Dial opens a connection to the websocket server and performs a WebSocket handshake.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - target: Target server URL. Only ws scheme is supported.
+//
+// # Returns
+//
+// The server response to websocket handshake or an error if any.
+func (adapter *GnetWebsocketConnectionAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return nil, ctx.Err()
+	default:
+		// Lock internal mutex before accessing internal state
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if adapter.session != nil {
+			// Return error in case a connection has already been set
+			return nil, wsadapters.ErrAlreadyConnected
+		}
+		if target.Scheme != "ws" {
+			return nil, fmt.Errorf("unsupported scheme %q: only ws is supported", target.Scheme)
+		}
+		// Use the dial host for Host header if target host has been resolved
+		host := target.Host
+		if dialHost, ok := wsadapters.DialHostFromContext(ctx); ok {
+			host = dialHost
+		}
+		session, err := newGnetSession(target, host, adapter.requestHeader, adapter.codec, adapter.extensionHandler)
+		if err != nil {
+			return nil, err
+		}
+		// Open TCP connection and hand it over to the gnet event loops - OnOpen sends the
+		// handshake request
+		addr := target.Host
+		if target.Port() == "" {
+			addr = net.JoinHostPort(target.Hostname(), "80")
+		}
+		netConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := adapter.client.EnrollContext(netConn, session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enroll connection in gnet event loop: %w", err)
+		}
+		session.conn = conn
+		// Wait for the handshake response
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case result := <-session.handshake:
+			if result.err != nil {
+				conn.Close()
+				return result.resp, result.err
+			}
+			// Persist session internally and return
+			session.subprotocol = result.resp.Header.Get("Sec-WebSocket-Protocol")
+			adapter.session = session
+			return result.resp, nil
+		}
+	}
+}
+
+// # Description
+//
+// Send a close message with the provided status code and an optional close reason and drop
+// the websocket connection. The method waits for the server close message for at most 5 seconds
+// or until the context is done.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//   - code: Status code to use in close message
+//   - reason: Optional reason joined in close message. Can be empty.
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: server unreachable, connection already closed, ...
+func (adapter *GnetWebsocketConnectionAdapter) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	session := adapter.session
+	// Void connection in any case
+	adapter.session = nil
+	adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if session == nil {
+		return fmt.Errorf("close failed: %w", wsadapters.ErrNotConnected)
+	}
+	// Send close message unless the connection is already closed
+	select {
+	case <-session.closed:
+		return fmt.Errorf("failed to close websocket: %w", net.ErrClosed)
+	default:
+	}
+	session.closeSent.Store(true)
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	frame, err := session.codec.Encode(int(opClose), payload, true)
+	if err == nil {
+		err = session.conn.AsyncWrite(frame, nil)
+	}
+	if err != nil {
+		session.conn.Close()
+		return fmt.Errorf("failed to send close message: %w", err)
+	}
+	// Wait for the server close message before dropping the connection
+	timer := time.NewTimer(closeHandshakeTimeout)
+	defer timer.Stop()
+	select {
+	case <-session.closed:
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	session.conn.Close()
+	return nil
+}
+
+// # Description
+//
+// Close the underlying network connection without sending a close message and drop the
+// websocket connection. The server sees an abnormal closure (1006).
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (adapter *GnetWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	session := adapter.session
+	// Void connection in any case
+	adapter.session = nil
+	adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if session == nil {
+		return fmt.Errorf("drop failed: %w", wsadapters.ErrNotConnected)
+	}
+	// Close the network connection
+	return session.conn.Close()
+}
+
+// # Description
+//
+// Ping always returns ErrBlockingOperationForbidden: waiting for a pong is a blocking operation
+// which is not allowed by the gnet event loop model. Pings sent by the server are answered by the
+// adapter.
+//
+// # Returns
+//
+// The context error if the context is done or ErrBlockingOperationForbidden.
+func (adapter *GnetWebsocketConnectionAdapter) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		return ErrBlockingOperationForbidden
+	}
+}
+
+// # Description
+//
+// Read a single message from the websocket server. Read blocks until a message has been decoded
+// by the event loop, until connection is closed or until the context is done.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GnetWebsocketConnectionAdapter) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return -1, nil, ctx.Err()
+	default:
+		// Lock internal mutex before and store current session reference in local variable to
+		// allow other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		session := adapter.session
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if session == nil {
+			return -1, nil, fmt.Errorf("read failed: %w", wsadapters.ErrNotConnected)
+		}
+		for {
+			msg, ok, err := session.dequeue()
+			if ok {
+				if msg.err != nil {
+					return -1, nil, msg.err
+				}
+				return msg.msgType, msg.payload, nil
+			}
+			if err != nil {
+				// Drop the closed connection so a new one can be established - unless it has
+				// already been replaced
+				adapter.mu.Lock()
+				if adapter.session == session {
+					adapter.session = nil
+				}
+				adapter.mu.Unlock()
+				return -1, nil, err
+			}
+			// Wait for a new message or for connection closure
+			select {
+			case <-ctx.Done():
+				return -1, nil, ctx.Err()
+			case <-session.notify:
+			case <-session.closed:
+			}
+		}
+	}
+}
+
+// # Description
+//
+// The adapter does not stream messages: ReadStream reads the whole message with Read and returns
+// a reader on the message content.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+// The message type and a reader on the message content or the error returned by Read.
+func (adapter *GnetWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, msg, err := adapter.Read(ctx)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
+// # Description
+//
+// Write a single message to the websocket server. Write blocks until the event loop has written
+// the message to the connection or until an error occurs: context timeout, cancellation,
+// connection closed, ....
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GnetWebsocketConnectionAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock internal mutex before and store current session reference in local variable to
+		// allow other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		session := adapter.session
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if session == nil {
+			return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+		}
+		opcode := opBinary
+		if msgType == wsadapters.Text {
+			opcode = opText
+		}
+		frame, err := session.codec.Encode(int(opcode), msg, true)
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		// Hand the frame over to the event loop and wait until it has been written
+		written := make(chan error, 1)
+		err = session.conn.AsyncWrite(frame, func(c gnetv2.Conn, err error) error {
+			written <- err
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-written:
+			return err
+		case <-session.closed:
+			return session.closeErr
+		}
+	}
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode of the adapter
+// and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *GnetWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	adapter.mu.Lock()
+	mode := adapter.propagationMode
+	adapter.mu.Unlock()
+	msgType, msg, err := wsadapters.InjectTraceContext(ctx, mode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in outgoing
+// messages. Defaults to wsadapters.None: messages are written as is.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *GnetWebsocketConnectionAdapter) WithPropagationMode(mode wsadapters.PropagationMode) *GnetWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// # Description
+//
+// The adapter does not stream messages: the returned writer buffers the message content and
+// writes the whole message with Write when it is closed.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose. Provided to Write when the writer is closed.
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+// A writer for the message content. Close returns the error returned by Write.
+func (adapter *GnetWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return wsadapters.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
+// # Description
+//
+// Return the underlying gnet connection (gnet.Conn) if any. Returned value has to be type
+// asserted. Most gnet.Conn methods must only be called from the event loop.
+//
+// # Returns
+//
+// The underlying gnet connection if any. Returned value has to be type asserted.
+func (adapter *GnetWebsocketConnectionAdapter) GetUnderlyingWebsocketConnection() any {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Return underlying connection
+	if adapter.session == nil {
+		return nil
+	}
+	return adapter.session.conn
+}
+
+// # Description
+//
+// Return the subprotocol selected by the server for the current connection. Subprotocols are
+// proposed to the server with the Sec-WebSocket-Protocol request header.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if the server has not selected any subprotocol
+// or if no connection is up.
+func (adapter *GnetWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.session == nil {
+		return ""
+	}
+	return adapter.session.subprotocol
+}
+
+// # Description
+//
+// The adapter does not track its read throughput.
+//
+// # Returns
+//
+// A zero value.
+func (adapter *GnetWebsocketConnectionAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return wsadapters.AdapterReadStats{}
+}
+
+/*************************************************************************************************/
+/* EVENT LOOP                                                                                    */
+/*************************************************************************************************/
+
+// gnet event handler which delegates events to the session bound to the connection.
+type gnetEventHandler struct {
+	gnetv2.BuiltinEventEngine
+}
+
+// Send the handshake request once the connection is bound to an event loop.
+func (handler *gnetEventHandler) OnOpen(c gnetv2.Conn) ([]byte, gnetv2.Action) {
+	session, ok := c.Context().(*gnetSession)
+	if !ok {
+		return nil, gnetv2.Close
+	}
+	return session.handshakeRequest, gnetv2.None
+}
+
+// Decode received data on the event loop goroutine.
+func (handler *gnetEventHandler) OnTraffic(c gnetv2.Conn) gnetv2.Action {
+	session, ok := c.Context().(*gnetSession)
+	if !ok {
+		return gnetv2.Close
+	}
+	return session.onTraffic(c)
+}
+
+// Signal connection closure.
+func (handler *gnetEventHandler) OnClose(c gnetv2.Conn, err error) gnetv2.Action {
+	if session, ok := c.Context().(*gnetSession); ok {
+		session.onClose(err)
+	}
+	return gnetv2.None
+}
+
+// Result of the websocket handshake
+type handshakeResult struct {
+	resp *http.Response
+	err  error
+}
+
+// A received message or a read error
+type message struct {
+	msgType wsadapters.MessageType
+	payload []byte
+	err     error
+}
+
+// State of a websocket connection.
+type gnetSession struct {
+	// gnet connection - set once the connection is enrolled
+	conn gnetv2.Conn
+	// Handshake request sent when connection opens
+	handshakeRequest []byte
+	// Request used to parse the handshake response
+	request *http.Request
+	// Expected Sec-WebSocket-Accept header value
+	expectedAccept string
+	// Channel used to deliver the handshake result - capacity 1
+	handshake chan handshakeResult
+	// Used to deliver the handshake result once
+	handshakeOnce sync.Once
+	// Codec used to encode and decode frames
+	codec wsadapters.FrameCodec
+	// Handler called with extension frames - nil if none
+	extensionHandler wsadapters.ExtensionFrameHandler
+	// Whether the handshake has completed - event loop only
+	upgraded bool
+	// Subprotocol selected by the server - set once the handshake has completed
+	subprotocol string
+	// Opcode and content of the fragmented message being received - event loop only
+	fragmentOpcode byte
+	fragments      []byte
+	// Whether a close message has been sent to the server
+	closeSent atomic.Bool
+	// Mutex which protects queue
+	mu sync.Mutex
+	// Received messages waiting to be read
+	queue []message
+	// Channel used to notify readers a message has been queued - capacity 1
+	notify chan struct{}
+	// Channel closed once the connection is closed
+	closed chan struct{}
+	// Error returned once the connection is closed - set before closed is closed
+	closeErr error
+	// Close error built from the close message received from the server if any - event loop only
+	receivedCloseErr *wsadapters.WebsocketCloseError
+	// Used to close the session once
+	closeOnce sync.Once
+}
+
+// Create a new session and build the handshake request.
+func newGnetSession(
+	target url.URL,
+	host string,
+	requestHeader http.Header,
+	codec wsadapters.FrameCodec,
+	extensionHandler wsadapters.ExtensionFrameHandler) (*gnetSession, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate websocket key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	header := requestHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Key", key)
+	header.Set("Sec-WebSocket-Version", "13")
+	target.Scheme = "http"
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &target,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       host,
+	}
+	buf := &bytes.Buffer{}
+	err = req.Write(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build handshake request: %w", err)
+	}
+	return &gnetSession{
+		handshakeRequest: buf.Bytes(),
+		request:          req,
+		expectedAccept:   computeAccept(key),
+		codec:            codec,
+		extensionHandler: extensionHandler,
+		handshake:        make(chan handshakeResult, 1),
+		notify:           make(chan struct{}, 1),
+		closed:           make(chan struct{}),
+	}, nil
+}
+
+// Decode the handshake response and the received frames. Called on the event loop goroutine.
+func (session *gnetSession) onTraffic(c gnetv2.Conn) gnetv2.Action {
+	if !session.upgraded {
+		buffered, _ := c.Peek(c.InboundBuffered())
+		end := bytes.Index(buffered, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(buffered) > maxHandshakeSize {
+				session.deliverHandshake(nil, fmt.Errorf("handshake response exceeds %d bytes", maxHandshakeSize))
+				return gnetv2.Close
+			}
+			// Wait for more data
+			return gnetv2.None
+		}
+		headers := append([]byte(nil), buffered[:end+4]...)
+		c.Discard(end + 4)
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(headers)), session.request)
+		if err != nil {
+			session.deliverHandshake(nil, fmt.Errorf("failed to parse handshake response: %w", err))
+			return gnetv2.Close
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != session.expectedAccept {
+			session.deliverHandshake(resp, fmt.Errorf("websocket handshake failed with status %s", resp.Status))
+			return gnetv2.Close
+		}
+		session.upgraded = true
+		session.deliverHandshake(resp, nil)
+	}
+	// Decode all complete frames
+	for {
+		buffered := c.InboundBuffered()
+		header, err := c.Peek(min(buffered, 14))
+		if err != nil {
+			return gnetv2.None
+		}
+		frameLen, headerLen, ok := frameSize(header)
+		if !ok {
+			// Wait for more data
+			return gnetv2.None
+		}
+		if frameLen > maxMessageSize {
+			return session.fail(c, wsadapters.MessageTooBig, "message too big")
+		}
+		if buffered < headerLen+frameLen {
+			// Wait for more data
+			return gnetv2.None
+		}
+		frame, _ := c.Next(headerLen + frameLen)
+		if frame[0]&0x70 != 0 && session.extensionHandler != nil {
+			// Hand over extension frames to the extension handler first
+			payload := append([]byte(nil), frame[headerLen:]...)
+			if frame[1]&0x80 != 0 {
+				applyMask(payload, frame[headerLen-4:headerLen])
+			}
+			err := session.extensionHandler(frame[0]&0x0F, payload)
+			if err != nil {
+				// Drop the frame and surface the error as a read error
+				session.enqueueError(fmt.Errorf("extension frame handler failed: %w", err))
+				continue
+			}
+		}
+		var action gnetv2.Action
+		if frame[0]&0x80 != 0 && frame[0]&0x0F != opContinuation {
+			// Decode final frames with the frame codec
+			msgType, payload, err := session.codec.Decode(frame)
+			if err != nil {
+				return session.fail(c, wsadapters.ProtocolError, "invalid frame")
+			}
+			action = session.onFrame(c, 0x80|byte(msgType&0x0F), payload)
+		} else {
+			// Payload is only valid until the next call to the event loop buffer - copy it
+			payload := append([]byte(nil), frame[headerLen:]...)
+			if frame[1]&0x80 != 0 {
+				// Server frames should not be masked but unmask them anyway
+				applyMask(payload, frame[headerLen-4:headerLen])
+			}
+			action = session.onFrame(c, frame[0], payload)
+		}
+		if action != gnetv2.None {
+			return action
+		}
+	}
+}
+
+// Process a received frame with an unmasked payload. Called on the event loop goroutine.
+func (session *gnetSession) onFrame(c gnetv2.Conn, b0 byte, payload []byte) gnetv2.Action {
+	fin := b0&0x80 != 0
+	opcode := b0 & 0x0F
+	switch opcode {
+	case opText, opBinary:
+		if session.fragmentOpcode != 0 {
+			return session.fail(c, wsadapters.ProtocolError, "unexpected new message in fragmented message")
+		}
+		if fin {
+			session.enqueue(opcode, payload)
+		} else {
+			session.fragmentOpcode = opcode
+			session.fragments = payload
+		}
+	case opContinuation:
+		if session.fragmentOpcode == 0 {
+			return session.fail(c, wsadapters.ProtocolError, "unexpected continuation frame")
+		}
+		if len(session.fragments)+len(payload) > maxMessageSize {
+			return session.fail(c, wsadapters.MessageTooBig, "message too big")
+		}
+		session.fragments = append(session.fragments, payload...)
+		if fin {
+			session.enqueue(session.fragmentOpcode, session.fragments)
+			session.fragmentOpcode = 0
+			session.fragments = nil
+		}
+	case opPing:
+		// Answer with a pong
+		session.writeFrame(c, opPong, payload)
+	case opPong:
+		// Pings are never sent by the adapter - Nothing to do
+	case opClose:
+		closeErr := wsadapters.WebsocketCloseError{Code: wsadapters.NoStatusReceived}
+		if len(payload) >= 2 {
+			closeErr.Code = wsadapters.StatusCode(binary.BigEndian.Uint16(payload))
+			closeErr.Reason = string(payload[2:])
+		}
+		session.receivedCloseErr = &closeErr
+		if !session.closeSent.Swap(true) {
+			// Echo the close status code to complete the close handshake
+			session.writeFrame(c, opClose, payload[:min(len(payload), 2)])
+		}
+		return gnetv2.Close
+	default:
+		return session.fail(c, wsadapters.ProtocolError, "unknown opcode")
+	}
+	return gnetv2.None
+}
+
+// Send a close message with the provided status code and close the connection. Called on the
+// event loop goroutine.
+func (session *gnetSession) fail(c gnetv2.Conn, code wsadapters.StatusCode, reason string) gnetv2.Action {
+	session.receivedCloseErr = &wsadapters.WebsocketCloseError{Code: code, Reason: reason}
+	if !session.closeSent.Swap(true) {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		session.writeFrame(c, opClose, append(payload, reason...))
+	}
+	return gnetv2.Close
+}
+
+// Encode a frame and write it to the connection. Errors are ignored as write failures are signaled
+// by the connection closure. Called on the event loop goroutine.
+func (session *gnetSession) writeFrame(c gnetv2.Conn, opcode byte, payload []byte) {
+	frame, err := session.codec.Encode(int(opcode), payload, true)
+	if err == nil {
+		c.Write(frame)
+	}
+}
+
+// Mark the session as closed. Called on the event loop goroutine.
+func (session *gnetSession) onClose(err error) {
+	session.deliverHandshake(nil, fmt.Errorf("connection closed during handshake: %w", errors.Join(net.ErrClosed, err)))
+	session.closeOnce.Do(func() {
+		if session.receivedCloseErr != nil {
+			session.closeErr = *session.receivedCloseErr
+		} else {
+			session.closeErr = wsadapters.WebsocketCloseError{
+				Code:   wsadapters.AbnormalClosure,
+				Reason: "websocket connection abnormal closure",
+				Err:    err,
+			}
+		}
+		close(session.closed)
+	})
+}
+
+// Deliver the handshake result if it has not been delivered yet.
+func (session *gnetSession) deliverHandshake(resp *http.Response, err error) {
+	session.handshakeOnce.Do(func() {
+		session.handshake <- handshakeResult{resp: resp, err: err}
+	})
+}
+
+// Queue a received message and notify readers.
+func (session *gnetSession) enqueue(opcode byte, payload []byte) {
+	msgType := wsadapters.Binary
+	if opcode == opText {
+		msgType = wsadapters.Text
+	}
+	session.push(message{msgType: msgType, payload: payload})
+}
+
+// Queue a read error and notify readers.
+func (session *gnetSession) enqueueError(err error) {
+	session.push(message{err: err})
+}
+
+// Queue an item and notify readers.
+func (session *gnetSession) push(msg message) {
+	session.mu.Lock()
+	session.queue = append(session.queue, msg)
+	session.mu.Unlock()
+	select {
+	case session.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Return the next queued message if any or the close error if the connection is closed and all
+// messages have been read.
+func (session *gnetSession) dequeue() (message, bool, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if len(session.queue) > 0 {
+		msg := session.queue[0]
+		session.queue = session.queue[1:]
+		if len(session.queue) > 0 {
+			// Let other readers know there are remaining messages
+			select {
+			case session.notify <- struct{}{}:
+			default:
+			}
+		}
+		return msg, true, nil
+	}
+	select {
+	case <-session.closed:
+		return message{}, false, session.closeErr
+	default:
+		return message{}, false, nil
+	}
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Compute the expected Sec-WebSocket-Accept header value for the provided key.
+func computeAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// # Description
+//
+// Decode the frame header size and payload length from the beginning of a frame.
+//
+// # Returns
+//
+// The payload length, the header length (including the mask key) and true or false if more data
+// is needed to decode the header.
+func frameSize(header []byte) (int, int, bool) {
+	if len(header) < 2 {
+		return 0, 0, false
+	}
+	headerLen := 2
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		headerLen += 2
+		if len(header) < headerLen {
+			return 0, 0, false
+		}
+		length = int(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		headerLen += 8
+		if len(header) < headerLen {
+			return 0, 0, false
+		}
+		length64 := binary.BigEndian.Uint64(header[2:10])
+		if length64 > maxMessageSize {
+			length64 = maxMessageSize + 1
+		}
+		length = int(length64)
+	}
+	if header[1]&0x80 != 0 {
+		headerLen += 4
+	}
+	return length, headerLen, true
+}
+
+// Mask or unmask the payload in place with the provided mask key.
+func applyMask(payload []byte, maskKey []byte) {
+	for i := range payload {
+		payload[i] ^= maskKey[i%4]
+	}
+}
@@ -0,0 +1,340 @@
+package gnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+	gnetv2 "github.com/panjf2000/gnet/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+type GnetWebsocketConnectionAdapterTestSuite struct {
+	suite.Suite
+	// Websocket server address
+	srvUrl *url.URL
+	// Websocket test server which echoes messages
+	srv *httptest.Server
+	// Adapter under test
+	adapter *GnetWebsocketConnectionAdapter
+}
+
+// Run GnetWebsocketConnectionAdapterTestSuite test suite
+func TestGnetWebsocketConnectionAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(GnetWebsocketConnectionAdapterTestSuite))
+}
+
+// GnetWebsocketConnectionAdapterTestSuite - Before all tests
+func (suite *GnetWebsocketConnectionAdapterTestSuite) SetupSuite() {
+	upgrader := websocket.Upgrader{}
+	suite.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Host": []string{r.Host}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		switch r.URL.Path {
+		case "/close":
+			// Close the connection from the server side
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"))
+			conn.ReadMessage()
+			return
+		case "/ping":
+			// Send a ping and forward the pong payload as a text message
+			pong := make(chan string, 1)
+			conn.SetPongHandler(func(appData string) error {
+				pong <- appData
+				return nil
+			})
+			conn.WriteControl(websocket.PingMessage, []byte("heartbeat"), time.Now().Add(time.Second))
+			go conn.ReadMessage()
+			select {
+			case data := <-pong:
+				conn.WriteMessage(websocket.TextMessage, []byte(data))
+			case <-time.After(5 * time.Second):
+			}
+			return
+		case "/extension":
+			// Send a raw text frame with the RSV1 bit set followed by a regular text frame
+			conn.UnderlyingConn().Write([]byte{0xC1, 0x03, 'e', 'x', 't', 0x81, 0x05, 'a', 'f', 't', 'e', 'r'})
+			conn.ReadMessage()
+			return
+		}
+		// Echo messages until connection is closed
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	u, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	suite.srvUrl = u
+	suite.adapter, err = NewGnetWebsocketConnectionAdapter(nil, gnetv2.WithMulticore(true))
+	require.NoError(suite.T(), err)
+}
+
+// GnetWebsocketConnectionAdapterTestSuite - After all tests
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TearDownSuite() {
+	suite.adapter.Stop()
+	suite.srv.Close()
+}
+
+// GnetWebsocketConnectionAdapterTestSuite - After each test
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TearDownTest() {
+	// Drop any connection left by the test
+	suite.adapter.Close(context.Background(), wsadapters.NormalClosure, "")
+}
+
+/*************************************************************************************************/
+/* TESTS                                                                                         */
+/*************************************************************************************************/
+
+// Check adapter fully implements interface.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestInterfaceCompliance() {
+	var impl interface{} = suite.adapter
+	_, ok := impl.(wsadapters.WebsocketConnectionAdapterInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test Dial, Write, Read, Close and GetUnderlyingWebsocketConnection against the test server.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestEcho() {
+	adapter := suite.adapter
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Connect to server and check a second Dial fails
+	resp, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+	_, ok := adapter.GetUnderlyingWebsocketConnection().(gnetv2.Conn)
+	require.True(suite.T(), ok)
+	_, err = adapter.Dial(ctx, *suite.srvUrl)
+	require.Error(suite.T(), err)
+	// Echo text and binary messages of every payload length encoding
+	messages := [][]byte{[]byte("hello"), bytes.Repeat([]byte("a"), 1000), bytes.Repeat([]byte("b"), 100000)}
+	for _, msgType := range []wsadapters.MessageType{wsadapters.Text, wsadapters.Binary} {
+		for _, sent := range messages {
+			require.NoError(suite.T(), adapter.Write(ctx, msgType, sent))
+			readType, msg, err := adapter.Read(ctx)
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), msgType, readType)
+			require.Equal(suite.T(), sent, msg)
+		}
+	}
+	// Close connection and check a second Close fails
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	require.Error(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test the dial host provided through the context is used as Host header.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestDialWithDialHost() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := suite.adapter.Dial(wsadapters.ContextWithDialHost(ctx, "example.com"), *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "example.com", resp.Header.Get("X-Host"))
+}
+
+// Test Ping is forbidden and server pings are answered.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestPing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target := *suite.srvUrl
+	target.Path = "/ping"
+	_, err := suite.adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	require.ErrorIs(suite.T(), suite.adapter.Ping(ctx), ErrBlockingOperationForbidden)
+	// Server forwards the pong payload
+	msgType, msg, err := suite.adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), []byte("heartbeat"), msg)
+}
+
+// Test Read returns a WebsocketCloseError when the server closes the connection.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestReadWhenServerCloses() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target := *suite.srvUrl
+	target.Path = "/close"
+	_, err := suite.adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	_, _, err = suite.adapter.Read(ctx)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.GoingAway, closeErr.Code)
+	require.Equal(suite.T(), "server shutdown", closeErr.Reason)
+	// Connection is dropped so a new one can be established
+	require.Nil(suite.T(), suite.adapter.GetUnderlyingWebsocketConnection())
+}
+
+// Test Dial fails when the server rejects the handshake or when the scheme is not supported.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestDialFailures() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target := *suite.srvUrl
+	target.Path = "/forbidden"
+	resp, err := suite.adapter.Dial(ctx, target)
+	require.Error(suite.T(), err)
+	require.NotNil(suite.T(), resp)
+	require.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	require.Nil(suite.T(), suite.adapter.GetUnderlyingWebsocketConnection())
+	target.Scheme = "wss"
+	_, err = suite.adapter.Dial(ctx, target)
+	require.Error(suite.T(), err)
+}
+
+// Test adapter methods when called without an active connection or with a canceled context.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestMethodsWithoutActiveConnection() {
+	adapter := suite.adapter
+	_, _, err := adapter.Read(context.Background())
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), adapter.Write(context.Background(), wsadapters.Text, []byte("hello")))
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapter.Dial(canceledCtx, *suite.srvUrl)
+	require.ErrorIs(suite.T(), err, context.Canceled)
+	require.ErrorIs(suite.T(), adapter.Ping(canceledCtx), context.Canceled)
+}
+
+// Test a custom frame codec is used to encode and decode frames.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestWithFrameCodec() {
+	codec := &countingFrameCodec{}
+	adapter, err := NewGnetWebsocketConnectionAdapter(nil)
+	require.NoError(suite.T(), err)
+	defer adapter.Stop()
+	require.Same(suite.T(), adapter, adapter.WithFrameCodec(codec))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("hello")))
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	// Message and close frames have been encoded with the codec, echo decoded with the codec
+	require.Equal(suite.T(), int32(2), codec.encoded.Load())
+	require.GreaterOrEqual(suite.T(), codec.decoded.Load(), int32(1))
+	// Nil codec restores the default codec
+	adapter.WithFrameCodec(nil)
+	require.Equal(suite.T(), wsadapters.DefaultFrameCodec{}, adapter.codec)
+}
+
+// Test frames with RSV bits are handed over to the extension frame handler before they are
+// processed and handler errors are returned by Read.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestWithExtensionFrameHandler() {
+	adapter, err := NewGnetWebsocketConnectionAdapter(nil)
+	require.NoError(suite.T(), err)
+	defer adapter.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target := *suite.srvUrl
+	target.Path = "/extension"
+	// Without handler, RSV bits are ignored
+	_, err = adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	_, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("ext"), msg)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	// Handler is called before the frame is processed
+	handled := make(chan []byte, 2)
+	var handlerErr error
+	require.Same(suite.T(), adapter, adapter.WithExtensionFrameHandler(func(opcode byte, payload []byte) error {
+		require.Equal(suite.T(), opText, opcode)
+		handled <- payload
+		return handlerErr
+	}))
+	_, err = adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	_, msg, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("ext"), msg)
+	require.Equal(suite.T(), []byte("ext"), <-handled)
+	_, msg, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("after"), msg)
+	require.Empty(suite.T(), handled)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	// Handler errors are read errors - the frame is dropped and the connection remains open
+	handlerErr = errors.New("unsupported extension")
+	_, err = adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, handlerErr)
+	_, msg, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []byte("after"), msg)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test frame encoding and decoding utilities.
+func (suite *GnetWebsocketConnectionAdapterTestSuite) TestFrameEncoding() {
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		payload := bytes.Repeat([]byte("x"), size)
+		frame, err := wsadapters.DefaultFrameCodec{}.Encode(int(opBinary), payload, true)
+		require.NoError(suite.T(), err)
+		length, headerLen, ok := frameSize(frame)
+		require.True(suite.T(), ok)
+		require.Equal(suite.T(), size, length)
+		require.Equal(suite.T(), len(frame), headerLen+length)
+		require.Equal(suite.T(), byte(0x80|opBinary), frame[0])
+		decoded := bytes.Clone(frame[headerLen:])
+		applyMask(decoded, frame[headerLen-4:headerLen])
+		require.Equal(suite.T(), payload, decoded)
+	}
+	// Incomplete headers
+	_, _, ok := frameSize([]byte{0x82})
+	require.False(suite.T(), ok)
+	_, _, ok = frameSize([]byte{0x82, 127, 0})
+	require.False(suite.T(), ok)
+	// Accept key example from RFC 6455
+	require.Equal(suite.T(), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", computeAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Frame codec which counts encoded and decoded frames and delegates to the default codec.
+type countingFrameCodec struct {
+	wsadapters.DefaultFrameCodec
+	encoded atomic.Int32
+	decoded atomic.Int32
+}
+
+func (codec *countingFrameCodec) Encode(msgType int, payload []byte, mask bool) ([]byte, error) {
+	codec.encoded.Add(1)
+	return codec.DefaultFrameCodec.Encode(msgType, payload, mask)
+}
+
+func (codec *countingFrameCodec) Decode(frame []byte) (int, []byte, error) {
+	codec.decoded.Add(1)
+	return codec.DefaultFrameCodec.Decode(frame)
+}
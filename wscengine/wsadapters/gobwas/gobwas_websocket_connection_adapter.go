@@ -0,0 +1,762 @@
+// Package which contains a WebsocketConnectionAdapterInterface implementation for gobwas/ws
+// library (https://github.com/gobwas/ws), a low level websocket library for high throughput
+// scenarios.
+//
+// Unlike gorilla/websocket, gobwas/ws lets callers choose where frame payloads are read: ReadInto
+// reads messages in a caller provided buffer so applications which process a large number of
+// small messages can reuse a single buffer instead of allocating a new []byte per message.
+package gobwas
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	wsconnadapter "github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Default size of the buffer used to read frames from the connection.
+const DefaultReadBufferSize = 4096
+
+// Maximum number of bytes of the handshake response body which are kept when Dial fails.
+const maxHandshakeResponseBodySize = 1024
+
+// Adapter for gobwas/ws library
+type GobwasWebsocketConnectionAdapter struct {
+	// Current websocket session - nil if no connection is up
+	session *gobwasSession
+	// Dial options to use when opening a connection
+	dialer ws.Dialer
+	// Size of the buffer used to read frames from the connection
+	bufferSize int
+	// Headers to use when opening a connection
+	requestHeader http.Header
+	// Internal mutex - also used to serialize writes
+	mu sync.Mutex
+	// Mutex used to serialize reads as wsutil.Reader is not goroutine safe
+	readMu sync.Mutex
+	// Buffer reused by Read to assemble messages before they are copied
+	readBuf []byte
+	// Internal channel of channels used to manage ping/pong
+	//
+	// The channel that is sent is used to wait for pong or an error.
+	pingRequests chan chan error
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsconnadapter.PropagationMode
+}
+
+// Internal state of a websocket connection
+type gobwasSession struct {
+	// Underlying network connection
+	conn net.Conn
+	// Reader used to read frames from the connection
+	reader *wsutil.Reader
+	// Flag set when a close frame has been sent to the server
+	closeSent atomic.Bool
+	// Subprotocol selected by the server during the handshake
+	subprotocol string
+}
+
+// # Description
+//
+// Factory which creates a new GobwasWebsocketConnectionAdapter.
+//
+// # Inputs
+//
+//   - dialer: Dialer to use when using Dial method. The zero value can be used. Header,
+//     ReadBufferSize, OnHeader and OnStatusError are managed by the adapter: request headers must
+//     be provided with requestHeader.
+//
+//   - bufferSize: Size of the buffer used to read frames from the connection. If 0 or negative,
+//     DefaultReadBufferSize is used.
+//
+//   - requestHeader: Headers which will be used during Dial to specify the origin (Origin),
+//     subprotocols (Sec-WebSocket-Protocol) and cookies (Cookie)
+//
+// # Returns
+//
+// New GobwasWebsocketConnectionAdapter
+func NewGobwasWebsocketConnectionAdapter(dialer ws.Dialer, bufferSize int, requestHeader http.Header) *GobwasWebsocketConnectionAdapter {
+	if bufferSize <= 0 {
+		// Use default buffer size
+		bufferSize = DefaultReadBufferSize
+	}
+	return &GobwasWebsocketConnectionAdapter{
+		session:       nil,
+		dialer:        dialer,
+		bufferSize:    bufferSize,
+		requestHeader: requestHeader,
+		mu:            sync.Mutex{},
+		readMu:        sync.Mutex{},
+		readBuf:       make([]byte, 0, bufferSize),
+		// Use a chan with capacity so ping requests can be recorded before sending ping message.
+		pingRequests: make(chan chan error, 10),
+	}
+}
+
+// # Description
+//
+// Dial opens a connection to the websocket server and performs a WebSocket handshake.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - target: Target server URL
+//
+// # Returns
+//
+// The server response to websocket handshake or an error if any. The response is also returned
+// when the server refuses the handshake.
+func (adapter *GobwasWebsocketConnectionAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return nil, ctx.Err()
+	default:
+		// Lock internal mutex before accessing internal state
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if adapter.session != nil {
+			// Return error in case a connection has already been set
+			return nil, wsconnadapter.ErrAlreadyConnected
+		}
+		// Build the response from the handshake response headers or from the refused handshake
+		res := &http.Response{
+			Status:     "101 Switching Protocols",
+			StatusCode: http.StatusSwitchingProtocols,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+		}
+		var refused *http.Response
+		dialer := adapter.dialer
+		dialer.ReadBufferSize = adapter.bufferSize
+		dialer.Header = ws.HandshakeHeaderHTTP(adapter.requestHeader)
+		dialer.OnHeader = func(key, value []byte) error {
+			res.Header.Add(string(key), string(value))
+			return nil
+		}
+		dialer.OnStatusError = func(status int, reason []byte, resp io.Reader) {
+			refused = readRefusedHandshake(resp)
+		}
+		// Use the dial host for Host header and TLS server name if target host has been resolved
+		if host, ok := wsconnadapter.DialHostFromContext(ctx); ok {
+			dialer = withDialHost(dialer, host)
+		}
+		// Open websocket connection
+		conn, br, hs, err := dialer.Dial(ctx, target.String())
+		if err != nil {
+			// Return the refused handshake response if any and the error
+			if refused != nil {
+				return refused, fmt.Errorf("websocket handshake failed: %w", err)
+			}
+			return nil, err
+		}
+		// Frames which have been received along with the handshake response are buffered in br
+		src := io.Reader(br)
+		if br == nil {
+			src = bufio.NewReaderSize(conn, adapter.bufferSize)
+		}
+		// Persist connection internally
+		adapter.session = &gobwasSession{
+			conn:        conn,
+			subprotocol: hs.Protocol,
+			reader: &wsutil.Reader{
+				Source: src,
+				State:  ws.StateClientSide,
+			},
+		}
+		adapter.session.reader.OnIntermediate = adapter.controlFrameHandler(adapter.session)
+		// Return
+		return res, nil
+	}
+}
+
+// # Description
+//
+// Send a close message with the provided status code and an optional close reason and drop
+// the websocket connection.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//   - code: Status code to use in close message
+//   - reason: Optional reason joined in close message. Can be empty.
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: server unreachable, connection already closed, ...
+func (adapter *GobwasWebsocketConnectionAdapter) Close(ctx context.Context, code wsconnadapter.StatusCode, reason string) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.session == nil {
+		return fmt.Errorf("close failed: %w", wsconnadapter.ErrNotConnected)
+	}
+	// Send close message - The connection is closed by Read when the server echoes the close
+	// message or when the deadline is exceeded.
+	session := adapter.session
+	session.closeSent.Store(true)
+	session.conn.SetWriteDeadline(time.Now().Add(60 * time.Second))
+	err := wsutil.WriteClientMessage(session.conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusCode(code), reason))
+	session.conn.SetWriteDeadline(time.Time{})
+	session.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	// Propagate close error to all pending Ping
+	propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
+		Code:   code,
+		Reason: reason,
+		Err:    fmt.Errorf("client closed the connection"),
+	})
+	// Void connection in any case
+	adapter.session = nil
+	// Return result
+	return err
+}
+
+// # Description
+//
+// Close the underlying network connection without sending a close message and drop the
+// websocket connection. The server sees an abnormal closure (1006).
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (adapter *GobwasWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.session == nil {
+		return fmt.Errorf("drop failed: %w", wsconnadapter.ErrNotConnected)
+	}
+	// Close the network connection
+	err := adapter.session.conn.Close()
+	// Propagate close error to all pending Ping
+	propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
+		Code:   wsconnadapter.AbnormalClosure,
+		Reason: "connection dropped",
+		Err:    fmt.Errorf("client dropped the connection"),
+	})
+	// Void connection in any case
+	adapter.session = nil
+	// Return result
+	return err
+}
+
+// # Description
+//
+// Send a ping message to the websocket server and block until a pong response is received, the
+// connection is closed, or the provided context is cancelled.
+//
+// A separate goroutine must continuously call the Read method to process messages from the server
+// so that pong responses from the server can be processed.
+//
+// # Inputs
+//
+//   - ctx: context used for tracing/timeout purpose.
+//
+// # Returns
+//
+// - nil in case of success: A Ping message has been sent to the server and a Pong has been received.
+// - error: connection is closed, context timeout/cancellation, ...
+func (adapter *GobwasWebsocketConnectionAdapter) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock internal mutex before and store current session reference in local variable to
+		// allow other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		session := adapter.session
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if session == nil {
+			return fmt.Errorf("%w: %w", wsconnadapter.ErrPingFailed, wsconnadapter.ErrNotConnected)
+		}
+		// Create channel to receive pong and send it on pingRequest channel
+		// It is OK because pingRequest is a channel with capacity
+		// pong channel must be a blocking channel
+		pong := make(chan error)
+		select {
+		case adapter.pingRequests <- pong:
+			// Do nothing
+		case <-ctx.Done():
+			// Handle cancellation in case pingRequest channel is full
+			return ctx.Err()
+		}
+		// Send Ping - Lock internal mutex as frames cannot be written concurrently
+		adapter.mu.Lock()
+		err := wsutil.WriteClientMessage(session.conn, ws.OpPing, nil)
+		adapter.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("%w: %w", wsconnadapter.ErrPingFailed, err)
+		}
+		// Wait for a pong or for ctx cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-pong:
+			// Return received notification (nil or error if ping/pong failed)
+			return err
+		}
+	}
+}
+
+// # Description
+//
+// Read a single message from the websocket server. Read blocks until a message is received
+// from the server, or until connection is closed.
+//
+// Read assembles messages in an internal buffer which is reused between calls and returns a copy
+// of the message. Use ReadInto to read messages in a caller provided buffer.
+//
+// Read will handle control frames from the server until a message is received:
+//   - Ping from server are answered with a Pong.
+//   - Close will result in a wsconnadapter.WebsocketCloseError for Read and all pending Ping.
+//   - Each pong message will be used to unlock one pending Ping call.
+//
+// Any other read failure (connection lost, protocol error, ...) leaves the connection unusable: the
+// connection is dropped and a wsconnadapter.WebsocketCloseError with code 1006 (Abnormal Closure)
+// is returned.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GobwasWebsocketConnectionAdapter) Read(ctx context.Context) (wsconnadapter.MessageType, []byte, error) {
+	// Lock read mutex before accessing the shared read buffer
+	adapter.readMu.Lock()
+	defer adapter.readMu.Unlock()
+	msgType, msg, err := adapter.readInto(ctx, adapter.readBuf[:0])
+	if err != nil {
+		return msgType, nil, err
+	}
+	// Keep the (possibly grown) buffer for the next call and return a copy of the message
+	adapter.readBuf = msg[:0]
+	return msgType, append([]byte(nil), msg...), nil
+}
+
+// # Description
+//
+// The adapter does not stream messages: ReadStream reads the whole message with Read and returns
+// a reader on the message content.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+// The message type and a reader on the message content or the error returned by Read.
+func (adapter *GobwasWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsconnadapter.MessageType, io.Reader, error) {
+	msgType, msg, err := adapter.Read(ctx)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
+// # Description
+//
+// Read a single message from the websocket server in the provided buffer. ReadInto behaves like
+// Read but does not allocate when the message fits in the buffer capacity: the message is
+// appended to buf[:0] and the buffer is grown if the message is larger than its capacity.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//   - buf: Buffer used to read the message. Can be nil.
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content. It shares the provided buffer memory unless the buffer has been
+//     grown: it is only valid until the buffer is reused.
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GobwasWebsocketConnectionAdapter) ReadInto(ctx context.Context, buf []byte) (wsconnadapter.MessageType, []byte, error) {
+	adapter.readMu.Lock()
+	defer adapter.readMu.Unlock()
+	return adapter.readInto(ctx, buf[:0])
+}
+
+// # Description
+//
+// Write a single message to the websocket server. Write blocks until message is sent to the
+// server or until an error occurs: context timeout, cancellation, connection closed, ....
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: received message type (Binary | Text)
+//   - []bytes: Message content
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GobwasWebsocketConnectionAdapter) Write(ctx context.Context, msgType wsconnadapter.MessageType, msg []byte) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock internal mutex as frames cannot be written concurrently
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if adapter.session == nil {
+			return fmt.Errorf("write failed: %w", wsconnadapter.ErrNotConnected)
+		}
+		// Write message - the payload is masked in a copy so msg is not altered
+		return wsutil.WriteClientMessage(adapter.session.conn, ws.OpCode(msgType), msg)
+	}
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode of the adapter
+// and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *GobwasWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsconnadapter.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	adapter.mu.Lock()
+	mode := adapter.propagationMode
+	adapter.mu.Unlock()
+	msgType, msg, err := wsconnadapter.InjectTraceContext(ctx, mode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in outgoing
+// messages. Defaults to wsconnadapter.None: messages are written as is.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *GobwasWebsocketConnectionAdapter) WithPropagationMode(mode wsconnadapter.PropagationMode) *GobwasWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// # Description
+//
+// The adapter does not stream messages: the returned writer buffers the message content and
+// writes the whole message with Write when it is closed.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose. Provided to Write when the writer is closed.
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+// A writer for the message content. Close returns the error returned by Write.
+func (adapter *GobwasWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsconnadapter.MessageType) (io.WriteCloser, error) {
+	return wsconnadapter.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
+// # Description
+//
+// Return the underlying network connection if any. Returned value has to be type asserted.
+//
+// # Returns
+//
+// The underlying net.Conn if any. Returned value has to be type asserted.
+func (adapter *GobwasWebsocketConnectionAdapter) GetUnderlyingWebsocketConnection() any {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Return underlying connection
+	if adapter.session == nil {
+		return nil
+	}
+	return adapter.session.conn
+}
+
+// # Description
+//
+// Return the subprotocol selected by the server for the current connection. Subprotocols are
+// proposed to the server with the Protocols field of the dialer or with the
+// Sec-WebSocket-Protocol request header.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if the server has not selected any subprotocol
+// or if no connection is up.
+func (adapter *GobwasWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.session == nil {
+		return ""
+	}
+	return adapter.session.subprotocol
+}
+
+// # Description
+//
+// The adapter does not track its read throughput.
+//
+// # Returns
+//
+// A zero value.
+func (adapter *GobwasWebsocketConnectionAdapter) ReadStats() wsconnadapter.AdapterReadStats {
+	return wsconnadapter.AdapterReadStats{}
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Read frames until a complete message is received and append its payload to buf. Control frames
+// received before or between message fragments are processed by the control frame handler.
+//
+// The read mutex must be held by the caller.
+func (adapter *GobwasWebsocketConnectionAdapter) readInto(ctx context.Context, buf []byte) (wsconnadapter.MessageType, []byte, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return -1, nil, ctx.Err()
+	default:
+		// Lock internal mutex before and store current session reference in local variable to
+		// allow other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		session := adapter.session
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if session == nil {
+			return -1, nil, fmt.Errorf("read failed: %w", wsconnadapter.ErrNotConnected)
+		}
+		for {
+			hdr, err := session.reader.NextFrame()
+			if err != nil {
+				return -1, nil, adapter.handleReadError(session, err)
+			}
+			if hdr.OpCode.IsControl() {
+				// Process control frame and discard its unread payload if any
+				err = adapter.controlFrameHandler(session)(hdr, session.reader)
+				if err == nil {
+					err = session.reader.Discard()
+				}
+				if err != nil {
+					return -1, nil, adapter.handleReadError(session, err)
+				}
+				continue
+			}
+			// Read message payload, including continuation frames, in the buffer
+			buf, err = readPayload(session.reader, buf)
+			if err != nil {
+				return -1, nil, adapter.handleReadError(session, err)
+			}
+			return wsconnadapter.MessageType(hdr.OpCode), buf, nil
+		}
+	}
+}
+
+// Build a handler for control frames received on the provided session:
+//   - Ping are answered with a Pong which has the same payload.
+//   - Pong are propagated to the first active listener waiting for a Pong notification.
+//   - Close are echoed (unless a close message has already been sent) and propagated to all
+//     active listeners waiting for a Pong notification. The handler returns the close error.
+func (adapter *GobwasWebsocketConnectionAdapter) controlFrameHandler(session *gobwasSession) wsutil.FrameHandlerFunc {
+	return func(hdr ws.Header, r io.Reader) error {
+		// Control frames payload is at most 125 bytes long
+		payload := make([]byte, hdr.Length)
+		if _, err := io.ReadFull(r, payload); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		switch hdr.OpCode {
+		case ws.OpPing:
+			adapter.mu.Lock()
+			defer adapter.mu.Unlock()
+			return wsutil.WriteClientMessage(session.conn, ws.OpPong, payload)
+		case ws.OpPong:
+			propagateToFirstActiveListener(adapter.pingRequests, nil)
+			return nil
+		case ws.OpClose:
+			code, reason := wsconnadapter.NoStatusReceived, ""
+			if len(payload) > 0 {
+				statusCode, text := ws.ParseCloseFrameData(payload)
+				code, reason = wsconnadapter.NormalizeCloseCode(wsconnadapter.StatusCode(statusCode)), text
+			}
+			closeErr := wsconnadapter.WebsocketCloseError{
+				Code:   code,
+				Reason: reason,
+				Err:    fmt.Errorf("close message received from server"),
+			}
+			// Echo the close message if the closing handshake has been started by the server
+			if !session.closeSent.Swap(true) {
+				adapter.mu.Lock()
+				wsutil.WriteClientMessage(session.conn, ws.OpClose, payload)
+				adapter.mu.Unlock()
+			}
+			propagateToAllActiveListener(adapter.pingRequests, closeErr)
+			return closeErr
+		default:
+			return nil
+		}
+	}
+}
+
+// Drop the session after a read failure and return the error to surface: close errors are
+// returned as is while other failures are wrapped in a close error with code 1006.
+func (adapter *GobwasWebsocketConnectionAdapter) handleReadError(session *gobwasSession, err error) error {
+	// Drop and close the existing connection so a new one can be established
+	adapter.mu.Lock()
+	if adapter.session == session {
+		adapter.session = nil
+	}
+	adapter.mu.Unlock()
+	session.conn.Close()
+	closeErr := new(wsconnadapter.WebsocketCloseError)
+	if errors.As(err, closeErr) {
+		return err
+	}
+	abnormal := wsconnadapter.WebsocketCloseError{
+		Code:   wsconnadapter.AbnormalClosure,
+		Reason: err.Error(),
+		Err:    err,
+	}
+	propagateToAllActiveListener(adapter.pingRequests, abnormal)
+	return abnormal
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Append the payload of the current message to buf until the whole message has been read. The
+// buffer is grown when it is full.
+func readPayload(r io.Reader, buf []byte) ([]byte, error) {
+	for {
+		if len(buf) == cap(buf) {
+			// Grow buffer
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return buf, nil
+			}
+			return buf, err
+		}
+	}
+}
+
+// Parse the response to a refused handshake. Only the first bytes of the response body are kept.
+func readRefusedHandshake(resp io.Reader) *http.Response {
+	res, err := http.ReadResponse(bufio.NewReader(resp), nil)
+	if err != nil {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxHandshakeResponseBodySize))
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res
+}
+
+// Return a copy of the dialer which uses the provided host for the Host header and for TLS server
+// name verification.
+func withDialHost(dialer ws.Dialer, host string) ws.Dialer {
+	dialer.Host = host
+	if dialer.TLSConfig == nil {
+		dialer.TLSConfig = &tls.Config{}
+	} else {
+		dialer.TLSConfig = dialer.TLSConfig.Clone()
+	}
+	if dialer.TLSConfig.ServerName == "" {
+		dialer.TLSConfig.ServerName = hostWithoutPort(host)
+	}
+	return dialer
+}
+
+// Remove the port from a host[:port] string.
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// Propagate a notification to the first writeable (non-blocking write) channel received.
+//
+// The function returns false if the notification could not be propagated: either because no channel
+// was received or because all received channels were not writeable.
+func propagateToFirstActiveListener(listeners chan chan error, notification error) bool {
+	for {
+		select {
+		case listener := <-listeners:
+			// We have received a channel from a listener
+			select {
+			case listener <- notification:
+				// Listener was active (or channel has capacity) - Notification has been sent
+				return true
+			default:
+				// Listener was not actively listenning (not writeable) - Loop to try the next one
+				continue
+			}
+		default:
+			// No channel available to notify active listeners - Exit (false)
+			return false
+		}
+	}
+}
+
+// Propagate a notification to all writeable (non-blocking write) channel received through
+// the provided channel.
+func propagateToAllActiveListener(listeners chan chan error, notification error) {
+	for {
+		select {
+		case listener := <-listeners:
+			// We have received a channel from a listener
+			select {
+			case listener <- notification:
+				// Listener was active (or channel has capacity) - Notification has been sent,
+				// continue with the next listener
+				continue
+			default:
+				// Listener is not actively listening - Skip
+				continue
+			}
+		default:
+			// No active listeners left - Exit
+			return
+		}
+	}
+}
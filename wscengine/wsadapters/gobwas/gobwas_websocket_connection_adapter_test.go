@@ -0,0 +1,259 @@
+package gobwas
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	wsconnadapter "github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gobwas/ws"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+type GobwasWebsocketConnectionAdapterTestSuite struct {
+	suite.Suite
+	// Websocket server address
+	srvUrl *url.URL
+	// Websocket test server
+	srv *httptest.Server
+}
+
+// Run GobwasWebsocketConnectionAdapterTestSuite test suite
+func TestGobwasWebsocketConnectionAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(GobwasWebsocketConnectionAdapterTestSuite))
+}
+
+// GobwasWebsocketConnectionAdapterTestSuite - Before all tests
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) SetupSuite() {
+	// Start a test server with an echo endpoint, an endpoint which closes the connection after
+	// the first message and an endpoint which refuses the handshake
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Test": []string{"echo"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/close", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"), time.Now().Add(time.Second))
+		// Wait for the close message echoed by the client
+		conn.ReadMessage()
+	})
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	suite.srv = httptest.NewServer(mux)
+	u, err := url.Parse("ws" + strings.TrimPrefix(suite.srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	suite.srvUrl = u
+}
+
+// GobwasWebsocketConnectionAdapterTestSuite - After all tests
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TearDownSuite() {
+	// Stop server
+	suite.srv.Close()
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test adapter complies with WebsocketConnectionAdapterInterface
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestInterfaceCompliance() {
+	var adapter interface{} = NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	_, ok := adapter.(wsconnadapter.WebsocketConnectionAdapterInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test the default read buffer size is used when a non positive buffer size is provided
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestDefaultBufferSize() {
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, -1, nil)
+	require.Equal(suite.T(), DefaultReadBufferSize, adapter.bufferSize)
+	adapter = NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 128, nil)
+	require.Equal(suite.T(), 128, adapter.bufferSize)
+}
+
+// Test methods fail when no connection is up
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestMethodsWithoutConnection() {
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	ctx := context.Background()
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	_, _, err := adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, wsconnadapter.ErrNotConnected)
+	require.ErrorIs(suite.T(), adapter.Write(ctx, wsconnadapter.Text, []byte("hello")), wsconnadapter.ErrNotConnected)
+	require.ErrorIs(suite.T(), adapter.Ping(ctx), wsconnadapter.ErrPingFailed)
+	require.ErrorIs(suite.T(), adapter.Close(ctx, wsconnadapter.NormalClosure, ""), wsconnadapter.ErrNotConnected)
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the adapter can dial the server, write messages and read echoed messages. The test
+// includes a message larger than the read buffer.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestEcho() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 64, nil)
+	res, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, res.StatusCode)
+	require.Equal(suite.T(), "echo", res.Header.Get("X-Test"))
+	require.NotNil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	// A second dial must fail
+	_, err = adapter.Dial(ctx, *suite.srvUrl)
+	require.ErrorIs(suite.T(), err, wsconnadapter.ErrAlreadyConnected)
+	// Echo a small text message and a large binary message
+	large := []byte(strings.Repeat("x", 1000))
+	require.NoError(suite.T(), adapter.Write(ctx, wsconnadapter.Text, []byte("hello")))
+	require.NoError(suite.T(), adapter.Write(ctx, wsconnadapter.Binary, large))
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsconnadapter.Text, msgType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	msgType, msg, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsconnadapter.Binary, msgType)
+	require.Equal(suite.T(), large, msg)
+	require.NoError(suite.T(), adapter.Close(ctx, wsconnadapter.NormalClosure, ""))
+}
+
+// Test ReadInto reads messages in the provided buffer without allocating a new one.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestReadInto() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	_, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	defer adapter.Close(ctx, wsconnadapter.NormalClosure, "")
+	buf := make([]byte, 0, 32)
+	for _, expected := range []string{"first", "second"} {
+		require.NoError(suite.T(), adapter.Write(ctx, wsconnadapter.Text, []byte(expected)))
+		msgType, msg, err := adapter.ReadInto(ctx, buf)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), wsconnadapter.Text, msgType)
+		require.Equal(suite.T(), expected, string(msg))
+		// Message shares the buffer memory
+		require.Same(suite.T(), &buf[:1][0], &msg[0])
+	}
+}
+
+// Test Ping is unlocked by the pong sent by the server while a goroutine reads messages.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestPing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	_, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	// Read messages in a separate goroutine so pong are processed
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := adapter.Read(ctx); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Close(ctx, wsconnadapter.NormalClosure, ""))
+	// Read fails with a close error once the server echoes the close message
+	select {
+	case err := <-readErr:
+		require.ErrorAs(suite.T(), err, new(wsconnadapter.WebsocketCloseError))
+	case <-ctx.Done():
+		suite.FailNow("read did not fail after close")
+	}
+}
+
+// Test a close message sent by the server is returned by Read as a WebsocketCloseError and
+// propagated to pending Ping.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestReadWhenServerCloses() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	target := *suite.srvUrl
+	target.Path = "/close"
+	_, err := adapter.Dial(ctx, target)
+	require.NoError(suite.T(), err)
+	// Record a pending ping which will be notified by the close message
+	pong := make(chan error, 1)
+	adapter.pingRequests <- pong
+	require.NoError(suite.T(), adapter.Write(ctx, wsconnadapter.Text, []byte("close")))
+	_, _, err = adapter.Read(ctx)
+	closeErr := new(wsconnadapter.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsconnadapter.GoingAway, closeErr.Code)
+	require.Equal(suite.T(), "bye", closeErr.Reason)
+	require.ErrorAs(suite.T(), <-pong, closeErr)
+	require.Equal(suite.T(), wsconnadapter.GoingAway, closeErr.Code)
+	// Connection has been dropped and a new one can be established
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	_, err = adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(ctx, wsconnadapter.NormalClosure, ""))
+}
+
+// Test Dial returns the server response when the handshake is refused.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestDialRefused() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	target := *suite.srvUrl
+	target.Path = "/forbidden"
+	res, err := adapter.Dial(ctx, target)
+	require.Error(suite.T(), err)
+	require.NotNil(suite.T(), res)
+	require.Equal(suite.T(), http.StatusForbidden, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(suite.T(), err)
+	require.Contains(suite.T(), string(body), "forbidden")
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+}
+
+// Test the connection is dropped with an abnormal closure error when the connection is lost.
+func (suite *GobwasWebsocketConnectionAdapterTestSuite) TestReadWhenConnectionIsLost() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGobwasWebsocketConnectionAdapter(ws.Dialer{}, 0, nil)
+	_, err := adapter.Dial(ctx, *suite.srvUrl)
+	require.NoError(suite.T(), err)
+	// Close the network connection without closing handshake
+	adapter.session.conn.Close()
+	_, _, err = adapter.Read(ctx)
+	closeErr := new(wsconnadapter.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsconnadapter.AbnormalClosure, closeErr.Code)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+}
@@ -0,0 +1,71 @@
+package gorilla
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Response writer which records the handshake response written by gorilla's upgrader so
+// UpgradeFromHTTP can return the response actually sent to the client.
+//
+// Gorilla hijacks the connection and writes the whole handshake response with a single Write on
+// the hijacked connection: the first Write on the connection is recorded.
+type handshakeRecorder struct {
+	// Response writer of the HTTP handler - must implement http.Hijacker
+	http.ResponseWriter
+	// Hijacked connection - nil until the connection is hijacked
+	conn *handshakeRecordingConn
+}
+
+// Hijack the connection of the underlying response writer and wrap it so the handshake response
+// is recorded.
+func (recorder *handshakeRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := recorder.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	recorder.conn = &handshakeRecordingConn{Conn: conn}
+	return recorder.conn, brw, nil
+}
+
+// # Description
+//
+// Parse the recorded handshake response.
+//
+// # Inputs
+//
+//   - r: Handshake request.
+//
+// # Returns
+//
+// The handshake response written by the upgrader or an error if no response has been recorded.
+func (recorder *handshakeRecorder) response(r *http.Request) (*http.Response, error) {
+	if recorder.conn == nil || recorder.conn.handshake == nil {
+		return nil, fmt.Errorf("handshake response has not been recorded")
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(recorder.conn.handshake)), r)
+}
+
+// Hijacked connection which records the content of the first Write: the handshake response.
+type handshakeRecordingConn struct {
+	// Hijacked connection
+	net.Conn
+	// Content of the first Write - nil until the handshake response has been written
+	handshake []byte
+}
+
+// Record the content of the first Write and write to the hijacked connection.
+func (conn *handshakeRecordingConn) Write(p []byte) (int, error) {
+	if conn.handshake == nil {
+		conn.handshake = append([]byte{}, p...)
+	}
+	return conn.Conn.Write(p)
+}
+
+// Return the hijacked connection. Used to reach the TCP connection (see TCPStats).
+func (conn *handshakeRecordingConn) NetConn() net.Conn {
+	return conn.Conn
+}
@@ -0,0 +1,90 @@
+package gorilla
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	wsconnadapter "github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Error returned when TCP statistics cannot be collected: the platform is not supported (only
+// Linux is supported) or the connection does not use TCP.
+var ErrTCPStatsUnavailable = errors.New("tcp statistics are unavailable")
+
+// # Description
+//
+// Return the TCP connection used by the websocket connection and its kernel level statistics
+// (TCP_INFO). Network issues like retransmits are often visible at TCP level before the websocket
+// connection fails.
+//
+// TCPInfo is an alias of syscall.TCPInfo on Linux. TLS layers are unwrapped to find the TCP
+// connection.
+//
+// # Returns
+//
+// The TCP connection and its statistics or an error if there is no active connection or if
+// statistics are unavailable (ErrTCPStatsUnavailable).
+func (adapter *GorillaWebsocketConnectionAdapter) TCPStats() (*net.TCPConn, TCPInfo, error) {
+	// Lock internal mutex and store current conn reference in local variable
+	adapter.mu.Lock()
+	conn := adapter.conn
+	adapter.mu.Unlock()
+	if conn == nil {
+		return nil, TCPInfo{}, fmt.Errorf("tcp stats failed: %w", wsconnadapter.ErrNotConnected)
+	}
+	// Unwrap TLS layers (wss, HTTPS proxy tunnel) and connection wrappers to get the TCP
+	// connection
+	netConn := conn.UnderlyingConn()
+	for {
+		wrapper, ok := netConn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		netConn = wrapper.NetConn()
+	}
+	tcpConn, ok := netConn.(*net.TCPConn)
+	if !ok {
+		return nil, TCPInfo{}, fmt.Errorf("%w: underlying connection is a %T", ErrTCPStatsUnavailable, netConn)
+	}
+	info, err := getTCPInfo(tcpConn)
+	if err != nil {
+		return tcpConn, TCPInfo{}, err
+	}
+	return tcpConn, info, nil
+}
+
+// # Description
+//
+// Return the smoothed round trip time of the TCP connection.
+func (adapter *GorillaWebsocketConnectionAdapter) RTT() (time.Duration, error) {
+	_, info, err := adapter.TCPStats()
+	if err != nil {
+		return 0, err
+	}
+	return tcpInfoRTT(info), nil
+}
+
+// # Description
+//
+// Return the total number of segments retransmitted on the TCP connection.
+func (adapter *GorillaWebsocketConnectionAdapter) RetransmitCount() (uint32, error) {
+	_, info, err := adapter.TCPStats()
+	if err != nil {
+		return 0, err
+	}
+	return tcpInfoRetransmits(info), nil
+}
+
+// # Description
+//
+// Return the size in bytes of the TCP send window: the congestion window (in segments) multiplied
+// by the sender maximum segment size.
+func (adapter *GorillaWebsocketConnectionAdapter) SendWindowSize() (uint32, error) {
+	_, info, err := adapter.TCPStats()
+	if err != nil {
+		return 0, err
+	}
+	return tcpInfoSendWindow(info), nil
+}
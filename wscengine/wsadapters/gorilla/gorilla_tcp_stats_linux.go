@@ -0,0 +1,60 @@
+//go:build linux
+
+package gorilla
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Kernel level statistics of a TCP connection (TCP_INFO).
+type TCPInfo = syscall.TCPInfo
+
+// Read TCP_INFO of the provided connection.
+func getTCPInfo(conn *net.TCPConn) (TCPInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return TCPInfo{}, fmt.Errorf("failed to get raw connection: %w", err)
+	}
+	info := TCPInfo{}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(info))
+		_, _, errno := syscall.Syscall6(
+			syscall.SYS_GETSOCKOPT,
+			fd,
+			syscall.IPPROTO_TCP,
+			syscall.TCP_INFO,
+			uintptr(unsafe.Pointer(&info)),
+			uintptr(unsafe.Pointer(&size)),
+			0)
+		if errno != 0 {
+			sockErr = errno
+		}
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return TCPInfo{}, fmt.Errorf("failed to get tcp info: %w", err)
+	}
+	return info, nil
+}
+
+// Return the smoothed round trip time.
+func tcpInfoRTT(info TCPInfo) time.Duration {
+	return time.Duration(info.Rtt) * time.Microsecond
+}
+
+// Return the total number of retransmitted segments.
+func tcpInfoRetransmits(info TCPInfo) uint32 {
+	return info.Total_retrans
+}
+
+// Return the send window size in bytes.
+func tcpInfoSendWindow(info TCPInfo) uint32 {
+	return info.Snd_cwnd * info.Snd_mss
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package gorilla
+
+import (
+	"net"
+	"time"
+)
+
+// Kernel level statistics of a TCP connection - unavailable on this platform.
+type TCPInfo struct{}
+
+// TCP statistics are only available on Linux.
+func getTCPInfo(conn *net.TCPConn) (TCPInfo, error) {
+	return TCPInfo{}, ErrTCPStatsUnavailable
+}
+
+func tcpInfoRTT(info TCPInfo) time.Duration {
+	return 0
+}
+
+func tcpInfoRetransmits(info TCPInfo) uint32 {
+	return 0
+}
+
+func tcpInfoSendWindow(info TCPInfo) uint32 {
+	return 0
+}
@@ -0,0 +1,63 @@
+package gorilla
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+// Test suite used for TCP statistics unit tests
+type GorillaTCPStatsTestSuite struct {
+	suite.Suite
+}
+
+// Run GorillaTCPStatsTestSuite test suite
+func TestGorillaTCPStatsTestSuite(t *testing.T) {
+	suite.Run(t, new(GorillaTCPStatsTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test TCP statistics are collected from an active connection on Linux.
+func (suite *GorillaTCPStatsTestSuite) TestTCPStats() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	// No active connection
+	_, _, err := adapter.TCPStats()
+	require.Error(suite.T(), err)
+	// Connect to the shared echo server and exchange a message
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	defer adapter.Close(ctx, wsadapters.NormalClosure, "bye")
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("hello")))
+	_, _, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	// Collect statistics
+	tcpConn, _, err := adapter.TCPStats()
+	if runtime.GOOS != "linux" {
+		require.ErrorIs(suite.T(), err, ErrTCPStatsUnavailable)
+		return
+	}
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), echoSrvURL.Host, tcpConn.RemoteAddr().String())
+	rtt, err := adapter.RTT()
+	require.NoError(suite.T(), err)
+	require.Greater(suite.T(), rtt, time.Duration(0))
+	_, err = adapter.RetransmitCount()
+	require.NoError(suite.T(), err)
+	window, err := adapter.SendWindowSize()
+	require.NoError(suite.T(), err)
+	require.Greater(suite.T(), window, uint32(0))
+}
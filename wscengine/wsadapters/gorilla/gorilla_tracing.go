@@ -0,0 +1,15 @@
+package gorilla
+
+// Constants used for tracing purpose
+const (
+	// Instrumentation library package name
+	pkgName = "gowsclient.wsadapters.gorilla"
+	// Instrumentation library package version
+	pkgVersion = "0.0.0"
+	// Name of the span used to instrument Ping method call
+	spanPing = "wscengine.ping"
+	// Name of the event used when the pong which answers a ping has been received
+	eventPong = "wscengine.pong"
+	// Name of the attribute used to provide the application data of a ping or pong
+	attrAppData = "app_data"
+)
@@ -4,14 +4,28 @@ package gorilla
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	wsconnadapter "github.com/gbdevw/gowse/wscengine/wsadapters"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Adapter for gorilla/websocket library
@@ -22,12 +36,64 @@ type GorillaWebsocketConnectionAdapter struct {
 	dialer *websocket.Dialer
 	// Headers to use when opening a connection
 	requestHeader http.Header
+	// Optional function which produces the headers to use when opening a connection - replaces
+	// requestHeader when set
+	headerProvider HeaderProvider
+	// Upgrader used by UpgradeFromHTTP to accept connections from clients
+	upgrader websocket.Upgrader
 	// Internal mutex
 	mu sync.Mutex
+	// Mutex held while a message is written - locked before mu. Held by message writers returned
+	// by WriteStream until they are closed.
+	writeMu sync.Mutex
 	// Internal channel of channels used to manage ping/pong
 	//
 	// The channel that is sent is used to wait for pong or an error.
 	pingRequests chan chan error
+	// Maximum number of pending Ping calls. If 0, Ping blocks until its request can be recorded.
+	maxPendingPings int
+	// Number of pending Ping calls
+	pendingPings atomic.Int32
+	// Policy used to retry failed Dial - retries are disabled by default
+	dialRetryPolicy RetryPolicy
+	// Optional function applied to the handshake response before it is returned by Dial
+	responseHeaderTransformer ResponseHeaderTransformer
+	// Function used to normalize close codes received from the server
+	closeCodeNormalizer func(code wsconnadapter.StatusCode) wsconnadapter.StatusCode
+	// Minimum TLS version which must be negotiated with the server - 0 if not checked
+	minTLSVersion uint16
+	// Timeout applied to each write - 0 if writes have no deadline
+	writeTimeout time.Duration
+	// Flag set when permessage-deflate compression is enabled
+	compression bool
+	// Compression level used to compress outgoing messages when compression is enabled
+	compressionLevel int
+	// Extensions negotiated with the server for the current connection
+	negotiatedExtensions []string
+	// Maximum size in bytes of a message read from the server - 0 if not limited
+	readLimit int64
+	// Optional structured logger - nil if logging is disabled
+	logger *slog.Logger
+	// Duration of the sliding window used to compute read throughput - set by options
+	readStatsWindow time.Duration
+	// Recorder used to compute read throughput
+	readStats *wsconnadapter.ReadStatsRecorder
+	// Flag set when writes are performed by a dedicated writer goroutine
+	writerEnabled bool
+	// Capacity of the queue of the writer goroutine
+	writerQueueSize int
+	// Queue of the writer goroutine of the current connection - nil if disabled or not connected
+	writeRequests chan writeRequest
+	// Channel closed when the current connection is dropped to stop its writer goroutine
+	writerStop chan struct{}
+	// Tracer used to instrument Ping
+	tracer trace.Tracer
+	// Sequence number of the last ping - used as ping application data
+	pingSeq atomic.Uint64
+	// Application data of the last received pong
+	lastPongAppData atomic.Pointer[string]
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsconnadapter.PropagationMode
 }
 
 // # Description
@@ -42,23 +108,98 @@ type GorillaWebsocketConnectionAdapter struct {
 //   - requestHeader: Headers which will be used during Dial to specify the origin (Origin),
 //     subprotocols (Sec-WebSocket-Protocol) and cookies (Cookie)
 //
+//   - opts: Optional options used to further customize the adapter.
+//
 // # Returns
 //
 // New GorillaWebsocketConnectionAdapter
-func NewGorillaWebsocketConnectionAdapter(dialer *websocket.Dialer, requestHeader http.Header) *GorillaWebsocketConnectionAdapter {
+func NewGorillaWebsocketConnectionAdapter(dialer *websocket.Dialer, requestHeader http.Header, opts ...GorillaAdapterOption) *GorillaWebsocketConnectionAdapter {
 	if dialer == nil {
 		// Use default dialer if nil
 		dialer = websocket.DefaultDialer
 	}
-	// Build and return adapter
-	return &GorillaWebsocketConnectionAdapter{
+	if len(opts) > 0 {
+		// Work on a copy of the dialer so options do not alter a shared dialer
+		dialerCopy := *dialer
+		dialer = &dialerCopy
+	}
+	// Build adapter
+	adapter := &GorillaWebsocketConnectionAdapter{
 		conn:          nil,
 		dialer:        dialer,
 		requestHeader: requestHeader,
 		mu:            sync.Mutex{},
 		// Use a chan with capacity so ping requests can be recorded before sending ping message.
-		pingRequests: make(chan chan error, 10),
+		pingRequests: make(chan chan error, DefaultPingChannelCapacity),
+		// Map close codes outside RFC6455 ranges to 1006
+		closeCodeNormalizer: wsconnadapter.NormalizeCloseCode,
+	}
+	// Apply options
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	// Use global tracer provider if none has been set
+	if adapter.tracer == nil {
+		adapter.tracer = otel.GetTracerProvider().Tracer(pkgName, trace.WithInstrumentationVersion(pkgVersion))
+	}
+	// Create read throughput recorder and return adapter
+	adapter.readStats = wsconnadapter.NewReadStatsRecorder(adapter.readStatsWindow, wsconnadapter.DefaultReadStatsCapacity)
+	return adapter
+}
+
+// # Description
+//
+// Factory which creates a new GorillaWebsocketConnectionAdapter which uses a dialer with the same
+// defaults as gorilla's default dialer (proxy from environment, 45 seconds handshake timeout) and
+// the provided TLS configuration. Use it to provide client certificates (mutual TLS) or custom root
+// CAs without building a dialer.
+//
+// # Inputs
+//
+//   - tlsCfg: TLS configuration used to connect to wss servers. The configuration is cloned so
+//     later changes do not affect the adapter. If nil, the default TLS configuration is used.
+//
+//   - requestHeader: Headers which will be used during Dial to specify the origin (Origin),
+//     subprotocols (Sec-WebSocket-Protocol) and cookies (Cookie)
+//
+//   - opts: Optional options used to further customize the adapter.
+//
+// # Returns
+//
+// New GorillaWebsocketConnectionAdapter
+func NewGorillaWebsocketConnectionAdapterWithTLS(tlsCfg *tls.Config, requestHeader http.Header, opts ...GorillaAdapterOption) *GorillaWebsocketConnectionAdapter {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		TLSClientConfig:  tlsCfg.Clone(),
 	}
+	return NewGorillaWebsocketConnectionAdapter(dialer, requestHeader, opts...)
+}
+
+// # Description
+//
+// Factory which creates a new GorillaWebsocketConnectionAdapter configured only with functional
+// options. The adapter uses gorilla's default dialer and no request headers unless WithDialer and
+// WithRequestHeader are provided.
+//
+// Example:
+//
+//	adapter := NewGorillaWebsocketConnectionAdapterWithOptions(
+//		WithRequestHeader(http.Header{"Origin": []string{"https://example.com"}}),
+//		WithTLSConfig(tlsCfg),
+//		WithReadLimit(1 << 20),
+//		WithCompression(flate.BestSpeed),
+//	)
+//
+// # Inputs
+//
+//   - opts: Options used to configure the adapter, applied in the order they are provided.
+//
+// # Returns
+//
+// New GorillaWebsocketConnectionAdapter
+func NewGorillaWebsocketConnectionAdapterWithOptions(opts ...GorillaAdapterOption) *GorillaWebsocketConnectionAdapter {
+	return NewGorillaWebsocketConnectionAdapter(nil, nil, opts...)
 }
 
 // # Description
@@ -72,7 +213,9 @@ func NewGorillaWebsocketConnectionAdapter(dialer *websocket.Dialer, requestHeade
 //
 // # Returns
 //
-// The server response to websocket handshake or an error if any.
+// The server response to websocket handshake or an error if any. The response keeps all headers
+// sent by the server (Set-Cookie, Sec-WebSocket-Extensions, custom headers) and is transformed by
+// the function set with WithResponseHeaderTransformer, if any. The engine provides it to OnOpen.
 func (adapter *GorillaWebsocketConnectionAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
 	select {
 	case <-ctx.Done():
@@ -85,23 +228,121 @@ func (adapter *GorillaWebsocketConnectionAdapter) Dial(ctx context.Context, targ
 		// Check whether there is already a connection set
 		if adapter.conn != nil {
 			// Return error in case a connection has already been set
-			return nil, fmt.Errorf("a connection has already been established")
+			return nil, wsconnadapter.ErrAlreadyConnected
+		}
+		// Produce fresh request headers if a header provider is set
+		dialer, requestHeader := adapter.dialer, adapter.requestHeader
+		if adapter.headerProvider != nil {
+			headers, err := adapter.headerProvider(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to provide request headers: %w", err)
+			}
+			requestHeader = headers
+		}
+		// Use the dial host for Host header and TLS server name if target host has been resolved
+		if host, ok := wsconnadapter.DialHostFromContext(ctx); ok {
+			dialer, requestHeader = withDialHost(dialer, requestHeader, host)
+		}
+		// Open websocket connection - Retry transient failures if enabled
+		conn, res, err := dialer.DialContext(ctx, target.String(), requestHeader)
+		for attempt := 1; err != nil && res == nil && attempt < adapter.dialRetryPolicy.MaxAttempts; attempt++ {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(adapter.dialRetryPolicy.delay(attempt)):
+				adapter.log(slog.LevelWarn, "retrying failed dial", "attempt", attempt+1, "error", err)
+				conn, res, err = dialer.DialContext(ctx, target.String(), requestHeader)
+			}
+		}
+		// Extract the negotiated extensions before the response is transformed
+		var extensions []string
+		if res != nil {
+			extensions = parseExtensions(res.Header)
+		}
+		if res != nil && adapter.responseHeaderTransformer != nil {
+			// Transform response before it is returned
+			res = adapter.responseHeaderTransformer(res)
 		}
-		// Open websocket connection
-		conn, res, err := adapter.dialer.DialContext(ctx, target.String(), adapter.requestHeader)
 		if err != nil {
 			// Return response and error
 			return res, err
 		}
-		// Persist connection internally and set handlers
-		adapter.conn = conn
-		conn.SetCloseHandler(adapter.closeHandler)
-		conn.SetPongHandler(adapter.pongHandler)
+		// Check the negotiated TLS version if enabled
+		if tlsConn, ok := conn.UnderlyingConn().(*tls.Conn); ok && adapter.minTLSVersion > 0 {
+			version := tlsConn.ConnectionState().Version
+			if version < adapter.minTLSVersion {
+				conn.Close()
+				return res, fmt.Errorf("%w: %s is lower than %s", ErrInsecureTLSVersion,
+					tls.VersionName(version), tls.VersionName(adapter.minTLSVersion))
+			}
+		}
+		// Configure and persist connection
+		if err := adapter.setConnection(conn, extensions); err != nil {
+			return res, err
+		}
 		// Return
 		return res, nil
 	}
 }
 
+// # Description
+//
+// UpgradeFromHTTP upgrades a HTTP/1.1 request received by a HTTP handler to a websocket connection
+// using the upgrader set with WithUpgrader. The connection is configured and stored like
+// connections opened with Dial so all other methods work unchanged.
+//
+// In case of failure, the upgrader replies to the client with a HTTP error. No reply is sent if
+// the adapter already has an active connection.
+//
+// # Inputs
+//
+//   - w: Response writer of the HTTP handler. It must support hijacking.
+//   - r: HTTP request to upgrade.
+//   - responseHeader: Optional headers to include in the handshake response (cookies, ...). Use
+//     the upgrader to negotiate subprotocols.
+//
+// # Returns
+//
+// The handshake response sent to the client, as written by the upgrader, or an error if any.
+func (adapter *GorillaWebsocketConnectionAdapter) UpgradeFromHTTP(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*http.Response, error) {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.conn != nil {
+		// Return error in case a connection has already been set
+		return nil, wsconnadapter.ErrAlreadyConnected
+	}
+	// Negotiate permessage-deflate if compression is enabled
+	upgrader := adapter.upgrader
+	if adapter.compression {
+		upgrader.EnableCompression = true
+	}
+	// Record the handshake response written by the upgrader. If the connection cannot be
+	// hijacked, the upgrader replies with an error.
+	recorder := &handshakeRecorder{ResponseWriter: w}
+	if _, ok := w.(http.Hijacker); ok {
+		w = recorder
+	}
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		return nil, err
+	}
+	res, err := recorder.response(r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read handshake response: %w", err)
+	}
+	// Extensions agreed by the upgrader
+	extensions := parseExtensions(res.Header)
+	// Configure and persist connection
+	if err := adapter.setConnection(conn, extensions); err != nil {
+		return res, err
+	}
+	// Return
+	return res, nil
+}
+
 // # Description
 //
 // Send a close message with the provided status code and an optional close reason and drop
@@ -123,10 +364,10 @@ func (adapter *GorillaWebsocketConnectionAdapter) Close(ctx context.Context, cod
 	defer adapter.mu.Unlock()
 	// Check whether there is already a connection set
 	if adapter.conn == nil {
-		return fmt.Errorf("close failed because no connection is already up")
+		return fmt.Errorf("close failed: %w", wsconnadapter.ErrNotConnected)
 	}
 	// Close connection
-	err := adapter.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(int(code), reason), time.Now().Add(60*time.Second))
+	err := adapter.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(int(code), reason), adapter.controlWriteDeadline())
 	// Propagate close error to all pending Ping - This has to be done be close handler works only
 	// when conneciton is closed by server (not from client side)
 	propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
@@ -135,7 +376,42 @@ func (adapter *GorillaWebsocketConnectionAdapter) Close(ctx context.Context, cod
 		Err:    fmt.Errorf("client closed the connection"),
 	})
 	// Void connection in any case
-	adapter.conn = nil
+	adapter.dropConnection()
+	// Return result
+	return err
+}
+
+// # Description
+//
+// Close the underlying network connection without sending a close message and drop the
+// websocket connection. The server sees an abnormal closure (1006).
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (adapter *GorillaWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.conn == nil {
+		return fmt.Errorf("drop failed: %w", wsconnadapter.ErrNotConnected)
+	}
+	// Close the network connection - gorilla does not send a close message
+	err := adapter.conn.Close()
+	// Propagate close error to all pending Ping
+	propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
+		Code:   wsconnadapter.AbnormalClosure,
+		Reason: "connection dropped",
+		Err:    fmt.Errorf("client dropped the connection"),
+	})
+	// Void connection in any case
+	adapter.dropConnection()
 	// Return result
 	return err
 }
@@ -156,7 +432,21 @@ func (adapter *GorillaWebsocketConnectionAdapter) Close(ctx context.Context, cod
 //
 // - nil in case of success: A Ping message has been sent to the server and a Pong has been received.
 // - error: connection is closed, context timeout/cancellation, ...
-func (adapter *GorillaWebsocketConnectionAdapter) Ping(ctx context.Context) error {
+func (adapter *GorillaWebsocketConnectionAdapter) Ping(ctx context.Context) (err error) {
+	// Trace the ping/pong cycle - span status is set to error if no pong is received
+	appData := strconv.FormatUint(adapter.pingSeq.Add(1), 10)
+	ctx, span := adapter.tracer.Start(ctx, spanPing,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(attrAppData, appData)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, codes.Ok.String())
+		}
+	}()
 	select {
 	case <-ctx.Done():
 		// Shortcut if context is done (timeout/cancel)
@@ -169,30 +459,56 @@ func (adapter *GorillaWebsocketConnectionAdapter) Ping(ctx context.Context) erro
 		adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if conn == nil {
-			return fmt.Errorf("ping failed because no connection is already up")
+			return fmt.Errorf("%w: %w", wsconnadapter.ErrPingFailed, wsconnadapter.ErrNotConnected)
+		}
+		// Reject the Ping without blocking if the maximum number of pending Ping is reached
+		if adapter.maxPendingPings > 0 {
+			pending := adapter.pendingPings.Add(1)
+			defer adapter.pendingPings.Add(-1)
+			if int(pending) > adapter.maxPendingPings {
+				return ErrPingQueueFull
+			}
 		}
 		// Create channel to receive pong and send it on pingRequest channel
 		// It is OK because pingRequest is a channel with capacity
 		// pong channel must be a blocking channel
 		pong := make(chan error)
-		select {
-		case adapter.pingRequests <- pong:
-			// Do nothing
-		case <-ctx.Done():
-			// Handle cancellation in case pingRequest channel is full
-			return ctx.Err()
+		if adapter.maxPendingPings > 0 {
+			select {
+			case adapter.pingRequests <- pong:
+				// Do nothing
+			default:
+				// Channel capacity is lower than the maximum number of pending Ping
+				return ErrPingQueueFull
+			}
+		} else {
+			select {
+			case adapter.pingRequests <- pong:
+				// Do nothing
+			case <-ctx.Done():
+				// Handle cancellation in case pingRequest channel is full
+				return ctx.Err()
+			}
 		}
 		// Send Ping
-		err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(60*time.Second))
+		err := conn.WriteControl(websocket.PingMessage, []byte(appData), adapter.controlWriteDeadline())
 		if err != nil {
-			return fmt.Errorf("ping failed: %w", err)
+			return fmt.Errorf("%w: %w", wsconnadapter.ErrPingFailed, err)
 		}
+		adapter.log(slog.LevelDebug, "ping sent")
 		// Wait for a pong or for ctx cancellation
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case err := <-pong:
-			// Return received notification (nil or error if ping/pong failed)
+			// Record the pong and return received notification (nil or error if ping/pong failed)
+			if err == nil {
+				pongAppData := ""
+				if last := adapter.lastPongAppData.Load(); last != nil {
+					pongAppData = *last
+				}
+				span.AddEvent(eventPong, trace.WithAttributes(attribute.String(attrAppData, pongAppData)))
+			}
 			return err
 		}
 	}
@@ -206,6 +522,12 @@ func (adapter *GorillaWebsocketConnectionAdapter) Ping(ctx context.Context) erro
 // Read will handle control frames from the server until a message is received:
 //   - Ping from server are discarded.
 //   - Close will result in a wsconnadapter.WebsocketCloseError for Read and all pending Ping.
+//   - A message larger than the read limit (see WithReadLimit) will result in a
+//     wsconnadapter.WebsocketCloseError with code 1009 (Message Too Big) which wraps
+//     ErrMessageTooLarge.
+//   - A panic in a control frame handler will result in a wsconnadapter.WebsocketCloseError with
+//     code 1011 (Internal Error) for all pending Ping. The connection is dropped and Read returns
+//     an error which wraps ErrControlHandlerPanic and wsconnadapter.ErrNotConnected.
 //   - Each pong message will be used to unlock one pending Ping call.
 //
 // # Inputs
@@ -230,38 +552,71 @@ func (adapter *GorillaWebsocketConnectionAdapter) Read(ctx context.Context) (wsc
 		adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if conn == nil {
-			return -1, nil, fmt.Errorf("read failed because no connection is already up")
+			return -1, nil, fmt.Errorf("read failed: %w", wsconnadapter.ErrNotConnected)
 		}
 		// Read message
 		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
-			// Check if close error
-			if ce, ok := err.(*websocket.CloseError); ok {
-				// Drop the existing connection so a new one can be established
-				adapter.conn = nil
-				// Connection is closed
-				closeErr := wsconnadapter.WebsocketCloseError{
-					Code:   wsconnadapter.StatusCode(ce.Code),
-					Reason: err.Error(),
-					Err:    err,
-				}
-				// Return error
-				return -1, nil, closeErr
-			}
-			// Other errors
-			return -1, nil, err
+			return -1, nil, adapter.convertReadError(conn, err)
 		}
-		// Return message
+		// Record message for read throughput and return message
+		adapter.readStats.Record(len(msg))
 		return wsconnadapter.MessageType(msgType), msg, nil
 	}
 
 }
 
+// # Description
+//
+// Wait for the next message from the websocket server and return a reader which streams its
+// content: the message is not loaded in memory. ReadStream handles control frames like Read does.
+//
+// The returned reader is only valid until the next call to Read or ReadStream and must be
+// consumed before the next message is read. Errors returned by the reader are converted like the
+// errors returned by Read: a wsconnadapter.WebsocketCloseError is returned when the connection is
+// closed while the message is read.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - io.Reader: Reader which streams the message content
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GorillaWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsconnadapter.MessageType, io.Reader, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return -1, nil, ctx.Err()
+	default:
+		// Lock internal mutex before and store current conn reference in local variable to allow
+		// other routines to perform other operations on the connection.
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			return -1, nil, fmt.Errorf("read failed: %w", wsconnadapter.ErrNotConnected)
+		}
+		// Wait for the next message
+		msgType, reader, err := conn.NextReader()
+		if err != nil {
+			return -1, nil, adapter.convertReadError(conn, err)
+		}
+		return wsconnadapter.MessageType(msgType), &gorillaStreamReader{adapter: adapter, conn: conn, reader: reader}, nil
+	}
+}
+
 // # Description
 //
 // Write a single message to the websocket server. Write blocks until message is sent to the
 // server or until an error occurs: context timeout, cancellation, connection closed, ....
 //
+// If the writer goroutine is enabled (see WithWriterGoroutine), the message is handed over to the
+// writer goroutine of the connection and Write waits for the result of the write.
+//
 // # Inputs
 //
 //   - ctx: Context used for tracing/timeout purpose
@@ -277,15 +632,185 @@ func (adapter *GorillaWebsocketConnectionAdapter) Write(ctx context.Context, msg
 		// Shortcut if context is done (timeout/cancel)
 		return ctx.Err()
 	default:
-		// Lock internal mutex as WriteMessage cannot be called concurrently
+		// Hand over the message to the writer goroutine if enabled
+		if adapter.writerEnabled {
+			return adapter.enqueueWrite(ctx, msgType, msg)
+		}
+		// Lock write and internal mutexes as WriteMessage cannot be called concurrently
+		adapter.writeMu.Lock()
+		defer adapter.writeMu.Unlock()
 		adapter.mu.Lock()
 		defer adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if adapter.conn == nil {
-			return fmt.Errorf("write failed because no connection is already up")
+			return fmt.Errorf("write failed: %w", wsconnadapter.ErrNotConnected)
 		}
 		// Call Write and return results
-		return adapter.conn.WriteMessage(int(msgType), msg)
+		return adapter.writeMessage(adapter.conn, msgType, msg)
+	}
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode set with
+// WithPropagationMode and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *GorillaWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsconnadapter.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	msgType, msg, err := wsconnadapter.InjectTraceContext(ctx, adapter.propagationMode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Write a single message which content is streamed from the provided reader until EOF. The
+// content is copied directly in the connection write buffer (gorilla NextWriter) without being
+// buffered in an intermediate []byte: large messages are sent as several fragments, each time the
+// write buffer is full.
+//
+// The message cannot be aborted once streaming has started: if the reader fails, the content read
+// so far is sent as a complete message before the error is returned. Other writes are blocked
+// while the content is streamed but reads, pings and Close are not: Close can be used to abort a
+// write blocked by a stalled reader.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: message type (Binary | Text)
+//   - r: Reader which provides the message content
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation, reader or write failure.
+func (adapter *GorillaWebsocketConnectionAdapter) WriteFrom(ctx context.Context, msgType wsconnadapter.MessageType, r io.Reader) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Lock write mutex for the whole message as NextWriter cannot be called concurrently. The
+		// internal mutex is only held to get the connection so a stalled reader does not prevent
+		// Close from dropping the connection.
+		adapter.writeMu.Lock()
+		defer adapter.writeMu.Unlock()
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			return fmt.Errorf("write failed: %w", wsconnadapter.ErrNotConnected)
+		}
+		// Set the write deadline if enabled and clear it once the message has been written
+		if adapter.writeTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(adapter.writeTimeout))
+			defer conn.SetWriteDeadline(time.Time{})
+		}
+		w, err := conn.NextWriter(int(msgType))
+		if err != nil {
+			return err
+		}
+		// Message writer implements io.ReaderFrom: content is read directly in the write buffer
+		_, err = io.Copy(w, r)
+		if errClose := w.Close(); err == nil {
+			err = errClose
+		}
+		return err
+	}
+}
+
+// # Description
+//
+// Return a writer which streams the content of a single message to the server: the content is
+// written in the connection write buffer (gorilla NextWriter) and sent as a fragment each time the
+// buffer is full, so large messages do not have to be materialized before they are written. Close
+// must be called to send the final fragment and complete the message.
+//
+// Other messages cannot be written until the returned writer is closed: Write, WriteFrom and
+// WriteStream calls block meanwhile. Do not call them from the goroutine which uses the writer
+// before it is closed. Reads, pings and Close are not blocked.
+//
+// The write timeout (see WithWriteTimeout) is applied to each Write call on the returned writer.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose. Only checked before the writer is created.
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+//   - io.WriteCloser: Writer for the message content. Close flushes the buffered content and
+//     completes the message.
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (adapter *GorillaWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsconnadapter.MessageType) (io.WriteCloser, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return nil, ctx.Err()
+	default:
+		// Lock write mutex until the writer is closed as NextWriter cannot be called concurrently
+		adapter.writeMu.Lock()
+		adapter.mu.Lock()
+		conn := adapter.conn
+		adapter.mu.Unlock()
+		// Check whether there is already a connection set
+		if conn == nil {
+			adapter.writeMu.Unlock()
+			return nil, fmt.Errorf("write failed: %w", wsconnadapter.ErrNotConnected)
+		}
+		w, err := conn.NextWriter(int(msgType))
+		if err != nil {
+			adapter.writeMu.Unlock()
+			return nil, err
+		}
+		return &gorillaStreamWriter{adapter: adapter, conn: conn, writer: w}, nil
+	}
+}
+
+// # Description
+//
+// Write a single, unfragmented message of exactly size bytes read from the provided reader. The
+// frame length is set to size for servers which do not support fragmented messages.
+//
+// Gorilla writes a frame header only once the whole frame payload is buffered: the content is
+// read in a buffer of size bytes before it is sent. Use WriteFrom to avoid the buffer when the
+// server supports fragmented messages.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: message type (Binary | Text)
+//   - r: Reader which provides the message content. Bytes after the first size bytes are not read.
+//   - size: Size of the message in bytes.
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation, reader or write failure.
+//     io.ErrUnexpectedEOF is returned, and nothing is sent, if the reader provides less than size
+//     bytes.
+func (adapter *GorillaWebsocketConnectionAdapter) WriteFromSized(ctx context.Context, msgType wsconnadapter.MessageType, r io.Reader, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("invalid message size: %d", size)
+	}
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		// Read the whole message before locking the connection
+		msg := make([]byte, size)
+		_, err := io.ReadFull(r, msg)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to read %d bytes message: %w", size, err)
+		}
+		return adapter.Write(ctx, msgType, msg)
 	}
 }
 
@@ -304,13 +829,132 @@ func (adapter *GorillaWebsocketConnectionAdapter) GetUnderlyingWebsocketConnecti
 	return adapter.conn
 }
 
+// # Description
+//
+// Return the websocket extensions negotiated with the server for the current connection, as
+// listed in the Sec-WebSocket-Extensions header of the handshake response (like
+// "permessage-deflate; server_no_context_takeover; client_no_context_takeover").
+//
+// # Returns
+//
+// The negotiated extensions or nil if no extension has been negotiated or if no connection is up.
+func (adapter *GorillaWebsocketConnectionAdapter) GetNegotiatedExtensions() []string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.conn == nil {
+		return nil
+	}
+	return append([]string(nil), adapter.negotiatedExtensions...)
+}
+
+// # Description
+//
+// Return the subprotocol selected by the server for the current connection. Subprotocols are
+// proposed to the server with the Sec-WebSocket-Protocol request header (see WithRequestHeader)
+// or with the Subprotocols field of the dialer.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if the server has not selected any subprotocol
+// or if no connection is up.
+func (adapter *GorillaWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.conn == nil {
+		return ""
+	}
+	return adapter.conn.Subprotocol()
+}
+
+// # Description
+//
+// Return the read throughput of the adapter averaged over a sliding window of 10 seconds (see
+// WithReadStatsWindow). The window spans connections: statistics are not reset on Dial.
+//
+// # Returns
+//
+// The messages and bytes read per second over the window and the time the last message has
+// been read.
+func (adapter *GorillaWebsocketConnectionAdapter) ReadStats() wsconnadapter.AdapterReadStats {
+	return adapter.readStats.Stats()
+}
+
 /*************************************************************************************************/
 /* INTERNAL                                                                                      */
 /*************************************************************************************************/
 
+// Configure a connection opened by Dial or UpgradeFromHTTP, persist it internally and set the
+// control frame handlers. The connection is closed in case of error. Internal mutex must be held.
+func (adapter *GorillaWebsocketConnectionAdapter) setConnection(conn *websocket.Conn, extensions []string) error {
+	// Compress outgoing messages if enabled - Messages are only compressed if the peer has agreed
+	// to use permessage-deflate
+	if adapter.compression {
+		conn.EnableWriteCompression(true)
+		if err := conn.SetCompressionLevel(adapter.compressionLevel); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to set compression level: %w", err)
+		}
+	}
+	// Limit the size of messages read from the peer if enabled
+	if adapter.readLimit > 0 {
+		conn.SetReadLimit(adapter.readLimit)
+	}
+	// Persist connection internally and set handlers
+	adapter.conn = conn
+	adapter.negotiatedExtensions = extensions
+	conn.SetCloseHandler(adapter.closeHandler)
+	conn.SetPongHandler(adapter.pongHandler)
+	// Start the writer goroutine of the connection if enabled
+	if adapter.writerEnabled {
+		adapter.writeRequests = make(chan writeRequest, adapter.writerQueueSize)
+		adapter.writerStop = make(chan struct{})
+		go adapter.runWriter(conn, adapter.writeRequests, adapter.writerStop)
+	}
+	return nil
+}
+
+// Forget the current connection and stop its writer goroutine if any. Internal mutex must be
+// held.
+func (adapter *GorillaWebsocketConnectionAdapter) dropConnection() {
+	adapter.conn = nil
+	if adapter.writerStop != nil {
+		close(adapter.writerStop)
+		adapter.writerStop = nil
+		adapter.writeRequests = nil
+	}
+}
+
+// Write a whole message on the provided connection with the write timeout if enabled. Write
+// mutex must be held.
+func (adapter *GorillaWebsocketConnectionAdapter) writeMessage(conn *websocket.Conn, msgType wsconnadapter.MessageType, msg []byte) error {
+	// Set the write deadline if enabled and clear it once the message has been written
+	if adapter.writeTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(adapter.writeTimeout))
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	return conn.WriteMessage(int(msgType), msg)
+}
+
+// Return the deadline used to write control messages: the write timeout if set, 60 seconds
+// otherwise.
+func (adapter *GorillaWebsocketConnectionAdapter) controlWriteDeadline() time.Time {
+	if adapter.writeTimeout > 0 {
+		return time.Now().Add(adapter.writeTimeout)
+	}
+	return time.Now().Add(60 * time.Second)
+}
+
 // Handler for received Pong which will propagate a pong notification to the first active listner
 // waiting for a Pong notification.
-func (adapter *GorillaWebsocketConnectionAdapter) pongHandler(appData string) error {
+//
+// A panic in the handler is recovered: see recoverHandlerPanic.
+func (adapter *GorillaWebsocketConnectionAdapter) pongHandler(appData string) (err error) {
+	defer adapter.recoverHandlerPanic("pong handler", &err)
+	adapter.log(slog.LevelDebug, "pong received")
+	// Record the application data so the released Ping can trace it
+	adapter.lastPongAppData.Store(&appData)
 	// Propagate pong to first active listener
 	propagateToFirstActiveListener(adapter.pingRequests, nil)
 	return nil
@@ -318,20 +962,208 @@ func (adapter *GorillaWebsocketConnectionAdapter) pongHandler(appData string) er
 
 // Handler for received Close which will propagate a close error notification to all active
 // listeners waiting for a Pong notification.
-func (adapter *GorillaWebsocketConnectionAdapter) closeHandler(code int, text string) error {
+//
+// A panic in the handler is recovered: see recoverHandlerPanic.
+func (adapter *GorillaWebsocketConnectionAdapter) closeHandler(code int, text string) (err error) {
+	defer adapter.recoverHandlerPanic("close handler", &err)
+	adapter.log(slog.LevelDebug, "close message received", "close_code", code, "reason", text)
 	// Build a close error and propagate it to alla ctive listeners wiaiting for a Pong.
 	propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
-		Code:   wsconnadapter.StatusCode(code),
+		Code:   adapter.closeCodeNormalizer(wsconnadapter.StatusCode(code)),
 		Reason: text,
 		Err:    fmt.Errorf("close message received from server"),
 	})
 	return nil
 }
 
+// Recover from a panic in a control frame handler called by gorilla's read loop. The panic and its
+// stack trace are logged with the logger set with WithLogger, if any, and a close error with code
+// 1011 (Internal Error) is propagated to all active listeners waiting for a Pong notification. The
+// handler error is set to an error which wraps ErrControlHandlerPanic so the pending Read fails
+// and the connection is dropped.
+//
+// The method must be directly deferred by the handler.
+func (adapter *GorillaWebsocketConnectionAdapter) recoverHandlerPanic(handler string, err *error) {
+	if r := recover(); r != nil {
+		adapter.log(slog.LevelError, "recovered from panic in "+handler, "panic", r, "stack", string(debug.Stack()))
+		propagateToAllActiveListener(adapter.pingRequests, wsconnadapter.WebsocketCloseError{
+			Code:   wsconnadapter.InternalError,
+			Reason: fmt.Sprintf("panic in %s", handler),
+			Err:    fmt.Errorf("panic in %s: %v", handler, r),
+		})
+		*err = fmt.Errorf("%w: %s: %v", ErrControlHandlerPanic, handler, r)
+	}
+}
+
 /*************************************************************************************************/
 /* UTILS                                                                                         */
 /*************************************************************************************************/
 
+// Reader returned by ReadStream which converts read errors and records the message for read
+// throughput once it has been fully read.
+type gorillaStreamReader struct {
+	// Adapter which has returned the reader
+	adapter *GorillaWebsocketConnectionAdapter
+	// Connection the message is read from
+	conn *websocket.Conn
+	// Message reader returned by gorilla
+	reader io.Reader
+	// Number of bytes read so far
+	size int
+	// Flag set once the whole message has been read
+	done bool
+}
+
+// Read the message content.
+func (stream *gorillaStreamReader) Read(p []byte) (int, error) {
+	n, err := stream.reader.Read(p)
+	stream.size = stream.size + n
+	if err == io.EOF {
+		if !stream.done {
+			stream.done = true
+			stream.adapter.readStats.Record(stream.size)
+		}
+		return n, err
+	}
+	if err != nil {
+		return n, stream.adapter.convertReadError(stream.conn, err)
+	}
+	return n, nil
+}
+
+// Writer returned by WriteStream which applies the write timeout and releases the write mutex of
+// the adapter once closed.
+type gorillaStreamWriter struct {
+	// Adapter which has returned the writer
+	adapter *GorillaWebsocketConnectionAdapter
+	// Connection the message is written to
+	conn *websocket.Conn
+	// Message writer returned by gorilla
+	writer io.WriteCloser
+	// Flag set once the writer is closed
+	closed bool
+}
+
+// Write a part of the message content.
+func (stream *gorillaStreamWriter) Write(p []byte) (int, error) {
+	if stream.closed {
+		return 0, fmt.Errorf("write failed: message writer is closed")
+	}
+	// Set the write deadline if enabled and clear it once the content has been written
+	if stream.adapter.writeTimeout > 0 {
+		stream.conn.SetWriteDeadline(time.Now().Add(stream.adapter.writeTimeout))
+		defer stream.conn.SetWriteDeadline(time.Time{})
+	}
+	return stream.writer.Write(p)
+}
+
+// Send the final fragment of the message and release the write mutex.
+func (stream *gorillaStreamWriter) Close() error {
+	if stream.closed {
+		return fmt.Errorf("close failed: message writer is already closed")
+	}
+	stream.closed = true
+	defer stream.adapter.writeMu.Unlock()
+	if stream.adapter.writeTimeout > 0 {
+		stream.conn.SetWriteDeadline(time.Now().Add(stream.adapter.writeTimeout))
+		defer stream.conn.SetWriteDeadline(time.Time{})
+	}
+	return stream.writer.Close()
+}
+
+// Convert an error returned by gorilla while reading a message. Close errors, invalid close codes,
+// read limit errors and close errors raised by control frame handlers are converted to
+// wsconnadapter.WebsocketCloseError and the connection is dropped so a new one can be
+// established. Other errors are returned as is.
+func (adapter *GorillaWebsocketConnectionAdapter) convertReadError(conn *websocket.Conn, err error) error {
+	// Check if close error
+	if ce, ok := err.(*websocket.CloseError); ok {
+		// Drop the existing connection so a new one can be established
+		adapter.mu.Lock()
+		if adapter.conn == conn {
+			adapter.dropConnection()
+		}
+		adapter.mu.Unlock()
+		// Connection is closed
+		closeErr := wsconnadapter.WebsocketCloseError{
+			Code:   adapter.closeCodeNormalizer(wsconnadapter.StatusCode(ce.Code)),
+			Reason: err.Error(),
+			Err:    err,
+		}
+		// Return error
+		return closeErr
+	}
+	// Check if the server closed the connection with a close code gorilla rejects
+	if code, ok := parseBadCloseCode(err); ok {
+		// Drop the existing connection so a new one can be established
+		adapter.mu.Lock()
+		if adapter.conn == conn {
+			adapter.dropConnection()
+		}
+		adapter.mu.Unlock()
+		return wsconnadapter.WebsocketCloseError{
+			Code:   adapter.closeCodeNormalizer(wsconnadapter.StatusCode(code)),
+			Reason: err.Error(),
+			Err:    err,
+		}
+	}
+	// Check if the message exceeds the read limit - gorilla has sent a close message and
+	// the connection cannot be used anymore
+	if errors.Is(err, websocket.ErrReadLimit) {
+		// Drop and close the existing connection so a new one can be established
+		adapter.mu.Lock()
+		if adapter.conn == conn {
+			adapter.dropConnection()
+		}
+		adapter.mu.Unlock()
+		conn.Close()
+		return wsconnadapter.WebsocketCloseError{
+			Code:   wsconnadapter.MessageTooBig,
+			Reason: err.Error(),
+			Err:    fmt.Errorf("%w: %w", ErrMessageTooLarge, err),
+		}
+	}
+	// Check if a control frame handler has panicked - gorilla returns the same error for every
+	// next read so the connection cannot be used anymore
+	if errors.Is(err, ErrControlHandlerPanic) {
+		// Drop and close the existing connection so a new one can be established
+		adapter.mu.Lock()
+		if adapter.conn == conn {
+			adapter.dropConnection()
+		}
+		adapter.mu.Unlock()
+		conn.Close()
+		return fmt.Errorf("read failed: %w: %w", err, wsconnadapter.ErrNotConnected)
+	}
+	// Other errors
+	return err
+}
+
+// Log a record with the logger set with WithLogger. Do nothing if no logger is set.
+func (adapter *GorillaWebsocketConnectionAdapter) log(level slog.Level, msg string, args ...any) {
+	if adapter.logger != nil {
+		adapter.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// Prefix of the error returned by gorilla when the server sends a close code which is outside the
+// ranges defined by RFC6455
+const badCloseCodePrefix = "websocket: bad close code "
+
+// Extract the close code from the error returned by gorilla when the server sends a close code
+// which is outside the ranges defined by RFC6455.
+func parseBadCloseCode(err error) (int, bool) {
+	code, found := strings.CutPrefix(err.Error(), badCloseCodePrefix)
+	if !found {
+		return 0, false
+	}
+	value, convErr := strconv.Atoi(code)
+	if convErr != nil {
+		return 0, false
+	}
+	return value, true
+}
+
 // Propagate a notification to the first writeable (non-blocking write) channel received.
 //
 // The function returns false if the notification could not be propagated: either because no channel
@@ -356,6 +1188,47 @@ func propagateToFirstActiveListener(listeners chan chan error, notification erro
 	}
 }
 
+// Return a copy of the dialer and request headers which use the provided host for the Host header
+// and for TLS server name verification.
+func withDialHost(dialer *websocket.Dialer, requestHeader http.Header, host string) (*websocket.Dialer, http.Header) {
+	requestHeader = requestHeader.Clone()
+	if requestHeader == nil {
+		requestHeader = http.Header{}
+	}
+	requestHeader.Set("Host", host)
+	dialerCopy := *dialer
+	if dialerCopy.TLSClientConfig == nil {
+		dialerCopy.TLSClientConfig = &tls.Config{}
+	} else {
+		dialerCopy.TLSClientConfig = dialerCopy.TLSClientConfig.Clone()
+	}
+	if dialerCopy.TLSClientConfig.ServerName == "" {
+		dialerCopy.TLSClientConfig.ServerName = hostWithoutPort(host)
+	}
+	return &dialerCopy, requestHeader
+}
+
+// Extract the extensions listed in the Sec-WebSocket-Extensions headers of a handshake response.
+func parseExtensions(header http.Header) []string {
+	var extensions []string
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(value, ",") {
+			if extension = strings.TrimSpace(extension); extension != "" {
+				extensions = append(extensions, extension)
+			}
+		}
+	}
+	return extensions
+}
+
+// Remove the port from a host[:port] string.
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
 // Propagate a notification to all writeable (non-blocking write) channel received through
 // the provided channel.
 func propagateToAllActiveListener(listeners chan chan error, notification error) {
@@ -365,8 +1238,9 @@ func propagateToAllActiveListener(listeners chan chan error, notification error)
 			// We have received a channel from a listener
 			select {
 			case listener <- notification:
-				// Listener was active (or channel has capacity) - Notification has been sent
-				return
+				// Listener was active (or channel has capacity) - Notification has been sent,
+				// continue with the next listener
+				continue
 			default:
 				// Listener is not actively listening - Skip
 				continue
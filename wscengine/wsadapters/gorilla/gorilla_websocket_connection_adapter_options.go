@@ -0,0 +1,560 @@
+package gorilla
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Default capacity of the internal channel used to record pending Ping calls.
+const DefaultPingChannelCapacity = 10
+
+// Error returned by Ping when the maximum number of pending Ping calls set with
+// WithMaxPendingPings is reached.
+var ErrPingQueueFull = errors.New("too many pending ping requests")
+
+// Error returned by Dial when the TLS version negotiated with the server is lower than the
+// minimum version set with WithMinTLSVersion.
+var ErrInsecureTLSVersion = errors.New("insecure TLS version negotiated with the server")
+
+// Error wrapped in the error returned by Read when a message from the server exceeds the read
+// limit set with WithReadLimit.
+var ErrMessageTooLarge = errors.New("message exceeds the read limit")
+
+// Error wrapped in the error returned by Read when a control frame handler has panicked. The
+// connection is dropped, so the error wraps wsconnadapter.ErrNotConnected too.
+var ErrControlHandlerPanic = errors.New("panic in control frame handler")
+
+// Functional option used to customize a GorillaWebsocketConnectionAdapter when it is created.
+//
+// Options are applied in the order they are provided, after the adapter has been built with the
+// provided dialer and request headers. Options which modify the dialer work on a private copy of
+// the provided dialer so shared dialers (like websocket.DefaultDialer) are never modified.
+type GorillaAdapterOption func(adapter *GorillaWebsocketConnectionAdapter)
+
+// Policy used to retry a failed Dial with an exponential backoff.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one. Values lower than 2 disable retries.
+	MaxAttempts int
+	// Delay before the first retry. Defaults to 100ms if 0 or less.
+	InitialDelay time.Duration
+	// Maximum delay between two attempts. If 0 or less, the delay is not capped.
+	MaxDelay time.Duration
+	// Factor applied to the delay after each retry. Defaults to 2 if lower than 1.
+	Multiplier float64
+}
+
+// Return the delay to wait before the provided retry (starting at 1).
+func (policy RetryPolicy) delay(retry int) time.Duration {
+	initial := policy.InitialDelay
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(initial) * math.Pow(multiplier, float64(retry-1))
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		return policy.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// Function which transforms the server handshake response before it is returned by Dial. It can be
+// used to remove headers which must not be logged or traced. The function must return the
+// response to use, which can be the provided response or a modified copy.
+type ResponseHeaderTransformer func(resp *http.Response) *http.Response
+
+// Function which produces the headers used by a Dial call. It is called before each Dial so the
+// headers can carry short-lived credentials (signed JWT, HMAC signature, timestamp, ...) which
+// must be regenerated when the adapter reconnects.
+type HeaderProvider func(ctx context.Context) (http.Header, error)
+
+// # Description
+//
+// Option which sets the dialer used to open connections. The adapter works on a copy of the
+// provided dialer so later changes do not affect the adapter.
+//
+// The option replaces the dialer configured by the options provided before it (WithTLSConfig,
+// WithProxy, WithCompression, ...): provide it first.
+//
+// # Inputs
+//
+//   - dialer: Dialer to use. If nil, gorilla's default dialer is used.
+//
+// # Returns
+//
+// An option which sets the dialer.
+func WithDialer(dialer *websocket.Dialer) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		if dialer == nil {
+			dialer = websocket.DefaultDialer
+		}
+		dialerCopy := *dialer
+		adapter.dialer = &dialerCopy
+	}
+}
+
+// # Description
+//
+// Option which sets the headers used during Dial to specify the origin (Origin), subprotocols
+// (Sec-WebSocket-Protocol) and cookies (Cookie).
+//
+// # Inputs
+//
+//   - requestHeader: Headers to use during Dial. If nil, no additional headers are sent.
+//
+// # Returns
+//
+// An option which sets the request headers.
+func WithRequestHeader(requestHeader http.Header) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.requestHeader = requestHeader
+	}
+}
+
+// # Description
+//
+// Option which sets the TLS configuration used to connect to wss servers, for example to provide
+// client certificates (mutual TLS) or custom root CAs.
+//
+// # Inputs
+//
+//   - tlsCfg: TLS configuration to use. The configuration is cloned so later changes do not
+//     affect the adapter. If nil, the default TLS configuration is used.
+//
+// # Returns
+//
+// An option which sets the TLS configuration.
+func WithTLSConfig(tlsCfg *tls.Config) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.dialer.TLSClientConfig = tlsCfg.Clone()
+	}
+}
+
+// # Description
+//
+// Option which configures the adapter dialer to open connections through a HTTPS CONNECT proxy.
+//
+// The dialer will:
+//  1. Open a TLS connection to the proxy.
+//  2. Send a HTTP CONNECT request to the proxy to establish a tunnel to the target server.
+//  3. Perform the websocket handshake (and TLS handshake for wss) over the tunnel.
+//
+// This differs from a plain HTTP proxy where the connection to the proxy is not encrypted. Any
+// Proxy function set on the dialer is removed so the connection is not proxied twice.
+//
+// # Inputs
+//
+//   - proxyURL: URL of the HTTPS proxy (https://[user:password@]host[:port]). Port defaults to
+//     443. If user info is provided, it is used for proxy basic authentication.
+//   - proxyTLSConfig: Optional TLS configuration used to connect to the proxy. If nil, a default
+//     configuration is used. ServerName defaults to the proxy hostname.
+//
+// # Returns
+//
+// An option which configures the adapter dialer to use the HTTPS CONNECT proxy.
+func WithHTTPSConnectProxy(proxyURL *url.URL, proxyTLSConfig *tls.Config) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		tunnel := &httpsConnectProxyDialer{
+			proxyURL:       proxyURL,
+			proxyTLSConfig: proxyTLSConfig,
+		}
+		adapter.dialer.Proxy = nil
+		adapter.dialer.NetDialContext = tunnel.DialContext
+	}
+}
+
+// # Description
+//
+// Option which configures the adapter dialer to open connections through a HTTP proxy. The
+// dialer sends a HTTP CONNECT request to the proxy to establish a tunnel to the target server.
+// The connection to the proxy is not encrypted: use WithHTTPSConnectProxy for HTTPS proxies.
+//
+// By default, gorilla's default dialer uses the proxy defined by the environment (HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY). The option overrides this behavior.
+//
+// # Inputs
+//
+//   - proxyURL: URL of the HTTP proxy (http://[user:password@]host[:port]). If user info is
+//     provided, it is used for proxy basic authentication. If nil, connections are not proxied.
+//
+// # Returns
+//
+// An option which configures the adapter dialer to use the HTTP proxy.
+func WithProxy(proxyURL *url.URL) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		if proxyURL == nil {
+			adapter.dialer.Proxy = nil
+			return
+		}
+		adapter.dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// # Description
+//
+// Option which sets a function that is applied to the server handshake response before it is
+// returned by Dial, also when the handshake fails. Use it to strip sensitive headers (like
+// internal routing headers) so they are not captured in logs or traces.
+//
+// # Inputs
+//
+//   - transformer: Function applied to the handshake response. If nil, the response is returned
+//     as is.
+//
+// # Returns
+//
+// An option which sets the response header transformer.
+func WithResponseHeaderTransformer(transformer ResponseHeaderTransformer) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.responseHeaderTransformer = transformer
+	}
+}
+
+// # Description
+//
+// Option which sets the policy used by Dial to retry transient failures (connection refused or
+// reset while the server restarts, ...) before an error is returned to the caller. Only failures
+// which occur before a handshake response is received are retried: a handshake rejected by the
+// server is returned immediately. Retries stop when the Dial context is done.
+//
+// Adapter retries are independent from the engine reconnect logic: the engine only sees the
+// error returned after the last attempt.
+//
+// # Inputs
+//
+//   - policy: Policy used to retry a failed Dial.
+//
+// # Returns
+//
+// An option which sets the Dial retry policy.
+func WithDialRetryPolicy(policy RetryPolicy) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.dialRetryPolicy = policy
+	}
+}
+
+// # Description
+//
+// Option which sets the maximum number of concurrent Ping calls waiting for a pong. When the limit
+// is reached, Ping returns ErrPingQueueFull immediately instead of blocking until a pending Ping
+// completes. The limit cannot exceed the capacity of the internal ping queue (see
+// WithPingChannelCapacity): above, Ping is rejected once the queue is full.
+//
+// # Inputs
+//
+//   - max: Maximum number of pending Ping calls. If 0 or less, Ping blocks until its request can
+//     be queued or its context is done (default behavior).
+//
+// # Returns
+//
+// An option which sets the maximum number of pending Ping calls.
+func WithMaxPendingPings(max int) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.maxPendingPings = max
+	}
+}
+
+// # Description
+//
+// Option which sets the function used to normalize the close codes received from the server
+// before they are reported in WebsocketCloseError by Read and pending Ping calls. By default,
+// codes outside the ranges defined by RFC6455 (like 0, 999 or 5000) are mapped to 1006 (see
+// wsadapters.NormalizeCloseCode). Use wsadapters.NewCloseCodeNormalizer to map them to another
+// default code.
+//
+// # Inputs
+//
+//   - fn: Function used to normalize close codes. If nil, close codes are reported as is.
+//
+// # Returns
+//
+// An option which sets the close code normalizer.
+func WithCloseCodeNormalizer(fn func(code wsadapters.StatusCode) wsadapters.StatusCode) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		if fn == nil {
+			fn = func(code wsadapters.StatusCode) wsadapters.StatusCode { return code }
+		}
+		adapter.closeCodeNormalizer = fn
+	}
+}
+
+// # Description
+//
+// Option which sets the minimum TLS version which must be negotiated with the server. After the
+// handshake, Dial checks the version of the TLS connection and immediately closes it and returns
+// ErrInsecureTLSVersion if the version is lower. Connections which do not use TLS (ws) are not
+// checked.
+//
+// The check is performed in addition to the dialer TLS configuration so servers which still
+// accept insecure versions are detected even when a custom TLS configuration is used.
+//
+// # Inputs
+//
+//   - version: Minimum TLS version (like tls.VersionTLS12). If 0, the version is not checked.
+//
+// # Returns
+//
+// An option which sets the minimum TLS version.
+func WithMinTLSVersion(version uint16) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.minTLSVersion = version
+	}
+}
+
+// # Description
+//
+// Option which sets the timeout applied to each write. Before each call to Write and WriteFrom,
+// and to Write and Close on writers returned by WriteStream, the adapter sets a write deadline on
+// the connection and clears it once the content has been written so a server which stops reading
+// cannot block the writing goroutine indefinitely. The timeout is also used for the control
+// messages sent by Ping and Close instead of the default 60 seconds.
+//
+// Once a write has timed out, the connection is broken and subsequent writes fail.
+//
+// # Inputs
+//
+//   - d: Timeout applied to each write. If 0 or less, writes have no deadline and control
+//     messages use a 60 seconds deadline (default behavior).
+//
+// # Returns
+//
+// An option which sets the write timeout.
+func WithWriteTimeout(d time.Duration) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.writeTimeout = d
+	}
+}
+
+// # Description
+//
+// Option which enables permessage-deflate compression (RFC 7692). The dialer offers the extension
+// to the server during the handshake. If the server agrees, incoming messages are transparently
+// decompressed and outgoing messages are compressed with the provided compression level. Use
+// GetNegotiatedExtensions to check whether the server has agreed to use the extension.
+//
+// # Inputs
+//
+//   - level: Compression level used for outgoing messages (flate.BestSpeed (1) to
+//     flate.BestCompression (9), or flate.HuffmanOnly (-2) and flate.DefaultCompression (-1)). Dial
+//     fails if the level is invalid.
+//
+// # Returns
+//
+// An option which enables permessage-deflate compression.
+func WithCompression(level int) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.dialer.EnableCompression = true
+		adapter.compression = true
+		adapter.compressionLevel = level
+	}
+}
+
+// # Description
+//
+// Option which sets the maximum size of a message read from the server to protect against memory
+// exhaustion. When a message exceeds the limit, a close message with code 1009 (Message Too Big)
+// is sent to the server, the connection is dropped and Read returns a
+// wsadapters.WebsocketCloseError which wraps ErrMessageTooLarge.
+//
+// # Inputs
+//
+//   - bytes: Maximum size of a message in bytes. If 0 or less, the message size is not limited
+//     (default behavior).
+//
+// # Returns
+//
+// An option which sets the read limit.
+func WithReadLimit(bytes int64) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.readLimit = bytes
+	}
+}
+
+// # Description
+//
+// Option which sets a structured logger the adapter logs its activity to: pings sent, pongs and
+// close messages received at DEBUG level, dial retries at WARN level and panics recovered in
+// control frame handlers at ERROR level.
+//
+// # Inputs
+//
+//   - logger: Logger to use. If nil, the adapter does not log (default behavior).
+//
+// # Returns
+//
+// An option which sets the logger.
+func WithLogger(logger *slog.Logger) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.logger = logger
+	}
+}
+
+// # Description
+//
+// Option which sets the duration of the sliding window used to compute the read throughput
+// returned by ReadStats.
+//
+// # Inputs
+//
+//   - window: Duration of the sliding window. If 0 or less, the default window of 10 seconds is
+//     used (default behavior).
+//
+// # Returns
+//
+// An option which sets the read throughput window.
+func WithReadStatsWindow(window time.Duration) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.readStatsWindow = window
+	}
+}
+
+// # Description
+//
+// Option which sets a function called before each Dial to produce the headers used during the
+// handshake. The produced headers replace the static request headers provided to the factory
+// or set with WithRequestHeader. The headers are produced once per Dial call: retries performed
+// by the dial retry policy reuse them.
+//
+// If the function returns an error, Dial fails with an error which wraps it and no connection is
+// attempted.
+//
+// # Inputs
+//
+//   - provider: Function which produces the request headers. If nil, the static request headers
+//     are used (default behavior).
+//
+// # Returns
+//
+// An option which sets the header provider.
+func WithHeaderProvider(provider HeaderProvider) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.headerProvider = provider
+	}
+}
+
+// # Description
+//
+// Option which enables a dedicated writer goroutine per connection. Write calls hand over their
+// message to the writer goroutine through a queue and wait for the result of the write, instead
+// of holding the adapter mutexes while the message is written: concurrent writers do not contend
+// on the adapter mutexes and reads, pings and Close are never delayed by a slow write. Messages
+// are still written one at a time as gorilla does not support concurrent writers.
+//
+// WriteFrom, WriteStream and WriteFromSized are not affected by the option.
+//
+// # Inputs
+//
+//   - queueSize: Number of messages which can wait in the queue of the writer goroutine. If 0,
+//     Write waits until the writer goroutine is ready to dequeue its message.
+//
+// # Returns
+//
+// An option which enables the writer goroutine.
+func WithWriterGoroutine(queueSize int) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.writerEnabled = true
+		adapter.writerQueueSize = max(queueSize, 0)
+	}
+}
+
+// # Description
+//
+// Option which sets the OpenTelemetry tracer provider used to trace the ping/pong cycles: each
+// Ping call creates a wscengine.ping span and the received pong is recorded as a wscengine.pong
+// event.
+//
+// # Inputs
+//
+//   - tracerProvider: Tracer provider to use. If nil, the global tracer provider is used (default
+//     behavior).
+//
+// # Returns
+//
+// An option which sets the tracer provider.
+func WithTracerProvider(tracerProvider trace.TracerProvider) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.tracer = nil
+		if tracerProvider != nil {
+			adapter.tracer = tracerProvider.Tracer(pkgName, trace.WithInstrumentationVersion(pkgVersion))
+		}
+	}
+}
+
+// # Description
+//
+// Option which sets the upgrader used by UpgradeFromHTTP to accept connections from clients
+// (buffer sizes, subprotocols, origin check, ...). The adapter works on a copy of the provided
+// upgrader so later changes do not affect the adapter. Compression is negotiated regardless of
+// the upgrader settings when WithCompression is used.
+//
+// # Inputs
+//
+//   - upgrader: Upgrader to use. If nil, an upgrader with gorilla's default settings is used
+//     (default behavior). By default, gorilla upgraders reject cross-origin requests.
+//
+// # Returns
+//
+// An option which sets the upgrader.
+func WithUpgrader(upgrader *websocket.Upgrader) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.upgrader = websocket.Upgrader{}
+		if upgrader != nil {
+			adapter.upgrader = *upgrader
+		}
+	}
+}
+
+// # Description
+//
+// Option which sets the propagation mode used by WritePropagated to embed the trace context in
+// outgoing messages.
+//
+// # Inputs
+//
+//   - mode: Propagation mode to use. Defaults to wsadapters.None: messages are written as is.
+//
+// # Returns
+//
+// An option which sets the propagation mode.
+func WithPropagationMode(mode wsadapters.PropagationMode) GorillaAdapterOption {
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.propagationMode = mode
+	}
+}
+
+// # Description
+//
+// Option which sets the capacity of the internal channel used to record pending Ping calls. Once
+// the channel is full, Ping blocks until a pending Ping completes, or is rejected with
+// ErrPingQueueFull when WithMaxPendingPings is used. Increase it for applications which issue many
+// concurrent pings, like latency benchmarks.
+//
+// # Inputs
+//
+//   - n: Capacity of the channel. Defaults to DefaultPingChannelCapacity. The option panics if n
+//     is lower than 1.
+//
+// # Returns
+//
+// An option which sets the capacity of the ping channel.
+func WithPingChannelCapacity(n int) GorillaAdapterOption {
+	if n < 1 {
+		panic(fmt.Sprintf("ping channel capacity must be at least 1: %d", n))
+	}
+	return func(adapter *GorillaWebsocketConnectionAdapter) {
+		adapter.pingRequests = make(chan chan error, n)
+	}
+}
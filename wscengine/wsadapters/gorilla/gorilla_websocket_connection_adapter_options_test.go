@@ -0,0 +1,896 @@
+package gorilla
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+// Test suite used for GorillaAdapterOption unit tests
+type GorillaAdapterOptionsTestSuite struct {
+	suite.Suite
+}
+
+// Run GorillaAdapterOptionsTestSuite test suite
+func TestGorillaAdapterOptionsTestSuite(t *testing.T) {
+	suite.Run(t, new(GorillaAdapterOptionsTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the response header transformer is applied to the handshake response returned by Dial.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithResponseHeaderTransformer() {
+	// Start a server which returns an internal header in its handshake response
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Internal-Backend-Ip": []string{"10.0.0.1"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	// Create adapter with a transformer which strips the internal header
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithResponseHeaderTransformer(func(resp *http.Response) *http.Response {
+		resp.Header.Del("X-Internal-Backend-Ip")
+		return resp
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Empty(suite.T(), resp.Header.Get("X-Internal-Backend-Ip"))
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test the header provider is called before each Dial and its headers replace the static ones.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithHeaderProvider() {
+	// Start a server which records the Authorization and Origin headers of each handshake
+	headers := make(chan http.Header, 2)
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	// Provider which signs each handshake with a new token
+	calls := atomic.Int32{}
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Origin": []string{"static"}}, WithHeaderProvider(func(ctx context.Context) (http.Header, error) {
+		return http.Header{"Authorization": []string{"Bearer " + strconv.Itoa(int(calls.Add(1)))}}, nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 2; i++ {
+		_, err = adapter.Dial(ctx, *target)
+		require.NoError(suite.T(), err)
+		received := <-headers
+		require.Equal(suite.T(), "Bearer "+strconv.Itoa(i), received.Get("Authorization"))
+		require.Empty(suite.T(), received.Get("Origin"))
+		require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	}
+	// Dial fails without connecting if the provider fails
+	adapter = NewGorillaWebsocketConnectionAdapter(nil, nil, WithHeaderProvider(func(ctx context.Context) (http.Header, error) {
+		return nil, context.DeadlineExceeded
+	}))
+	_, err = adapter.Dial(ctx, *target)
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	require.Empty(suite.T(), headers)
+}
+
+// Test options do not modify the provided dialer.
+func (suite *GorillaAdapterOptionsTestSuite) TestOptionsDoNotModifyProvidedDialer() {
+	dialer := &websocket.Dialer{}
+	proxyURL, err := url.Parse("https://proxy.example.com")
+	require.NoError(suite.T(), err)
+	adapter := NewGorillaWebsocketConnectionAdapter(dialer, nil, WithHTTPSConnectProxy(proxyURL, nil))
+	require.NotNil(suite.T(), adapter.dialer.NetDialContext)
+	require.Nil(suite.T(), dialer.NetDialContext)
+}
+
+// Test an adapter configured only with options connects with the provided TLS configuration and
+// request headers.
+func (suite *GorillaAdapterOptionsTestSuite) TestNewGorillaWebsocketConnectionAdapterWithOptions() {
+	// Start a TLS server which records the Origin header
+	origins := make(chan string, 1)
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origins <- r.Header.Get("Origin")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("wss" + strings.TrimPrefix(srv.URL, "https"))
+	require.NoError(suite.T(), err)
+	// Without options, the adapter uses the default dialer
+	require.Same(suite.T(), websocket.DefaultDialer, NewGorillaWebsocketConnectionAdapterWithOptions().dialer)
+	// Options are applied on a copy of the provided dialer
+	dialer := &websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	tlsCfg := srv.Client().Transport.(*http.Transport).TLSClientConfig
+	adapter := NewGorillaWebsocketConnectionAdapterWithOptions(
+		WithDialer(dialer),
+		WithRequestHeader(http.Header{"Origin": []string{"https://example.com"}}),
+		WithTLSConfig(tlsCfg),
+		WithReadLimit(1024),
+		WithCompression(flate.BestSpeed),
+	)
+	require.Nil(suite.T(), dialer.TLSClientConfig)
+	require.False(suite.T(), dialer.EnableCompression)
+	require.Equal(suite.T(), 5*time.Second, adapter.dialer.HandshakeTimeout)
+	require.NotSame(suite.T(), tlsCfg, adapter.dialer.TLSClientConfig)
+	require.Equal(suite.T(), int64(1024), adapter.readLimit)
+	// Connect
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "https://example.com", <-origins)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test Ping is rejected without blocking once the maximum number of pending Ping is reached.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithMaxPendingPings() {
+	// Start a server which answers pings - pongs are never processed as the client does not read
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	// Create adapter with a limit of 10 pending pings and connect
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithMaxPendingPings(10))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Start 10 pings which remain pending
+	pingCtx, pingCancel := context.WithCancel(ctx)
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			results <- adapter.Ping(pingCtx)
+		}()
+	}
+	require.Eventually(suite.T(), func() bool {
+		return adapter.pendingPings.Load() == 10
+	}, 3*time.Second, 10*time.Millisecond)
+	// 11th ping must be rejected immediately
+	require.ErrorIs(suite.T(), adapter.Ping(ctx), ErrPingQueueFull)
+	// Cancel pending pings
+	pingCancel()
+	for i := 0; i < 10; i++ {
+		require.ErrorIs(suite.T(), <-results, context.Canceled)
+	}
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test WithPingChannelCapacity: more concurrent pings than the default capacity can be pending.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithPingChannelCapacity() {
+	require.Panics(suite.T(), func() { WithPingChannelCapacity(0) })
+	require.Equal(suite.T(), DefaultPingChannelCapacity, cap(NewGorillaWebsocketConnectionAdapter(nil, nil).pingRequests))
+	// Start a server which answers pings - pongs are never processed as the client does not read
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithPingChannelCapacity(20), WithMaxPendingPings(20))
+	require.Equal(suite.T(), 20, cap(adapter.pingRequests))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Start 20 pings which remain pending - all requests are recorded
+	pingCtx, pingCancel := context.WithCancel(ctx)
+	results := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func() {
+			results <- adapter.Ping(pingCtx)
+		}()
+	}
+	require.Eventually(suite.T(), func() bool {
+		return len(adapter.pingRequests) == 20
+	}, 3*time.Second, 10*time.Millisecond)
+	require.ErrorIs(suite.T(), adapter.Ping(ctx), ErrPingQueueFull)
+	pingCancel()
+	for i := 0; i < 20; i++ {
+		require.ErrorIs(suite.T(), <-results, context.Canceled)
+	}
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test Dial retries transient failures according to the retry policy.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithDialRetryPolicy() {
+	// Start a server which accepts websocket connections
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	// Create a dialer which fails the two first connections with a reset
+	attempts := atomic.Int32{}
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if attempts.Add(1) <= 2 {
+				return nil, syscall.ECONNRESET
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Dial fails when attempts are exhausted
+	adapter := NewGorillaWebsocketConnectionAdapter(dialer, nil, WithDialRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond}))
+	_, err = adapter.Dial(ctx, *target)
+	require.ErrorIs(suite.T(), err, syscall.ECONNRESET)
+	require.Equal(suite.T(), int32(2), attempts.Load())
+	// Dial succeeds on the third attempt
+	attempts.Store(0)
+	adapter = NewGorillaWebsocketConnectionAdapter(dialer, nil, WithDialRetryPolicy(policy))
+	resp, err := adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(suite.T(), int32(3), attempts.Load())
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	// Handshake rejected by the server is not retried
+	attempts.Store(2)
+	adapter = NewGorillaWebsocketConnectionAdapter(dialer, nil, WithDialRetryPolicy(policy))
+	forbidden := *target
+	forbidden.Path = "/forbidden"
+	resp, err = adapter.Dial(ctx, forbidden)
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	require.Equal(suite.T(), int32(3), attempts.Load())
+}
+
+// Test close codes outside RFC6455 ranges are normalized by Read.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithCloseCodeNormalizer() {
+	// Start a server which closes connections with the close code provided as path
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, "bye"))
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	testCases := []struct {
+		code     int
+		opts     []GorillaAdapterOption
+		expected wsadapters.StatusCode
+	}{
+		// Default normalization
+		{code: 5000, expected: wsadapters.AbnormalClosure},
+		{code: 4000, expected: wsadapters.StatusCode(4000)},
+		{code: 1001, expected: wsadapters.GoingAway},
+		// Custom default code
+		{code: 999, opts: []GorillaAdapterOption{WithCloseCodeNormalizer(wsadapters.NewCloseCodeNormalizer(wsadapters.GoingAway))}, expected: wsadapters.GoingAway},
+		// Normalization disabled
+		{code: 5000, opts: []GorillaAdapterOption{WithCloseCodeNormalizer(nil)}, expected: wsadapters.StatusCode(5000)},
+	}
+	for _, tc := range testCases {
+		adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, tc.opts...)
+		closeTarget := *target
+		closeTarget.Path = "/" + strconv.Itoa(tc.code)
+		_, err := adapter.Dial(ctx, closeTarget)
+		require.NoError(suite.T(), err)
+		_, _, err = adapter.Read(ctx)
+		closeErr := new(wsadapters.WebsocketCloseError)
+		require.ErrorAs(suite.T(), err, closeErr)
+		require.Equal(suite.T(), tc.expected, closeErr.Code, "code %d", tc.code)
+		// Connection is dropped so a new one can be established
+		require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	}
+}
+
+// Test Dial fails when the negotiated TLS version is lower than the minimum version.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithMinTLSVersion() {
+	// Start a TLS server which only accepts TLS 1.0 and 1.1
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	srv.StartTLS()
+	defer srv.Close()
+	target, err := url.Parse("wss" + strings.TrimPrefix(srv.URL, "https"))
+	require.NoError(suite.T(), err)
+	// Dialer which trusts the test server and accepts TLS 1.1
+	dialer := &websocket.Dialer{TLSClientConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()}
+	dialer.TLSClientConfig.MinVersion = tls.VersionTLS10
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Without minimum version, the connection is accepted
+	adapter := NewGorillaWebsocketConnectionAdapter(dialer, nil)
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	// With TLS 1.2 as minimum version, the connection is rejected
+	adapter = NewGorillaWebsocketConnectionAdapter(dialer, nil, WithMinTLSVersion(tls.VersionTLS12))
+	resp, err := adapter.Dial(ctx, *target)
+	require.ErrorIs(suite.T(), err, ErrInsecureTLSVersion)
+	require.NotNil(suite.T(), resp)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	// With TLS 1.1 as minimum version, the connection is accepted
+	adapter = NewGorillaWebsocketConnectionAdapter(dialer, nil, WithMinTLSVersion(tls.VersionTLS11))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test a write to a server which stops reading is unblocked within the configured write timeout.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithWriteTimeout() {
+	// Start a server which never reads messages
+	upgrader := websocket.Upgrader{}
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	timeout := 200 * time.Millisecond
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithWriteTimeout(timeout))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Write large messages until socket buffers are full and a write blocks
+	msg := make([]byte, 1<<20)
+	for err == nil && ctx.Err() == nil {
+		start := time.Now()
+		err = adapter.Write(ctx, wsadapters.Binary, msg)
+		if err != nil {
+			// Blocked write has been unblocked by the write deadline
+			netErr, ok := err.(net.Error)
+			require.True(suite.T(), ok)
+			require.True(suite.T(), netErr.Timeout())
+			require.Less(suite.T(), time.Since(start), timeout+time.Second)
+		}
+	}
+	require.Error(suite.T(), err)
+}
+
+// Test concurrent writes are performed by the writer goroutine of the connection and a new
+// writer goroutine is started for each connection.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithWriterGoroutine() {
+	// Start a server which records the received messages
+	upgrader := websocket.Upgrader{}
+	received := make(chan []byte, 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithWriterGoroutine(8))
+	require.ErrorIs(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("too early")), wsadapters.ErrNotConnected)
+	for round := 1; round <= 2; round++ {
+		_, err = adapter.Dial(ctx, *target)
+		require.NoError(suite.T(), err)
+		// Concurrent writers
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte(strconv.Itoa(i))))
+			}(i)
+		}
+		wg.Wait()
+		require.Eventually(suite.T(), func() bool {
+			return len(received) == 100*round
+		}, 5*time.Second, time.Millisecond)
+		require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+		require.ErrorIs(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("closed")), wsadapters.ErrNotConnected)
+	}
+}
+
+// Test a message queued for the writer goroutine is not written once the context of its caller
+// has been canceled.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithWriterGoroutineCanceledWrite() {
+	// Start a server which records the received messages
+	upgrader := websocket.Upgrader{}
+	received := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithWriterGoroutine(8))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Keep the writer goroutine busy with an open stream writer
+	stream, err := adapter.WriteStream(ctx, wsadapters.Text)
+	require.NoError(suite.T(), err)
+	// Queue a message and cancel its context while it waits for the writer goroutine
+	writeCtx, cancelWrite := context.WithCancel(ctx)
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- adapter.Write(writeCtx, wsadapters.Text, []byte("canceled"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancelWrite()
+	require.ErrorIs(suite.T(), <-writeErr, context.Canceled)
+	// Release the writer goroutine - the canceled message must be skipped
+	_, err = stream.Write([]byte("streamed"))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), stream.Close())
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("after")))
+	require.Equal(suite.T(), []byte("streamed"), <-received)
+	require.Equal(suite.T(), []byte("after"), <-received)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+}
+
+// Test messages are exchanged with permessage-deflate compression when the server agrees to use
+// the extension.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithCompression() {
+	// Start an echo server which supports compression if enabled by the query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{EnableCompression: r.URL.Query().Has("compress")}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http") + "?compress")
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Extension is negotiated and messages are echoed
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithCompression(flate.BestCompression))
+	require.Nil(suite.T(), adapter.GetNegotiatedExtensions())
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	extensions := adapter.GetNegotiatedExtensions()
+	require.Len(suite.T(), extensions, 1)
+	require.True(suite.T(), strings.HasPrefix(extensions[0], "permessage-deflate"))
+	msg := []byte(strings.Repeat("compressible ", 1000))
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, msg))
+	_, echoed, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), msg, echoed)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	require.Nil(suite.T(), adapter.GetNegotiatedExtensions())
+	// No extension is negotiated when the server does not support compression
+	target.RawQuery = ""
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), adapter.GetNegotiatedExtensions())
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, msg))
+	_, echoed, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), msg, echoed)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	// Dial fails with an invalid compression level
+	adapter = NewGorillaWebsocketConnectionAdapter(nil, nil, WithCompression(42))
+	_, err = adapter.Dial(ctx, *target)
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+}
+
+// Test Read fails with ErrMessageTooLarge when a message exceeds the read limit.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithReadLimit() {
+	// Start a server which sends a small message and then a large message
+	upgrader := websocket.Upgrader{}
+	closeCodes := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.BinaryMessage, make([]byte, 10))
+		conn.WriteMessage(websocket.BinaryMessage, make([]byte, 100))
+		// Record the close code sent by the client
+		_, _, err = conn.ReadMessage()
+		if ce, ok := err.(*websocket.CloseError); ok {
+			closeCodes <- ce.Code
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithReadLimit(50))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Message under the limit is read
+	_, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), msg, 10)
+	// Message over the limit is rejected and connection is dropped
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, ErrMessageTooLarge)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.MessageTooBig, closeErr.Code)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	select {
+	case code := <-closeCodes:
+		require.Equal(suite.T(), websocket.CloseMessageTooBig, code)
+	case <-ctx.Done():
+		suite.FailNow("server did not receive the close message")
+	}
+}
+
+// Test the adapter logs ping/pong cycles and close messages with the provided logger.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithLogger() {
+	// Start a server which replies to pings and closes the connection when it receives a message
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"), time.Now().Add(time.Second))
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithLogger(logger))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Read in a separate goroutine to process pong and close messages
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := adapter.Read(ctx)
+		readErr <- err
+	}()
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("close")))
+	require.Error(suite.T(), <-readErr)
+	output := logs.String()
+	require.Contains(suite.T(), output, "level=DEBUG msg=\"ping sent\"")
+	require.Contains(suite.T(), output, "level=DEBUG msg=\"pong received\"")
+	require.Contains(suite.T(), output, "level=DEBUG msg=\"close message received\" close_code=1000 reason=bye")
+}
+
+// Test the adapter opens connections through the HTTP proxy set with WithProxy.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithProxy() {
+	// Start echo server and proxy
+	srv := newTestEchoServer()
+	defer srv.Close()
+	counter := new(atomic.Int64)
+	expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	proxy := newTestHTTPConnectProxy(counter, expectedAuth)
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(suite.T(), err)
+	proxyURL.User = url.UserPassword("user", "secret")
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Connection is tunneled through the proxy
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithProxy(proxyURL))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), int64(1), counter.Load())
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("hello")))
+	_, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "hello", string(msg))
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	// Default dialer must not be modified
+	require.NotNil(suite.T(), websocket.DefaultDialer.Proxy)
+	// Nil proxy URL disables the proxy
+	adapter = NewGorillaWebsocketConnectionAdapter(nil, nil, WithProxy(nil))
+	require.Nil(suite.T(), adapter.dialer.Proxy)
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), int64(1), counter.Load())
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test each Ping creates a span which records the pong and fails when no pong is received.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithTracerProvider() {
+	// Start a server which reads messages - pongs are sent automatically by gorilla - or which
+	// never reads
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if r.URL.Query().Has("silent") {
+			time.Sleep(time.Second)
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	provider := &pingTracerProvider{}
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithTracerProvider(provider))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	go adapter.Read(ctx)
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""))
+	require.Len(suite.T(), provider.spans, 2)
+	for index, span := range provider.spans {
+		appData := strconv.Itoa(index + 1)
+		require.Equal(suite.T(), spanPing, span.name)
+		require.Equal(suite.T(), codes.Ok, span.status)
+		require.True(suite.T(), span.ended)
+		require.Equal(suite.T(), []string{eventPong}, span.events)
+		require.Equal(suite.T(), []attribute.KeyValue{attribute.String(attrAppData, appData)}, span.eventAttrs)
+	}
+	// Span status is set to error when no pong is received
+	silent := *target
+	silent.RawQuery = "silent"
+	_, err = adapter.Dial(ctx, silent)
+	require.NoError(suite.T(), err)
+	pingCtx, pingCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer pingCancel()
+	require.Error(suite.T(), adapter.Ping(pingCtx))
+	require.Len(suite.T(), provider.spans, 3)
+	require.Equal(suite.T(), codes.Error, provider.spans[2].status)
+	require.Empty(suite.T(), provider.spans[2].events)
+	adapter.Close(ctx, wsadapters.NormalClosure, "")
+}
+
+// Test WithPropagationMode: propagated messages carry the trace context of the caller.
+func (suite *GorillaAdapterOptionsTestSuite) TestWithPropagationMode() {
+	// Start a server which echoes messages
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(msgType, msg)
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithPropagationMode(wsadapters.JSONEnvelope))
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	defer adapter.Close(ctx, wsadapters.NormalClosure, "")
+	require.NoError(suite.T(), adapter.WritePropagated(ctx, wsadapters.Text, []byte(`{"op":"ping"}`), propagation.TraceContext{}))
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	extracted, msgType, payload, err := wsadapters.ExtractTraceContext(context.Background(), wsadapters.JSONEnvelope, msgType, msg, propagation.TraceContext{})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.JSONEq(suite.T(), `{"op":"ping"}`, string(payload))
+	require.Equal(suite.T(), trace.SpanID{2}, trace.SpanContextFromContext(extracted).SpanID())
+	// Messages which cannot be wrapped are not written
+	err = adapter.WritePropagated(ctx, wsadapters.Text, []byte("not json"), propagation.TraceContext{})
+	require.ErrorIs(suite.T(), err, wsadapters.ErrInvalidEnvelopePayload)
+}
+
+// Test retry policy delays.
+func (suite *GorillaAdapterOptionsTestSuite) TestRetryPolicyDelay() {
+	policy := RetryPolicy{InitialDelay: time.Second, MaxDelay: 3 * time.Second}
+	require.Equal(suite.T(), time.Second, policy.delay(1))
+	require.Equal(suite.T(), 2*time.Second, policy.delay(2))
+	require.Equal(suite.T(), 3*time.Second, policy.delay(3))
+	require.Equal(suite.T(), 100*time.Millisecond, RetryPolicy{}.delay(1))
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Buffer safe for concurrent use
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (buffer *lockedBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.Write(p)
+}
+
+func (buffer *lockedBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.String()
+}
+
+// Minimal TracerProvider which records the spans started by Ping
+type pingTracerProvider struct {
+	embedded.TracerProvider
+	spans []*pingSpan
+}
+
+func (provider *pingTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return &pingTracer{provider: provider}
+}
+
+type pingTracer struct {
+	embedded.Tracer
+	provider *pingTracerProvider
+}
+
+// Record the span - Ping is called sequentially by the test
+func (tracer *pingTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &pingSpan{Span: trace.SpanFromContext(ctx), name: spanName}
+	tracer.provider.spans = append(tracer.provider.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// Span which records its name, events, status and whether it has ended
+type pingSpan struct {
+	trace.Span
+	name       string
+	events     []string
+	eventAttrs []attribute.KeyValue
+	status     codes.Code
+	ended      bool
+}
+
+func (span *pingSpan) AddEvent(name string, options ...trace.EventOption) {
+	span.events = append(span.events, name)
+	cfg := trace.NewEventConfig(options...)
+	span.eventAttrs = append(span.eventAttrs, cfg.Attributes()...)
+}
+
+func (span *pingSpan) SetStatus(code codes.Code, description string) {
+	span.status = code
+}
+
+func (span *pingSpan) End(options ...trace.SpanEndOption) {
+	span.ended = true
+}
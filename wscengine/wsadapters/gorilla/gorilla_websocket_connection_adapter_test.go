@@ -1,21 +1,68 @@
 package gorilla
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/gbdevw/gowse/echowsserver"
+	"github.com/gbdevw/gowse/wscengine"
 	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wstest"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
+/*************************************************************************************************/
+/* TEST MAIN                                                                                     */
+/*************************************************************************************************/
+
+// URL of the echo server shared by tests - set by TestMain
+var echoSrvURL url.URL
+
+// Start an echo server shared by tests, run tests and stop the server. Each connection is served
+// by its own goroutine so tests can safely use the server concurrently.
+func TestMain(m *testing.M) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Echo messages with the same message type until connection is closed
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	u, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		panic(err)
+	}
+	echoSrvURL = *u
+	code := m.Run()
+	srv.Close()
+	os.Exit(code)
+}
+
 /*************************************************************************************************/
 /* TEST SUITE                                                                                    */
 /*************************************************************************************************/
@@ -64,7 +111,7 @@ func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestDialWhenAlreadyConn
 	require.NotNil(suite.T(), resp)
 	// Connect to server again
 	resp, err = adapter.Dial(timeoutCtx, *u)
-	require.Error(suite.T(), err)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrAlreadyConnected)
 	require.Nil(suite.T(), resp)
 	// Close connection
 	err = adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye")
@@ -88,6 +135,39 @@ func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestDialWithoutPeer() {
 	require.Nil(suite.T(), resp)
 }
 
+// Test the adapter created with a custom TLS configuration can connect to a server signed by a
+// custom CA.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestNewWithTLS() {
+	// Start a TLS server which uses a self-signed certificate
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("wss" + strings.TrimPrefix(srv.URL, "https"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Default TLS configuration does not trust the server certificate
+	adapter := NewGorillaWebsocketConnectionAdapterWithTLS(nil, nil)
+	_, err = adapter.Dial(ctx, *target)
+	require.Error(suite.T(), err)
+	// TLS configuration which trusts the server certificate - config is cloned
+	tlsCfg := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	adapter = NewGorillaWebsocketConnectionAdapterWithTLS(tlsCfg, nil)
+	tlsCfg.RootCAs = nil
+	require.NotNil(suite.T(), adapter.dialer.TLSClientConfig.RootCAs)
+	require.NotNil(suite.T(), adapter.dialer.Proxy)
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
 // Test Read and Write methods by performing sending and reading multiple echo messages
 func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestEcho() {
 	// Start a echo server
@@ -233,6 +313,89 @@ func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestPendingPingWhenConn
 	srv.Stop()
 }
 
+// Test all pending Ping calls are interrupted when connection is closed, not only the first one.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestPendingPingsWhenConnClose() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Start goroutines that will remain stuck on Ping (no read = no pong notification)
+	pingCount := 3
+	notifications := make(chan error, pingCount)
+	for i := 0; i < pingCount; i++ {
+		go func() {
+			notifications <- adapter.Ping(timeoutCtx)
+		}()
+	}
+	// Close connection so all Ping calls are interrupted
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.GoingAway, "close client connection"))
+	for i := 0; i < pingCount; i++ {
+		select {
+		case err := <-notifications:
+			require.ErrorAs(suite.T(), err, new(wsadapters.WebsocketCloseError))
+		case <-time.After(time.Second):
+			suite.FailNow("pending Ping has not been interrupted by Close")
+		}
+	}
+}
+
+// Test Drop closes the connection without close message and interrupts pending Ping calls.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestDrop() {
+	// Start a server which reports the close error it reads
+	closes := make(chan error, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, err = conn.ReadMessage()
+		closes <- err
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	// Connect and start a Ping which remains stuck (no read = no pong notification)
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = adapter.Dial(timeoutCtx, *target)
+	require.NoError(suite.T(), err)
+	notification := make(chan error, 1)
+	go func() {
+		notification <- adapter.Ping(timeoutCtx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	// Drop the connection - server does not receive any close message: depending on whether the
+	// pong it sent has been read, the server sees an abnormal closure or a connection reset
+	require.NoError(suite.T(), adapter.Drop(timeoutCtx))
+	select {
+	case err := <-closes:
+		require.Error(suite.T(), err)
+		closeErr := new(websocket.CloseError)
+		if errors.As(err, &closeErr) {
+			require.Equal(suite.T(), websocket.CloseAbnormalClosure, closeErr.Code)
+		}
+	case <-timeoutCtx.Done():
+		suite.FailNow("server has not detected the dropped connection")
+	}
+	select {
+	case err := <-notification:
+		closeErr := new(wsadapters.WebsocketCloseError)
+		require.ErrorAs(suite.T(), err, closeErr)
+		require.Equal(suite.T(), wsadapters.AbnormalClosure, closeErr.Code)
+	case <-timeoutCtx.Done():
+		suite.FailNow("pending Ping has not been interrupted by Drop")
+	}
+	// Connection is gone
+	require.ErrorIs(suite.T(), adapter.Drop(timeoutCtx), wsadapters.ErrNotConnected)
+	require.ErrorIs(suite.T(), adapter.Write(timeoutCtx, wsadapters.Text, []byte("hello")), wsadapters.ErrNotConnected)
+}
+
 // Test Ping timeout
 func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestPingTimeout() {
 	// Start a echo server
@@ -269,18 +432,19 @@ func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestMethodsWithoutActiv
 	require.NotNil(suite.T(), adapter)
 	// Test close
 	err := adapter.Close(context.Background(), wsadapters.GoingAway, "")
-	require.Error(suite.T(), err)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
 	// Test Ping
 	err = adapter.Ping(context.Background())
-	require.Error(suite.T(), err)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrPingFailed)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
 	// Test Read
 	msgType, msg, err := adapter.Read(context.Background())
-	require.Error(suite.T(), err)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
 	require.Less(suite.T(), msgType, 0)
 	require.Empty(suite.T(), msg)
 	// Test Write
 	err = adapter.Write(context.Background(), wsadapters.Text, []byte("hello"))
-	require.Error(suite.T(), err)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
 	// Test GetUnderlyingWebsocketConnection
 	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
 }
@@ -340,6 +504,420 @@ func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestGetUnderlyingWebsoc
 	srv.Stop()
 }
 
+// Test the subprotocol selected by the server is returned by NegotiatedSubprotocol
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestNegotiatedSubprotocol() {
+	// Start a server which supports graphql-transport-ws only
+	upgrader := websocket.Upgrader{Subprotocols: []string{"graphql-transport-ws"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Propose two subprotocols - server selects the one it supports
+	adapter := NewGorillaWebsocketConnectionAdapterWithOptions(
+		WithRequestHeader(http.Header{"Sec-WebSocket-Protocol": []string{"graphql-ws, graphql-transport-ws"}}))
+	require.Empty(suite.T(), adapter.NegotiatedSubprotocol())
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "graphql-transport-ws", adapter.NegotiatedSubprotocol())
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	require.Empty(suite.T(), adapter.NegotiatedSubprotocol())
+	// No subprotocol proposed
+	adapter = NewGorillaWebsocketConnectionAdapter(nil, nil)
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), adapter.NegotiatedSubprotocol())
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test UpgradeFromHTTP upgrades a request received by a HTTP handler: the connection works like a
+// connection opened with Dial.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestUpgradeFromHTTP() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Start a server which upgrades requests with an adapter and echoes messages
+	adapters := make(chan *GorillaWebsocketConnectionAdapter, 1)
+	responses := make(chan *http.Response, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adapter := NewGorillaWebsocketConnectionAdapterWithOptions(
+			WithUpgrader(&websocket.Upgrader{Subprotocols: []string{"chat"}}),
+			WithCompression(1))
+		res, err := adapter.UpgradeFromHTTP(w, r, http.Header{"Set-Cookie": []string{"id=1"}})
+		if err != nil {
+			return
+		}
+		// Upgrade again
+		_, err = adapter.UpgradeFromHTTP(w, r, nil)
+		require.ErrorIs(suite.T(), err, wsadapters.ErrAlreadyConnected)
+		responses <- res
+		adapters <- adapter
+		for {
+			msgType, msg, err := adapter.Read(ctx)
+			if err != nil {
+				return
+			}
+			if err := adapter.Write(ctx, msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	client := NewGorillaWebsocketConnectionAdapterWithOptions(
+		WithRequestHeader(http.Header{"Sec-WebSocket-Protocol": []string{"chat"}}),
+		WithCompression(1))
+	clientRes, err := client.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Check the handshake response returned by UpgradeFromHTTP
+	res := <-responses
+	server := <-adapters
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, res.StatusCode)
+	require.Equal(suite.T(), clientRes.Header.Get("Sec-WebSocket-Accept"), res.Header.Get("Sec-WebSocket-Accept"))
+	require.Equal(suite.T(), "chat", res.Header.Get("Sec-WebSocket-Protocol"))
+	require.Equal(suite.T(), "id=1", res.Header.Get("Set-Cookie"))
+	require.Equal(suite.T(), "chat", server.NegotiatedSubprotocol())
+	require.Equal(suite.T(), clientRes.Header.Get("Sec-WebSocket-Extensions"), res.Header.Get("Sec-WebSocket-Extensions"))
+	require.Equal(suite.T(), client.GetNegotiatedExtensions(), server.GetNegotiatedExtensions())
+	require.NotEmpty(suite.T(), server.GetNegotiatedExtensions())
+	// TCP statistics reach the hijacked TCP connection
+	_, _, err = server.TCPStats()
+	if runtime.GOOS == "linux" {
+		require.NoError(suite.T(), err)
+	}
+	// Echo
+	require.NoError(suite.T(), client.Write(ctx, wsadapters.Text, []byte("hello")))
+	msgType, msg, err := client.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), "hello", string(msg))
+	// Server pings the client - pong handler is set
+	go client.Read(ctx)
+	require.NoError(suite.T(), server.Ping(ctx))
+	require.NoError(suite.T(), client.Close(ctx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test ReadStats reports the throughput of the messages read during the sliding window.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestReadStats() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithReadStatsWindow(time.Minute))
+	require.Equal(suite.T(), wsadapters.AdapterReadStats{}, adapter.ReadStats())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(ctx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Read 4 echoed messages of 10 bytes
+	before := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Binary, make([]byte, 10)))
+		_, _, err := adapter.Read(ctx)
+		require.NoError(suite.T(), err)
+	}
+	stats := adapter.ReadStats()
+	require.InDelta(suite.T(), 4.0/60, stats.MessagesPerSecond, 0.0001)
+	require.InDelta(suite.T(), 40.0/60, stats.BytesPerSecond, 0.0001)
+	require.False(suite.T(), stats.LastMessageAt.Before(before))
+	// Statistics are kept when the connection is closed
+	require.NoError(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, "bye"))
+	require.Equal(suite.T(), stats.LastMessageAt, adapter.ReadStats().LastMessageAt)
+}
+
+// Test ReadStream streams messages and returns a close error when the server closes the
+// connection.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestReadStream() {
+	// Start a server which sends a large message and then closes the connection
+	large := bytes.Repeat([]byte("0123456789"), 100000)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.BinaryMessage, large)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"))
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	_, _, err = adapter.ReadStream(ctx)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+	_, err = adapter.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	// Stream the large message
+	msgType, reader, err := adapter.ReadStream(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Binary, msgType)
+	msg, err := io.ReadAll(reader)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), large, msg)
+	require.False(suite.T(), adapter.ReadStats().LastMessageAt.IsZero())
+	// Server closes the connection
+	_, _, err = adapter.ReadStream(ctx)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.GoingAway, closeErr.Code)
+}
+
+// Test WriteStream streams a large message and blocks other writes until the writer is closed.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestWriteStream() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithWriteTimeout(5*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.WriteStream(ctx, wsadapters.Binary)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+	_, err = adapter.Dial(ctx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	defer adapter.Close(ctx, wsadapters.NormalClosure, "bye")
+	// Stream a large message in chunks
+	writer, err := adapter.WriteStream(ctx, wsadapters.Binary)
+	require.NoError(suite.T(), err)
+	chunk := bytes.Repeat([]byte("0123456789"), 10000)
+	for i := 0; i < 10; i++ {
+		_, err = writer.Write(chunk)
+		require.NoError(suite.T(), err)
+	}
+	// Other writes wait until the writer is closed
+	written := make(chan error, 1)
+	go func() {
+		written <- adapter.Write(ctx, wsadapters.Text, []byte("next"))
+	}()
+	select {
+	case <-written:
+		suite.FailNow("write should wait until the writer is closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(suite.T(), writer.Close())
+	require.NoError(suite.T(), <-written)
+	_, err = writer.Write(chunk)
+	require.Error(suite.T(), err)
+	require.Error(suite.T(), writer.Close())
+	// Read echoed messages
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Binary, msgType)
+	require.Equal(suite.T(), bytes.Repeat(chunk, 10), msg)
+	msgType, msg, err = adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), "next", string(msg))
+}
+
+// Test Write and Read with text and binary messages
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestWriteAndReadMessageTypes() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Write and read back each message type
+	for _, msgType := range []wsadapters.MessageType{wsadapters.Text, wsadapters.Binary} {
+		echo := []byte{0x00, 0x01, 'h', 'i'}
+		require.NoError(suite.T(), adapter.Write(timeoutCtx, msgType, echo))
+		readType, msg, err := adapter.Read(timeoutCtx)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), msgType, readType)
+		require.Equal(suite.T(), echo, msg)
+	}
+	// Close connection
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test WriteFrom and WriteFromSized stream messages from a reader
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestWriteFrom() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Methods fail when there is no active connection
+	require.Error(suite.T(), adapter.WriteFrom(timeoutCtx, wsadapters.Binary, bytes.NewReader([]byte("hello"))))
+	require.Error(suite.T(), adapter.WriteFromSized(timeoutCtx, wsadapters.Binary, bytes.NewReader([]byte("hello")), 5))
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Stream a message larger than the write buffer (sent as several fragments)
+	large := bytes.Repeat([]byte{0x00, 0x01, 0x02}, 100000)
+	require.NoError(suite.T(), adapter.WriteFrom(timeoutCtx, wsadapters.Binary, bytes.NewReader(large)))
+	readType, msg, err := adapter.Read(timeoutCtx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Binary, readType)
+	require.Equal(suite.T(), large, msg)
+	// Send the first bytes of a reader as a single message
+	require.NoError(suite.T(), adapter.WriteFromSized(timeoutCtx, wsadapters.Text, strings.NewReader("hello world"), 5))
+	readType, msg, err = adapter.Read(timeoutCtx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, readType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	// Reader with less bytes than size and invalid size
+	require.ErrorIs(suite.T(), adapter.WriteFromSized(timeoutCtx, wsadapters.Text, strings.NewReader("hi"), 5), io.ErrUnexpectedEOF)
+	require.Error(suite.T(), adapter.WriteFromSized(timeoutCtx, wsadapters.Text, strings.NewReader("hi"), -1))
+	// Reader failure is returned
+	require.ErrorIs(suite.T(), adapter.WriteFrom(timeoutCtx, wsadapters.Binary, iotest.ErrReader(io.ErrClosedPipe)), io.ErrClosedPipe)
+	// Canceled context
+	canceledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	require.ErrorIs(suite.T(), adapter.WriteFrom(canceledCtx, wsadapters.Binary, strings.NewReader("hi")), context.Canceled)
+	require.ErrorIs(suite.T(), adapter.WriteFromSized(canceledCtx, wsadapters.Binary, strings.NewReader("hi"), 2), context.Canceled)
+	// Close connection
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test Close is not blocked by a WriteFrom call whose reader is stalled.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestWriteFromStalledReader() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Stream a message from a reader which blocks until the pipe is closed
+	reader, writer := io.Pipe()
+	writeFromErr := make(chan error, 1)
+	go func() {
+		writeFromErr <- adapter.WriteFrom(timeoutCtx, wsadapters.Binary, reader)
+	}()
+	_, err = writer.Write([]byte("partial"))
+	require.NoError(suite.T(), err)
+	// Close must complete while WriteFrom is still waiting for the reader
+	closeErr := make(chan error, 1)
+	go func() {
+		closeErr <- adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye")
+	}()
+	select {
+	case err := <-closeErr:
+		require.NoError(suite.T(), err)
+	case <-time.After(time.Second):
+		suite.FailNow("Close blocked by a stalled WriteFrom")
+	}
+	// Unblock the reader - WriteFrom fails as the connection has been closed
+	writer.CloseWithError(io.ErrClosedPipe)
+	select {
+	case err := <-writeFromErr:
+		require.Error(suite.T(), err)
+	case <-time.After(time.Second):
+		suite.FailNow("WriteFrom did not return once the reader was unblocked")
+	}
+}
+
+// Test Read, Write and GetUnderlyingWebsocketConnection once connection has been closed
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestMethodsOnClosedConnection() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	// Close connection
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	// Methods fail as there is no active connection anymore
+	msgType, msg, err := adapter.Read(timeoutCtx)
+	require.Error(suite.T(), err)
+	require.Less(suite.T(), int(msgType), 0)
+	require.Empty(suite.T(), msg)
+	require.Error(suite.T(), adapter.Write(timeoutCtx, wsadapters.Text, []byte("hello")))
+	require.Error(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+	// A new connection can be opened
+	_, err = adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test a panic in pongHandler is recovered and results in a 1011 close error for Ping and in a
+// dropped connection for Read.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestPongHandlerPanicRecovery() {
+	// Create an adapter and connect to the shared echo server
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	// Record a closed channel as first ping request: pongHandler will panic when notifying it
+	closed := make(chan error)
+	close(closed)
+	adapter.pingRequests <- closed
+	// Start a goroutine which reads until an error occurs
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := adapter.Read(timeoutCtx)
+		readErr <- err
+	}()
+	// Ping server and expect a close error with code 1011
+	closeErr := new(wsadapters.WebsocketCloseError)
+	err = adapter.Ping(timeoutCtx)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.InternalError, closeErr.Code)
+	// Read fails as the connection has been dropped
+	select {
+	case err := <-readErr:
+		require.ErrorIs(suite.T(), err, ErrControlHandlerPanic)
+		require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+		require.False(suite.T(), errors.As(err, new(wsadapters.WebsocketCloseError)))
+	case <-timeoutCtx.Done():
+		suite.FailNow(timeoutCtx.Err().Error())
+	}
+	// Connection has been dropped so a new one can be opened
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+	_, err = adapter.Dial(timeoutCtx, echoSrvURL)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye"))
+}
+
+// Test a panic in closeHandler is recovered and propagated to pending Ping as a 1011 close error.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestCloseHandlerPanicRecovery() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	// Close handler panics when the normalizer is nil
+	adapter.closeCodeNormalizer = nil
+	listener := make(chan error, 1)
+	adapter.pingRequests <- listener
+	err := adapter.closeHandler(int(wsadapters.NormalClosure), "bye")
+	require.ErrorIs(suite.T(), err, ErrControlHandlerPanic)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), <-listener, closeErr)
+	require.Equal(suite.T(), wsadapters.InternalError, closeErr.Code)
+}
+
+// Test the engine calls OnReadError and restarts when a panic occurs in pongHandler.
+func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestEngineRestartsOnHandlerPanic() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil)
+	client := wstest.NewRecordingClient()
+	engine, err := wscengine.NewWebsocketEngine(&echoSrvURL, adapter, client, nil, nil)
+	require.NoError(suite.T(), err)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(timeoutCtx))
+	defer engine.Stop(timeoutCtx)
+	client.AssertOnOpenCalled(suite.T(), 1)
+	// Make pongHandler panic on next pong
+	closed := make(chan error)
+	close(closed)
+	adapter.pingRequests <- closed
+	require.Error(suite.T(), adapter.Ping(timeoutCtx))
+	// Engine restarts
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnOpens()) == 2
+	}, 20*time.Second, 100*time.Millisecond)
+	require.True(suite.T(), client.RecordedOnOpens()[1].Restarting)
+	readErrors := client.RecordedOnReadErrors()
+	require.Len(suite.T(), readErrors, 1)
+	require.ErrorIs(suite.T(), readErrors[0].Err, ErrControlHandlerPanic)
+	require.Len(suite.T(), client.RecordedOnCloses(), 1)
+	require.Empty(suite.T(), client.RecordedOnCloseErrors())
+}
+
 // Test propagateToFirstActiveListener when there are no active listeners
 func (suite *GorillaWebsocketConnectionAdapterTestSuite) TestPropagateToFirstActiveListenerWithoutActiveListener() {
 	// Create chan used to receive notification channels
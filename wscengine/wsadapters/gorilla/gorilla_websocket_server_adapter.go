@@ -0,0 +1,88 @@
+package gorilla
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server side adapter for gorilla/websocket library which upgrades HTTP requests to websocket
+// connections.
+//
+// Upgraded connections are wrapped in a GorillaWebsocketConnectionAdapter so server side code can
+// use the same WebsocketConnectionAdapterInterface as client side code: requests are upgraded with
+// the UpgradeFromHTTP method of the returned adapters. Dial must not be called on the returned
+// adapters as their connection is already established.
+type GorillaWebsocketServerAdapter struct {
+	// Upgrader used to upgrade HTTP requests
+	upgrader websocket.Upgrader
+	// Optional CORS headers added to handshake responses
+	corsHeaders http.Header
+}
+
+// # Description
+//
+// Factory which creates a new GorillaWebsocketServerAdapter.
+//
+// # Inputs
+//
+//   - upgrader: Optional upgrader to use to upgrade HTTP requests. If nil, an upgrader with
+//     default settings is used. The upgrader is copied so options do not alter a shared upgrader.
+//     By default, gorilla upgraders reject cross-origin requests: use WithOriginChecker to accept
+//     them.
+//   - opts: Optional options used to further customize the adapter.
+//
+// # Returns
+//
+// New GorillaWebsocketServerAdapter
+func NewGorillaWebsocketServerAdapter(upgrader *websocket.Upgrader, opts ...GorillaServerAdapterOption) *GorillaWebsocketServerAdapter {
+	adapter := &GorillaWebsocketServerAdapter{}
+	if upgrader != nil {
+		adapter.upgrader = *upgrader
+	}
+	// Apply options and return adapter
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	return adapter
+}
+
+// # Description
+//
+// Upgrade the HTTP request to a websocket connection. In case of failure, Upgrade replies to the
+// client with a HTTP error: 403 Forbidden when the request origin is rejected by the origin
+// checker, 400 Bad Request when the request is not a valid websocket handshake.
+//
+// CORS headers configured with WithCORSHeaders are added to the handshake response and to HTTP
+// error responses.
+//
+// # Inputs
+//
+//   - w: Response writer of the HTTP request.
+//   - r: HTTP request to upgrade.
+//   - responseHeader: Optional headers included in the handshake response (like Set-Cookie).
+//
+// # Returns
+//
+// An adapter for the upgraded connection or an error if the upgrade failed.
+func (adapter *GorillaWebsocketServerAdapter) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*GorillaWebsocketConnectionAdapter, error) {
+	if len(adapter.corsHeaders) > 0 {
+		// Upgrader writes error responses using w headers and the handshake response using
+		// responseHeader: add CORS headers to both
+		merged := responseHeader.Clone()
+		if merged == nil {
+			merged = http.Header{}
+		}
+		for name, values := range adapter.corsHeaders {
+			w.Header()[name] = append([]string(nil), values...)
+			merged[name] = append([]string(nil), values...)
+		}
+		responseHeader = merged
+	}
+	// Wrap the connection in an adapter which upgrades the request like client side adapters
+	wrapper := NewGorillaWebsocketConnectionAdapter(nil, nil, WithUpgrader(&adapter.upgrader))
+	if _, err := wrapper.UpgradeFromHTTP(w, r, responseHeader); err != nil {
+		return nil, err
+	}
+	return wrapper, nil
+}
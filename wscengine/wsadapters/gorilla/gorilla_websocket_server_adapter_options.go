@@ -0,0 +1,116 @@
+package gorilla
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Functional option used to customize a GorillaWebsocketServerAdapter when it is created.
+//
+// Options are applied in the order they are provided and work on the private copy of the
+// upgrader made by the factory.
+type GorillaServerAdapterOption func(adapter *GorillaWebsocketServerAdapter)
+
+// Function which decides whether the origin of a websocket handshake request is accepted.
+type OriginChecker func(r *http.Request) bool
+
+// # Description
+//
+// Option which sets the function used to check the origin of handshake requests
+// (Upgrader.CheckOrigin). Requests rejected by the checker are answered with 403 Forbidden.
+//
+// # Inputs
+//
+//   - checker: Origin checker. Use AllowAllOrigins, AllowOrigins, AllowOriginRegexp or
+//     AllowSameOrigin or provide a custom function. If nil, gorilla default same origin check is
+//     used.
+//
+// # Returns
+//
+// An option which sets the origin checker.
+func WithOriginChecker(checker func(r *http.Request) bool) GorillaServerAdapterOption {
+	return func(adapter *GorillaWebsocketServerAdapter) {
+		adapter.upgrader.CheckOrigin = checker
+	}
+}
+
+// # Description
+//
+// Option which sets CORS headers (like Access-Control-Allow-Origin) added to handshake
+// responses and to the HTTP error responses sent when upgrade fails.
+//
+// # Inputs
+//
+//   - headers: CORS headers. The headers are copied.
+//
+// # Returns
+//
+// An option which sets CORS headers.
+func WithCORSHeaders(headers http.Header) GorillaServerAdapterOption {
+	return func(adapter *GorillaWebsocketServerAdapter) {
+		adapter.corsHeaders = headers.Clone()
+	}
+}
+
+// # Description
+//
+// Origin checker which accepts all origins.
+func AllowAllOrigins() OriginChecker {
+	return func(r *http.Request) bool {
+		return true
+	}
+}
+
+// # Description
+//
+// Origin checker which accepts the provided origins (scheme://host[:port]). Comparison is case
+// insensitive. Requests without Origin header are accepted as they do not come from browsers.
+func AllowOrigins(origins ...string) OriginChecker {
+	allowed := make(map[string]struct{}, len(origins))
+	for _, origin := range origins {
+		allowed[strings.ToLower(origin)] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		_, ok := allowed[strings.ToLower(origin)]
+		return ok
+	}
+}
+
+// # Description
+//
+// Origin checker which accepts origins that match the provided pattern. Anchor the pattern
+// (^...$) to avoid partial matches. Requests without Origin header are accepted as they do not
+// come from browsers.
+func AllowOriginRegexp(pattern *regexp.Regexp) OriginChecker {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return pattern.MatchString(origin)
+	}
+}
+
+// # Description
+//
+// Origin checker which accepts origins which have the same host as the request Host header.
+// Requests without Origin header are accepted as they do not come from browsers.
+func AllowSameOrigin() OriginChecker {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, r.Host)
+	}
+}
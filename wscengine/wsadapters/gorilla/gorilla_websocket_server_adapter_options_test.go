@@ -0,0 +1,54 @@
+package gorilla
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test GorillaWebsocketServerAdapter options and origin checkers
+type GorillaServerAdapterOptionsTestSuite struct {
+	suite.Suite
+}
+
+// Run GorillaServerAdapterOptionsTestSuite test suite
+func TestGorillaServerAdapterOptionsTestSuite(t *testing.T) {
+	suite.Run(t, new(GorillaServerAdapterOptionsTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test built-in origin checkers.
+func (suite *GorillaServerAdapterOptionsTestSuite) TestOriginCheckers() {
+	cases := []struct {
+		checker  OriginChecker
+		origin   string
+		expected bool
+	}{
+		{AllowAllOrigins(), "https://evil.example", true},
+		{AllowOrigins("https://app.example"), "https://APP.example", true},
+		{AllowOrigins("https://app.example"), "https://evil.example", false},
+		{AllowOrigins("https://app.example"), "", true},
+		{AllowOriginRegexp(regexp.MustCompile(`^https://[a-z]+\.app\.example$`)), "https://eu.app.example", true},
+		{AllowOriginRegexp(regexp.MustCompile(`^https://[a-z]+\.app\.example$`)), "https://eu.app.example.evil", false},
+		{AllowSameOrigin(), "http://server.example:8080", true},
+		{AllowSameOrigin(), "http://other.example:8080", false},
+		{AllowSameOrigin(), "%", false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "http://server.example:8080/ws", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		require.Equal(suite.T(), c.expected, c.checker(r), c.origin)
+	}
+}
@@ -0,0 +1,85 @@
+package gorilla
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	wsconnadapter "github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test GorillaWebsocketServerAdapter
+type GorillaWebsocketServerAdapterTestSuite struct {
+	suite.Suite
+}
+
+// Run GorillaWebsocketServerAdapterTestSuite test suite
+func TestGorillaWebsocketServerAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(GorillaWebsocketServerAdapterTestSuite))
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test upgraded connections are wrapped in an adapter which can read and write messages.
+func (suite *GorillaWebsocketServerAdapterTestSuite) TestUpgrade() {
+	server := NewGorillaWebsocketServerAdapter(nil,
+		WithOriginChecker(AllowOrigins("https://app.example")),
+		WithCORSHeaders(http.Header{"Access-Control-Allow-Origin": {"https://app.example"}}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := server.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// Echo a single message
+		msgType, msg, err := conn.Read(context.Background())
+		if err == nil {
+			conn.Write(context.Background(), msgType, msg)
+		}
+		conn.Close(context.Background(), wsconnadapter.NormalClosure, "")
+	}))
+	defer srv.Close()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Accepted origin
+	client := NewGorillaWebsocketConnectionAdapter(nil, http.Header{"Origin": {"https://app.example"}})
+	resp, err := client.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "https://app.example", resp.Header.Get("Access-Control-Allow-Origin"))
+	require.NoError(suite.T(), client.Write(ctx, wsconnadapter.Text, []byte("hello")))
+	_, msg, err := client.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "hello", string(msg))
+	// Rejected origin
+	_, resp, err = websocket.DefaultDialer.DialContext(ctx, target.String(), http.Header{"Origin": {"https://evil.example"}})
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	require.Equal(suite.T(), "https://app.example", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+// Test gorilla default origin check rejects cross-origin requests when no checker is set.
+func (suite *GorillaWebsocketServerAdapterTestSuite) TestDefaultOriginCheck() {
+	server := NewGorillaWebsocketServerAdapter(&websocket.Upgrader{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Upgrade(w, r, nil)
+	}))
+	defer srv.Close()
+	target := "ws" + strings.TrimPrefix(srv.URL, "http")
+	_, resp, err := websocket.DefaultDialer.Dial(target, http.Header{"Origin": {"https://evil.example"}})
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+}
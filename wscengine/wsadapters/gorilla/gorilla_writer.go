@@ -0,0 +1,86 @@
+package gorilla
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+)
+
+// Request to write a message handed over to the writer goroutine of a connection.
+type writeRequest struct {
+	// Context of the caller - the message is not written if it is done when the writer gets it
+	ctx context.Context
+	// Message type
+	msgType wsadapters.MessageType
+	// Message content
+	msg []byte
+	// Channel which receives the result of the write - buffered so the writer never blocks
+	resultChan chan error
+}
+
+// # Description
+//
+// Hand over a message to the writer goroutine of the current connection and wait for the result
+// of the write.
+//
+// If the context is done before the writer goroutine starts writing the message, the message is
+// not written, even if it has already been dequeued. If the context is done while the message is
+// being written, the method returns the context error but the message is still written.
+//
+// # Returns
+//
+// The result of the write, the context error or an error which wraps ErrNotConnected if there is
+// no connection or if the connection is dropped before the message is written.
+func (adapter *GorillaWebsocketConnectionAdapter) enqueueWrite(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	adapter.mu.Lock()
+	requests, stop := adapter.writeRequests, adapter.writerStop
+	adapter.mu.Unlock()
+	if requests == nil {
+		return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+	}
+	req := writeRequest{ctx: ctx, msgType: msgType, msg: msg, resultChan: make(chan error, 1)}
+	select {
+	case requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-stop:
+		return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+	}
+	select {
+	case err := <-req.resultChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-stop:
+		// Prefer the result if the message has been written before the connection was dropped
+		select {
+		case err := <-req.resultChan:
+			return err
+		default:
+			return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+		}
+	}
+}
+
+// Writer goroutine of a connection: write the queued messages one at a time until the
+// connection is dropped. The write mutex is held during each write so messages do not interleave
+// with the messages written with WriteFrom and WriteStream. Messages whose context is done once
+// the write mutex is held are skipped: their caller has already given up.
+func (adapter *GorillaWebsocketConnectionAdapter) runWriter(conn *websocket.Conn, requests <-chan writeRequest, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case req := <-requests:
+			adapter.writeMu.Lock()
+			err := req.ctx.Err()
+			if err == nil {
+				err = adapter.writeMessage(conn, req.msgType, req.msg)
+			}
+			adapter.writeMu.Unlock()
+			req.resultChan <- err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gorilla
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Dialer which opens a tunnel to a target address through a HTTPS proxy using HTTP CONNECT.
+type httpsConnectProxyDialer struct {
+	// URL of the HTTPS proxy
+	proxyURL *url.URL
+	// Optional TLS configuration used to connect to the proxy
+	proxyTLSConfig *tls.Config
+}
+
+// # Description
+//
+// Open a TLS connection to the proxy, send a CONNECT request for the provided address and return
+// the tunneled connection once the proxy has accepted the request. The method can be used as the
+// NetDialContext function of a gorilla websocket.Dialer.
+//
+// # Inputs
+//
+//   - ctx: Context used for timeout/cancellation purpose.
+//   - network: Network to use to reach the proxy (tcp).
+//   - addr: Target address (host:port) the proxy must connect to.
+//
+// # Returns
+//
+// The tunneled connection or an error if the tunnel could not be established.
+func (d *httpsConnectProxyDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	if d.proxyURL == nil {
+		return nil, fmt.Errorf("https connect proxy failed because proxy url is nil")
+	}
+	// Compute proxy address - use 443 as default port
+	proxyHost := d.proxyURL.Hostname()
+	proxyPort := d.proxyURL.Port()
+	if proxyPort == "" {
+		proxyPort = "443"
+	}
+	// Open TCP connection to the proxy
+	netDialer := &net.Dialer{}
+	rawConn, err := netDialer.DialContext(ctx, network, net.JoinHostPort(proxyHost, proxyPort))
+	if err != nil {
+		return nil, fmt.Errorf("https connect proxy dial failed: %w", err)
+	}
+	// Use context deadline, if any, for both TLS handshake and CONNECT request
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+	// Perform TLS handshake with the proxy
+	tlsCfg := &tls.Config{}
+	if d.proxyTLSConfig != nil {
+		tlsCfg = d.proxyTLSConfig.Clone()
+	}
+	if tlsCfg.ServerName == "" {
+		tlsCfg.ServerName = proxyHost
+	}
+	conn := tls.Client(rawConn, tlsCfg)
+	err = conn.HandshakeContext(ctx)
+	if err != nil {
+		rawConn.Close()
+		return nil, fmt.Errorf("https connect proxy tls handshake failed: %w", err)
+	}
+	// Send CONNECT request
+	connectHeader := make(http.Header)
+	if user := d.proxyURL.User; user != nil {
+		credential := user.Username()
+		if password, ok := user.Password(); ok {
+			credential = credential + ":" + password
+		}
+		connectHeader.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credential)))
+	}
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: connectHeader,
+	}
+	err = connectReq.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("https connect proxy request failed: %w", err)
+	}
+	// Read proxy response. The proxy must not send data before the client does so it is safe to
+	// discard the buffered reader once the response has been read.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("https connect proxy response could not be read: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("https connect proxy refused tunnel: %s", resp.Status)
+	}
+	// Clear deadline and return tunneled connection
+	rawConn.SetDeadline(time.Time{})
+	return conn, nil
+}
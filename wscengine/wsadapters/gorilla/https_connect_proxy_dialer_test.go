@@ -0,0 +1,196 @@
+package gorilla
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+type HTTPSConnectProxyDialerTestSuite struct {
+	suite.Suite
+}
+
+// Run HTTPSConnectProxyDialerTestSuite test suite
+func TestHTTPSConnectProxyDialerTestSuite(t *testing.T) {
+	suite.Run(t, new(HTTPSConnectProxyDialerTestSuite))
+}
+
+/*************************************************************************************************/
+/* HELPERS                                                                                       */
+/*************************************************************************************************/
+
+// Create a test websocket server which echoes received messages.
+func newTestEchoServer() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+// Create a HTTPS proxy which accepts CONNECT requests and counts received requests. If
+// expectedAuth is not empty, requests without the expected Proxy-Authorization are refused.
+func newTestHTTPSConnectProxy(counter *atomic.Int64, expectedAuth string) *httptest.Server {
+	return httptest.NewTLSServer(newTestConnectProxyHandler(counter, expectedAuth))
+}
+
+// Create a HTTP proxy which accepts CONNECT requests and counts received requests. If
+// expectedAuth is not empty, requests without the expected Proxy-Authorization are refused.
+func newTestHTTPConnectProxy(counter *atomic.Int64, expectedAuth string) *httptest.Server {
+	return httptest.NewServer(newTestConnectProxyHandler(counter, expectedAuth))
+}
+
+// Create a handler which accepts CONNECT requests and tunnels them to the requested host.
+func newTestConnectProxyHandler(counter *atomic.Int64, expectedAuth string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.Add(1)
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if expectedAuth != "" && r.Header.Get("Proxy-Authorization") != expectedAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(target, client)
+			target.Close()
+		}()
+		go func() {
+			io.Copy(client, target)
+			client.Close()
+		}()
+	})
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the adapter can open a connection and exchange messages through a HTTPS CONNECT proxy.
+func (suite *HTTPSConnectProxyDialerTestSuite) TestDialThroughProxy() {
+	// Start echo server and proxy
+	srv := newTestEchoServer()
+	defer srv.Close()
+	counter := new(atomic.Int64)
+	expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	proxy := newTestHTTPSConnectProxy(counter, expectedAuth)
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(suite.T(), err)
+	proxyURL.User = url.UserPassword("user", "secret")
+	// Create adapter which uses the proxy
+	proxyTLSConfig := proxy.Client().Transport.(*http.Transport).TLSClientConfig
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithHTTPSConnectProxy(proxyURL, proxyTLSConfig))
+	require.NotNil(suite.T(), adapter)
+	// Default dialer must not be modified
+	require.Nil(suite.T(), websocket.DefaultDialer.NetDialContext)
+	// Connect to server
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	target, err := url.Parse(strings.Replace(srv.URL, "http", "ws", 1))
+	require.NoError(suite.T(), err)
+	resp, err := adapter.Dial(timeoutCtx, *target)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), resp)
+	require.Equal(suite.T(), int64(1), counter.Load())
+	// Echo
+	err = adapter.Write(timeoutCtx, wsadapters.Text, []byte("hello"))
+	require.NoError(suite.T(), err)
+	msgType, msg, err := adapter.Read(timeoutCtx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	// Close connection
+	err = adapter.Close(timeoutCtx, wsadapters.NormalClosure, "bye")
+	require.NoError(suite.T(), err)
+}
+
+// Test Dial returns an error when the proxy refuses to open the tunnel.
+func (suite *HTTPSConnectProxyDialerTestSuite) TestDialProxyRefusesTunnel() {
+	// Start echo server and a proxy which requires authentication
+	srv := newTestEchoServer()
+	defer srv.Close()
+	counter := new(atomic.Int64)
+	proxy := newTestHTTPSConnectProxy(counter, "Basic expected")
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(suite.T(), err)
+	// Create adapter without credentials
+	proxyTLSConfig := proxy.Client().Transport.(*http.Transport).TLSClientConfig
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithHTTPSConnectProxy(proxyURL, proxyTLSConfig))
+	// Dial and expect an error
+	target, err := url.Parse(strings.Replace(srv.URL, "http", "ws", 1))
+	require.NoError(suite.T(), err)
+	resp, err := adapter.Dial(context.Background(), *target)
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), resp)
+	require.Contains(suite.T(), err.Error(), "407")
+	require.Equal(suite.T(), int64(1), counter.Load())
+}
+
+// Test Dial returns an error when proxy certificate cannot be verified.
+func (suite *HTTPSConnectProxyDialerTestSuite) TestDialProxyUntrustedCertificate() {
+	// Start proxy
+	counter := new(atomic.Int64)
+	proxy := newTestHTTPSConnectProxy(counter, "")
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(suite.T(), err)
+	// Create adapter with default TLS configuration
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithHTTPSConnectProxy(proxyURL, &tls.Config{}))
+	// Dial and expect a TLS error
+	resp, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost:1"})
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), resp)
+	require.Equal(suite.T(), int64(0), counter.Load())
+}
+
+// Test Dial returns an error when proxy URL is nil.
+func (suite *HTTPSConnectProxyDialerTestSuite) TestDialNilProxyURL() {
+	adapter := NewGorillaWebsocketConnectionAdapter(nil, nil, WithHTTPSConnectProxy(nil, nil))
+	resp, err := adapter.Dial(context.Background(), url.URL{Scheme: "ws", Host: "localhost:1"})
+	require.Error(suite.T(), err)
+	require.Nil(suite.T(), resp)
+}
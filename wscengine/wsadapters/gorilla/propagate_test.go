@@ -0,0 +1,154 @@
+package gorilla
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used to test propagateToAllActiveListener
+type PropagateToAllActiveListenerTestSuite struct {
+	suite.Suite
+}
+
+// Run PropagateToAllActiveListenerTestSuite test suite
+func TestPropagateToAllActiveListenerTestSuite(t *testing.T) {
+	suite.Run(t, new(PropagateToAllActiveListenerTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test all active listeners receive the notification and inactive listeners are skipped.
+func (suite *PropagateToAllActiveListenerTestSuite) TestActiveAndInactiveListeners() {
+	listeners := make(chan chan error, 10)
+	notification := fmt.Errorf("closed")
+	// Active listeners: channels with capacity
+	active := []chan error{}
+	for i := 0; i < 5; i++ {
+		listener := make(chan error, 1)
+		active = append(active, listener)
+		listeners <- listener
+		// Interleave inactive listeners: blocking channels which are not read
+		listeners <- make(chan error)
+	}
+	propagateToAllActiveListener(listeners, notification)
+	for _, listener := range active {
+		select {
+		case err := <-listener:
+			require.Equal(suite.T(), notification, err)
+		default:
+			suite.FailNow("active listener should have been notified")
+		}
+	}
+	// All listeners have been consumed
+	require.Empty(suite.T(), listeners)
+}
+
+// Test listeners which are blocked waiting for a notification are all notified.
+func (suite *PropagateToAllActiveListenerTestSuite) TestBlockedListeners() {
+	listeners := make(chan chan error, 10)
+	notification := fmt.Errorf("closed")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listener := make(chan error)
+			listeners <- listener
+			select {
+			case err := <-listener:
+				require.Equal(suite.T(), notification, err)
+			case <-time.After(5 * time.Second):
+				suite.Fail("blocked listener should have been notified")
+			}
+		}()
+	}
+	// Listeners may not be blocked on receive yet when propagating: propagate until all are done.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			propagateToAllActiveListener(listeners, notification)
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// Test listeners which arrive while notifications are propagated are handled and none is lost.
+func (suite *PropagateToAllActiveListenerTestSuite) TestListenersArrivingDuringPropagation() {
+	listeners := make(chan chan error, 10)
+	notification := fmt.Errorf("closed")
+	count := 200
+	registered := make([]chan error, count)
+	wg := sync.WaitGroup{}
+	// Producer which registers listeners
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			registered[i] = make(chan error, 1)
+			listeners <- registered[i]
+		}
+	}()
+	// Concurrent propagations
+	stop := make(chan struct{})
+	propagators := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		propagators.Add(1)
+		go func() {
+			defer propagators.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					propagateToAllActiveListener(listeners, notification)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	propagators.Wait()
+	// Final propagation for listeners registered after the last propagation
+	propagateToAllActiveListener(listeners, notification)
+	for i, listener := range registered {
+		select {
+		case err := <-listener:
+			require.Equal(suite.T(), notification, err)
+		default:
+			suite.FailNowf("listener should have been notified", "listener %d", i)
+		}
+	}
+}
+
+// Test propagateToAllActiveListener terminates when there are no listeners.
+func (suite *PropagateToAllActiveListenerTestSuite) TestWithoutListeners() {
+	done := make(chan struct{})
+	go func() {
+		propagateToAllActiveListener(make(chan chan error, 10), nil)
+		propagateToAllActiveListener(make(chan chan error), nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("propagateToAllActiveListener should have returned")
+	}
+}
@@ -0,0 +1,453 @@
+// Package which contains an in-memory WebsocketConnectionAdapterInterface implementation which can
+// be used to unit test WebsocketClientInterface implementations without a websocket server.
+//
+// Messages, close messages and errors returned by Read are scripted with EnqueueMessage,
+// EnqueueClose and EnqueueError. Messages sent with Write are recorded and can be retrieved with
+// WrittenMessages.
+//
+// Unlike wsadapters.WebsocketConnectionAdapterInterfaceMock, which relies on testify expectations,
+// the adapter behaves like a connection to a real server: Read blocks until a scripted message is
+// available and a close message drops the connection.
+package mock
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Default capacity of the queue of scripted Read results.
+const DefaultQueueCapacity = 1024
+
+// Message sent with Write
+type WrittenMessage struct {
+	// Time when the message has been written
+	Timestamp time.Time
+	// Message type
+	MsgType wsadapters.MessageType
+	// Message content - a copy of the written message
+	Msg []byte
+}
+
+// Close message sent with Close
+type CloseMessage struct {
+	// Status code used in the close message
+	Code wsadapters.StatusCode
+	// Close reason
+	Reason string
+}
+
+// Scripted Read result
+type readResult struct {
+	msgType wsadapters.MessageType
+	msg     []byte
+	err     error
+}
+
+// In-memory WebsocketConnectionAdapterInterface implementation
+type MockWebsocketConnectionAdapter struct {
+	// Internal mutex
+	mu sync.Mutex
+	// Queue of scripted Read results
+	incoming chan readResult
+	// Channel closed when the current connection is dropped - nil if no connection is up
+	closed chan struct{}
+	// Response and error returned by Dial
+	dialResponse *http.Response
+	dialErr      error
+	// Error returned by Ping when a connection is up
+	pingErr error
+	// Recorded messages and close messages
+	written []WrittenMessage
+	closes  []CloseMessage
+	// Number of connections dropped with Drop
+	drops int
+	// Recorder used to compute read throughput
+	readStats *wsadapters.ReadStatsRecorder
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsadapters.PropagationMode
+}
+
+// # Description
+//
+// Factory which creates a new MockWebsocketConnectionAdapter. By default, Dial succeeds with a
+// 101 Switching Protocols response and Ping succeeds while a connection is up.
+//
+// # Returns
+//
+// New MockWebsocketConnectionAdapter
+func NewMockWebsocketConnectionAdapter() *MockWebsocketConnectionAdapter {
+	return &MockWebsocketConnectionAdapter{
+		mu:       sync.Mutex{},
+		incoming: make(chan readResult, DefaultQueueCapacity),
+		closed:   nil,
+		dialResponse: &http.Response{
+			Status:     "101 Switching Protocols",
+			StatusCode: http.StatusSwitchingProtocols,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+		},
+		readStats: wsadapters.NewReadStatsRecorder(0, 0),
+	}
+}
+
+// # Description
+//
+// Set the response and the error returned by the next Dial calls. If err is not nil, Dial fails
+// and no connection is established.
+//
+// # Inputs
+//
+//   - resp: Handshake response returned by Dial. Can be nil.
+//   - err: Error returned by Dial. Can be nil.
+func (adapter *MockWebsocketConnectionAdapter) SetDialResponse(resp *http.Response, err error) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.dialResponse, adapter.dialErr = resp, err
+}
+
+// # Description
+//
+// Set the error returned by the next Ping calls while a connection is up. If nil, Ping succeeds.
+func (adapter *MockWebsocketConnectionAdapter) SetPingError(err error) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.pingErr = err
+}
+
+// # Description
+//
+// Enqueue a message which will be returned by Read. The method blocks if the queue is full
+// (see DefaultQueueCapacity).
+//
+// # Inputs
+//
+//   - msgType: Message type (Text | Binary)
+//   - msg: Message content
+func (adapter *MockWebsocketConnectionAdapter) EnqueueMessage(msgType wsadapters.MessageType, msg []byte) {
+	adapter.incoming <- readResult{msgType: msgType, msg: msg}
+}
+
+// # Description
+//
+// Enqueue an error which will be returned by Read. If the error is a wsadapters.WebsocketCloseError,
+// Read drops the connection when it returns the error, like when the server closes the
+// connection. The method blocks if the queue is full (see DefaultQueueCapacity).
+func (adapter *MockWebsocketConnectionAdapter) EnqueueError(err error) {
+	adapter.incoming <- readResult{msgType: -1, err: err}
+}
+
+// # Description
+//
+// Enqueue a close message from the server: Read returns a wsadapters.WebsocketCloseError with the
+// provided code and reason and drops the connection.
+func (adapter *MockWebsocketConnectionAdapter) EnqueueClose(code wsadapters.StatusCode, reason string) {
+	adapter.EnqueueError(wsadapters.WebsocketCloseError{
+		Code:   code,
+		Reason: reason,
+		Err:    fmt.Errorf("close message received from server"),
+	})
+}
+
+// # Description
+//
+// Return a copy of the messages sent with Write, in the order they have been written.
+func (adapter *MockWebsocketConnectionAdapter) WrittenMessages() []WrittenMessage {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	return append([]WrittenMessage(nil), adapter.written...)
+}
+
+// # Description
+//
+// Return a copy of the close messages sent with Close, in the order they have been sent.
+func (adapter *MockWebsocketConnectionAdapter) CloseMessages() []CloseMessage {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	return append([]CloseMessage(nil), adapter.closes...)
+}
+
+// # Description
+//
+// Return the number of connections dropped with Drop.
+func (adapter *MockWebsocketConnectionAdapter) DropCount() int {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	return adapter.drops
+}
+
+// # Description
+//
+// Open a new in-memory connection. Scripted Read results which have not been read yet are kept.
+//
+// # Returns
+//
+// The response and the error set with SetDialResponse. wsadapters.ErrAlreadyConnected is returned
+// if a connection is already up.
+func (adapter *MockWebsocketConnectionAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return nil, ctx.Err()
+	default:
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		if adapter.closed != nil {
+			return nil, wsadapters.ErrAlreadyConnected
+		}
+		if adapter.dialErr != nil {
+			return adapter.dialResponse, adapter.dialErr
+		}
+		adapter.closed = make(chan struct{})
+		return adapter.dialResponse, nil
+	}
+}
+
+// # Description
+//
+// Record the close message, drop the connection and unblock pending Read calls.
+//
+// # Returns
+//
+// nil in case of success or an error which wraps wsadapters.ErrNotConnected if no connection is
+// up.
+func (adapter *MockWebsocketConnectionAdapter) Close(ctx context.Context, code wsadapters.StatusCode, reason string) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.closed == nil {
+		return fmt.Errorf("close failed: %w", wsadapters.ErrNotConnected)
+	}
+	adapter.closes = append(adapter.closes, CloseMessage{Code: code, Reason: reason})
+	adapter.dropConnection()
+	return nil
+}
+
+// # Description
+//
+// Count the dropped connection, drop the connection without recording a close message and
+// unblock pending Read calls.
+//
+// # Returns
+//
+// nil in case of success or an error which wraps wsadapters.ErrNotConnected if no connection is
+// up.
+func (adapter *MockWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.closed == nil {
+		return fmt.Errorf("drop failed: %w", wsadapters.ErrNotConnected)
+	}
+	adapter.drops++
+	adapter.dropConnection()
+	return nil
+}
+
+// # Description
+//
+// Simulate a ping: Ping returns immediately.
+//
+// # Returns
+//
+// The error set with SetPingError, nil by default, or an error which wraps
+// wsadapters.ErrPingFailed and wsadapters.ErrNotConnected if no connection is up.
+func (adapter *MockWebsocketConnectionAdapter) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		if adapter.closed == nil {
+			return fmt.Errorf("%w: %w", wsadapters.ErrPingFailed, wsadapters.ErrNotConnected)
+		}
+		return adapter.pingErr
+	}
+}
+
+// # Description
+//
+// Return the next scripted Read result. Read blocks until a result is enqueued, the connection is
+// closed with Close or the context is done.
+//
+// # Returns
+//
+//   - MessageType: message type (Text | Binary)
+//   - []bytes: Message content
+//   - error: scripted error, context error, or an error which wraps wsadapters.ErrNotConnected
+//     if no connection is up or if the connection is closed while Read is waiting.
+func (adapter *MockWebsocketConnectionAdapter) Read(ctx context.Context) (wsadapters.MessageType, []byte, error) {
+	adapter.mu.Lock()
+	closed := adapter.closed
+	adapter.mu.Unlock()
+	if closed == nil {
+		return -1, nil, fmt.Errorf("read failed: %w", wsadapters.ErrNotConnected)
+	}
+	select {
+	case <-ctx.Done():
+		return -1, nil, ctx.Err()
+	case <-closed:
+		return -1, nil, fmt.Errorf("read failed: %w", wsadapters.ErrNotConnected)
+	case res := <-adapter.incoming:
+		if closeErr, ok := res.err.(wsadapters.WebsocketCloseError); ok {
+			// Drop the connection as the server has closed it
+			adapter.mu.Lock()
+			if adapter.closed == closed {
+				adapter.dropConnection()
+			}
+			adapter.mu.Unlock()
+			return -1, nil, closeErr
+		}
+		if res.err == nil {
+			adapter.readStats.Record(len(res.msg))
+		}
+		return res.msgType, res.msg, res.err
+	}
+}
+
+// # Description
+//
+// Wait for the next scripted Read result like Read does and return a reader on the message.
+//
+// # Returns
+//
+// The message type and a reader on the message content or the error returned by Read.
+func (adapter *MockWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, msg, err := adapter.Read(ctx)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
+// # Description
+//
+// Record the written message.
+//
+// # Returns
+//
+// nil in case of success, the context error or an error which wraps wsadapters.ErrNotConnected
+// if no connection is up.
+func (adapter *MockWebsocketConnectionAdapter) Write(ctx context.Context, msgType wsadapters.MessageType, msg []byte) error {
+	select {
+	case <-ctx.Done():
+		// Shortcut if context is done (timeout/cancel)
+		return ctx.Err()
+	default:
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		if adapter.closed == nil {
+			return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
+		}
+		adapter.written = append(adapter.written, WrittenMessage{
+			Timestamp: time.Now(),
+			MsgType:   msgType,
+			Msg:       append([]byte(nil), msg...),
+		})
+		return nil
+	}
+}
+
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode of the adapter
+// and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *MockWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	adapter.mu.Lock()
+	mode := adapter.propagationMode
+	adapter.mu.Unlock()
+	msgType, msg, err := wsadapters.InjectTraceContext(ctx, mode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in outgoing
+// messages. Defaults to wsadapters.None: messages are written as is.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *MockWebsocketConnectionAdapter) WithPropagationMode(mode wsadapters.PropagationMode) *MockWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// # Description
+//
+// Return a writer which buffers the message content and records the message with Write when the
+// writer is closed.
+//
+// # Returns
+//
+// A writer for the message content. Close returns the error returned by Write.
+func (adapter *MockWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return wsadapters.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
+// # Description
+//
+// The adapter has no underlying connection.
+//
+// # Returns
+//
+// Always nil.
+func (adapter *MockWebsocketConnectionAdapter) GetUnderlyingWebsocketConnection() any {
+	return nil
+}
+
+// # Description
+//
+// Return the Sec-WebSocket-Protocol header of the response set with SetDialResponse while a
+// connection is up.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if no connection is up.
+func (adapter *MockWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.closed == nil || adapter.dialResponse == nil {
+		return ""
+	}
+	return adapter.dialResponse.Header.Get("Sec-WebSocket-Protocol")
+}
+
+// # Description
+//
+// Return the throughput of the messages successfully returned by Read, averaged over a sliding
+// window of wsadapters.DefaultReadStatsWindow.
+//
+// # Returns
+//
+// The read throughput statistics.
+func (adapter *MockWebsocketConnectionAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return adapter.readStats.Stats()
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Drop the current connection and unblock pending Read calls. Internal mutex must be held.
+func (adapter *MockWebsocketConnectionAdapter) dropConnection() {
+	close(adapter.closed)
+	adapter.closed = nil
+}
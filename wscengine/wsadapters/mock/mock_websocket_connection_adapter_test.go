@@ -0,0 +1,173 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine"
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+// Test suite used for MockWebsocketConnectionAdapter unit tests
+type MockWebsocketConnectionAdapterTestSuite struct {
+	suite.Suite
+}
+
+// Run MockWebsocketConnectionAdapterTestSuite test suite
+func TestMockWebsocketConnectionAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(MockWebsocketConnectionAdapterTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test adapter complies with WebsocketConnectionAdapterInterface
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestInterfaceCompliance() {
+	var adapter interface{} = NewMockWebsocketConnectionAdapter()
+	_, ok := adapter.(wsadapters.WebsocketConnectionAdapterInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test methods fail when no connection is up
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestMethodsWithoutConnection() {
+	adapter := NewMockWebsocketConnectionAdapter()
+	ctx := context.Background()
+	_, _, err := adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+	require.ErrorIs(suite.T(), adapter.Write(ctx, wsadapters.Text, []byte("hello")), wsadapters.ErrNotConnected)
+	require.ErrorIs(suite.T(), adapter.Ping(ctx), wsadapters.ErrPingFailed)
+	require.ErrorIs(suite.T(), adapter.Close(ctx, wsadapters.NormalClosure, ""), wsadapters.ErrNotConnected)
+	require.Nil(suite.T(), adapter.GetUnderlyingWebsocketConnection())
+}
+
+// Test scripted messages, errors and close messages are returned by Read in order.
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestScriptedReads() {
+	adapter := NewMockWebsocketConnectionAdapter()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := adapter.Dial(ctx, url.URL{})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusSwitchingProtocols, res.StatusCode)
+	_, err = adapter.Dial(ctx, url.URL{})
+	require.ErrorIs(suite.T(), err, wsadapters.ErrAlreadyConnected)
+	readErr := fmt.Errorf("read error")
+	adapter.EnqueueMessage(wsadapters.Text, []byte("hello"))
+	adapter.EnqueueError(readErr)
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	msgType, msg, err := adapter.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsadapters.Text, msgType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, readErr)
+	_, _, err = adapter.Read(ctx)
+	closeErr := new(wsadapters.WebsocketCloseError)
+	require.ErrorAs(suite.T(), err, closeErr)
+	require.Equal(suite.T(), wsadapters.GoingAway, closeErr.Code)
+	require.Equal(suite.T(), "bye", closeErr.Reason)
+	// Connection has been dropped by the close message
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, wsadapters.ErrNotConnected)
+}
+
+// Test Read is unblocked by Close and by context cancellation.
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestReadUnblocked() {
+	adapter := NewMockWebsocketConnectionAdapter()
+	_, err := adapter.Dial(context.Background(), url.URL{})
+	require.NoError(suite.T(), err)
+	// Context cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = adapter.Read(ctx)
+	require.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	// Close
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := adapter.Read(context.Background())
+		readErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(suite.T(), adapter.Close(context.Background(), wsadapters.NormalClosure, "done"))
+	require.ErrorIs(suite.T(), <-readErr, wsadapters.ErrNotConnected)
+	require.Equal(suite.T(), []CloseMessage{{Code: wsadapters.NormalClosure, Reason: "done"}}, adapter.CloseMessages())
+}
+
+// Test writes are recorded and Dial and Ping results can be scripted.
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestWritesAndScriptedResults() {
+	adapter := NewMockWebsocketConnectionAdapter()
+	ctx := context.Background()
+	// Dial failure
+	dialErr := fmt.Errorf("dial error")
+	adapter.SetDialResponse(&http.Response{StatusCode: http.StatusServiceUnavailable}, dialErr)
+	res, err := adapter.Dial(ctx, url.URL{})
+	require.ErrorIs(suite.T(), err, dialErr)
+	require.Equal(suite.T(), http.StatusServiceUnavailable, res.StatusCode)
+	adapter.SetDialResponse(nil, nil)
+	_, err = adapter.Dial(ctx, url.URL{})
+	require.NoError(suite.T(), err)
+	// Ping
+	require.NoError(suite.T(), adapter.Ping(ctx))
+	pingErr := fmt.Errorf("ping error")
+	adapter.SetPingError(pingErr)
+	require.ErrorIs(suite.T(), adapter.Ping(ctx), pingErr)
+	// Writes are copied
+	msg := []byte("hello")
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Text, msg))
+	require.NoError(suite.T(), adapter.Write(ctx, wsadapters.Binary, []byte{1, 2}))
+	msg[0] = 'j'
+	written := adapter.WrittenMessages()
+	require.Len(suite.T(), written, 2)
+	require.Equal(suite.T(), wsadapters.Text, written[0].MsgType)
+	require.Equal(suite.T(), []byte("hello"), written[0].Msg)
+	require.Equal(suite.T(), wsadapters.Binary, written[1].MsgType)
+	require.Equal(suite.T(), []byte{1, 2}, written[1].Msg)
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test the adapter can be used to run a websocket engine without a server.
+func (suite *MockWebsocketConnectionAdapterTestSuite) TestWithEngine() {
+	adapter := NewMockWebsocketConnectionAdapter()
+	client := wstest.NewRecordingClient()
+	target, err := url.Parse("ws://localhost")
+	require.NoError(suite.T(), err)
+	opts := wscengine.NewWebsocketEngineConfigurationOptions().
+		WithReaderRoutinesCount(1).
+		WithAutoReconnect(false)
+	engine, err := wscengine.NewWebsocketEngine(target, adapter, client, opts, nil)
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Start(ctx))
+	defer engine.Stop(ctx)
+	// Messages are handed over to OnMessage
+	adapter.EnqueueMessage(wsadapters.Text, []byte("first"))
+	adapter.EnqueueMessage(wsadapters.Text, []byte("second"))
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 2, 5*time.Second))
+	messages := client.RecordedOnMessages()
+	require.Equal(suite.T(), "first", string(messages[0].Msg))
+	require.Equal(suite.T(), "second", string(messages[1].Msg))
+	// Close message is handed over to OnClose
+	adapter.EnqueueClose(wsadapters.GoingAway, "bye")
+	require.Eventually(suite.T(), func() bool {
+		return len(client.RecordedOnCloses()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	closeMsg := client.RecordedOnCloses()[0].CloseMessage
+	require.NotNil(suite.T(), closeMsg)
+	require.Equal(suite.T(), wsadapters.GoingAway, closeMsg.CloseReason)
+	require.Equal(suite.T(), "bye", closeMsg.CloseMessage)
+}
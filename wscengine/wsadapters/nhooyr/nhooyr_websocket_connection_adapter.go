@@ -3,7 +3,9 @@
 package nhooyr
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +15,7 @@ import (
 	"sync"
 
 	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"go.opentelemetry.io/otel/propagation"
 	"nhooyr.io/websocket"
 )
 
@@ -24,6 +27,8 @@ type NhooyrWebsocketConnectionAdapter struct {
 	opts *websocket.DialOptions
 	// Internal mutex
 	mu sync.Mutex
+	// Mode used by WritePropagated to embed the trace context in outgoing messages
+	propagationMode wsadapters.PropagationMode
 }
 
 // # Description
@@ -45,6 +50,29 @@ func NewNhooyrWebsocketConnectionAdapter(opts *websocket.DialOptions) *NhooyrWeb
 	}
 }
 
+// # Description
+//
+// Factory which creates a new NhooyrWebsocketConnectionAdapter for a server side connection
+// accepted with websocket.Accept. Unlike gorilla/websocket, websocket.Accept does not require the
+// response writer to support hijacking so it can be used with HTTP/2 friendly platforms.
+//
+// The returned adapter must not be used to call Dial.
+//
+// # Inputs
+//
+//   - conn: Connection returned by websocket.Accept.
+//
+// # Returns
+//
+// An adapter for the accepted connection.
+func NewNhooyrWebsocketConnectionAdapterFromAccept(conn *websocket.Conn) *NhooyrWebsocketConnectionAdapter {
+	return &NhooyrWebsocketConnectionAdapter{
+		conn: conn,
+		opts: nil,
+		mu:   sync.Mutex{},
+	}
+}
+
 // # Description
 //
 // Dial opens a connection to the websocket server and performs a WebSocket handshake.
@@ -69,10 +97,15 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Dial(ctx context.Context, targe
 		// Check whether there is already a connection set
 		if adapter.conn != nil {
 			// Return error in case a connection has already been set
-			return nil, fmt.Errorf("a connection has already been established")
+			return nil, wsadapters.ErrAlreadyConnected
+		}
+		// Use the dial host for Host header and TLS server name if target host has been resolved
+		opts := adapter.opts
+		if host, ok := wsadapters.DialHostFromContext(ctx); ok {
+			opts = withDialHost(opts, host)
 		}
 		// Open websocket connection
-		conn, res, err := websocket.Dial(ctx, target.String(), adapter.opts)
+		conn, res, err := websocket.Dial(ctx, target.String(), opts)
 		if err != nil {
 			// Return response and error
 			return res, err
@@ -104,7 +137,7 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Close(ctx context.Context, code
 	defer adapter.mu.Unlock()
 	// Check whether there is already a connection set
 	if adapter.conn == nil {
-		return fmt.Errorf("close failed because no connection is already up")
+		return fmt.Errorf("close failed: %w", wsadapters.ErrNotConnected)
 	}
 	// Close connection
 	err := adapter.conn.Close(convertToNhooyrStatusCodes(code), reason)
@@ -121,6 +154,35 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Close(ctx context.Context, code
 	return err
 }
 
+// # Description
+//
+// Close the underlying network connection without sending a close message and drop the
+// websocket connection. The server sees an abnormal closure (1006).
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (adapter *NhooyrWebsocketConnectionAdapter) Drop(ctx context.Context) error {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	// Check whether there is already a connection set
+	if adapter.conn == nil {
+		return fmt.Errorf("drop failed: %w", wsadapters.ErrNotConnected)
+	}
+	// Close the network connection without closing handshake
+	err := adapter.conn.CloseNow()
+	// Void connection in any case
+	adapter.conn = nil
+	// Return result
+	return err
+}
+
 // # Description
 //
 // Send a Ping message to the websocket server and blocks until a Pong response is received, a
@@ -150,7 +212,7 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Ping(ctx context.Context) error
 		adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if conn == nil {
-			return fmt.Errorf("ping failed because no connection is already up")
+			return fmt.Errorf("%w: %w", wsadapters.ErrPingFailed, wsadapters.ErrNotConnected)
 		}
 		// Call Ping and return results
 		return conn.Ping(ctx)
@@ -184,7 +246,7 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Read(ctx context.Context) (wsad
 		adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if conn == nil {
-			return -1, nil, fmt.Errorf("read failed because no connection is already up")
+			return -1, nil, fmt.Errorf("read failed: %w", wsadapters.ErrNotConnected)
 		}
 		// Call Read
 		nhooyrMsgType, msg, err := conn.Read(ctx)
@@ -219,6 +281,26 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Read(ctx context.Context) (wsad
 	}
 }
 
+// # Description
+//
+// The adapter does not stream messages: ReadStream reads the whole message with Read and returns
+// a reader on the message content.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+// The message type and a reader on the message content or the error returned by Read.
+func (adapter *NhooyrWebsocketConnectionAdapter) ReadStream(ctx context.Context) (wsadapters.MessageType, io.Reader, error) {
+	msgType, msg, err := adapter.Read(ctx)
+	if err != nil {
+		return msgType, nil, err
+	}
+	return msgType, bytes.NewReader(msg), nil
+}
+
 // # Description
 //
 // Write a single message to the websocket server. Write blocks until message is sent to the
@@ -246,13 +328,64 @@ func (adapter *NhooyrWebsocketConnectionAdapter) Write(ctx context.Context, msgT
 		adapter.mu.Unlock()
 		// Check whether there is already a connection set
 		if conn == nil {
-			return fmt.Errorf("write failed because no connection is already up")
+			return fmt.Errorf("write failed: %w", wsadapters.ErrNotConnected)
 		}
 		// Call Write and retuurn results
 		return conn.Write(ctx, convertToNhooyrMsgTypes(msgType), msg)
 	}
 }
 
+// # Description
+//
+// Embed the trace context of ctx in the message according to the propagation mode of the adapter
+// and write the message like Write.
+//
+// # Returns
+//
+// An error if the trace context cannot be embedded or if the message cannot be written.
+func (adapter *NhooyrWebsocketConnectionAdapter) WritePropagated(ctx context.Context, msgType wsadapters.MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	adapter.mu.Lock()
+	mode := adapter.propagationMode
+	adapter.mu.Unlock()
+	msgType, msg, err := wsadapters.InjectTraceContext(ctx, mode, msgType, msg, propagator)
+	if err != nil {
+		return fmt.Errorf("failed to propagate trace context: %w", err)
+	}
+	return adapter.Write(ctx, msgType, msg)
+}
+
+// # Description
+//
+// Set the propagation mode used by WritePropagated to embed the trace context in outgoing
+// messages. Defaults to wsadapters.None: messages are written as is.
+//
+// # Returns
+//
+// The modified adapter.
+func (adapter *NhooyrWebsocketConnectionAdapter) WithPropagationMode(mode wsadapters.PropagationMode) *NhooyrWebsocketConnectionAdapter {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	adapter.propagationMode = mode
+	return adapter
+}
+
+// # Description
+//
+// The adapter does not stream messages: the returned writer buffers the message content and
+// writes the whole message with Write when it is closed.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose. Provided to Write when the writer is closed.
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+// A writer for the message content. Close returns the error returned by Write.
+func (adapter *NhooyrWebsocketConnectionAdapter) WriteStream(ctx context.Context, msgType wsadapters.MessageType) (io.WriteCloser, error) {
+	return wsadapters.NewBufferedMessageWriter(ctx, msgType, adapter.Write), nil
+}
+
 // # Description
 //
 // Return the underlying websocket connection if any. Returned value has to be type asserted.
@@ -268,6 +401,36 @@ func (adapter *NhooyrWebsocketConnectionAdapter) GetUnderlyingWebsocketConnectio
 	return adapter.conn
 }
 
+// # Description
+//
+// Return the subprotocol selected by the server for the current connection. Subprotocols are
+// proposed to the server with the Subprotocols field of the dial options.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string if the server has not selected any subprotocol
+// or if no connection is up.
+func (adapter *NhooyrWebsocketConnectionAdapter) NegotiatedSubprotocol() string {
+	// Lock internal mutex before accessing internal state
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+	if adapter.conn == nil {
+		return ""
+	}
+	return adapter.conn.Subprotocol()
+}
+
+// # Description
+//
+// The adapter does not track its read throughput.
+//
+// # Returns
+//
+// A zero value.
+func (adapter *NhooyrWebsocketConnectionAdapter) ReadStats() wsadapters.AdapterReadStats {
+	return wsadapters.AdapterReadStats{}
+}
+
 /*************************************************************************************************/
 /* UTILS                                                                                         */
 /*************************************************************************************************/
@@ -405,3 +568,39 @@ func convertFromNhooyrMsgTypes(msgType websocket.MessageType) wsadapters.Message
 	}
 	return wsadapters.Binary
 }
+
+// Return a copy of the dial options which use the provided host for the Host header and for TLS
+// server name verification. TLS server name is only set when the HTTP client uses a
+// *http.Transport (the default).
+func withDialHost(opts *websocket.DialOptions, host string) *websocket.DialOptions {
+	copyOpts := websocket.DialOptions{}
+	if opts != nil {
+		copyOpts = *opts
+	}
+	copyOpts.Host = host
+	client := http.DefaultClient
+	if copyOpts.HTTPClient != nil {
+		client = copyOpts.HTTPClient
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if ok {
+		transport = transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		if transport.TLSClientConfig.ServerName == "" {
+			serverName := host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				serverName = h
+			}
+			transport.TLSClientConfig.ServerName = serverName
+		}
+		clientCopy := *client
+		clientCopy.Transport = transport
+		copyOpts.HTTPClient = &clientCopy
+	}
+	return &copyOpts
+}
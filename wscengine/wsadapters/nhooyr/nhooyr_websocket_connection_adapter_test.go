@@ -5,7 +5,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -339,3 +341,68 @@ func (suite *NhooyrWebsocketConnectionAdapterTestSuite) TestClosureOnCtxCanceled
 	err = adapter.Ping(ctx)
 	require.Error(suite.T(), err)
 }
+
+/*************************************************************************************************/
+/* SERVER SIDE ADAPTER TEST SUITE                                                                */
+/*************************************************************************************************/
+
+// Test suite used to test adapters created for server side connections
+type NhooyrServerWebsocketConnectionAdapterTestSuite struct {
+	suite.Suite
+}
+
+// Run NhooyrServerWebsocketConnectionAdapterTestSuite test suite
+func TestNhooyrServerWebsocketConnectionAdapterTestSuite(t *testing.T) {
+	suite.Run(t, new(NhooyrServerWebsocketConnectionAdapterTestSuite))
+}
+
+// Test an adapter created from an accepted connection echoes messages and propagates the close
+// message of the client.
+func (suite *NhooyrServerWebsocketConnectionAdapterTestSuite) TestFromAccept() {
+	closeErrs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		adapter := NewNhooyrWebsocketConnectionAdapterFromAccept(conn)
+		require.Same(suite.T(), conn, adapter.GetUnderlyingWebsocketConnection())
+		// Dial is not allowed on server side adapters
+		_, err = adapter.Dial(r.Context(), url.URL{})
+		require.Error(suite.T(), err)
+		for {
+			msgType, msg, err := adapter.Read(r.Context())
+			if err != nil {
+				closeErrs <- err
+				return
+			}
+			if err := adapter.Write(r.Context(), msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	target, err := url.Parse("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(suite.T(), err)
+	client := NewNhooyrWebsocketConnectionAdapter(nil)
+	_, err = client.Dial(ctx, *target)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), client.Write(ctx, wsconnadapter.Text, []byte("hello")))
+	msgType, msg, err := client.Read(ctx)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), wsconnadapter.Text, msgType)
+	require.Equal(suite.T(), []byte("hello"), msg)
+	require.NoError(suite.T(), client.Close(ctx, wsconnadapter.GoingAway, "bye"))
+	// Server side adapter receives the close message as a WebsocketCloseError
+	select {
+	case err := <-closeErrs:
+		closeErr := new(wsconnadapter.WebsocketCloseError)
+		require.ErrorAs(suite.T(), err, closeErr)
+		require.Equal(suite.T(), wsconnadapter.GoingAway, closeErr.Code)
+		require.Contains(suite.T(), closeErr.Reason, "bye")
+	case <-ctx.Done():
+		require.Fail(suite.T(), "server did not receive the close message")
+	}
+}
@@ -0,0 +1,182 @@
+package wsadapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Mode used by WritePropagated to embed the trace context in outgoing messages.
+type PropagationMode int
+
+const (
+	// The trace context is not propagated: the message is written as is.
+	None PropagationMode = iota
+	// The message is wrapped in a JSON envelope which carries the trace context headers:
+	//
+	//	{"trace_context":{"traceparent":"..."},"payload":<message>}
+	//
+	// The message content must be valid JSON. The message type is kept.
+	JSONEnvelope
+	// The trace context headers are prepended to the message in a binary extension header. The
+	// message is always written as a binary message. See InjectTraceContext for the layout.
+	BinaryExtensionFrame
+)
+
+// Magic bytes which start the binary extension header.
+var propagationFrameMagic = []byte("WSTC")
+
+// JSON envelope used by the JSONEnvelope propagation mode.
+type propagationEnvelope struct {
+	// Trace context headers
+	TraceContext map[string]string `json:"trace_context"`
+	// Message content
+	Payload json.RawMessage `json:"payload"`
+}
+
+// # Description
+//
+// Return the name of the propagation mode.
+func (mode PropagationMode) String() string {
+	switch mode {
+	case None:
+		return "none"
+	case JSONEnvelope:
+		return "json_envelope"
+	case BinaryExtensionFrame:
+		return "binary_extension_frame"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(mode))
+	}
+}
+
+// # Description
+//
+// Embed the trace context carried by ctx in a message according to the provided propagation
+// mode. Adapters use it to implement WritePropagated and servers can use ExtractTraceContext to
+// read the trace context back.
+//
+// With BinaryExtensionFrame, the message is encoded as follows:
+//
+//	magic "WSTC" (4 bytes) | original message type (1 byte) |
+//	header length (2 bytes, big endian) | headers (JSON object) | message content
+//
+// # Inputs
+//
+//   - ctx: Context which carries the span to propagate.
+//   - mode: Propagation mode.
+//   - msgType: Message type.
+//   - msg: Message content.
+//   - propagator: Propagator used to inject the trace context. If nil, the global propagator is
+//     used.
+//
+// # Returns
+//
+// The type and the content of the message to write, or an error which wraps
+// ErrInvalidEnvelopePayload if the message cannot be wrapped in a JSON envelope or
+// ErrUnknownPropagationMode if the mode is unknown.
+func InjectTraceContext(
+	ctx context.Context,
+	mode PropagationMode,
+	msgType MessageType,
+	msg []byte,
+	propagator propagation.TextMapPropagator) (MessageType, []byte, error) {
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	switch mode {
+	case None:
+		return msgType, msg, nil
+	case JSONEnvelope:
+		if !json.Valid(msg) {
+			return msgType, nil, ErrInvalidEnvelopePayload
+		}
+		carrier := propagation.MapCarrier{}
+		propagator.Inject(ctx, carrier)
+		envelope, err := json.Marshal(propagationEnvelope{TraceContext: carrier, Payload: msg})
+		if err != nil {
+			return msgType, nil, fmt.Errorf("failed to encode envelope: %w", err)
+		}
+		return msgType, envelope, nil
+	case BinaryExtensionFrame:
+		carrier := propagation.MapCarrier{}
+		propagator.Inject(ctx, carrier)
+		headers, err := json.Marshal(carrier)
+		if err != nil {
+			return msgType, nil, fmt.Errorf("failed to encode headers: %w", err)
+		}
+		if len(headers) > 0xFFFF {
+			return msgType, nil, fmt.Errorf("trace context headers are too large: %d bytes", len(headers))
+		}
+		frame := make([]byte, 0, len(propagationFrameMagic)+3+len(headers)+len(msg))
+		frame = append(frame, propagationFrameMagic...)
+		frame = append(frame, byte(msgType))
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(headers)))
+		frame = append(frame, headers...)
+		frame = append(frame, msg...)
+		return Binary, frame, nil
+	default:
+		return msgType, nil, fmt.Errorf("%w: %d", ErrUnknownPropagationMode, mode)
+	}
+}
+
+// # Description
+//
+// Read the trace context embedded by InjectTraceContext in a received message.
+//
+// # Inputs
+//
+//   - ctx: Parent context.
+//   - mode: Propagation mode used by the peer.
+//   - msgType: Received message type.
+//   - msg: Received message content.
+//   - propagator: Propagator used to extract the trace context. If nil, the global propagator is
+//     used.
+//
+// # Returns
+//
+// A context derived from ctx which carries the extracted trace context, the original type and
+// content of the message, or an error which wraps ErrMalformedPropagatedMessage if the message
+// cannot be decoded or ErrUnknownPropagationMode if the mode is unknown.
+func ExtractTraceContext(
+	ctx context.Context,
+	mode PropagationMode,
+	msgType MessageType,
+	msg []byte,
+	propagator propagation.TextMapPropagator) (context.Context, MessageType, []byte, error) {
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	switch mode {
+	case None:
+		return ctx, msgType, msg, nil
+	case JSONEnvelope:
+		envelope := propagationEnvelope{}
+		if err := json.Unmarshal(msg, &envelope); err != nil {
+			return ctx, msgType, nil, fmt.Errorf("%w: %w", ErrMalformedPropagatedMessage, err)
+		}
+		return propagator.Extract(ctx, propagation.MapCarrier(envelope.TraceContext)), msgType, envelope.Payload, nil
+	case BinaryExtensionFrame:
+		headerStart := len(propagationFrameMagic) + 3
+		if len(msg) < headerStart || !bytes.Equal(msg[:len(propagationFrameMagic)], propagationFrameMagic) {
+			return ctx, msgType, nil, fmt.Errorf("%w: missing extension header", ErrMalformedPropagatedMessage)
+		}
+		originalType := MessageType(msg[len(propagationFrameMagic)])
+		headerEnd := headerStart + int(binary.BigEndian.Uint16(msg[len(propagationFrameMagic)+1:]))
+		if len(msg) < headerEnd {
+			return ctx, msgType, nil, fmt.Errorf("%w: truncated extension header", ErrMalformedPropagatedMessage)
+		}
+		carrier := propagation.MapCarrier{}
+		if err := json.Unmarshal(msg[headerStart:headerEnd], &carrier); err != nil {
+			return ctx, msgType, nil, fmt.Errorf("%w: %w", ErrMalformedPropagatedMessage, err)
+		}
+		return propagator.Extract(ctx, carrier), originalType, msg[headerEnd:], nil
+	default:
+		return ctx, msgType, nil, fmt.Errorf("%w: %d", ErrUnknownPropagationMode, mode)
+	}
+}
@@ -0,0 +1,92 @@
+package wsadapters
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Test the trace context embedded in a JSON envelope is extracted back.
+func TestJSONEnvelopeRoundTrip(t *testing.T) {
+	ctx := newPropagationTestContext()
+	msgType, msg, err := InjectTraceContext(ctx, JSONEnvelope, Text, []byte(`{"op":"subscribe"}`), propagation.TraceContext{})
+	require.NoError(t, err)
+	require.Equal(t, Text, msgType)
+	envelope := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(msg, &envelope))
+	require.JSONEq(t, `{"traceparent":"00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"}`, string(envelope["trace_context"]))
+	require.JSONEq(t, `{"op":"subscribe"}`, string(envelope["payload"]))
+	extracted, msgType, payload, err := ExtractTraceContext(context.Background(), JSONEnvelope, msgType, msg, propagation.TraceContext{})
+	require.NoError(t, err)
+	require.Equal(t, Text, msgType)
+	require.JSONEq(t, `{"op":"subscribe"}`, string(payload))
+	require.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), trace.SpanContextFromContext(extracted).TraceID())
+	require.True(t, trace.SpanContextFromContext(extracted).IsRemote())
+	// Message content must be valid JSON
+	_, _, err = InjectTraceContext(ctx, JSONEnvelope, Text, []byte("not json"), propagation.TraceContext{})
+	require.ErrorIs(t, err, ErrInvalidEnvelopePayload)
+	_, _, _, err = ExtractTraceContext(ctx, JSONEnvelope, Text, []byte("not json"), propagation.TraceContext{})
+	require.ErrorIs(t, err, ErrMalformedPropagatedMessage)
+}
+
+// Test the trace context embedded in a binary extension header is extracted back with the
+// original message type.
+func TestBinaryExtensionFrameRoundTrip(t *testing.T) {
+	ctx := newPropagationTestContext()
+	msgType, msg, err := InjectTraceContext(ctx, BinaryExtensionFrame, Text, []byte("hello"), propagation.TraceContext{})
+	require.NoError(t, err)
+	require.Equal(t, Binary, msgType)
+	require.Equal(t, []byte("WSTC"), msg[:4])
+	require.Equal(t, byte(Text), msg[4])
+	extracted, msgType, payload, err := ExtractTraceContext(context.Background(), BinaryExtensionFrame, msgType, msg, propagation.TraceContext{})
+	require.NoError(t, err)
+	require.Equal(t, Text, msgType)
+	require.Equal(t, []byte("hello"), payload)
+	require.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), trace.SpanContextFromContext(extracted).SpanID())
+	// Malformed messages are rejected
+	for _, malformed := range [][]byte{
+		[]byte("hello"),
+		[]byte("WSTC\x01"),
+		[]byte("WSTC\x01\x00\x10{}"),
+		[]byte("WSTC\x01\x00\x02[]"),
+	} {
+		_, _, _, err = ExtractTraceContext(ctx, BinaryExtensionFrame, Binary, malformed, propagation.TraceContext{})
+		require.ErrorIs(t, err, ErrMalformedPropagatedMessage)
+	}
+}
+
+// Test messages are kept as is with None and unknown modes are rejected.
+func TestPropagationModes(t *testing.T) {
+	ctx := newPropagationTestContext()
+	msgType, msg, err := InjectTraceContext(ctx, None, Text, []byte("hello"), nil)
+	require.NoError(t, err)
+	require.Equal(t, Text, msgType)
+	require.Equal(t, []byte("hello"), msg)
+	extracted, _, msg, err := ExtractTraceContext(ctx, None, Text, msg, nil)
+	require.NoError(t, err)
+	require.Equal(t, ctx, extracted)
+	require.Equal(t, []byte("hello"), msg)
+	_, _, err = InjectTraceContext(ctx, PropagationMode(42), Text, msg, nil)
+	require.ErrorIs(t, err, ErrUnknownPropagationMode)
+	_, _, _, err = ExtractTraceContext(ctx, PropagationMode(42), Text, msg, nil)
+	require.ErrorIs(t, err, ErrUnknownPropagationMode)
+	require.Equal(t, "json_envelope", JSONEnvelope.String())
+	require.Equal(t, "unknown(42)", PropagationMode(42).String())
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Return a context which carries a sampled span context
+func newPropagationTestContext() context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
@@ -0,0 +1,135 @@
+package wsadapters
+
+import (
+	"sync"
+	"time"
+)
+
+// Default duration of the sliding window used to compute read throughput.
+const DefaultReadStatsWindow = 10 * time.Second
+
+// Default maximum number of messages recorded in the sliding window.
+const DefaultReadStatsCapacity = 4096
+
+// Read throughput of an adapter averaged over a sliding window.
+type AdapterReadStats struct {
+	// Average number of messages read per second
+	MessagesPerSecond float64
+	// Average number of bytes read per second
+	BytesPerSecond float64
+	// Time the last message has been read - zero value if no message has been read
+	LastMessageAt time.Time
+}
+
+// Message recorded by ReadStatsRecorder.
+type readSample struct {
+	// Time the message has been read
+	at time.Time
+	// Message size in bytes
+	size int
+}
+
+// Recorder which computes the read throughput of an adapter over a sliding window. Read messages
+// are recorded in a fixed size ring buffer: when more messages than the buffer capacity are read
+// during the window, the throughput is averaged over the period covered by the buffer instead.
+//
+// The recorder is safe for concurrent use.
+type ReadStatsRecorder struct {
+	// Duration of the sliding window
+	window time.Duration
+	// Mutex used to protect the fields below
+	mu sync.Mutex
+	// Ring buffer of recorded messages
+	samples []readSample
+	// Index where the next message is recorded
+	next int
+	// Number of recorded messages in the ring buffer
+	count int
+	// Time the last message has been read
+	lastMessageAt time.Time
+}
+
+// # Description
+//
+// Factory which creates a new ReadStatsRecorder.
+//
+// # Inputs
+//
+//   - window: Duration of the sliding window. If 0 or less, DefaultReadStatsWindow is used.
+//   - capacity: Maximum number of messages recorded in the sliding window. If 0 or less,
+//     DefaultReadStatsCapacity is used.
+//
+// # Returns
+//
+// New ReadStatsRecorder
+func NewReadStatsRecorder(window time.Duration, capacity int) *ReadStatsRecorder {
+	if window <= 0 {
+		window = DefaultReadStatsWindow
+	}
+	if capacity <= 0 {
+		capacity = DefaultReadStatsCapacity
+	}
+	return &ReadStatsRecorder{
+		window:  window,
+		samples: make([]readSample, capacity),
+	}
+}
+
+// # Description
+//
+// Record a message of the provided size read now.
+func (recorder *ReadStatsRecorder) Record(size int) {
+	recorder.record(time.Now(), size)
+}
+
+// # Description
+//
+// Return the read throughput averaged over the sliding window which ends now.
+func (recorder *ReadStatsRecorder) Stats() AdapterReadStats {
+	return recorder.stats(time.Now())
+}
+
+// Record a message of the provided size read at the provided time.
+func (recorder *ReadStatsRecorder) record(at time.Time, size int) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.samples[recorder.next] = readSample{at: at, size: size}
+	recorder.next = (recorder.next + 1) % len(recorder.samples)
+	if recorder.count < len(recorder.samples) {
+		recorder.count++
+	}
+	recorder.lastMessageAt = at
+}
+
+// Compute the read throughput averaged over the sliding window which ends at the provided time.
+func (recorder *ReadStatsRecorder) stats(now time.Time) AdapterReadStats {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	stats := AdapterReadStats{LastMessageAt: recorder.lastMessageAt}
+	start := now.Add(-recorder.window)
+	// Walk the ring buffer from the most recent message to the oldest one in the window
+	messages, bytes := 0, 0
+	var oldest time.Time
+	for i := 1; i <= recorder.count; i++ {
+		sample := recorder.samples[(recorder.next-i+len(recorder.samples))%len(recorder.samples)]
+		if sample.at.Before(start) {
+			break
+		}
+		messages++
+		bytes = bytes + sample.size
+		oldest = sample.at
+	}
+	if messages == 0 {
+		return stats
+	}
+	// Average over the period covered by the buffer if it does not cover the whole window
+	period := recorder.window
+	if messages == len(recorder.samples) {
+		if covered := now.Sub(oldest); covered > 0 && covered < period {
+			period = covered
+		}
+	}
+	stats.MessagesPerSecond = float64(messages) / period.Seconds()
+	stats.BytesPerSecond = float64(bytes) / period.Seconds()
+	return stats
+}
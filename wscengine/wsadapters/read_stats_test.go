@@ -0,0 +1,91 @@
+package wsadapters
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for ReadStatsRecorder unit tests
+type ReadStatsRecorderUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run ReadStatsRecorderUnitTestSuite test suite
+func TestReadStatsRecorderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReadStatsRecorderUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory applies defaults.
+func (suite *ReadStatsRecorderUnitTestSuite) TestNewReadStatsRecorder() {
+	recorder := NewReadStatsRecorder(0, 0)
+	require.Equal(suite.T(), DefaultReadStatsWindow, recorder.window)
+	require.Len(suite.T(), recorder.samples, DefaultReadStatsCapacity)
+	require.Equal(suite.T(), AdapterReadStats{}, recorder.Stats())
+}
+
+// Test the throughput is averaged over the sliding window.
+func (suite *ReadStatsRecorderUnitTestSuite) TestSlidingWindow() {
+	recorder := NewReadStatsRecorder(10*time.Second, 100)
+	start := time.Now()
+	// 20 messages of 100 bytes during the first 10 seconds
+	for i := 0; i < 20; i++ {
+		recorder.record(start.Add(time.Duration(i)*500*time.Millisecond), 100)
+	}
+	last := start.Add(19 * 500 * time.Millisecond)
+	stats := recorder.stats(start.Add(10 * time.Second))
+	require.InDelta(suite.T(), 2, stats.MessagesPerSecond, 0.001)
+	require.InDelta(suite.T(), 200, stats.BytesPerSecond, 0.001)
+	require.Equal(suite.T(), last, stats.LastMessageAt)
+	// Half of the messages are out of the window 5 seconds later
+	stats = recorder.stats(start.Add(15 * time.Second))
+	require.InDelta(suite.T(), 1, stats.MessagesPerSecond, 0.001)
+	require.InDelta(suite.T(), 100, stats.BytesPerSecond, 0.001)
+	// No message in the window
+	stats = recorder.stats(start.Add(time.Minute))
+	require.Equal(suite.T(), AdapterReadStats{LastMessageAt: last}, stats)
+}
+
+// Test the throughput is averaged over the period covered by the buffer when it is full.
+func (suite *ReadStatsRecorderUnitTestSuite) TestFullBuffer() {
+	recorder := NewReadStatsRecorder(10*time.Second, 10)
+	start := time.Now()
+	// 100 messages per second during 1 second
+	for i := 0; i < 100; i++ {
+		recorder.record(start.Add(time.Duration(i)*10*time.Millisecond), 1)
+	}
+	// Buffer covers the last 10 messages - 100ms
+	stats := recorder.stats(start.Add(time.Second))
+	require.InDelta(suite.T(), 100, stats.MessagesPerSecond, 0.001)
+	require.InDelta(suite.T(), 100, stats.BytesPerSecond, 0.001)
+}
+
+// Test the recorder can be used concurrently.
+func (suite *ReadStatsRecorderUnitTestSuite) TestConcurrency() {
+	recorder := NewReadStatsRecorder(time.Minute, 16)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				recorder.Record(10)
+				recorder.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+	require.False(suite.T(), recorder.Stats().LastMessageAt.IsZero())
+	require.Greater(suite.T(), recorder.Stats().MessagesPerSecond, float64(0))
+}
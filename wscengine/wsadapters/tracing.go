@@ -12,12 +12,20 @@ const (
 	spanDial = namespace + "." + "dial"
 	// Name of the span used to instrument Close method call
 	spanClose = namespace + "." + "close"
+	// Name of the span used to instrument Drop method call
+	spanDrop = namespace + "." + "drop"
 	// Name of span used to instrument Ping method call
 	spanPing = namespace + "." + "ping"
 	// Name of span sed to instrument Write method call
 	spanWrite = namespace + "." + "write"
+	// Name of span used to instrument WriteStream method call
+	spanWriteStream = namespace + "." + "write.stream"
+	// Name of span used to instrument WritePropagated method call
+	spanWritePropagated = namespace + "." + "write.propagated"
 	// Name of span sed to instrument Read method call
 	spanRead = namespace + "." + "read"
+	// Name of span used to instrument ReadStream method call
+	spanReadStream = namespace + "." + "read.stream"
 
 	// Name of event used when a message has been received
 	eventReceived = namespace + "." + "message.received"
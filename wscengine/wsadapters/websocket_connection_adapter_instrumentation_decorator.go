@@ -4,12 +4,14 @@ package wsadapters
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -86,6 +88,22 @@ func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Close(ctx c
 	return err
 }
 
+// Decorate and instrument the Drop method of a WebsocketConnectionAdapterInterface implementation.
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Drop(ctx context.Context) error {
+	// Start span
+	ctx, span := decorator.tracer.Start(ctx, spanDrop, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	// Call decorated Drop method
+	err := decorator.decorated.Drop(ctx)
+	if err != nil {
+		// Trace error
+		span.RecordError(err)
+		span.SetStatus(codes.Error, codes.Error.String())
+	}
+	// Return decorated results
+	return err
+}
+
 // Decorate and instrument the Close method of a WebsocketConnectionAdapterInterface implementation.
 func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Ping(ctx context.Context) error {
 	// Start span
@@ -123,6 +141,28 @@ func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Read(ctx co
 	return msgType, msg, err
 }
 
+// Decorate and instrument the ReadStream method of a WebsocketConnectionAdapterInterface
+// implementation. The span ends when the next message is available, before the message content is
+// read.
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) ReadStream(ctx context.Context) (MessageType, io.Reader, error) {
+	// Start span
+	ctx, span := decorator.tracer.Start(ctx, spanReadStream, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	// Call decorated ReadStream method
+	msgType, reader, err := decorator.decorated.ReadStream(ctx)
+	if err != nil {
+		// Trace error
+		span.RecordError(err)
+		span.SetStatus(codes.Error, codes.Error.String())
+	}
+	// Add received event
+	span.AddEvent(eventReceived, trace.WithAttributes(
+		attribute.Int(attrMessageType, int(msgType)),
+	))
+	// Return decorated results
+	return msgType, reader, err
+}
+
 // Decorate and instrument the Read method of a WebsocketConnectionAdapterInterface implementation.
 func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Write(ctx context.Context, msgType MessageType, msg []byte) error {
 	// Start span
@@ -144,7 +184,61 @@ func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) Write(ctx c
 	return err
 }
 
+// Instrument WritePropagated method: the span started by the decorator is the one propagated to
+// the server.
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) WritePropagated(ctx context.Context, msgType MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	// Start span
+	ctx, span := decorator.tracer.Start(ctx, spanWritePropagated,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.Int(attrMessageByteSize, len(msg)),
+			attribute.Int(attrMessageType, int(msgType)),
+		))
+	defer span.End()
+	// Call decorated WritePropagated method
+	err := decorator.decorated.WritePropagated(ctx, msgType, msg, propagator)
+	if err != nil {
+		// Trace error
+		span.RecordError(err)
+		span.SetStatus(codes.Error, codes.Error.String())
+	}
+	// Return decorated results
+	return err
+}
+
+// Decorate and instrument the WriteStream method of a WebsocketConnectionAdapterInterface
+// implementation. The span ends when the writer is returned, before the message content is
+// written.
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) WriteStream(ctx context.Context, msgType MessageType) (io.WriteCloser, error) {
+	// Start span
+	ctx, span := decorator.tracer.Start(ctx, spanWriteStream,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int(attrMessageType, int(msgType)),
+		))
+	defer span.End()
+	// Call decorated WriteStream method
+	writer, err := decorator.decorated.WriteStream(ctx, msgType)
+	if err != nil {
+		// Trace error
+		span.RecordError(err)
+		span.SetStatus(codes.Error, codes.Error.String())
+	}
+	// Return decorated results
+	return writer, err
+}
+
 // Simple proxy for non-instrumented getter
 func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) GetUnderlyingWebsocketConnection() any {
 	return decorator.decorated.GetUnderlyingWebsocketConnection()
 }
+
+// Simple proxy for non-instrumented getter
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) NegotiatedSubprotocol() string {
+	return decorator.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for non-instrumented getter
+func (decorator *WebsocketConnectionAdapterInstrumentationDecorator) ReadStats() AdapterReadStats {
+	return decorator.decorated.ReadStats()
+}
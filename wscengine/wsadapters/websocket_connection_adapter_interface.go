@@ -3,8 +3,11 @@ package wsadapters
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/url"
+
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // Interface which describes the adapter methods and behaviour that the websocket engine expects
@@ -28,7 +31,10 @@ type WebsocketConnectionAdapterInterface interface {
 	//    later by other adapter methods.
 	//
 	//	- Dial MUST return an error in case a connection has already been established and Close
-	//	  method has not been called yet.
+	//	  method has not been called yet. The error SHOULD be (or wrap) ErrAlreadyConnected.
+	//
+	//	- Decorators MUST return the response of the decorated Dial as is: the engine provides it
+	//    to the OnOpen callback of the client.
 	//
 	// # Inputs
 	//
@@ -63,6 +69,26 @@ type WebsocketConnectionAdapterInterface interface {
 	Close(ctx context.Context, code StatusCode, reason string) error
 	// # Description
 	//
+	// Close the underlying network connection without sending a close message: no closing
+	// handshake is performed and the server sees an abnormal closure (1006).
+	//
+	// # Expected behaviour
+	//
+	//	- Drop MUST NOT send a close message to the server.
+	//	- Drop MUST drop pending write/read messages and pending Ping MUST return an error.
+	//	- Close, Ping, Read and Write SHOULD return an error which wraps ErrNotConnected after Drop.
+	//
+	// # Inputs
+	//
+	//	- ctx: Context used for tracing purpose
+	//
+	// # Returns
+	//
+	//	- nil in case of success
+	//	- error: an error which wraps ErrNotConnected if there is no connection, ...
+	Drop(ctx context.Context) error
+	// # Description
+	//
 	// Send a Ping message to the websocket server and blocks until a Pong response is received, a
 	// timmeout occurs, or connection is closed.
 	//
@@ -79,6 +105,10 @@ type WebsocketConnectionAdapterInterface interface {
 	//
 	//	- Ping MUST return an error if connection is closed, if server is unreachable or if context
 	//    has expired (timeout or cancel). In this later case, Ping MUST return the context error.
+	//    Otherwise, the error SHOULD wrap ErrPingFailed.
+	//
+	//	- Close, Ping, Read and Write SHOULD return an error which wraps ErrNotConnected when there
+	//    is no connection.
 	//
 	// # Inputs
 	//
@@ -136,7 +166,9 @@ type WebsocketConnectionAdapterInterface interface {
 	//
 	// # Inputs
 	//
-	//	- ctx: Context used for tracing/timeout purpose
+	//	- ctx: Context used for tracing/timeout purpose. Decorators MUST pass ctx, or a context
+	//    derived from it, to the decorated Write so spans started by the caller are the parents
+	//    of the write spans.
 	//	- MessageType: received message type (Binary | Text)
 	//	- []bytes: Message content
 	//
@@ -146,10 +178,112 @@ type WebsocketConnectionAdapterInterface interface {
 	Write(ctx context.Context, msgType MessageType, msg []byte) error
 	// # Description
 	//
+	// Return a writer which streams the content of a single message to the server so large
+	// messages do not have to be materialized before they are written. The message is complete
+	// once the writer is closed.
+	//
+	// # Expected behaviour
+	//
+	//	- Callers MUST close the returned writer to complete the message. Other messages cannot be
+	//    written until the writer is closed.
+	//
+	//	- Adapters for libraries which cannot stream messages MAY buffer the content and write
+	//    the whole message when the writer is closed.
+	//
+	// # Inputs
+	//
+	//	- ctx: Context used for tracing/timeout purpose.
+	//	- MessageType: message type (Binary | Text)
+	//
+	// # Returns
+	//
+	//	- io.WriteCloser: Writer for the message content. Close flushes the content and completes
+	//    the message.
+	//	- error: in case of connection closure, context timeout/cancellation or failure.
+	WriteStream(ctx context.Context, msgType MessageType) (io.WriteCloser, error)
+	// # Description
+	//
+	// Write a single message which carries the trace context of ctx so the server can continue
+	// the trace of the caller. The trace context is embedded according to the propagation mode
+	// configured on the adapter (None by default): see PropagationMode and InjectTraceContext.
+	//
+	// # Expected behaviour
+	//
+	//	- WritePropagated MUST behave like Write once the trace context has been embedded.
+	//
+	//	- Decorators which do not transform the message content SHOULD call the decorated
+	//    WritePropagated so the propagation mode of the decorated adapter is used.
+	//
+	// # Inputs
+	//
+	//	- ctx: Context which carries the span to propagate. Used for timeout purpose too.
+	//	- MessageType: message type (Binary | Text)
+	//	- []bytes: Message content
+	//	- propagator: Propagator used to inject the trace context. If nil, the global propagator
+	//    is used.
+	//
+	// # Returns
+	//
+	//	- error: in case the trace context cannot be embedded, connection closure, context
+	//    timeout/cancellation or failure.
+	WritePropagated(ctx context.Context, msgType MessageType, msg []byte, propagator propagation.TextMapPropagator) error
+	// # Description
+	//
+	// Wait for the next message and return a reader which streams its content instead of loading
+	// the whole message in memory. Use it to process large messages.
+	//
+	// # Expected behaviour
+	//
+	//	- ReadStream MUST behave like Read regarding errors: if the connection is closed, the
+	//    returned error MUST be (or wrap) a WebsocketCloseError.
+	//
+	//	- The returned reader is only valid until the next call to Read or ReadStream: callers
+	//    MUST consume the reader (until it returns io.EOF or an error) before they read the next
+	//    message.
+	//
+	//	- Adapters for libraries which cannot stream messages MAY read the whole message and
+	//    return a reader on it.
+	//
+	// # Inputs
+	//
+	//	- ctx: context used for tracing/timeout purpose
+	//
+	// # Returns
+	//
+	//	- MessageType: received message type (Binary | Text)
+	//	- io.Reader: Reader which streams the message content
+	//	- error: in case of connection closure, context timeout/cancellation or failure.
+	ReadStream(ctx context.Context) (MessageType, io.Reader, error)
+	// # Description
+	//
 	// Return the underlying websocket connection if any. Returned value has to be type asserted.
 	//
 	// # Returns
 	//
 	// The underlying websocket connection if any. Returned value has to be type asserted.
 	GetUnderlyingWebsocketConnection() any
+	// # Description
+	//
+	// Return the subprotocol selected by the server during the opening handshake, as returned
+	// in the Sec-WebSocket-Protocol header of the handshake response. Subprotocols proposed by
+	// the client are usually set in the request headers provided to the adapter.
+	//
+	// Clients can call this method from OnOpen to check which subprotocol has been agreed.
+	//
+	// # Returns
+	//
+	// The negotiated subprotocol or an empty string if the server has not selected any
+	// subprotocol, if the library does not expose it or if no connection is up.
+	NegotiatedSubprotocol() string
+	// # Description
+	//
+	// Return the read throughput of the adapter (messages and bytes read per second) averaged
+	// over a sliding window, and the time the last message has been read. Only messages
+	// successfully returned by Read are accounted. Statistics are not reset when a new
+	// connection is opened.
+	//
+	// # Returns
+	//
+	// The read throughput statistics or a zero value if the adapter does not track them.
+	ReadStats() AdapterReadStats
 }
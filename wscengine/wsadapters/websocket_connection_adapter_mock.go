@@ -3,10 +3,12 @@ package wsadapters
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/url"
 
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // Mock for WebsocketConnectionAdapterInterface
@@ -81,6 +83,23 @@ func (mock *WebsocketConnectionAdapterInterfaceMock) Close(ctx context.Context,
 	return args.Error(0)
 }
 
+// # Description
+//
+// Close the underlying network connection without sending a close message.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing purpose
+//
+// # Returns
+//
+//   - nil in case of success
+//   - error: connection already closed, ...
+func (mock *WebsocketConnectionAdapterInterfaceMock) Drop(ctx context.Context) error {
+	args := mock.Called(ctx)
+	return args.Error(0)
+}
+
 // # Description
 //
 // Send a Ping message to the websocket server and blocks until a Pong response is received.
@@ -144,6 +163,25 @@ func (mock *WebsocketConnectionAdapterInterfaceMock) Read(ctx context.Context) (
 	return MessageType(args.Int(0)), args.Get(1).([]byte), args.Error(2)
 }
 
+// # Description
+//
+// Wait for the next message and return a reader which streams its content.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//
+// # Returns
+//
+//   - MessageType: received message type (Binary | Text)
+//   - io.Reader: Reader which streams the message content - can be nil in case of error
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (mock *WebsocketConnectionAdapterInterfaceMock) ReadStream(ctx context.Context) (MessageType, io.Reader, error) {
+	args := mock.Called(ctx)
+	reader, _ := args.Get(1).(io.Reader)
+	return MessageType(args.Int(0)), reader, args.Error(2)
+}
+
 // # Description
 //
 // Write a single message to the websocket server. Write blocks until message is sent to the
@@ -172,6 +210,44 @@ func (mock *WebsocketConnectionAdapterInterfaceMock) Write(ctx context.Context,
 	return args.Error(0)
 }
 
+// # Description
+//
+// Write a single message which carries the trace context of ctx.
+//
+// # Inputs
+//
+//   - ctx: Context which carries the span to propagate
+//   - MessageType: message type (Binary | Text)
+//   - []bytes: Message content
+//   - propagator: Propagator used to inject the trace context
+//
+// # Returns
+//
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (mock *WebsocketConnectionAdapterInterfaceMock) WritePropagated(ctx context.Context, msgType MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	args := mock.Called(ctx, msgType, msg, propagator)
+	return args.Error(0)
+}
+
+// # Description
+//
+// Return a writer which streams the content of a single message to the server.
+//
+// # Inputs
+//
+//   - ctx: Context used for tracing/timeout purpose
+//   - MessageType: message type (Binary | Text)
+//
+// # Returns
+//
+//   - io.WriteCloser: Writer for the message content - can be nil in case of error
+//   - error: in case of connection closure, context timeout/cancellation or failure.
+func (mock *WebsocketConnectionAdapterInterfaceMock) WriteStream(ctx context.Context, msgType MessageType) (io.WriteCloser, error) {
+	args := mock.Called(ctx, msgType)
+	writer, _ := args.Get(0).(io.WriteCloser)
+	return writer, args.Error(1)
+}
+
 // # Description
 //
 // Return the underlying websocket connection if any. Returned value has to be type asserted.
@@ -183,3 +259,27 @@ func (mock *WebsocketConnectionAdapterInterfaceMock) GetUnderlyingWebsocketConne
 	args := mock.Called()
 	return args.Get(0)
 }
+
+// # Description
+//
+// Return the subprotocol selected by the server during the opening handshake.
+//
+// # Returns
+//
+// The negotiated subprotocol or an empty string.
+func (mock *WebsocketConnectionAdapterInterfaceMock) NegotiatedSubprotocol() string {
+	args := mock.Called()
+	return args.String(0)
+}
+
+// # Description
+//
+// Return the read throughput statistics of the adapter.
+//
+// # Returns
+//
+// The read throughput statistics.
+func (mock *WebsocketConnectionAdapterInterfaceMock) ReadStats() AdapterReadStats {
+	args := mock.Called()
+	return args.Get(0).(AdapterReadStats)
+}
@@ -0,0 +1,211 @@
+package wsadapters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Name of the metric incremented each time the P99 write latency exceeds the slow write threshold
+const metricWriteSlow = "wscengine.write.slow"
+
+// Number of writes between two checks of the P99 write latency
+const slowWriteCheckPeriod = 64
+
+// Statistics collected by StatsAdapter.
+type ConnectionStats struct {
+	// Histogram of Write call durations
+	writeLatency writeLatencyHistogram
+	// Number of Write calls
+	writes atomic.Uint64
+	// Number of failed Write calls
+	writeErrors atomic.Uint64
+}
+
+// # Description
+//
+// Return the Write call duration at the provided percentile (0 - 100). The returned duration has
+// a relative error below 1/16.
+//
+// # Returns
+//
+// The duration at the provided percentile or 0 if no Write call has been recorded.
+func (stats *ConnectionStats) WriteLatencyPercentile(p float64) time.Duration {
+	return stats.writeLatency.percentile(p)
+}
+
+// # Description
+//
+// Return the number of Write calls, including the failed ones.
+func (stats *ConnectionStats) WriteCount() uint64 {
+	return stats.writes.Load()
+}
+
+// # Description
+//
+// Return the number of failed Write calls.
+func (stats *ConnectionStats) WriteErrorCount() uint64 {
+	return stats.writeErrors.Load()
+}
+
+// StatsAdapter options.
+type StatsAdapterOptions struct {
+	// Slow Write threshold. A warning is logged and the wscengine.write.slow metric is incremented
+	// when the P99 write latency exceeds the threshold. The P99 write latency is checked every 64
+	// writes. Zero disables the check.
+	SlowWriteThreshold time.Duration
+	// Optional logger used to log slow writes. If nil, log.Default() is used.
+	Logger *log.Logger
+	// Optional meter provider used to emit metrics. If nil, the global meter provider is used.
+	MeterProvider metric.MeterProvider
+}
+
+// A decorator which collects statistics about the calls made to a
+// WebsocketConnectionAdapterInterface implementation.
+//
+// The decorator tracks the duration of Write calls: slow writes indicate back pressure caused by
+// the server (TCP send buffer full). The histogram used to track durations is lock-free so the
+// decorator adds negligible overhead to writes.
+type StatsAdapter struct {
+	// Decorated WebsocketConnectionAdapterInterface implementation
+	decorated WebsocketConnectionAdapterInterface
+	// Collected statistics
+	stats *ConnectionStats
+	// Slow write threshold
+	slowWriteThreshold time.Duration
+	// Logger used to log slow writes
+	logger *log.Logger
+	// Counter incremented when the P99 write latency exceeds the threshold
+	slowWrites metric.Int64Counter
+}
+
+// # Description
+//
+// Create a new decorator which collects statistics about the calls made to the provided
+// implementation of WebsocketConnectionAdapterInterface.
+//
+// # Returns
+//
+// The decorator or an error if decorated is nil or if the metric could not be created.
+func NewStatsAdapter(decorated WebsocketConnectionAdapterInterface, opts StatsAdapterOptions) (*StatsAdapter, error) {
+	// Return error if decorated is nil
+	if decorated == nil {
+		return nil, fmt.Errorf("provided decorated is nil")
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	if opts.MeterProvider == nil {
+		opts.MeterProvider = otel.GetMeterProvider()
+	}
+	slowWrites, err := opts.MeterProvider.
+		Meter(pkgName, metric.WithInstrumentationVersion(pkgVersion)).
+		Int64Counter(metricWriteSlow, metric.WithDescription("Number of times the P99 write latency exceeded the slow write threshold"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s metric: %w", metricWriteSlow, err)
+	}
+	return &StatsAdapter{
+		decorated:          decorated,
+		stats:              &ConnectionStats{},
+		slowWriteThreshold: opts.SlowWriteThreshold,
+		logger:             opts.Logger,
+		slowWrites:         slowWrites,
+	}, nil
+}
+
+// # Description
+//
+// Return the statistics collected by the decorator.
+func (adapter *StatsAdapter) Stats() *ConnectionStats {
+	return adapter.stats
+}
+
+// Simple proxy for Dial method.
+func (adapter *StatsAdapter) Dial(ctx context.Context, target url.URL) (*http.Response, error) {
+	return adapter.decorated.Dial(ctx, target)
+}
+
+// Simple proxy for Close method.
+func (adapter *StatsAdapter) Close(ctx context.Context, code StatusCode, reason string) error {
+	return adapter.decorated.Close(ctx, code, reason)
+}
+
+// Simple proxy for Drop method.
+func (adapter *StatsAdapter) Drop(ctx context.Context) error {
+	return adapter.decorated.Drop(ctx)
+}
+
+// Simple proxy for Ping method.
+func (adapter *StatsAdapter) Ping(ctx context.Context) error {
+	return adapter.decorated.Ping(ctx)
+}
+
+// Simple proxy for Read method.
+func (adapter *StatsAdapter) Read(ctx context.Context) (MessageType, []byte, error) {
+	return adapter.decorated.Read(ctx)
+}
+
+// Simple proxy for ReadStream method.
+func (adapter *StatsAdapter) ReadStream(ctx context.Context) (MessageType, io.Reader, error) {
+	return adapter.decorated.ReadStream(ctx)
+}
+
+// Decorate the Write method to record the duration of the call.
+func (adapter *StatsAdapter) Write(ctx context.Context, msgType MessageType, msg []byte) error {
+	start := time.Now()
+	err := adapter.decorated.Write(ctx, msgType, msg)
+	adapter.recordWrite(ctx, time.Since(start), err)
+	return err
+}
+
+// Proxy for WritePropagated method which records the same statistics as Write.
+func (adapter *StatsAdapter) WritePropagated(ctx context.Context, msgType MessageType, msg []byte, propagator propagation.TextMapPropagator) error {
+	start := time.Now()
+	err := adapter.decorated.WritePropagated(ctx, msgType, msg, propagator)
+	adapter.recordWrite(ctx, time.Since(start), err)
+	return err
+}
+
+// Simple proxy for WriteStream method.
+func (adapter *StatsAdapter) WriteStream(ctx context.Context, msgType MessageType) (io.WriteCloser, error) {
+	return adapter.decorated.WriteStream(ctx, msgType)
+}
+
+// Simple proxy for GetUnderlyingWebsocketConnection method.
+func (adapter *StatsAdapter) GetUnderlyingWebsocketConnection() any {
+	return adapter.decorated.GetUnderlyingWebsocketConnection()
+}
+
+// Simple proxy for NegotiatedSubprotocol method.
+func (adapter *StatsAdapter) NegotiatedSubprotocol() string {
+	return adapter.decorated.NegotiatedSubprotocol()
+}
+
+// Simple proxy for ReadStats method.
+func (adapter *StatsAdapter) ReadStats() AdapterReadStats {
+	return adapter.decorated.ReadStats()
+}
+
+// Record the latency and the outcome of a write and warn when the P99 write latency exceeds the
+// slow write threshold.
+func (adapter *StatsAdapter) recordWrite(ctx context.Context, latency time.Duration, err error) {
+	adapter.stats.writeLatency.record(latency)
+	if err != nil {
+		adapter.stats.writeErrors.Add(1)
+	}
+	if adapter.stats.writes.Add(1)%slowWriteCheckPeriod == 0 && adapter.slowWriteThreshold > 0 {
+		if p99 := adapter.stats.WriteLatencyPercentile(99); p99 > adapter.slowWriteThreshold {
+			adapter.logger.Printf("slow websocket writes: P99 write latency %s exceeds threshold %s", p99, adapter.slowWriteThreshold)
+			adapter.slowWrites.Add(ctx, 1)
+		}
+	}
+}
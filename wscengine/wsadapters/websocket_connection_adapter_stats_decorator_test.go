@@ -0,0 +1,46 @@
+package wsadapters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Test StatsAdapter implements the interface it decorates.
+func TestStatsAdapterInterfaceCompliance(t *testing.T) {
+	var instance any = new(StatsAdapter)
+	_, ok := instance.(WebsocketConnectionAdapterInterface)
+	require.True(t, ok)
+	_, err := NewStatsAdapter(nil, StatsAdapterOptions{})
+	require.Error(t, err)
+}
+
+// Test Write durations are recorded and slow writes are logged.
+func TestStatsAdapterWrite(t *testing.T) {
+	decorated := NewWebsocketConnectionAdapterInterfaceMock()
+	decorated.On("Write", mock.Anything, Text, []byte("ok")).Run(func(args mock.Arguments) {
+		time.Sleep(time.Millisecond)
+	}).Return(nil)
+	decorated.On("Write", mock.Anything, Text, []byte("ko")).Return(fmt.Errorf("fail"))
+	buf := &bytes.Buffer{}
+	adapter, err := NewStatsAdapter(decorated, StatsAdapterOptions{
+		SlowWriteThreshold: 100 * time.Microsecond,
+		Logger:             log.New(buf, "", 0),
+	})
+	require.NoError(t, err)
+	require.Error(t, adapter.Write(context.Background(), Text, []byte("ko")))
+	for i := 1; i < slowWriteCheckPeriod; i++ {
+		require.NoError(t, adapter.Write(context.Background(), Text, []byte("ok")))
+	}
+	stats := adapter.Stats()
+	require.Equal(t, uint64(slowWriteCheckPeriod), stats.WriteCount())
+	require.Equal(t, uint64(1), stats.WriteErrorCount())
+	require.GreaterOrEqual(t, stats.WriteLatencyPercentile(99), time.Millisecond)
+	require.Contains(t, buf.String(), "slow websocket writes")
+}
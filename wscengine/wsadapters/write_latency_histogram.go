@@ -0,0 +1,88 @@
+package wsadapters
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Number of bits used for sub-buckets: values are recorded with a relative error below 1/16.
+const histogramSubBucketBits = 4
+
+// Number of sub-buckets per power of two.
+const histogramSubBuckets = 1 << histogramSubBucketBits
+
+// Number of buckets required to cover all int64 durations.
+const histogramBuckets = (64 - histogramSubBucketBits + 1) * histogramSubBuckets
+
+// Lock-free, HDR-like histogram of durations. Durations are recorded in log-linear buckets: each
+// power of two is divided in histogramSubBuckets linear sub-buckets. Buckets are atomic counters
+// so recording never blocks.
+type writeLatencyHistogram struct {
+	// Counters by bucket
+	counts [histogramBuckets]atomic.Uint64
+	// Total number of recorded durations
+	total atomic.Uint64
+}
+
+// Record a duration. Negative durations are recorded as 0.
+func (histogram *writeLatencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	histogram.counts[bucketIndex(uint64(d))].Add(1)
+	histogram.total.Add(1)
+}
+
+// Return the duration at the provided percentile (0 - 100) or 0 if no duration has been recorded.
+// The returned duration is the upper bound of the bucket which contains the percentile.
+func (histogram *writeLatencyHistogram) percentile(p float64) time.Duration {
+	total := histogram.total.Load()
+	if total == 0 {
+		return 0
+	}
+	p = math.Max(0, math.Min(100, p))
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	seen := uint64(0)
+	for index := range histogram.counts {
+		seen = seen + histogram.counts[index].Load()
+		if seen >= rank {
+			return time.Duration(bucketUpperBound(index))
+		}
+	}
+	// Counters have been updated concurrently: use the highest non-empty bucket
+	for index := len(histogram.counts) - 1; index >= 0; index-- {
+		if histogram.counts[index].Load() > 0 {
+			return time.Duration(bucketUpperBound(index))
+		}
+	}
+	return 0
+}
+
+// Return the index of the bucket which contains the provided value.
+func bucketIndex(value uint64) int {
+	if value < histogramSubBuckets {
+		return int(value)
+	}
+	exp := bits.Len64(value) - histogramSubBucketBits - 1
+	return (exp+1)*histogramSubBuckets + int((value>>exp)&(histogramSubBuckets-1))
+}
+
+// Return the highest value contained by the bucket with the provided index.
+func bucketUpperBound(index int) uint64 {
+	if index < histogramSubBuckets {
+		return uint64(index)
+	}
+	exp := index/histogramSubBuckets - 1
+	sub := uint64(index % histogramSubBuckets)
+	lower := (histogramSubBuckets + sub) << exp
+	upper := lower + (uint64(1) << exp) - 1
+	if upper > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return upper
+}
@@ -0,0 +1,33 @@
+package wsadapters
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test bucket index and upper bound are consistent and keep relative error below 1/16.
+func TestHistogramBuckets(t *testing.T) {
+	for _, value := range []uint64{0, 1, 15, 16, 17, 31, 32, 1000, 123456789, math.MaxInt64} {
+		upper := bucketUpperBound(bucketIndex(value))
+		require.GreaterOrEqual(t, upper, value)
+		require.LessOrEqual(t, float64(upper-value), float64(value)/histogramSubBuckets)
+	}
+	require.Less(t, bucketIndex(math.MaxUint64), histogramBuckets)
+}
+
+// Test percentiles.
+func TestHistogramPercentile(t *testing.T) {
+	histogram := &writeLatencyHistogram{}
+	require.Zero(t, histogram.percentile(99))
+	for i := 1; i <= 100; i++ {
+		histogram.record(time.Duration(i) * time.Millisecond)
+	}
+	histogram.record(-time.Second)
+	require.InEpsilon(t, float64(50*time.Millisecond), float64(histogram.percentile(50)), 1.0/16)
+	require.InEpsilon(t, float64(95*time.Millisecond), float64(histogram.percentile(95)), 1.0/16)
+	require.InEpsilon(t, float64(100*time.Millisecond), float64(histogram.percentile(100)), 1.0/16)
+	require.Zero(t, histogram.percentile(0))
+}
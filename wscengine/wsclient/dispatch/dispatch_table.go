@@ -0,0 +1,127 @@
+// The package provides a dispatch table which routes received messages to handlers based on a
+// type value extracted from the message. The dispatch table replaces the switch statement commonly
+// found in OnMessage callbacks.
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Error returned when no handler is registered for the message type and no default handler is
+// configured.
+var ErrNoHandlerRegistered = errors.New("no handler registered for message type")
+
+// Function which handles a message routed by the dispatch table.
+type Handler func(ctx context.Context, msg []byte) error
+
+// Function which extracts the type value used to route a message. An empty string can be returned
+// when the type cannot be extracted: the message is then handled by the default handler.
+type TypeExtractor func(msg []byte) string
+
+// Dispatch table which routes messages to handlers registered for their type.
+//
+// The dispatch table is safe for concurrent use: handlers can be registered while messages are
+// dispatched by engine goroutines.
+type DispatchTable struct {
+	// Function used to extract message types
+	extractor TypeExtractor
+	// Optional handler called for messages with no registered handler
+	defaultHandler Handler
+	// Mutex used to protect handlers
+	mu sync.RWMutex
+	// Handlers by type value
+	handlers map[string]Handler
+}
+
+// # Description
+//
+// Factory which creates a new, empty DispatchTable.
+//
+// # Inputs
+//
+//   - extractor: Function used to extract the type value of messages. Required.
+//   - defaultHandler: Optional handler called for messages which have no registered handler. If
+//     nil, OnMessage returns ErrNoHandlerRegistered for such messages.
+//
+// # Returns
+//
+// A new DispatchTable or an error if extractor is nil.
+func NewDispatchTable(extractor TypeExtractor, defaultHandler Handler) (*DispatchTable, error) {
+	if extractor == nil {
+		return nil, fmt.Errorf("provided type extractor is nil")
+	}
+	return &DispatchTable{
+		extractor:      extractor,
+		defaultHandler: defaultHandler,
+		handlers:       map[string]Handler{},
+	}, nil
+}
+
+// # Description
+//
+// Register the handler for the provided type value. A handler already registered for the type
+// value is replaced. Providing a nil handler removes the handler registered for the type value.
+func (table *DispatchTable) Register(typeValue string, handler func(ctx context.Context, msg []byte) error) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	if handler == nil {
+		delete(table.handlers, typeValue)
+		return
+	}
+	table.handlers[typeValue] = handler
+}
+
+// # Description
+//
+// Extract the type value of the message and call the matching handler, or the default handler if
+// no handler is registered for the type value. The method is meant to be called from the OnMessage
+// callback of a websocket client.
+//
+// # Returns
+//
+// The error returned by the handler or ErrNoHandlerRegistered if no handler is registered for the
+// type value and no default handler is configured.
+func (table *DispatchTable) OnMessage(ctx context.Context, msg []byte) error {
+	typeValue := table.extractor(msg)
+	table.mu.RLock()
+	handler, ok := table.handlers[typeValue]
+	table.mu.RUnlock()
+	if ok {
+		return handler(ctx, msg)
+	}
+	if table.defaultHandler != nil {
+		return table.defaultHandler(ctx, msg)
+	}
+	return fmt.Errorf("%w: %q", ErrNoHandlerRegistered, typeValue)
+}
+
+// # Description
+//
+// Build a TypeExtractor which extracts the value of a top-level field from JSON objects. String
+// values are returned unquoted. Other values (numbers, booleans) are returned as their JSON text.
+//
+// # Returns
+//
+// A TypeExtractor which returns an empty string if the message is not a JSON object or if the
+// field is missing or null.
+func JSONFieldExtractor(fieldName string) TypeExtractor {
+	return func(msg []byte) string {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(msg, &fields); err != nil {
+			return ""
+		}
+		raw, ok := fields[fieldName]
+		if !ok || string(raw) == "null" {
+			return ""
+		}
+		value := ""
+		if err := json.Unmarshal(raw, &value); err == nil {
+			return value
+		}
+		return string(raw)
+	}
+}
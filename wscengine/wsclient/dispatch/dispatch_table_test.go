@@ -0,0 +1,82 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for DispatchTable unit tests
+type DispatchTableUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run DispatchTableUnitTestSuite test suite
+func TestDispatchTableUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DispatchTableUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test factory fails when extractor is nil.
+func (suite *DispatchTableUnitTestSuite) TestFactoryWithNilExtractor() {
+	_, err := NewDispatchTable(nil, nil)
+	require.Error(suite.T(), err)
+}
+
+// Test messages are routed to registered handlers.
+func (suite *DispatchTableUnitTestSuite) TestOnMessage() {
+	table, err := NewDispatchTable(JSONFieldExtractor("event"), nil)
+	require.NoError(suite.T(), err)
+	received := []string{}
+	table.Register("trade", func(ctx context.Context, msg []byte) error {
+		received = append(received, "trade:"+string(msg))
+		return nil
+	})
+	table.Register("book", func(ctx context.Context, msg []byte) error {
+		return errors.New("boom")
+	})
+	require.NoError(suite.T(), table.OnMessage(context.Background(), []byte(`{"event":"trade"}`)))
+	require.Equal(suite.T(), []string{`trade:{"event":"trade"}`}, received)
+	require.EqualError(suite.T(), table.OnMessage(context.Background(), []byte(`{"event":"book"}`)), "boom")
+	// Unhandled type without default handler
+	err = table.OnMessage(context.Background(), []byte(`{"event":"unknown"}`))
+	require.ErrorIs(suite.T(), err, ErrNoHandlerRegistered)
+	// Removing handler
+	table.Register("trade", nil)
+	err = table.OnMessage(context.Background(), []byte(`{"event":"trade"}`))
+	require.ErrorIs(suite.T(), err, ErrNoHandlerRegistered)
+}
+
+// Test unhandled messages are routed to the default handler.
+func (suite *DispatchTableUnitTestSuite) TestDefaultHandler() {
+	called := 0
+	table, err := NewDispatchTable(JSONFieldExtractor("event"), func(ctx context.Context, msg []byte) error {
+		called++
+		return nil
+	})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), table.OnMessage(context.Background(), []byte(`{"event":"unknown"}`)))
+	require.NoError(suite.T(), table.OnMessage(context.Background(), []byte(`not json`)))
+	require.Equal(suite.T(), 2, called)
+}
+
+// Test JSONFieldExtractor.
+func (suite *DispatchTableUnitTestSuite) TestJSONFieldExtractor() {
+	extractor := JSONFieldExtractor("type")
+	require.Equal(suite.T(), "trade", extractor([]byte(`{"type":"trade","data":[]}`)))
+	require.Equal(suite.T(), "42", extractor([]byte(`{"type":42}`)))
+	require.Equal(suite.T(), "", extractor([]byte(`{"type":null}`)))
+	require.Equal(suite.T(), "", extractor([]byte(`{"other":"trade"}`)))
+	require.Equal(suite.T(), "", extractor([]byte(`[1,2,3]`)))
+	require.Equal(suite.T(), "", extractor([]byte(`not json`)))
+}
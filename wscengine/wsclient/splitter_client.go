@@ -0,0 +1,147 @@
+package wsclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Error which aggregates the errors returned by several callbacks.
+type MultiError struct {
+	// Aggregated errors, in callback order
+	Errors []error
+}
+
+func (err MultiError) Error() string {
+	msgs := make([]string, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (err MultiError) Unwrap() []error {
+	return err.Errors
+}
+
+// WebsocketClientInterface implementation which fans out all callbacks to several clients, for
+// example to a logging client and to the real client.
+//
+// Clients are called sequentially, in the order they have been provided. The splitter holds no
+// mutable state so it can safely be called concurrently by engine goroutines: each client must be
+// safe for concurrent OnMessage calls as if it was used directly by the engine.
+type SplitterClient struct {
+	// Clients callbacks are fanned out to
+	clients []WebsocketClientInterface
+}
+
+// # Description
+//
+// Factory which creates a new SplitterClient which fans out callbacks to the provided clients.
+// Nil clients are skipped.
+func NewSplitterClient(clients ...WebsocketClientInterface) *SplitterClient {
+	filtered := make([]WebsocketClientInterface, 0, len(clients))
+	for _, client := range clients {
+		if client != nil {
+			filtered = append(filtered, client)
+		}
+	}
+	return &SplitterClient{clients: filtered}
+}
+
+// # Description
+//
+// Call OnOpen on all clients, even if some of them fail.
+//
+// # Returns
+//
+// Nil if all clients succeeded or a MultiError which contains the errors returned by clients.
+func (splitter *SplitterClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	errs := []error{}
+	for _, client := range splitter.clients {
+		err := client.OnOpen(ctx, resp, conn, readMutex, exit, sessionId, restarting)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// Call OnMessage on all clients with the same message.
+func (splitter *SplitterClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	for _, client := range splitter.clients {
+		client.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	}
+}
+
+// Call OnReadError on all clients.
+func (splitter *SplitterClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+	for _, client := range splitter.clients {
+		client.OnReadError(ctx, conn, readMutex, restart, exit, sessionId, err)
+	}
+}
+
+// # Description
+//
+// Call OnClose on all clients.
+//
+// # Returns
+//
+// The first non-nil close message details returned by clients or nil if all clients returned nil.
+func (splitter *SplitterClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *CloseMessageDetails) *CloseMessageDetails {
+	var result *CloseMessageDetails
+	for _, client := range splitter.clients {
+		details := client.OnClose(ctx, conn, readMutex, sessionId, closeMessage)
+		if result == nil {
+			result = details
+		}
+	}
+	return result
+}
+
+// Call OnCloseError on all clients.
+func (splitter *SplitterClient) OnCloseError(ctx context.Context, sessionId string, err error) {
+	for _, client := range splitter.clients {
+		client.OnCloseError(ctx, sessionId, err)
+	}
+}
+
+// Call OnRestartError on all clients.
+func (splitter *SplitterClient) OnRestartError(ctx context.Context, exit context.CancelFunc, sessionId string, err error, retryCount int) {
+	for _, client := range splitter.clients {
+		client.OnRestartError(ctx, exit, sessionId, err, retryCount)
+	}
+}
@@ -0,0 +1,108 @@
+package wsclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for SplitterClient unit tests
+type SplitterClientUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run SplitterClientUnitTestSuite test suite
+func TestSplitterClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SplitterClientUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Check splitter fully implements interface.
+func (suite *SplitterClientUnitTestSuite) TestInterfaceCompliance() {
+	var impl interface{} = NewSplitterClient()
+	_, ok := impl.(WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test all callbacks are fanned out to all clients.
+func (suite *SplitterClientUnitTestSuite) TestFanOut() {
+	first, second := NewWebsocketClientMock(), NewWebsocketClientMock()
+	splitter := NewSplitterClient(first, nil, second)
+	ctx := context.Background()
+	readMutex := &sync.Mutex{}
+	readErr := errors.New("read failed")
+	closeMessage := &CloseMessageDetails{CloseReason: wsadapters.GoingAway, CloseMessage: "bye"}
+	secondClose := &CloseMessageDetails{CloseReason: wsadapters.NormalClosure, CloseMessage: "second"}
+	for _, client := range []*WebsocketClientMock{first, second} {
+		client.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, true).Return(nil)
+		client.On("OnMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, "session", wsadapters.Text, []byte("hello"))
+		client.On("OnReadError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, readErr)
+		client.On("OnCloseError", mock.Anything, mock.Anything, readErr)
+		client.On("OnRestartError", mock.Anything, mock.Anything, mock.Anything, readErr, 2)
+	}
+	first.On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, closeMessage).Return(nil)
+	second.On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, closeMessage).Return(secondClose)
+	require.NoError(suite.T(), splitter.OnOpen(ctx, nil, nil, readMutex, nil, "s1", true))
+	// Concurrent OnMessage calls
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			splitter.OnMessage(ctx, nil, readMutex, nil, nil, "session", wsadapters.Text, []byte("hello"))
+		}()
+	}
+	wg.Wait()
+	splitter.OnReadError(ctx, nil, readMutex, nil, nil, "s1", readErr)
+	require.Equal(suite.T(), secondClose, splitter.OnClose(ctx, nil, readMutex, "s1", closeMessage))
+	splitter.OnCloseError(ctx, "s1", readErr)
+	splitter.OnRestartError(ctx, nil, "s1", readErr, 2)
+	for _, client := range []*WebsocketClientMock{first, second} {
+		client.AssertNumberOfCalls(suite.T(), "OnOpen", 1)
+		client.AssertNumberOfCalls(suite.T(), "OnMessage", 10)
+		client.AssertNumberOfCalls(suite.T(), "OnReadError", 1)
+		client.AssertNumberOfCalls(suite.T(), "OnClose", 1)
+		client.AssertNumberOfCalls(suite.T(), "OnCloseError", 1)
+		client.AssertNumberOfCalls(suite.T(), "OnRestartError", 1)
+	}
+}
+
+// Test OnOpen errors are aggregated and OnClose prefers the first non-nil result.
+func (suite *SplitterClientUnitTestSuite) TestErrorsAndCloseAggregation() {
+	first, second, third := NewWebsocketClientMock(), NewWebsocketClientMock(), NewWebsocketClientMock()
+	firstErr, thirdErr := errors.New("first failed"), errors.New("third failed")
+	first.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).Return(firstErr)
+	second.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).Return(nil)
+	third.On("OnOpen", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, false).Return(thirdErr)
+	firstClose := &CloseMessageDetails{CloseReason: wsadapters.GoingAway}
+	thirdClose := &CloseMessageDetails{CloseReason: wsadapters.NormalClosure}
+	first.On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(firstClose)
+	second.On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	third.On("OnClose", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(thirdClose)
+	splitter := NewSplitterClient(first, second, third)
+	// All clients are called and errors are aggregated
+	err := splitter.OnOpen(context.Background(), nil, nil, nil, nil, "s1", false)
+	multiErr := MultiError{}
+	require.ErrorAs(suite.T(), err, &multiErr)
+	require.Equal(suite.T(), []error{firstErr, thirdErr}, multiErr.Errors)
+	require.ErrorIs(suite.T(), err, thirdErr)
+	require.Equal(suite.T(), "first failed; third failed", err.Error())
+	third.AssertNumberOfCalls(suite.T(), "OnOpen", 1)
+	// First non-nil close message details is returned
+	require.Same(suite.T(), firstClose, splitter.OnClose(context.Background(), nil, nil, "s1", nil))
+	// No client
+	require.Nil(suite.T(), NewSplitterClient().OnClose(context.Background(), nil, nil, "s1", nil))
+}
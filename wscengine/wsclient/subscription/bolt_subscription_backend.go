@@ -0,0 +1,83 @@
+package subscription
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// Bucket which contains the subscriptions
+	boltBucket = []byte("subscriptions")
+	// Key under which the JSON encoded subscriptions are stored
+	boltKey = []byte("subscriptions")
+)
+
+// PersistentSubscriptionBackend implementation which persists subscriptions in a BoltDB file.
+//
+// Subscriptions are written in a single transaction so a crash while saving never leaves
+// partially written subscriptions.
+type BoltDBSubscriptionBackend struct {
+	// BoltDB database
+	db *bolt.DB
+}
+
+// # Description
+//
+// Factory which creates a new BoltDBSubscriptionBackend which persists subscriptions in the
+// provided BoltDB file. The file is created if it does not exist. Call Close to release the file.
+//
+// # Returns
+//
+// A new BoltDBSubscriptionBackend or an error if the file could not be opened within 1 second (for
+// example because it is locked by another process).
+func NewBoltDBSubscriptionBackend(path string) (*BoltDBSubscriptionBackend, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open subscription database: %w", err)
+	}
+	return &BoltDBSubscriptionBackend{db: db}, nil
+}
+
+// Persist the subscriptions in the database.
+func (backend *BoltDBSubscriptionBackend) Save(subs []Subscription) error {
+	content, err := json.Marshal(subs)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscriptions: %w", err)
+	}
+	return backend.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return fmt.Errorf("failed to create subscription bucket: %w", err)
+		}
+		return bucket.Put(boltKey, content)
+	})
+}
+
+// Load the subscriptions from the database. An empty slice is returned if no subscriptions have
+// been persisted.
+func (backend *BoltDBSubscriptionBackend) Load() ([]Subscription, error) {
+	subs := []Subscription{}
+	err := backend.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if bucket == nil {
+			return nil
+		}
+		content := bucket.Get(boltKey)
+		if content == nil {
+			return nil
+		}
+		return json.Unmarshal(content, &subs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// Close the database.
+func (backend *BoltDBSubscriptionBackend) Close() error {
+	return backend.db.Close()
+}
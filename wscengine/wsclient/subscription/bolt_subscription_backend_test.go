@@ -0,0 +1,59 @@
+package subscription
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for BoltDBSubscriptionBackend unit tests
+type BoltDBSubscriptionBackendUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run BoltDBSubscriptionBackendUnitTestSuite test suite
+func TestBoltDBSubscriptionBackendUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(BoltDBSubscriptionBackendUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test subscriptions survive a database reopen.
+func (suite *BoltDBSubscriptionBackendUnitTestSuite) TestSaveAndLoad() {
+	path := filepath.Join(suite.T().TempDir(), "subscriptions.db")
+	backend, err := NewBoltDBSubscriptionBackend(path)
+	require.NoError(suite.T(), err)
+	// Nothing persisted yet
+	subs, err := backend.Load()
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), subs)
+	// Save and reopen
+	expected := []Subscription{{Topic: "a", Params: map[string]string{"depth": "10"}}, {Topic: "b"}}
+	require.NoError(suite.T(), backend.Save(expected))
+	require.NoError(suite.T(), backend.Close())
+	backend, err = NewBoltDBSubscriptionBackend(path)
+	require.NoError(suite.T(), err)
+	defer backend.Close()
+	subs, err = backend.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), expected, subs)
+	// Saved subscriptions replace previous ones
+	require.NoError(suite.T(), backend.Save([]Subscription{}))
+	subs, err = backend.Load()
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), subs)
+}
+
+// Test factory fails when database file cannot be opened.
+func (suite *BoltDBSubscriptionBackendUnitTestSuite) TestOpenFailure() {
+	_, err := NewBoltDBSubscriptionBackend(filepath.Join(suite.T().TempDir(), "missing", "subscriptions.db"))
+	require.Error(suite.T(), err)
+}
@@ -0,0 +1,35 @@
+package subscription
+
+import "sync"
+
+// PersistentSubscriptionBackend implementation which keeps subscriptions in memory. Subscriptions
+// do not survive a crash: the backend is meant for tests and for applications which only need
+// subscriptions to be renewed on reconnect.
+type InMemorySubscriptionBackend struct {
+	// Mutex used to protect subs
+	mu sync.Mutex
+	// Saved subscriptions
+	subs []Subscription
+}
+
+// # Description
+//
+// Factory which creates a new, empty InMemorySubscriptionBackend.
+func NewInMemorySubscriptionBackend() *InMemorySubscriptionBackend {
+	return &InMemorySubscriptionBackend{}
+}
+
+// Save a copy of the subscriptions.
+func (backend *InMemorySubscriptionBackend) Save(subs []Subscription) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.subs = append([]Subscription(nil), subs...)
+	return nil
+}
+
+// Return a copy of the saved subscriptions.
+func (backend *InMemorySubscriptionBackend) Load() ([]Subscription, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return append([]Subscription(nil), backend.subs...), nil
+}
@@ -0,0 +1,157 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// Error used when the server has reported a subscribe failure.
+var ErrSubscribeFailed = errors.New("server reported a subscribe failure")
+
+// Identifier of a subscription: the subscription topic.
+type SubscriptionID string
+
+// # Description
+//
+// Return the identifier of the subscription.
+func (sub Subscription) ID() SubscriptionID {
+	return SubscriptionID(sub.Topic)
+}
+
+// Function which returns true if the provided message is an error message sent by the server
+// because the subscribe request of the provided subscription has failed (for example
+// {"type":"error","msg":"subscription_failed","topic":"..."}).
+type SubscribeErrorDetector func(msg []byte, subID SubscriptionID) bool
+
+// Policy used to retry a failed subscribe request with an exponential backoff.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one. Values lower than 2 disable retries.
+	MaxAttempts int
+	// Delay before the first retry. Defaults to 100ms if 0 or less.
+	InitialDelay time.Duration
+	// Maximum delay between two attempts. If 0 or less, the delay is not capped.
+	MaxDelay time.Duration
+	// Factor applied to the delay after each retry. Defaults to 2 if lower than 1.
+	Multiplier float64
+}
+
+// Return the delay to wait before the provided retry (starting at 1).
+func (policy RetryPolicy) delay(retry int) time.Duration {
+	initial := policy.InitialDelay
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(initial) * math.Pow(multiplier, float64(retry-1))
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		return policy.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// Retry state of a subscription. A new state is created each time the subscription is renewed so
+// retries scheduled for a previous state are discarded.
+type retryState struct {
+	// Number of failed attempts
+	failures int
+}
+
+// # Description
+//
+// Method meant to be called from the OnMessage callback of a websocket client to detect subscribe
+// failures reported by the server with SubscribeErrorDetector.
+//
+// When a failure is detected for an active subscription, the subscribe request is sent again
+// after a delay computed with SubscribeRetryPolicy. Once all attempts have failed, the
+// subscription is removed from the active subscriptions and OnSubscribeError is called.
+//
+// # Returns
+//
+// True if the message has been detected as a subscribe failure. False if it is not or if
+// SubscribeErrorDetector is nil.
+func (manager *SubscriptionManager) HandleMessage(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, msg []byte) bool {
+	if manager.SubscribeErrorDetector == nil {
+		return false
+	}
+	manager.mu.Lock()
+	detected := false
+	callbacks := []func(){}
+	for _, sub := range append([]Subscription(nil), manager.subs...) {
+		if !manager.SubscribeErrorDetector(msg, sub.ID()) {
+			continue
+		}
+		detected = true
+		state := manager.retries[sub.ID()]
+		if state == nil {
+			state = &retryState{}
+			manager.retries[sub.ID()] = state
+		}
+		callbacks = append(callbacks, manager.onSubscribeFailure(ctx, conn, sub, state, fmt.Errorf("%w: %s", ErrSubscribeFailed, msg)))
+	}
+	manager.mu.Unlock()
+	for _, callback := range callbacks {
+		callback()
+	}
+	return detected
+}
+
+// Record a failed attempt and either schedule a retry or drop the subscription. Must be called
+// while holding the manager mutex. The returned function must be called once the mutex has been
+// released.
+func (manager *SubscriptionManager) onSubscribeFailure(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	sub Subscription,
+	state *retryState,
+	err error) func() {
+	state.failures++
+	if state.failures < manager.SubscribeRetryPolicy.MaxAttempts {
+		time.AfterFunc(manager.SubscribeRetryPolicy.delay(state.failures), func() {
+			manager.retrySubscribe(ctx, conn, sub, state)
+		})
+		return func() {}
+	}
+	// All attempts have failed - drop the subscription
+	delete(manager.retries, sub.ID())
+	if index := manager.indexOf(sub.Topic); index >= 0 {
+		manager.subs = append(manager.subs[:index:index], manager.subs[index+1:]...)
+	}
+	if saveErr := manager.save(); saveErr != nil {
+		err = errors.Join(err, saveErr)
+	}
+	err = fmt.Errorf("failed to subscribe to %s after %d attempts: %w", sub.Topic, state.failures, err)
+	return func() {
+		if manager.OnSubscribeError != nil {
+			manager.OnSubscribeError(ctx, sub.ID(), err)
+		}
+	}
+}
+
+// Send the subscribe request again unless the subscription has been renewed or removed meanwhile
+// or the context is done.
+func (manager *SubscriptionManager) retrySubscribe(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	sub Subscription,
+	state *retryState) {
+	manager.mu.Lock()
+	if manager.retries[sub.ID()] != state || ctx.Err() != nil {
+		manager.mu.Unlock()
+		return
+	}
+	callback := func() {}
+	err := manager.subscribe(ctx, conn, sub)
+	if err != nil {
+		callback = manager.onSubscribeFailure(ctx, conn, sub, state, err)
+	}
+	manager.mu.Unlock()
+	callback()
+}
@@ -0,0 +1,224 @@
+// The package provides a subscription manager which keeps track of the active subscriptions,
+// persists them with a pluggable backend and renews them when the websocket engine reconnects.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+)
+
+// A subscription to a topic/channel of the websocket server.
+type Subscription struct {
+	// Topic or channel name. Identifies the subscription.
+	Topic string `json:"topic"`
+	// Optional, protocol specific parameters used to build the subscribe request.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Interface for backends which persist the active subscriptions so they survive a crash.
+type PersistentSubscriptionBackend interface {
+	// # Description
+	//
+	// Persist the provided subscriptions. The persisted subscriptions replace the previous ones.
+	//
+	// # Returns
+	//
+	// Nil in case of success or an error if the subscriptions could not be persisted.
+	Save(subs []Subscription) error
+	// # Description
+	//
+	// Load the persisted subscriptions.
+	//
+	// # Returns
+	//
+	// The persisted subscriptions (empty if none has been persisted) or an error.
+	Load() ([]Subscription, error)
+}
+
+// Function which sends the protocol specific subscribe or unsubscribe request for the provided
+// subscription to the server.
+type RequestFunc func(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, sub Subscription) error
+
+// Subscription manager which persists the active subscriptions with a PersistentSubscriptionBackend
+// and subscribes again to all persisted subscriptions when the engine reconnects.
+//
+// Subscribe, Unsubscribe and OnOpen are serialized: the manager holds its mutex while requests
+// are sent to the server.
+//
+// Subscribe failures reported asynchronously by the server can be detected and retried by
+// setting SubscribeErrorDetector and calling HandleMessage from OnMessage. Exported fields must be
+// set before the manager is used.
+type SubscriptionManager struct {
+	// Policy used to retry subscribe requests reported as failed by the server. Retries are
+	// disabled by default.
+	SubscribeRetryPolicy RetryPolicy
+	// Function used by HandleMessage to detect subscribe failures. If nil, failures are not
+	// detected.
+	SubscribeErrorDetector SubscribeErrorDetector
+	// Optional function called once all attempts to subscribe have failed. The subscription has
+	// been removed from the active subscriptions when the function is called.
+	OnSubscribeError func(ctx context.Context, subID SubscriptionID, err error)
+	// Backend used to persist subscriptions
+	backend PersistentSubscriptionBackend
+	// Function used to send subscribe requests
+	subscribe RequestFunc
+	// Function used to send unsubscribe requests
+	unsubscribe RequestFunc
+	// Mutex used to protect subs
+	mu sync.Mutex
+	// Active subscriptions, in subscription order
+	subs []Subscription
+	// Retry state of the subscriptions which have failed
+	retries map[SubscriptionID]*retryState
+}
+
+// # Description
+//
+// Factory which creates a new SubscriptionManager and loads the subscriptions persisted in the
+// provided backend.
+//
+// # Inputs
+//
+//   - backend: Backend used to persist subscriptions. Required.
+//   - subscribe: Function used to send subscribe requests. Required.
+//   - unsubscribe: Function used to send unsubscribe requests. Required.
+//
+// # Returns
+//
+// A new SubscriptionManager or an error if an input is nil or if the persisted subscriptions
+// could not be loaded.
+func NewSubscriptionManager(
+	backend PersistentSubscriptionBackend,
+	subscribe RequestFunc,
+	unsubscribe RequestFunc) (*SubscriptionManager, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("provided backend is nil")
+	}
+	if subscribe == nil || unsubscribe == nil {
+		return nil, fmt.Errorf("subscribe and unsubscribe functions are required")
+	}
+	subs, err := backend.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load persisted subscriptions: %w", err)
+	}
+	return &SubscriptionManager{
+		backend:     backend,
+		subscribe:   subscribe,
+		unsubscribe: unsubscribe,
+		subs:        subs,
+		retries:     map[SubscriptionID]*retryState{},
+	}, nil
+}
+
+// # Description
+//
+// Send the subscribe request for the provided subscription and persist the active subscriptions.
+//
+// # Returns
+//
+// Nil in case of success or an error if there is already a subscription to the topic, if the
+// request could not be sent or if the subscriptions could not be persisted. In the latter case,
+// the subscription is active but will not be renewed after a crash.
+func (manager *SubscriptionManager) Subscribe(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, sub Subscription) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if manager.indexOf(sub.Topic) >= 0 {
+		return fmt.Errorf("already subscribed to %s", sub.Topic)
+	}
+	err := manager.subscribe(ctx, conn, sub)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", sub.Topic, err)
+	}
+	manager.subs = append(manager.subs, sub)
+	delete(manager.retries, sub.ID())
+	return manager.save()
+}
+
+// # Description
+//
+// Send the unsubscribe request for the subscription to the provided topic and persist the active
+// subscriptions.
+//
+// # Returns
+//
+// Nil in case of success or an error if there is no subscription to the topic, if the request
+// could not be sent or if the subscriptions could not be persisted.
+func (manager *SubscriptionManager) Unsubscribe(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, topic string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	index := manager.indexOf(topic)
+	if index < 0 {
+		return fmt.Errorf("not subscribed to %s", topic)
+	}
+	err := manager.unsubscribe(ctx, conn, manager.subs[index])
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from %s: %w", topic, err)
+	}
+	manager.subs = append(manager.subs[:index:index], manager.subs[index+1:]...)
+	delete(manager.retries, SubscriptionID(topic))
+	return manager.save()
+}
+
+// # Description
+//
+// Return a copy of the active subscriptions, in subscription order.
+func (manager *SubscriptionManager) Subscriptions() []Subscription {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return append([]Subscription(nil), manager.subs...)
+}
+
+// # Description
+//
+// Method meant to be called from the OnOpen callback of a websocket client. When the engine
+// restarts, the persisted subscriptions are reloaded and a subscribe request is sent for each of
+// them. Nothing is done when the engine starts.
+//
+// # Returns
+//
+// Nil in case of success or an error if the subscriptions could not be loaded or if requests
+// failed. Returning the error from OnOpen makes the engine restart again.
+func (manager *SubscriptionManager) OnOpen(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, restarting bool) error {
+	if !restarting {
+		return nil
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	subs, err := manager.backend.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load persisted subscriptions: %w", err)
+	}
+	manager.subs = subs
+	manager.retries = map[SubscriptionID]*retryState{}
+	errs := []error{}
+	for _, sub := range subs {
+		err := manager.subscribe(ctx, conn, sub)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to subscribe to %s: %w", sub.Topic, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Return the index of the subscription to the provided topic or -1.
+func (manager *SubscriptionManager) indexOf(topic string) int {
+	for i, sub := range manager.subs {
+		if sub.Topic == topic {
+			return i
+		}
+	}
+	return -1
+}
+
+// Persist the active subscriptions.
+func (manager *SubscriptionManager) save() error {
+	err := manager.backend.Save(append([]Subscription(nil), manager.subs...))
+	if err != nil {
+		return fmt.Errorf("failed to persist subscriptions: %w", err)
+	}
+	return nil
+}
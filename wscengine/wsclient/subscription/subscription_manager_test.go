@@ -0,0 +1,178 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for SubscriptionManager unit tests
+type SubscriptionManagerUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run SubscriptionManagerUnitTestSuite test suite
+func TestSubscriptionManagerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionManagerUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test subscriptions are persisted on Subscribe/Unsubscribe and renewed when engine restarts.
+func (suite *SubscriptionManagerUnitTestSuite) TestSubscribeUnsubscribeAndRenew() {
+	backend := NewInMemorySubscriptionBackend()
+	manager, err := NewSubscriptionManager(backend, writeRequest("sub:"), writeRequest("unsub:"))
+	require.NoError(suite.T(), err)
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Text, mock.Anything).Return(nil)
+	ctx := context.Background()
+	// Subscribe to two topics - a second subscription to a topic fails
+	require.NoError(suite.T(), manager.Subscribe(ctx, connMock, Subscription{Topic: "a", Params: map[string]string{"depth": "10"}}))
+	require.NoError(suite.T(), manager.Subscribe(ctx, connMock, Subscription{Topic: "b"}))
+	require.Error(suite.T(), manager.Subscribe(ctx, connMock, Subscription{Topic: "a"}))
+	saved, err := backend.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []Subscription{{Topic: "a", Params: map[string]string{"depth": "10"}}, {Topic: "b"}}, saved)
+	// Unsubscribe from first topic - unsubscribing again fails
+	require.NoError(suite.T(), manager.Unsubscribe(ctx, connMock, "a"))
+	require.Error(suite.T(), manager.Unsubscribe(ctx, connMock, "a"))
+	saved, err = backend.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []Subscription{{Topic: "b"}}, saved)
+	require.Equal(suite.T(), saved, manager.Subscriptions())
+	// A new manager loads persisted subscriptions and renews them on restart only
+	renewed, err := NewSubscriptionManager(backend, writeRequest("sub:"), writeRequest("unsub:"))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), saved, renewed.Subscriptions())
+	require.NoError(suite.T(), renewed.OnOpen(ctx, connMock, false))
+	connMock.AssertNumberOfCalls(suite.T(), "Write", 3)
+	require.NoError(suite.T(), renewed.OnOpen(ctx, connMock, true))
+	connMock.AssertNumberOfCalls(suite.T(), "Write", 4)
+	connMock.AssertCalled(suite.T(), "Write", mock.Anything, wsadapters.Text, []byte("sub:b"))
+	connMock.AssertCalled(suite.T(), "Write", mock.Anything, wsadapters.Text, []byte("unsub:a"))
+}
+
+// Test failed requests are not recorded and renewal failures are reported.
+func (suite *SubscriptionManagerUnitTestSuite) TestRequestFailures() {
+	backend := NewInMemorySubscriptionBackend()
+	backend.Save([]Subscription{{Topic: "a"}, {Topic: "b"}})
+	manager, err := NewSubscriptionManager(backend, writeRequest("sub:"), writeRequest("unsub:"))
+	require.NoError(suite.T(), err)
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Text, []byte("sub:a")).Return(nil)
+	connMock.On("Write", mock.Anything, wsadapters.Text, mock.Anything).Return(errors.New("write failed"))
+	ctx := context.Background()
+	require.Error(suite.T(), manager.Subscribe(ctx, connMock, Subscription{Topic: "c"}))
+	require.Error(suite.T(), manager.Unsubscribe(ctx, connMock, "b"))
+	require.Equal(suite.T(), []Subscription{{Topic: "a"}, {Topic: "b"}}, manager.Subscriptions())
+	// Renewal of b fails but a is renewed
+	require.ErrorContains(suite.T(), manager.OnOpen(ctx, connMock, true), "failed to subscribe to b")
+	connMock.AssertCalled(suite.T(), "Write", mock.Anything, wsadapters.Text, []byte("sub:a"))
+}
+
+// Test subscribe failures reported by the server are retried and reported once all attempts failed.
+func (suite *SubscriptionManagerUnitTestSuite) TestSubscribeRetry() {
+	backend := NewInMemorySubscriptionBackend()
+	sent := make(chan string, 10)
+	subscribe := func(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, sub Subscription) error {
+		sent <- sub.Topic
+		return nil
+	}
+	manager, err := NewSubscriptionManager(backend, subscribe, writeRequest("unsub:"))
+	require.NoError(suite.T(), err)
+	ctx := context.Background()
+	// Detection is disabled without detector
+	require.False(suite.T(), manager.HandleMessage(ctx, nil, []byte(`{"type":"error","topic":"a"}`)))
+	failed := make(chan error, 1)
+	manager.SubscribeRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialDelay: 10 * time.Millisecond}
+	manager.SubscribeErrorDetector = func(msg []byte, subID SubscriptionID) bool {
+		return string(msg) == fmt.Sprintf(`{"type":"error","topic":"%s"}`, subID)
+	}
+	manager.OnSubscribeError = func(ctx context.Context, subID SubscriptionID, err error) {
+		require.Equal(suite.T(), SubscriptionID("a"), subID)
+		failed <- err
+	}
+	require.NoError(suite.T(), manager.Subscribe(ctx, nil, Subscription{Topic: "a"}))
+	require.NoError(suite.T(), manager.Subscribe(ctx, nil, Subscription{Topic: "b"}))
+	require.Equal(suite.T(), "a", <-sent)
+	require.Equal(suite.T(), "b", <-sent)
+	// Other messages are not subscribe failures
+	require.False(suite.T(), manager.HandleMessage(ctx, nil, []byte(`{"type":"update","topic":"a"}`)))
+	// First two failures are retried
+	for i := 0; i < 2; i++ {
+		require.True(suite.T(), manager.HandleMessage(ctx, nil, []byte(`{"type":"error","topic":"a"}`)))
+		select {
+		case topic := <-sent:
+			require.Equal(suite.T(), "a", topic)
+		case <-time.After(5 * time.Second):
+			suite.FailNow("subscribe request has not been retried")
+		}
+	}
+	// Third failure drops the subscription and reports the error
+	require.True(suite.T(), manager.HandleMessage(ctx, nil, []byte(`{"type":"error","topic":"a"}`)))
+	select {
+	case err := <-failed:
+		require.ErrorIs(suite.T(), err, ErrSubscribeFailed)
+	case <-time.After(5 * time.Second):
+		suite.FailNow("subscribe error has not been reported")
+	}
+	require.Equal(suite.T(), []Subscription{{Topic: "b"}}, manager.Subscriptions())
+	saved, err := backend.Load()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []Subscription{{Topic: "b"}}, saved)
+	require.Empty(suite.T(), sent)
+}
+
+// Test scheduled retries are discarded when the subscription is removed meanwhile.
+func (suite *SubscriptionManagerUnitTestSuite) TestSubscribeRetryAfterUnsubscribe() {
+	sent := make(chan string, 10)
+	subscribe := func(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, sub Subscription) error {
+		sent <- sub.Topic
+		return nil
+	}
+	manager, err := NewSubscriptionManager(NewInMemorySubscriptionBackend(), subscribe, writeRequest("unsub:"))
+	require.NoError(suite.T(), err)
+	manager.SubscribeRetryPolicy = RetryPolicy{MaxAttempts: 2, InitialDelay: 50 * time.Millisecond}
+	manager.SubscribeErrorDetector = func(msg []byte, subID SubscriptionID) bool { return true }
+	connMock := wsadapters.NewWebsocketConnectionAdapterInterfaceMock()
+	connMock.On("Write", mock.Anything, wsadapters.Text, mock.Anything).Return(nil)
+	ctx := context.Background()
+	require.NoError(suite.T(), manager.Subscribe(ctx, connMock, Subscription{Topic: "a"}))
+	<-sent
+	require.True(suite.T(), manager.HandleMessage(ctx, connMock, []byte("error")))
+	require.NoError(suite.T(), manager.Unsubscribe(ctx, connMock, "a"))
+	time.Sleep(200 * time.Millisecond)
+	require.Empty(suite.T(), sent)
+}
+
+// Test factory rejects invalid parameters.
+func (suite *SubscriptionManagerUnitTestSuite) TestInvalidParameters() {
+	_, err := NewSubscriptionManager(nil, writeRequest("sub:"), writeRequest("unsub:"))
+	require.Error(suite.T(), err)
+	_, err = NewSubscriptionManager(NewInMemorySubscriptionBackend(), nil, writeRequest("unsub:"))
+	require.Error(suite.T(), err)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Build a RequestFunc which writes the topic with the provided prefix as a text message.
+func writeRequest(prefix string) RequestFunc {
+	return func(ctx context.Context, conn wsadapters.WebsocketConnectionAdapterInterface, sub Subscription) error {
+		return conn.Write(ctx, wsadapters.Text, []byte(prefix+sub.Topic))
+	}
+}
@@ -2,6 +2,7 @@ package wsclient
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"sync"
 
@@ -17,6 +18,10 @@ type CloseMessageDetails struct {
 }
 
 // Interface which defines callbacks called by the websocket client engine.
+//
+// All callbacks receive the ID of the session they are called for: a session ID is generated by
+// the engine each time it starts or restarts, before it dials the server, and is bound to the
+// websocket connection lifetime. It can be used to correlate the callbacks of a session.
 type WebsocketClientInterface interface {
 
 	// # Description
@@ -36,10 +41,16 @@ type WebsocketClientInterface interface {
 	// # Inputs
 	//
 	//	- ctx: context produced from the websocket engine context and bound to OnOpen lifecycle.
-	//	- resp: The server response to the websocket handshake.
-	//	- conn: Websocket adapter provided during engine creation. Connection is now opened.
+	//	- resp: The server response to the websocket handshake, as returned by the Dial method of
+	//    the connection adapter. The engine provides it as is, both when it starts and when it
+	//    restarts: response headers (Set-Cookie, Sec-WebSocket-Extensions, custom server headers)
+	//    can be used to set up the session. Can be nil if the adapter does not expose it.
+	//	- conn: Websocket adapter provided during engine creation. Connection is now opened. The subprotocol
+	//    selected by the server, if any, can be retrieved with conn.NegotiatedSubprotocol().
 	//	- readMutex: A reference to engine read mutex user can lock to pause the engine.
 	//	- exit: Function to call to definitely stop the engine (ex: when stuck in retry loop).
+	//	- sessionId: Unique identifier produced by engine for each new websocket connection and
+	//    bound to the websocket connection lifetime.
 	//	- restarting: Flag which indicates whether engine restarts (true) or is starting (false).
 	//
 	// # Returns
@@ -63,6 +74,7 @@ type WebsocketClientInterface interface {
 		conn wsadapters.WebsocketConnectionAdapterInterface,
 		readMutex *sync.Mutex,
 		exit context.CancelFunc,
+		sessionId string,
 		restarting bool) error
 
 	// # Description
@@ -74,6 +86,8 @@ type WebsocketClientInterface interface {
 	// # Inputs
 	//
 	//	- ctx: context produce from websocket engine context and bound to OnMessage lifecycle.
+	//    Pass ctx, or a context derived from it, to conn methods: the spans created for these
+	//    calls are then children of the spans started in the callback.
 	//	- conn: Websocket adapter provided during engine creation with a connection opened.
 	//	- readMutex: A reference to engine read mutex user can lock to pause the engine.
 	//	- restart: Function to call to instruct engine to stop and restart.
@@ -131,6 +145,7 @@ type WebsocketClientInterface interface {
 	//	- readMutex: A reference to engine read mutex user can lock to pause the engine.
 	//	- restart: Function to call to instruct engine to stop and restart.
 	//	- exit: Function to call to definitely stop the engine.
+	//	- sessionId: ID of the session the error occured in.
 	//	- err: Error returned by the websocket read operation.
 	//
 	// # Engine behavior on exit/restart call
@@ -149,6 +164,7 @@ type WebsocketClientInterface interface {
 		readMutex *sync.Mutex,
 		restart context.CancelFunc,
 		exit context.CancelFunc,
+		sessionId string,
 		err error)
 
 	// # Description
@@ -157,10 +173,13 @@ type WebsocketClientInterface interface {
 	// Stop method call or a call to the provided restart/exit functions. Callback is called once
 	// by the engine: the engine will not exit or restart until the callback has been completed.
 	//
-	// Callback can return an optional CloseMessageDetails which will be used to build the close
-	// message sent to the server if the connection needs to be closed after OnClose has finished.
-	// In such a case, if the returned value is nil, the engine will use 1001 "Going Away" as the
-	// close message.
+	// Callback can return an optional CloseMessageDetails which overrides the close message sent
+	// to the server: when the connection is still open after OnClose has finished (Stop, restart,
+	// exit, failed ping), the engine sends a close frame with the returned code and reason before
+	// it drops the connection. If the returned value is nil, the engine sends 1001 "Going Away",
+	// or drops the connection without close frame if the engine is configured with
+	// WithSkipCloseFrameOnNilOnClose.
+	// The returned value is ignored when the connection has already been closed by the server.
 	//
 	// Do not close the websocket connection here if it is still open: It will be automatically
 	// closed by the engine with a close message.
@@ -170,6 +189,7 @@ type WebsocketClientInterface interface {
 	//	- ctx: Context produced from the websocket engine context and bound to OnClose lifecycle.
 	//	- conn: Connection to the websocket server that is closed or about to close.
 	//	- readMutex: A reference to engine read mutex user can lock to pause the engine.
+	//	- sessionId: ID of the session which ends.
 	//	- closeMessage: Websocket close message received from server or generated by the engine
 	//    when connection has been closed. If nil, connection might not be closed and will be
 	//    closed by the engine using the returned close message or the default 1001 "Going Away".
@@ -186,6 +206,7 @@ type WebsocketClientInterface interface {
 		ctx context.Context,
 		conn wsadapters.WebsocketConnectionAdapterInterface,
 		readMutex *sync.Mutex,
+		sessionId string,
 		closeMessage *CloseMessageDetails) *CloseMessageDetails
 
 	// # Description
@@ -196,9 +217,14 @@ type WebsocketClientInterface interface {
 	// # Inputs
 	//
 	//	- ctx:  Context produced OnClose context.
-	//	- err: Error returned by conn.Close method
+	//	- sessionId: ID of the session which ends. When the engine stops reconnecting, ID of the
+	//    last failed restart attempt or of the last session if no attempt has been made.
+	//	- err: Error returned by conn.Close method - wrapped in a wscengine.CloseError when the
+	//    callback is called by the websocket engine. When the engine stops reconnecting,
+	//    wscengine.ErrReconnectAborted or wscengine.ErrMaxReconnectAttemptsExceeded.
 	OnCloseError(
 		ctx context.Context,
+		sessionId string,
 		err error)
 
 	// # Description
@@ -209,11 +235,59 @@ type WebsocketClientInterface interface {
 	//
 	//	- ctx:  Context used for tracing purpose. Will be Done in case a timeout has occured.
 	//	- exit: Function to call to stop trying to restart the engine.
+	//	- sessionId: ID generated for the failed restart attempt.
 	//	- err: Error which has occured when restarting the engine
 	//	- retryCount: Number of restart retry since last time engine has successfully (re)started.
 	OnRestartError(
 		ctx context.Context,
 		exit context.CancelFunc,
+		sessionId string,
 		err error,
 		retryCount int)
 }
+
+// Optional interface a WebsocketClientInterface implementation can implement to receive large
+// messages as a stream instead of a byte array. When the client provided to the engine implements
+// it, the engine reads messages with conn.ReadStream and routes the messages larger than the
+// configured stream threshold (see WithStreamThreshold) to OnMessageStream. Smaller messages are
+// still handed over to OnMessage.
+type WebsocketClientStreamInterface interface {
+
+	// # Description
+	//
+	// Callback called when a message larger than the stream threshold is read from the server.
+	//
+	// The engine holds the read mutex while the callback runs: no other message is read until
+	// the callback completes. Do not lock the read mutex in this callback. The part of the message
+	// which has not been read when the callback returns is discarded by the engine.
+	//
+	// Messages are not streamed when message middlewares are configured in the engine: the
+	// middlewares process whole messages which are then handed over to OnMessage.
+	//
+	// # Inputs
+	//
+	//	- ctx: context produce from websocket engine context and bound to OnMessageStream
+	//    lifecycle.
+	//	- conn: Websocket adapter provided during engine creation with a connection opened.
+	//	- readMutex: A reference to engine read mutex - held by the engine during the callback.
+	//	- restart: Function to call to instruct engine to stop and restart.
+	//	- exit: Function to call to definitely stop the engine.
+	//	- sessionId: Unique identifier produced by engine for each new websocket connection and
+	//    bound to the websocket connection lifetime.
+	//	- msgType: Message type returned by read function.
+	//	- reader: Reader which streams the message content. The reader is only valid during the
+	//    callback.
+	//
+	// # Engine behavior on exit/restart call
+	//
+	//	- Same as OnMessage.
+	OnMessageStream(
+		ctx context.Context,
+		conn wsadapters.WebsocketConnectionAdapterInterface,
+		readMutex *sync.Mutex,
+		restart context.CancelFunc,
+		exit context.CancelFunc,
+		sessionId string,
+		msgType wsadapters.MessageType,
+		reader io.Reader)
+}
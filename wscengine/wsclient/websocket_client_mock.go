@@ -26,9 +26,10 @@ func (mock *WebsocketClientMock) OnOpen(
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
 	exit context.CancelFunc,
+	sessionId string,
 	restarting bool) error {
 	// Call mocked method with provided args and return predefined return value if any
-	args := mock.Called(ctx, resp, conn, readMutex, exit, restarting)
+	args := mock.Called(ctx, resp, conn, readMutex, exit, sessionId, restarting)
 	return args.Error(0)
 }
 
@@ -53,9 +54,10 @@ func (mock *WebsocketClientMock) OnReadError(
 	readMutex *sync.Mutex,
 	restart context.CancelFunc,
 	exit context.CancelFunc,
+	sessionId string,
 	err error) {
 	// Call mocked method with provided args and return predefined return value if any
-	mock.Called(ctx, conn, readMutex, restart, exit, err)
+	mock.Called(ctx, conn, readMutex, restart, exit, sessionId, err)
 }
 
 // Mocked OnClose method
@@ -63,9 +65,10 @@ func (mock *WebsocketClientMock) OnClose(
 	ctx context.Context,
 	conn wsadapters.WebsocketConnectionAdapterInterface,
 	readMutex *sync.Mutex,
+	sessionId string,
 	closeMessage *CloseMessageDetails) *CloseMessageDetails {
 	// Call mocked method with provided args and return predefined return value if any
-	args := mock.Called(ctx, conn, readMutex, closeMessage)
+	args := mock.Called(ctx, conn, readMutex, sessionId, closeMessage)
 	if args.Get(0) != nil {
 		// Type assertion
 		msg, ok := args.Get(0).(*CloseMessageDetails)
@@ -82,18 +85,20 @@ func (mock *WebsocketClientMock) OnClose(
 // Mocked OnCloseError method
 func (mock *WebsocketClientMock) OnCloseError(
 	ctx context.Context,
+	sessionId string,
 	err error) {
 	// Call mocked method with provided args and return predefined return value if any
-	mock.Called(ctx, err)
+	mock.Called(ctx, sessionId, err)
 }
 
 func (mock *WebsocketClientMock) OnRestartError(
 	ctx context.Context,
 	exit context.CancelFunc,
+	sessionId string,
 	err error,
 	retryCount int) {
 	// Call mocked method with provided args and return predefined return value if any
-	mock.Called(ctx, exit, err, retryCount)
+	mock.Called(ctx, exit, sessionId, err, retryCount)
 }
 
 // Factory for WebsocketClientMock
@@ -0,0 +1,352 @@
+// Package which contains the server side counterpart of the websocket engine.
+//
+// The WebsocketServerEngine is a net/http handler which upgrades incoming HTTP requests to
+// websocket connections and runs, for each accepted connection, the same callbacks as the client
+// side engine (see wsclient.WebsocketClientInterface). Connections are wrapped in a
+// wsadapters.WebsocketConnectionAdapterInterface so the same adapters and client implementations
+// can be used on both sides.
+package wsserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/google/uuid"
+)
+
+// Error returned by Shutdown when the server engine has already been shut down. The error is also
+// used as the cancellation cause of the sessions canceled by Shutdown.
+var ErrServerClosed = errors.New("websocket server engine closed")
+
+// # Description
+//
+// Function which upgrades a HTTP request to a websocket connection.
+//
+// In case of failure, the function must reply to the client with a HTTP error and return an error.
+//
+// # Inputs
+//
+//   - w: Response writer of the HTTP request.
+//   - r: HTTP request to upgrade.
+//
+// # Returns
+//
+// An adapter for the upgraded connection or an error if the upgrade failed.
+type Upgrader func(w http.ResponseWriter, r *http.Request) (wsadapters.WebsocketConnectionAdapterInterface, error)
+
+// # Description
+//
+// Factory which provides the WebsocketClientInterface implementation which will handle the
+// callbacks of a connection. The factory is called before the request is upgraded: if it returns
+// an error, the request is rejected with a 500 Internal Server Error.
+//
+// The factory can return the same client for all connections if the client is safe for
+// concurrent use.
+type ClientFactory func(r *http.Request) (wsclient.WebsocketClientInterface, error)
+
+// # Description
+//
+// Build an Upgrader which uses the provided gorilla server adapter.
+//
+// # Inputs
+//
+//   - adapter: Gorilla server adapter used to upgrade HTTP requests.
+//   - responseHeader: Optional headers included in handshake responses (like Set-Cookie).
+//
+// # Returns
+//
+// An Upgrader which uses the provided gorilla server adapter.
+func GorillaUpgrader(adapter *gorilla.GorillaWebsocketServerAdapter, responseHeader http.Header) Upgrader {
+	return func(w http.ResponseWriter, r *http.Request) (wsadapters.WebsocketConnectionAdapterInterface, error) {
+		conn, err := adapter.Upgrade(w, r, responseHeader)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// Server side websocket engine.
+//
+// The engine implements http.Handler: each accepted connection is handled by a dedicated goroutine
+// which calls the client callbacks as follow:
+//   - OnOpen is called once the connection is accepted. The handshake response is not available
+//     on server side: resp is nil and restarting is false.
+//   - OnMessage is called for each received message, OnReadError for each read error which is not
+//     caused by the connection being closed.
+//   - OnClose is called once when the connection is closed by the peer, when restart or exit is
+//     called or when the engine shuts down. The connection is then closed with the returned close
+//     message or 1001 Going Away. OnCloseError is called if the connection fails to close.
+//
+// A server cannot reconnect: restart and exit both close the connection. OnRestartError is never
+// called.
+type WebsocketServerEngine struct {
+	// Upgrader used to accept connections
+	upgrader Upgrader
+	// Factory which provides the client callbacks of each connection
+	clientFactory ClientFactory
+	// Engine context - canceled by Shutdown
+	engineCtx    context.Context
+	engineCancel context.CancelCauseFunc
+	// Mutex which protects sessions and closed
+	mu sync.Mutex
+	// Active sessions indexed by session ID
+	sessions map[string]*serverSession
+	// True when the engine has been shut down
+	closed bool
+	// Wait group used to wait for sessions goroutines
+	wg sync.WaitGroup
+}
+
+// # Description
+//
+// Factory which creates a new WebsocketServerEngine.
+//
+// # Inputs
+//
+//   - upgrader: Function used to upgrade HTTP requests to websocket connections.
+//   - clientFactory: Factory which provides the client callbacks of each connection.
+//
+// # Returns
+//
+// New WebsocketServerEngine or an error if a mandatory input is missing.
+func NewWebsocketServerEngine(upgrader Upgrader, clientFactory ClientFactory) (*WebsocketServerEngine, error) {
+	if upgrader == nil {
+		return nil, fmt.Errorf("upgrader cannot be nil")
+	}
+	if clientFactory == nil {
+		return nil, fmt.Errorf("client factory cannot be nil")
+	}
+	engineCtx, engineCancel := context.WithCancelCause(context.Background())
+	return &WebsocketServerEngine{
+		upgrader:      upgrader,
+		clientFactory: clientFactory,
+		engineCtx:     engineCtx,
+		engineCancel:  engineCancel,
+		mu:            sync.Mutex{},
+		sessions:      map[string]*serverSession{},
+		closed:        false,
+		wg:            sync.WaitGroup{},
+	}, nil
+}
+
+// # Description
+//
+// Upgrade the HTTP request to a websocket connection and handle the connection in a dedicated
+// goroutine. Requests are rejected with a 503 Service Unavailable once the engine has been shut
+// down.
+func (engine *WebsocketServerEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Get the client callbacks for the connection
+	client, err := engine.clientFactory(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// Register the session before the upgrade so Shutdown waits for it
+	engine.mu.Lock()
+	if engine.closed {
+		engine.mu.Unlock()
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	engine.wg.Add(1)
+	engine.mu.Unlock()
+	// Upgrade the request - the upgrader replies with an HTTP error in case of failure
+	conn, err := engine.upgrader(w, r)
+	if err != nil {
+		engine.wg.Done()
+		return
+	}
+	// Create the session and run it in a dedicated goroutine
+	sessionCtx, cancelSession := context.WithCancelCause(engine.engineCtx)
+	session := &serverSession{
+		id:        uuid.New().String(),
+		conn:      conn,
+		client:    client,
+		readMutex: &sync.Mutex{},
+		ctx:       sessionCtx,
+		cancel:    cancelSession,
+		shutdown:  sync.Once{},
+	}
+	engine.mu.Lock()
+	engine.sessions[session.id] = session
+	engine.mu.Unlock()
+	go engine.runSession(session)
+}
+
+// # Description
+//
+// Stop accepting new connections, close all active connections and wait until all sessions
+// goroutines have exited.
+//
+// OnClose is called for each active connection which is then closed with the returned close
+// message or 1001 Going Away. The peers are expected to reply to the close message so pending
+// reads complete.
+//
+// # Inputs
+//
+//   - ctx: Context used to bound the time spent waiting for sessions goroutines.
+//
+// # Returns
+//
+// nil in case of success, the context error if the context is done before all sessions have
+// exited or ErrServerClosed if the engine has already been shut down.
+func (engine *WebsocketServerEngine) Shutdown(ctx context.Context) error {
+	engine.mu.Lock()
+	if engine.closed {
+		engine.mu.Unlock()
+		return ErrServerClosed
+	}
+	engine.closed = true
+	engine.mu.Unlock()
+	// Cancel all sessions
+	engine.engineCancel(ErrServerClosed)
+	// Wait for sessions goroutines
+	done := make(chan struct{})
+	go func() {
+		engine.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// # Description
+//
+// Return the number of active connections.
+func (engine *WebsocketServerEngine) ActiveConnections() int {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	return len(engine.sessions)
+}
+
+/*************************************************************************************************/
+/* INTERNAL                                                                                      */
+/*************************************************************************************************/
+
+// Connection accepted by the server engine
+type serverSession struct {
+	// Unique session ID bound to the connection lifetime
+	id string
+	// Accepted connection
+	conn wsadapters.WebsocketConnectionAdapterInterface
+	// Client callbacks
+	client wsclient.WebsocketClientInterface
+	// Read mutex provided to callbacks
+	readMutex *sync.Mutex
+	// Session context - canceled by restart/exit functions and engine shutdown
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	// Used to shut down the session once
+	shutdown sync.Once
+}
+
+// Exit function provided to callbacks: cancel the session so the connection is closed.
+func (session *serverSession) exit() {
+	session.cancel(context.Canceled)
+}
+
+// # Description
+//
+// Run the session: call OnOpen, then read messages until the session is canceled or the
+// connection is closed. The method removes the session from the engine when it exits.
+func (engine *WebsocketServerEngine) runSession(session *serverSession) {
+	defer engine.wg.Done()
+	defer func() {
+		engine.mu.Lock()
+		delete(engine.sessions, session.id)
+		engine.mu.Unlock()
+	}()
+	defer session.exit()
+	// Call OnOpen
+	err := session.client.OnOpen(session.ctx, nil, session.conn, session.readMutex, session.exit, session.id, false)
+	if err != nil {
+		// Close the connection without calling OnClose
+		closeErr := session.conn.Close(context.Background(), wsadapters.InternalError, "Internal Error")
+		if closeErr != nil {
+			session.client.OnCloseError(context.Background(), session.id, closeErr)
+		}
+		return
+	}
+	// Close the connection as soon as the session is canceled so the connection is closed even
+	// when the reading goroutine is blocked
+	go func() {
+		<-session.ctx.Done()
+		session.shutdown.Do(func() { engine.shutdownSession(session, nil, false) })
+	}()
+	for {
+		// Lock read mutex and read next message
+		session.readMutex.Lock()
+		msgType, msg, err := session.conn.Read(session.ctx)
+		select {
+		case <-session.ctx.Done():
+			// Session canceled - Wait for the session watcher to close the connection
+			session.readMutex.Unlock()
+			session.shutdown.Do(func() { engine.shutdownSession(session, nil, false) })
+			return
+		default:
+		}
+		if err != nil {
+			closeErr := new(wsadapters.WebsocketCloseError)
+			if errors.As(err, closeErr) {
+				// Connection has been closed by the peer - skip connection close
+				session.readMutex.Unlock()
+				session.shutdown.Do(func() {
+					engine.shutdownSession(session, &wsclient.CloseMessageDetails{
+						CloseReason:  closeErr.Code,
+						CloseMessage: closeErr.Reason,
+					}, true)
+				})
+				return
+			}
+			// Call OnReadError and loop unless session has been canceled
+			session.client.OnReadError(session.ctx, session.conn, session.readMutex, session.exit, session.exit, session.id, err)
+			session.readMutex.Unlock()
+			continue
+		}
+		// Release read mutex and process message
+		session.readMutex.Unlock()
+		session.client.OnMessage(session.ctx, session.conn, session.readMutex, session.exit, session.exit, session.id, msgType, msg)
+	}
+}
+
+// # Description
+//
+// Call OnClose and close the connection with the returned close message or 1001 Going Away.
+//
+// # Inputs
+//
+//   - session: Session to shut down.
+//   - closeMessage: Close message received from the peer. Can be nil.
+//   - skipWebsocketClose: Skip connection close (because connection is already closed).
+func (engine *WebsocketServerEngine) shutdownSession(
+	session *serverSession,
+	closeMessage *wsclient.CloseMessageDetails,
+	skipWebsocketClose bool) {
+	// Session context is canceled: use a fresh context for OnClose and Close
+	ctx := context.Background()
+	cmsg := session.client.OnClose(ctx, session.conn, session.readMutex, session.id, closeMessage)
+	if skipWebsocketClose {
+		return
+	}
+	if cmsg == nil {
+		cmsg = &wsclient.CloseMessageDetails{
+			CloseReason:  wsadapters.GoingAway,
+			CloseMessage: "Going away",
+		}
+	}
+	err := session.conn.Close(ctx, cmsg.CloseReason, cmsg.CloseMessage)
+	if err != nil {
+		session.client.OnCloseError(ctx, session.id, err)
+	}
+}
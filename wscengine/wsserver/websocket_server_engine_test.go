@@ -0,0 +1,206 @@
+package wsserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsadapters/gorilla"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/gbdevw/gowse/wscengine/wstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITE                                                                                    */
+/*************************************************************************************************/
+
+// Test suite used for WebsocketServerEngine tests
+type WebsocketServerEngineTestSuite struct {
+	suite.Suite
+}
+
+// Run WebsocketServerEngineTestSuite test suite
+func TestWebsocketServerEngineTestSuite(t *testing.T) {
+	suite.Run(t, new(WebsocketServerEngineTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Test the factory rejects missing inputs.
+func (suite *WebsocketServerEngineTestSuite) TestNewWebsocketServerEngineInvalidInputs() {
+	upgrader := GorillaUpgrader(gorilla.NewGorillaWebsocketServerAdapter(nil), nil)
+	factory := func(r *http.Request) (wsclient.WebsocketClientInterface, error) {
+		return wstest.NewRecordingClient(), nil
+	}
+	_, err := NewWebsocketServerEngine(nil, factory)
+	require.Error(suite.T(), err)
+	_, err = NewWebsocketServerEngine(upgrader, nil)
+	require.Error(suite.T(), err)
+	engine, err := NewWebsocketServerEngine(upgrader, factory)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), engine)
+}
+
+// Test requests are rejected with a 500 when the client factory fails.
+func (suite *WebsocketServerEngineTestSuite) TestClientFactoryError() {
+	upgrader := GorillaUpgrader(gorilla.NewGorillaWebsocketServerAdapter(nil), nil)
+	engine, err := NewWebsocketServerEngine(upgrader, func(r *http.Request) (wsclient.WebsocketClientInterface, error) {
+		return nil, fmt.Errorf("rejected")
+	})
+	require.NoError(suite.T(), err)
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	_, res, err := websocket.DefaultDialer.Dial(toWebsocketURL(srv.URL), nil)
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusInternalServerError, res.StatusCode)
+}
+
+/*************************************************************************************************/
+/* INTEGRATION TESTS                                                                             */
+/*************************************************************************************************/
+
+// Test callbacks are called for an accepted connection and OnClose receives the close message
+// sent by the peer.
+func (suite *WebsocketServerEngineTestSuite) TestEchoAndPeerClose() {
+	client := newEchoClient()
+	engine, srv := newTestServer(suite.T(), client)
+	defer srv.Close()
+	defer engine.Shutdown(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(toWebsocketURL(srv.URL), nil)
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+	// Messages are handed over to OnMessage
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	msgType, msg, err := conn.ReadMessage()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), websocket.TextMessage, msgType)
+	require.Equal(suite.T(), "hello", string(msg))
+	require.Equal(suite.T(), 1, engine.ActiveConnections())
+	opens := client.RecordedOnOpens()
+	require.Len(suite.T(), opens, 1)
+	require.Nil(suite.T(), opens[0].Resp)
+	require.False(suite.T(), opens[0].Restarting)
+	require.NotEmpty(suite.T(), client.RecordedOnMessages()[0].SessionId)
+	// Peer closes the connection
+	require.NoError(suite.T(), conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"),
+		time.Now().Add(time.Second)))
+	require.Eventually(suite.T(), func() bool {
+		return engine.ActiveConnections() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	closes := client.RecordedOnCloses()
+	require.Len(suite.T(), closes, 1)
+	require.NotNil(suite.T(), closes[0].CloseMessage)
+	require.Equal(suite.T(), wsadapters.NormalClosure, closes[0].CloseMessage.CloseReason)
+	require.Contains(suite.T(), closes[0].CloseMessage.CloseMessage, "bye")
+}
+
+// Test Shutdown closes active connections with 1001 Going Away and rejects new requests.
+func (suite *WebsocketServerEngineTestSuite) TestShutdown() {
+	client := newEchoClient()
+	engine, srv := newTestServer(suite.T(), client)
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial(toWebsocketURL(srv.URL), nil)
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+	require.Eventually(suite.T(), func() bool {
+		return engine.ActiveConnections() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// Read in a separate goroutine so the close message is echoed by the peer
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(suite.T(), engine.Shutdown(ctx))
+	require.Equal(suite.T(), 0, engine.ActiveConnections())
+	require.True(suite.T(), websocket.IsCloseError(<-readErr, websocket.CloseGoingAway))
+	closes := client.RecordedOnCloses()
+	require.Len(suite.T(), closes, 1)
+	require.Nil(suite.T(), closes[0].CloseMessage)
+	// New requests are rejected
+	_, res, err := websocket.DefaultDialer.Dial(toWebsocketURL(srv.URL), nil)
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusServiceUnavailable, res.StatusCode)
+	require.ErrorIs(suite.T(), engine.Shutdown(ctx), ErrServerClosed)
+}
+
+// Test calling exit from OnMessage closes the connection.
+func (suite *WebsocketServerEngineTestSuite) TestExit() {
+	client := newEchoClient()
+	client.exitOn = "exit"
+	engine, srv := newTestServer(suite.T(), client)
+	defer srv.Close()
+	defer engine.Shutdown(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(toWebsocketURL(srv.URL), nil)
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+	require.NoError(suite.T(), conn.WriteMessage(websocket.TextMessage, []byte("exit")))
+	_, _, err = conn.ReadMessage()
+	require.True(suite.T(), websocket.IsCloseError(err, websocket.CloseGoingAway))
+	require.Eventually(suite.T(), func() bool {
+		return engine.ActiveConnections() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(suite.T(), client.RecordedOnCloses(), 1)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// Recording client which echoes received messages and calls exit when it receives exitOn
+type echoClient struct {
+	*wstest.RecordingClient
+	exitOn string
+}
+
+func newEchoClient() *echoClient {
+	return &echoClient{RecordingClient: wstest.NewRecordingClient()}
+}
+
+// Record the call and echo the message
+func (client *echoClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.RecordingClient.OnMessage(ctx, conn, readMutex, restart, exit, sessionId, msgType, msg)
+	if client.exitOn != "" && string(msg) == client.exitOn {
+		exit()
+		return
+	}
+	conn.Write(ctx, msgType, msg)
+}
+
+// Start a test HTTP server which uses a server engine with the provided client
+func newTestServer(t *testing.T, client wsclient.WebsocketClientInterface) (*WebsocketServerEngine, *httptest.Server) {
+	upgrader := GorillaUpgrader(gorilla.NewGorillaWebsocketServerAdapter(nil), nil)
+	engine, err := NewWebsocketServerEngine(upgrader, func(r *http.Request) (wsclient.WebsocketClientInterface, error) {
+		return client, nil
+	})
+	require.NoError(t, err)
+	return engine, httptest.NewServer(engine)
+}
+
+// Convert a http test server URL to a websocket URL
+func toWebsocketURL(url string) string {
+	return "ws" + strings.TrimPrefix(url, "http")
+}
@@ -0,0 +1,351 @@
+// The package provides helpers to test applications which use the websocket engine.
+package wstest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// Recorded OnOpen call
+type OnOpenCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx        context.Context
+	Resp       *http.Response
+	Conn       wsadapters.WebsocketConnectionAdapterInterface
+	ReadMutex  *sync.Mutex
+	Exit       context.CancelFunc
+	SessionId  string
+	Restarting bool
+}
+
+// Recorded OnMessage call
+type OnMessageCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx       context.Context
+	Conn      wsadapters.WebsocketConnectionAdapterInterface
+	ReadMutex *sync.Mutex
+	Restart   context.CancelFunc
+	Exit      context.CancelFunc
+	SessionId string
+	MsgType   wsadapters.MessageType
+	Msg       []byte
+}
+
+// Recorded OnReadError call
+type OnReadErrorCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx       context.Context
+	Conn      wsadapters.WebsocketConnectionAdapterInterface
+	ReadMutex *sync.Mutex
+	Restart   context.CancelFunc
+	Exit      context.CancelFunc
+	SessionId string
+	Err       error
+}
+
+// Recorded OnClose call
+type OnCloseCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx          context.Context
+	Conn         wsadapters.WebsocketConnectionAdapterInterface
+	ReadMutex    *sync.Mutex
+	SessionId    string
+	CloseMessage *wsclient.CloseMessageDetails
+}
+
+// Recorded OnCloseError call
+type OnCloseErrorCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx       context.Context
+	SessionId string
+	Err       error
+}
+
+// Recorded OnRestartError call
+type OnRestartErrorCall struct {
+	// Time when the callback has been called
+	Timestamp time.Time
+	// Callback arguments
+	Ctx        context.Context
+	Exit       context.CancelFunc
+	SessionId  string
+	Err        error
+	RetryCount int
+}
+
+// WebsocketClientInterface implementation which records all callback invocations so tests can
+// assert on them. OnOpen returns nil and OnClose returns nil (= engine default close message).
+//
+// The client is safe for concurrent use: callbacks can be called by engine goroutines while the
+// test reads recorded calls.
+type RecordingClient struct {
+	// Mutex used to protect recorded calls
+	mu sync.Mutex
+	// Channel closed and replaced each time a call is recorded
+	changed chan struct{}
+	// Recorded calls
+	onOpens         []OnOpenCall
+	onMessages      []OnMessageCall
+	onReadErrors    []OnReadErrorCall
+	onCloses        []OnCloseCall
+	onCloseErrors   []OnCloseErrorCall
+	onRestartErrors []OnRestartErrorCall
+}
+
+// # Description
+//
+// Factory which creates a new RecordingClient with no recorded calls.
+func NewRecordingClient() *RecordingClient {
+	return &RecordingClient{changed: make(chan struct{})}
+}
+
+// Record the OnOpen call and return nil.
+func (client *RecordingClient) OnOpen(
+	ctx context.Context,
+	resp *http.Response,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	exit context.CancelFunc,
+	sessionId string,
+	restarting bool) error {
+	client.record(func() {
+		client.onOpens = append(client.onOpens, OnOpenCall{
+			Timestamp:  time.Now(),
+			Ctx:        ctx,
+			Resp:       resp,
+			Conn:       conn,
+			ReadMutex:  readMutex,
+			Exit:       exit,
+			SessionId:  sessionId,
+			Restarting: restarting,
+		})
+	})
+	return nil
+}
+
+// Record the OnMessage call.
+func (client *RecordingClient) OnMessage(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	msgType wsadapters.MessageType,
+	msg []byte) {
+	client.record(func() {
+		client.onMessages = append(client.onMessages, OnMessageCall{
+			Timestamp: time.Now(),
+			Ctx:       ctx,
+			Conn:      conn,
+			ReadMutex: readMutex,
+			Restart:   restart,
+			Exit:      exit,
+			SessionId: sessionId,
+			MsgType:   msgType,
+			Msg:       msg,
+		})
+	})
+}
+
+// Record the OnReadError call.
+func (client *RecordingClient) OnReadError(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	restart context.CancelFunc,
+	exit context.CancelFunc,
+	sessionId string,
+	err error) {
+	client.record(func() {
+		client.onReadErrors = append(client.onReadErrors, OnReadErrorCall{
+			Timestamp: time.Now(),
+			Ctx:       ctx,
+			Conn:      conn,
+			ReadMutex: readMutex,
+			Restart:   restart,
+			Exit:      exit,
+			SessionId: sessionId,
+			Err:       err,
+		})
+	})
+}
+
+// Record the OnClose call and return nil.
+func (client *RecordingClient) OnClose(
+	ctx context.Context,
+	conn wsadapters.WebsocketConnectionAdapterInterface,
+	readMutex *sync.Mutex,
+	sessionId string,
+	closeMessage *wsclient.CloseMessageDetails) *wsclient.CloseMessageDetails {
+	client.record(func() {
+		client.onCloses = append(client.onCloses, OnCloseCall{
+			Timestamp:    time.Now(),
+			Ctx:          ctx,
+			Conn:         conn,
+			ReadMutex:    readMutex,
+			SessionId:    sessionId,
+			CloseMessage: closeMessage,
+		})
+	})
+	return nil
+}
+
+// Record the OnCloseError call.
+func (client *RecordingClient) OnCloseError(ctx context.Context, sessionId string, err error) {
+	client.record(func() {
+		client.onCloseErrors = append(client.onCloseErrors, OnCloseErrorCall{
+			Timestamp: time.Now(),
+			Ctx:       ctx,
+			SessionId: sessionId,
+			Err:       err,
+		})
+	})
+}
+
+// Record the OnRestartError call.
+func (client *RecordingClient) OnRestartError(ctx context.Context, exit context.CancelFunc, sessionId string, err error, retryCount int) {
+	client.record(func() {
+		client.onRestartErrors = append(client.onRestartErrors, OnRestartErrorCall{
+			Timestamp:  time.Now(),
+			Ctx:        ctx,
+			Exit:       exit,
+			SessionId:  sessionId,
+			Err:        err,
+			RetryCount: retryCount,
+		})
+	})
+}
+
+// # Description
+//
+// Return a copy of the recorded OnOpen calls, in call order.
+func (client *RecordingClient) RecordedOnOpens() []OnOpenCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnOpenCall(nil), client.onOpens...)
+}
+
+// # Description
+//
+// Return a copy of the recorded OnMessage calls, in call order.
+func (client *RecordingClient) RecordedOnMessages() []OnMessageCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnMessageCall(nil), client.onMessages...)
+}
+
+// # Description
+//
+// Return a copy of the recorded OnReadError calls, in call order.
+func (client *RecordingClient) RecordedOnReadErrors() []OnReadErrorCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnReadErrorCall(nil), client.onReadErrors...)
+}
+
+// # Description
+//
+// Return a copy of the recorded OnClose calls, in call order.
+func (client *RecordingClient) RecordedOnCloses() []OnCloseCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnCloseCall(nil), client.onCloses...)
+}
+
+// # Description
+//
+// Return a copy of the recorded OnCloseError calls, in call order.
+func (client *RecordingClient) RecordedOnCloseErrors() []OnCloseErrorCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnCloseErrorCall(nil), client.onCloseErrors...)
+}
+
+// # Description
+//
+// Return a copy of the recorded OnRestartError calls, in call order.
+func (client *RecordingClient) RecordedOnRestartErrors() []OnRestartErrorCall {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]OnRestartErrorCall(nil), client.onRestartErrors...)
+}
+
+// # Description
+//
+// Assert OnOpen has been called exactly count times.
+//
+// # Returns
+//
+// True if the assertion succeeded. Otherwise, the test is marked as failed and false is returned.
+func (client *RecordingClient) AssertOnOpenCalled(t testing.TB, count int) bool {
+	t.Helper()
+	return assert.Len(t, client.RecordedOnOpens(), count, "unexpected number of OnOpen calls")
+}
+
+// # Description
+//
+// Assert OnMessage has been called exactly expected times.
+//
+// # Returns
+//
+// True if the assertion succeeded. Otherwise, the test is marked as failed and false is returned.
+func (client *RecordingClient) AssertOnMessageCount(t testing.TB, expected int) bool {
+	t.Helper()
+	return assert.Len(t, client.RecordedOnMessages(), expected, "unexpected number of OnMessage calls")
+}
+
+// # Description
+//
+// Wait until OnMessage has been called at least n times or until timeout expires.
+//
+// # Returns
+//
+// True if OnMessage has been called at least n times before timeout expired. Otherwise, the test
+// is marked as failed and false is returned.
+func (client *RecordingClient) WaitForMessageCount(t testing.TB, n int, timeout time.Duration) bool {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		client.mu.Lock()
+		count := len(client.onMessages)
+		changed := client.changed
+		client.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return assert.Fail(t, "timeout while waiting for OnMessage calls",
+				"expected at least %d OnMessage calls within %s, got %d", n, timeout, len(client.RecordedOnMessages()))
+		}
+	}
+}
+
+// Record a call with the provided function and notify waiters.
+func (client *RecordingClient) record(fn func()) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	fn()
+	close(client.changed)
+	client.changed = make(chan struct{})
+}
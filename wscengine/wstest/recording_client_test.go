@@ -0,0 +1,113 @@
+package wstest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbdevw/gowse/wscengine/wsadapters"
+	"github.com/gbdevw/gowse/wscengine/wsclient"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+/*************************************************************************************************/
+/* TEST SUITES                                                                                   */
+/*************************************************************************************************/
+
+// Test suite used for RecordingClient unit tests
+type RecordingClientUnitTestSuite struct {
+	suite.Suite
+}
+
+// Run RecordingClientUnitTestSuite test suite
+func TestRecordingClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RecordingClientUnitTestSuite))
+}
+
+/*************************************************************************************************/
+/* UNIT TESTS                                                                                    */
+/*************************************************************************************************/
+
+// Check client fully implements interface.
+func (suite *RecordingClientUnitTestSuite) TestInterfaceCompliance() {
+	var impl interface{} = NewRecordingClient()
+	_, ok := impl.(wsclient.WebsocketClientInterface)
+	require.True(suite.T(), ok)
+}
+
+// Test all callback invocations are recorded with their arguments.
+func (suite *RecordingClientUnitTestSuite) TestRecordedCalls() {
+	client := NewRecordingClient()
+	ctx := context.Background()
+	readMutex := &sync.Mutex{}
+	readErr := errors.New("read failed")
+	closeMessage := &wsclient.CloseMessageDetails{CloseReason: wsadapters.NormalClosure, CloseMessage: "bye"}
+	before := time.Now()
+	require.NoError(suite.T(), client.OnOpen(ctx, nil, nil, readMutex, nil, "s1", true))
+	client.OnMessage(ctx, nil, readMutex, nil, nil, "session", wsadapters.Text, []byte("hello"))
+	client.OnReadError(ctx, nil, readMutex, nil, nil, "s1", readErr)
+	require.Nil(suite.T(), client.OnClose(ctx, nil, readMutex, "s1", closeMessage))
+	client.OnCloseError(ctx, "s1", readErr)
+	client.OnRestartError(ctx, nil, "s1", readErr, 3)
+	// Check recorded calls
+	opens := client.RecordedOnOpens()
+	require.Len(suite.T(), opens, 1)
+	require.True(suite.T(), opens[0].Restarting)
+	require.Same(suite.T(), readMutex, opens[0].ReadMutex)
+	require.False(suite.T(), opens[0].Timestamp.Before(before))
+	messages := client.RecordedOnMessages()
+	require.Len(suite.T(), messages, 1)
+	require.Equal(suite.T(), "session", messages[0].SessionId)
+	require.Equal(suite.T(), wsadapters.Text, messages[0].MsgType)
+	require.Equal(suite.T(), []byte("hello"), messages[0].Msg)
+	require.Equal(suite.T(), readErr, client.RecordedOnReadErrors()[0].Err)
+	require.Equal(suite.T(), closeMessage, client.RecordedOnCloses()[0].CloseMessage)
+	require.Equal(suite.T(), readErr, client.RecordedOnCloseErrors()[0].Err)
+	require.Equal(suite.T(), 3, client.RecordedOnRestartErrors()[0].RetryCount)
+	// Check assertions
+	require.True(suite.T(), client.AssertOnOpenCalled(suite.T(), 1))
+	require.True(suite.T(), client.AssertOnMessageCount(suite.T(), 1))
+	failing := &recordingT{}
+	require.False(suite.T(), client.AssertOnOpenCalled(failing, 2))
+	require.False(suite.T(), client.AssertOnMessageCount(failing, 0))
+	require.Equal(suite.T(), 2, failing.errors)
+}
+
+// Test WaitForMessageCount waits for messages recorded by other goroutines.
+func (suite *RecordingClientUnitTestSuite) TestWaitForMessageCount() {
+	client := NewRecordingClient()
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			client.OnMessage(context.Background(), nil, nil, nil, nil, "session", wsadapters.Binary, []byte{byte(i)})
+		}
+	}()
+	require.True(suite.T(), client.WaitForMessageCount(suite.T(), 3, 5*time.Second))
+	// Timeout
+	failing := &recordingT{}
+	require.False(suite.T(), client.WaitForMessageCount(failing, 4, 50*time.Millisecond))
+	require.Equal(suite.T(), 1, failing.errors)
+}
+
+/*************************************************************************************************/
+/* UTILS                                                                                         */
+/*************************************************************************************************/
+
+// testing.TB implementation which counts reported errors instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors int
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors++
+}
+
+func (t *recordingT) Name() string {
+	return "recordingT"
+}